@@ -21,6 +21,33 @@ type Config struct {
 	TLSCertFile           string `toml:"tls_x509_cert_file"`
 	TLSKeyFile            string `toml:"tls_x509_key_file"`
 
+	// Interval in seconds between echo requests sent to switches.
+	EchoInterval int `toml:"echo_interval"`
+
+	// Number of missed echo replies, after which switch is torn down.
+	EchoMissLimit int `toml:"echo_miss_limit"`
+
+	// Interval in seconds between port statistics requests.
+	StatsInterval int `toml:"stats_interval"`
+
+	// Number of port statistics samples stored in memory for each port.
+	StatsHistoryLen int `toml:"stats_history_len"`
+
+	// Save port statistics samples into database.
+	StatsPersist bool `toml:"stats_persist"`
+
+	// Handling of installed flows on switch boot (flush, reconcile).
+	BootMode string `toml:"boot_mode"`
+
+	// Leader election backend for switch roles (none, file, postgres).
+	RoleElection string `toml:"role_election"`
+
+	// Directory with lock files of file election backend.
+	RoleElectionDir string `toml:"role_election_dir"`
+
+	// Interval in seconds between attempts to become a master.
+	RoleElectionInterval int `toml:"role_election_interval"`
+
 	Database map[string]DatabaseConfig `toml:"database"`
 }
 
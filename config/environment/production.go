@@ -3,9 +3,17 @@
 package environment
 
 import (
+	_ "github.com/netrack/netrack/netutil/bgp.v4"
+	_ "github.com/netrack/netrack/netutil/bridge"
+	_ "github.com/netrack/netrack/netutil/dhcp"
 	_ "github.com/netrack/netrack/netutil/drivers"
 	_ "github.com/netrack/netrack/netutil/ip.v4"
+	_ "github.com/netrack/netrack/netutil/ip.v6"
+	_ "github.com/netrack/netrack/netutil/lldp"
+	_ "github.com/netrack/netrack/netutil/ofp.v10"
 	_ "github.com/netrack/netrack/netutil/ofp.v13"
+	_ "github.com/netrack/netrack/netutil/ospf.v2"
+	_ "github.com/netrack/netrack/netutil/rip.v2"
 )
 
 const Env = "production"
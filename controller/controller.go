@@ -4,12 +4,14 @@ import (
 	"crypto/tls"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/netrack/netrack/config"
 	"github.com/netrack/netrack/database"
 	"github.com/netrack/netrack/httputil"
 	"github.com/netrack/netrack/logging"
 	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/mechanism/election"
 	"github.com/netrack/openflow"
 )
 
@@ -41,6 +43,34 @@ func (c *C) initializeDatabase() {
 	db.DefaultDB = persister
 }
 
+func (c *C) initializeElection() {
+	var elector election.Elector
+	var err error
+
+	switch c.Config.RoleElection {
+	case "", "none":
+		return
+	case "file":
+		elector, err = election.NewFileElector(c.Config.RoleElectionDir, c.Config.ID)
+	case "postgres":
+		elector, err = election.NewPGElector(c.Config.ConnString(), c.Config.ID)
+	default:
+		log.FatalLog("controller/INITIALIZE_ELECTION",
+			"Unknown role_election parameter: ", c.Config.RoleElection)
+	}
+
+	if err != nil {
+		log.FatalLog("controller/INITIALIZE_ELECTION",
+			"Failed to initialize leader election: ", err)
+	}
+
+	log.InfoLog("controller/INITIALIZE_ELECTION",
+		"Leader election enabled with backend: ", c.Config.RoleElection)
+
+	c.switchManager.Elector = elector
+	c.switchManager.ElectionInterval = time.Duration(c.Config.RoleElectionInterval) * time.Second
+}
+
 func (c *C) initializeSwitches() {
 	u, err := url.Parse(c.Config.OFPEndpoint)
 	if err != nil {
@@ -51,6 +81,25 @@ func (c *C) initializeSwitches() {
 	log.DebugLogf("controller/INITIALIZE_SWITCHES",
 		"Starting serving OFP at: %s://%s", u.Scheme, u.Host)
 
+	// Configure switch liveness monitoring.
+	c.switchManager.EchoInterval = time.Duration(c.Config.EchoInterval) * time.Second
+	c.switchManager.EchoMissLimit = c.Config.EchoMissLimit
+
+	// Configure port statistics collection.
+	c.switchManager.StatsInterval = time.Duration(c.Config.StatsInterval) * time.Second
+	c.switchManager.StatsHistoryLen = c.Config.StatsHistoryLen
+	c.switchManager.StatsPersist = c.Config.StatsPersist
+
+	bootMode, err := mech.ParseBootMode(c.Config.BootMode)
+	if err != nil {
+		log.FatalLog("controller/PARSE_BOOT_MODE_ERR",
+			"Failed to parse boot_mode parameter: ", err)
+	}
+
+	c.switchManager.BootMode = bootMode
+
+	c.initializeElection()
+
 	var l *of.Listener
 
 	if c.Config.TLSEnable {
@@ -27,6 +27,7 @@ type Stmt struct {
 	Update *sql.Stmt
 	Read   *sql.Stmt
 	Delete *sql.Stmt
+	List   *sql.Stmt
 }
 
 type Statementer interface {
@@ -60,6 +61,10 @@ type ModelPersister interface {
 
 	// Delete deletes single record specified by ID.
 	Delete(Model, string) error
+
+	// List reads all records of a table, specified in a Model
+	// parameter, to the slice.
+	List(Model, interface{}) error
 }
 
 type Persister interface {
@@ -123,6 +128,38 @@ func (db *sqlDB) readRecord(m interface{}, stmt *sql.Stmt, args ...interface{})
 	return json.Unmarshal(b, m)
 }
 
+func (db *sqlDB) readRecords(m interface{}, stmt *sql.Stmt) error {
+	rows, err := stmt.Query()
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	// Join records into JSON array to decode them at once.
+	records := []byte{'['}
+
+	for rows.Next() {
+		var b []byte
+		if err = rows.Scan(&b); err != nil {
+			return err
+		}
+
+		if len(records) > 1 {
+			records = append(records, ',')
+		}
+
+		records = append(records, b...)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	records = append(records, ']')
+	return json.Unmarshal(records, m)
+}
+
 // Lock reads record from the database for update.
 func (db *sqlDB) Lock(m Model, id string, s interface{}) error {
 	stmt, err := db.stmts.Stmt(m)
@@ -182,6 +219,16 @@ func (db *sqlDB) Delete(m Model, id string) error {
 	return nil
 }
 
+// List retrieves all records of the model from the database
+func (db *sqlDB) List(m Model, s interface{}) error {
+	stmt, err := db.stmts.Stmt(m)
+	if err != nil {
+		return err
+	}
+
+	return db.readRecords(s, stmt.List)
+}
+
 func (db *sqlDB) Transaction(fn func(ModelPersister) error) error {
 	tx, err := db.sqldb.Begin()
 	if err != nil {
@@ -201,6 +248,7 @@ func (db *sqlDB) Transaction(fn func(ModelPersister) error) error {
 			Update: tx.Stmt(stmt.Update),
 			Read:   tx.Stmt(stmt.Read),
 			Delete: tx.Stmt(stmt.Delete),
+			List:   tx.Stmt(stmt.List),
 		}, nil
 	})
 
@@ -241,6 +289,10 @@ func Delete(m Model, id string) error {
 	return DefaultDB.Delete(m, id)
 }
 
+func List(m Model, s interface{}) error {
+	return DefaultDB.List(m, s)
+}
+
 func Transaction(fn func(ModelPersister) error) error {
 	return DefaultDB.Transaction(fn)
 }
@@ -99,6 +99,40 @@ func TestDBDelete(t *testing.T) {
 	})
 }
 
+func TestDBList(t *testing.T) {
+	withDb(t, func() {
+		var testrecords []map[string]interface{}
+		err := DefaultDB.List(FakeModel, &testrecords)
+		if err != nil {
+			t.Fatalf("Failed to list empty table: '%s'", err)
+		}
+
+		if len(testrecords) != 0 {
+			t.Fatalf("Empty table returned records: %v", testrecords)
+		}
+
+		records := []map[string]interface{}{
+			{"id": "5", "column": "12345"},
+			{"id": "6", "column": "01234"},
+		}
+
+		for _, record := range records {
+			if err = DefaultDB.Create(FakeModel, record); err != nil {
+				t.Fatalf("Failed to create a new record: '%s'", err)
+			}
+		}
+
+		err = DefaultDB.List(FakeModel, &testrecords)
+		if err != nil {
+			t.Fatalf("Failed to list records: '%s'", err)
+		}
+
+		if !reflect.DeepEqual(records, testrecords) {
+			t.Fatalf("Records are not equal")
+		}
+	})
+}
+
 func withDb(t *testing.T, fn func()) {
 	config, err := test.Config()
 	if err != nil {
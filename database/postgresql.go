@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	_ "github.com/lib/pq"
 )
@@ -23,23 +24,36 @@ func (s pgStmt) Stmt(m Model) (*Stmt, error) {
 	return nil, errors.New("db: model in not presetnt")
 }
 
+// tableName returns plural form of the resource name.
+func tableName(resource string) string {
+	for _, suffix := range []string{"s", "x", "ch", "sh"} {
+		if strings.HasSuffix(resource, suffix) {
+			return resource + "es"
+		}
+	}
+
+	return resource + "s"
+}
+
 func prepareHelper(db *sql.DB, resource string, idx ...string) (*Stmt, error) {
 	var buf bytes.Buffer
 
+	table := tableName(resource)
+
 	for _, column := range idx {
 		buf.WriteString(fmt.Sprintf("->'%s'", column))
 	}
 
 	// Prepare statement for INSERT operations
-	query := fmt.Sprintf("INSERT INTO %ss (%s) VALUES ($1)", resource, resource)
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES ($1)", table, resource)
 	createStmt, err := db.Prepare(query)
 	if err != nil {
 		return nil, fmt.Errorf("db: failed to prepare sql INSERT statement: '%s'", err)
 	}
 
 	// Prepare statement for UPDATE operations
-	query = fmt.Sprintf("UPDATE %ss SET %s = ($1) WHERE %s%s->>'id' = ($2)",
-		resource, resource, resource, buf.String())
+	query = fmt.Sprintf("UPDATE %s SET %s = ($1) WHERE %s%s->>'id' = ($2)",
+		table, resource, resource, buf.String())
 
 	updateStmt, err := db.Prepare(query)
 	if err != nil {
@@ -47,8 +61,8 @@ func prepareHelper(db *sql.DB, resource string, idx ...string) (*Stmt, error) {
 	}
 
 	// Prepare statement for SELECT FOR UPDATE operations
-	query = fmt.Sprintf("SELECT %s FROM %ss WHERE %s%s->>'id' = ($1) FOR UPDATE",
-		resource, resource, resource, buf.String())
+	query = fmt.Sprintf("SELECT %s FROM %s WHERE %s%s->>'id' = ($1) FOR UPDATE",
+		resource, table, resource, buf.String())
 
 	lockStmt, err := db.Prepare(query)
 	if err != nil {
@@ -56,8 +70,8 @@ func prepareHelper(db *sql.DB, resource string, idx ...string) (*Stmt, error) {
 	}
 
 	// Prepare statement for SELECT operations
-	query = fmt.Sprintf("SELECT %s FROM %ss WHERE %s%s->>'id' = ($1)",
-		resource, resource, resource, buf.String())
+	query = fmt.Sprintf("SELECT %s FROM %s WHERE %s%s->>'id' = ($1)",
+		resource, table, resource, buf.String())
 
 	readStmt, err := db.Prepare(query)
 	if err != nil {
@@ -65,15 +79,23 @@ func prepareHelper(db *sql.DB, resource string, idx ...string) (*Stmt, error) {
 	}
 
 	// Prepare statement for DELETE operations
-	query = fmt.Sprintf("DELETE FROM %ss WHERE %s%s->>'id' = ($1)",
-		resource, resource, buf.String())
+	query = fmt.Sprintf("DELETE FROM %s WHERE %s%s->>'id' = ($1)",
+		table, resource, buf.String())
 
 	deleteStmt, err := db.Prepare(query)
 	if err != nil {
 		return nil, fmt.Errorf("db: failed to prepare sql DELETE statement: '%s'", err)
 	}
 
-	return &Stmt{lockStmt, createStmt, updateStmt, readStmt, deleteStmt}, nil
+	// Prepare statement for SELECT of all records
+	query = fmt.Sprintf("SELECT %s FROM %s", resource, table)
+
+	listStmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("db: failed to prepare sql SELECT statement: '%s'", err)
+	}
+
+	return &Stmt{lockStmt, createStmt, updateStmt, readStmt, deleteStmt, listStmt}, nil
 }
 
 func prepareStmts(db *sql.DB) (Statementer, error) {
@@ -94,6 +116,6 @@ func prepareStmts(db *sql.DB) (Statementer, error) {
 // TruncateTables erases data from tables.
 func TruncateTables(db *sql.DB) {
 	for model := range models {
-		db.Exec(fmt.Sprintf("DELETE FROM %ss", string(model)))
+		db.Exec(fmt.Sprintf("DELETE FROM %s", tableName(string(model))))
 	}
 }
@@ -1,16 +1,276 @@
 package httprest
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/netrack/netrack/database"
 	"github.com/netrack/netrack/httputil"
 	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/openflow"
 )
 
+// testDatapath is an identifier of the switch connected in tests.
+const testDatapath = "00:00:00:00:00:00:00:01"
+
+func init() {
+	mech.RegisterSwitch("OFP/1.3", mech.SwitchConstructorFunc(func() mech.Switch {
+		return &testSwitch{}
+	}))
+}
+
+// testConn returns ofp_hello message and then blocks
+// till the end of the test.
+type testConn struct {
+	hello bool
+	lock  sync.Mutex
+}
+
+func (c *testConn) Receive() (*of.Request, error) {
+	c.lock.Lock()
+	hello := c.hello
+	c.hello = true
+	c.lock.Unlock()
+
+	if hello {
+		select {}
+	}
+
+	r, err := of.NewRequest(of.T_HELLO, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r.ProtoMajor, r.ProtoMinor = 1, 3
+	return r, nil
+}
+
+func (c *testConn) Send(*of.Request) error {
+	return nil
+}
+
+func (c *testConn) Flush() error {
+	return nil
+}
+
+func (c *testConn) Close() error {
+	return nil
+}
+
+// testSwitch is a switch with a single port.
+type testSwitch struct {
+	mech.Switch
+
+	conn of.OFPConn
+}
+
+func (s *testSwitch) ID() string {
+	return testDatapath
+}
+
+func (s *testSwitch) Version() string {
+	return "OFP/1.3"
+}
+
+func (s *testSwitch) Boot(c of.OFPConn) error {
+	s.conn = c
+	return nil
+}
+
+func (s *testSwitch) Conn() of.OFPConn {
+	return s.conn
+}
+
+func (s *testSwitch) Name() string {
+	return "br0"
+}
+
+func (s *testSwitch) PortList() []*mech.SwitchPort {
+	return []*mech.SwitchPort{{Name: "eth1", Number: 1}}
+}
+
+// testPersister keeps records in memory in JSON format.
+type testPersister struct {
+	db.Persister
+
+	records map[db.Model]map[string][]byte
+	lock    sync.Mutex
+}
+
+func newTestPersister() *testPersister {
+	return &testPersister{records: make(map[db.Model]map[string][]byte)}
+}
+
+func (p *testPersister) Transaction(fn func(db.ModelPersister) error) error {
+	return fn(p)
+}
+
+func (p *testPersister) Lock(m db.Model, id string, s interface{}) error {
+	return p.Read(m, id, s)
+}
+
+func (p *testPersister) Create(m db.Model, s interface{}) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	var record struct {
+		ID string `json:"id"`
+	}
+
+	if err = json.Unmarshal(b, &record); err != nil {
+		return err
+	}
+
+	return p.Update(m, record.ID, s)
+}
+
+func (p *testPersister) Update(m db.Model, id string, s interface{}) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.records[m] == nil {
+		p.records[m] = make(map[string][]byte)
+	}
+
+	p.records[m][id] = b
+	return nil
+}
+
+func (p *testPersister) Read(m db.Model, id string, s interface{}) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	b, ok := p.records[m][id]
+	if !ok {
+		return db.ErrNoRows
+	}
+
+	return json.Unmarshal(b, s)
+}
+
+func (p *testPersister) List(m db.Model, s interface{}) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var ids []string
+	for id := range p.records[m] {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	var records []json.RawMessage
+	for _, id := range ids {
+		records = append(records, p.records[m][id])
+	}
+
+	b, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, s)
+}
+
+// withSwitch connects the test switch and calls fn with HTTP driver
+// context of the switch manager and mechanism context of the switch.
+func withSwitch(t *testing.T, fn func(*mech.HTTPDriverContext, *mech.MechanismContext)) {
+	defer func(persister db.Persister) {
+		db.DefaultDB = persister
+	}(db.DefaultDB)
+
+	db.DefaultDB = newTestPersister()
+
+	c := &mech.HTTPDriverContext{
+		Mux:           httputil.NewServeMux(),
+		SwitchManager: &mech.SwitchManager{},
+	}
+
+	if err := c.SwitchManager.CreateSwitch(&testConn{}); err != nil {
+		t.Fatal("Failed to create switch:", err)
+	}
+
+	context, err := c.SwitchManager.Context(testDatapath)
+	if err != nil {
+		t.Fatal("Failed to find created switch:", err)
+	}
+
+	fn(c, context)
+}
+
+// serve sends request to the multiplexer and decodes JSON response
+// into v, error is returned, when response status is not expected.
+func serve(c *mech.HTTPDriverContext, method, path, body string, status int, v interface{}) error {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	r, err := http.NewRequest(method, path, reader)
+	if err != nil {
+		return err
+	}
+
+	r.Header.Set(httputil.HeaderAccept, httputil.TypeApplicationJSON)
+	if body != "" {
+		r.Header.Set(httputil.HeaderContentType, httputil.TypeApplicationJSON)
+	}
+
+	rw := httptest.NewRecorder()
+	c.Mux.ServeHTTP(rw, r)
+
+	if rw.Code != status {
+		return errors.New(rw.Body.String())
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.Unmarshal(rw.Body.Bytes(), v)
+}
+
 func TestAcceptFilter(t *testing.T) {
+	c := &mech.HTTPDriverContext{Mux: httputil.NewServeMux()}
+	NewBaseHandler().Enable(c)
+
+	r, _ := http.NewRequest("GET", "/v1/switches", nil)
+	r.Header.Set(httputil.HeaderAccept, "text/plain")
+
+	rw := httptest.NewRecorder()
+	c.Mux.ServeHTTP(rw, r)
+
+	if rw.Code != http.StatusNotAcceptable {
+		t.Fatal("Unsupported media type must not be accepted:", rw.Code)
+	}
 }
 
 func TestContentFilter(t *testing.T) {
+	c := &mech.HTTPDriverContext{Mux: httputil.NewServeMux()}
+	NewBaseHandler().Enable(c)
+
+	r, _ := http.NewRequest("PUT", "/v1/switches", strings.NewReader("{}"))
+	r.Header.Set(httputil.HeaderAccept, httputil.TypeApplicationJSON)
+	r.Header.Set(httputil.HeaderContentType, "text/plain")
+
+	rw := httptest.NewRecorder()
+	c.Mux.ServeHTTP(rw, r)
+
+	if rw.Code != http.StatusUnsupportedMediaType {
+		t.Fatal("Unsupported content must be rejected:", rw.Code)
+	}
 }
@@ -0,0 +1,221 @@
+package httprest
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/httputil"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+)
+
+func init() {
+	// Register BGP HTTP API driver.
+	constructor := mech.HTTPDriverConstructorFunc(NewBGPHandler)
+	mech.RegisterHTTPDriver(constructor)
+}
+
+// BGPHandler configures BGP speaker and exposes its neighbors.
+type BGPHandler struct {
+	// Base HTTP driver instance.
+	mech.BaseHTTPDriver
+}
+
+// NewBGPHandler creates a new instance of BGPHandler type.
+func NewBGPHandler() mech.HTTPDriver {
+	return &BGPHandler{}
+}
+
+// Enable implements HTTPDriver interface.
+func (h *BGPHandler) Enable(c *mech.HTTPDriverContext) {
+	h.BaseHTTPDriver.Enable(c)
+
+	h.C.Mux.HandleFunc("GET", "/v1/datapaths/{dpid}/bgp", h.showHandler)
+	h.C.Mux.HandleFunc("PUT", "/v1/datapaths/{dpid}/bgp", h.updateHandler)
+	h.C.Mux.HandleFunc("GET", "/v1/datapaths/{dpid}/bgp/neighbors", h.neighborsHandler)
+
+	log.InfoLog("bgp_handlers/ENABLE_HOOK",
+		"BGP handlers enabled")
+}
+
+// speaker returns BGP speaker of the requested switch,
+// error response is written, when speaker is not available.
+func (h *BGPHandler) speaker(rw http.ResponseWriter, r *http.Request) (mech.BGPSpeaker, error) {
+	dpid := httputil.Param(r, "dpid")
+	wf := WriteFormat(r)
+
+	context, err := h.C.SwitchManager.Context(dpid)
+	if err != nil {
+		log.ErrorLog("bgp_handlers/SPEAKER",
+			"Failed to find requested datapath: ", err)
+
+		text := fmt.Sprintf("switch '%s' not found", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotFound)
+		return nil, err
+	}
+
+	speaker, err := mech.BGPSpkr(context)
+	if err != nil {
+		text := fmt.Sprintf("BGP is not available on '%s'", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotImplemented)
+		return nil, err
+	}
+
+	return speaker, nil
+}
+
+// config returns validated BGP configuration of the model.
+func (h *BGPHandler) config(bgpModel *models.BGP) (*mech.BGPConfig, error) {
+	if bgpModel.AS == 0 {
+		return nil, fmt.Errorf("BGP autonomous system is required")
+	}
+
+	if ip := net.ParseIP(bgpModel.RouterID); ip == nil || ip.To4() == nil || ip.IsUnspecified() {
+		return nil, fmt.Errorf("invalid BGP router identifier")
+	}
+
+	if bgpModel.HoldTime == 1 || bgpModel.HoldTime == 2 {
+		return nil, fmt.Errorf("BGP hold time must be zero or at least 3 seconds")
+	}
+
+	config := &mech.BGPConfig{
+		AS:       bgpModel.AS,
+		RouterID: bgpModel.RouterID,
+		HoldTime: bgpModel.HoldTime,
+		Networks: make([]string, 0),
+	}
+
+	for _, network := range bgpModel.Networks {
+		_, ipnet, err := net.ParseCIDR(network)
+		if err != nil || ipnet.IP.To4() == nil {
+			return nil, fmt.Errorf("invalid BGP network '%s'", network)
+		}
+
+		config.Networks = append(config.Networks, ipnet.String())
+	}
+
+	for _, nbr := range bgpModel.Neighbors {
+		ip := net.ParseIP(nbr.Address)
+		if ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("invalid BGP neighbor address '%s'", nbr.Address)
+		}
+
+		// Only external peers are supported.
+		if nbr.RemoteAS == 0 || nbr.RemoteAS == bgpModel.AS {
+			return nil, fmt.Errorf("invalid autonomous system of BGP neighbor '%s'", nbr.Address)
+		}
+
+		config.Neighbors = append(config.Neighbors, mech.BGPNeighborConfig{
+			Addr: ip.To4().String(),
+			AS:   nbr.RemoteAS,
+		})
+	}
+
+	return config, nil
+}
+
+// model returns JSON representation of the configuration.
+func (h *BGPHandler) model(config *mech.BGPConfig) models.BGP {
+	bgpModel := models.BGP{
+		Networks:  make([]string, 0),
+		Neighbors: make([]models.BGPNeighborConfig, 0),
+	}
+
+	if config == nil {
+		return bgpModel
+	}
+
+	bgpModel.AS = config.AS
+	bgpModel.RouterID = config.RouterID
+	bgpModel.HoldTime = config.HoldTime
+	bgpModel.Networks = append(bgpModel.Networks, config.Networks...)
+
+	for _, nbr := range config.Neighbors {
+		bgpModel.Neighbors = append(bgpModel.Neighbors, models.BGPNeighborConfig{
+			Address:  nbr.Addr,
+			RemoteAS: nbr.AS,
+		})
+	}
+
+	return bgpModel
+}
+
+func (h *BGPHandler) showHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("bgp_handlers/SHOW_HANDLER",
+		"Got request to show BGP configuration")
+
+	speaker, err := h.speaker(rw, r)
+	if err != nil {
+		return
+	}
+
+	WriteFormat(r).Write(rw, h.model(speaker.BGPConfig()), http.StatusOK)
+}
+
+func (h *BGPHandler) updateHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("bgp_handlers/UPDATE_HANDLER",
+		"Got request to update BGP configuration")
+
+	speaker, err := h.speaker(rw, r)
+	if err != nil {
+		return
+	}
+
+	rf, wf := Format(r)
+
+	var bgpModel models.BGP
+	if err = rf.Read(r, &bgpModel); err != nil {
+		log.ErrorLog("bgp_handlers/UPDATE_HANDLER",
+			"Failed to read request body: ", err)
+
+		body := models.Error{"failed to read request body"}
+		wf.Write(rw, body, http.StatusBadRequest)
+		return
+	}
+
+	config, err := h.config(&bgpModel)
+	if err != nil {
+		log.ErrorLog("bgp_handlers/UPDATE_HANDLER",
+			"Invalid BGP configuration: ", err)
+
+		wf.Write(rw, models.Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if err = speaker.UpdateBGP(config); err != nil {
+		log.ErrorLog("bgp_handlers/UPDATE_HANDLER",
+			"Failed to update BGP configuration: ", err)
+
+		body := models.Error{"failed to update BGP configuration"}
+		wf.Write(rw, body, http.StatusConflict)
+		return
+	}
+
+	wf.Write(rw, h.model(config), http.StatusOK)
+}
+
+func (h *BGPHandler) neighborsHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("bgp_handlers/NEIGHBORS_HANDLER",
+		"Got request to list BGP neighbors")
+
+	speaker, err := h.speaker(rw, r)
+	if err != nil {
+		return
+	}
+
+	neighborModels := make([]models.BGPNeighbor, 0)
+	for _, nbr := range speaker.BGPNeighbors() {
+		neighborModels = append(neighborModels, models.BGPNeighbor{
+			Address:  nbr.Addr,
+			RemoteAS: nbr.AS,
+			RouterID: nbr.RouterID,
+			State:    nbr.State,
+			Uptime:   nbr.Uptime,
+			Prefixes: nbr.Prefixes,
+		})
+	}
+
+	WriteFormat(r).Write(rw, neighborModels, http.StatusOK)
+}
@@ -0,0 +1,128 @@
+package httprest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/mechanism"
+)
+
+type testBGPSpeaker struct {
+	config    *mech.BGPConfig
+	neighbors []mech.BGPNeighbor
+}
+
+func (s *testBGPSpeaker) BGPConfig() *mech.BGPConfig {
+	return s.config
+}
+
+func (s *testBGPSpeaker) UpdateBGP(config *mech.BGPConfig) error {
+	s.config = config
+	return nil
+}
+
+func (s *testBGPSpeaker) BGPNeighbors() []mech.BGPNeighbor {
+	return s.neighbors
+}
+
+func withBGP(t *testing.T, fn func(*mech.HTTPDriverContext, *testBGPSpeaker)) {
+	withSwitch(t, func(c *mech.HTTPDriverContext, context *mech.MechanismContext) {
+		NewBGPHandler().Enable(c)
+
+		path := "/v1/datapaths/" + testDatapath + "/bgp"
+		if err := serve(c, "GET", path, "", http.StatusNotImplemented, nil); err != nil {
+			t.Fatal("Configuration must not be shown without BGP:", err)
+		}
+
+		path = "/v1/datapaths/00:00:00:00:00:00:00:02/bgp"
+		if err := serve(c, "GET", path, "", http.StatusNotFound, nil); err != nil {
+			t.Fatal("Configuration of unknown switch must not be found:", err)
+		}
+
+		speaker := &testBGPSpeaker{}
+		context.Managers.Bind(new(mech.BGPSpeaker), speaker)
+
+		fn(c, speaker)
+	})
+}
+
+func TestBGPShow(t *testing.T) {
+	withBGP(t, func(c *mech.HTTPDriverContext, speaker *testBGPSpeaker) {
+		var bgp models.BGP
+
+		path := "/v1/datapaths/" + testDatapath + "/bgp"
+		if err := serve(c, "GET", path, "", http.StatusOK, &bgp); err != nil {
+			t.Fatal("Failed to show configuration:", err)
+		}
+
+		if bgp.AS != 0 || bgp.Networks == nil || bgp.Neighbors == nil {
+			t.Fatal("Empty configuration must be shown:", bgp)
+		}
+
+		speaker.config = &mech.BGPConfig{AS: 65001, RouterID: "192.0.2.1"}
+
+		if err := serve(c, "GET", path, "", http.StatusOK, &bgp); err != nil {
+			t.Fatal("Failed to show configuration:", err)
+		}
+
+		if bgp.AS != 65001 || bgp.RouterID != "192.0.2.1" {
+			t.Fatal("Invalid configuration:", bgp)
+		}
+	})
+}
+
+func TestBGPUpdate(t *testing.T) {
+	withBGP(t, func(c *mech.HTTPDriverContext, speaker *testBGPSpeaker) {
+		path := "/v1/datapaths/" + testDatapath + "/bgp"
+
+		tests := []struct {
+			body   string
+			status int
+		}{
+			{`{"as":65001}`, http.StatusBadRequest},
+			{`{"as":65001,"router_id":"192.0.2.1","hold_time":2}`, http.StatusBadRequest},
+			{`{"as":65001,"router_id":"192.0.2.1","networks":["2001:db8::/32"]}`, http.StatusBadRequest},
+			{`{"as":65001,"router_id":"192.0.2.1","neighbors":[{"address":"10.0.0.2","remote_as":65001}]}`,
+				http.StatusBadRequest},
+			{`{"as":65001,"router_id":"192.0.2.1","networks":["10.1.0.1/16"],` +
+				`"neighbors":[{"address":"10.0.0.2","remote_as":65002}]}`, http.StatusOK},
+		}
+
+		for i, test := range tests {
+			if err := serve(c, "PUT", path, test.body, test.status, nil); err != nil {
+				t.Fatalf("Invalid response to %d configuration: %s", i, err)
+			}
+		}
+
+		config := speaker.config
+		if config == nil || config.AS != 65001 || len(config.Networks) != 1 {
+			t.Fatal("Configuration must be updated:", config)
+		}
+
+		if config.Networks[0] != "10.1.0.0/16" || len(config.Neighbors) != 1 ||
+			config.Neighbors[0].AS != 65002 {
+			t.Fatal("Invalid updated configuration:", config)
+		}
+	})
+}
+
+func TestBGPNeighborsIndex(t *testing.T) {
+	withBGP(t, func(c *mech.HTTPDriverContext, speaker *testBGPSpeaker) {
+		speaker.neighbors = []mech.BGPNeighbor{
+			{Addr: "10.0.0.2", AS: 65002, State: "Established", Prefixes: 3},
+		}
+
+		var neighbors []models.BGPNeighbor
+
+		path := "/v1/datapaths/" + testDatapath + "/bgp/neighbors"
+		if err := serve(c, "GET", path, "", http.StatusOK, &neighbors); err != nil {
+			t.Fatal("Failed to list neighbors:", err)
+		}
+
+		if len(neighbors) != 1 || neighbors[0].Address != "10.0.0.2" ||
+			neighbors[0].State != "Established" || neighbors[0].Prefixes != 3 {
+			t.Fatal("Invalid neighbors:", neighbors)
+		}
+	})
+}
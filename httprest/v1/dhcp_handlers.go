@@ -0,0 +1,81 @@
+package httprest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/httputil"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+)
+
+func init() {
+	// Register DHCP leases HTTP API driver.
+	constructor := mech.HTTPDriverConstructorFunc(NewDHCPHandler)
+	mech.RegisterHTTPDriver(constructor)
+}
+
+// DHCPHandler exposes addresses leased by the switch.
+type DHCPHandler struct {
+	// Base HTTP driver instance.
+	mech.BaseHTTPDriver
+}
+
+// NewDHCPHandler creates a new instance of DHCPHandler type.
+func NewDHCPHandler() mech.HTTPDriver {
+	return &DHCPHandler{}
+}
+
+// Enable implements HTTPDriver interface.
+func (h *DHCPHandler) Enable(c *mech.HTTPDriverContext) {
+	h.BaseHTTPDriver.Enable(c)
+
+	h.C.Mux.HandleFunc("GET", "/v1/datapaths/{dpid}/dhcp/leases", h.indexHandler)
+
+	log.InfoLog("dhcp_handlers/ENABLE_HOOK",
+		"DHCP handlers enabled")
+}
+
+func (h *DHCPHandler) indexHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("dhcp_handlers/INDEX_HANDLER",
+		"Got request to list leased addresses")
+
+	dpid := httputil.Param(r, "dpid")
+	wf := WriteFormat(r)
+
+	context, err := h.C.SwitchManager.Context(dpid)
+	if err != nil {
+		log.ErrorLog("dhcp_handlers/INDEX_HANDLER",
+			"Failed to find requested datapath: ", err)
+
+		text := fmt.Sprintf("switch '%s' not found", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotFound)
+		return
+	}
+
+	reader, err := mech.DHCPLeaseTbl(context)
+	if err != nil {
+		text := fmt.Sprintf("addresses are not leased by '%s'", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotImplemented)
+		return
+	}
+
+	names := make(map[uint32]string)
+	for _, port := range context.Switch.PortList() {
+		names[port.Number] = port.Name
+	}
+
+	leaseModels := make([]models.DHCPLease, 0)
+	for _, lease := range reader.DHCPLeases() {
+		leaseModels = append(leaseModels, models.DHCPLease{
+			Address:   lease.Addr,
+			HWAddress: lease.LinkAddr,
+			Port:      lease.Port,
+			Interface: names[lease.Port],
+			Expires:   lease.Expires,
+		})
+	}
+
+	wf.Write(rw, leaseModels, http.StatusOK)
+}
@@ -0,0 +1,46 @@
+package httprest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/mechanism"
+)
+
+type testDHCPLeases []mech.DHCPLease
+
+func (l testDHCPLeases) DHCPLeases() []mech.DHCPLease {
+	return l
+}
+
+func TestDHCPLeasesIndex(t *testing.T) {
+	withSwitch(t, func(c *mech.HTTPDriverContext, context *mech.MechanismContext) {
+		NewDHCPHandler().Enable(c)
+
+		path := "/v1/datapaths/" + testDatapath + "/dhcp/leases"
+		if err := serve(c, "GET", path, "", http.StatusNotImplemented, nil); err != nil {
+			t.Fatal("Leases must not be listed without DHCP server:", err)
+		}
+
+		context.Managers.Bind(new(mech.DHCPLeaseReader), testDHCPLeases{
+			{Addr: "192.0.2.10", LinkAddr: "00:00:5e:00:53:01", Port: 1},
+		})
+
+		var leases []models.DHCPLease
+
+		if err := serve(c, "GET", path, "", http.StatusOK, &leases); err != nil {
+			t.Fatal("Failed to list leases:", err)
+		}
+
+		if len(leases) != 1 || leases[0].Address != "192.0.2.10" ||
+			leases[0].HWAddress != "00:00:5e:00:53:01" || leases[0].Interface != "eth1" {
+			t.Fatal("Invalid leases:", leases)
+		}
+
+		path = "/v1/datapaths/00:00:00:00:00:00:00:02/dhcp/leases"
+		if err := serve(c, "GET", path, "", http.StatusNotFound, nil); err != nil {
+			t.Fatal("Leases of unknown switch must not be found:", err)
+		}
+	})
+}
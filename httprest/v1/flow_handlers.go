@@ -0,0 +1,109 @@
+package httprest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/httputil"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+)
+
+func init() {
+	// Register flow inventory HTTP API driver.
+	constructor := mech.HTTPDriverConstructorFunc(NewFlowHandler)
+	mech.RegisterHTTPDriver(constructor)
+}
+
+// FlowHandler exposes flows installed on the switch.
+type FlowHandler struct {
+	// Base HTTP driver instance.
+	mech.BaseHTTPDriver
+}
+
+// NewFlowHandler creates a new instance of FlowHandler type.
+func NewFlowHandler() mech.HTTPDriver {
+	return &FlowHandler{}
+}
+
+// Enable implements HTTPDriver interface.
+func (h *FlowHandler) Enable(c *mech.HTTPDriverContext) {
+	h.BaseHTTPDriver.Enable(c)
+
+	h.C.Mux.HandleFunc("GET", "/v1/datapaths/{dpid}/flows", h.indexHandler)
+
+	log.InfoLog("flow_handlers/ENABLE_HOOK",
+		"Flow handlers enabled")
+}
+
+func (h *FlowHandler) indexHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("flow_handlers/INDEX_HANDLER",
+		"Got request to list flows")
+
+	dpid := httputil.Param(r, "dpid")
+	wf := WriteFormat(r)
+
+	// List flows of all tables by default.
+	table := -1
+
+	if param := r.URL.Query().Get("table"); param != "" {
+		var err error
+
+		if table, err = strconv.Atoi(param); err != nil || table < 0 {
+			log.ErrorLog("flow_handlers/INDEX_HANDLER",
+				"Failed to parse table number: ", param)
+
+			text := fmt.Sprintf("invalid table number '%s'", param)
+			wf.Write(rw, models.Error{text}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	context, err := h.C.SwitchManager.Context(dpid)
+	if err != nil {
+		log.ErrorLog("flow_handlers/INDEX_HANDLER",
+			"Failed to find requested datapath: ", err)
+
+		text := fmt.Sprintf("switch '%s' not found", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotFound)
+		return
+	}
+
+	inventory, err := mech.FlowInv(context)
+	if err != nil {
+		text := fmt.Sprintf("flow inventory is not supported by '%s'", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotImplemented)
+		return
+	}
+
+	flows, err := inventory.Flows(table)
+	if err != nil {
+		log.ErrorLog("flow_handlers/INDEX_HANDLER",
+			"Failed to retrieve installed flows: ", err)
+
+		text := fmt.Sprintf("flows of '%s' inaccessible", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusInternalServerError)
+		return
+	}
+
+	flowModels := make([]models.Flow, 0)
+	for _, flow := range flows {
+		flowModels = append(flowModels, models.Flow{
+			Table:        flow.Table,
+			Priority:     flow.Priority,
+			Cookie:       fmt.Sprintf("0x%x", flow.Cookie),
+			Match:        flow.Match,
+			Instructions: flow.Instructions,
+			PacketCount:  flow.PacketCount,
+			ByteCount:    flow.ByteCount,
+			Duration:     flow.Duration.Seconds(),
+			IdleTimeout:  flow.IdleTimeout,
+			HardTimeout:  flow.HardTimeout,
+			Mechanism:    flow.Mechanism,
+		})
+	}
+
+	wf.Write(rw, flowModels, http.StatusOK)
+}
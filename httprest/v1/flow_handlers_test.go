@@ -0,0 +1,66 @@
+package httprest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/mechanism"
+)
+
+type testFlowInventory []mech.FlowEntry
+
+func (i testFlowInventory) Flows(table int) ([]mech.FlowEntry, error) {
+	var flows []mech.FlowEntry
+
+	for _, flow := range i {
+		if table < 0 || flow.Table == table {
+			flows = append(flows, flow)
+		}
+	}
+
+	return flows, nil
+}
+
+func TestFlowIndex(t *testing.T) {
+	withSwitch(t, func(c *mech.HTTPDriverContext, context *mech.MechanismContext) {
+		NewFlowHandler().Enable(c)
+
+		path := "/v1/datapaths/" + testDatapath + "/flows"
+		if err := serve(c, "GET", path, "", http.StatusNotImplemented, nil); err != nil {
+			t.Fatal("Flows must not be listed without inventory:", err)
+		}
+
+		context.Managers.Bind(new(mech.FlowInventory), testFlowInventory{
+			{Table: 0, Priority: 10, Cookie: 0x2a, Mechanism: "arp"},
+			{Table: 1, Priority: 20},
+		})
+
+		var flows []models.Flow
+
+		if err := serve(c, "GET", path, "", http.StatusOK, &flows); err != nil {
+			t.Fatal("Failed to list flows:", err)
+		}
+
+		if len(flows) != 2 || flows[0].Cookie != "0x2a" || flows[0].Mechanism != "arp" {
+			t.Fatal("Invalid flows:", flows)
+		}
+
+		if err := serve(c, "GET", path+"?table=1", "", http.StatusOK, &flows); err != nil {
+			t.Fatal("Failed to list flows of the table:", err)
+		}
+
+		if len(flows) != 1 || flows[0].Table != 1 || flows[0].Priority != 20 {
+			t.Fatal("Invalid flows of the table:", flows)
+		}
+
+		if err := serve(c, "GET", path+"?table=x", "", http.StatusBadRequest, nil); err != nil {
+			t.Fatal("Invalid table number must be rejected:", err)
+		}
+
+		path = "/v1/datapaths/00:00:00:00:00:00:00:02/flows"
+		if err := serve(c, "GET", path, "", http.StatusNotFound, nil); err != nil {
+			t.Fatal("Flows of unknown switch must not be found:", err)
+		}
+	})
+}
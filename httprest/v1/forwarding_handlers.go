@@ -0,0 +1,131 @@
+package httprest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/httputil"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+)
+
+func init() {
+	// Register forwarding HTTP API driver.
+	constructor := mech.HTTPDriverConstructorFunc(NewForwardingHandler)
+	mech.RegisterHTTPDriver(constructor)
+}
+
+// ForwardingHandler exposes routing and forwarding information bases.
+type ForwardingHandler struct {
+	// Base HTTP driver instance.
+	mech.BaseHTTPDriver
+}
+
+// NewForwardingHandler creates a new instance of ForwardingHandler type.
+func NewForwardingHandler() mech.HTTPDriver {
+	return &ForwardingHandler{}
+}
+
+// Enable implements HTTPDriver interface.
+func (h *ForwardingHandler) Enable(c *mech.HTTPDriverContext) {
+	h.BaseHTTPDriver.Enable(c)
+
+	h.C.Mux.HandleFunc("GET", "/v1/datapaths/{dpid}/rib", h.ribHandler)
+	h.C.Mux.HandleFunc("GET", "/v1/datapaths/{dpid}/fib", h.fibHandler)
+
+	log.InfoLog("forwarding_handlers/ENABLE_HOOK",
+		"Forwarding handlers enabled")
+}
+
+// reader returns forwarding information reader of the requested
+// switch and names of its ports, error response is written, when
+// reader is not available.
+func (h *ForwardingHandler) reader(rw http.ResponseWriter, r *http.Request) (mech.ForwardingReader, map[uint32]string, error) {
+	dpid := httputil.Param(r, "dpid")
+	wf := WriteFormat(r)
+
+	context, err := h.C.SwitchManager.Context(dpid)
+	if err != nil {
+		log.ErrorLog("forwarding_handlers/READER",
+			"Failed to find requested datapath: ", err)
+
+		text := fmt.Sprintf("switch '%s' not found", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotFound)
+		return nil, nil, err
+	}
+
+	reader, err := mech.ForwardingRdr(context)
+	if err != nil {
+		text := fmt.Sprintf("packets are not routed by '%s'", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotImplemented)
+		return nil, nil, err
+	}
+
+	names := make(map[uint32]string)
+	for _, port := range context.Switch.PortList() {
+		names[port.Number] = port.Name
+	}
+
+	return reader, names, nil
+}
+
+func (h *ForwardingHandler) ribHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("forwarding_handlers/RIB_HANDLER",
+		"Got request to list routing information base")
+
+	reader, names, err := h.reader(rw, r)
+	if err != nil {
+		return
+	}
+
+	entryModels := make([]models.RIBEntry, 0)
+	for _, entry := range reader.RIB() {
+		entryModels = append(entryModels, models.RIBEntry{
+			Type:          string(entry.Type),
+			Network:       entry.Network,
+			NextHop:       entry.NextHop,
+			Interface:     entry.Port,
+			InterfaceName: names[entry.Port],
+			Distance:      entry.Distance,
+			Metric:        entry.Metric,
+			Best:          entry.Best,
+		})
+	}
+
+	WriteFormat(r).Write(rw, entryModels, http.StatusOK)
+}
+
+func (h *ForwardingHandler) fibHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("forwarding_handlers/FIB_HANDLER",
+		"Got request to list forwarding information base")
+
+	reader, names, err := h.reader(rw, r)
+	if err != nil {
+		return
+	}
+
+	entryModels := make([]models.FIBEntry, 0)
+	for _, entry := range reader.FIB() {
+		nexthopModels := make([]models.FIBNextHop, 0, len(entry.NextHops))
+		for _, nexthop := range entry.NextHops {
+			nexthopModels = append(nexthopModels, models.FIBNextHop{
+				NextHop:       nexthop.Addr,
+				LinkAddr:      nexthop.LinkAddr,
+				Interface:     nexthop.Port,
+				InterfaceName: names[nexthop.Port],
+			})
+		}
+
+		entryModels = append(entryModels, models.FIBEntry{
+			Type:     string(entry.Type),
+			Network:  entry.Network,
+			NextHops: nexthopModels,
+			Group:    entry.Group,
+			Priority: entry.Priority,
+			State:    entry.State,
+		})
+	}
+
+	WriteFormat(r).Write(rw, entryModels, http.StatusOK)
+}
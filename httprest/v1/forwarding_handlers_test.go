@@ -0,0 +1,91 @@
+package httprest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/mechanism"
+)
+
+type testForwardingReader struct {
+	rib []mech.RIBEntry
+	fib []mech.FIBEntry
+}
+
+func (r *testForwardingReader) RIB() []mech.RIBEntry {
+	return r.rib
+}
+
+func (r *testForwardingReader) FIB() []mech.FIBEntry {
+	return r.fib
+}
+
+func withForwarding(t *testing.T, fn func(*mech.HTTPDriverContext)) {
+	withSwitch(t, func(c *mech.HTTPDriverContext, context *mech.MechanismContext) {
+		NewForwardingHandler().Enable(c)
+
+		path := "/v1/datapaths/" + testDatapath + "/rib"
+		if err := serve(c, "GET", path, "", http.StatusNotImplemented, nil); err != nil {
+			t.Fatal("Routes must not be listed without forwarding:", err)
+		}
+
+		path = "/v1/datapaths/00:00:00:00:00:00:00:02/fib"
+		if err := serve(c, "GET", path, "", http.StatusNotFound, nil); err != nil {
+			t.Fatal("Forwarding entries of unknown switch must not be found:", err)
+		}
+
+		context.Managers.Bind(new(mech.ForwardingReader), &testForwardingReader{
+			rib: []mech.RIBEntry{
+				{Type: mech.StaticRoute, Network: "10.1.0.0/16", NextHop: "10.0.0.2",
+					Port: 1, Distance: 1, Best: true},
+			},
+			fib: []mech.FIBEntry{
+				{Type: mech.StaticRoute, Network: "10.1.0.0/16",
+					NextHops: []mech.FIBNextHop{
+						{Addr: "10.0.0.2", LinkAddr: "00:00:00:00:00:02", Port: 1},
+					},
+					Priority: 16, State: "forward"},
+			},
+		})
+
+		fn(c)
+	})
+}
+
+func TestForwardingRIB(t *testing.T) {
+	withForwarding(t, func(c *mech.HTTPDriverContext) {
+		var entries []models.RIBEntry
+
+		path := "/v1/datapaths/" + testDatapath + "/rib"
+		if err := serve(c, "GET", path, "", http.StatusOK, &entries); err != nil {
+			t.Fatal("Failed to list routes:", err)
+		}
+
+		if len(entries) != 1 || entries[0].Type != "static" ||
+			entries[0].InterfaceName != "eth1" || !entries[0].Best {
+			t.Fatal("Invalid routes:", entries)
+		}
+	})
+}
+
+func TestForwardingFIB(t *testing.T) {
+	withForwarding(t, func(c *mech.HTTPDriverContext) {
+		var entries []models.FIBEntry
+
+		path := "/v1/datapaths/" + testDatapath + "/fib"
+		if err := serve(c, "GET", path, "", http.StatusOK, &entries); err != nil {
+			t.Fatal("Failed to list forwarding entries:", err)
+		}
+
+		if len(entries) != 1 || entries[0].State != "forward" || entries[0].Priority != 16 {
+			t.Fatal("Invalid forwarding entries:", entries)
+		}
+
+		nexthops := entries[0].NextHops
+		if len(nexthops) != 1 || nexthops[0].LinkAddr != "00:00:00:00:00:02" ||
+			nexthops[0].InterfaceName != "eth1" {
+			t.Fatal("Invalid next hops:", nexthops)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package httprest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/httputil"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+)
+
+func init() {
+	// Register group HTTP API driver.
+	constructor := mech.HTTPDriverConstructorFunc(NewGroupHandler)
+	mech.RegisterHTTPDriver(constructor)
+}
+
+// GroupHandler exposes group entries of the switch.
+type GroupHandler struct {
+	// Base HTTP driver instance.
+	mech.BaseHTTPDriver
+}
+
+// NewGroupHandler creates a new instance of GroupHandler type.
+func NewGroupHandler() mech.HTTPDriver {
+	return &GroupHandler{}
+}
+
+// Enable implements HTTPDriver interface.
+func (h *GroupHandler) Enable(c *mech.HTTPDriverContext) {
+	h.BaseHTTPDriver.Enable(c)
+
+	h.C.Mux.HandleFunc("GET", "/v1/datapaths/{dpid}/groups", h.indexHandler)
+
+	log.InfoLog("group_handlers/ENABLE_HOOK",
+		"Group handlers enabled")
+}
+
+func (h *GroupHandler) indexHandler(rw http.ResponseWriter, r *http.Request) {
+	dpid := httputil.Param(r, "dpid")
+	wf := WriteFormat(r)
+
+	log.InfoLog("group_handlers/INDEX_HANDLER",
+		"Got request to list group entries")
+
+	context, err := h.C.SwitchManager.Context(dpid)
+	if err != nil {
+		log.ErrorLog("group_handlers/INDEX_HANDLER",
+			"Failed to find requested datapath: ", err)
+
+		text := fmt.Sprintf("switch '%s' not found", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotFound)
+		return
+	}
+
+	reader, err := mech.GroupRdr(context)
+	if err != nil {
+		text := fmt.Sprintf("groups are not installed to '%s'", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotImplemented)
+		return
+	}
+
+	names := make(map[uint32]string)
+	for _, port := range context.Switch.PortList() {
+		names[port.Number] = port.Name
+	}
+
+	groupModels := make([]models.GroupEntry, 0)
+	for _, group := range reader.Groups() {
+		bucketModels := make([]models.GroupBucket, 0, len(group.Buckets))
+		for _, bucket := range group.Buckets {
+			bucketModels = append(bucketModels, models.GroupBucket{
+				Weight:        bucket.Weight,
+				NextHop:       bucket.NextHop,
+				LinkAddr:      bucket.LinkAddr,
+				Interface:     bucket.Port,
+				InterfaceName: names[bucket.Port],
+			})
+		}
+
+		groupModels = append(groupModels, models.GroupEntry{
+			ID:      group.ID,
+			Type:    group.Type,
+			Network: group.Network,
+			Buckets: bucketModels,
+		})
+	}
+
+	wf.Write(rw, groupModels, http.StatusOK)
+}
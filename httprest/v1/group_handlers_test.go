@@ -0,0 +1,53 @@
+package httprest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/mechanism"
+)
+
+type testGroupReader []mech.GroupEntry
+
+func (r testGroupReader) Groups() []mech.GroupEntry {
+	return r
+}
+
+func TestGroupIndex(t *testing.T) {
+	withSwitch(t, func(c *mech.HTTPDriverContext, context *mech.MechanismContext) {
+		NewGroupHandler().Enable(c)
+
+		path := "/v1/datapaths/" + testDatapath + "/groups"
+		if err := serve(c, "GET", path, "", http.StatusNotImplemented, nil); err != nil {
+			t.Fatal("Groups must not be listed without group reader:", err)
+		}
+
+		context.Managers.Bind(new(mech.GroupReader), testGroupReader{
+			{ID: 1, Type: "select", Network: "10.1.0.0/16", Buckets: []mech.GroupBucket{
+				{Weight: 1, Port: 1, NextHop: "10.0.0.2", LinkAddr: "00:00:00:00:00:02"},
+				{Weight: 1, Port: 2, NextHop: "10.0.0.3", LinkAddr: "00:00:00:00:00:03"},
+			}},
+		})
+
+		var groups []models.GroupEntry
+		if err := serve(c, "GET", path, "", http.StatusOK, &groups); err != nil {
+			t.Fatal("Failed to list groups:", err)
+		}
+
+		if len(groups) != 1 || groups[0].ID != 1 || groups[0].Type != "select" {
+			t.Fatal("Invalid groups:", groups)
+		}
+
+		buckets := groups[0].Buckets
+		if len(buckets) != 2 || buckets[0].InterfaceName != "eth1" ||
+			buckets[1].NextHop != "10.0.0.3" || buckets[1].InterfaceName != "" {
+			t.Fatal("Invalid group buckets:", buckets)
+		}
+
+		path = "/v1/datapaths/00:00:00:00:00:00:00:02/groups"
+		if err := serve(c, "GET", path, "", http.StatusNotFound, nil); err != nil {
+			t.Fatal("Groups of unknown switch must not be found:", err)
+		}
+	})
+}
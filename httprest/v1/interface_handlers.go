@@ -1,13 +1,14 @@
 package httprest
 
 import (
-	//"fmt"
+	"fmt"
 	"net/http"
+	"strconv"
 	//"strings"
 
 	//"github.com/netrack/netrack/httprest/format"
-	//"github.com/netrack/netrack/httprest/v1/models"
-	//"github.com/netrack/netrack/httputil"
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/httputil"
 	"github.com/netrack/netrack/logging"
 	"github.com/netrack/netrack/mechanism"
 )
@@ -34,6 +35,7 @@ func (h *InterfaceHandler) Enable(c *mech.HTTPDriverContext) {
 
 	h.C.Mux.HandleFunc("GET", "/v1/switches/{dpid}/interfaces", h.indexHandler)
 	h.C.Mux.HandleFunc("GET", "/v1/switches/{dpid}/interfaces/{interface}", h.showHandler)
+	h.C.Mux.HandleFunc("GET", "/v1/switches/{dpid}/interfaces/{interface}/stats", h.statsHandler)
 
 	log.InfoLog("interface_handlers/ENABLE_HOOK",
 		"Interface management enabled")
@@ -82,3 +84,73 @@ func (h *InterfaceHandler) indexHandler(rw http.ResponseWriter, r *http.Request)
 // showHandler returns description of the specified switch interface.
 func (h *InterfaceHandler) showHandler(rw http.ResponseWriter, r *http.Request) {
 }
+
+// statsHandler returns statistics history of the specified switch interface.
+func (h *InterfaceHandler) statsHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("interface_handlers/STATS_HANDLER",
+		"Got request to show interface statistics")
+
+	dpid := httputil.Param(r, "dpid")
+	iface := httputil.Param(r, "interface")
+	wf := WriteFormat(r)
+
+	context, err := h.C.SwitchManager.Context(dpid)
+	if err != nil {
+		log.ErrorLog("interface_handlers/STATS_HANDLER",
+			"Failed to find requested datapath: ", err)
+
+		text := fmt.Sprintf("switch '%s' not found", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotFound)
+		return
+	}
+
+	// Interface could be specified either by name or by number.
+	port, err := context.Switch.PortByName(iface)
+	if err != nil {
+		number, perr := strconv.ParseUint(iface, 10, 32)
+		if perr == nil {
+			port, err = context.Switch.PortByNumber(uint32(number))
+		}
+	}
+
+	if err != nil {
+		log.ErrorLog("interface_handlers/STATS_HANDLER",
+			"Failed to find requested interface: ", iface)
+
+		text := fmt.Sprintf("switch '%s' does not have '%s' interface", dpid, iface)
+		wf.Write(rw, models.Error{text}, http.StatusNotFound)
+		return
+	}
+
+	samples, err := h.C.SwitchManager.PortStats(context.Switch.ID(), port.Number)
+	if err != nil {
+		log.ErrorLog("interface_handlers/STATS_HANDLER",
+			"Failed to retrieve interface statistics: ", err)
+
+		text := fmt.Sprintf("statistics of '%s' inaccessible", iface)
+		wf.Write(rw, models.Error{text}, http.StatusInternalServerError)
+		return
+	}
+
+	stats := models.InterfaceStats{
+		Interface: port.Name,
+		Samples:   make([]models.PortStats, 0, len(samples)),
+	}
+
+	for _, sample := range samples {
+		stats.Samples = append(stats.Samples, models.PortStats{
+			Time:      sample.Time,
+			RxPackets: sample.Counters.RxPackets,
+			TxPackets: sample.Counters.TxPackets,
+			RxBytes:   sample.Counters.RxBytes,
+			TxBytes:   sample.Counters.TxBytes,
+			RxDropped: sample.Counters.RxDropped,
+			TxDropped: sample.Counters.TxDropped,
+			RxErrors:  sample.Counters.RxErrors,
+			TxErrors:  sample.Counters.TxErrors,
+			Rates:     models.PortRates(sample.Rates),
+		})
+	}
+
+	wf.Write(rw, stats, http.StatusOK)
+}
@@ -153,6 +153,8 @@ func (h *LinkHandler) indexHandler(rw http.ResponseWriter, r *http.Request) {
 			State:         models.NullString(switchPort.State),
 			Config:        models.NullString(switchPort.Config),
 			Features:      models.NullString(switchPort.Features),
+			Mode:          models.NullString(string(linkPort.Mode)),
+			VLAN:          linkPort.VLAN,
 			InterfaceName: switchPort.Name,
 			Interface:     switchPort.Number,
 		})
@@ -184,6 +186,17 @@ func (h *LinkHandler) createHandler(rw http.ResponseWriter, r *http.Request) {
 	port := mech.LinkPort{
 		Addr: linkModel.Addr.String(),
 		Port: context.Port.Number,
+		Mode: mech.LinkPortMode(linkModel.Mode.String()),
+		VLAN: linkModel.VLAN,
+	}
+
+	if err = port.CheckVLAN(); err != nil {
+		log.ErrorLog("link_handlers/CREATE_HANDLER",
+			"Invalid VLAN configuration: ", err)
+
+		body := models.Error{"invalid VLAN configuration"}
+		context.W.Write(rw, body, http.StatusBadRequest)
+		return
 	}
 
 	linkContext := &mech.LinkManagerContext{
@@ -222,6 +235,8 @@ func (h *LinkHandler) showHandler(rw http.ResponseWriter, r *http.Request) {
 		State:         models.NullString(context.Port.State),
 		Config:        models.NullString(context.Port.Config),
 		Features:      models.NullString(context.Port.Features),
+		Mode:          models.NullString(string(linkPort.Mode)),
+		VLAN:          linkPort.VLAN,
 		InterfaceName: context.Port.Name,
 		Interface:     context.Port.Number,
 	}
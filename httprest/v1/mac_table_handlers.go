@@ -0,0 +1,81 @@
+package httprest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/httputil"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+)
+
+func init() {
+	// Register MAC table HTTP API driver.
+	constructor := mech.HTTPDriverConstructorFunc(NewMACTableHandler)
+	mech.RegisterHTTPDriver(constructor)
+}
+
+// MACTableHandler exposes hardware addresses learned by the switch.
+type MACTableHandler struct {
+	// Base HTTP driver instance.
+	mech.BaseHTTPDriver
+}
+
+// NewMACTableHandler creates a new instance of MACTableHandler type.
+func NewMACTableHandler() mech.HTTPDriver {
+	return &MACTableHandler{}
+}
+
+// Enable implements HTTPDriver interface.
+func (h *MACTableHandler) Enable(c *mech.HTTPDriverContext) {
+	h.BaseHTTPDriver.Enable(c)
+
+	h.C.Mux.HandleFunc("GET", "/v1/datapaths/{dpid}/link/mactable", h.indexHandler)
+
+	log.InfoLog("mac_table_handlers/ENABLE_HOOK",
+		"MAC table handlers enabled")
+}
+
+func (h *MACTableHandler) indexHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("mac_table_handlers/INDEX_HANDLER",
+		"Got request to list learned hardware addresses")
+
+	dpid := httputil.Param(r, "dpid")
+	wf := WriteFormat(r)
+
+	context, err := h.C.SwitchManager.Context(dpid)
+	if err != nil {
+		log.ErrorLog("mac_table_handlers/INDEX_HANDLER",
+			"Failed to find requested datapath: ", err)
+
+		text := fmt.Sprintf("switch '%s' not found", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotFound)
+		return
+	}
+
+	reader, err := mech.MACTbl(context)
+	if err != nil {
+		text := fmt.Sprintf("hardware addresses are not learned by '%s'", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotImplemented)
+		return
+	}
+
+	names := make(map[uint32]string)
+	for _, port := range context.Switch.PortList() {
+		names[port.Number] = port.Name
+	}
+
+	entryModels := make([]models.MACEntry, 0)
+	for _, entry := range reader.MACTable() {
+		entryModels = append(entryModels, models.MACEntry{
+			Address:   entry.Addr.String(),
+			VLAN:      entry.VLAN,
+			Port:      entry.Port,
+			Interface: names[entry.Port],
+			LastSeen:  entry.LastSeen,
+		})
+	}
+
+	wf.Write(rw, entryModels, http.StatusOK)
+}
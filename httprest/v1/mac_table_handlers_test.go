@@ -0,0 +1,48 @@
+package httprest
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/mechanism"
+)
+
+type testMACTable []mech.MACEntry
+
+func (t testMACTable) MACTable() []mech.MACEntry {
+	return t
+}
+
+func TestMACTableIndex(t *testing.T) {
+	withSwitch(t, func(c *mech.HTTPDriverContext, context *mech.MechanismContext) {
+		NewMACTableHandler().Enable(c)
+
+		path := "/v1/datapaths/" + testDatapath + "/link/mactable"
+		if err := serve(c, "GET", path, "", http.StatusNotImplemented, nil); err != nil {
+			t.Fatal("Addresses must not be listed without MAC table:", err)
+		}
+
+		addr, _ := net.ParseMAC("00:00:5e:00:53:01")
+		context.Managers.Bind(new(mech.MACTableReader), testMACTable{
+			{Addr: addr, Port: 1},
+		})
+
+		var entries []models.MACEntry
+
+		if err := serve(c, "GET", path, "", http.StatusOK, &entries); err != nil {
+			t.Fatal("Failed to list learned addresses:", err)
+		}
+
+		if len(entries) != 1 || entries[0].Address != addr.String() ||
+			entries[0].Port != 1 || entries[0].Interface != "eth1" {
+			t.Fatal("Invalid learned addresses:", entries)
+		}
+
+		path = "/v1/datapaths/00:00:00:00:00:00:00:02/link/mactable"
+		if err := serve(c, "GET", path, "", http.StatusNotFound, nil); err != nil {
+			t.Fatal("Addresses of unknown switch must not be found:", err)
+		}
+	})
+}
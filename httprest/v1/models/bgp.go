@@ -0,0 +1,49 @@
+package models
+
+// BGPNeighborConfig is a JSON representation of configured BGP peer.
+type BGPNeighborConfig struct {
+	// Address of the peer.
+	Address string `json:"address"`
+
+	// Autonomous system of the peer.
+	RemoteAS uint16 `json:"remote_as"`
+}
+
+// BGP is a JSON representation of BGP configuration of the switch.
+type BGP struct {
+	// Autonomous system of the switch.
+	AS uint16 `json:"as"`
+
+	// BGP identifier of the switch.
+	RouterID string `json:"router_id"`
+
+	// Hold time in seconds proposed to peers.
+	HoldTime uint16 `json:"hold_time,omitempty"`
+
+	// Networks advertised to peers.
+	Networks []string `json:"networks"`
+
+	// Peers, sessions are opened to.
+	Neighbors []BGPNeighborConfig `json:"neighbors"`
+}
+
+// BGPNeighbor is a JSON representation of BGP session state.
+type BGPNeighbor struct {
+	// Address of the peer.
+	Address string `json:"address"`
+
+	// Autonomous system of the peer.
+	RemoteAS uint16 `json:"remote_as"`
+
+	// BGP identifier of the peer.
+	RouterID string `json:"router_id"`
+
+	// State of the session.
+	State string `json:"state"`
+
+	// Time in seconds since session is established.
+	Uptime uint32 `json:"uptime"`
+
+	// Number of prefixes received from the peer.
+	Prefixes int `json:"prefixes"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// DHCP is a JSON representation of DHCP configuration of the interface.
+type DHCP struct {
+	// First address of the pool of leased addresses.
+	PoolStart string `json:"pool_start,omitempty"`
+
+	// Last address of the pool of leased addresses.
+	PoolEnd string `json:"pool_end,omitempty"`
+
+	// Lease time in seconds.
+	LeaseTime uint32 `json:"lease_time,omitempty"`
+
+	// Addresses of domain name servers.
+	DNS []string `json:"dns,omitempty"`
+
+	// Addresses of DHCP servers, requests are relayed to.
+	Relay []string `json:"relay,omitempty"`
+}
+
+// DHCPLease is a JSON representation of address leased to the host.
+type DHCPLease struct {
+	// Leased network layer address.
+	Address string `json:"address"`
+
+	// Hardware address of the host.
+	HWAddress string `json:"hwaddr"`
+
+	// Switch port number, the host is connected to.
+	Port uint32 `json:"port"`
+
+	// Name of the switch port.
+	Interface string `json:"interface"`
+
+	// Time of the lease expiration.
+	Expires time.Time `json:"expires"`
+}
@@ -0,0 +1,37 @@
+package models
+
+// Flow is a JSON representation of flow installed on the switch.
+type Flow struct {
+	// Flow table number.
+	Table int `json:"table"`
+
+	// Flow priority.
+	Priority int `json:"priority"`
+
+	// Flow cookie in hexadecimal form.
+	Cookie string `json:"cookie"`
+
+	// Matching fields (e.g. eth_type=0x0806).
+	Match []string `json:"match"`
+
+	// Flow instructions (e.g. goto_table:1).
+	Instructions []string `json:"instructions"`
+
+	// Number of packets matched by the flow.
+	PacketCount uint64 `json:"packet_count"`
+
+	// Number of bytes matched by the flow.
+	ByteCount uint64 `json:"byte_count"`
+
+	// Time flow has been alive in seconds.
+	Duration float64 `json:"duration_sec"`
+
+	// Number of seconds idle before expiration.
+	IdleTimeout int `json:"idle_timeout"`
+
+	// Number of seconds before expiration.
+	HardTimeout int `json:"hard_timeout"`
+
+	// Name of the mechanism, that installed the flow.
+	Mechanism string `json:"mechanism,omitempty"`
+}
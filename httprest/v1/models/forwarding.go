@@ -0,0 +1,60 @@
+package models
+
+// RIBEntry is a JSON representation of candidate route.
+type RIBEntry struct {
+	// Route type (static, connected, rip)
+	Type string `json:"type"`
+
+	// Network in a CIDR notation
+	Network string `json:"network"`
+
+	// Next hop address
+	NextHop string `json:"via,omitempty"`
+
+	// Switch port number.
+	Interface uint32 `json:"interface"`
+
+	// Switch port name.
+	InterfaceName string `json:"interface_name"`
+
+	// Administrative distance and metric of the route.
+	Distance int `json:"distance"`
+	Metric   int `json:"metric"`
+
+	// Route is the best one to the network.
+	Best bool `json:"best"`
+}
+
+// FIBNextHop is a JSON representation of next hop of forwarding entry.
+type FIBNextHop struct {
+	// Next hop address and its hardware address.
+	NextHop  string `json:"via,omitempty"`
+	LinkAddr string `json:"via_hwaddr,omitempty"`
+
+	// Egress switch port number.
+	Interface uint32 `json:"interface"`
+
+	// Egress switch port name.
+	InterfaceName string `json:"interface_name"`
+}
+
+// FIBEntry is a JSON representation of forwarding entry.
+type FIBEntry struct {
+	// Type of the best routes.
+	Type string `json:"type"`
+
+	// Network in a CIDR notation
+	Network string `json:"network"`
+
+	// Next hops of the equal-cost best routes.
+	NextHops []FIBNextHop `json:"nexthops"`
+
+	// Select group identifier.
+	Group uint32 `json:"group,omitempty"`
+
+	// Priority of installed flows.
+	Priority uint16 `json:"priority"`
+
+	// State of the entry (forward, resolving, connected).
+	State string `json:"state"`
+}
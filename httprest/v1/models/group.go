@@ -0,0 +1,32 @@
+package models
+
+// GroupBucket is a JSON representation of group bucket.
+type GroupBucket struct {
+	// Relative weight of the bucket.
+	Weight uint16 `json:"weight"`
+
+	// Next hop address and its hardware address.
+	NextHop  string `json:"via"`
+	LinkAddr string `json:"via_hwaddr"`
+
+	// Egress switch port number.
+	Interface uint32 `json:"interface"`
+
+	// Egress switch port name.
+	InterfaceName string `json:"interface_name"`
+}
+
+// GroupEntry is a JSON representation of group entry.
+type GroupEntry struct {
+	// Group identifier.
+	ID uint32 `json:"id"`
+
+	// Type of the group (select).
+	Type string `json:"type"`
+
+	// Network in a CIDR notation
+	Network string `json:"network"`
+
+	// Buckets of the group.
+	Buckets []GroupBucket `json:"buckets"`
+}
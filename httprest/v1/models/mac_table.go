@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// MACEntry is a JSON representation of learned hardware address.
+type MACEntry struct {
+	// Hardware address of the host.
+	Address string `json:"address"`
+
+	// VLAN identifier of the host.
+	VLAN uint16 `json:"vlan,omitempty"`
+
+	// Switch port number, the host is connected to.
+	Port uint32 `json:"port"`
+
+	// Name of the switch port.
+	Interface string `json:"interface"`
+
+	// Time of the last frame received from the host.
+	LastSeen time.Time `json:"last_seen"`
+}
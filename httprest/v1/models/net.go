@@ -1,5 +1,9 @@
 package models
 
+import (
+	"time"
+)
+
 // Link is a JSON representation of link layer configuration.
 type Link struct {
 	// Link layer encapsulation protocol (HDLC, PPP, Ethernet)
@@ -17,6 +21,12 @@ type Link struct {
 	// Port features
 	Features nullString `json:"features"`
 
+	// VLAN mode of the port (access, trunk)
+	Mode nullString `json:"mode"`
+
+	// VLAN identifier of the access port.
+	VLAN uint16 `json:"vlan,omitempty"`
+
 	// Switch port number.
 	Interface uint32 `json:"interface,omitempty"`
 
@@ -24,13 +34,34 @@ type Link struct {
 	InterfaceName string `json:"interface_name,omitempty"`
 }
 
-// Network is a JSON representation of network layer configuration.
-type Network struct {
+// NetworkAddr is a JSON representation of network layer address.
+type NetworkAddr struct {
 	// Network layer encapsulation protocol (IPv4, IPv6, etc.)
 	Encapsulation nullString `json:"encapsulation"`
 
 	// Network layer address data
 	Addr nullString `json:"address"`
+}
+
+// Network is a JSON representation of network layer configuration.
+type Network struct {
+	// Network layer encapsulation protocol of the primary address.
+	Encapsulation nullString `json:"encapsulation"`
+
+	// Primary network layer address data
+	Addr nullString `json:"address"`
+
+	// List of all network layer addresses of the interface.
+	Addrs []NetworkAddr `json:"addresses"`
+
+	// VLAN identifier of the sub-interface of the trunk port.
+	VLAN uint16 `json:"vlan,omitempty"`
+
+	// DHCP configuration of the interface.
+	DHCP *DHCP `json:"dhcp,omitempty"`
+
+	// OSPF configuration of the interface.
+	OSPF *OSPF `json:"ospf,omitempty"`
 
 	// Switch port number.
 	Interface uint32 `json:"interface,omitempty"`
@@ -41,7 +72,7 @@ type Network struct {
 
 // Route is a JSON representation of route configuration.
 type Route struct {
-	// Route type (static, local, rip)
+	// Route type (static, local, connected)
 	Type string `json:"type,omitempty"`
 
 	// Next hop address
@@ -55,4 +86,17 @@ type Route struct {
 
 	// Switch port name.
 	InterfaceName string `json:"interface_name"`
+
+	// Administrative distance of the route, default
+	// distance of the route type is used, when omitted.
+	Distance int `json:"distance,omitempty"`
+
+	// Metric of the route.
+	Metric int `json:"metric,omitempty"`
+
+	// Administrative tag of the route.
+	Tag uint32 `json:"tag,omitempty"`
+
+	// Time, when route was created, ignored in requests.
+	CreatedAt time.Time `json:"created_at"`
 }
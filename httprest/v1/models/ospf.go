@@ -0,0 +1,68 @@
+package models
+
+// OSPF is a JSON representation of OSPF configuration of the interface.
+type OSPF struct {
+	// Type of the attached network (broadcast, point-to-point).
+	Type string `json:"type,omitempty"`
+
+	// Cost of sending packets out of the interface.
+	Cost uint16 `json:"cost,omitempty"`
+
+	// Priority of the router in the designated router election.
+	Priority *uint8 `json:"priority,omitempty"`
+
+	// Interval in seconds between hello packets.
+	HelloInterval uint16 `json:"hello_interval,omitempty"`
+
+	// Interval in seconds before neighbor is declared down.
+	DeadInterval uint32 `json:"dead_interval,omitempty"`
+}
+
+// OSPFNeighbor is a JSON representation of OSPF neighbor.
+type OSPFNeighbor struct {
+	// Router identifier of the neighbor.
+	RouterID string `json:"router_id"`
+
+	// Interface address of the neighbor.
+	Address string `json:"address"`
+
+	// Switch port number, the neighbor is connected to.
+	Port uint32 `json:"port"`
+
+	// Name of the switch port.
+	Interface string `json:"interface"`
+
+	// State of the neighbor conversation.
+	State string `json:"state"`
+
+	// Priority of the neighbor.
+	Priority uint8 `json:"priority"`
+
+	// Designated and backup designated routers declared by the neighbor.
+	DR  string `json:"dr"`
+	BDR string `json:"bdr"`
+}
+
+// OSPFLSA is a JSON representation of link state advertisement.
+type OSPFLSA struct {
+	// Type of the advertisement (router, network).
+	Type string `json:"type"`
+
+	// Link state identifier.
+	ID string `json:"ls_id"`
+
+	// Router identifier of the originator.
+	AdvRouter string `json:"adv_router"`
+
+	// Sequence number of the advertisement.
+	Seq string `json:"sequence"`
+
+	// Age of the advertisement in seconds.
+	Age uint16 `json:"age"`
+
+	// Checksum of the advertisement.
+	Checksum string `json:"checksum"`
+
+	// Length of the advertisement in bytes.
+	Length uint16 `json:"length"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+// PortRates is a JSON representation of per-second rates of port counters.
+type PortRates struct {
+	RxPackets float64 `json:"rx_packets"`
+	TxPackets float64 `json:"tx_packets"`
+	RxBytes   float64 `json:"rx_bytes"`
+	TxBytes   float64 `json:"tx_bytes"`
+	RxDropped float64 `json:"rx_dropped"`
+	TxDropped float64 `json:"tx_dropped"`
+	RxErrors  float64 `json:"rx_errors"`
+	TxErrors  float64 `json:"tx_errors"`
+}
+
+// PortStats is a JSON representation of port statistics sample.
+type PortStats struct {
+	// Time of the sample.
+	Time time.Time `json:"time"`
+
+	// Number of received and transmitted packets.
+	RxPackets uint64 `json:"rx_packets"`
+	TxPackets uint64 `json:"tx_packets"`
+
+	// Number of received and transmitted bytes.
+	RxBytes uint64 `json:"rx_bytes"`
+	TxBytes uint64 `json:"tx_bytes"`
+
+	// Number of packets dropped by receiver and transmitter.
+	RxDropped uint64 `json:"rx_dropped"`
+	TxDropped uint64 `json:"tx_dropped"`
+
+	// Number of receive and transmit errors.
+	RxErrors uint64 `json:"rx_errors"`
+	TxErrors uint64 `json:"tx_errors"`
+
+	// Rates since the previous sample.
+	Rates PortRates `json:"rates"`
+}
+
+// InterfaceStats is a JSON representation of interface statistics history.
+type InterfaceStats struct {
+	// Interface name.
+	Interface string `json:"interface"`
+
+	// Statistics samples, the latest samples are at the end.
+	Samples []PortStats `json:"samples"`
+}
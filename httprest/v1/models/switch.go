@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+)
+
+// SwitchPort is a JSON representation of switch port.
+type SwitchPort struct {
+	// Switch port name.
+	Name string `json:"name"`
+
+	// Switch port number.
+	Number uint32 `json:"number"`
+
+	// Port configuration
+	Config string `json:"config"`
+
+	// Port state
+	State string `json:"state"`
+
+	// Port features
+	Features string `json:"features"`
+}
+
+// Switch is a JSON representation of switch description.
+type Switch struct {
+	// Datapath identifier.
+	ID string `json:"id"`
+
+	// Switch name.
+	Name string `json:"name"`
+
+	// Negotiated OpenFlow protocol version.
+	Version string `json:"version"`
+
+	// Switch remote address.
+	RemoteAddr string `json:"remote_addr"`
+
+	// Switch connection state.
+	Connected bool `json:"connected"`
+
+	// Role of the controller (equal, master, slave).
+	Role string `json:"role,omitempty"`
+
+	// List of switch ports.
+	Ports []SwitchPort `json:"ports"`
+
+	// Time of the last connection.
+	ConnectedAt time.Time `json:"connected_at"`
+
+	// Time of the last disconnection.
+	DisconnectedAt *time.Time `json:"disconnected_at"`
+
+	// Liveness statistics, available only for connected switches.
+	Echo *SwitchEcho `json:"echo,omitempty"`
+}
+
+// SwitchEcho is a JSON representation of switch liveness statistics.
+type SwitchEcho struct {
+	// Round-trip time of the last echo request in milliseconds.
+	RTT float64 `json:"rtt_ms"`
+
+	// Number of subsequently missed echo replies.
+	Missed int `json:"missed"`
+
+	// Time of the last received echo reply.
+	LastReply *time.Time `json:"last_reply"`
+}
+
+// SwitchEvent is a JSON representation of switch connection event.
+type SwitchEvent struct {
+	// Event type (connect, disconnect)
+	Type string `json:"type"`
+
+	// Reason of the event.
+	Reason string `json:"reason,omitempty"`
+
+	// Switch remote address.
+	RemoteAddr string `json:"remote_addr,omitempty"`
+
+	// Time of the event.
+	Time time.Time `json:"time"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// TopologyNode is a JSON representation of switch in a topology.
+type TopologyNode struct {
+	// Datapath identifier.
+	ID string `json:"id"`
+}
+
+// TopologyEdge is a JSON representation of directed link between switches.
+type TopologyEdge struct {
+	// Datapath identifier of the source switch.
+	Source string `json:"source"`
+
+	// Port number of the source switch.
+	SourcePort uint32 `json:"source_port"`
+
+	// Datapath identifier of the target switch.
+	Target string `json:"target"`
+
+	// Port number of the target switch.
+	TargetPort uint32 `json:"target_port"`
+
+	// Cost of the link used for path computation.
+	Cost uint32 `json:"cost"`
+
+	// Time of the last link confirmation.
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Topology is a JSON representation of switches graph.
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Edges []TopologyEdge `json:"edges"`
+}
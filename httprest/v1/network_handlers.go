@@ -1,7 +1,9 @@
 package httprest
 
 import (
+	"bytes"
 	"fmt"
+	"net"
 	"net/http"
 
 	"github.com/netrack/netrack/httprest/format"
@@ -131,6 +133,169 @@ func (h *NetworkHandler) context(rw http.ResponseWriter, r *http.Request) (*Netw
 	return ctx, nil
 }
 
+// trunk returns true, when requested port is configured as trunk port.
+func (h *NetworkHandler) trunk(context *NetworkHandlerContext) bool {
+	var llink mech.LinkMechanismManager
+	if err := context.Mech.Managers.Obtain(&llink); err != nil {
+		return false
+	}
+
+	linkContext, err := llink.Context()
+	if err != nil {
+		return false
+	}
+
+	return linkContext.Port(context.Port.Number).Mode == mech.LinkPortTrunk
+}
+
+// model returns network layer configuration of the switch port, the
+// first address of the port is returned as a primary one.
+func (h *NetworkHandler) model(networkPort mech.NetworkPort, switchPort *mech.SwitchPort) models.Network {
+	networkModel := models.Network{
+		Addrs:         make([]models.NetworkAddr, 0),
+		VLAN:          networkPort.VLAN,
+		InterfaceName: switchPort.Name,
+		Interface:     switchPort.Number,
+	}
+
+	for _, addr := range networkPort.Addrs {
+		networkModel.Addrs = append(networkModel.Addrs, models.NetworkAddr{
+			Encapsulation: models.NullString(addr.Driver),
+			Addr:          models.NullString(addr.Addr),
+		})
+	}
+
+	if len(networkModel.Addrs) != 0 {
+		networkModel.Encapsulation = networkModel.Addrs[0].Encapsulation
+		networkModel.Addr = networkModel.Addrs[0].Addr
+	}
+
+	if dhcp := networkPort.DHCP; dhcp != nil {
+		networkModel.DHCP = &models.DHCP{
+			PoolStart: dhcp.PoolStart,
+			PoolEnd:   dhcp.PoolEnd,
+			LeaseTime: dhcp.LeaseTime,
+			DNS:       dhcp.DNS,
+			Relay:     dhcp.Relay,
+		}
+	}
+
+	if ospf := networkPort.OSPF; ospf != nil {
+		priority := ospf.Priority
+		networkModel.OSPF = &models.OSPF{
+			Type:          ospf.Type,
+			Cost:          ospf.Cost,
+			Priority:      &priority,
+			HelloInterval: ospf.HelloInterval,
+			DeadInterval:  ospf.DeadInterval,
+		}
+	}
+
+	return networkModel
+}
+
+// dhcp returns DHCP configuration of the interface, error is
+// returned, when pool is not a valid range of IPv4 addresses.
+func (h *NetworkHandler) dhcp(dhcpModel *models.DHCP) (*mech.DHCPConfig, error) {
+	if dhcpModel == nil {
+		return nil, nil
+	}
+
+	// Interface relays requests to the DHCP servers.
+	if len(dhcpModel.Relay) != 0 {
+		return h.dhcpRelay(dhcpModel)
+	}
+
+	start := net.ParseIP(dhcpModel.PoolStart).To4()
+	end := net.ParseIP(dhcpModel.PoolEnd).To4()
+
+	if start == nil || end == nil || bytes.Compare(start, end) > 0 {
+		return nil, fmt.Errorf("invalid DHCP pool")
+	}
+
+	for _, dns := range dhcpModel.DNS {
+		if net.ParseIP(dns).To4() == nil {
+			return nil, fmt.Errorf("invalid DNS server address")
+		}
+	}
+
+	config := &mech.DHCPConfig{
+		PoolStart: start.String(),
+		PoolEnd:   end.String(),
+		LeaseTime: dhcpModel.LeaseTime,
+		DNS:       dhcpModel.DNS,
+	}
+
+	return config, nil
+}
+
+// dhcpRelay returns DHCP relay configuration of the interface, error is
+// returned, when pool is defined or server addresses are not valid.
+func (h *NetworkHandler) dhcpRelay(dhcpModel *models.DHCP) (*mech.DHCPConfig, error) {
+	if dhcpModel.PoolStart != "" || dhcpModel.PoolEnd != "" {
+		return nil, fmt.Errorf("DHCP pool and relay are mutually exclusive")
+	}
+
+	var relay []string
+	for _, server := range dhcpModel.Relay {
+		addr := net.ParseIP(server).To4()
+		if addr == nil {
+			return nil, fmt.Errorf("invalid DHCP server address")
+		}
+
+		relay = append(relay, addr.String())
+	}
+
+	return &mech.DHCPConfig{Relay: relay}, nil
+}
+
+// ospf returns OSPF configuration of the interface, default values
+// are used for omitted parameters, error is returned, when network
+// type is unknown or dead interval does not exceed hello interval.
+func (h *NetworkHandler) ospf(ospfModel *models.OSPF) (*mech.OSPFConfig, error) {
+	if ospfModel == nil {
+		return nil, nil
+	}
+
+	config := &mech.OSPFConfig{
+		Type:          ospfModel.Type,
+		Cost:          ospfModel.Cost,
+		Priority:      1,
+		HelloInterval: ospfModel.HelloInterval,
+		DeadInterval:  ospfModel.DeadInterval,
+	}
+
+	switch config.Type {
+	case "":
+		config.Type = mech.OSPFBroadcast
+	case mech.OSPFBroadcast, mech.OSPFPointToPoint:
+	default:
+		return nil, fmt.Errorf("invalid OSPF network type")
+	}
+
+	if config.Cost == 0 {
+		config.Cost = 10
+	}
+
+	if ospfModel.Priority != nil {
+		config.Priority = *ospfModel.Priority
+	}
+
+	if config.HelloInterval == 0 {
+		config.HelloInterval = 10
+	}
+
+	if config.DeadInterval == 0 {
+		config.DeadInterval = 4 * uint32(config.HelloInterval)
+	}
+
+	if config.DeadInterval <= uint32(config.HelloInterval) {
+		return nil, fmt.Errorf("OSPF dead interval must exceed hello interval")
+	}
+
+	return config, nil
+}
+
 func (h *NetworkHandler) indexHandler(rw http.ResponseWriter, r *http.Request) {
 	log.InfoLog("network_handlers/INDEX_HANDLER",
 		"Got request to list network layer addresses")
@@ -144,13 +309,7 @@ func (h *NetworkHandler) indexHandler(rw http.ResponseWriter, r *http.Request) {
 
 	for _, switchPort := range context.Mech.Switch.PortList() {
 		networkPort := context.NetworkContext.Port(switchPort.Number)
-
-		networkModels = append(networkModels, models.Network{
-			Encapsulation: models.NullString(context.NetworkContext.Driver),
-			Addr:          models.NullString(networkPort.Addr),
-			InterfaceName: switchPort.Name,
-			Interface:     switchPort.Number,
-		})
+		networkModels = append(networkModels, h.model(networkPort, switchPort))
 	}
 
 	context.W.Write(rw, networkModels, http.StatusOK)
@@ -175,14 +334,71 @@ func (h *NetworkHandler) createHandler(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	dhcp, err := h.dhcp(networkModel.DHCP)
+	if err != nil {
+		log.ErrorLog("network_handlers/CREATE_HANDLER",
+			"Failed to read DHCP configuration: ", err)
+
+		body := models.Error{err.Error()}
+		context.W.Write(rw, body, http.StatusBadRequest)
+		return
+	}
+
+	ospf, err := h.ospf(networkModel.OSPF)
+	if err != nil {
+		log.ErrorLog("network_handlers/CREATE_HANDLER",
+			"Failed to read OSPF configuration: ", err)
+
+		body := models.Error{err.Error()}
+		context.W.Write(rw, body, http.StatusBadRequest)
+		return
+	}
+
 	port := mech.NetworkPort{
-		Addr: networkModel.Addr.String(),
 		Port: context.Port.Number,
+		VLAN: networkModel.VLAN,
+		DHCP: dhcp,
+		OSPF: ospf,
+	}
+
+	// Single address is accepted as a primary one.
+	if networkModel.Addr.String() != "" {
+		networkModel.Addrs = append([]models.NetworkAddr{{
+			Encapsulation: networkModel.Encapsulation,
+			Addr:          networkModel.Addr,
+		}}, networkModel.Addrs...)
+	}
+
+	for _, addr := range networkModel.Addrs {
+		if addr.Addr.String() == "" {
+			body := models.Error{"network address is required"}
+			context.W.Write(rw, body, http.StatusBadRequest)
+			return
+		}
+
+		port.Addrs = append(port.Addrs, mech.NetworkAddress{
+			Addr:   addr.Addr.String(),
+			Driver: addr.Encapsulation.String(),
+		})
+	}
+
+	if port.VLAN > mech.VLANMax {
+		body := models.Error{"invalid VLAN identifier"}
+		context.W.Write(rw, body, http.StatusBadRequest)
+		return
+	}
+
+	if port.VLAN != 0 && !h.trunk(context) {
+		log.ErrorLog("network_handlers/CREATE_HANDLER",
+			"Sub-interface requested on not trunk port: ", context.Port.Name)
+
+		body := models.Error{"VLAN sub-interface requires trunk port"}
+		context.W.Write(rw, body, http.StatusBadRequest)
+		return
 	}
 
 	networkContext := &mech.NetworkManagerContext{
 		Datapath: context.Mech.Switch.ID(),
-		Driver:   networkModel.Encapsulation.String(),
 		Ports:    []mech.NetworkPort{port},
 	}
 
@@ -207,17 +423,10 @@ func (h *NetworkHandler) showHandler(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	networkContext := context.NetworkContext
-	networkPort := networkContext.Port(context.Port.Number)
+	networkPort := context.NetworkContext.Port(context.Port.Number)
 
 	// Return interface network data.
-	body := models.Network{
-		Encapsulation: models.NullString(networkContext.Driver),
-		Addr:          models.NullString(networkPort.Addr),
-		InterfaceName: context.Port.Name,
-		Interface:     context.Port.Number,
-	}
-
+	body := h.model(networkPort, context.Port)
 	context.W.Write(rw, body, http.StatusOK)
 }
 
@@ -0,0 +1,121 @@
+package httprest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/httputil"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+)
+
+func init() {
+	// Register OSPF HTTP API driver.
+	constructor := mech.HTTPDriverConstructorFunc(NewOSPFHandler)
+	mech.RegisterHTTPDriver(constructor)
+}
+
+// OSPFHandler exposes neighbors and link state database of OSPF.
+type OSPFHandler struct {
+	// Base HTTP driver instance.
+	mech.BaseHTTPDriver
+}
+
+// NewOSPFHandler creates a new instance of OSPFHandler type.
+func NewOSPFHandler() mech.HTTPDriver {
+	return &OSPFHandler{}
+}
+
+// Enable implements HTTPDriver interface.
+func (h *OSPFHandler) Enable(c *mech.HTTPDriverContext) {
+	h.BaseHTTPDriver.Enable(c)
+
+	h.C.Mux.HandleFunc("GET", "/v1/datapaths/{dpid}/ospf/neighbors", h.neighborsHandler)
+	h.C.Mux.HandleFunc("GET", "/v1/datapaths/{dpid}/ospf/database", h.databaseHandler)
+
+	log.InfoLog("ospf_handlers/ENABLE_HOOK",
+		"OSPF handlers enabled")
+}
+
+// reader returns OSPF state reader of the requested switch,
+// error response is written, when reader is not available.
+func (h *OSPFHandler) reader(rw http.ResponseWriter, r *http.Request) (*mech.MechanismContext, mech.OSPFReader, error) {
+	dpid := httputil.Param(r, "dpid")
+	wf := WriteFormat(r)
+
+	context, err := h.C.SwitchManager.Context(dpid)
+	if err != nil {
+		log.ErrorLog("ospf_handlers/READER",
+			"Failed to find requested datapath: ", err)
+
+		text := fmt.Sprintf("switch '%s' not found", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotFound)
+		return nil, nil, err
+	}
+
+	reader, err := mech.OSPFRdr(context)
+	if err != nil {
+		text := fmt.Sprintf("OSPF is not running on '%s'", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotImplemented)
+		return nil, nil, err
+	}
+
+	return context, reader, nil
+}
+
+func (h *OSPFHandler) neighborsHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("ospf_handlers/NEIGHBORS_HANDLER",
+		"Got request to list OSPF neighbors")
+
+	context, reader, err := h.reader(rw, r)
+	if err != nil {
+		return
+	}
+
+	names := make(map[uint32]string)
+	for _, port := range context.Switch.PortList() {
+		names[port.Number] = port.Name
+	}
+
+	neighborModels := make([]models.OSPFNeighbor, 0)
+	for _, nbr := range reader.OSPFNeighbors() {
+		neighborModels = append(neighborModels, models.OSPFNeighbor{
+			RouterID:  nbr.RouterID,
+			Address:   nbr.Addr,
+			Port:      nbr.Port,
+			Interface: names[nbr.Port],
+			State:     nbr.State,
+			Priority:  nbr.Priority,
+			DR:        nbr.DR,
+			BDR:       nbr.BDR,
+		})
+	}
+
+	WriteFormat(r).Write(rw, neighborModels, http.StatusOK)
+}
+
+func (h *OSPFHandler) databaseHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("ospf_handlers/DATABASE_HANDLER",
+		"Got request to list OSPF link state database")
+
+	_, reader, err := h.reader(rw, r)
+	if err != nil {
+		return
+	}
+
+	lsaModels := make([]models.OSPFLSA, 0)
+	for _, lsa := range reader.OSPFDatabase() {
+		lsaModels = append(lsaModels, models.OSPFLSA{
+			Type:      lsa.Type,
+			ID:        lsa.ID,
+			AdvRouter: lsa.AdvRouter,
+			Seq:       fmt.Sprintf("0x%08x", lsa.Seq),
+			Age:       lsa.Age,
+			Checksum:  fmt.Sprintf("0x%04x", lsa.Checksum),
+			Length:    lsa.Length,
+		})
+	}
+
+	WriteFormat(r).Write(rw, lsaModels, http.StatusOK)
+}
@@ -0,0 +1,82 @@
+package httprest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/mechanism"
+)
+
+type testOSPFReader struct {
+	neighbors []mech.OSPFNeighbor
+	database  []mech.OSPFLSA
+}
+
+func (r *testOSPFReader) OSPFNeighbors() []mech.OSPFNeighbor {
+	return r.neighbors
+}
+
+func (r *testOSPFReader) OSPFDatabase() []mech.OSPFLSA {
+	return r.database
+}
+
+func withOSPF(t *testing.T, fn func(*mech.HTTPDriverContext)) {
+	withSwitch(t, func(c *mech.HTTPDriverContext, context *mech.MechanismContext) {
+		NewOSPFHandler().Enable(c)
+
+		path := "/v1/datapaths/" + testDatapath + "/ospf/neighbors"
+		if err := serve(c, "GET", path, "", http.StatusNotImplemented, nil); err != nil {
+			t.Fatal("Neighbors must not be listed without OSPF:", err)
+		}
+
+		path = "/v1/datapaths/00:00:00:00:00:00:00:02/ospf/database"
+		if err := serve(c, "GET", path, "", http.StatusNotFound, nil); err != nil {
+			t.Fatal("Database of unknown switch must not be found:", err)
+		}
+
+		context.Managers.Bind(new(mech.OSPFReader), &testOSPFReader{
+			neighbors: []mech.OSPFNeighbor{
+				{RouterID: "192.0.2.2", Addr: "10.0.0.2", Port: 1, State: "Full"},
+			},
+			database: []mech.OSPFLSA{
+				{Type: "router", ID: "192.0.2.2", AdvRouter: "192.0.2.2",
+					Seq: 0x80000001, Checksum: 0xabcd, Length: 36},
+			},
+		})
+
+		fn(c)
+	})
+}
+
+func TestOSPFNeighborsIndex(t *testing.T) {
+	withOSPF(t, func(c *mech.HTTPDriverContext) {
+		var neighbors []models.OSPFNeighbor
+
+		path := "/v1/datapaths/" + testDatapath + "/ospf/neighbors"
+		if err := serve(c, "GET", path, "", http.StatusOK, &neighbors); err != nil {
+			t.Fatal("Failed to list neighbors:", err)
+		}
+
+		if len(neighbors) != 1 || neighbors[0].RouterID != "192.0.2.2" ||
+			neighbors[0].Interface != "eth1" || neighbors[0].State != "Full" {
+			t.Fatal("Invalid neighbors:", neighbors)
+		}
+	})
+}
+
+func TestOSPFDatabaseIndex(t *testing.T) {
+	withOSPF(t, func(c *mech.HTTPDriverContext) {
+		var lsas []models.OSPFLSA
+
+		path := "/v1/datapaths/" + testDatapath + "/ospf/database"
+		if err := serve(c, "GET", path, "", http.StatusOK, &lsas); err != nil {
+			t.Fatal("Failed to list link state database:", err)
+		}
+
+		if len(lsas) != 1 || lsas[0].Type != "router" ||
+			lsas[0].Seq != "0x80000001" || lsas[0].Checksum != "0xabcd" {
+			t.Fatal("Invalid link state database:", lsas)
+		}
+	})
+}
@@ -118,7 +118,7 @@ func (h *RoutingHandler) indexHandler(rw http.ResponseWriter, r *http.Request) {
 	routingContext := context.RoutingContext
 	routeModels := make([]models.Route, 0)
 
-	for _, route := range routingContext.Routes {
+	for _, route := range routingContext.Sorted() {
 		switchPort, _ := context.Mech.Switch.PortByNumber(route.Port)
 
 		routeModels = append(routeModels, models.Route{
@@ -127,6 +127,10 @@ func (h *RoutingHandler) indexHandler(rw http.ResponseWriter, r *http.Request) {
 			NextHop:       route.NextHop,
 			Interface:     switchPort.Number,
 			InterfaceName: switchPort.Name,
+			Distance:      route.Distance,
+			Metric:        route.Metric,
+			Tag:           route.Tag,
+			CreatedAt:     route.CreatedAt,
 		})
 	}
 
@@ -157,6 +161,31 @@ func (h *RoutingHandler) alter(rw http.ResponseWriter, r *http.Request) (*Routin
 	}
 
 	for _, route := range routeModels {
+		routeType := mech.RouteType(route.Type)
+
+		switch routeType {
+		case "":
+			routeType = mech.StaticRoute
+		case mech.StaticRoute, mech.LocalRoute, mech.ConnectedRoute:
+		default:
+			// Dynamic routes are learned by routing protocols.
+			log.ErrorLog("routing_handlers/ALTER_ROUTES",
+				"Requested route type is not configurable: ", route.Type)
+
+			text := fmt.Sprintf("route type '%s' is not configurable", route.Type)
+			context.W.Write(rw, models.Error{text}, http.StatusBadRequest)
+			return nil, fmt.Errorf(text)
+		}
+
+		if route.Distance < 0 || route.Metric < 0 {
+			log.ErrorLog("routing_handlers/ALTER_ROUTES",
+				"Requested route has negative distance or metric: ", route.Network)
+
+			text := fmt.Sprintf("route to '%s' has negative distance or metric", route.Network)
+			context.W.Write(rw, models.Error{text}, http.StatusBadRequest)
+			return nil, fmt.Errorf(text)
+		}
+
 		switchPort, err := context.Mech.Switch.PortByName(route.InterfaceName)
 		if err != nil {
 			log.ErrorLog("routing_handlers/ALTER_ROUTES",
@@ -169,10 +198,13 @@ func (h *RoutingHandler) alter(rw http.ResponseWriter, r *http.Request) (*Routin
 		}
 
 		routingContext.Routes = append(routingContext.Routes, &mech.Route{
-			Type:    string(mech.StaticRoute),
-			Network: route.Network,
-			NextHop: route.NextHop,
-			Port:    switchPort.Number,
+			Type:     string(routeType),
+			Network:  route.Network,
+			NextHop:  route.NextHop,
+			Port:     switchPort.Number,
+			Distance: route.Distance,
+			Metric:   route.Metric,
+			Tag:      route.Tag,
 		})
 	}
 
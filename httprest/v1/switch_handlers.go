@@ -0,0 +1,173 @@
+package httprest
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/httputil"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+)
+
+func init() {
+	// Register switch registry HTTP API driver.
+	constructor := mech.HTTPDriverConstructorFunc(NewSwitchHandler)
+	mech.RegisterHTTPDriver(constructor)
+}
+
+// SwitchHandler exposes switches, that were
+// ever connected to the controller.
+type SwitchHandler struct {
+	// Base HTTP driver instance.
+	mech.BaseHTTPDriver
+
+	// Contexts of disconnected switches.
+	offline map[string]*mech.MechanismContext
+	lock    sync.RWMutex
+}
+
+// NewSwitchHandler creates a new instance of SwitchHandler type.
+func NewSwitchHandler() mech.HTTPDriver {
+	return &SwitchHandler{offline: make(map[string]*mech.MechanismContext)}
+}
+
+// Enable implements HTTPDriver interface.
+func (h *SwitchHandler) Enable(c *mech.HTTPDriverContext) {
+	h.BaseHTTPDriver.Enable(c)
+
+	h.C.Mux.HandleFunc("GET", "/v1/switches", h.indexHandler)
+	h.C.Mux.HandleFunc("GET", "/v1/switches/{dpid}/history", h.historyHandler)
+
+	// Report disconnection of switches.
+	h.C.SwitchManager.HandleDisconnectFunc(h.disconnectHandler)
+
+	log.InfoLog("switch_handlers/ENABLE_HOOK",
+		"Switch handlers enabled")
+}
+
+func (h *SwitchHandler) disconnectHandler(c *mech.MechanismContext, reason string) {
+	log.InfoLogf("switch_handlers/DISCONNECT_HANDLER",
+		"Switch %s disconnected: %s", c.Switch.ID(), reason)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	// Context is replaced, when switch connects again.
+	h.offline[c.Switch.ID()] = c
+}
+
+// connected returns true, when switch is served by switch
+// manager and was not reported as disconnected.
+func (h *SwitchHandler) connected(dpid string) bool {
+	context, err := h.C.SwitchManager.Context(dpid)
+	if err != nil {
+		return false
+	}
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return h.offline[dpid] != context
+}
+
+func (h *SwitchHandler) switchModel(record *mech.SwitchRecord) models.Switch {
+	ports := make([]models.SwitchPort, 0)
+	for _, port := range record.Ports {
+		ports = append(ports, models.SwitchPort{
+			Name:     port.Name,
+			Number:   port.Number,
+			Config:   port.Config,
+			State:    port.State,
+			Features: port.Features,
+		})
+	}
+
+	var role string
+
+	if r, err := h.C.SwitchManager.Role(record.Datapath); err == nil {
+		role = string(r)
+	}
+
+	var echo *models.SwitchEcho
+
+	if stats, err := h.C.SwitchManager.EchoStats(record.Datapath); err == nil {
+		echo = &models.SwitchEcho{
+			RTT:    float64(stats.RTT) / float64(time.Millisecond),
+			Missed: stats.Missed,
+		}
+
+		if !stats.LastReply.IsZero() {
+			echo.LastReply = &stats.LastReply
+		}
+	}
+
+	return models.Switch{
+		ID:             record.Datapath,
+		Name:           record.Name,
+		Version:        record.Version,
+		RemoteAddr:     record.RemoteAddr,
+		Connected:      h.connected(record.Datapath),
+		Role:           role,
+		Ports:          ports,
+		ConnectedAt:    record.ConnectedAt,
+		DisconnectedAt: record.DisconnectedAt,
+		Echo:           echo,
+	}
+}
+
+func (h *SwitchHandler) indexHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("switch_handlers/INDEX_HANDLER",
+		"Got request to list switches")
+
+	wf := WriteFormat(r)
+
+	records, err := h.C.SwitchManager.SwitchRecords()
+	if err != nil {
+		log.ErrorLog("switch_handlers/INDEX_HANDLER",
+			"Failed to read switch records: ", err)
+
+		text := fmt.Sprintf("switch registry inaccessible")
+		wf.Write(rw, models.Error{text}, http.StatusInternalServerError)
+		return
+	}
+
+	switchModels := make([]models.Switch, 0)
+	for i := range records {
+		switchModels = append(switchModels, h.switchModel(&records[i]))
+	}
+
+	wf.Write(rw, switchModels, http.StatusOK)
+}
+
+func (h *SwitchHandler) historyHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("switch_handlers/HISTORY_HANDLER",
+		"Got request to show switch history")
+
+	dpid := httputil.Param(r, "dpid")
+	wf := WriteFormat(r)
+
+	record, err := h.C.SwitchManager.SwitchRecord(dpid)
+	if err != nil {
+		log.ErrorLog("switch_handlers/HISTORY_HANDLER",
+			"Failed to find requested datapath: ", err)
+
+		text := fmt.Sprintf("switch '%s' not found", dpid)
+		wf.Write(rw, models.Error{text}, http.StatusNotFound)
+		return
+	}
+
+	eventModels := make([]models.SwitchEvent, 0)
+	for _, event := range record.History {
+		eventModels = append(eventModels, models.SwitchEvent{
+			Type:       string(event.Type),
+			Reason:     event.Reason,
+			RemoteAddr: event.RemoteAddr,
+			Time:       event.Time,
+		})
+	}
+
+	wf.Write(rw, eventModels, http.StatusOK)
+}
@@ -0,0 +1,77 @@
+package httprest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/mechanism"
+)
+
+func TestSwitchIndex(t *testing.T) {
+	withSwitch(t, func(c *mech.HTTPDriverContext, context *mech.MechanismContext) {
+		NewSwitchHandler().Enable(c)
+
+		var switches []models.Switch
+
+		err := serve(c, "GET", "/v1/switches", "", http.StatusOK, &switches)
+		if err != nil {
+			t.Fatal("Failed to list switches:", err)
+		}
+
+		if len(switches) != 1 {
+			t.Fatal("Invalid number of switches:", switches)
+		}
+
+		sw := switches[0]
+		if sw.ID != testDatapath || sw.Name != "br0" || sw.Version != "OFP/1.3" {
+			t.Fatal("Invalid switch description:", sw)
+		}
+
+		if !sw.Connected || len(sw.Ports) != 1 || sw.Ports[0].Name != "eth1" {
+			t.Fatal("Invalid switch state:", sw)
+		}
+	})
+}
+
+func TestSwitchHistory(t *testing.T) {
+	withSwitch(t, func(c *mech.HTTPDriverContext, context *mech.MechanismContext) {
+		NewSwitchHandler().Enable(c)
+
+		var events []models.SwitchEvent
+
+		path := "/v1/switches/" + testDatapath + "/history"
+		if err := serve(c, "GET", path, "", http.StatusOK, &events); err != nil {
+			t.Fatal("Failed to list switch history:", err)
+		}
+
+		if len(events) != 1 || events[0].Type != string(mech.SwitchConnected) {
+			t.Fatal("Invalid switch history:", events)
+		}
+
+		path = "/v1/switches/00:00:00:00:00:00:00:02/history"
+		if err := serve(c, "GET", path, "", http.StatusNotFound, nil); err != nil {
+			t.Fatal("History of unknown switch must not be found:", err)
+		}
+	})
+}
+
+func TestSwitchDisconnect(t *testing.T) {
+	withSwitch(t, func(c *mech.HTTPDriverContext, context *mech.MechanismContext) {
+		h := NewSwitchHandler().(*SwitchHandler)
+		h.Enable(c)
+
+		h.disconnectHandler(context, "connection reset")
+
+		var switches []models.Switch
+
+		err := serve(c, "GET", "/v1/switches", "", http.StatusOK, &switches)
+		if err != nil {
+			t.Fatal("Failed to list switches:", err)
+		}
+
+		if len(switches) != 1 || switches[0].Connected {
+			t.Fatal("Disconnected switch must be offline:", switches)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package httprest
+
+import (
+	"net/http"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+)
+
+func init() {
+	// Register topology HTTP API driver.
+	constructor := mech.HTTPDriverConstructorFunc(NewTopologyHandler)
+	mech.RegisterHTTPDriver(constructor)
+}
+
+// TopologyHandler exposes discovered links between switches.
+type TopologyHandler struct {
+	// Base HTTP driver instance.
+	mech.BaseHTTPDriver
+}
+
+// NewTopologyHandler creates a new instance of TopologyHandler type.
+func NewTopologyHandler() mech.HTTPDriver {
+	return &TopologyHandler{}
+}
+
+// Enable implements HTTPDriver interface.
+func (h *TopologyHandler) Enable(c *mech.HTTPDriverContext) {
+	h.BaseHTTPDriver.Enable(c)
+
+	h.C.Mux.HandleFunc("GET", "/v1/topology", h.indexHandler)
+
+	log.InfoLog("topology_handlers/ENABLE_HOOK",
+		"Topology handlers enabled")
+}
+
+func (h *TopologyHandler) indexHandler(rw http.ResponseWriter, r *http.Request) {
+	log.InfoLog("topology_handlers/INDEX_HANDLER",
+		"Got request to show topology")
+
+	wf := WriteFormat(r)
+
+	topology := models.Topology{
+		Nodes: make([]models.TopologyNode, 0),
+		Edges: make([]models.TopologyEdge, 0),
+	}
+
+	for _, dpid := range mech.DefaultTopology.Nodes() {
+		topology.Nodes = append(topology.Nodes, models.TopologyNode{dpid})
+	}
+
+	for _, link := range mech.DefaultTopology.Links() {
+		topology.Edges = append(topology.Edges, models.TopologyEdge{
+			Source:     link.SrcDatapath,
+			SourcePort: link.SrcPort,
+			Target:     link.DstDatapath,
+			TargetPort: link.DstPort,
+			Cost:       link.Weight(),
+			LastSeen:   link.LastSeen,
+		})
+	}
+
+	wf.Write(rw, topology, http.StatusOK)
+}
@@ -0,0 +1,47 @@
+package httprest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/netrack/netrack/httprest/v1/models"
+	"github.com/netrack/netrack/httputil"
+	"github.com/netrack/netrack/mechanism"
+)
+
+func TestTopologyIndex(t *testing.T) {
+	defer func(topology *mech.Topology) {
+		mech.DefaultTopology = topology
+	}(mech.DefaultTopology)
+
+	mech.DefaultTopology = mech.NewTopology()
+	mech.DefaultTopology.UpdateLink(mech.TopologyLink{
+		SrcDatapath: "00:00:00:00:00:00:00:01",
+		SrcPort:     1,
+		DstDatapath: "00:00:00:00:00:00:00:02",
+		DstPort:     2,
+	})
+
+	c := &mech.HTTPDriverContext{Mux: httputil.NewServeMux()}
+	NewTopologyHandler().Enable(c)
+
+	var topology models.Topology
+
+	if err := serve(c, "GET", "/v1/topology", "", http.StatusOK, &topology); err != nil {
+		t.Fatal("Failed to show topology:", err)
+	}
+
+	if len(topology.Nodes) != 2 || topology.Nodes[0].ID != "00:00:00:00:00:00:00:01" {
+		t.Fatal("Invalid topology nodes:", topology.Nodes)
+	}
+
+	if len(topology.Edges) != 1 {
+		t.Fatal("Invalid number of topology edges:", topology.Edges)
+	}
+
+	edge := topology.Edges[0]
+	if edge.Source != "00:00:00:00:00:00:00:01" || edge.SourcePort != 1 ||
+		edge.Target != "00:00:00:00:00:00:00:02" || edge.TargetPort != 2 {
+		t.Fatal("Invalid topology edge:", edge)
+	}
+}
@@ -0,0 +1,103 @@
+package mech
+
+import (
+	"errors"
+
+	"github.com/netrack/netrack/database"
+	"github.com/netrack/netrack/logging"
+)
+
+const (
+	// BGPModel is a database table name (bgp_speakers)
+	BGPModel db.Model = "bgp_speaker"
+)
+
+func init() {
+	// Register model in a database to make it available
+	db.Register(BGPModel)
+}
+
+// ErrBGP is returned when BGP speaker is not available on the switch.
+var ErrBGP = errors.New("BGP: speaker is not available")
+
+// BGPNeighborConfig is a configuration of the external BGP peer.
+type BGPNeighborConfig struct {
+	// Address of the peer.
+	Addr string `json:"address"`
+
+	// Autonomous system of the peer.
+	AS uint16 `json:"remote_as"`
+}
+
+// BGPConfig is a configuration of the BGP speaker of the switch.
+type BGPConfig struct {
+	// Autonomous system of the switch.
+	AS uint16 `json:"as"`
+
+	// BGP identifier of the switch.
+	RouterID string `json:"router_id"`
+
+	// Hold time in seconds proposed to peers.
+	HoldTime uint16 `json:"hold_time"`
+
+	// Networks advertised to peers.
+	Networks []string `json:"networks"`
+
+	// Peers, sessions are opened to.
+	Neighbors []BGPNeighborConfig `json:"neighbors"`
+}
+
+// BGPNeighbor is a state of the session with the BGP peer.
+type BGPNeighbor struct {
+	// Address of the peer.
+	Addr string `json:"address"`
+
+	// Autonomous system of the peer.
+	AS uint16 `json:"remote_as"`
+
+	// BGP identifier of the peer.
+	RouterID string `json:"router_id"`
+
+	// State of the session.
+	State string `json:"state"`
+
+	// Time in seconds since session is established.
+	Uptime uint32 `json:"uptime"`
+
+	// Number of prefixes received from the peer.
+	Prefixes int `json:"prefixes"`
+}
+
+// BGPContext is a persisted BGP configuration of the switch.
+type BGPContext struct {
+	// Datapath identifier.
+	Datapath string `json:"id"`
+
+	// Configuration of the speaker, nil when BGP is not configured.
+	Config *BGPConfig `json:"config"`
+}
+
+// BGPSpeaker is the interface implemented
+// by mechanisms, that run BGP protocol.
+type BGPSpeaker interface {
+	// BGPConfig returns configuration of the speaker.
+	BGPConfig() *BGPConfig
+
+	// UpdateBGP persists and applies configuration of the speaker.
+	UpdateBGP(*BGPConfig) error
+
+	// BGPNeighbors returns states of sessions with peers.
+	BGPNeighbors() []BGPNeighbor
+}
+
+// BGPSpkr returns BGP speaker of the switch.
+func BGPSpkr(context *MechanismContext) (BGPSpeaker, error) {
+	var speaker BGPSpeaker
+	if err := context.Managers.Obtain(&speaker); err != nil {
+		log.ErrorLog("mechanism/BGP_SPEAKER",
+			"Failed to obtain BGP speaker: ", err)
+		return nil, ErrBGP
+	}
+
+	return speaker, nil
+}
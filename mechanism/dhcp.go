@@ -0,0 +1,83 @@
+package mech
+
+import (
+	"errors"
+	"time"
+
+	"github.com/netrack/netrack/database"
+	"github.com/netrack/netrack/logging"
+)
+
+const (
+	// DHCPLeaseModel is a database table name (dhcp_leases)
+	DHCPLeaseModel db.Model = "dhcp_lease"
+)
+
+func init() {
+	// Register model in a database to make it available
+	db.Register(DHCPLeaseModel)
+}
+
+// ErrDHCPLeases is returned when switch does not serve DHCP leases.
+var ErrDHCPLeases = errors.New("DHCPLeases: addresses are not leased")
+
+// DHCPConfig is a DHCP configuration of the network interface.
+type DHCPConfig struct {
+	// First and last addresses of the pool of leased addresses,
+	// pool belongs to the network of the interface address.
+	PoolStart string `json:"pool_start,omitempty"`
+	PoolEnd   string `json:"pool_end,omitempty"`
+
+	// Lease time in seconds, default one is used, when zero.
+	LeaseTime uint32 `json:"lease_time,omitempty"`
+
+	// Addresses of domain name servers.
+	DNS []string `json:"dns,omitempty"`
+
+	// Addresses of DHCP servers, requests of hosts are relayed
+	// to, interface either relays requests or leases addresses.
+	Relay []string `json:"relay,omitempty"`
+}
+
+// DHCPLease is an address leased to the host.
+type DHCPLease struct {
+	// Leased network layer address.
+	Addr string `json:"address"`
+
+	// Hardware address of the host.
+	LinkAddr string `json:"hwaddr"`
+
+	// Switch port number, the host is connected to.
+	Port uint32 `json:"port"`
+
+	// Time of the lease expiration.
+	Expires time.Time `json:"expires"`
+}
+
+// DHCPLeaseContext is a persisted list of leases of the switch.
+type DHCPLeaseContext struct {
+	// Datapath identifier.
+	Datapath string `json:"id"`
+
+	// List of active leases.
+	Leases []DHCPLease `json:"leases"`
+}
+
+// DHCPLeaseReader is the interface implemented
+// by mechanisms, that lease addresses to hosts.
+type DHCPLeaseReader interface {
+	// DHCPLeases returns active leases.
+	DHCPLeases() []DHCPLease
+}
+
+// DHCPLeaseTbl returns leases reader of the switch.
+func DHCPLeaseTbl(context *MechanismContext) (DHCPLeaseReader, error) {
+	var reader DHCPLeaseReader
+	if err := context.Managers.Obtain(&reader); err != nil {
+		log.ErrorLog("mechanism/DHCP_LEASES",
+			"Failed to obtain DHCP leases reader: ", err)
+		return nil, ErrDHCPLeases
+	}
+
+	return reader, nil
+}
@@ -0,0 +1,32 @@
+// Package election implements leader election between
+// controller instances, that manage the same switches.
+package election
+
+import (
+	"errors"
+)
+
+var (
+	// ErrNotLeader is returned on attempt to resign
+	// leadership, that was not acquired.
+	ErrNotLeader = errors.New("election: leadership is not acquired")
+)
+
+// Elector decides which controller instance is a leader for the key.
+type Elector interface {
+	// Elect tries to acquire leadership for specified key, when
+	// leadership is acquired, true is returned along with the
+	// generation identifier of leadership. Repeated calls for
+	// the acquired key return the same generation identifier.
+	Elect(key string) (uint64, bool, error)
+
+	// Generation returns the latest generation identifier of
+	// leadership for specified key, regardless of the leader.
+	Generation(key string) (uint64, error)
+
+	// Resign releases leadership for specified key.
+	Resign(key string) error
+
+	// Close releases all acquired leaderships.
+	Close() error
+}
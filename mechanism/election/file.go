@@ -0,0 +1,163 @@
+package election
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// FileElector implements Elector interface using exclusive locks
+// of files in a shared directory. Lock file contains identifier of
+// the leader and generation identifier of leadership. Lock is
+// released by the operating system, when leader terminates.
+type FileElector struct {
+	// Directory with lock files.
+	Dir string
+
+	// Identifier of the controller instance.
+	ID string
+
+	// Lock files of acquired keys.
+	files map[string]*os.File
+
+	// Generation identifiers of acquired keys.
+	generations map[string]uint64
+
+	lock sync.Mutex
+}
+
+// NewFileElector creates a new instance of FileElector type.
+func NewFileElector(dir, id string) (*FileElector, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileElector{
+		Dir:         dir,
+		ID:          id,
+		files:       make(map[string]*os.File),
+		generations: make(map[string]uint64),
+	}, nil
+}
+
+func (e *FileElector) path(key string) string {
+	// Datapath identifiers contain colons.
+	name := strings.Replace(key, ":", "_", -1)
+	return filepath.Join(e.Dir, name+".lock")
+}
+
+// readGeneration reads generation identifier from the lock file.
+func readGeneration(r io.Reader) (uint64, error) {
+	var id string
+	var generation uint64
+
+	_, err := fmt.Fscanln(r, &id, &generation)
+	if err == io.EOF {
+		return 0, nil
+	}
+
+	return generation, err
+}
+
+// Elect implements Elector interface.
+func (e *FileElector) Elect(key string) (uint64, bool, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if _, ok := e.files[key]; ok {
+		return e.generations[key], true, nil
+	}
+
+	file, err := os.OpenFile(e.path(key), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, false, err
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		// Lock is acquired by another instance.
+		file.Close()
+		return 0, false, nil
+	}
+
+	if err != nil {
+		file.Close()
+		return 0, false, err
+	}
+
+	generation, err := readGeneration(file)
+	if err != nil {
+		generation = 0
+	}
+
+	generation++
+
+	// Save leader identifier and a new generation.
+	if err = file.Truncate(0); err == nil {
+		_, err = file.WriteAt([]byte(fmt.Sprintf("%s %d\n", e.ID, generation)), 0)
+	}
+
+	if err != nil {
+		file.Close()
+		return 0, false, err
+	}
+
+	e.files[key] = file
+	e.generations[key] = generation
+
+	return generation, true, nil
+}
+
+// Generation implements Elector interface.
+func (e *FileElector) Generation(key string) (uint64, error) {
+	file, err := os.Open(e.path(key))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer file.Close()
+	return readGeneration(file)
+}
+
+// Resign implements Elector interface.
+func (e *FileElector) Resign(key string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.resign(key)
+}
+
+func (e *FileElector) resign(key string) error {
+	file, ok := e.files[key]
+	if !ok {
+		return ErrNotLeader
+	}
+
+	delete(e.files, key)
+	delete(e.generations, key)
+
+	// Closing of the file releases the lock.
+	return file.Close()
+}
+
+// Close implements Elector interface.
+func (e *FileElector) Close() (err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for key := range e.files {
+		if rerr := e.resign(key); rerr != nil {
+			err = rerr
+		}
+	}
+
+	return
+}
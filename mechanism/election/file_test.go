@@ -0,0 +1,51 @@
+package election
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFileElector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "election")
+	if err != nil {
+		t.Fatal("Failed to create temporary directory:", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	master, _ := NewFileElector(dir, "master")
+	slave, _ := NewFileElector(dir, "slave")
+
+	key := "00:00:00:00:00:00:00:01"
+
+	generation, ok, err := master.Elect(key)
+	if err != nil || !ok || generation != 1 {
+		t.Fatal("Failed to acquire leadership:", generation, ok, err)
+	}
+
+	if _, ok, err = slave.Elect(key); err != nil || ok {
+		t.Fatal("Leadership must be acquired only once:", ok, err)
+	}
+
+	if generation, err = slave.Generation(key); err != nil || generation != 1 {
+		t.Fatal("Invalid generation of leadership:", generation, err)
+	}
+
+	if err = master.Resign(key); err != nil {
+		t.Fatal("Failed to resign leadership:", err)
+	}
+
+	if err = master.Resign(key); err != ErrNotLeader {
+		t.Fatal("Leadership must be resigned only once:", err)
+	}
+
+	generation, ok, err = slave.Elect(key)
+	if err != nil || !ok || generation != 2 {
+		t.Fatal("Failed to acquire released leadership:", generation, ok, err)
+	}
+
+	if err = slave.Close(); err != nil {
+		t.Fatal("Failed to release leaderships:", err)
+	}
+}
@@ -0,0 +1,225 @@
+package election
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	_ "github.com/lib/pq"
+)
+
+// connEscaper escapes values of the connection string parameters.
+var connEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// PGElector implements Elector interface using session-level
+// advisory locks of PostgreSQL. Generation identifiers are
+// taken from the role_generations sequence and stored per key
+// in the roles table. Locks are released by the database
+// server, when connection terminates.
+type PGElector struct {
+	db *sql.DB
+
+	// Dedicated connection, advisory locks belong to a session.
+	conn *sql.Conn
+
+	// Generation identifiers of acquired keys.
+	generations map[string]uint64
+
+	lock sync.Mutex
+}
+
+// NewPGElector creates a new instance of PGElector type, identifier
+// of the controller instance is used as a name of application.
+func NewPGElector(connstr, id string) (*PGElector, error) {
+	connstr = fmt.Sprintf("%s application_name='%s'",
+		connstr, connEscaper.Replace(id))
+
+	db, err := sql.Open("postgres", connstr)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &PGElector{db: db, generations: make(map[string]uint64)}
+	if _, err = e.session(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// session returns dedicated connection, new session is
+// opened, when previous one was lost.
+func (e *PGElector) session() (*sql.Conn, error) {
+	if e.conn != nil {
+		return e.conn, nil
+	}
+
+	conn, err := e.db.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	e.conn = conn
+	return conn, nil
+}
+
+// drop closes dedicated connection, all acquired
+// leaderships are lost along with the session.
+func (e *PGElector) drop() {
+	e.conn.Close()
+	e.conn = nil
+	e.generations = make(map[string]uint64)
+}
+
+// held reports whether advisory lock of the key
+// is still held by the dedicated session.
+func (e *PGElector) held(conn *sql.Conn, key string) (bool, error) {
+	var held bool
+
+	row := conn.QueryRowContext(context.Background(), `SELECT EXISTS (
+		SELECT 1 FROM pg_locks WHERE locktype = 'advisory'
+		AND pid = pg_backend_pid() AND granted AND objsubid = 1
+		AND (classid::bigint << 32 | objid::bigint) = hashtext($1)::bigint)`, key)
+
+	return held, row.Scan(&held)
+}
+
+// Elect implements Elector interface.
+func (e *PGElector) Elect(key string) (uint64, bool, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	conn, err := e.session()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if generation, ok := e.generations[key]; ok {
+		held, err := e.held(conn, key)
+		if err != nil {
+			// Session could be terminated, so leadership
+			// has to be acquired again in a new one.
+			e.drop()
+			return 0, false, err
+		}
+
+		if held {
+			return generation, true, nil
+		}
+
+		// Lock was released outside of the elector,
+		// so the instance is not a leader anymore.
+		delete(e.generations, key)
+		return 0, false, nil
+	}
+
+	var acquired bool
+
+	ctx := context.Background()
+	row := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", key)
+	if err = row.Scan(&acquired); err != nil || !acquired {
+		return 0, false, err
+	}
+
+	var generation uint64
+
+	row = conn.QueryRowContext(ctx, "SELECT nextval('role_generations')")
+	if err = row.Scan(&generation); err != nil {
+		e.unlock(key)
+		return 0, false, err
+	}
+
+	if err = e.store(conn, key, generation); err != nil {
+		e.unlock(key)
+		return 0, false, err
+	}
+
+	e.generations[key] = generation
+	return generation, true, nil
+}
+
+// store persists generation identifier of the key, advisory
+// lock of the key must be held by the dedicated session.
+func (e *PGElector) store(conn *sql.Conn, key string, generation uint64) error {
+	ctx := context.Background()
+
+	result, err := conn.ExecContext(ctx,
+		"UPDATE roles SET generation = $2 WHERE id = $1", key, generation)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil || n != 0 {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx,
+		"INSERT INTO roles (id, generation) VALUES ($1, $2)", key, generation)
+	return err
+}
+
+// Generation implements Elector interface.
+func (e *PGElector) Generation(key string) (uint64, error) {
+	var generation uint64
+
+	row := e.db.QueryRow("SELECT generation FROM roles WHERE id = $1", key)
+	if err := row.Scan(&generation); err != sql.ErrNoRows {
+		return generation, err
+	}
+
+	return 0, nil
+}
+
+func (e *PGElector) unlock(key string) error {
+	if e.conn == nil {
+		return nil
+	}
+
+	_, err := e.conn.ExecContext(context.Background(),
+		"SELECT pg_advisory_unlock(hashtext($1))", key)
+	return err
+}
+
+// Resign implements Elector interface.
+func (e *PGElector) Resign(key string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.resign(key)
+}
+
+func (e *PGElector) resign(key string) error {
+	if _, ok := e.generations[key]; !ok {
+		return ErrNotLeader
+	}
+
+	delete(e.generations, key)
+	return e.unlock(key)
+}
+
+// Close implements Elector interface.
+func (e *PGElector) Close() (err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for key := range e.generations {
+		if rerr := e.resign(key); rerr != nil {
+			err = rerr
+		}
+	}
+
+	if e.conn != nil {
+		if cerr := e.conn.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+
+	if cerr := e.db.Close(); cerr != nil {
+		err = cerr
+	}
+
+	return
+}
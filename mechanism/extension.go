@@ -1,6 +1,8 @@
 package mech
 
 import (
+	"sort"
+
 	"github.com/netrack/netrack/logging"
 )
 
@@ -39,10 +41,19 @@ func (m ExtensionMechanismMap) Set(s string, mechanism Mechanism) {
 	m[s] = mechanism
 }
 
-// Iter calls specified function for all registered mechanisms.
+// Iter calls specified function for all registered mechanisms in
+// order of their names, so tables of the switch are allocated to
+// mechanisms in the same order after each connection.
 func (m ExtensionMechanismMap) Iter(fn func(string, Mechanism) bool) {
-	for s, mechanism := range m {
-		fn(s, mechanism)
+	names := make([]string, 0, len(m))
+	for s := range m {
+		names = append(names, s)
+	}
+
+	sort.Strings(names)
+
+	for _, s := range names {
+		fn(s, m[s])
 	}
 }
 
@@ -64,12 +75,15 @@ func RegisterExtensionMechanism(name string, ctor ExtensionMechanismContructor)
 	extensions[name] = ctor
 }
 
-// ExtensionMechanisms returns map of registered extension mechanisms.
-func ExtensionMechanisms() ExtensionMechanismMap {
+// ExtensionMechanisms returns map of registered extension mechanisms,
+// that support specified OpenFlow protocol version.
+func ExtensionMechanisms(version string) ExtensionMechanismMap {
 	emap := make(ExtensionMechanismMap)
 
 	for name, constructor := range extensions {
-		emap.Set(name, constructor.New())
+		if mechanism := constructor.New(); MechanismSupports(mechanism, version) {
+			emap.Set(name, mechanism)
+		}
 	}
 
 	return emap
@@ -0,0 +1,165 @@
+package mech
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/openflow"
+)
+
+var (
+	// ErrFlowInventory is returned when switch is not able
+	// to provide list of installed flows.
+	ErrFlowInventory = errors.New("FlowInventory: flow inventory is not supported")
+)
+
+// FlowEntry describes a flow installed on the switch.
+type FlowEntry struct {
+	// Flow table number.
+	Table int
+
+	// Flow priority.
+	Priority int
+
+	// Opaque identifier of the flow.
+	Cookie uint64
+
+	// Text representation of matching fields.
+	Match []string
+
+	// Text representation of flow instructions.
+	Instructions []string
+
+	// Number of packets matched by the flow.
+	PacketCount uint64
+
+	// Number of bytes matched by the flow.
+	ByteCount uint64
+
+	// Time flow has been alive.
+	Duration time.Duration
+
+	// Number of seconds idle before expiration.
+	IdleTimeout int
+
+	// Number of seconds before expiration.
+	HardTimeout int
+
+	// Name of the mechanism, that installed the flow.
+	Mechanism string
+}
+
+// FlowInventory is the interface implemented by extension
+// mechanisms, that able to retrieve flows installed on the switch.
+type FlowInventory interface {
+	// Flows returns flows installed into specified table,
+	// flows of all tables are returned for negative table number.
+	Flows(table int) ([]FlowEntry, error)
+}
+
+// FlowInv returns flow inventory of the switch.
+func FlowInv(context *MechanismContext) (FlowInventory, error) {
+	var inventory FlowInventory
+	if err := context.Managers.Obtain(&inventory); err != nil {
+		log.ErrorLog("mechanism/FLOW_INVENTORY",
+			"Failed to obtain flow inventory: ", err)
+		return nil, ErrFlowInventory
+	}
+
+	return inventory, nil
+}
+
+// FlowOwner is the interface implemented by mechanisms,
+// that install flows to the switch.
+type FlowOwner interface {
+	// OwnsFlow reports whether the flow installed into
+	// the table with the cookie belongs to the mechanism.
+	OwnsFlow(table int, cookie uint64) bool
+}
+
+// FlowOwnerName returns name of the activated mechanism, that installed
+// the flow. Mechanisms own flows of the tables they allocated, flows of
+// shared tables are found by cookie assigned by mechanism cookie filter.
+func FlowOwnerName(context *MechanismContext, table int, cookie uint64) (string, bool) {
+	var link LinkMechanismManager
+	var network NetworkMechanismManager
+	var routing RoutingMechanismManager
+
+	managers := []MechanismManager{context.Extension}
+	if err := context.Managers.Obtain(&link); err == nil {
+		managers = append(managers, link)
+	}
+
+	if err := context.Managers.Obtain(&network); err == nil {
+		managers = append(managers, network)
+	}
+
+	if err := context.Managers.Obtain(&routing); err == nil {
+		managers = append(managers, routing)
+	}
+
+	for _, manager := range managers {
+		for _, mechanism := range manager.MechanismList() {
+			owner, ok := mechanism.(FlowOwner)
+			if ok && mechanism.Activated() && owner.OwnsFlow(table, cookie) {
+				return mechanism.Name(), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// CookieFilter is an of.CookieFilter, that keeps cookies it
+// assigned to the flows, until they are released, so the owner
+// of the installed flow could be found by cookie.
+type CookieFilter struct {
+	*of.CookieFilter
+
+	cookies map[uint64]bool
+	lock    sync.RWMutex
+}
+
+// NewCookieFilter creates a new instance of CookieFilter type.
+func NewCookieFilter() *CookieFilter {
+	return &CookieFilter{
+		CookieFilter: of.NewCookieFilter(),
+		cookies:      make(map[uint64]bool),
+	}
+}
+
+// Filter assigns a cookie to the jar and registers
+// the handler for requests with the same cookie.
+func (f *CookieFilter) Filter(j of.CookieJar, h of.Handler) {
+	f.CookieFilter.Filter(j, h)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.cookies[j.Cookies()] = true
+}
+
+// FilterFunc registers the handler function, see Filter.
+func (f *CookieFilter) FilterFunc(j of.CookieJar, fn func(of.ResponseWriter, *of.Request)) {
+	f.Filter(j, of.HandlerFunc(fn))
+}
+
+// Release removes the handler of the cookie.
+func (f *CookieFilter) Release(j of.CookieReader) {
+	f.CookieFilter.Release(j)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	delete(f.cookies, j.Cookies())
+}
+
+// Contains reports whether the cookie was assigned by the filter.
+func (f *CookieFilter) Contains(cookie uint64) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.cookies[cookie]
+}
@@ -0,0 +1,86 @@
+package mech
+
+import (
+	"testing"
+
+	"github.com/netrack/netrack/mechanism/injector"
+)
+
+type cookieJar struct {
+	cookie uint64
+}
+
+func (j *cookieJar) SetCookies(cookie uint64) {
+	j.cookie = cookie
+}
+
+func (j *cookieJar) Cookies() uint64 {
+	return j.cookie
+}
+
+type ownerMechanism struct {
+	BaseMechanism
+	table   int
+	cookies *CookieFilter
+}
+
+func (m *ownerMechanism) Name() string {
+	return "owner"
+}
+
+func (m *ownerMechanism) Description() string {
+	return "Flow owner"
+}
+
+func (m *ownerMechanism) OwnsFlow(table int, cookie uint64) bool {
+	return table == m.table || m.cookies.Contains(cookie)
+}
+
+func TestCookieFilter(t *testing.T) {
+	filter := NewCookieFilter()
+
+	var jar cookieJar
+	filter.Filter(&jar, nil)
+
+	if !filter.Contains(jar.Cookies()) {
+		t.Fatal("Assigned cookie must be known:", jar.Cookies())
+	}
+
+	filter.Release(&jar)
+
+	if filter.Contains(jar.Cookies()) {
+		t.Fatal("Released cookie must not be known:", jar.Cookies())
+	}
+}
+
+func TestFlowOwnerName(t *testing.T) {
+	mechanism := &ownerMechanism{table: 1, cookies: NewCookieFilter()}
+
+	var jar cookieJar
+	mechanism.cookies.Filter(&jar, nil)
+
+	context := &MechanismContext{
+		Extension: &ExtensionMechanismManager{BaseMechanismManager{
+			Mechanisms: ExtensionMechanismMap{"owner": mechanism},
+		}},
+		Managers: injector.New(),
+	}
+
+	if name, ok := FlowOwnerName(context, 1, 0); ok {
+		t.Fatal("Flows of deactivated mechanism must not be owned:", name)
+	}
+
+	mechanism.Activate()
+
+	if name, _ := FlowOwnerName(context, 1, 0); name != "owner" {
+		t.Fatal("Flow must be owned by table owner:", name)
+	}
+
+	if name, _ := FlowOwnerName(context, 0, jar.Cookies()); name != "owner" {
+		t.Fatal("Flow must be owned by cookie owner:", name)
+	}
+
+	if name, ok := FlowOwnerName(context, 2, 0); ok {
+		t.Fatal("Flow of unallocated table must not be owned:", name)
+	}
+}
@@ -0,0 +1,103 @@
+package mech
+
+import (
+	"errors"
+
+	"github.com/netrack/netrack/logging"
+)
+
+// States of forwarding entries.
+const (
+	// Packets are forwarded by the switch to the resolved next hop.
+	FIBForward = "forward"
+
+	// Link layer address of the next hop is being
+	// resolved, packets are sent to the controller.
+	FIBResolving = "resolving"
+
+	// Network is directly connected, hosts are
+	// resolved by the controller on demand.
+	FIBConnected = "connected"
+)
+
+// ErrForwarding is returned when switch does not route packets.
+var ErrForwarding = errors.New("Forwarding: packets are not routed")
+
+// RIBEntry is a candidate route of the routing information base.
+type RIBEntry struct {
+	// Route type (static, connected, rip).
+	Type RouteType
+
+	// Network in a CIDR notation.
+	Network string
+
+	// Next hop address, empty for connected networks.
+	NextHop string
+
+	// Switch port number.
+	Port uint32
+
+	// Administrative distance and metric of the route.
+	Distance int
+	Metric   int
+
+	// Route is the best one to the network.
+	Best bool
+}
+
+// FIBNextHop is a next hop of the forwarding entry.
+type FIBNextHop struct {
+	// Next hop address, empty for connected networks.
+	Addr string
+
+	// Link layer address of the next hop, empty when not resolved.
+	LinkAddr string
+
+	// Egress switch port number.
+	Port uint32
+}
+
+// FIBEntry is an entry of the forwarding information
+// base, compiled from the best routes to the network.
+type FIBEntry struct {
+	// Type of the best routes.
+	Type RouteType
+
+	// Network in a CIDR notation with zeroed host bits.
+	Network string
+
+	// Next hops of the equal-cost best routes.
+	NextHops []FIBNextHop
+
+	// Select group identifier, zero when packets
+	// are not distributed across next hops.
+	Group uint32
+
+	// Priority of installed flows.
+	Priority uint16
+
+	// State of the entry (forward, resolving, connected).
+	State string
+}
+
+// ForwardingReader is the interface implemented by mechanisms,
+// that compile routes into the flows of the switch.
+type ForwardingReader interface {
+	// RIB returns candidate routes ordered by network.
+	RIB() []RIBEntry
+
+	// FIB returns forwarding entries ordered by network.
+	FIB() []FIBEntry
+}
+
+// ForwardingRdr returns forwarding information reader of the switch.
+func ForwardingRdr(context *MechanismContext) (ForwardingReader, error) {
+	var reader ForwardingReader
+	if err := context.Managers.Obtain(&reader); err != nil {
+		log.ErrorLog("mechanism/FORWARDING_READER",
+			"Failed to obtain forwarding information reader: ", err)
+		return nil, ErrForwarding
+	}
+
+	return reader, nil
+}
@@ -0,0 +1,64 @@
+package mech
+
+import (
+	"errors"
+
+	"github.com/netrack/netrack/logging"
+)
+
+// Types of group entries.
+const (
+	// Packets are distributed across buckets of the group.
+	GroupSelect = "select"
+)
+
+// ErrGroups is returned when switch does not install groups.
+var ErrGroups = errors.New("Groups: groups are not installed")
+
+// GroupBucket is a bucket of the group entry.
+type GroupBucket struct {
+	// Relative weight of the bucket.
+	Weight uint16
+
+	// Egress switch port number, bucket is
+	// not used, when the port is down.
+	Port uint32
+
+	// Next hop address and its link layer address.
+	NextHop  string
+	LinkAddr string
+}
+
+// GroupEntry is a group entry installed to the switch.
+type GroupEntry struct {
+	// Group identifier.
+	ID uint32
+
+	// Type of the group (select).
+	Type string
+
+	// Network in a CIDR notation, which packets are sent to the group.
+	Network string
+
+	// Buckets of the group.
+	Buckets []GroupBucket
+}
+
+// GroupReader is the interface implemented by mechanisms,
+// that install group entries to the switch.
+type GroupReader interface {
+	// Groups returns group entries ordered by identifier.
+	Groups() []GroupEntry
+}
+
+// GroupRdr returns group entries reader of the switch.
+func GroupRdr(context *MechanismContext) (GroupReader, error) {
+	var reader GroupReader
+	if err := context.Managers.Obtain(&reader); err != nil {
+		log.ErrorLog("mechanism/GROUP_READER",
+			"Failed to obtain group entries reader: ", err)
+		return nil, ErrGroups
+	}
+
+	return reader, nil
+}
@@ -0,0 +1,199 @@
+package mech
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/netrack/openflow"
+)
+
+var (
+	// ErrHelloFailed is returned when switch and controller
+	// do not share any OpenFlow protocol version.
+	ErrHelloFailed = errors.New(
+		"SwitchManager: no common OpenFlow protocol version")
+)
+
+const (
+	// helloElemVersionBitmap is a type of version bitmap hello element.
+	helloElemVersionBitmap uint16 = 1
+
+	// errorTypeHelloFailed is a type of hello protocol failure error.
+	errorTypeHelloFailed uint16 = 0
+
+	// errorCodeIncompatible is a code of no compatible version error.
+	errorCodeIncompatible uint16 = 0
+)
+
+// protoVersions maps OpenFlow wire versions to protocol version strings.
+var protoVersions = map[uint8]string{
+	1: "OFP/1.0",
+	2: "OFP/1.1",
+	3: "OFP/1.2",
+	4: "OFP/1.3",
+	5: "OFP/1.4",
+	6: "OFP/1.5",
+}
+
+// ProtoVersion returns protocol version string of
+// specified OpenFlow wire version.
+func ProtoVersion(version uint8) string {
+	if proto, ok := protoVersions[version]; ok {
+		return proto
+	}
+
+	return fmt.Sprintf("OFP/0x%02x", version)
+}
+
+// WireVersion returns OpenFlow wire version of specified
+// protocol version string.
+func WireVersion(proto string) (uint8, bool) {
+	for version, p := range protoVersions {
+		if p == proto {
+			return version, true
+		}
+	}
+
+	return 0, false
+}
+
+// Hello describes OpenFlow protocol versions, announced
+// by the switch in ofp_hello message.
+type Hello struct {
+	// Version is the highest protocol version supported by the switch.
+	Version uint8
+
+	// Bitmap of supported wire versions, it is nil when switch
+	// does not send version bitmap hello element.
+	Bitmap []uint32
+}
+
+// NewHello creates a new Hello from received ofp_hello message.
+func NewHello(r *of.Request) (*Hello, error) {
+	h := &Hello{Version: uint8(r.ProtoMinor + 1)}
+
+	if r.ProtoMajor != 1 {
+		h.Version = 0
+	}
+
+	if r.Body == nil {
+		return h, nil
+	}
+
+	_, err := h.ReadFrom(r.Body)
+	return h, err
+}
+
+// ReadFrom implements io.ReaderFrom interface. It reads
+// hello elements, unknown elements are ignored.
+func (h *Hello) ReadFrom(r io.Reader) (int64, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	for buf := b; len(buf) >= 4; {
+		elemType := binary.BigEndian.Uint16(buf[0:2])
+		elemLen := int(binary.BigEndian.Uint16(buf[2:4]))
+
+		if elemLen < 4 || elemLen > len(buf) {
+			return int64(len(b)), errors.New(
+				"Hello: malformed hello element length")
+		}
+
+		if elemType == helloElemVersionBitmap {
+			for i := 4; i+4 <= elemLen; i += 4 {
+				bitmap := binary.BigEndian.Uint32(buf[i : i+4])
+				h.Bitmap = append(h.Bitmap, bitmap)
+			}
+		}
+
+		// Elements are padded to 8 bytes boundary.
+		padded := (elemLen + 7) / 8 * 8
+		if padded > len(buf) {
+			padded = len(buf)
+		}
+
+		buf = buf[padded:]
+	}
+
+	return int64(len(b)), nil
+}
+
+// Supports returns true, when switch announced support
+// of specified wire version.
+func (h *Hello) Supports(version uint8) bool {
+	if h.Bitmap == nil {
+		return version <= h.Version
+	}
+
+	index, bit := int(version/32), version%32
+	if index >= len(h.Bitmap) {
+		return false
+	}
+
+	return h.Bitmap[index]&(1<<bit) != 0
+}
+
+// Negotiate returns the highest protocol version supported by both
+// the switch and specified versions. When switch does not send
+// version bitmap the negotiated version is the smaller of announced
+// and the highest of specified versions.
+func (h *Hello) Negotiate(versions []string) (string, error) {
+	var wire []int
+
+	for _, proto := range versions {
+		if version, ok := WireVersion(proto); ok {
+			wire = append(wire, int(version))
+		}
+	}
+
+	// Try higher versions first.
+	sort.Sort(sort.Reverse(sort.IntSlice(wire)))
+
+	if h.Bitmap == nil && len(wire) != 0 {
+		negotiated := wire[0]
+		if int(h.Version) < negotiated {
+			negotiated = int(h.Version)
+		}
+
+		for _, version := range wire {
+			if version == negotiated {
+				return ProtoVersion(uint8(version)), nil
+			}
+		}
+
+		return "", ErrHelloFailed
+	}
+
+	for _, version := range wire {
+		if h.Supports(uint8(version)) {
+			return ProtoVersion(uint8(version)), nil
+		}
+	}
+
+	return "", ErrHelloFailed
+}
+
+// HelloFailed returns OFPT_ERROR message of OFPET_HELLO_FAILED
+// type with specified reason in a data field.
+func HelloFailed(version uint8, reason string) (*of.Request, error) {
+	var buf bytes.Buffer
+
+	binary.Write(&buf, binary.BigEndian, errorTypeHelloFailed)
+	binary.Write(&buf, binary.BigEndian, errorCodeIncompatible)
+	buf.WriteString(reason)
+
+	r, err := of.NewRequest(of.T_ERROR, &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Header.Set(of.VersionHeaderKey, version)
+	return r, nil
+}
@@ -0,0 +1,89 @@
+package mech
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHelloReadFrom(t *testing.T) {
+	// Version bitmap element with OpenFlow 1.0 and 1.3 versions,
+	// followed by unknown element padded to 8 bytes.
+	elems := []byte{
+		0x00, 0x01, 0x00, 0x08, 0x00, 0x00, 0x00, 0x12,
+		0x00, 0x02, 0x00, 0x05, 0xff, 0x00, 0x00, 0x00,
+	}
+
+	var hello Hello
+	if _, err := hello.ReadFrom(bytes.NewReader(elems)); err != nil {
+		t.Fatal("Failed to read hello elements:", err)
+	}
+
+	if len(hello.Bitmap) != 1 || hello.Bitmap[0] != 0x12 {
+		t.Fatal("Invalid version bitmap:", hello.Bitmap)
+	}
+
+	if !hello.Supports(1) || !hello.Supports(4) || hello.Supports(5) {
+		t.Fatal("Invalid supported versions:", hello.Bitmap)
+	}
+
+	malformed := []byte{0x00, 0x01, 0x00, 0x10, 0x00, 0x00, 0x00, 0x12}
+	if _, err := new(Hello).ReadFrom(bytes.NewReader(malformed)); err == nil {
+		t.Fatal("Malformed hello element must be rejected")
+	}
+}
+
+func TestHelloNegotiate(t *testing.T) {
+	bitmap := func(versions ...uint8) []uint32 {
+		var b uint32
+		for _, version := range versions {
+			b |= 1 << version
+		}
+
+		return []uint32{b}
+	}
+
+	tests := []struct {
+		hello      Hello
+		controller []string
+		version    string
+	}{
+		// Version bitmap is sent by the switch.
+		{Hello{1, bitmap(1)}, []string{"OFP/1.0", "OFP/1.3"}, "OFP/1.0"},
+		{Hello{4, bitmap(1, 4)}, []string{"OFP/1.0", "OFP/1.3"}, "OFP/1.3"},
+		{Hello{6, bitmap(4, 5, 6)}, []string{"OFP/1.0", "OFP/1.3"}, "OFP/1.3"},
+		{Hello{6, bitmap(1, 6)}, []string{"OFP/1.3", "OFP/1.0"}, "OFP/1.0"},
+		{Hello{6, bitmap(5, 6)}, []string{"OFP/1.0", "OFP/1.3"}, ""},
+		{Hello{5, bitmap(5)}, []string{"OFP/1.3"}, ""},
+		{Hello{4, bitmap(1, 4)}, nil, ""},
+
+		// Switch announces only the highest version.
+		{Hello{1, nil}, []string{"OFP/1.0", "OFP/1.3"}, "OFP/1.0"},
+		{Hello{4, nil}, []string{"OFP/1.0", "OFP/1.3"}, "OFP/1.3"},
+		{Hello{5, nil}, []string{"OFP/1.0", "OFP/1.3"}, "OFP/1.3"},
+		{Hello{6, nil}, []string{"OFP/1.0"}, "OFP/1.0"},
+		{Hello{4, nil}, []string{"OFP/1.0"}, "OFP/1.0"},
+		{Hello{1, nil}, []string{"OFP/1.3"}, ""},
+		{Hello{5, nil}, []string{"OFP/1.0", "OFP/1.5"}, ""},
+	}
+
+	for i, test := range tests {
+		version, err := test.hello.Negotiate(test.controller)
+
+		if test.version == "" {
+			if err != ErrHelloFailed {
+				t.Errorf("%d: Negotiation must fail, got %s", i, version)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%d: Failed to negotiate version: %s", i, err)
+			continue
+		}
+
+		if version != test.version {
+			t.Errorf("%d: Negotiated %s instead of %s", i, version, test.version)
+		}
+	}
+}
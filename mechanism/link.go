@@ -3,6 +3,7 @@ package mech
 import (
 	"errors"
 	"io"
+	"sort"
 	"sync"
 
 	"github.com/netrack/netrack/database"
@@ -30,6 +31,11 @@ var (
 	// accessing not intialized link driver.
 	ErrLinkNotInitialized = errors.New(
 		"LinkManager: link driver not intialized")
+
+	// ErrLinkVLAN is returned on invalid
+	// VLAN configuration of the port.
+	ErrLinkVLAN = errors.New(
+		"LinkManager: invalid VLAN configuration")
 )
 
 const (
@@ -44,6 +50,20 @@ const (
 // LinkMode is a link communication mode.
 type LinkMode string
 
+const (
+	// Access port carries untagged frames of a single VLAN.
+	LinkPortAccess LinkPortMode = "access"
+
+	// Trunk port carries frames of multiple VLANs tagged with 802.1Q header.
+	LinkPortTrunk LinkPortMode = "trunk"
+)
+
+// LinkPortMode is a VLAN mode of the switch port.
+type LinkPortMode string
+
+// VLANMax is the highest valid VLAN identifier.
+const VLANMax = 4094
+
 // LinkAddr represents a L2 address.
 type LinkAddr interface {
 	// String returns string form of address.
@@ -61,6 +81,32 @@ type LinkPort struct {
 
 	// Switch port number.
 	Port uint32 `json:"port"`
+
+	// VLAN mode of the port, empty for ports without VLANs.
+	Mode LinkPortMode `json:"mode,omitempty"`
+
+	// VLAN identifier of the access port.
+	VLAN uint16 `json:"vlan,omitempty"`
+}
+
+// CheckVLAN returns error when VLAN configuration of the port is
+// invalid. Access port requires VLAN identifier, while trunk port
+// carries all VLANs and does not accept any.
+func (p *LinkPort) CheckVLAN() error {
+	switch p.Mode {
+	case "", LinkPortTrunk:
+		if p.VLAN != 0 {
+			return ErrLinkVLAN
+		}
+	case LinkPortAccess:
+		if p.VLAN == 0 || p.VLAN > VLANMax {
+			return ErrLinkVLAN
+		}
+	default:
+		return ErrLinkVLAN
+	}
+
+	return nil
 }
 
 type LinkManagerContext struct {
@@ -117,6 +163,12 @@ type LinkContext struct {
 	// Switch port number.
 	Port uint32
 
+	// VLAN mode of the port.
+	PortMode LinkPortMode
+
+	// VLAN identifier of the access port.
+	VLAN uint16
+
 	// Link layer driver
 	Driver LinkDriver
 
@@ -140,6 +192,10 @@ type LinkFrame struct {
 	// case of ethernet Proto returns IANA ethernet types.
 	Proto Proto
 
+	// VLAN is a VLAN identifier of the tagged frame,
+	// zero is used for untagged frames.
+	VLAN uint16
+
 	// Len is a header length.
 	Len int64
 }
@@ -243,6 +299,41 @@ type LinkMechanism interface {
 	DeleteLinkPostCommit() error
 }
 
+// BaseLinkMechanism implements LinkMechanism interface.
+type BaseLinkMechanism struct {
+	BaseMechanism
+}
+
+// CreateLinkPreCommit implements LinkMechanism interface.
+func (m *BaseLinkMechanism) CreateLinkPreCommit(*LinkContext) error {
+	return nil
+}
+
+// CreateLinkPostCommit implements LinkMechanism interface.
+func (m *BaseLinkMechanism) CreateLinkPostCommit() error {
+	return nil
+}
+
+// UpdateLinkPreCommit implements LinkMechanism interface.
+func (m *BaseLinkMechanism) UpdateLinkPreCommit(*LinkContext) error {
+	return nil
+}
+
+// UpdateLinkPostCommit implements LinkMechanism interface.
+func (m *BaseLinkMechanism) UpdateLinkPostCommit(*LinkContext) error {
+	return nil
+}
+
+// DeleteLinkPreCommit implements LinkMechanism interface.
+func (m *BaseLinkMechanism) DeleteLinkPreCommit(*LinkContext) error {
+	return nil
+}
+
+// DeleteLinkPostCommit implements LinkMechanism interface.
+func (m *BaseLinkMechanism) DeleteLinkPostCommit() error {
+	return nil
+}
+
 // LinkMechanismConstructor is a genereic
 // constructor for data link type mechanisms.
 type LinkMechanismConstructor interface {
@@ -320,12 +411,15 @@ func LinkDrivers() map[string]LinkDriver {
 	return nmap
 }
 
-// LinkMechanisms retruns instances of registered mechanisms.
-func LinkMechanisms() LinkMechanismMap {
+// LinkMechanisms retruns instances of registered mechanisms,
+// that support specified OpenFlow protocol version.
+func LinkMechanisms(version string) LinkMechanismMap {
 	lmap := make(LinkMechanismMap)
 
 	for name, constructor := range links {
-		lmap.Set(name, constructor.New())
+		if mechanism := constructor.New(); MechanismSupports(mechanism, version) {
+			lmap.Set(name, mechanism)
+		}
 	}
 
 	return lmap
@@ -352,10 +446,19 @@ func (m LinkMechanismMap) Set(s string, mechanism Mechanism) {
 	m[s] = lmechanism
 }
 
-// Iter calls specified function for all registered mechanisms.
+// Iter calls specified function for all registered mechanisms in
+// order of their names, so tables of the switch are allocated to
+// mechanisms in the same order after each connection.
 func (m LinkMechanismMap) Iter(fn func(string, Mechanism) bool) {
-	for s, mechanism := range m {
-		fn(s, mechanism)
+	names := make([]string, 0, len(m))
+	for s := range m {
+		names = append(names, s)
+	}
+
+	sort.Strings(names)
+
+	for _, s := range names {
+		fn(s, m[s])
 	}
 }
 
@@ -423,7 +526,7 @@ func NewLinkMechanismManager() *linkMechanismManager {
 func (m *linkMechanismManager) Enable(c *MechanismContext) {
 	m.BaseMechanismManager = BaseMechanismManager{
 		Datapath:   c.Switch.ID(),
-		Mechanisms: LinkMechanisms(),
+		Mechanisms: LinkMechanisms(c.Switch.Version()),
 		activated:  0,
 		enabled:    0,
 	}
@@ -552,9 +655,11 @@ func (m *linkMechanismManager) linkContext(port LinkPort) (*LinkContext, error)
 	}
 
 	linkContext := &LinkContext{
-		Addr:   lladdr,
-		Port:   port.Port,
-		Driver: lldriver,
+		Addr:     lladdr,
+		Port:     port.Port,
+		PortMode: port.Mode,
+		VLAN:     port.VLAN,
+		Driver:   lldriver,
 	}
 
 	return linkContext, nil
@@ -755,12 +860,17 @@ func (m *linkMechanismManager) DeleteLink(context *LinkManagerContext) error {
 		// Remove association from the driver
 		defer lldriver.DeleteAddr(port.Port)
 
+		// Persisted VLAN configuration of the port.
+		prev := link.Port(port.Port)
+
 		// Forward event to activated mechanisms
 		err = m.do(func(llmech LinkMechanism) error {
 			return llmech.DeleteLinkPreCommit(&LinkContext{
-				Addr:   addr,
-				Port:   port.Port,
-				Driver: lldriver,
+				Addr:     addr,
+				Port:     port.Port,
+				PortMode: prev.Mode,
+				VLAN:     prev.VLAN,
+				Driver:   lldriver,
 			})
 		})
 
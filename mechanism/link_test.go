@@ -1,6 +1,7 @@
 package mech
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -9,3 +10,23 @@ func TestLinkUpdate(t *testing.T) {
 
 func TestLinkDelete(t *testing.T) {
 }
+
+func TestLinkMechanismMapIter(t *testing.T) {
+	lmap := make(LinkMechanismMap)
+	for _, name := range []string{"lldp", "bridge", "ethernet", "arp"} {
+		lmap[name] = nil
+	}
+
+	// Tables are allocated in the order of iteration.
+	for i := 0; i < 10; i++ {
+		var names []string
+		lmap.Iter(func(name string, _ Mechanism) bool {
+			names = append(names, name)
+			return true
+		})
+
+		if fmt.Sprint(names) != "[arp bridge ethernet lldp]" {
+			t.Fatal("Mechanisms must be iterated in order of names:", names)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package mech
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/netrack/netrack/logging"
+)
+
+// DefaultAgingTime is a default time after which
+// not confirmed hardware address is removed.
+const DefaultAgingTime = 300 * time.Second
+
+// ErrMACTable is returned when switch does not learn hardware addresses.
+var ErrMACTable = errors.New("MACTable: hardware addresses are not learned")
+
+// MACEntry is a hardware address learned on the switch port.
+type MACEntry struct {
+	// Hardware address of the host.
+	Addr net.HardwareAddr
+
+	// VLAN identifier of the host, zero for hosts
+	// connected to ports without VLANs.
+	VLAN uint16
+
+	// Switch port number, the host is connected to.
+	Port uint32
+
+	// Time of the last frame received from the host.
+	LastSeen time.Time
+}
+
+// MACTableReader is the interface implemented by
+// mechanisms, that learn hardware addresses of hosts.
+type MACTableReader interface {
+	// MACTable returns learned hardware addresses.
+	MACTable() []MACEntry
+}
+
+// MACTbl returns hardware addresses reader of the switch.
+func MACTbl(context *MechanismContext) (MACTableReader, error) {
+	var reader MACTableReader
+	if err := context.Managers.Obtain(&reader); err != nil {
+		log.ErrorLog("mechanism/MAC_TABLE",
+			"Failed to obtain hardware addresses reader: ", err)
+		return nil, ErrMACTable
+	}
+
+	return reader, nil
+}
+
+// MACTable is a table of hardware addresses with aging.
+type MACTable struct {
+	// Time after which not confirmed address is removed.
+	AgingTime time.Duration
+
+	entries map[string]*MACEntry
+	lock    sync.RWMutex
+}
+
+// NewMACTable creates a new instance of MACTable type.
+func NewMACTable() *MACTable {
+	return &MACTable{
+		AgingTime: DefaultAgingTime,
+		entries:   make(map[string]*MACEntry),
+	}
+}
+
+func macKey(addr net.HardwareAddr, vlan uint16) string {
+	return fmt.Sprintf("%d/%s", vlan, addr)
+}
+
+// Learn adds hardware address of the VLAN to the table or confirms existing
+// one, true is returned when address is new or moved to another port.
+func (t *MACTable) Learn(addr net.HardwareAddr, vlan uint16, port uint32, now time.Time) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := macKey(addr, vlan)
+
+	entry, ok := t.entries[key]
+	if ok && entry.Port == port {
+		entry.LastSeen = now
+		return false
+	}
+
+	hwaddr := make(net.HardwareAddr, len(addr))
+	copy(hwaddr, addr)
+
+	t.entries[key] = &MACEntry{hwaddr, vlan, port, now}
+	return true
+}
+
+// Lookup returns not expired entry of the hardware address of the VLAN.
+func (t *MACTable) Lookup(addr net.HardwareAddr, vlan uint16, now time.Time) (MACEntry, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	entry, ok := t.entries[macKey(addr, vlan)]
+	if !ok || now.Sub(entry.LastSeen) > t.AgingTime {
+		return MACEntry{}, false
+	}
+
+	return *entry, true
+}
+
+// deleteEntries removes entries matching the function from the table.
+func (t *MACTable) deleteEntries(fn func(*MACEntry) bool) []MACEntry {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var removed []MACEntry
+
+	for key, entry := range t.entries {
+		if fn(entry) {
+			removed = append(removed, *entry)
+			delete(t.entries, key)
+		}
+	}
+
+	sort.Sort(macEntries(removed))
+	return removed
+}
+
+// Expire removes addresses, that were not confirmed in time.
+func (t *MACTable) Expire(now time.Time) []MACEntry {
+	return t.deleteEntries(func(entry *MACEntry) bool {
+		return now.Sub(entry.LastSeen) > t.AgingTime
+	})
+}
+
+// DeletePort removes addresses learned on the switch port.
+func (t *MACTable) DeletePort(port uint32) []MACEntry {
+	return t.deleteEntries(func(entry *MACEntry) bool {
+		return entry.Port == port
+	})
+}
+
+// Entries returns list of entries sorted by port, VLAN and address.
+func (t *MACTable) Entries() []MACEntry {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	entries := make([]MACEntry, 0, len(t.entries))
+	for _, entry := range t.entries {
+		entries = append(entries, *entry)
+	}
+
+	sort.Sort(macEntries(entries))
+	return entries
+}
+
+type macEntries []MACEntry
+
+func (e macEntries) Len() int {
+	return len(e)
+}
+
+func (e macEntries) Less(i, j int) bool {
+	if e[i].Port != e[j].Port {
+		return e[i].Port < e[j].Port
+	}
+
+	if e[i].VLAN != e[j].VLAN {
+		return e[i].VLAN < e[j].VLAN
+	}
+
+	return e[i].Addr.String() < e[j].Addr.String()
+}
+
+func (e macEntries) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+}
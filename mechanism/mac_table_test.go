@@ -0,0 +1,64 @@
+package mech
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMACTable(t *testing.T) {
+	table := NewMACTable()
+	table.AgingTime = time.Minute
+
+	host1 := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	host2 := net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+
+	now := time.Now()
+
+	if !table.Learn(host1, 0, 2, now.Add(-2*time.Minute)) {
+		t.Fatal("New address must be reported as learned")
+	}
+
+	if !table.Learn(host2, 10, 1, now) {
+		t.Fatal("New address must be reported as learned")
+	}
+
+	if table.Learn(host2, 10, 1, now) {
+		t.Fatal("Confirmed address must not be reported as learned")
+	}
+
+	if _, ok := table.Lookup(host1, 0, now); ok {
+		t.Fatal("Aged address must not be found")
+	}
+
+	entry, ok := table.Lookup(host2, 10, now)
+	if !ok || entry.Port != 1 {
+		t.Fatal("Failed to lookup learned address:", entry)
+	}
+
+	if _, ok = table.Lookup(host2, 20, now); ok {
+		t.Fatal("Address must not be found in another VLAN")
+	}
+
+	entries := table.Entries()
+	if len(entries) != 2 || entries[0].Port != 1 || entries[1].Port != 2 {
+		t.Fatal("Invalid list of entries:", entries)
+	}
+
+	removed := table.Expire(now)
+	if len(removed) != 1 || removed[0].Addr.String() != host1.String() {
+		t.Fatal("Aged address must be removed:", removed)
+	}
+
+	if !table.Learn(host2, 10, 3, now) {
+		t.Fatal("Moved address must be reported as learned")
+	}
+
+	if removed = table.DeletePort(3); len(removed) != 1 {
+		t.Fatal("Addresses of deleted port must be removed:", removed)
+	}
+
+	if entries = table.Entries(); len(entries) != 0 {
+		t.Fatal("Table must be empty:", entries)
+	}
+}
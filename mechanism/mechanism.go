@@ -39,6 +39,9 @@ type MechanismContext struct {
 
 	// Container for available managers
 	Managers injector.Injector
+
+	// Subscriptions to the port changes.
+	Ports *PortNotifier
 }
 
 // Mechanism describes switch drivers
@@ -65,6 +68,30 @@ type Mechanism interface {
 	// Disable removes installed flows from the switch
 	// and performs all other necessary clean-up operations.
 	Disable()
+
+	// Deactivate called after switch disconnection, it
+	// releases acquired resources without sending any
+	// messages to the switch.
+	Deactivate()
+}
+
+// VersionedMechanism is the interface implemented by mechanisms,
+// that can operate only on switches of a particular OpenFlow version.
+type VersionedMechanism interface {
+	// Version returns supported OpenFlow protocol version.
+	Version() string
+}
+
+// MechanismSupports returns true, when specified mechanism can
+// operate on switch of specified OpenFlow protocol version. Mechanisms,
+// that do not implement VersionedMechanism are version-agnostic.
+func MechanismSupports(m Mechanism, version string) bool {
+	vmechanism, ok := m.(VersionedMechanism)
+	if !ok {
+		return true
+	}
+
+	return vmechanism.Version() == version
 }
 
 // BaseMechanism implements thread-safe methods of
@@ -104,6 +131,12 @@ func (m *BaseMechanism) Disable() {
 	atomic.StoreInt64(&m.enabled, 0)
 }
 
+// Deactivate implements Mechanism interface.
+func (m *BaseMechanism) Deactivate() {
+	atomic.StoreInt64(&m.activated, 0)
+	atomic.StoreInt64(&m.enabled, 0)
+}
+
 // MechanismMap describes map for mechanism type.
 type MechanismMap interface {
 	// Get returns Mechanism by registered name.
@@ -142,6 +175,10 @@ type MechanismManager interface {
 
 	// DisableByName releases resources of specified mechanism.
 	DisableByName(string) error
+
+	// Deactivate releases resources of registered
+	// mechanisms after switch disconnection.
+	Deactivate()
 }
 
 // BaseMechanismManager implements MechanismManager interface.
@@ -332,6 +369,17 @@ func (m *BaseMechanismManager) Disable() {
 	})
 }
 
+// Deactivate deactivates all registered mechanisms
+func (m *BaseMechanismManager) Deactivate() {
+	atomic.StoreInt64(&m.activated, 0)
+	atomic.StoreInt64(&m.enabled, 0)
+
+	m.Mechanisms.Iter(func(_ string, mechanism Mechanism) bool {
+		mechanism.Deactivate()
+		return true
+	})
+}
+
 // DisableByName disables mechanism driver by specified name,
 // error will be returned, when mechanism was not registered
 // or specified mechanism already disabled.
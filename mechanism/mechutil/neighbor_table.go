@@ -25,7 +25,9 @@ func NewNeighTable() *NeighTable {
 	return &NeighTable{neighs: neighs}
 }
 
-func (t *NeighTable) Populate(entry NeighEntry) {
+// Populate adds neighbor to the table, true is returned, when
+// neighbor is new or its link layer address changed.
+func (t *NeighTable) Populate(entry NeighEntry) bool {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
@@ -36,16 +38,33 @@ func (t *NeighTable) Populate(entry NeighEntry) {
 	// If network address is the first one
 	if !ok {
 		t.neighs[nladdr] = entry
-		return
+		return true
 	}
 
 	// Entry already in the table
 	if bytes.Equal(neighEntry.LinkAddr.Bytes(), entry.LinkAddr.Bytes()) {
-		return
+		return false
 	}
 
 	// Add a new entry in a table
 	t.neighs[nladdr] = entry
+	return true
+}
+
+// DeletePort removes neighbors learned on the port.
+func (t *NeighTable) DeletePort(port uint32) []NeighEntry {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var removed []NeighEntry
+	for nladdr, entry := range t.neighs {
+		if entry.Port == port {
+			removed = append(removed, entry)
+			delete(t.neighs, nladdr)
+		}
+	}
+
+	return removed
 }
 
 func (t *NeighTable) List() []NeighEntry {
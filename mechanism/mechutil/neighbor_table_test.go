@@ -30,25 +30,33 @@ func (nladdr NetworkAddr) Bytes() []byte {
 	return []byte(nladdr)
 }
 
-func (nladdr NetworkAddr) Mask() []byte {
+func (nladdr NetworkAddr) Mask() mech.NetworkMask {
 	return nil
 }
 
 func TestNeighTable(t *testing.T) {
 	table := NewNeighTable()
 
-	table.Populate(NeighEntry{
+	changed := table.Populate(NeighEntry{
 		NetworkAddr: NetworkAddr("1.1.1.1"),
 		LinkAddr:    LinkAddr("2-2-2-2"),
 		Port:        42,
 	})
 
-	table.Populate(NeighEntry{
+	if !changed {
+		t.Fatal("New neighbor must be reported as changed")
+	}
+
+	changed = table.Populate(NeighEntry{
 		NetworkAddr: NetworkAddr("1.1.1.1"),
 		LinkAddr:    LinkAddr("2-2-2-2"),
 		Port:        43,
 	})
 
+	if changed {
+		t.Fatal("Known neighbor must not be reported as changed")
+	}
+
 	neigh, ok := table.Lookup(NetworkAddr("1.1.1.1"))
 	if !ok {
 		t.Fatal("Failed to return neighbor entry")
@@ -57,4 +65,13 @@ func TestNeighTable(t *testing.T) {
 	if neigh.Port != 42 {
 		t.Fatal("Failed to return right neighbor instance:", neigh.Port)
 	}
+
+	removed := table.DeletePort(42)
+	if len(removed) != 1 || removed[0].Port != 42 {
+		t.Fatal("Neighbors of the port must be removed:", removed)
+	}
+
+	if _, ok := table.Lookup(NetworkAddr("1.1.1.1")); ok {
+		t.Fatal("Removed neighbor must not be found")
+	}
 }
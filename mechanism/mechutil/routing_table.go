@@ -3,6 +3,7 @@ package mechutil
 import (
 	"bytes"
 	"errors"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,6 +14,7 @@ var distanceMap = map[mech.RouteType]int{
 	mech.StaticRoute:    0,
 	mech.LocalRoute:     0,
 	mech.ConnectedRoute: 1,
+	mech.BGPRoute:       20,
 	mech.EIGRPRoute:     90,
 	mech.OSPFRoute:      110,
 	mech.RIPRoute:       120,
@@ -37,7 +39,22 @@ type RouteEntry struct {
 	Port      uint32
 }
 
+// addrBytes returns raw representation of the optional address.
+func addrBytes(addr mech.NetworkAddr) []byte {
+	if addr == nil {
+		return nil
+	}
+
+	return addr.Bytes()
+}
+
+// Equal reports whether entries are the same candidate route: they
+// have the same type, destination network, next hop and port.
 func (e *RouteEntry) Equal(entry *RouteEntry) bool {
+	if e.Type != entry.Type || e.Port != entry.Port {
+		return false
+	}
+
 	if !bytes.Equal(e.Network.Bytes(), entry.Network.Bytes()) {
 		return false
 	}
@@ -46,22 +63,160 @@ func (e *RouteEntry) Equal(entry *RouteEntry) bool {
 		return false
 	}
 
-	if e.Port != entry.Port {
-		return false
+	return bytes.Equal(addrBytes(e.NextHop), addrBytes(entry.NextHop))
+}
+
+// less reports whether entry is preferred over the other
+// candidate to the same network.
+func (e *RouteEntry) less(entry *RouteEntry) bool {
+	if e.Distance != entry.Distance {
+		return e.Distance < entry.Distance
 	}
 
-	return true
+	return e.Metric < entry.Metric
 }
 
-type RoutingTable struct {
+// routeNode is a node of the path-compressed binary trie, it is
+// either a prefix with candidate routes or a branching point.
+type routeNode struct {
+	// Network prefix with zeroed host bits.
+	key    []byte
+	length int
+
+	children [2]*routeNode
+
+	// Candidate routes ordered by distance and metric.
 	routes []RouteEntry
-	lock   sync.RWMutex
+}
+
+// bit returns value of the bit of the key at the position.
+func bit(key []byte, pos int) int {
+	return int(key[pos/8]>>uint(7-pos%8)) & 1
+}
+
+// commonLen returns length of the common prefix of
+// the keys, but not more than the specified number of bits.
+func commonLen(a, b []byte, max int) int {
+	n := 0
+	for i := 0; n < max; i++ {
+		if x := a[i] ^ b[i]; x != 0 {
+			for x&0x80 == 0 {
+				x <<= 1
+				n++
+			}
+
+			break
+		}
+
+		n += 8
+	}
+
+	if n > max {
+		return max
+	}
+
+	return n
+}
+
+// mask returns copy of the key with bits after the length zeroed.
+func mask(key []byte, length int) []byte {
+	masked := append([]byte(nil), key...)
+	for i := range masked {
+		switch {
+		case length >= (i+1)*8:
+		case length <= i*8:
+			masked[i] = 0
+		default:
+			masked[i] &= 0xff << uint(8-length%8)
+		}
+	}
+
+	return masked
+}
+
+// prefix returns key and prefix length of the network address.
+func prefix(network mech.NetworkAddr) ([]byte, int) {
+	length := network.Mask().Len()
+	return mask(network.Bytes(), length), length
+}
+
+// RoutingTable stores candidate routes in a trie keyed by network
+// prefix, lookup takes time proportional to the address length.
+// Routes of IPv4 and IPv6 networks are stored in separate tries.
+type RoutingTable struct {
+	// Roots of tries indexed by address length.
+	roots map[int]*routeNode
+	lock  sync.RWMutex
 }
 
 func NewRoutingTable() *RoutingTable {
-	return &RoutingTable{}
+	return &RoutingTable{roots: make(map[int]*routeNode)}
+}
+
+// root returns root of the trie of addresses of the length.
+func (t *RoutingTable) root(addrLen int) *routeNode {
+	if t.roots == nil {
+		t.roots = make(map[int]*routeNode)
+	}
+
+	root, ok := t.roots[addrLen]
+	if !ok {
+		root = &routeNode{key: make([]byte, addrLen)}
+		t.roots[addrLen] = root
+	}
+
+	return root
 }
 
+// insert returns node of the prefix, node is created when missing.
+func (n *routeNode) insert(key []byte, length int) *routeNode {
+	for n.length != length {
+		b := bit(key, n.length)
+		child := n.children[b]
+
+		if child == nil {
+			child = &routeNode{key: key, length: length}
+			n.children[b] = child
+			return child
+		}
+
+		common := commonLen(child.key, key, minInt(child.length, length))
+		if common == child.length {
+			n = child
+			continue
+		}
+
+		node := &routeNode{key: key, length: length}
+
+		// Node of the prefix becomes a parent of the child.
+		if common == length {
+			node.children[bit(child.key, length)] = child
+			n.children[b] = node
+			return node
+		}
+
+		glue := &routeNode{key: mask(key, common), length: common}
+		glue.children[bit(child.key, common)] = child
+		glue.children[bit(key, common)] = node
+
+		n.children[b] = glue
+		return node
+	}
+
+	return n
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// Populate adds candidate route to the routing table, the same
+// candidate is replaced. Default distance of the route type is
+// used, when distance of the entry is not specified.
 func (t *RoutingTable) Populate(entry RouteEntry) error {
 	t.lock.Lock()
 	defer t.lock.Unlock()
@@ -72,59 +227,198 @@ func (t *RoutingTable) Populate(entry RouteEntry) error {
 	}
 
 	entry.Timestamp = time.Now()
-	entry.Distance = distance
+	if entry.Distance == 0 {
+		entry.Distance = distance
+	}
 
-	t.routes = append(t.routes, entry)
+	key, length := prefix(entry.Network)
+	node := t.root(len(key)).insert(key, length)
 
+	routes := node.routes[:0]
+	for _, e := range node.routes {
+		if !e.Equal(&entry) {
+			routes = append(routes, e)
+		}
+	}
+
+	// Insert after candidates with the same preference.
+	i := sort.Search(len(routes), func(i int) bool {
+		return entry.less(&routes[i])
+	})
+
+	routes = append(routes, RouteEntry{})
+	copy(routes[i+1:], routes[i:])
+	routes[i] = entry
+
+	node.routes = routes
 	return nil
 }
 
+// Evict removes candidate route from the routing table.
 func (t *RoutingTable) Evict(entry RouteEntry) bool {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	for i, e := range t.routes {
+	key, length := prefix(entry.Network)
+	root, ok := t.roots[len(key)]
+	if !ok {
+		return false
+	}
+
+	// Remember the path to remove nodes without routes.
+	path := []*routeNode{root}
+
+	node := root
+	for node.length != length {
+		node = node.children[bit(key, node.length)]
+		if node == nil || node.length > length ||
+			commonLen(node.key, key, node.length) != node.length {
+			return false
+		}
+
+		path = append(path, node)
+	}
+
+	for i, e := range node.routes {
 		if !e.Equal(&entry) {
 			continue
 		}
 
-		t.routes = append(t.routes[i:], t.routes[i+1:]...)
+		node.routes = append(node.routes[:i], node.routes[i+1:]...)
+
+		for j := len(path) - 1; j > 0; j-- {
+			parent := path[j-1]
+			parent.children[bit(path[j].key, parent.length)] = path[j].compact()
+		}
+
 		return true
 	}
 
 	return false
 }
 
+// compact returns node replacing the one without routes:
+// nil for the leaf and the child for a single-child node.
+func (n *routeNode) compact() *routeNode {
+	if len(n.routes) != 0 {
+		return n
+	}
+
+	switch {
+	case n.children[0] == nil:
+		return n.children[1]
+	case n.children[1] == nil:
+		return n.children[0]
+	}
+
+	return n
+}
+
+// walk calls function for all nodes of the trie in prefix order.
+func (n *routeNode) walk(fn func(*routeNode)) {
+	if n == nil {
+		return
+	}
+
+	fn(n)
+	n.children[0].walk(fn)
+	n.children[1].walk(fn)
+}
+
+// Routes returns copy of the routing table entries ordered by
+// address family and network prefix, candidates to the same
+// network are ordered by preference.
+func (t *RoutingTable) Routes() []RouteEntry {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var lengths []int
+	for addrLen := range t.roots {
+		lengths = append(lengths, addrLen)
+	}
+
+	sort.Ints(lengths)
+
+	routes := make([]RouteEntry, 0)
+	for _, addrLen := range lengths {
+		t.roots[addrLen].walk(func(n *routeNode) {
+			routes = append(routes, n.routes...)
+		})
+	}
+
+	return routes
+}
+
+// Candidates returns copy of candidate routes to the network
+// ordered by preference, the first one is the best route.
+func (t *RoutingTable) Candidates(network mech.NetworkAddr) []RouteEntry {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	key, length := prefix(network)
+
+	node, ok := t.roots[len(key)]
+	for ok && node != nil && node.length <= length &&
+		commonLen(node.key, key, node.length) == node.length {
+
+		if node.length == length {
+			return append([]RouteEntry(nil), node.routes...)
+		}
+
+		node = node.children[bit(key, node.length)]
+	}
+
+	return nil
+}
+
+// Best returns the most preferred candidate routes to the network,
+// routes with the same distance and metric are all returned, so
+// packets could be distributed across multiple next hops.
+func (t *RoutingTable) Best(network mech.NetworkAddr) []RouteEntry {
+	candidates := t.Candidates(network)
+
+	for i := 1; i < len(candidates); i++ {
+		if candidates[0].less(&candidates[i]) {
+			return candidates[:i]
+		}
+	}
+
+	return candidates
+}
+
+// Lookup returns route to the address with the longest matching
+// prefix, the most preferred candidate is chosen among routes to
+// the same network. Routes of IPv4 and IPv6 networks never match
+// addresses of the other family.
 func (t *RoutingTable) Lookup(nladdr mech.NetworkAddr) (RouteEntry, bool) {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
 
-	var candidate *RouteEntry
+	addr := nladdr.Bytes()
 
-	for _, entry := range t.routes {
-		if entry.Network.Contains(nladdr) {
-			e := entry
+	node, ok := t.roots[len(addr)]
+	if !ok {
+		return RouteEntry{}, false
+	}
 
-			if candidate == nil {
-				candidate = &e
-				continue
-			}
+	var candidate *routeNode
 
-			if candidate.Network.Mask().Len() < entry.Network.Mask().Len() {
-				candidate = &e
-				continue
-			}
+	maxLen := len(addr) * 8
+	for node != nil && commonLen(node.key, addr, node.length) == node.length {
+		if len(node.routes) != 0 {
+			candidate = node
+		}
 
-			if candidate.Distance > entry.Distance {
-				candidate = &e
-				continue
-			}
+		if node.length == maxLen {
+			break
 		}
+
+		node = node.children[bit(addr, node.length)]
 	}
 
 	if candidate == nil {
 		return RouteEntry{}, false
 	}
 
-	return *candidate, true
+	return candidate.routes[0], true
 }
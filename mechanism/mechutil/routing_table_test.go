@@ -1,16 +1,264 @@
 package mechutil
 
 import (
+	"fmt"
+	"math/rand"
+	"net"
 	"testing"
+
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/netutil/drivers"
 )
 
 func TestRoutingTable(t *testing.T) {
+	ipv4 := drivers.NewIPv4Driver()
+	network, _ := ipv4.ParseAddr("10.0.0.0/8")
+	nexthop, _ := ipv4.ParseAddr("192.168.0.1")
+
 	var table RoutingTable
 
-	table.Populate(RouteEntry{})
-	table.Populate(RouteEntry{})
+	if err := table.Populate(RouteEntry{Network: network}); err == nil {
+		t.Fatal("Route of unknown type must not be populated")
+	}
+
+	table.Populate(RouteEntry{Type: mech.RIPRoute, Network: network, NextHop: nexthop, Port: 1})
+	table.Populate(RouteEntry{Type: mech.StaticRoute, Network: network, NextHop: nexthop, Port: 2})
+	table.Populate(RouteEntry{Type: mech.RIPRoute, Network: network, NextHop: nexthop, Port: 1})
+
+	routes := table.Routes()
+	if len(routes) != 2 {
+		t.Fatal("Failed to append a routes:", routes)
+	}
+
+	if routes[0].Type != mech.StaticRoute || routes[1].Type != mech.RIPRoute {
+		t.Fatal("Candidates must be ordered by distance:", routes)
+	}
+}
+
+func TestRoutingTableLookup(t *testing.T) {
+	ipv4 := drivers.NewIPv4Driver()
+	ipv6 := drivers.NewIPv6Driver()
+
+	parse := func(d mech.NetworkDriver, s string) mech.NetworkAddr {
+		addr, err := d.ParseAddr(s)
+		if err != nil {
+			t.Fatal("Failed to parse address:", err)
+		}
+
+		return addr
+	}
+
+	table := NewRoutingTable()
+	table.Populate(RouteEntry{Type: mech.StaticRoute, Network: parse(ipv6, "::/0"), Port: 1})
+	table.Populate(RouteEntry{Type: mech.ConnectedRoute, Network: parse(ipv6, "2001:db8::/32"), Port: 2})
+	table.Populate(RouteEntry{Type: mech.ConnectedRoute, Network: parse(ipv6, "2001:db8:1::/64"), Port: 3})
+	table.Populate(RouteEntry{Type: mech.StaticRoute, Network: parse(ipv4, "0.0.0.0/0"), Port: 4})
+
+	tests := []struct {
+		addr mech.NetworkAddr
+		port uint32
+	}{
+		{parse(ipv6, "2001:db8:1::10"), 3},
+		{parse(ipv6, "2001:db8:2::10"), 2},
+		{parse(ipv6, "2001:db9::10"), 1},
+		{parse(ipv4, "10.0.0.1"), 4},
+	}
+
+	for _, test := range tests {
+		route, ok := table.Lookup(test.addr)
+		if !ok || route.Port != test.port {
+			t.Fatal("Invalid route to:", test.addr, route.Port)
+		}
+	}
+}
+
+func TestRoutingTableCandidates(t *testing.T) {
+	ipv4 := drivers.NewIPv4Driver()
+
+	parse := func(s string) mech.NetworkAddr {
+		addr, err := ipv4.ParseAddr(s)
+		if err != nil {
+			t.Fatal("Failed to parse address:", err)
+		}
+
+		return addr
+	}
+
+	table := NewRoutingTable()
+	routes := []RouteEntry{
+		{Type: mech.StaticRoute, Network: parse("0.0.0.0/0"), Port: 1},
+		{Type: mech.ConnectedRoute, Network: parse("10.0.0.1/8"), Port: 2},
+		{Type: mech.RIPRoute, Network: parse("10.1.0.0/16"), NextHop: parse("10.0.0.2"), Port: 2, Metric: 3},
+		{Type: mech.RIPRoute, Network: parse("10.1.0.0/16"), NextHop: parse("10.0.0.3"), Port: 2, Metric: 2},
+		{Type: mech.OSPFRoute, Network: parse("10.1.0.0/16"), NextHop: parse("10.0.0.4"), Port: 3},
+		{Type: mech.RIPRoute, Network: parse("10.1.128.0/17"), NextHop: parse("10.0.0.2"), Port: 4},
+		{Type: mech.StaticRoute, Network: parse("10.2.0.0/16"), NextHop: parse("10.0.0.5"), Port: 5},
+	}
+
+	for _, route := range routes {
+		if err := table.Populate(route); err != nil {
+			t.Fatal("Failed to populate route:", err)
+		}
+	}
+
+	tests := []struct {
+		addr string
+		port uint32
+	}{
+		// Longer prefix wins regardless of the distance.
+		{"10.1.200.1", 4},
+		// The most preferred candidate of the prefix.
+		{"10.1.0.1", 3},
+		{"10.2.0.1", 5},
+		{"10.3.0.1", 2},
+		{"11.0.0.1", 1},
+	}
+
+	lookup := func(step string) {
+		for _, test := range tests {
+			route, ok := table.Lookup(parse(test.addr))
+			if !ok || route.Port != test.port {
+				t.Fatal(step, "invalid route to:", test.addr, route.Port)
+			}
+		}
+	}
+
+	lookup("Populated:")
+
+	candidates := table.Candidates(parse("10.1.0.0/16"))
+	if len(candidates) != 3 || candidates[0].Type != mech.OSPFRoute {
+		t.Fatal("Invalid candidates of the prefix:", candidates)
+	}
+
+	if candidates := table.Candidates(parse("10.0.0.0/8")); len(candidates) != 1 {
+		t.Fatal("Candidates must be found by the masked prefix:", candidates)
+	}
+
+	if candidates := table.Candidates(parse("10.1.0.0/24")); candidates != nil {
+		t.Fatal("Candidates of the missing prefix must not be found:", candidates)
+	}
+
+	// Eviction of the most preferred candidates reveals the others.
+	if !table.Evict(routes[4]) || !table.Evict(routes[5]) {
+		t.Fatal("Failed to evict routes")
+	}
+
+	if table.Evict(routes[5]) {
+		t.Fatal("Evicted route must not be found")
+	}
+
+	tests[0].port, tests[1].port = 2, 2
+	lookup("Evicted:")
+
+	route, _ := table.Lookup(parse("10.1.0.1"))
+	if !route.NextHop.Contains(parse("10.0.0.3")) {
+		t.Fatal("Candidate with lower metric must be preferred:", route.NextHop)
+	}
+
+	// Candidates are identified by next hop and type.
+	if table.Evict(RouteEntry{Type: mech.StaticRoute, Network: parse("10.1.0.0/16"), NextHop: parse("10.0.0.3"), Port: 2}) {
+		t.Fatal("Candidate of other type must not be evicted")
+	}
+
+	for _, i := range []int{0, 1, 2, 3, 6} {
+		if !table.Evict(routes[i]) {
+			t.Fatal("Failed to evict route:", routes[i].Network)
+		}
+	}
+
+	if routes := table.Routes(); len(routes) != 0 {
+		t.Fatal("Routing table must be empty:", routes)
+	}
+
+	root := table.roots[net.IPv4len]
+	if root.children[0] != nil || root.children[1] != nil {
+		t.Fatal("Nodes without routes must be removed")
+	}
+}
+
+func TestRoutingTableBest(t *testing.T) {
+	ipv4 := drivers.NewIPv4Driver()
+	network, _ := ipv4.ParseAddr("10.0.0.0/8")
+
+	table := NewRoutingTable()
+	for i, metric := range []int{2, 1, 1} {
+		nexthop, _ := ipv4.ParseAddr(fmt.Sprintf("192.168.0.%d", i+1))
+		table.Populate(RouteEntry{Type: mech.OSPFRoute, Network: network, NextHop: nexthop, Port: 1, Metric: metric})
+	}
+
+	// Routes with the same distance and metric are equal-cost paths.
+	best := table.Best(network)
+	if len(best) != 2 || best[0].Metric != 1 || best[1].Metric != 1 {
+		t.Fatal("Equal-cost routes must be the best ones:", best)
+	}
+
+	static, _ := ipv4.ParseAddr("192.168.0.4")
+	table.Populate(RouteEntry{Type: mech.StaticRoute, Network: network, NextHop: static, Port: 2})
+
+	if best := table.Best(network); len(best) != 1 || best[0].Type != mech.StaticRoute {
+		t.Fatal("Route with lower distance must be the only best one:", best)
+	}
+
+	// Floating static route is preferred less than the dynamic ones.
+	table.Populate(RouteEntry{Type: mech.StaticRoute, Network: network, NextHop: static, Port: 2, Distance: 200})
+
+	if best := table.Best(network); len(best) != 2 || best[0].Type != mech.OSPFRoute {
+		t.Fatal("Distance of the route must override the default one:", best)
+	}
+}
+
+// benchmarkTable returns routing table with the number of random
+// IPv4 prefixes of lengths from /16 to /32 and addresses within them.
+func benchmarkTable(n int) (*RoutingTable, []mech.NetworkAddr) {
+	ipv4 := drivers.NewIPv4Driver()
+	random := rand.New(rand.NewSource(1))
+
+	table := NewRoutingTable()
+	addrs := make([]mech.NetworkAddr, 0, n)
+
+	for len(addrs) < n {
+		ip := make(net.IP, net.IPv4len)
+		random.Read(ip)
+
+		mask := net.CIDRMask(16+random.Intn(17), 32)
+		network := ipv4.CreateAddr(ip.Mask(mask), mask)
+
+		table.Populate(RouteEntry{Type: mech.StaticRoute, Network: network, Port: 1})
+		addrs = append(addrs, ipv4.CreateAddr(ip, nil))
+	}
+
+	return table, addrs
+}
+
+func BenchmarkRoutingTableLookup(b *testing.B) {
+	table, addrs := benchmarkTable(100000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, ok := table.Lookup(addrs[i%len(addrs)]); !ok {
+			b.Fatal("Route is not found")
+		}
+	}
+}
+
+func BenchmarkRoutingTablePopulate(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchmarkTable(100000)
+	}
+}
+
+func BenchmarkRoutingTableEvict(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		table, _ := benchmarkTable(100000)
+		b.StartTimer()
+
+		for _, route := range table.Routes() {
+			table.Evict(route)
+		}
 
-	if len(table.routes) != 2 {
-		t.Fatalf("Failed to append a routes")
+		if len(table.Routes()) != 0 {
+			b.Fatal("Routes are not evicted")
+		}
 	}
 }
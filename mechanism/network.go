@@ -1,8 +1,11 @@
 package mech
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"io"
+	"sort"
 	"sync"
 
 	"github.com/netrack/netrack/database"
@@ -63,14 +66,48 @@ type NetworkAddr interface {
 	Mask() NetworkMask
 }
 
+// NetworkAddress is a network layer address
+// assigned to the switch port.
+type NetworkAddress struct {
+	// Network layer address string.
+	Addr string `json:"address"`
+
+	// Network driver name.
+	Driver string `json:"driver"`
+}
+
 // NetworkPort represents network layer
 // port abstraction.
 type NetworkPort struct {
-	// Network layer address string.
-	Addr string `json:"address"`
+	// List of network layer addresses of the port,
+	// addresses of different drivers could be mixed.
+	Addrs []NetworkAddress `json:"addresses"`
 
 	// Switch port number.
 	Port uint32 `json:"port"`
+
+	// VLAN identifier of the routed sub-interface
+	// of the trunk port, zero for untagged interface.
+	VLAN uint16 `json:"vlan,omitempty"`
+
+	// DHCP configuration of the interface, nil
+	// when addresses are not leased to hosts.
+	DHCP *DHCPConfig `json:"dhcp,omitempty"`
+
+	// OSPF configuration of the interface, nil
+	// when OSPF does not run on the interface.
+	OSPF *OSPFConfig `json:"ospf,omitempty"`
+}
+
+// HasAddr reports whether address is assigned to the port.
+func (p NetworkPort) HasAddr(s string) bool {
+	for _, addr := range p.Addrs {
+		if addr.Addr == s {
+			return true
+		}
+	}
+
+	return false
 }
 
 type NetworkManagerContext struct {
@@ -78,13 +115,48 @@ type NetworkManagerContext struct {
 	// only for database storing.
 	Datapath string `json:"id"`
 
-	// Network driver name.
-	Driver string `json:"driver"`
-
 	// List of ports to modify.
 	Ports []NetworkPort `json:"ports"`
 }
 
+// UnmarshalJSON implements json.Unmarshaler interface. Networks
+// persisted before multiple addresses per port were supported
+// keep a single port address and a network driver shared by all
+// ports, these are converted to the first address of the port.
+func (c *NetworkManagerContext) UnmarshalJSON(b []byte) error {
+	type context NetworkManagerContext
+
+	type legacyPort struct {
+		NetworkPort
+		Addr string `json:"address"`
+	}
+
+	var legacy struct {
+		context
+		Driver string       `json:"driver"`
+		Ports  []legacyPort `json:"ports"`
+	}
+
+	if err := json.Unmarshal(b, &legacy); err != nil {
+		return err
+	}
+
+	*c = NetworkManagerContext(legacy.context)
+	c.Ports = nil
+
+	for _, port := range legacy.Ports {
+		p := port.NetworkPort
+
+		if len(p.Addrs) == 0 && port.Addr != "" {
+			p.Addrs = []NetworkAddress{{port.Addr, legacy.Driver}}
+		}
+
+		c.Ports = append(c.Ports, p)
+	}
+
+	return nil
+}
+
 // Port searchs for a specified port number.
 func (c *NetworkManagerContext) Port(p uint32) NetworkPort {
 	for _, port := range c.Ports {
@@ -108,13 +180,29 @@ func (c *NetworkManagerContext) SetPort(p NetworkPort) {
 	c.Ports = append(c.Ports, p)
 }
 
-// DelPort remove specified port from port list.
+// DelPort removes specified addresses of the port, the port is
+// removed from the list, when addresses are not specified or
+// there are no addresses left.
 func (c *NetworkManagerContext) DelPort(p NetworkPort) {
 	for i, port := range c.Ports {
-		if port.Port == p.Port {
-			c.Ports = append(c.Ports[:i], c.Ports[i+1:]...)
+		if port.Port != p.Port {
+			continue
+		}
+
+		var addrs []NetworkAddress
+		for _, addr := range port.Addrs {
+			if len(p.Addrs) != 0 && !p.HasAddr(addr.Addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+
+		if len(addrs) != 0 {
+			c.Ports[i].Addrs = addrs
 			return
 		}
+
+		c.Ports = append(c.Ports[:i], c.Ports[i+1:]...)
+		return
 	}
 }
 
@@ -135,6 +223,27 @@ type NetworkContext struct {
 
 	// Switch port number.
 	Port uint32
+
+	// VLAN identifier of the interface, zero for untagged interface.
+	VLAN uint16
+
+	// DHCP configuration of the interface.
+	DHCP *DHCPConfig
+
+	// OSPF configuration of the interface.
+	OSPF *OSPFConfig
+}
+
+// Shared reports whether other addresses of the same network
+// driver are assigned to the port of the context.
+func (c *NetworkContext) Shared() bool {
+	for _, nladdr := range c.NetworkDriver.Addrs(c.Port) {
+		if !bytes.Equal(nladdr.Bytes(), c.NetworkAddr.Bytes()) {
+			return true
+		}
+	}
+
+	return false
 }
 
 // NetworkPacket describes OSI L3 PDU.
@@ -203,14 +312,18 @@ type NetworkDriver interface {
 	// CreateAddr returns a new NetworkAddr
 	CreateAddr([]byte, []byte) NetworkAddr
 
-	// Addr returns network layer address of specified switch port.
+	// Addr returns primary network layer address of specified switch port.
 	Addr(uint32) (NetworkAddr, error)
 
-	// UpdateAddr updates switch port network layer address.
+	// Addrs returns all network layer addresses of specified switch port.
+	Addrs(uint32) []NetworkAddr
+
+	// UpdateAddr assigns network layer address to switch port,
+	// address with the same host part is replaced.
 	UpdateAddr(uint32, NetworkAddr) error
 
-	// DeleteAddr delets address associated with port.
-	DeleteAddr(uint32) error
+	// DeleteAddr deletes address associated with port.
+	DeleteAddr(uint32, NetworkAddr) error
 
 	// Reads network layer packets.
 	NetworkPacketReader
@@ -232,6 +345,19 @@ func (d *BaseNetworkDriver) Addr(LinkAddr) (NetworkAddr, error) {
 	return nil, errors.New("BaseNetworkDriver: not implemented")
 }
 
+// SourceAddr returns network layer address of the port, which network
+// includes the destination address. Primary address of the port is
+// returned, when none of the port networks includes destination.
+func SourceAddr(nldriver NetworkDriver, port uint32, dst NetworkAddr) (NetworkAddr, error) {
+	for _, nladdr := range nldriver.Addrs(port) {
+		if nladdr.Contains(dst) {
+			return nladdr, nil
+		}
+	}
+
+	return nldriver.Addr(port)
+}
+
 // NetworkMechanism is the interface implemented by an object
 // that handles OSI network layer resources.
 type NetworkMechanism interface {
@@ -340,12 +466,15 @@ func (fn NetworkMechanismConstructorFunc) New() NetworkMechanism {
 	return fn()
 }
 
-// NetworkMechanisms returns map of registered network layer mechanisms
-func NetworkMechanisms() NetworkMechanismMap {
+// NetworkMechanisms returns map of registered network layer mechanisms,
+// that support specified OpenFlow protocol version.
+func NetworkMechanisms(version string) NetworkMechanismMap {
 	nmap := make(NetworkMechanismMap)
 
 	for name, constructor := range networks {
-		nmap.Set(name, constructor.New())
+		if mechanism := constructor.New(); MechanismSupports(mechanism, version) {
+			nmap.Set(name, mechanism)
+		}
 	}
 
 	return nmap
@@ -401,10 +530,19 @@ func (m NetworkMechanismMap) Set(s string, mechanism Mechanism) {
 	m[s] = nmechanism
 }
 
-// Iter calls specified function for all registered mechcanisms.
+// Iter calls specified function for all registered mechanisms in
+// order of their names, so tables of the switch are allocated to
+// mechanisms in the same order after each connection.
 func (m NetworkMechanismMap) Iter(fn func(string, Mechanism) bool) {
-	for s, mechanism := range m {
-		fn(s, mechanism)
+	names := make([]string, 0, len(m))
+	for s := range m {
+		names = append(names, s)
+	}
+
+	sort.Strings(names)
+
+	for _, s := range names {
+		fn(s, m[s])
 	}
 }
 
@@ -413,8 +551,11 @@ type NetworkMechanismManager interface {
 	// Base mechanism manager interface.
 	MechanismManager
 
-	// NetworkDriver returns active network layer driver instance.
-	Driver() (NetworkDriver, error)
+	// Driver returns activated network layer driver instance.
+	Driver(string) (NetworkDriver, error)
+
+	// Drivers returns list of activated network layer drivers.
+	Drivers() []NetworkDriver
 
 	// Context returns network context.
 	Context() (*NetworkManagerContext, error)
@@ -426,7 +567,8 @@ type NetworkMechanismManager interface {
 	DeleteNetwork(*NetworkManagerContext) error
 }
 
-func NetworkDrv(context *MechanismContext) (NetworkDriver, error) {
+// NetworkDrv returns activated network layer driver with specified name.
+func NetworkDrv(context *MechanismContext, name string) (NetworkDriver, error) {
 	var network NetworkMechanismManager
 	if err := context.Managers.Obtain(&network); err != nil {
 		log.ErrorLog("mechanism/NETWORK_DRIVER",
@@ -434,7 +576,7 @@ func NetworkDrv(context *MechanismContext) (NetworkDriver, error) {
 		return nil, err
 	}
 
-	nldriver, err := network.Driver()
+	nldriver, err := network.Driver(name)
 	if err != nil {
 		log.ErrorLog("mechanism/NETWORK_DRIVER",
 			"Network layer driver is not initialized: ", err)
@@ -452,8 +594,8 @@ type networkMechanismManager struct {
 	// List of available network drivers.
 	drivers map[string]NetworkDriver
 
-	// Activated network layer driver.
-	drv     NetworkDriver
+	// Names of activated network layer drivers.
+	active  map[string]bool
 	drvLock sync.RWMutex
 
 	// Link layer driver
@@ -467,6 +609,7 @@ type networkMechanismManager struct {
 func NewNetworkMechanismManager() *networkMechanismManager {
 	return &networkMechanismManager{
 		drivers: NetworkDrivers(),
+		active:  make(map[string]bool),
 	}
 }
 
@@ -481,7 +624,7 @@ func (m *networkMechanismManager) Description() string {
 func (m *networkMechanismManager) Enable(c *MechanismContext) {
 	m.BaseMechanismManager = BaseMechanismManager{
 		Datapath:   c.Switch.ID(),
-		Mechanisms: NetworkMechanisms(),
+		Mechanisms: NetworkMechanisms(c.Switch.Version()),
 		activated:  0,
 		enabled:    0,
 	}
@@ -550,14 +693,20 @@ func (m *networkMechanismManager) UpdateLinkPreCommit(context *LinkContext) erro
 	// If network layer address is not associated with
 	// specified port, there is nothing to do then.
 
-	networkContext, err := m.readNetworkContext(context.Port)
+	networkContexts, err := m.readNetworkContexts(context.Port)
 	if err != nil {
 		return nil
 	}
 
-	err = m.do(func(nlmech NetworkMechanism) error {
-		return nlmech.UpdateNetworkPreCommit(networkContext)
-	})
+	for _, networkContext := range networkContexts {
+		err = m.do(func(nlmech NetworkMechanism) error {
+			return nlmech.UpdateNetworkPreCommit(networkContext)
+		})
+
+		if err != nil {
+			break
+		}
+	}
 
 	if err != nil {
 		log.ErrorLog("network/UPDATE_LINK_PRECOMMIT",
@@ -574,14 +723,20 @@ func (m *networkMechanismManager) UpdateLinkPostCommit(context *LinkContext) err
 	// Persist link layer driver
 	m.SetLinkDriver(context.Driver)
 
-	networkContext, err := m.readNetworkContext(context.Port)
+	networkContexts, err := m.readNetworkContexts(context.Port)
 	if err != nil {
 		return nil
 	}
 
-	err = m.do(func(nlmech NetworkMechanism) error {
-		return nlmech.UpdateNetworkPostCommit(networkContext)
-	})
+	for _, networkContext := range networkContexts {
+		err = m.do(func(nlmech NetworkMechanism) error {
+			return nlmech.UpdateNetworkPostCommit(networkContext)
+		})
+
+		if err != nil {
+			break
+		}
+	}
 
 	if err != nil {
 		log.ErrorLog("network/UPDATE_LINK_POSTCOMMIT",
@@ -598,17 +753,15 @@ func (m *networkMechanismManager) DeleteLinkPreCommit(context *LinkContext) erro
 		"Got request to delete link")
 
 	// Probably network layer just not initialized.
-	networkContext, err := m.readNetworkContext(context.Port)
-	if err != nil {
+	networkContexts, err := m.readNetworkContexts(context.Port)
+	if err != nil || len(networkContexts) == 0 {
 		return nil
 	}
 
-	// Delete network layer configuration
+	// Delete all network layer addresses of the port.
 	err = m.DeleteNetwork(&NetworkManagerContext{
-		Datapath: m.Datapath, Ports: []NetworkPort{{
-			Addr: networkContext.NetworkAddr.String(),
-			Port: networkContext.Port,
-		}},
+		Datapath: m.Datapath,
+		Ports:    []NetworkPort{{Port: context.Port}},
 	})
 
 	if err != nil {
@@ -678,39 +831,113 @@ func (m *networkMechanismManager) do(fn func(NetworkMechanism) error) (err error
 	return
 }
 
-func (m *networkMechanismManager) SetDriver(name string) error {
+// activateDriver activates network layer driver with specified name.
+func (m *networkMechanismManager) activateDriver(name string) (NetworkDriver, error) {
 	m.drvLock.Lock()
 	defer m.drvLock.Unlock()
 
-	// If driver already activated.
-	if m.drv != nil && m.drv.Name() == name {
-		return nil
-	}
-
 	// Search for a new driver.
 	drv, ok := m.drivers[name]
 	if !ok {
 		log.ErrorLog("network/NETWORK_DRIVER",
 			"Requested network driver not found: ", name)
-		return ErrNetworkNotRegistered
+		return nil, ErrNetworkNotRegistered
 	}
 
-	m.drv = drv
-	return nil
+	m.active[name] = true
+	return drv, nil
 }
 
-// Driver returns active network layer driver.
-func (m *networkMechanismManager) Driver() (NetworkDriver, error) {
+// Driver returns activated network layer driver.
+func (m *networkMechanismManager) Driver(name string) (NetworkDriver, error) {
 	m.drvLock.RLock()
 	defer m.drvLock.RUnlock()
 
-	if m.drv == nil {
+	drv, ok := m.drivers[name]
+	if !ok {
+		log.ErrorLog("network/NETWORK_DRIVER",
+			"Requested network driver not found: ", name)
+		return nil, ErrNetworkNotRegistered
+	}
+
+	if !m.active[name] {
 		log.ErrorLog("network/NETWORK_DRIVER",
-			"Network layer driver is not itialized")
+			"Network layer driver is not itialized: ", name)
 		return nil, ErrNetworkNotInitialized
 	}
 
-	return m.drv, nil
+	return drv, nil
+}
+
+// Drivers returns activated network layer drivers ordered by name.
+func (m *networkMechanismManager) Drivers() []NetworkDriver {
+	m.drvLock.RLock()
+	defer m.drvLock.RUnlock()
+
+	var names []string
+	for name := range m.active {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var drivers []NetworkDriver
+	for _, name := range names {
+		drivers = append(drivers, m.drivers[name])
+	}
+
+	return drivers
+}
+
+// parseAddr returns driver and parsed network layer address. When driver
+// of the address is not specified, the first driver (ordered by name), that
+// accepts the address is used, and address is updated with its name.
+func (m *networkMechanismManager) parseAddr(addr *NetworkAddress) (NetworkDriver, NetworkAddr, error) {
+	if addr.Driver == "" {
+		m.drvLock.RLock()
+
+		var names []string
+		for name := range m.drivers {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			if _, err := m.drivers[name].ParseAddr(addr.Addr); err == nil {
+				addr.Driver = name
+				break
+			}
+		}
+
+		m.drvLock.RUnlock()
+	}
+
+	nldriver, err := m.activateDriver(addr.Driver)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nladdr, err := nldriver.ParseAddr(addr.Addr)
+	if err != nil {
+		log.ErrorLog("network/PARSE_ADDR",
+			"Failed to parse network layer address: ", err)
+		return nil, nil, err
+	}
+
+	return nldriver, nladdr, nil
+}
+
+// portAddr returns network layer address assigned to the port, that
+// has the same host part, as specified one.
+func portAddr(nldriver NetworkDriver, port uint32, addr NetworkAddr) (NetworkAddr, bool) {
+	for _, nladdr := range nldriver.Addrs(port) {
+		if bytes.Equal(nladdr.Bytes(), addr.Bytes()) {
+			return nladdr, true
+		}
+	}
+
+	return nil, false
 }
 
 // Context returns network context of specified switch port.
@@ -726,51 +953,51 @@ func (m *networkMechanismManager) Context() (*NetworkManagerContext, error) {
 	return context, nil
 }
 
-func (m *networkMechanismManager) readNetworkContext(port uint32) (*NetworkContext, error) {
+func (m *networkMechanismManager) readNetworkContexts(port uint32) ([]*NetworkContext, error) {
 	lldriver, err := m.LinkDriver()
 	if err != nil {
 		return nil, err
 	}
 
-	nldriver, err := m.Driver()
-	if err != nil {
-		return nil, err
-	}
-
 	lladdr, err := lldriver.Addr(port)
 	if err != nil {
 		return nil, err
 	}
 
-	// If network layer address is not associated with
-	// specified port, there is nothing to do then.
-	nladdr, err := nldriver.Addr(port)
+	// VLAN of the interface is a part of persisted configuration.
+	network, err := m.Context()
 	if err != nil {
 		return nil, err
 	}
 
-	context := &NetworkContext{
-		NetworkAddr:   nladdr,
-		NetworkDriver: nldriver,
-		LinkAddr:      lladdr,
-		LinkDriver:    lldriver,
-		Port:          port,
+	var contexts []*NetworkContext
+
+	// If network layer addresses are not associated with
+	// specified port, there is nothing to do then.
+	for _, nldriver := range m.Drivers() {
+		for _, nladdr := range nldriver.Addrs(port) {
+			contexts = append(contexts, &NetworkContext{
+				NetworkAddr:   nladdr,
+				NetworkDriver: nldriver,
+				LinkAddr:      lladdr,
+				LinkDriver:    lldriver,
+				Port:          port,
+				VLAN:          network.Port(port).VLAN,
+				DHCP:          network.Port(port).DHCP,
+				OSPF:          network.Port(port).OSPF,
+			})
+		}
 	}
 
-	return context, nil
+	return contexts, nil
 }
 
-func (m *networkMechanismManager) updateNetworkContext(port NetworkPort) (*NetworkContext, error) {
+func (m *networkMechanismManager) updateNetworkContext(port NetworkPort, addr *NetworkAddress) (*NetworkContext, error) {
 	lldriver, err := m.LinkDriver()
 	if err != nil {
 		return nil, err
 	}
 
-	nldriver, err := m.Driver()
-	if err != nil {
-		return nil, err
-	}
-
 	lladdr, err := lldriver.Addr(port.Port)
 	if err != nil {
 		log.ErrorLog("network/NETWORK_CONTEXT",
@@ -778,10 +1005,8 @@ func (m *networkMechanismManager) updateNetworkContext(port NetworkPort) (*Netwo
 		return nil, err
 	}
 
-	nladdr, err := nldriver.ParseAddr(port.Addr)
+	nldriver, nladdr, err := m.parseAddr(addr)
 	if err != nil {
-		log.ErrorLog("network/NETWORK_CONTEXT",
-			"Failed to parse network layer address: ", err)
 		return nil, err
 	}
 
@@ -797,6 +1022,9 @@ func (m *networkMechanismManager) updateNetworkContext(port NetworkPort) (*Netwo
 		LinkAddr:      lladdr,
 		LinkDriver:    lldriver,
 		Port:          port.Port,
+		VLAN:          port.VLAN,
+		DHCP:          port.DHCP,
+		OSPF:          port.OSPF,
 	}
 
 	return context, nil
@@ -812,8 +1040,8 @@ func (m *networkMechanismManager) CreateNetwork() error {
 		)
 	}
 
-	alter := func(port NetworkPort) error {
-		networkContext, err := m.updateNetworkContext(port)
+	alter := func(port NetworkPort, addr *NetworkAddress) error {
+		networkContext, err := m.updateNetworkContext(port, addr)
 		if err != nil {
 			return err
 		}
@@ -835,27 +1063,24 @@ func (m *networkMechanismManager) CreateNetwork() error {
 	defer m.lock.RUnlock()
 
 	return create(func() error {
-		// If driver equals to empty string, that mechanism was not
+		// If there are no configured ports, that mechanism was not
 		// previously activated, so there is nothing to configure
-		if network.Driver == "" {
+		if len(network.Ports) == 0 {
 			return nil
 		}
 
 		// Restore state of the persited switch
-		err := m.SetDriver(network.Driver)
-		if err != nil {
-			return err
-		}
-
 		for _, port := range network.Ports {
-			if err := alter(port); err != nil {
-				log.ErrorLog("network/CREATE_NETWORK",
-					"Network create pre-commit failed: ", err)
-				return err
+			for i := range port.Addrs {
+				if err := alter(port, &port.Addrs[i]); err != nil {
+					log.ErrorLog("network/CREATE_NETWORK",
+						"Network create pre-commit failed: ", err)
+					return err
+				}
 			}
 		}
 
-		err = m.do(func(nlmech NetworkMechanism) error {
+		err := m.do(func(nlmech NetworkMechanism) error {
 			return nlmech.CreateNetworkPostCommit()
 		})
 
@@ -868,7 +1093,8 @@ func (m *networkMechanismManager) CreateNetwork() error {
 	})
 }
 
-// UpdateNetwork calls corresponding method for activated mechanisms.
+// UpdateNetwork calls corresponding method for activated mechanisms,
+// addresses of the specified ports replace previously assigned ones.
 func (m *networkMechanismManager) UpdateNetwork(context *NetworkManagerContext) (err error) {
 	network := new(NetworkManagerContext)
 
@@ -879,39 +1105,52 @@ func (m *networkMechanismManager) UpdateNetwork(context *NetworkManagerContext)
 	}
 
 	pre := func(port NetworkPort) error {
-		networkContext, err := m.updateNetworkContext(port)
-		if err != nil {
-			return err
-		}
+		for i := range port.Addrs {
+			networkContext, err := m.updateNetworkContext(port, &port.Addrs[i])
+			if err != nil {
+				return err
+			}
 
-		err = m.do(func(nlmech NetworkMechanism) error {
-			return nlmech.UpdateNetworkPreCommit(networkContext)
-		})
+			err = m.do(func(nlmech NetworkMechanism) error {
+				return nlmech.UpdateNetworkPreCommit(networkContext)
+			})
 
-		if err != nil {
-			log.ErrorLog("network/UPDATE_NETWORK_PRECOMMIT",
-				"Failed to parse ")
+			if err != nil {
+				log.ErrorLog("network/UPDATE_NETWORK_PRECOMMIT",
+					"Failed to release network configuration: ", err)
+				return err
+			}
+
+			// Address is assigned again on post-commit,
+			// when it is still in a list of port addresses.
+			nldriver := networkContext.NetworkDriver
+			if err = nldriver.DeleteAddr(port.Port, networkContext.NetworkAddr); err != nil {
+				return err
+			}
 		}
 
-		return err
+		return nil
 	}
 
 	post := func(port NetworkPort) error {
-		networkContext, err := m.updateNetworkContext(port)
-		if err != nil {
-			return err
-		}
+		for i := range port.Addrs {
+			networkContext, err := m.updateNetworkContext(port, &port.Addrs[i])
+			if err != nil {
+				return err
+			}
 
-		err = m.do(func(nlmech NetworkMechanism) error {
-			return nlmech.UpdateNetworkPostCommit(networkContext)
-		})
+			err = m.do(func(nlmech NetworkMechanism) error {
+				return nlmech.UpdateNetworkPostCommit(networkContext)
+			})
 
-		if err != nil {
-			log.ErrorLog("network/UPDATE_NETWORK_POSTCOMMIT",
-				"Failed to update network configuration: ", err)
+			if err != nil {
+				log.ErrorLog("network/UPDATE_NETWORK_POSTCOMMIT",
+					"Failed to update network configuration: ", err)
+				return err
+			}
 		}
 
-		return err
+		return nil
 	}
 
 	return update(func() error {
@@ -920,40 +1159,15 @@ func (m *networkMechanismManager) UpdateNetwork(context *NetworkManagerContext)
 			return err
 		}
 
-		_, err = m.Driver()
-		if err != nil && len(network.Ports) != 0 {
-			log.ErrorLog("network/UPDATE_NETWORK",
-				"Driver not found, but ports need to be updated")
-			return ErrNetworkNotInitialized
-		}
-
 		for _, port := range context.Ports {
-			// Get previous port coniguration
-			port = network.Port(port.Port)
-			blank := NetworkPort{}
-
-			// Skip blank ports
-			if port == blank {
-				continue
-			}
-
-			// Precommit changes
-			if err := pre(port); err != nil {
+			// Precommit changes of previous port coniguration.
+			if err := pre(network.Port(port.Port)); err != nil {
 				log.ErrorLog("network/UPDATE_NETWORK",
 					"Network update pre-commit failed: ", err)
 				return err
 			}
 		}
 
-		// Set new driver
-		err = m.SetDriver(context.Driver)
-		if err != nil {
-			return err
-		}
-
-		// Update driver configuration
-		network.Driver = context.Driver
-
 		for _, port := range context.Ports {
 			if err := post(port); err != nil {
 				log.ErrorLog("network/UPDATE_NETWORK",
@@ -961,7 +1175,8 @@ func (m *networkMechanismManager) UpdateNetwork(context *NetworkManagerContext)
 				return err
 			}
 
-			// Update port configuration
+			// Update port configuration, drivers of
+			// addresses are resolved on post-commit.
 			network.SetPort(port)
 		}
 
@@ -969,7 +1184,8 @@ func (m *networkMechanismManager) UpdateNetwork(context *NetworkManagerContext)
 	})
 }
 
-// DeleteNetwork calls corresponding method for activated mechanisms.
+// DeleteNetwork calls corresponding method for activated mechanisms. All
+// addresses of the port are deleted, when addresses are not specified.
 func (m *networkMechanismManager) DeleteNetwork(context *NetworkManagerContext) (err error) {
 	// Create new instance, that whould be readed from
 	// the database
@@ -980,11 +1196,6 @@ func (m *networkMechanismManager) DeleteNetwork(context *NetworkManagerContext)
 		return err
 	}
 
-	nldriver, err := m.Driver()
-	if err != nil {
-		return err
-	}
-
 	update := func(fn func() error) error {
 		return m.BaseMechanismManager.Update(
 			NetworkModel, network, fn,
@@ -999,33 +1210,52 @@ func (m *networkMechanismManager) DeleteNetwork(context *NetworkManagerContext)
 			return err
 		}
 
-		// If there is no network layer address assigned to port,
-		// there is nothing to do then.
-		nladdr, err := nldriver.Addr(port.Port)
-		if err != nil {
-			log.ErrorLog("network/DELETE_NETWORK",
-				"Network layer address is not assigned to port: ", err)
-			return nil
+		addrs := port.Addrs
+		if len(addrs) == 0 {
+			addrs = network.Port(port.Port).Addrs
 		}
 
-		// Forward event to activated mechanisms.
-		err = m.do(func(nlmech NetworkMechanism) error {
-			return nlmech.DeleteNetworkPreCommit(&NetworkContext{
-				NetworkDriver: nldriver,
-				NetworkAddr:   nladdr,
-				LinkDriver:    lldriver,
-				LinkAddr:      lladdr,
-				Port:          port.Port,
+		for i := range addrs {
+			nldriver, nladdr, err := m.parseAddr(&addrs[i])
+			if err != nil {
+				return err
+			}
+
+			// If address is not assigned to the port,
+			// there is nothing to do then.
+			nladdr, ok := portAddr(nldriver, port.Port, nladdr)
+			if !ok {
+				log.ErrorLog("network/DELETE_NETWORK",
+					"Network layer address is not assigned to port: ", addrs[i].Addr)
+				continue
+			}
+
+			// Forward event to activated mechanisms.
+			err = m.do(func(nlmech NetworkMechanism) error {
+				return nlmech.DeleteNetworkPreCommit(&NetworkContext{
+					NetworkDriver: nldriver,
+					NetworkAddr:   nladdr,
+					LinkDriver:    lldriver,
+					LinkAddr:      lladdr,
+					Port:          port.Port,
+					VLAN:          network.Port(port.Port).VLAN,
+					DHCP:          network.Port(port.Port).DHCP,
+					OSPF:          network.Port(port.Port).OSPF,
+				})
 			})
-		})
 
-		if err != nil {
-			log.ErrorLog("network/DELETE_NETWORK",
-				"Failed to delete network configuration: ", err)
-			return err
+			if err != nil {
+				log.ErrorLog("network/DELETE_NETWORK",
+					"Failed to delete network configuration: ", err)
+				return err
+			}
+
+			if err = nldriver.DeleteAddr(port.Port, nladdr); err != nil {
+				return err
+			}
 		}
 
-		return nldriver.DeleteAddr(port.Port)
+		return nil
 	}
 
 	m.lock.RLock()
@@ -1,6 +1,7 @@
 package mech
 
 import (
+	"encoding/json"
 	"testing"
 )
 
@@ -9,3 +10,79 @@ func TestNetworkUpdate(t *testing.T) {
 
 func TestNetworkDelete(t *testing.T) {
 }
+
+func TestNetworkManagerContextDelPort(t *testing.T) {
+	context := &NetworkManagerContext{Ports: []NetworkPort{
+		{Port: 1, Addrs: []NetworkAddress{
+			{"10.0.0.1/24", "ipv4"},
+			{"2001:db8::1/64", "ipv6"},
+		}},
+		{Port: 2, Addrs: []NetworkAddress{
+			{"10.0.1.1/24", "ipv4"},
+		}},
+	}}
+
+	// Delete only specified address of the port.
+	context.DelPort(NetworkPort{Port: 1, Addrs: []NetworkAddress{
+		{Addr: "10.0.0.1/24"},
+	}})
+
+	port := context.Port(1)
+	if len(port.Addrs) != 1 || !port.HasAddr("2001:db8::1/64") {
+		t.Fatal("Only specified address must be deleted:", port.Addrs)
+	}
+
+	// Port is removed with the last address.
+	context.DelPort(NetworkPort{Port: 1, Addrs: []NetworkAddress{
+		{Addr: "2001:db8::1/64"},
+	}})
+
+	if len(context.Ports) != 1 || context.Ports[0].Port != 2 {
+		t.Fatal("Port without addresses must be removed:", context.Ports)
+	}
+
+	// All addresses are deleted, when none specified.
+	context.DelPort(NetworkPort{Port: 2})
+	if len(context.Ports) != 0 {
+		t.Fatal("All ports must be removed:", context.Ports)
+	}
+}
+
+func TestNetworkManagerContextLegacy(t *testing.T) {
+	var context NetworkManagerContext
+
+	// Network persisted with a single address per port.
+	b := []byte(`{"id":"00:00:00:00:00:00:00:01","driver":"ipv4",` +
+		`"ports":[{"address":"10.0.0.1/24","port":1},{"port":2}]}`)
+
+	if err := json.Unmarshal(b, &context); err != nil {
+		t.Fatal("Failed to decode legacy network:", err)
+	}
+
+	if context.Datapath != "00:00:00:00:00:00:00:01" {
+		t.Fatal("Datapath identifier must be decoded:", context.Datapath)
+	}
+
+	port := context.Port(1)
+	if len(port.Addrs) != 1 || port.Addrs[0] != (NetworkAddress{"10.0.0.1/24", "ipv4"}) {
+		t.Fatal("Legacy address must be converted:", port.Addrs)
+	}
+
+	if port = context.Port(2); len(context.Ports) != 2 || len(port.Addrs) != 0 {
+		t.Fatal("Port without address must be kept empty:", context.Ports)
+	}
+
+	// Network persisted with a list of addresses per port.
+	b = []byte(`{"id":"00:00:00:00:00:00:00:01","ports":[{"addresses":` +
+		`[{"address":"10.0.0.1/24","driver":"ipv4"}],"port":1,"vlan":10}]}`)
+
+	context = NetworkManagerContext{}
+	if err := json.Unmarshal(b, &context); err != nil {
+		t.Fatal("Failed to decode network:", err)
+	}
+
+	port = context.Port(1)
+	if len(port.Addrs) != 1 || port.Addrs[0] != (NetworkAddress{"10.0.0.1/24", "ipv4"}) || port.VLAN != 10 {
+		t.Fatal("Network must be decoded:", port)
+	}
+}
@@ -0,0 +1,104 @@
+package mech
+
+import (
+	"errors"
+
+	"github.com/netrack/netrack/logging"
+)
+
+// Network types of OSPF interfaces.
+const (
+	OSPFBroadcast    = "broadcast"
+	OSPFPointToPoint = "point-to-point"
+)
+
+// ErrOSPF is returned when OSPF does not run on the switch.
+var ErrOSPF = errors.New("OSPF: routing protocol is not running")
+
+// OSPFConfig is an OSPF configuration of the network interface,
+// all interfaces of the switch belong to the backbone area.
+type OSPFConfig struct {
+	// Type of the attached network, either
+	// broadcast or point-to-point.
+	Type string `json:"type"`
+
+	// Cost of sending packets out of the interface.
+	Cost uint16 `json:"cost"`
+
+	// Priority of the router in the designated router election,
+	// router with zero priority never becomes designated router.
+	Priority uint8 `json:"priority"`
+
+	// Intervals in seconds between hello packets and
+	// before neighbor is declared down.
+	HelloInterval uint16 `json:"hello_interval"`
+	DeadInterval  uint32 `json:"dead_interval"`
+}
+
+// OSPFNeighbor is an OSPF router discovered on the network interface.
+type OSPFNeighbor struct {
+	// Router identifier of the neighbor.
+	RouterID string `json:"router_id"`
+
+	// Interface address of the neighbor.
+	Addr string `json:"address"`
+
+	// Switch port number, the neighbor is connected to.
+	Port uint32 `json:"port"`
+
+	// State of the neighbor conversation.
+	State string `json:"state"`
+
+	// Priority of the neighbor in the designated router election.
+	Priority uint8 `json:"priority"`
+
+	// Designated and backup designated routers declared by the neighbor.
+	DR  string `json:"dr"`
+	BDR string `json:"bdr"`
+}
+
+// OSPFLSA is a link state advertisement of the OSPF database.
+type OSPFLSA struct {
+	// Type of the advertisement.
+	Type string `json:"type"`
+
+	// Link state identifier.
+	ID string `json:"ls_id"`
+
+	// Router identifier of the originator.
+	AdvRouter string `json:"adv_router"`
+
+	// Sequence number of the advertisement instance.
+	Seq uint32 `json:"sequence"`
+
+	// Age of the advertisement in seconds.
+	Age uint16 `json:"age"`
+
+	// Checksum of the advertisement.
+	Checksum uint16 `json:"checksum"`
+
+	// Length of the advertisement in bytes.
+	Length uint16 `json:"length"`
+}
+
+// OSPFReader is the interface implemented
+// by mechanisms, that run OSPF protocol.
+type OSPFReader interface {
+	// OSPFNeighbors returns discovered neighbors.
+	OSPFNeighbors() []OSPFNeighbor
+
+	// OSPFDatabase returns link state database.
+	OSPFDatabase() []OSPFLSA
+}
+
+// OSPFRdr returns OSPF state reader of the switch.
+func OSPFRdr(context *MechanismContext) (OSPFReader, error) {
+	var reader OSPFReader
+	if err := context.Managers.Obtain(&reader); err != nil {
+		log.ErrorLog("mechanism/OSPF_READER",
+			"Failed to obtain OSPF reader: ", err)
+		return nil, ErrOSPF
+	}
+
+	return reader, nil
+}
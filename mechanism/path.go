@@ -0,0 +1,227 @@
+package mech
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+
+	"github.com/netrack/netrack/logging"
+)
+
+var (
+	// ErrPathNotFound is returned when there is no
+	// path between switches in the topology.
+	ErrPathNotFound = errors.New("PathManager: path not found")
+
+	// DefaultPaths is a controller-wide path computation service.
+	DefaultPaths = NewPathManager(DefaultTopology)
+)
+
+// pathNode is an entry of the Dijkstra priority queue.
+type pathNode struct {
+	dpid string
+	cost uint64
+}
+
+type pathQueue []pathNode
+
+func (q pathQueue) Len() int {
+	return len(q)
+}
+
+func (q pathQueue) Less(i, j int) bool {
+	if q[i].cost != q[j].cost {
+		return q[i].cost < q[j].cost
+	}
+
+	// Make result independent from the order of insertion.
+	return q[i].dpid < q[j].dpid
+}
+
+func (q pathQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *pathQueue) Push(x interface{}) {
+	*q = append(*q, x.(pathNode))
+}
+
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	node := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return node
+}
+
+// ShortestPath returns links of the path with the lowest cost
+// from the source switch to the destination switch. Empty path
+// is returned when source and destination are the same switch.
+func ShortestPath(links []TopologyLink, src, dst string) ([]TopologyLink, bool) {
+	adjacent := make(map[string][]TopologyLink)
+	for _, link := range links {
+		adjacent[link.SrcDatapath] = append(adjacent[link.SrcDatapath], link)
+	}
+
+	costs := map[string]uint64{src: 0}
+	via := make(map[string]TopologyLink)
+	visited := make(map[string]bool)
+
+	queue := &pathQueue{{src, 0}}
+
+	for queue.Len() > 0 {
+		node := heap.Pop(queue).(pathNode)
+		if visited[node.dpid] {
+			continue
+		}
+
+		visited[node.dpid] = true
+		if node.dpid == dst {
+			break
+		}
+
+		for _, link := range adjacent[node.dpid] {
+			cost := node.cost + uint64(link.Weight())
+
+			if prev, ok := costs[link.DstDatapath]; ok && prev <= cost {
+				continue
+			}
+
+			costs[link.DstDatapath] = cost
+			via[link.DstDatapath] = link
+			heap.Push(queue, pathNode{link.DstDatapath, cost})
+		}
+	}
+
+	if !visited[dst] {
+		return nil, false
+	}
+
+	var path []TopologyLink
+	for dpid := dst; dpid != src; {
+		link := via[dpid]
+		path = append([]TopologyLink{link}, path...)
+		dpid = link.SrcDatapath
+	}
+
+	return path, true
+}
+
+// PathFunc is a function called to install links of the
+// computed path, nil links are passed when path is lost.
+type PathFunc func(links []TopologyLink) error
+
+// path is a path installed between two switches.
+type path struct {
+	src, dst string
+	links    []TopologyLink
+	fn       PathFunc
+}
+
+// uses returns true, when path goes through the link.
+func (p *path) uses(link TopologyLink) bool {
+	for _, l := range p.links {
+		if l.SrcDatapath == link.SrcDatapath && l.SrcPort == link.SrcPort {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PathManager computes paths between switches and recomputes
+// them, when links of the installed paths disappear.
+type PathManager struct {
+	topology *Topology
+
+	paths map[string]*path
+	lock  sync.Mutex
+}
+
+// NewPathManager creates a new instance of PathManager
+// type, that computes paths through specified topology.
+func NewPathManager(topology *Topology) *PathManager {
+	m := &PathManager{
+		topology: topology,
+		paths:    make(map[string]*path),
+	}
+
+	topology.HandleLinkDownFunc(m.linkDown)
+	return m
+}
+
+// Install computes path from the source switch to the destination
+// switch and calls specified function to install it. Path is
+// identified by key, it will be recomputed on topology changes.
+func (m *PathManager) Install(key, src, dst string, fn PathFunc) error {
+	links, ok := ShortestPath(m.topology.Links(), src, dst)
+	if !ok {
+		log.DebugLogf("path/INSTALL",
+			"Path from %s to %s not found", src, dst)
+		return ErrPathNotFound
+	}
+
+	if err := fn(links); err != nil {
+		log.ErrorLog("path/INSTALL",
+			"Failed to install path: ", err)
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.paths[key] = &path{src, dst, links, fn}
+	return nil
+}
+
+// Remove stops tracking of the path identified by key.
+func (m *PathManager) Remove(key string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.paths, key)
+}
+
+// Path returns links of the installed path identified by key.
+func (m *PathManager) Path(key string) ([]TopologyLink, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	p, ok := m.paths[key]
+	if !ok {
+		return nil, false
+	}
+
+	return p.links, true
+}
+
+// linkDown recomputes paths, that go through the removed link.
+func (m *PathManager) linkDown(link TopologyLink) {
+	m.lock.Lock()
+
+	affected := make(map[string]*path)
+	for key, p := range m.paths {
+		if p.uses(link) {
+			affected[key] = p
+		}
+	}
+
+	m.lock.Unlock()
+
+	for key, p := range affected {
+		log.InfoLogf("path/LINK_DOWN",
+			"Link %s/%d is down, recomputing path from %s to %s",
+			link.SrcDatapath, link.SrcPort, p.src, p.dst)
+
+		if err := m.Install(key, p.src, p.dst, p.fn); err == nil {
+			continue
+		}
+
+		m.Remove(key)
+
+		// Let the owner of the path to clean up installed flows.
+		if err := p.fn(nil); err != nil {
+			log.ErrorLog("path/LINK_DOWN",
+				"Failed to remove lost path: ", err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package mech
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShortestPath(t *testing.T) {
+	links := []TopologyLink{
+		{SrcDatapath: "a", SrcPort: 1, DstDatapath: "b", DstPort: 1},
+		{SrcDatapath: "b", SrcPort: 2, DstDatapath: "d", DstPort: 1},
+		{SrcDatapath: "a", SrcPort: 2, DstDatapath: "c", DstPort: 1},
+		{SrcDatapath: "c", SrcPort: 2, DstDatapath: "d", DstPort: 2, Cost: 5},
+	}
+
+	path, ok := ShortestPath(links, "a", "d")
+	if !ok || len(path) != 2 {
+		t.Fatal("Failed to find path:", path)
+	}
+
+	if path[0].SrcPort != 1 || path[1].SrcDatapath != "b" {
+		t.Fatal("Path with the lowest cost must be chosen:", path)
+	}
+
+	if path, ok = ShortestPath(links, "a", "a"); !ok || len(path) != 0 {
+		t.Fatal("Path to the same switch must be empty:", path)
+	}
+
+	if _, ok = ShortestPath(links, "d", "a"); ok {
+		t.Fatal("Links must be directed")
+	}
+}
+
+func TestPathManagerLinkDown(t *testing.T) {
+	topology := NewTopology()
+	paths := NewPathManager(topology)
+
+	now := time.Now()
+	topology.UpdateLink(TopologyLink{"a", 1, "b", 1, 0, now})
+	topology.UpdateLink(TopologyLink{"b", 2, "c", 1, 0, now})
+	topology.UpdateLink(TopologyLink{"a", 2, "c", 2, 3, now})
+
+	var installed [][]TopologyLink
+	install := func(links []TopologyLink) error {
+		installed = append(installed, links)
+		return nil
+	}
+
+	if err := paths.Install("a>c", "a", "c", install); err != nil {
+		t.Fatal("Failed to install path:", err)
+	}
+
+	if len(installed) != 1 || len(installed[0]) != 2 {
+		t.Fatal("Invalid installed path:", installed)
+	}
+
+	// Path is recomputed through the remaining link.
+	topology.DeletePort("b", 2)
+
+	if len(installed) != 2 || len(installed[1]) != 1 || installed[1][0].SrcPort != 2 {
+		t.Fatal("Path must be recomputed:", installed)
+	}
+
+	// Path is lost.
+	topology.DeleteNode("c")
+
+	if len(installed) != 3 || installed[2] != nil {
+		t.Fatal("Lost path must be removed:", installed)
+	}
+
+	if _, ok := paths.Path("a>c"); ok {
+		t.Fatal("Lost path must not be tracked")
+	}
+}
@@ -0,0 +1,103 @@
+package mech
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/netrack/openflow"
+)
+
+// PortEventType describes changes of the switch port.
+type PortEventType string
+
+const (
+	// PortAdded is an event of port addition.
+	PortAdded PortEventType = "add"
+
+	// PortDeleted is an event of port removal.
+	PortDeleted PortEventType = "delete"
+
+	// PortUp is an event of port transition to the up state.
+	PortUp PortEventType = "up"
+
+	// PortDown is an event of port transition to the down state.
+	PortDown PortEventType = "down"
+
+	// PortModified is an event of port modification,
+	// that does not change state of the port.
+	PortModified PortEventType = "modify"
+)
+
+// PortEvent describes a single change of the switch port.
+type PortEvent struct {
+	// Type of the event.
+	Type PortEventType
+
+	// Description of the port after the change.
+	Port *SwitchPort
+}
+
+// PortStatusSwitch is the interface implemented by
+// switches, that track changes of their ports.
+type PortStatusSwitch interface {
+	Switch
+
+	// UpdatePort applies port status message to the
+	// list of switch ports and returns port change.
+	UpdatePort(*of.Request) (PortEvent, error)
+}
+
+// PortEventHandlerFunc is a function called on port changes.
+type PortEventHandlerFunc func(PortEvent)
+
+// PortNotifier delivers port changes to the subscribed mechanisms.
+type PortNotifier struct {
+	handlers map[string]PortEventHandlerFunc
+	lock     sync.RWMutex
+}
+
+// NewPortNotifier creates a new instance of PortNotifier type.
+func NewPortNotifier() *PortNotifier {
+	return &PortNotifier{handlers: make(map[string]PortEventHandlerFunc)}
+}
+
+// Subscribe registers handler of port changes with specified
+// name, previous handler with the same name is replaced.
+func (n *PortNotifier) Subscribe(name string, fn PortEventHandlerFunc) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.handlers[name] = fn
+}
+
+// Unsubscribe removes handler of port changes with specified name.
+func (n *PortNotifier) Unsubscribe(name string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	delete(n.handlers, name)
+}
+
+// Notify calls subscribed handlers in order of their names.
+func (n *PortNotifier) Notify(event PortEvent) {
+	n.lock.RLock()
+
+	var names []string
+	for name := range n.handlers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	handlers := make([]PortEventHandlerFunc, 0, len(names))
+	for _, name := range names {
+		handlers = append(handlers, n.handlers[name])
+	}
+
+	n.lock.RUnlock()
+
+	// Handlers are allowed to (un)subscribe.
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
@@ -0,0 +1,32 @@
+package mech
+
+import (
+	"testing"
+)
+
+func TestPortNotifier(t *testing.T) {
+	notifier := NewPortNotifier()
+
+	var calls []string
+
+	notifier.Subscribe("routing", func(event PortEvent) {
+		calls = append(calls, "routing:"+string(event.Type))
+	})
+
+	notifier.Subscribe("link", func(event PortEvent) {
+		calls = append(calls, "link:"+string(event.Type))
+	})
+
+	notifier.Notify(PortEvent{Type: PortDown, Port: &SwitchPort{Number: 1}})
+
+	if len(calls) != 2 || calls[0] != "link:down" || calls[1] != "routing:down" {
+		t.Fatal("Invalid notification of handlers:", calls)
+	}
+
+	notifier.Unsubscribe("link")
+	notifier.Notify(PortEvent{Type: PortUp, Port: &SwitchPort{Number: 1}})
+
+	if len(calls) != 3 || calls[2] != "routing:up" {
+		t.Fatal("Unsubscribed handler must not be notified:", calls)
+	}
+}
@@ -0,0 +1,318 @@
+package mech
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/netrack/netrack/database"
+	"github.com/netrack/netrack/logging"
+)
+
+const (
+	// PortStatsModel is a database table name (port_stats)
+	PortStatsModel db.Model = "port_stat"
+
+	// DefaultStatsInterval is a default interval
+	// between port statistics requests.
+	DefaultStatsInterval = 10 * time.Second
+
+	// DefaultStatsHistoryLen is a default number of
+	// samples stored in memory for each port.
+	DefaultStatsHistoryLen = 60
+)
+
+func init() {
+	// Register model in a database to make it available
+	db.Register(PortStatsModel)
+}
+
+var (
+	// ErrPortStats is returned when switch is not able
+	// to provide port statistics.
+	ErrPortStats = errors.New("PortStatsReader: port statistics are not supported")
+)
+
+// PortCounters describes counters of the switch port.
+type PortCounters struct {
+	// Number of the port in a switch.
+	Port uint32 `json:"port"`
+
+	// Number of received and transmitted packets.
+	RxPackets uint64 `json:"rx_packets"`
+	TxPackets uint64 `json:"tx_packets"`
+
+	// Number of received and transmitted bytes.
+	RxBytes uint64 `json:"rx_bytes"`
+	TxBytes uint64 `json:"tx_bytes"`
+
+	// Number of packets dropped by receiver and transmitter.
+	RxDropped uint64 `json:"rx_dropped"`
+	TxDropped uint64 `json:"tx_dropped"`
+
+	// Number of receive and transmit errors.
+	RxErrors uint64 `json:"rx_errors"`
+	TxErrors uint64 `json:"tx_errors"`
+}
+
+// PortRates describes per-second rates of port counters.
+type PortRates struct {
+	RxPackets float64 `json:"rx_packets"`
+	TxPackets float64 `json:"tx_packets"`
+	RxBytes   float64 `json:"rx_bytes"`
+	TxBytes   float64 `json:"tx_bytes"`
+	RxDropped float64 `json:"rx_dropped"`
+	TxDropped float64 `json:"tx_dropped"`
+	RxErrors  float64 `json:"rx_errors"`
+	TxErrors  float64 `json:"tx_errors"`
+}
+
+// PortStats is a single sample of port statistics.
+type PortStats struct {
+	// Identifier of the sample.
+	ID string `json:"id"`
+
+	// Datapath identifier.
+	Datapath string `json:"datapath"`
+
+	// Time of the sample.
+	Time time.Time `json:"time"`
+
+	// Port counters at the time of the sample.
+	Counters PortCounters `json:"counters"`
+
+	// Rates since the previous sample.
+	Rates PortRates `json:"rates"`
+}
+
+// rate returns per-second rate of the counter, zero is
+// returned when counter was reset (e.g. by switch reboot).
+func rate(prev, next uint64, interval time.Duration) float64 {
+	if next < prev || interval <= 0 {
+		return 0
+	}
+
+	return float64(next-prev) / interval.Seconds()
+}
+
+// portRates calculates rates of counters between two samples.
+func portRates(prev, next PortCounters, interval time.Duration) PortRates {
+	return PortRates{
+		RxPackets: rate(prev.RxPackets, next.RxPackets, interval),
+		TxPackets: rate(prev.TxPackets, next.TxPackets, interval),
+		RxBytes:   rate(prev.RxBytes, next.RxBytes, interval),
+		TxBytes:   rate(prev.TxBytes, next.TxBytes, interval),
+		RxDropped: rate(prev.RxDropped, next.RxDropped, interval),
+		TxDropped: rate(prev.TxDropped, next.TxDropped, interval),
+		RxErrors:  rate(prev.RxErrors, next.RxErrors, interval),
+		TxErrors:  rate(prev.TxErrors, next.TxErrors, interval),
+	}
+}
+
+// PortStatsReader is the interface implemented by extension
+// mechanisms, that able to retrieve counters of switch ports.
+type PortStatsReader interface {
+	// PortStats returns counters of all switch ports.
+	PortStats() ([]PortCounters, error)
+}
+
+// StatsReader returns port statistics reader of the switch.
+func StatsReader(context *MechanismContext) (PortStatsReader, error) {
+	var reader PortStatsReader
+	if err := context.Managers.Obtain(&reader); err != nil {
+		log.ErrorLog("mechanism/PORT_STATS_READER",
+			"Failed to obtain port statistics reader: ", err)
+		return nil, ErrPortStats
+	}
+
+	return reader, nil
+}
+
+// portStatsRing is a bounded list of port statistics
+// samples, the oldest samples are overwritten.
+type portStatsRing struct {
+	samples []PortStats
+
+	// Position of the next sample.
+	next int
+
+	// Number of stored samples.
+	count int
+}
+
+func newPortStatsRing(size int) *portStatsRing {
+	return &portStatsRing{samples: make([]PortStats, size)}
+}
+
+// Last returns the latest sample, false is returned for empty ring.
+func (r *portStatsRing) Last() (PortStats, bool) {
+	if r.count == 0 {
+		return PortStats{}, false
+	}
+
+	return r.samples[(r.next+len(r.samples)-1)%len(r.samples)], true
+}
+
+// Push appends sample to the ring.
+func (r *portStatsRing) Push(stats PortStats) {
+	r.samples[r.next] = stats
+	r.next = (r.next + 1) % len(r.samples)
+
+	if r.count < len(r.samples) {
+		r.count++
+	}
+}
+
+// List returns stored samples, the latest samples are at the end.
+func (r *portStatsRing) List() []PortStats {
+	samples := make([]PortStats, 0, r.count)
+	start := (r.next + len(r.samples) - r.count) % len(r.samples)
+
+	for i := 0; i < r.count; i++ {
+		samples = append(samples, r.samples[(start+i)%len(r.samples)])
+	}
+
+	return samples
+}
+
+// statsCollector periodically requests counters of switch
+// ports and keeps a bounded history of samples for each port.
+type statsCollector struct {
+	// Monitored switch context.
+	c *MechanismContext
+
+	// Interval between statistics requests.
+	interval time.Duration
+
+	// Maximum number of samples for each port.
+	limit int
+
+	// Save samples into database.
+	persist bool
+
+	rings map[uint32]*portStatsRing
+	lock  sync.RWMutex
+
+	stopCh chan bool
+}
+
+func newStatsCollector(c *MechanismContext, interval time.Duration, limit int, persist bool) *statsCollector {
+	if interval <= 0 {
+		interval = DefaultStatsInterval
+	}
+
+	if limit <= 0 {
+		limit = DefaultStatsHistoryLen
+	}
+
+	return &statsCollector{
+		c:        c,
+		interval: interval,
+		limit:    limit,
+		persist:  persist,
+		rings:    make(map[uint32]*portStatsRing),
+		stopCh:   make(chan bool),
+	}
+}
+
+// Start starts collecting statistics in a separate goroutine.
+func (m *statsCollector) Start() {
+	go m.run()
+}
+
+// Stop stops collecting statistics.
+func (m *statsCollector) Stop() {
+	close(m.stopCh)
+}
+
+// Stats returns collected samples of the port, the latest samples are at the end.
+func (m *statsCollector) Stats(port uint32) []PortStats {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	ring, ok := m.rings[port]
+	if !ok {
+		return nil
+	}
+
+	return ring.List()
+}
+
+func (m *statsCollector) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		m.tick()
+	}
+}
+
+// tick requests counters of switch ports and stores them.
+func (m *statsCollector) tick() {
+	// Not all protocol versions provide port statistics,
+	// so skip the sample without reporting an error.
+	var reader PortStatsReader
+	if err := m.c.Managers.Obtain(&reader); err != nil {
+		return
+	}
+
+	counters, err := reader.PortStats()
+	if err != nil {
+		log.ErrorLog("port_stats/COLLECT",
+			"Failed to retrieve port statistics: ", err)
+		return
+	}
+
+	samples := m.add(time.Now(), counters)
+	if !m.persist {
+		return
+	}
+
+	for _, sample := range samples {
+		if err = db.Create(PortStatsModel, &sample); err != nil {
+			log.ErrorLog("port_stats/PERSIST",
+				"Failed to save port statistics: ", err)
+			return
+		}
+	}
+}
+
+// add stores counters as samples with rates since the previous samples.
+func (m *statsCollector) add(now time.Time, counters []PortCounters) []PortStats {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	dpid := m.c.Switch.ID()
+	samples := make([]PortStats, 0, len(counters))
+
+	for _, c := range counters {
+		ring, ok := m.rings[c.Port]
+		if !ok {
+			ring = newPortStatsRing(m.limit)
+			m.rings[c.Port] = ring
+		}
+
+		sample := PortStats{
+			ID:       fmt.Sprintf("%s/%d/%d", dpid, c.Port, now.UnixNano()),
+			Datapath: dpid,
+			Time:     now,
+			Counters: c,
+		}
+
+		if prev, ok := ring.Last(); ok {
+			sample.Rates = portRates(prev.Counters, c, now.Sub(prev.Time))
+		}
+
+		ring.Push(sample)
+		samples = append(samples, sample)
+	}
+
+	return samples
+}
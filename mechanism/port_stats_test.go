@@ -0,0 +1,61 @@
+package mech
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPortStatsRing(t *testing.T) {
+	ring := newPortStatsRing(3)
+
+	if _, ok := ring.Last(); ok {
+		t.Fatal("Empty ring must not have the last sample")
+	}
+
+	for i := 1; i <= 5; i++ {
+		ring.Push(PortStats{Counters: PortCounters{RxPackets: uint64(i)}})
+	}
+
+	samples := ring.List()
+	if len(samples) != 3 {
+		t.Fatal("Invalid number of samples:", len(samples))
+	}
+
+	for i, sample := range samples {
+		if sample.Counters.RxPackets != uint64(i+3) {
+			t.Fatal("Invalid order of samples:", samples)
+		}
+	}
+
+	if last, _ := ring.Last(); last.Counters.RxPackets != 5 {
+		t.Fatal("Invalid last sample:", last)
+	}
+}
+
+func TestStatsCollectorRates(t *testing.T) {
+	c := &MechanismContext{Switch: &fakeSwitch{}}
+	collector := newStatsCollector(c, time.Second, 10, false)
+
+	now := time.Now()
+	collector.add(now, []PortCounters{{Port: 1, RxBytes: 1000, TxPackets: 10}})
+	collector.add(now.Add(2*time.Second), []PortCounters{{Port: 1, RxBytes: 3000, TxPackets: 5}})
+
+	samples := collector.Stats(1)
+	if len(samples) != 2 {
+		t.Fatal("Invalid number of samples:", len(samples))
+	}
+
+	rates := samples[1].Rates
+	if rates.RxBytes != 1000 {
+		t.Fatal("Invalid rate of received bytes:", rates.RxBytes)
+	}
+
+	// Counter was reset, so rate is unknown.
+	if rates.TxPackets != 0 {
+		t.Fatal("Invalid rate of reset counter:", rates.TxPackets)
+	}
+
+	if samples := collector.Stats(2); samples != nil {
+		t.Fatal("Unknown port must not have samples:", samples)
+	}
+}
@@ -1,7 +1,9 @@
 package mech
 
 import (
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/netrack/netrack/database"
 	"github.com/netrack/netrack/logging"
@@ -16,6 +18,7 @@ const (
 	StaticRoute    RouteType = "static"
 	LocalRoute     RouteType = "local"
 	ConnectedRoute RouteType = "connected"
+	BGPRoute       RouteType = "bgp"
 	EIGRPRoute     RouteType = "eigrp"
 	OSPFRoute      RouteType = "ospf"
 	RIPRoute       RouteType = "rip"
@@ -35,12 +38,39 @@ func init() {
 
 type RouteType string
 
+// Dynamic reports whether routes of the type are learned by
+// a routing protocol, such routes are never persisted.
+func (t RouteType) Dynamic() bool {
+	switch t {
+	case BGPRoute, EIGRPRoute, OSPFRoute, RIPRoute:
+		return true
+	}
+
+	return false
+}
+
+// routeOrder defines order of restoration of persisted
+// routes, next hops of static routes are resolved through
+// connected networks, so these are restored first.
+var routeOrder = map[RouteType]int{
+	ConnectedRoute: 0,
+	LocalRoute:     1,
+	StaticRoute:    2,
+}
+
 type RoutingContext struct {
 	Type    RouteType
 	Network NetworkAddr
 	NextHop NetworkAddr
 	Driver  NetworkDriver
 	Port    uint32
+
+	// Administrative distance of the route, zero
+	// for the default distance of the route type.
+	Distance int
+
+	// Metric of the route.
+	Metric int
 }
 
 type Route struct {
@@ -48,10 +78,26 @@ type Route struct {
 	Network string `json:"network"`
 	NextHop string `json:"nexthop"`
 	Port    uint32 `json:"port"`
+
+	// Administrative distance of the route, zero
+	// for the default distance of the route type.
+	Distance int `json:"distance,omitempty"`
+
+	// Metric of the route.
+	Metric int `json:"metric,omitempty"`
+
+	// Administrative tag of the route.
+	Tag uint32 `json:"tag,omitempty"`
+
+	// Time, when route was persisted.
+	CreatedAt time.Time `json:"created_at"`
 }
 
+// Equals reports whether routes are the same: they have the
+// same type, destination network, next hop and port.
 func (c *Route) Equals(rc *Route) bool {
-	return c.Network == rc.Network && c.NextHop == rc.NextHop
+	return c.Type == rc.Type && c.Network == rc.Network &&
+		c.NextHop == rc.NextHop && c.Port == rc.Port
 }
 
 type RoutingMechanism interface {
@@ -84,14 +130,63 @@ type RoutingManagerContext struct {
 	Routes   []*Route `json:"routes"`
 }
 
+// SetRoute persists the route, metadata of the same
+// route is updated, dynamic routes are ignored.
 func (c *RoutingManagerContext) SetRoute(r *Route) {
-	for _, route := range c.Routes {
+	if RouteType(r.Type).Dynamic() {
+		return
+	}
+
+	for i, route := range c.Routes {
 		if route.Equals(r) {
+			updated := *r
+			updated.CreatedAt = route.CreatedAt
+			c.Routes[i] = &updated
 			return
 		}
 	}
 
-	c.Routes = append(c.Routes, r)
+	created := *r
+	if created.CreatedAt.IsZero() {
+		created.CreatedAt = time.Now().UTC()
+	}
+
+	c.Routes = append(c.Routes, &created)
+}
+
+// Sorted returns persisted routes in order of restoration: by type,
+// time of creation, network, next hop and port. Dynamic routes are
+// skipped.
+func (c *RoutingManagerContext) Sorted() []*Route {
+	var routes []*Route
+	for _, route := range c.Routes {
+		if !RouteType(route.Type).Dynamic() {
+			routes = append(routes, route)
+		}
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+
+		ai, bi := routeOrder[RouteType(a.Type)], routeOrder[RouteType(b.Type)]
+
+		switch {
+		case ai != bi:
+			return ai < bi
+		case a.Type != b.Type:
+			return a.Type < b.Type
+		case !a.CreatedAt.Equal(b.CreatedAt):
+			return a.CreatedAt.Before(b.CreatedAt)
+		case a.Network != b.Network:
+			return a.Network < b.Network
+		case a.NextHop != b.NextHop:
+			return a.NextHop < b.NextHop
+		}
+
+		return a.Port < b.Port
+	})
+
+	return routes
 }
 
 func (c *RoutingManagerContext) DelRoute(r *Route) {
@@ -136,12 +231,15 @@ func RegisterRoutingMechanism(name string, ctor RoutingMechanismConstructor) {
 	routes[name] = ctor
 }
 
-// RoutingMechanisms retruns instances of registered mechanisms.
-func RoutingMechanisms() RoutingMechanismMap {
+// RoutingMechanisms retruns instances of registered mechanisms,
+// that support specified OpenFlow protocol version.
+func RoutingMechanisms(version string) RoutingMechanismMap {
 	lmap := make(RoutingMechanismMap)
 
 	for name, constructor := range routes {
-		lmap.Set(name, constructor.New())
+		if mechanism := constructor.New(); MechanismSupports(mechanism, version) {
+			lmap.Set(name, mechanism)
+		}
 	}
 
 	return lmap
@@ -168,10 +266,19 @@ func (m RoutingMechanismMap) Set(s string, mechanism Mechanism) {
 	m[s] = rmechanism
 }
 
-// Iter calls specified function for all registered mechanisms.
+// Iter calls specified function for all registered mechanisms in
+// order of their names, so tables of the switch are allocated to
+// mechanisms in the same order after each connection.
 func (m RoutingMechanismMap) Iter(fn func(string, Mechanism) bool) {
-	for s, mechanism := range m {
-		fn(s, mechanism)
+	names := make([]string, 0, len(m))
+	for s := range m {
+		names = append(names, s)
+	}
+
+	sort.Strings(names)
+
+	for _, s := range names {
+		fn(s, m[s])
 	}
 }
 
@@ -187,20 +294,30 @@ type RoutingMechanismManager interface {
 
 	// DeleteRoutes forwards call to all registered mechanisms.
 	DeleteRoutes(*RoutingManagerContext) error
+
+	// LearnRoute forwards route learned by a routing protocol
+	// to all registered mechanisms, route is not persisted.
+	LearnRoute(*RoutingContext) error
+
+	// ForgetRoute forwards withdrawal of the learned
+	// route to all registered mechanisms.
+	ForgetRoute(*RoutingContext) error
 }
 
 type routingMechanismManager struct {
 	BaseMechanismManager
 
-	// Network layer driver.
-	nldrv     NetworkDriver
+	// Network layer drivers of configured interfaces.
+	nldrvs    map[string]NetworkDriver
 	nldrvLock sync.RWMutex
 
 	lock sync.RWMutex
 }
 
 func NewRoutingMechanismManager() *routingMechanismManager {
-	return &routingMechanismManager{}
+	return &routingMechanismManager{
+		nldrvs: make(map[string]NetworkDriver),
+	}
 }
 
 func (m *routingMechanismManager) Name() string {
@@ -214,13 +331,64 @@ func (m *routingMechanismManager) Description() string {
 func (m *routingMechanismManager) Enable(c *MechanismContext) {
 	m.BaseMechanismManager = BaseMechanismManager{
 		Datapath:   c.Switch.ID(),
-		Mechanisms: RoutingMechanisms(),
+		Mechanisms: RoutingMechanisms(c.Switch.Version()),
 		activated:  0,
 		enabled:    0,
 	}
 
 	m.BaseMechanismManager.Enable(c)
 	c.Managers.Bind(new(RoutingMechanismManager), m)
+
+	// Withdraw routes through the ports, that went down.
+	c.Ports.Subscribe(m.Name(), m.portHandler)
+}
+
+// portHandler withdraws routes through the port, when it goes down
+// and restores them back, when port goes up. Routes are kept in the
+// persisted configuration, so they are not lost while port is down.
+func (m *routingMechanismManager) portHandler(event PortEvent) {
+	var fn routeMechanismFunc
+
+	switch event.Type {
+	case PortDown, PortDeleted:
+		fn = RoutingMechanism.DeleteRoute
+	case PortUp:
+		fn = RoutingMechanism.UpdateRoute
+	default:
+		return
+	}
+
+	if !m.Activated() {
+		return
+	}
+
+	routing, err := m.Context()
+	if err != nil {
+		return
+	}
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	for _, route := range routing.Routes {
+		if route.Port != event.Port.Number {
+			continue
+		}
+
+		routingContext, err := m.routingContext(route)
+		if err != nil {
+			continue
+		}
+
+		log.InfoLogf("routing/PORT_HANDLER",
+			"Port %s is %s, altering route to %s",
+			event.Port.Name, event.Type, route.Network)
+
+		if err = m.do(fn, routingContext); err != nil {
+			log.ErrorLog("routing/PORT_HANDLER",
+				"Failed to alter route: ", err)
+		}
+	}
 }
 
 func (m *routingMechanismManager) Activate() {
@@ -246,20 +414,37 @@ func (m *routingMechanismManager) SetNetworkDriver(nldriver NetworkDriver) {
 	m.nldrvLock.Lock()
 	defer m.nldrvLock.Unlock()
 
-	m.nldrv = nldriver
+	m.nldrvs[nldriver.Name()] = nldriver
 }
 
-func (m *routingMechanismManager) NetworkDriver() (NetworkDriver, error) {
+// NetworkDriver returns network layer driver, that accepts specified
+// address, drivers are probed in order of their names.
+func (m *routingMechanismManager) NetworkDriver(addr string) (NetworkDriver, error) {
 	m.nldrvLock.RLock()
 	defer m.nldrvLock.RUnlock()
 
-	if m.nldrv == nil {
+	if len(m.nldrvs) == 0 {
 		log.ErrorLog("routing/NETWORK_DRIVER",
 			"Network layer driver is not intialized")
 		return nil, ErrNetworkNotInitialized
 	}
 
-	return m.nldrv, nil
+	var names []string
+	for name := range m.nldrvs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := m.nldrvs[name].ParseAddr(addr); err == nil {
+			return m.nldrvs[name], nil
+		}
+	}
+
+	log.ErrorLog("routing/NETWORK_DRIVER",
+		"Network layer driver of address is not initialized: ", addr)
+	return nil, ErrNetworkNotInitialized
 }
 
 func (m *routingMechanismManager) CreateNetworkPreCommit(context *NetworkContext) error {
@@ -432,7 +617,7 @@ func (m *routingMechanismManager) CreateRoutes() error {
 	defer m.lock.RUnlock()
 
 	return create(func() error {
-		for _, route := range routing.Routes {
+		for _, route := range routing.Sorted() {
 			if err := alter(route); err != nil {
 				return err
 			}
@@ -443,7 +628,7 @@ func (m *routingMechanismManager) CreateRoutes() error {
 }
 
 func (m *routingMechanismManager) routingContext(route *Route) (*RoutingContext, error) {
-	nldriver, err := m.NetworkDriver()
+	nldriver, err := m.NetworkDriver(route.Network)
 	if err != nil {
 		return nil, err
 	}
@@ -463,11 +648,13 @@ func (m *routingMechanismManager) routingContext(route *Route) (*RoutingContext,
 	}
 
 	context := &RoutingContext{
-		Type:    RouteType(route.Type),
-		Network: networkAddr,
-		NextHop: nextHopAddr,
-		Driver:  nldriver,
-		Port:    route.Port,
+		Type:     RouteType(route.Type),
+		Network:  networkAddr,
+		NextHop:  nextHopAddr,
+		Driver:   nldriver,
+		Port:     route.Port,
+		Distance: route.Distance,
+		Metric:   route.Metric,
 	}
 
 	return context, nil
@@ -552,3 +739,17 @@ func (m *routingMechanismManager) DeleteRoutes(context *RoutingManagerContext) e
 		return nil
 	})
 }
+
+func (m *routingMechanismManager) LearnRoute(context *RoutingContext) error {
+	log.DebugLogf("routing/LEARN_ROUTE",
+		"Learned %s route to %s", context.Type, context.Network)
+
+	return m.do(RoutingMechanism.UpdateRoute, context)
+}
+
+func (m *routingMechanismManager) ForgetRoute(context *RoutingContext) error {
+	log.DebugLogf("routing/FORGET_ROUTE",
+		"Forgot %s route to %s", context.Type, context.Network)
+
+	return m.do(RoutingMechanism.DeleteRoute, context)
+}
@@ -2,6 +2,7 @@ package mech
 
 import (
 	"testing"
+	"time"
 )
 
 func TestRoutingUpdate(t *testing.T) {
@@ -9,3 +10,42 @@ func TestRoutingUpdate(t *testing.T) {
 
 func TestRoutingDelete(t *testing.T) {
 }
+
+func TestRoutingManagerContext(t *testing.T) {
+	created := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var context RoutingManagerContext
+	context.SetRoute(&Route{Type: string(StaticRoute), Network: "10.0.0.0/8", NextHop: "192.168.0.1", Port: 1, Metric: 1, CreatedAt: created})
+	context.SetRoute(&Route{Type: string(RIPRoute), Network: "10.1.0.0/16", NextHop: "192.168.0.2", Port: 1})
+	context.SetRoute(&Route{Type: string(StaticRoute), Network: "10.0.0.0/8", NextHop: "192.168.0.1", Port: 2})
+
+	if len(context.Routes) != 2 {
+		t.Fatal("Dynamic routes must not be persisted:", context.Routes)
+	}
+
+	// Metadata of the same route is updated.
+	context.SetRoute(&Route{Type: string(StaticRoute), Network: "10.0.0.0/8", NextHop: "192.168.0.1", Port: 1, Metric: 5, Tag: 7})
+
+	route := context.Routes[0]
+	if len(context.Routes) != 2 || route.Metric != 5 || route.Tag != 7 || !route.CreatedAt.Equal(created) {
+		t.Fatal("Metadata of the route must be updated:", route)
+	}
+
+	if context.Routes[1].CreatedAt.IsZero() {
+		t.Fatal("Time of creation must be set")
+	}
+
+	context.SetRoute(&Route{Type: string(ConnectedRoute), Network: "192.168.0.0/24", Port: 1})
+	context.Routes = append(context.Routes, &Route{Type: string(OSPFRoute), Network: "10.2.0.0/16"})
+
+	// Connected routes are restored first, then routes in order of creation.
+	routes := context.Sorted()
+	if len(routes) != 3 || routes[0].Type != string(ConnectedRoute) || routes[1].Port != 1 || routes[2].Port != 2 {
+		t.Fatal("Routes must be ordered by type and time of creation:", routes)
+	}
+
+	context.DelRoute(&Route{Type: string(StaticRoute), Network: "10.0.0.0/8", NextHop: "192.168.0.1", Port: 2})
+	if len(context.Routes) != 3 {
+		t.Fatal("Route through the port must be deleted:", context.Routes)
+	}
+}
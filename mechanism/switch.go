@@ -1,6 +1,8 @@
 package mech
 
 import (
+	"fmt"
+
 	"github.com/netrack/netrack/logging"
 	"github.com/netrack/openflow"
 )
@@ -28,6 +30,10 @@ type Switch interface {
 	// ID returns switch datapath identifier.
 	ID() string
 
+	// Version returns OpenFlow protocol version
+	// used to communicate with the switch.
+	Version() string
+
 	// Boot performs version negotiation and initial switch
 	// configuration on specified openflow connection. The
 	// very next step on Boot call is to send ofp_hello message back.
@@ -59,6 +65,49 @@ type Switch interface {
 	PortByNumber(uint32) (*SwitchPort, error)
 }
 
+// BootMode describes handling of installed flows on switch boot.
+type BootMode string
+
+const (
+	// BootFlush removes all installed flows on switch boot,
+	// mechanisms install their flows into the empty tables.
+	BootFlush BootMode = "flush"
+
+	// BootReconcile preserves installed flows on switch boot,
+	// only missing and stale flows are updated.
+	BootReconcile BootMode = "reconcile"
+)
+
+// ParseBootMode returns boot mode by its name, empty
+// string is interpreted as a BootFlush mode.
+func ParseBootMode(s string) (BootMode, error) {
+	switch mode := BootMode(s); mode {
+	case "":
+		return BootFlush, nil
+	case BootFlush, BootReconcile:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("switch: unknown boot mode '%s'", s)
+}
+
+// ReconcilingSwitch is the interface implemented by switches,
+// that are able to reconcile installed flows on boot.
+type ReconcilingSwitch interface {
+	Switch
+
+	// BootReconcile performs the same handshake as Boot, but
+	// instead of flushing flow tables it retrieves installed flows.
+	// Flows sent after the boot are compared with installed ones,
+	// and only missing or changed flows are sent to the switch.
+	BootReconcile(of.OFPConn) error
+
+	// Reconcile removes installed flows, that were not sent
+	// since the boot, and completes reconciliation. It is called
+	// only, when controller is allowed to modify the switch.
+	Reconcile() error
+}
+
 // SwitchConstructor is a generic constructor for switches.
 type SwitchConstructor interface {
 	// New creates a new Switch instance.
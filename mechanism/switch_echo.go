@@ -0,0 +1,191 @@
+package mech
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/openflow"
+)
+
+const (
+	// DefaultEchoInterval is a default interval
+	// between ofp_echo_request messages.
+	DefaultEchoInterval = 5 * time.Second
+
+	// DefaultEchoMissLimit is a default number of
+	// missed ofp_echo_reply messages, after which
+	// switch considered dead.
+	DefaultEchoMissLimit = 3
+)
+
+// EchoStats describes switch liveness.
+type EchoStats struct {
+	// Round-trip time of the last echo request.
+	RTT time.Duration
+
+	// Number of subsequently missed echo replies.
+	Missed int
+
+	// Time of the last received echo reply.
+	LastReply time.Time
+}
+
+// echoMonitor periodically sends ofp_echo_request messages
+// to the switch and closes connection after a number of
+// missed ofp_echo_reply messages.
+type echoMonitor struct {
+	// Monitored switch context.
+	c *MechanismContext
+
+	// Interval between echo requests.
+	interval time.Duration
+
+	// Maximum number of missed echo replies.
+	limit int
+
+	stats   EchoStats
+	pending bool
+	reason  string
+	lock    sync.Mutex
+
+	stopCh chan bool
+}
+
+func newEchoMonitor(c *MechanismContext, interval time.Duration, limit int) *echoMonitor {
+	if interval <= 0 {
+		interval = DefaultEchoInterval
+	}
+
+	if limit <= 0 {
+		limit = DefaultEchoMissLimit
+	}
+
+	return &echoMonitor{
+		c:        c,
+		interval: interval,
+		limit:    limit,
+		stopCh:   make(chan bool),
+	}
+}
+
+// Stats returns liveness statistics of the switch.
+func (m *echoMonitor) Stats() EchoStats {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.stats
+}
+
+// Reason returns reason of switch eviction, empty
+// string is returned if switch was not evicted.
+func (m *echoMonitor) Reason() string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.reason
+}
+
+// Start starts sending echo requests in a separate goroutine.
+func (m *echoMonitor) Start() {
+	m.c.Mux.HandleFunc(of.T_ECHO_REPLY, m.echoReplyHandler)
+
+	go m.run()
+}
+
+// Stop stops sending echo requests.
+func (m *echoMonitor) Stop() {
+	close(m.stopCh)
+}
+
+func (m *echoMonitor) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		if !m.tick() {
+			return
+		}
+	}
+}
+
+// tick accounts missed replies and sends the next echo request,
+// false is returned when the switch was evicted.
+func (m *echoMonitor) tick() bool {
+	m.lock.Lock()
+
+	if m.pending {
+		m.stats.Missed++
+	}
+
+	if m.stats.Missed >= m.limit {
+		m.reason = fmt.Sprintf("%d echo replies missed", m.stats.Missed)
+		m.lock.Unlock()
+
+		log.ErrorLogf("switch_echo/ECHO_MISSED",
+			"Switch %s evicted: %s", m.c.Switch.ID(), m.reason)
+
+		// Closed connection forces switch manager to tear the switch down.
+		if err := m.c.Switch.Conn().Close(); err != nil {
+			log.ErrorLog("switch_echo/ECHO_MISSED",
+				"Failed to close switch connection: ", err)
+		}
+
+		return false
+	}
+
+	m.pending = true
+	m.lock.Unlock()
+
+	// Put send time into data field, it will be returned back
+	// by the switch in ofp_echo_reply message.
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, time.Now().UnixNano())
+
+	r, err := of.NewRequest(of.T_ECHO_REQUEST, &buf)
+	if err != nil {
+		log.ErrorLog("switch_echo/ECHO_REQUEST",
+			"Failed to create ofp_echo_request message: ", err)
+		return true
+	}
+
+	if version, ok := WireVersion(m.c.Switch.Version()); ok {
+		r.Header.Set(of.VersionHeaderKey, version)
+	}
+
+	if err = of.Send(m.c.Switch.Conn(), r); err != nil {
+		log.ErrorLog("switch_echo/ECHO_REQUEST",
+			"Failed to send ofp_echo_request message: ", err)
+	}
+
+	return true
+}
+
+func (m *echoMonitor) echoReplyHandler(rw of.ResponseWriter, r *of.Request) {
+	var sent int64
+
+	if err := binary.Read(r.Body, binary.BigEndian, &sent); err != nil {
+		log.ErrorLog("switch_echo/ECHO_REPLY",
+			"Failed to read ofp_echo_reply data: ", err)
+		return
+	}
+
+	now := time.Now()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.stats.RTT = now.Sub(time.Unix(0, sent))
+	m.stats.LastReply = now
+	m.stats.Missed = 0
+	m.pending = false
+}
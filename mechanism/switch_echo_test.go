@@ -0,0 +1,106 @@
+package mech
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/netrack/openflow"
+)
+
+type fakeConn struct {
+	of.OFPConn
+
+	sent   int
+	closed bool
+}
+
+func (c *fakeConn) Send(*of.Request) error {
+	c.sent++
+	return nil
+}
+
+func (c *fakeConn) Flush() error {
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+type fakeSwitch struct {
+	Switch
+
+	conn *fakeConn
+}
+
+func (s *fakeSwitch) ID() string {
+	return "00:00:00:00:00:00:00:01"
+}
+
+func (s *fakeSwitch) Version() string {
+	return "OFP/1.3"
+}
+
+func (s *fakeSwitch) Conn() of.OFPConn {
+	return s.conn
+}
+
+func TestEchoMonitorEviction(t *testing.T) {
+	conn := &fakeConn{}
+	c := &MechanismContext{Switch: &fakeSwitch{conn: conn}}
+
+	monitor := newEchoMonitor(c, time.Second, 2)
+
+	for i := 0; i < 2; i++ {
+		if !monitor.tick() {
+			t.Fatal("Switch evicted too early on tick:", i)
+		}
+	}
+
+	if stats := monitor.Stats(); stats.Missed != 1 {
+		t.Fatal("Invalid number of missed replies:", stats.Missed)
+	}
+
+	if monitor.tick() {
+		t.Fatal("Switch must be evicted")
+	}
+
+	if !conn.closed || monitor.Reason() == "" {
+		t.Fatal("Connection of evicted switch must be closed")
+	}
+
+	if conn.sent != 2 {
+		t.Fatal("Invalid number of echo requests:", conn.sent)
+	}
+}
+
+func TestEchoMonitorReply(t *testing.T) {
+	conn := &fakeConn{}
+	c := &MechanismContext{Switch: &fakeSwitch{conn: conn}}
+
+	monitor := newEchoMonitor(c, time.Second, 2)
+	monitor.tick()
+	monitor.tick()
+
+	var buf bytes.Buffer
+	sent := time.Now().Add(-10 * time.Millisecond)
+	binary.Write(&buf, binary.BigEndian, sent.UnixNano())
+
+	monitor.echoReplyHandler(nil, &of.Request{Body: &buf})
+
+	stats := monitor.Stats()
+	if stats.Missed != 0 {
+		t.Fatal("Missed replies must be reset:", stats.Missed)
+	}
+
+	if stats.RTT < 10*time.Millisecond {
+		t.Fatal("Invalid round-trip time:", stats.RTT)
+	}
+
+	if !monitor.tick() || conn.closed {
+		t.Fatal("Switch must not be evicted after reply")
+	}
+}
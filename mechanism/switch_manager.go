@@ -2,9 +2,14 @@ package mech
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism/election"
 	"github.com/netrack/netrack/mechanism/injector"
 	"github.com/netrack/netrack/mechanism/rpc"
 	"github.com/netrack/openflow"
@@ -13,14 +18,60 @@ import (
 var (
 	// ErrSwitchNotFound is returned when switch is not managed by SwitchManager.
 	ErrSwitchNotFound = errors.New("SwitchManager: switch not found")
+
+	// ErrSwitchDisconnected is returned to the pending
+	// operations, when switch connection is lost.
+	ErrSwitchDisconnected = errors.New("SwitchManager: switch disconnected")
 )
 
+// DisconnectHandlerFunc is a function called after
+// switch disconnection with a reason of disconnection.
+type DisconnectHandlerFunc func(c *MechanismContext, reason string)
+
 // SwitchManager manages switch connections and mechanism
 // drivers associated with each switch.
 type SwitchManager struct {
+	// Interval between ofp_echo_request messages
+	// sent to the switches.
+	EchoInterval time.Duration
+
+	// Number of missed ofp_echo_reply messages,
+	// after which switch will be torn down.
+	EchoMissLimit int
+
+	// Handling of installed flows on switch boot.
+	BootMode BootMode
+
+	// Interval between port statistics requests
+	// sent to the switches.
+	StatsInterval time.Duration
+
+	// Number of port statistics samples stored
+	// in memory for each port.
+	StatsHistoryLen int
+
+	// Save port statistics samples into database.
+	StatsPersist bool
+
+	// Leader election backend, when it is defined, controller
+	// requests master or slave role for each switch.
+	Elector election.Elector
+
+	// Interval between attempts of slave instance to become a master.
+	ElectionInterval time.Duration
+
 	// List of serving switches
 	entries map[string]*MechanismContext
 
+	// Liveness monitors of serving switches
+	monitors map[string]*echoMonitor
+
+	// Port statistics collectors of serving switches
+	collectors map[string]*statsCollector
+
+	// Handlers of switch disconnections
+	disconnectHandlers []DisconnectHandlerFunc
+
 	// Lock for entries list
 	lock sync.RWMutex
 }
@@ -32,6 +83,8 @@ func (m *SwitchManager) init() {
 	// Make lazy intialization
 	if m.entries == nil {
 		m.entries = make(map[string]*MechanismContext)
+		m.monitors = make(map[string]*echoMonitor)
+		m.collectors = make(map[string]*statsCollector)
 	}
 }
 
@@ -48,10 +101,31 @@ func (m *SwitchManager) CreateSwitch(conn of.OFPConn) error {
 		return err
 	}
 
-	constructor := SwitchByVersion(r.Proto)
+	hello, err := NewHello(r)
+	if err != nil {
+		log.ErrorLog("switch_manager/CREATE_SWITCH",
+			"Failed to read ofp_hello elements: ", err)
+		return err
+	}
+
+	// Choose the highest version supported by both sides.
+	version, err := hello.Negotiate(SwitchVersionList())
+	if err != nil {
+		log.ErrorLogf("switch_manager/CREATE_SWITCH",
+			"Failed to negotiate protocol version with %s switch: %s",
+			r.Proto, err)
+
+		m.helloFailed(conn, hello)
+		return err
+	}
+
+	log.DebugLogf("switch_manager/CREATE_SWITCH",
+		"Negotiated protocol version %s with %s switch", version, r.Proto)
+
+	constructor := SwitchByVersion(version)
 	if constructor == nil {
 		log.ErrorLog("switch_manager/CREATE_SWITCH",
-			"Unknown protocol version: ", r.Proto)
+			"Unknown protocol version: ", version)
 
 		return errors.New("SwitchManager: Unknown protocol version")
 	}
@@ -59,23 +133,50 @@ func (m *SwitchManager) CreateSwitch(conn of.OFPConn) error {
 	// Create a new switch instance
 	sw := constructor.New()
 
+	// Not all switches are able to track changes of ports.
+	portSwitch, tracking := sw.(PortStatusSwitch)
+
+	// Flushing of tables on boot would remove flows, installed
+	// by the master instance, so always reconcile with elections.
+	reconciler, reconcile := sw.(ReconcilingSwitch)
+	if m.BootMode != BootReconcile && m.Elector == nil {
+		reconcile = false
+	} else if !reconcile {
+		log.InfoLog("switch_manager/CREATE_SWITCH",
+			"Reconciliation is not supported, flushing flows of ", version)
+	}
+
 	log.DebugLog("switch_manager/CREATE_SWITCH",
 		"Booting switch...")
 
 	// Wait for switch boot up
-	if err = sw.Boot(conn); err != nil {
+	if reconcile {
+		err = reconciler.BootReconcile(conn)
+	} else {
+		err = sw.Boot(conn)
+	}
+
+	if err != nil {
 		log.ErrorLog("switch_manager/CREATE_SWITCH",
 			"Failed to boot switch: ", err)
 		return err
 	}
 
 	log.DebugLog("switch_manager/CREATE_SWITCH",
-		"Switch successfully booted for ", r.Proto)
+		"Switch successfully booted for ", version)
+
+	role := RoleEqual
+
+	if m.Elector != nil {
+		// Mechanisms of slave instance will not modify the switch.
+		rsw := m.electRole(sw)
+		role, sw = rsw.Role(), rsw
+	}
 
 	linkManager := NewLinkMechanismManager()
 
 	extensionManager := &ExtensionMechanismManager{
-		BaseMechanismManager{sw.ID(), ExtensionMechanisms(), 0, 0},
+		BaseMechanismManager{sw.ID(), ExtensionMechanisms(sw.Version()), 0, 0},
 	}
 
 	// Create a new mechanism driver context
@@ -85,6 +186,11 @@ func (m *SwitchManager) CreateSwitch(conn of.OFPConn) error {
 		Mux:       of.NewServeMux(),
 		Extension: extensionManager,
 		Managers:  injector.New(),
+		Ports:     NewPortNotifier(),
+	}
+
+	if tracking {
+		context.Mux.HandleFunc(of.T_PORT_STATUS, m.portStatusHandler(context, portSwitch))
 	}
 
 	linkManager.Enable(context)
@@ -100,14 +206,48 @@ func (m *SwitchManager) CreateSwitch(conn of.OFPConn) error {
 			"Failed to create link configuration: ", err)
 	}
 
+	// All mechanisms installed their flows, so remaining are stale,
+	// but only master is allowed to remove them.
+	if reconcile && role != RoleSlave {
+		if err = reconciler.Reconcile(); err != nil {
+			log.ErrorLog("switch_manager/CREATE_SWITCH",
+				"Failed to reconcile switch flows: ", err)
+		}
+	}
+
 	log.DebugLog("switch_manager/CREATE_SWITCH",
 		"Switch successfully created")
 
+	// Save switch description and connection event.
+	switchConnected(sw, conn)
+
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
 	m.entries[context.Switch.ID()] = context
 
+	// Stop monitoring of the previous switch connection,
+	// it will be torn down on receive failure.
+	if monitor, ok := m.monitors[context.Switch.ID()]; ok {
+		monitor.Stop()
+	}
+
+	// Start monitoring of switch liveness.
+	monitor := newEchoMonitor(context, m.EchoInterval, m.EchoMissLimit)
+	m.monitors[context.Switch.ID()] = monitor
+	monitor.Start()
+
+	if collector, ok := m.collectors[context.Switch.ID()]; ok {
+		collector.Stop()
+	}
+
+	// Start collecting port statistics.
+	collector := newStatsCollector(context,
+		m.StatsInterval, m.StatsHistoryLen, m.StatsPersist)
+
+	m.collectors[context.Switch.ID()] = collector
+	collector.Start()
+
 	// Serve can delete context from entries list,
 	// so call it after adding context to entries list.
 	go m.serve(context)
@@ -115,6 +255,41 @@ func (m *SwitchManager) CreateSwitch(conn of.OFPConn) error {
 	return nil
 }
 
+// helloFailed notifies switch about absence of common protocol
+// version and terminates the connection.
+func (m *SwitchManager) helloFailed(conn of.OFPConn, hello *Hello) {
+	defer conn.Close()
+
+	var versions []string
+	for version := uint8(1); version < 64; version++ {
+		if hello.Supports(version) {
+			versions = append(versions, ProtoVersion(version))
+		}
+	}
+
+	supported := SwitchVersionList()
+	sort.Strings(supported)
+
+	reason := fmt.Sprintf("Incompatible OpenFlow versions, "+
+		"controller supports: %s, switch supports: %s",
+		strings.Join(supported, ", "),
+		strings.Join(versions, ", "))
+
+	// Use version of received ofp_hello message, so
+	// switch would be able to parse the error message.
+	r, err := HelloFailed(hello.Version, reason)
+	if err != nil {
+		log.ErrorLog("switch_manager/HELLO_FAILED",
+			"Failed to create ofp_error_msg request: ", err)
+		return
+	}
+
+	if err = of.Send(conn, r); err != nil {
+		log.ErrorLog("switch_manager/HELLO_FAILED",
+			"Failed to send ofp_error_msg request: ", err)
+	}
+}
+
 // SwitchContext returns switch context of managing switch,
 // ErrSwitchNotFound returned when switch is not managed by SwitchManager.
 func (m *SwitchManager) Context(dpid string) (*MechanismContext, error) {
@@ -145,6 +320,141 @@ func (m *SwitchManager) Context(dpid string) (*MechanismContext, error) {
 	return nil, ErrSwitchNotFound
 }
 
+// EchoStats returns liveness statistics of the managed switch.
+func (m *SwitchManager) EchoStats(dpid string) (EchoStats, error) {
+	m.init()
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if monitor, ok := m.monitors[dpid]; ok {
+		return monitor.Stats(), nil
+	}
+
+	return EchoStats{}, ErrSwitchNotFound
+}
+
+// PortStats returns collected statistics samples of the
+// switch port, the latest samples are at the end.
+func (m *SwitchManager) PortStats(dpid string, port uint32) ([]PortStats, error) {
+	m.init()
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if collector, ok := m.collectors[dpid]; ok {
+		return collector.Stats(port), nil
+	}
+
+	return nil, ErrSwitchNotFound
+}
+
+// portStatusHandler returns handler of ofp_port_status messages, that
+// updates list of switch ports and notifies subscribed mechanisms.
+func (m *SwitchManager) portStatusHandler(c *MechanismContext, sw PortStatusSwitch) of.HandlerFunc {
+	return func(rw of.ResponseWriter, r *of.Request) {
+		event, err := sw.UpdatePort(r)
+		if err != nil {
+			log.ErrorLog("switch_manager/PORT_STATUS",
+				"Failed to update switch port: ", err)
+			return
+		}
+
+		log.InfoLogf("switch_manager/PORT_STATUS",
+			"Port %s of switch %s changed: %s",
+			event.Port.Name, sw.ID(), event.Type)
+
+		// Save updated list of ports.
+		switchPortsChanged(sw)
+
+		c.Ports.Notify(event)
+	}
+}
+
+// HandleDisconnectFunc registers function, that will be called
+// after the switch teardown, when all mechanisms are deactivated.
+func (m *SwitchManager) HandleDisconnectFunc(fn DisconnectHandlerFunc) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.disconnectHandlers = append(m.disconnectHandlers, fn)
+}
+
+// teardown deactivates all mechanisms of the switch and
+// removes switch from the list of managed switches.
+func (m *SwitchManager) teardown(c *MechanismContext, reason string) {
+	dpid := c.Switch.ID()
+
+	m.lock.Lock()
+	monitor := m.monitors[dpid]
+	collector := m.collectors[dpid]
+
+	// Switch could be already reconnected with a new context.
+	current := m.entries[dpid] == c
+	if current {
+		delete(m.entries, dpid)
+		delete(m.monitors, dpid)
+		delete(m.collectors, dpid)
+	}
+
+	if monitor != nil && monitor.c != c {
+		monitor = nil
+	}
+
+	if collector != nil && collector.c != c {
+		collector = nil
+	}
+
+	handlers := make([]DisconnectHandlerFunc, len(m.disconnectHandlers))
+	copy(handlers, m.disconnectHandlers)
+
+	m.lock.Unlock()
+
+	if collector != nil {
+		collector.Stop()
+	}
+
+	if monitor != nil {
+		monitor.Stop()
+
+		// Prefer eviction reason over the connection error.
+		if evicted := monitor.Reason(); evicted != "" {
+			reason = evicted
+		}
+	}
+
+	// Switch connection is lost, so mechanisms are not able to
+	// remove installed flows, just release acquired resources.
+	// Network and routing managers are deactivated by link manager.
+	var link LinkMechanismManager
+	if err := c.Managers.Obtain(&link); err == nil {
+		link.Deactivate()
+	}
+
+	c.Extension.Deactivate()
+
+	// Leadership is shared with a new context on reconnect.
+	if m.Elector != nil {
+		m.resignRole(c, current)
+	}
+
+	// State of the reconnected switch belongs to the new context.
+	if !current {
+		log.InfoLogf("switch_manager/SWITCH_TEARDOWN",
+			"Stale context of switch %s deleted: %s", dpid, reason)
+		return
+	}
+
+	switchDisconnected(c.Switch, reason)
+
+	for _, handler := range handlers {
+		handler(c, reason)
+	}
+
+	log.InfoLogf("switch_manager/SWITCH_TEARDOWN",
+		"Switch %s deleted: %s", dpid, reason)
+}
+
 func (m *SwitchManager) serve(c *MechanismContext) {
 	conn := c.Switch.Conn()
 
@@ -154,17 +464,16 @@ func (m *SwitchManager) serve(c *MechanismContext) {
 			log.ErrorLog("switch_manager/SWITCH_SERVE_ERR",
 				"Failed to receive next OpenFlow message: ", err)
 
-			m.lock.Lock()
-			defer m.lock.Unlock()
-
-			delete(m.entries, c.Switch.ID())
-
-			log.InfoLogf("switch_manager/SWITCH_SERVE",
-				"Switch %s deleted", c.Switch.ID())
-
+			m.teardown(c, err.Error())
 			return
 		}
 
+		// Parts of multipart replies have to be joined in order.
+		if r.Header.Get(of.TypeHeaderKey) == of.T_MULTIPART_REPLY {
+			c.Mux.Serve(&of.Response{Conn: conn}, r)
+			continue
+		}
+
 		go c.Mux.Serve(&of.Response{Conn: conn}, r)
 	}
 }
@@ -0,0 +1,225 @@
+package mech
+
+import (
+	"net"
+	"time"
+
+	"github.com/netrack/netrack/database"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/openflow"
+)
+
+const (
+	// SwitchModel is a database table name (switches)
+	SwitchModel db.Model = "switch"
+
+	// SwitchHistoryLen is a maximum number of
+	// stored switch connection events.
+	SwitchHistoryLen = 100
+)
+
+func init() {
+	// Register model in a database to make it available
+	db.Register(SwitchModel)
+}
+
+// SwitchEventType describes switch connection events.
+type SwitchEventType string
+
+const (
+	// SwitchConnected is an event of switch connection.
+	SwitchConnected SwitchEventType = "connect"
+
+	// SwitchDisconnected is an event of switch disconnection.
+	SwitchDisconnected SwitchEventType = "disconnect"
+)
+
+// SwitchEvent describes a single switch connection event.
+type SwitchEvent struct {
+	// Type of the event.
+	Type SwitchEventType `json:"type"`
+
+	// Reason of the event.
+	Reason string `json:"reason,omitempty"`
+
+	// Switch remote address.
+	RemoteAddr string `json:"remote_addr,omitempty"`
+
+	// Time of the event.
+	Time time.Time `json:"time"`
+}
+
+// SwitchRecord is a persistent description of
+// the switch and its connection history.
+type SwitchRecord struct {
+	// Datapath identifier.
+	Datapath string `json:"id"`
+
+	// Switch name (name of the local port).
+	Name string `json:"name"`
+
+	// Negotiated OpenFlow protocol version.
+	Version string `json:"version"`
+
+	// Switch remote address.
+	RemoteAddr string `json:"remote_addr"`
+
+	// List of switch ports.
+	Ports []SwitchPort `json:"ports"`
+
+	// Time of the last connection.
+	ConnectedAt time.Time `json:"connected_at"`
+
+	// Time of the last disconnection.
+	DisconnectedAt *time.Time `json:"disconnected_at"`
+
+	// Connection history, the latest events are at the end.
+	History []SwitchEvent `json:"history"`
+}
+
+// Connected returns true when switch is currently connected.
+func (r *SwitchRecord) Connected() bool {
+	return r.DisconnectedAt == nil
+}
+
+// addEvent appends event to the history and truncates
+// it to the SwitchHistoryLen elements.
+func (r *SwitchRecord) addEvent(event SwitchEvent) {
+	r.History = append(r.History, event)
+
+	if len(r.History) > SwitchHistoryLen {
+		r.History = r.History[len(r.History)-SwitchHistoryLen:]
+	}
+}
+
+// RemoteAddr returns remote address of OpenFlow connection, if
+// connection is not able to provide it, empty string returned.
+func RemoteAddr(conn of.OFPConn) string {
+	if c, ok := conn.(interface {
+		RemoteAddr() net.Addr
+	}); ok && c.RemoteAddr() != nil {
+		return c.RemoteAddr().String()
+	}
+
+	return ""
+}
+
+// updateSwitchRecord locks switch record and calls fn to update it,
+// record will be created, if it does not exist. Other errors of the
+// lock are returned, so duplicate records are not created.
+func updateSwitchRecord(dpid string, fn func(*SwitchRecord)) error {
+	return db.Transaction(func(p db.ModelPersister) error {
+		var record SwitchRecord
+
+		err := p.Lock(SwitchModel, dpid, &record)
+		if err == db.ErrNoRows {
+			record = SwitchRecord{Datapath: dpid}
+			fn(&record)
+
+			return p.Create(SwitchModel, &record)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fn(&record)
+		return p.Update(SwitchModel, dpid, &record)
+	})
+}
+
+// switchConnected saves switch description and connection event.
+func switchConnected(sw Switch, conn of.OFPConn) {
+	now := time.Now()
+	addr := RemoteAddr(conn)
+
+	var ports []SwitchPort
+	for _, port := range sw.PortList() {
+		ports = append(ports, *port)
+	}
+
+	err := updateSwitchRecord(sw.ID(), func(r *SwitchRecord) {
+		r.Name = sw.Name()
+		r.Version = sw.Version()
+		r.RemoteAddr = addr
+		r.Ports = ports
+		r.ConnectedAt = now
+		r.DisconnectedAt = nil
+
+		r.addEvent(SwitchEvent{
+			Type:       SwitchConnected,
+			RemoteAddr: addr,
+			Time:       now,
+		})
+	})
+
+	if err != nil {
+		log.ErrorLog("switch_registry/SWITCH_CONNECTED",
+			"Failed to save switch connection event: ", err)
+	}
+}
+
+// switchPortsChanged saves updated list of switch ports.
+func switchPortsChanged(sw Switch) {
+	var ports []SwitchPort
+	for _, port := range sw.PortList() {
+		ports = append(ports, *port)
+	}
+
+	err := updateSwitchRecord(sw.ID(), func(r *SwitchRecord) {
+		r.Ports = ports
+	})
+
+	if err != nil {
+		log.ErrorLog("switch_registry/SWITCH_PORTS_CHANGED",
+			"Failed to save switch ports: ", err)
+	}
+}
+
+// switchDisconnected saves switch disconnection event with specified reason.
+func switchDisconnected(sw Switch, reason string) {
+	now := time.Now()
+
+	err := updateSwitchRecord(sw.ID(), func(r *SwitchRecord) {
+		r.DisconnectedAt = &now
+
+		r.addEvent(SwitchEvent{
+			Type:       SwitchDisconnected,
+			Reason:     reason,
+			RemoteAddr: r.RemoteAddr,
+			Time:       now,
+		})
+	})
+
+	if err != nil {
+		log.ErrorLog("switch_registry/SWITCH_DISCONNECTED",
+			"Failed to save switch disconnection event: ", err)
+	}
+}
+
+// SwitchRecords returns descriptions of all switches,
+// that were ever connected to the controller.
+func (m *SwitchManager) SwitchRecords() ([]SwitchRecord, error) {
+	var records []SwitchRecord
+
+	if err := db.List(SwitchModel, &records); err != nil {
+		log.ErrorLog("switch_registry/SWITCH_RECORDS",
+			"Failed to read switch records: ", err)
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// SwitchRecord returns description of the switch by datapath identifier.
+func (m *SwitchManager) SwitchRecord(dpid string) (*SwitchRecord, error) {
+	var record SwitchRecord
+
+	if err := db.Read(SwitchModel, dpid, &record); err != nil {
+		log.ErrorLog("switch_registry/SWITCH_RECORD",
+			"Failed to read switch record: ", err)
+		return nil, err
+	}
+
+	return &record, nil
+}
@@ -0,0 +1,93 @@
+package mech
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/netrack/netrack/database"
+)
+
+func TestSwitchRecordHistory(t *testing.T) {
+	var record SwitchRecord
+
+	for i := 0; i < SwitchHistoryLen+10; i++ {
+		record.addEvent(SwitchEvent{
+			Type: SwitchConnected,
+			Time: time.Unix(int64(i), 0),
+		})
+	}
+
+	if len(record.History) != SwitchHistoryLen {
+		t.Fatal("History is not truncated:", len(record.History))
+	}
+
+	last := record.History[SwitchHistoryLen-1]
+	if last.Time != time.Unix(int64(SwitchHistoryLen+9), 0) {
+		t.Fatal("The latest event is lost:", last)
+	}
+
+	if !record.Connected() {
+		t.Fatal("Switch without disconnection time must be connected")
+	}
+}
+
+// registryPersister is a persister of a single switch record,
+// lock fails with the specified error.
+type registryPersister struct {
+	db.Persister
+	lockErr error
+	created int
+	updated int
+}
+
+func (p *registryPersister) Transaction(fn func(db.ModelPersister) error) error {
+	return fn(p)
+}
+
+func (p *registryPersister) Lock(m db.Model, id string, s interface{}) error {
+	return p.lockErr
+}
+
+func (p *registryPersister) Create(m db.Model, s interface{}) error {
+	p.created++
+	return nil
+}
+
+func (p *registryPersister) Update(m db.Model, id string, s interface{}) error {
+	p.updated++
+	return nil
+}
+
+func TestSwitchRecordUpdate(t *testing.T) {
+	defer func(persister db.Persister) {
+		db.DefaultDB = persister
+	}(db.DefaultDB)
+
+	errConn := errors.New("connection refused")
+
+	tests := []struct {
+		lockErr error
+		created int
+		updated int
+	}{
+		{nil, 0, 1},
+		{db.ErrNoRows, 1, 0},
+		// Records are not duplicated on failures of database.
+		{errConn, 0, 0},
+	}
+
+	for _, test := range tests {
+		persister := &registryPersister{lockErr: test.lockErr}
+		db.DefaultDB = persister
+
+		err := updateSwitchRecord("00:00:00:00:00:00:00:01", func(*SwitchRecord) {})
+		if test.lockErr == errConn && err != errConn {
+			t.Fatal("Error of the lock must be returned:", err)
+		}
+
+		if persister.created != test.created || persister.updated != test.updated {
+			t.Fatal("Invalid modifications of the record:", test.lockErr, persister.created, persister.updated)
+		}
+	}
+}
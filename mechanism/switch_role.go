@@ -0,0 +1,286 @@
+package mech
+
+import (
+	"sync"
+	"time"
+
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism/election"
+	"github.com/netrack/openflow"
+)
+
+// DefaultElectionInterval is a default interval between attempts
+// of slave instance to become a master and leadership checks.
+const DefaultElectionInterval = 5 * time.Second
+
+// Role describes role of the controller for the switch.
+type Role string
+
+const (
+	// RoleEqual is a role of controller with full access to the switch.
+	RoleEqual Role = "equal"
+
+	// RoleMaster is a role of controller with full access
+	// to the switch, only one controller can be a master.
+	RoleMaster Role = "master"
+
+	// RoleSlave is a role of controller with read-only
+	// access to the switch.
+	RoleSlave Role = "slave"
+)
+
+// RoleSwitch is the interface implemented by
+// switches, that support controller roles.
+type RoleSwitch interface {
+	Switch
+
+	// RequestRole sends role request with specified generation
+	// identifier to the switch.
+	RequestRole(Role, uint64) error
+}
+
+// slaveDenied returns true for messages, that
+// modify state of the switch.
+func slaveDenied(r *of.Request) bool {
+	switch r.Header.Get(of.TypeHeaderKey) {
+	case of.T_FLOW_MOD, of.T_GROUP_MOD, of.T_PORT_MOD,
+		of.T_TABLE_MOD, of.T_PACKET_OUT:
+		return true
+	}
+
+	return false
+}
+
+// roleConn suppresses messages, that modify state of the
+// switch, when controller is a slave for the switch.
+type roleConn struct {
+	of.OFPConn
+
+	role Role
+	lock sync.RWMutex
+}
+
+// Role returns role of the controller.
+func (c *roleConn) Role() Role {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.role
+}
+
+// Send implements of.OFPConn interface.
+func (c *roleConn) Send(r *of.Request) error {
+	if c.Role() == RoleSlave && slaveDenied(r) {
+		log.DebugLog("switch_role/SEND",
+			"Message suppressed by slave role: ", r.Header.Get(of.TypeHeaderKey))
+		return nil
+	}
+
+	return c.OFPConn.Send(r)
+}
+
+// roleSwitch overrides connection of the switch, so all
+// mechanisms are sending messages according to the role.
+type roleSwitch struct {
+	Switch
+
+	conn *roleConn
+
+	// Election monitor of the instance.
+	monitor *roleMonitor
+}
+
+// Conn implements Switch interface.
+func (s *roleSwitch) Conn() of.OFPConn {
+	return s.conn
+}
+
+// Role returns role of the controller for the switch.
+func (s *roleSwitch) Role() Role {
+	return s.conn.Role()
+}
+
+// roleMonitor periodically tries to acquire leadership for the
+// switch, master instance confirms, that leadership is still held.
+type roleMonitor struct {
+	sw *roleSwitch
+
+	// Leader election backend.
+	elector election.Elector
+
+	// Interval between attempts.
+	interval time.Duration
+
+	stopCh chan bool
+}
+
+func newRoleMonitor(sw *roleSwitch, elector election.Elector, interval time.Duration) *roleMonitor {
+	if interval <= 0 {
+		interval = DefaultElectionInterval
+	}
+
+	return &roleMonitor{
+		sw:       sw,
+		elector:  elector,
+		interval: interval,
+		stopCh:   make(chan bool),
+	}
+}
+
+// Start starts election attempts in a separate goroutine.
+func (m *roleMonitor) Start() {
+	go m.run()
+}
+
+// Stop stops election attempts.
+func (m *roleMonitor) Stop() {
+	close(m.stopCh)
+}
+
+func (m *roleMonitor) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		if !m.tick() {
+			return
+		}
+	}
+}
+
+// tick tries to acquire leadership, false is returned when the
+// role of instance changed and switch connection was closed.
+func (m *roleMonitor) tick() bool {
+	_, ok, err := m.elector.Elect(m.sw.ID())
+	if err != nil {
+		log.ErrorLog("switch_role/ELECT",
+			"Failed to elect master instance: ", err)
+	}
+
+	if m.sw.Role() == RoleMaster {
+		if ok {
+			return true
+		}
+
+		return m.demote()
+	}
+
+	if !ok {
+		return true
+	}
+
+	log.InfoLogf("switch_role/ELECT",
+		"Instance became a master for switch %s, reconnecting", m.sw.ID())
+
+	// Mechanisms have not installed their flows as a slave,
+	// so force the switch to reconnect to boot as a master.
+	// Leadership is kept, since role of the connection is slave.
+	if err = m.sw.Switch.Conn().Close(); err != nil {
+		log.ErrorLog("switch_role/ELECT",
+			"Failed to close switch connection: ", err)
+	}
+
+	return false
+}
+
+// demote switches role of the instance to slave, when leadership
+// was lost, so the instance stops modifying the switch at once.
+func (m *roleMonitor) demote() bool {
+	m.sw.conn.lock.Lock()
+	m.sw.conn.role = RoleSlave
+	m.sw.conn.lock.Unlock()
+
+	log.InfoLogf("switch_role/ELECT",
+		"Instance lost leadership for switch %s, reconnecting", m.sw.ID())
+
+	// Force the switch to reconnect to boot as a slave.
+	if err := m.sw.Switch.Conn().Close(); err != nil {
+		log.ErrorLog("switch_role/ELECT",
+			"Failed to close switch connection: ", err)
+	}
+
+	return false
+}
+
+// electRole chooses role of the controller for the switch and
+// notifies switch about it, controller becomes a slave, when
+// election failed, to prevent fighting over the flows.
+func (m *SwitchManager) electRole(sw Switch) *roleSwitch {
+	role := RoleSlave
+
+	generation, ok, err := m.Elector.Elect(sw.ID())
+	if err != nil {
+		log.ErrorLog("switch_manager/ELECT_ROLE",
+			"Failed to elect master instance: ", err)
+	}
+
+	if ok {
+		role = RoleMaster
+	} else if generation, err = m.Elector.Generation(sw.ID()); err != nil {
+		log.ErrorLog("switch_manager/ELECT_ROLE",
+			"Failed to retrieve generation identifier: ", err)
+	}
+
+	rsw := &roleSwitch{Switch: sw, conn: &roleConn{OFPConn: sw.Conn(), role: role}}
+
+	if rs, ok := sw.(RoleSwitch); ok {
+		if err = rs.RequestRole(role, generation); err != nil {
+			log.ErrorLog("switch_manager/ELECT_ROLE",
+				"Failed to send role request: ", err)
+		}
+	} else {
+		log.InfoLog("switch_manager/ELECT_ROLE",
+			"Roles are not supported, role applied locally for ", sw.ID())
+	}
+
+	rsw.monitor = newRoleMonitor(rsw, m.Elector, m.ElectionInterval)
+	rsw.monitor.Start()
+
+	log.InfoLogf("switch_manager/ELECT_ROLE",
+		"Instance is a %s for switch %s (generation %d)",
+		role, sw.ID(), generation)
+
+	return rsw
+}
+
+// resignRole stops election attempts and releases leadership
+// of the switch, when instance is a master and resign is true.
+func (m *SwitchManager) resignRole(c *MechanismContext, resign bool) {
+	rsw, ok := c.Switch.(*roleSwitch)
+	if !ok {
+		return
+	}
+
+	if rsw.monitor != nil {
+		rsw.monitor.Stop()
+	}
+
+	if !resign || rsw.Role() != RoleMaster {
+		return
+	}
+
+	if err := m.Elector.Resign(rsw.ID()); err != nil {
+		log.ErrorLog("switch_manager/RESIGN_ROLE",
+			"Failed to resign leadership: ", err)
+	}
+}
+
+// Role returns role of the controller for the managed switch.
+func (m *SwitchManager) Role(dpid string) (Role, error) {
+	c, err := m.Context(dpid)
+	if err != nil {
+		return "", err
+	}
+
+	if rsw, ok := c.Switch.(*roleSwitch); ok {
+		return rsw.Role(), nil
+	}
+
+	return RoleEqual, nil
+}
@@ -0,0 +1,70 @@
+package mech
+
+import (
+	"testing"
+	"time"
+
+	"github.com/netrack/netrack/mechanism/election"
+	"github.com/netrack/openflow"
+)
+
+func TestRoleConnSend(t *testing.T) {
+	flowMod := &of.Request{Header: make(of.Header)}
+	flowMod.Header.Set(of.TypeHeaderKey, of.T_FLOW_MOD)
+
+	echo := &of.Request{Header: make(of.Header)}
+	echo.Header.Set(of.TypeHeaderKey, of.T_ECHO_REQUEST)
+
+	conn := &fakeConn{}
+	rconn := &roleConn{OFPConn: conn, role: RoleSlave}
+
+	rconn.Send(flowMod)
+	rconn.Send(echo)
+
+	if conn.sent != 1 {
+		t.Fatal("Slave must send only non-modifying messages:", conn.sent)
+	}
+
+	conn = &fakeConn{}
+	rconn = &roleConn{OFPConn: conn, role: RoleMaster}
+
+	rconn.Send(flowMod)
+	rconn.Send(echo)
+
+	if conn.sent != 2 {
+		t.Fatal("Master must send all messages:", conn.sent)
+	}
+}
+
+type fakeElector struct {
+	election.Elector
+
+	leader bool
+}
+
+func (e *fakeElector) Elect(key string) (uint64, bool, error) {
+	return 1, e.leader, nil
+}
+
+func TestRoleMonitorDemote(t *testing.T) {
+	conn := &fakeConn{}
+	elector := &fakeElector{leader: true}
+
+	rsw := &roleSwitch{Switch: &fakeSwitch{conn: conn}}
+	rsw.conn = &roleConn{OFPConn: conn, role: RoleMaster}
+
+	monitor := newRoleMonitor(rsw, elector, time.Second)
+	if !monitor.tick() || conn.closed {
+		t.Fatal("Master must keep held leadership")
+	}
+
+	// Leadership is lost, when session of elector terminates.
+	elector.leader = false
+	if monitor.tick() {
+		t.Fatal("Master must be demoted")
+	}
+
+	if rsw.Role() != RoleSlave || !conn.closed {
+		t.Fatal("Demoted master must reconnect as a slave:", rsw.Role())
+	}
+}
@@ -0,0 +1,243 @@
+package mech
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultLinkTimeout is a default time after which
+// not confirmed link between switches is removed.
+const DefaultLinkTimeout = 15 * time.Second
+
+// DefaultLinkCost is a cost of link without explicitly defined cost.
+const DefaultLinkCost = 1
+
+// DefaultTopology is a controller-wide graph of switches.
+var DefaultTopology = NewTopology()
+
+// TopologyLink is a directed adjacency between ports of two switches.
+type TopologyLink struct {
+	// Datapath identifier of the source switch.
+	SrcDatapath string
+
+	// Port number of the source switch.
+	SrcPort uint32
+
+	// Datapath identifier of the destination switch.
+	DstDatapath string
+
+	// Port number of the destination switch.
+	DstPort uint32
+
+	// Cost of the link used for path computation,
+	// DefaultLinkCost is used when cost is zero.
+	Cost uint32
+
+	// Time of the last link confirmation.
+	LastSeen time.Time
+}
+
+func (l *TopologyLink) key() string {
+	return fmt.Sprintf("%s/%d", l.SrcDatapath, l.SrcPort)
+}
+
+// Weight returns cost of the link used for path computation.
+func (l *TopologyLink) Weight() uint32 {
+	if l.Cost == 0 {
+		return DefaultLinkCost
+	}
+
+	return l.Cost
+}
+
+// LinkHandlerFunc is a function called on link removal.
+type LinkHandlerFunc func(TopologyLink)
+
+// Topology is a graph of switch-to-switch adjacencies,
+// switches are nodes and links are directed edges.
+type Topology struct {
+	// Time after which not confirmed link is removed.
+	Timeout time.Duration
+
+	nodes map[string]bool
+	links map[string]*TopologyLink
+	lock  sync.RWMutex
+
+	// Handlers of link removals.
+	handlers     []LinkHandlerFunc
+	handlersLock sync.RWMutex
+}
+
+// NewTopology creates a new instance of Topology type.
+func NewTopology() *Topology {
+	return &Topology{
+		Timeout: DefaultLinkTimeout,
+		nodes:   make(map[string]bool),
+		links:   make(map[string]*TopologyLink),
+	}
+}
+
+// AddNode adds switch to the graph.
+func (t *Topology) AddNode(dpid string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.nodes[dpid] = true
+}
+
+// HandleLinkDownFunc registers function, that will be
+// called for each link removed from the graph.
+func (t *Topology) HandleLinkDownFunc(fn LinkHandlerFunc) {
+	t.handlersLock.Lock()
+	defer t.handlersLock.Unlock()
+
+	t.handlers = append(t.handlers, fn)
+}
+
+// notify calls handlers of link removals, it
+// must be called without holding the graph lock.
+func (t *Topology) notify(links []TopologyLink) {
+	t.handlersLock.RLock()
+	handlers := make([]LinkHandlerFunc, len(t.handlers))
+	copy(handlers, t.handlers)
+	t.handlersLock.RUnlock()
+
+	for _, link := range links {
+		for _, handler := range handlers {
+			handler(link)
+		}
+	}
+}
+
+// deleteLinks removes links matching the function from the graph.
+func (t *Topology) deleteLinks(fn func(*TopologyLink) bool) []TopologyLink {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var removed []TopologyLink
+
+	for key, link := range t.links {
+		if fn(link) {
+			removed = append(removed, *link)
+			delete(t.links, key)
+		}
+	}
+
+	sort.Sort(topologyLinks(removed))
+	return removed
+}
+
+// DeleteNode removes switch and all its links from the graph.
+func (t *Topology) DeleteNode(dpid string) {
+	t.lock.Lock()
+	delete(t.nodes, dpid)
+	t.lock.Unlock()
+
+	t.notify(t.deleteLinks(func(link *TopologyLink) bool {
+		return link.SrcDatapath == dpid || link.DstDatapath == dpid
+	}))
+}
+
+// DeletePort removes links attached to the switch port.
+func (t *Topology) DeletePort(dpid string, port uint32) {
+	t.notify(t.deleteLinks(func(link *TopologyLink) bool {
+		return (link.SrcDatapath == dpid && link.SrcPort == port) ||
+			(link.DstDatapath == dpid && link.DstPort == port)
+	}))
+}
+
+// UpdateLink adds a new link or confirms existing one,
+// only one link could be attached to the source port.
+func (t *Topology) UpdateLink(link TopologyLink) {
+	t.lock.Lock()
+
+	t.nodes[link.SrcDatapath] = true
+	t.nodes[link.DstDatapath] = true
+
+	// Port could be reconnected to another switch.
+	var removed []TopologyLink
+	if prev, ok := t.links[link.key()]; ok {
+		if prev.DstDatapath != link.DstDatapath || prev.DstPort != link.DstPort {
+			removed = append(removed, *prev)
+		} else if link.Cost == 0 {
+			// Keep cost of the confirmed link.
+			link.Cost = prev.Cost
+		}
+	}
+
+	t.links[link.key()] = &link
+	t.lock.Unlock()
+
+	t.notify(removed)
+}
+
+// SetCost sets cost of the link attached to the source port, false
+// is returned when there is no link attached to the port.
+func (t *Topology) SetCost(dpid string, port, cost uint32) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := (&TopologyLink{SrcDatapath: dpid, SrcPort: port}).key()
+
+	link, ok := t.links[key]
+	if ok {
+		link.Cost = cost
+	}
+
+	return ok
+}
+
+// Expire removes links, that were not confirmed in time.
+func (t *Topology) Expire(now time.Time) {
+	t.notify(t.deleteLinks(func(link *TopologyLink) bool {
+		return now.Sub(link.LastSeen) > t.Timeout
+	}))
+}
+
+// Nodes returns sorted list of datapath identifiers of switches.
+func (t *Topology) Nodes() []string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var nodes []string
+	for dpid := range t.nodes {
+		nodes = append(nodes, dpid)
+	}
+
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Links returns list of links sorted by source switch and port.
+func (t *Topology) Links() []TopologyLink {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	links := make([]TopologyLink, 0, len(t.links))
+	for _, link := range t.links {
+		links = append(links, *link)
+	}
+
+	sort.Sort(topologyLinks(links))
+	return links
+}
+
+type topologyLinks []TopologyLink
+
+func (l topologyLinks) Len() int {
+	return len(l)
+}
+
+func (l topologyLinks) Less(i, j int) bool {
+	if l[i].SrcDatapath != l[j].SrcDatapath {
+		return l[i].SrcDatapath < l[j].SrcDatapath
+	}
+
+	return l[i].SrcPort < l[j].SrcPort
+}
+
+func (l topologyLinks) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
@@ -0,0 +1,54 @@
+package mech
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTopology(t *testing.T) {
+	topology := NewTopology()
+	topology.AddNode("00:01")
+
+	var removed []TopologyLink
+	topology.HandleLinkDownFunc(func(link TopologyLink) {
+		removed = append(removed, link)
+	})
+
+	now := time.Now()
+
+	topology.UpdateLink(TopologyLink{"00:02", 1, "00:01", 2, 0, now})
+	topology.UpdateLink(TopologyLink{"00:01", 2, "00:02", 1, 0, now})
+	topology.UpdateLink(TopologyLink{"00:02", 3, "00:03", 1, 0, now.Add(-time.Minute)})
+
+	if nodes := topology.Nodes(); len(nodes) != 3 || nodes[0] != "00:01" {
+		t.Fatal("Invalid list of nodes:", nodes)
+	}
+
+	links := topology.Links()
+	if len(links) != 3 || links[0].SrcDatapath != "00:01" || links[2].SrcPort != 3 {
+		t.Fatal("Invalid list of links:", links)
+	}
+
+	topology.Expire(now)
+	if links = topology.Links(); len(links) != 2 {
+		t.Fatal("Expired link must be removed:", links)
+	}
+
+	if len(removed) != 1 || removed[0].SrcPort != 3 {
+		t.Fatal("Handlers must be notified about expired link:", removed)
+	}
+
+	topology.DeletePort("00:01", 2)
+	if links = topology.Links(); len(links) != 0 {
+		t.Fatal("Links of deleted port must be removed:", links)
+	}
+
+	if len(removed) != 3 {
+		t.Fatal("Handlers must be notified about removed links:", removed)
+	}
+
+	topology.DeleteNode("00:03")
+	if nodes := topology.Nodes(); len(nodes) != 2 {
+		t.Fatal("Deleted node must be removed:", nodes)
+	}
+}
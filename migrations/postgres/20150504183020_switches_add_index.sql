@@ -0,0 +1,8 @@
+
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE UNIQUE INDEX idxswitchid ON switches USING btree ((switch->>'id'));
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS idxswitchid;
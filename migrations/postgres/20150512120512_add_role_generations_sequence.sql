@@ -0,0 +1,9 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE SEQUENCE role_generations;
+CREATE TABLE roles (id VARCHAR(32) PRIMARY KEY, generation BIGINT NOT NULL);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE roles;
+DROP SEQUENCE role_generations;
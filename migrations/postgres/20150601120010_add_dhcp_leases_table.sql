@@ -0,0 +1,8 @@
+
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE dhcp_leases (dhcp_lease json);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE dhcp_leases;
@@ -0,0 +1,8 @@
+
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE INDEX idxdhcpleaseid ON dhcp_leases USING btree ((dhcp_lease->>'id'));
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS idxdhcpleaseid;
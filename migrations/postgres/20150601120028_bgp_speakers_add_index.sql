@@ -0,0 +1,8 @@
+
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE INDEX idxbgpspeakerid ON bgp_speakers USING btree ((bgp_speaker->>'id'));
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS idxbgpspeakerid;
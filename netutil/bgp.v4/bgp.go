@@ -0,0 +1,458 @@
+package bgp
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/netrack/netrack/database"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ofp.v13"
+)
+
+const BGPMechanismName = "bgp"
+
+func init() {
+	constructor := mech.RoutingMechanismConstructorFunc(NewBGPMechanism)
+	mech.RegisterRoutingMechanism(BGPMechanismName, constructor)
+}
+
+// isIPv4 reports whether address belongs to IPv4 network.
+func isIPv4(addr mech.NetworkAddr) bool {
+	return len(addr.Bytes()) == net.IPv4len
+}
+
+// connected is an interface address of the switch port.
+type connected struct {
+	addr net.IPNet
+	port uint32
+}
+
+// installed is a best path installed to the routing tables.
+type installed struct {
+	network net.IPNet
+	nexthop net.IP
+	port    uint32
+}
+
+// BGPMechanism runs external BGP speaker of the switch: sessions
+// to neighbors are opened from the controller host, best paths with
+// next hops on the connected networks are installed to the routing
+// tables of other mechanisms.
+type BGPMechanism struct {
+	mech.BaseRoutingMechanism
+
+	// BGP speaker, nil when BGP is not configured.
+	speaker *Speaker
+	config  *mech.BGPConfig
+
+	// Interface addresses of the switch ports.
+	connected map[string]connected
+
+	// Routes installed to the routing tables.
+	installed map[string]installed
+
+	lock sync.RWMutex
+
+	// Serializes configuration changes.
+	configLock sync.Mutex
+
+	// Serializes updates of the routing tables.
+	syncLock sync.Mutex
+}
+
+func NewBGPMechanism() mech.RoutingMechanism {
+	return &BGPMechanism{
+		connected: make(map[string]connected),
+		installed: make(map[string]installed),
+	}
+}
+
+func (m *BGPMechanism) Name() string {
+	return BGPMechanismName
+}
+
+func (m *BGPMechanism) Description() string {
+	return "BGP-4 external routing protocol"
+}
+
+// Version implements VersionedMechanism interface.
+func (m *BGPMechanism) Version() string {
+	return ofp13.ProtoVersion
+}
+
+// BGPConfig implements BGPSpeaker interface.
+func (m *BGPMechanism) BGPConfig() *mech.BGPConfig {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.config
+}
+
+// BGPNeighbors implements BGPSpeaker interface.
+func (m *BGPMechanism) BGPNeighbors() []mech.BGPNeighbor {
+	m.lock.RLock()
+	speaker := m.speaker
+	m.lock.RUnlock()
+
+	neighbors := make([]mech.BGPNeighbor, 0)
+	if speaker == nil {
+		return neighbors
+	}
+
+	now := time.Now()
+	for _, nbr := range speaker.Neighbors() {
+		neighbor := mech.BGPNeighbor{
+			Addr:     nbr.Addr.String(),
+			AS:       nbr.AS,
+			State:    nbr.State.String(),
+			Prefixes: nbr.Prefixes,
+		}
+
+		if nbr.ID != nil {
+			neighbor.RouterID = nbr.ID.String()
+		}
+
+		if nbr.State == Established {
+			neighbor.Uptime = uint32(now.Sub(nbr.Established) / time.Second)
+		}
+
+		neighbors = append(neighbors, neighbor)
+	}
+
+	return neighbors
+}
+
+// UpdateBGP implements BGPSpeaker interface.
+func (m *BGPMechanism) UpdateBGP(config *mech.BGPConfig) error {
+	context := mech.BGPContext{m.C.Switch.ID(), config}
+
+	if err := db.Update(mech.BGPModel, context.Datapath, &context); err != nil {
+		log.ErrorLog("bgp/UPDATE_BGP",
+			"Failed to save BGP configuration: ", err)
+		return err
+	}
+
+	m.configure(config)
+	return nil
+}
+
+// restore loads persisted configuration of the switch.
+func (m *BGPMechanism) restore() {
+	var context mech.BGPContext
+
+	err := db.Transaction(func(p db.ModelPersister) error {
+		err := p.Lock(mech.BGPModel, m.C.Switch.ID(), &context)
+		if err == nil {
+			return nil
+		}
+
+		// Create a new record for a new switch.
+		return p.Create(mech.BGPModel, map[string]string{"id": m.C.Switch.ID()})
+	})
+
+	if err != nil {
+		log.ErrorLog("bgp/RESTORE",
+			"Failed to restore BGP configuration: ", err)
+		return
+	}
+
+	m.configure(context.Config)
+}
+
+// Enable implements Mechanism interface.
+func (m *BGPMechanism) Enable(c *mech.MechanismContext) {
+	m.BaseRoutingMechanism.Enable(c)
+
+	// Expose configuration and neighbors.
+	m.C.Managers.Bind(new(mech.BGPSpeaker), m)
+
+	log.InfoLog("bgp/ENABLE_HOOK", "Mechanism BGP enabled")
+}
+
+// Activate implements Mechanism interface.
+func (m *BGPMechanism) Activate() {
+	m.BaseRoutingMechanism.Activate()
+
+	m.restore()
+}
+
+// Disable implements Mechanism interface.
+func (m *BGPMechanism) Disable() {
+	m.BaseRoutingMechanism.Disable()
+
+	m.configure(nil)
+	m.C.Managers.Unbind(new(mech.BGPSpeaker))
+
+	log.InfoLog("bgp/DISABLE_HOOK", "Mechanism BGP disabled")
+}
+
+// Deactivate implements Mechanism interface.
+func (m *BGPMechanism) Deactivate() {
+	m.BaseRoutingMechanism.Deactivate()
+
+	m.configure(nil)
+	m.C.Managers.Unbind(new(mech.BGPSpeaker))
+
+	// Installed routes are gone along with the switch.
+	m.syncLock.Lock()
+	m.installed = make(map[string]installed)
+	m.syncLock.Unlock()
+
+	m.lock.Lock()
+	m.connected = make(map[string]connected)
+	m.lock.Unlock()
+
+	log.InfoLog("bgp/DEACTIVATE_HOOK", "Mechanism BGP deactivated")
+}
+
+// newSpeaker returns speaker of the configuration.
+func (m *BGPMechanism) newSpeaker(config *mech.BGPConfig) *Speaker {
+	speaker := NewSpeaker(config.AS, net.ParseIP(config.RouterID).To4())
+	if config.HoldTime != 0 {
+		speaker.HoldTime = time.Duration(config.HoldTime) * time.Second
+	}
+
+	speaker.LocalAddr = m.localAddr
+	speaker.Learn = func(Route) { m.sync() }
+	speaker.Forget = func(Route) { m.sync() }
+
+	return speaker
+}
+
+// configure applies configuration of the speaker, sessions are
+// restarted, when autonomous system or identifier are changed.
+func (m *BGPMechanism) configure(config *mech.BGPConfig) {
+	m.configLock.Lock()
+	defer m.configLock.Unlock()
+
+	m.lock.RLock()
+	speaker, prev := m.speaker, m.config
+	m.lock.RUnlock()
+
+	if speaker != nil && (config == nil || config.AS != prev.AS ||
+		config.RouterID != prev.RouterID || config.HoldTime != prev.HoldTime) {
+		speaker.Stop()
+		speaker, prev = nil, nil
+	}
+
+	if config != nil && speaker == nil {
+		speaker = m.newSpeaker(config)
+		log.InfoLogf("bgp/CONFIGURE",
+			"Starting BGP speaker of AS %d", config.AS)
+	}
+
+	if prev != nil {
+		for _, nbr := range prev.Neighbors {
+			if !containsNeighbor(config.Neighbors, nbr) {
+				speaker.DeleteNeighbor(net.ParseIP(nbr.Addr))
+			}
+		}
+	}
+
+	m.lock.Lock()
+	m.speaker, m.config = speaker, config
+	m.lock.Unlock()
+
+	if speaker != nil {
+		speaker.Advertise(parseNetworks(config.Networks))
+
+		for _, nbr := range config.Neighbors {
+			if addr := net.ParseIP(nbr.Addr).To4(); addr != nil {
+				speaker.AddNeighbor(addr, nbr.AS)
+			}
+		}
+	}
+
+	m.sync()
+}
+
+// containsNeighbor reports whether neighbor is in the list.
+func containsNeighbor(neighbors []mech.BGPNeighborConfig, neighbor mech.BGPNeighborConfig) bool {
+	for _, nbr := range neighbors {
+		if nbr == neighbor {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseNetworks returns valid IPv4 networks of the list.
+func parseNetworks(networks []string) []net.IPNet {
+	var parsed []net.IPNet
+	for _, network := range networks {
+		_, ipnet, err := net.ParseCIDR(network)
+		if err == nil && ipnet.IP.To4() != nil {
+			parsed = append(parsed, *ipnet)
+		}
+	}
+
+	return parsed
+}
+
+// localAddr returns interface address on the network of the peer.
+func (m *BGPMechanism) localAddr(peer net.IP) net.IP {
+	if c, ok := m.resolve(peer); ok {
+		return c.addr.IP
+	}
+
+	return nil
+}
+
+// resolve returns connected interface of the next hop address.
+func (m *BGPMechanism) resolve(nexthop net.IP) (connected, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	for _, c := range m.connected {
+		if c.addr.Contains(nexthop) {
+			return c, true
+		}
+	}
+
+	return connected{}, false
+}
+
+// UpdateRoute tracks networks connected to the switch
+// to resolve next hops of the received routes.
+func (m *BGPMechanism) UpdateRoute(context *mech.RoutingContext) error {
+	if context.Type != mech.ConnectedRoute || !isIPv4(context.Network) {
+		return nil
+	}
+
+	addr := net.IPNet{
+		IP:   net.IP(context.Network.Bytes()),
+		Mask: net.IPMask(context.Network.Mask().Bytes()),
+	}
+
+	m.lock.Lock()
+	m.connected[addr.String()] = connected{addr, context.Port}
+	m.lock.Unlock()
+
+	// Routing tables are updated outside of the routing
+	// manager call, next hops may become reachable.
+	go m.sync()
+	return nil
+}
+
+// DeleteRoute stops tracking of the disconnected network.
+func (m *BGPMechanism) DeleteRoute(context *mech.RoutingContext) error {
+	if context.Type != mech.ConnectedRoute || !isIPv4(context.Network) {
+		return nil
+	}
+
+	addr := net.IPNet{
+		IP:   net.IP(context.Network.Bytes()),
+		Mask: net.IPMask(context.Network.Mask().Bytes()),
+	}
+
+	m.lock.Lock()
+	delete(m.connected, addr.String())
+	m.lock.Unlock()
+
+	go m.sync()
+	return nil
+}
+
+// sync installs best paths of the speaker with resolved next
+// hops to the routing tables and removes the stale ones.
+func (m *BGPMechanism) sync() {
+	m.syncLock.Lock()
+	defer m.syncLock.Unlock()
+
+	m.lock.RLock()
+	speaker := m.speaker
+	m.lock.RUnlock()
+
+	selected := make(map[string]installed)
+	if speaker != nil && m.Activated() {
+		for _, route := range speaker.Routes() {
+			if c, ok := m.resolve(route.NextHop); ok {
+				key := route.Network.String()
+				selected[key] = installed{route.Network, route.NextHop, c.port}
+			}
+		}
+	}
+
+	for key, route := range m.installed {
+		if r, ok := selected[key]; !ok || !r.nexthop.Equal(route.nexthop) || r.port != route.port {
+			m.forget(route)
+			delete(m.installed, key)
+		}
+	}
+
+	for key, route := range selected {
+		if _, ok := m.installed[key]; !ok {
+			m.learn(route)
+			m.installed[key] = route
+		}
+	}
+}
+
+// routingContext returns routing context of the best path.
+func (m *BGPMechanism) routingContext(route installed) (*mech.RoutingContext, error) {
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		return nil, err
+	}
+
+	context := &mech.RoutingContext{
+		Type:    mech.BGPRoute,
+		Network: nldriver.CreateAddr(route.network.IP, route.network.Mask),
+		NextHop: nldriver.CreateAddr(route.nexthop, nil),
+		Driver:  nldriver,
+		Port:    route.port,
+	}
+
+	return context, nil
+}
+
+// routing returns routing mechanism manager of the switch.
+func (m *BGPMechanism) routing() (mech.RoutingMechanismManager, error) {
+	var routing mech.RoutingMechanismManager
+	err := m.C.Managers.Obtain(&routing)
+	return routing, err
+}
+
+// learn installs best path to the routing tables.
+func (m *BGPMechanism) learn(route installed) {
+	context, err := m.routingContext(route)
+	if err != nil {
+		return
+	}
+
+	routing, err := m.routing()
+	if err != nil {
+		log.ErrorLog("bgp/LEARN_ROUTE",
+			"Failed to obtain routing manager: ", err)
+		return
+	}
+
+	if err = routing.LearnRoute(context); err != nil {
+		log.ErrorLog("bgp/LEARN_ROUTE",
+			"Failed to install best path: ", err)
+	}
+}
+
+// forget removes withdrawn path from the routing tables.
+func (m *BGPMechanism) forget(route installed) {
+	context, err := m.routingContext(route)
+	if err != nil {
+		return
+	}
+
+	routing, err := m.routing()
+	if err != nil {
+		log.ErrorLog("bgp/FORGET_ROUTE",
+			"Failed to obtain routing manager: ", err)
+		return
+	}
+
+	if err = routing.ForgetRoute(context); err != nil {
+		log.ErrorLog("bgp/FORGET_ROUTE",
+			"Failed to remove withdrawn path: ", err)
+	}
+}
@@ -0,0 +1,543 @@
+package bgp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	// Port is a TCP port of BGP speakers.
+	Port = 179
+
+	// Version is a version of BGP protocol.
+	Version = 4
+
+	// MaxMessageLen is a maximum length of BGP message.
+	MaxMessageLen = 4096
+)
+
+// Type is a type of BGP message.
+type Type uint8
+
+const (
+	OpenType Type = 1 + iota
+	UpdateType
+	NotificationType
+	KeepaliveType
+)
+
+// Origin is an origin of the path information.
+type Origin uint8
+
+const (
+	OriginIGP Origin = iota
+	OriginEGP
+	OriginIncomplete
+)
+
+// Types of AS path segments.
+const (
+	ASSet      = 1
+	ASSequence = 2
+)
+
+// Types of path attributes.
+const (
+	attrOrigin    = 1
+	attrASPath    = 2
+	attrNextHop   = 3
+	attrMED       = 4
+	attrLocalPref = 5
+)
+
+// Flags of path attributes.
+const (
+	flagExtended   = 0x10
+	flagPartial    = 0x20
+	flagTransitive = 0x40
+	flagOptional   = 0x80
+)
+
+const (
+	// headerLen is a length of message header.
+	headerLen = 19
+
+	// openLen is a length of open message without optional parameters.
+	openLen = 10
+)
+
+// Error codes of notification messages.
+const (
+	MessageHeaderError = 1 + iota
+	OpenMessageError
+	UpdateMessageError
+	HoldTimerExpired
+	FSMError
+	Cease
+)
+
+// Error is an error, that is reported to the peer with notification.
+type Error struct {
+	Code    uint8
+	Subcode uint8
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("bgp: error code %d, subcode %d", e.Code, e.Subcode)
+}
+
+var (
+	// errHeader is returned on messages with malformed header.
+	errHeader = &Error{MessageHeaderError, 2}
+
+	// errAttrs is returned on malformed attribute list.
+	errAttrs = &Error{UpdateMessageError, 1}
+
+	// errMissing is returned, when mandatory attribute is missing.
+	errMissing = &Error{UpdateMessageError, 3}
+
+	// errNLRI is returned on invalid network prefixes.
+	errNLRI = &Error{UpdateMessageError, 10}
+)
+
+// Message is a BGP message.
+type Message interface {
+	// Type returns type of the message.
+	Type() Type
+
+	marshal() []byte
+	unmarshal([]byte) error
+}
+
+// Open is a message, that opens the session.
+type Open struct {
+	// Protocol version.
+	Version uint8
+
+	// Autonomous system of the sender.
+	AS uint16
+
+	// Proposed hold time in seconds.
+	HoldTime uint16
+
+	// BGP identifier of the sender.
+	ID net.IP
+}
+
+func (o *Open) Type() Type {
+	return OpenType
+}
+
+func (o *Open) marshal() []byte {
+	b := make([]byte, openLen)
+
+	b[0] = o.Version
+	binary.BigEndian.PutUint16(b[1:3], o.AS)
+	binary.BigEndian.PutUint16(b[3:5], o.HoldTime)
+	copy(b[5:9], o.ID.To4())
+
+	return b
+}
+
+func (o *Open) unmarshal(b []byte) error {
+	if len(b) < openLen || len(b) != openLen+int(b[9]) {
+		return &Error{MessageHeaderError, 2}
+	}
+
+	o.Version = b[0]
+	o.AS = binary.BigEndian.Uint16(b[1:3])
+	o.HoldTime = binary.BigEndian.Uint16(b[3:5])
+	o.ID = net.IP(append([]byte(nil), b[5:9]...))
+
+	// Optional parameters are ignored.
+	return nil
+}
+
+// ASSegment is a segment of AS path.
+type ASSegment struct {
+	Type uint8
+	ASNs []uint16
+}
+
+// Attrs are path attributes of the advertised routes.
+type Attrs struct {
+	// Origin of the path information.
+	Origin Origin
+
+	// Autonomous systems the route passed through.
+	ASPath []ASSegment
+
+	// Address of the router to use as next hop.
+	NextHop net.IP
+
+	// Multi-exit discriminator, if present.
+	MED    uint32
+	HasMED bool
+}
+
+// PathLen returns length of AS path, AS set is counted as a single AS.
+func (a *Attrs) PathLen() int {
+	var n int
+	for _, segment := range a.ASPath {
+		if segment.Type == ASSet {
+			n++
+		} else {
+			n += len(segment.ASNs)
+		}
+	}
+
+	return n
+}
+
+// FirstAS returns the neighbor autonomous system of the path.
+func (a *Attrs) FirstAS() uint16 {
+	if len(a.ASPath) == 0 || a.ASPath[0].Type != ASSequence || len(a.ASPath[0].ASNs) == 0 {
+		return 0
+	}
+
+	return a.ASPath[0].ASNs[0]
+}
+
+// Contains reports whether AS path contains autonomous system.
+func (a *Attrs) Contains(as uint16) bool {
+	for _, segment := range a.ASPath {
+		for _, asn := range segment.ASNs {
+			if asn == as {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// attr returns encoded path attribute.
+func attr(flags, code uint8, value []byte) []byte {
+	if len(value) > 0xff {
+		b := []byte{flags | flagExtended, code, 0, 0}
+		binary.BigEndian.PutUint16(b[2:4], uint16(len(value)))
+		return append(b, value...)
+	}
+
+	return append([]byte{flags, code, uint8(len(value))}, value...)
+}
+
+func (a *Attrs) marshal() []byte {
+	b := attr(flagTransitive, attrOrigin, []byte{uint8(a.Origin)})
+
+	var path []byte
+	for _, segment := range a.ASPath {
+		path = append(path, segment.Type, uint8(len(segment.ASNs)))
+		for _, asn := range segment.ASNs {
+			path = append(path, uint8(asn>>8), uint8(asn))
+		}
+	}
+
+	b = append(b, attr(flagTransitive, attrASPath, path)...)
+	b = append(b, attr(flagTransitive, attrNextHop, a.NextHop.To4())...)
+
+	if a.HasMED {
+		med := make([]byte, 4)
+		binary.BigEndian.PutUint32(med, a.MED)
+		b = append(b, attr(flagOptional, attrMED, med)...)
+	}
+
+	return b
+}
+
+func (a *Attrs) unmarshal(b []byte) error {
+	var seen [attrLocalPref + 1]bool
+
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return errAttrs
+		}
+
+		flags, code := b[0], b[1]
+
+		n, hlen := int(b[2]), 3
+		if flags&flagExtended != 0 {
+			if len(b) < 4 {
+				return errAttrs
+			}
+
+			n, hlen = int(binary.BigEndian.Uint16(b[2:4])), 4
+		}
+
+		if len(b) < hlen+n {
+			return errAttrs
+		}
+
+		value := b[hlen : hlen+n]
+		b = b[hlen+n:]
+
+		if int(code) < len(seen) {
+			if seen[code] {
+				return errAttrs
+			}
+
+			seen[code] = true
+		}
+
+		switch code {
+		case attrOrigin:
+			if n != 1 || value[0] > uint8(OriginIncomplete) {
+				return &Error{UpdateMessageError, 6}
+			}
+
+			a.Origin = Origin(value[0])
+		case attrASPath:
+			a.ASPath = nil
+
+			for len(value) > 0 {
+				if len(value) < 2 || len(value) < 2+int(value[1])*2 {
+					return &Error{UpdateMessageError, 11}
+				}
+
+				segment := ASSegment{Type: value[0]}
+				for i := 0; i < int(value[1]); i++ {
+					segment.ASNs = append(segment.ASNs, binary.BigEndian.Uint16(value[2+i*2:]))
+				}
+
+				a.ASPath = append(a.ASPath, segment)
+				value = value[2+int(value[1])*2:]
+			}
+		case attrNextHop:
+			if n != net.IPv4len {
+				return &Error{UpdateMessageError, 8}
+			}
+
+			a.NextHop = net.IP(append([]byte(nil), value...))
+		case attrMED:
+			if n != 4 {
+				return errAttrs
+			}
+
+			a.MED, a.HasMED = binary.BigEndian.Uint32(value), true
+		}
+
+		// Local preference and unrecognized attributes are
+		// not used by external speaker and ignored.
+	}
+
+	if !seen[attrOrigin] || !seen[attrASPath] || !seen[attrNextHop] {
+		return errMissing
+	}
+
+	return nil
+}
+
+// Update is a message, that advertises and withdraws routes.
+type Update struct {
+	// Routes, that are not reachable anymore.
+	Withdrawn []net.IPNet
+
+	// Path attributes of advertised routes.
+	Attrs *Attrs
+
+	// Advertised routes.
+	NLRI []net.IPNet
+}
+
+func (u *Update) Type() Type {
+	return UpdateType
+}
+
+// marshalPrefixes returns encoded list of network prefixes.
+func marshalPrefixes(prefixes []net.IPNet) []byte {
+	var b []byte
+	for _, prefix := range prefixes {
+		ones, _ := prefix.Mask.Size()
+		b = append(b, uint8(ones))
+		b = append(b, prefix.IP.To4()[:(ones+7)/8]...)
+	}
+
+	return b
+}
+
+// unmarshalPrefixes returns list of network prefixes.
+func unmarshalPrefixes(b []byte) ([]net.IPNet, error) {
+	var prefixes []net.IPNet
+
+	for len(b) > 0 {
+		ones := int(b[0])
+		n := (ones + 7) / 8
+
+		if ones > 32 || len(b) < 1+n {
+			return nil, errNLRI
+		}
+
+		ip := make(net.IP, net.IPv4len)
+		copy(ip, b[1:1+n])
+
+		mask := net.CIDRMask(ones, 32)
+		prefixes = append(prefixes, net.IPNet{IP: ip.Mask(mask), Mask: mask})
+		b = b[1+n:]
+	}
+
+	return prefixes, nil
+}
+
+func (u *Update) marshal() []byte {
+	withdrawn := marshalPrefixes(u.Withdrawn)
+
+	var attrs []byte
+	if u.Attrs != nil && len(u.NLRI) != 0 {
+		attrs = u.Attrs.marshal()
+	}
+
+	b := make([]byte, 2, 4+len(withdrawn)+len(attrs))
+	binary.BigEndian.PutUint16(b, uint16(len(withdrawn)))
+	b = append(b, withdrawn...)
+
+	b = append(b, uint8(len(attrs)>>8), uint8(len(attrs)))
+	b = append(b, attrs...)
+
+	return append(b, marshalPrefixes(u.NLRI)...)
+}
+
+func (u *Update) unmarshal(b []byte) (err error) {
+	if len(b) < 4 {
+		return errAttrs
+	}
+
+	n := int(binary.BigEndian.Uint16(b[0:2]))
+	if len(b) < 4+n {
+		return errAttrs
+	}
+
+	if u.Withdrawn, err = unmarshalPrefixes(b[2 : 2+n]); err != nil {
+		return err
+	}
+
+	b = b[2+n:]
+
+	n = int(binary.BigEndian.Uint16(b[0:2]))
+	if len(b) < 2+n {
+		return errAttrs
+	}
+
+	if u.NLRI, err = unmarshalPrefixes(b[2+n:]); err != nil {
+		return err
+	}
+
+	u.Attrs = nil
+	if len(u.NLRI) == 0 {
+		return nil
+	}
+
+	u.Attrs = new(Attrs)
+	return u.Attrs.unmarshal(b[2 : 2+n])
+}
+
+// Notification is a message, that reports an error
+// to the peer, session is closed after it.
+type Notification struct {
+	Code    uint8
+	Subcode uint8
+	Data    []byte
+}
+
+func (n *Notification) Type() Type {
+	return NotificationType
+}
+
+func (n *Notification) marshal() []byte {
+	return append([]byte{n.Code, n.Subcode}, n.Data...)
+}
+
+func (n *Notification) unmarshal(b []byte) error {
+	if len(b) < 2 {
+		return errHeader
+	}
+
+	n.Code, n.Subcode = b[0], b[1]
+	n.Data = append([]byte(nil), b[2:]...)
+	return nil
+}
+
+// Keepalive is a message, that keeps the session alive.
+type Keepalive struct{}
+
+func (k *Keepalive) Type() Type {
+	return KeepaliveType
+}
+
+func (k *Keepalive) marshal() []byte {
+	return nil
+}
+
+func (k *Keepalive) unmarshal(b []byte) error {
+	if len(b) != 0 {
+		return errHeader
+	}
+
+	return nil
+}
+
+// WriteMessage writes message to the writer.
+func WriteMessage(w io.Writer, m Message) error {
+	body := m.marshal()
+	if headerLen+len(body) > MaxMessageLen {
+		return errHeader
+	}
+
+	b := make([]byte, headerLen, headerLen+len(body))
+	for i := 0; i < 16; i++ {
+		b[i] = 0xff
+	}
+
+	binary.BigEndian.PutUint16(b[16:18], uint16(headerLen+len(body)))
+	b[18] = uint8(m.Type())
+
+	_, err := w.Write(append(b, body...))
+	return err
+}
+
+// ReadMessage reads message from the reader, *Error is returned
+// on malformed messages, other errors are errors of the reader.
+func ReadMessage(r io.Reader) (Message, error) {
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < 16; i++ {
+		if header[i] != 0xff {
+			return nil, &Error{MessageHeaderError, 1}
+		}
+	}
+
+	length := int(binary.BigEndian.Uint16(header[16:18]))
+	if length < headerLen || length > MaxMessageLen {
+		return nil, errHeader
+	}
+
+	body := make([]byte, length-headerLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	var m Message
+
+	switch Type(header[18]) {
+	case OpenType:
+		m = new(Open)
+	case UpdateType:
+		m = new(Update)
+	case NotificationType:
+		m = new(Notification)
+	case KeepaliveType:
+		m = new(Keepalive)
+	default:
+		return nil, &Error{MessageHeaderError, 3}
+	}
+
+	if err := m.unmarshal(body); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
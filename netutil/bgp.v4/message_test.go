@@ -0,0 +1,122 @@
+package bgp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestOpenMarshal(t *testing.T) {
+	var buf bytes.Buffer
+
+	open := &Open{Version, 65001, 90, net.IPv4(10, 0, 0, 1)}
+	if err := WriteMessage(&buf, open); err != nil {
+		t.Fatal("Failed to write message:", err)
+	}
+
+	if buf.Len() != headerLen+openLen {
+		t.Fatal("Invalid message length:", buf.Len())
+	}
+
+	m, err := ReadMessage(&buf)
+	if err != nil {
+		t.Fatal("Failed to read message:", err)
+	}
+
+	parsed, ok := m.(*Open)
+	if !ok || parsed.AS != 65001 || parsed.HoldTime != 90 || !parsed.ID.Equal(open.ID) {
+		t.Fatal("Invalid parsed message:", m)
+	}
+}
+
+func TestUpdateMarshal(t *testing.T) {
+	_, withdrawn, _ := net.ParseCIDR("10.1.0.0/16")
+	_, network, _ := net.ParseCIDR("192.168.128.0/17")
+	_, host, _ := net.ParseCIDR("172.16.0.1/32")
+
+	update := &Update{
+		Withdrawn: []net.IPNet{*withdrawn},
+		Attrs: &Attrs{
+			Origin:  OriginEGP,
+			ASPath:  []ASSegment{{ASSequence, []uint16{65001, 65002}}, {ASSet, []uint16{1, 2, 3}}},
+			NextHop: net.IPv4(10, 0, 0, 2),
+			MED:     50,
+			HasMED:  true,
+		},
+		NLRI: []net.IPNet{*network, *host},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, update); err != nil {
+		t.Fatal("Failed to write message:", err)
+	}
+
+	b := buf.Bytes()
+
+	m, err := ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal("Failed to read message:", err)
+	}
+
+	parsed, ok := m.(*Update)
+	if !ok || len(parsed.Withdrawn) != 1 || len(parsed.NLRI) != 2 {
+		t.Fatal("Invalid parsed message:", m)
+	}
+
+	if parsed.Withdrawn[0].String() != "10.1.0.0/16" ||
+		parsed.NLRI[0].String() != "192.168.128.0/17" ||
+		parsed.NLRI[1].String() != "172.16.0.1/32" {
+		t.Fatal("Invalid parsed prefixes:", parsed.Withdrawn, parsed.NLRI)
+	}
+
+	attrs := parsed.Attrs
+	if attrs.Origin != OriginEGP || !attrs.NextHop.Equal(net.IPv4(10, 0, 0, 2)) || !attrs.HasMED || attrs.MED != 50 {
+		t.Fatal("Invalid parsed attributes:", attrs)
+	}
+
+	if attrs.PathLen() != 3 || attrs.FirstAS() != 65001 || !attrs.Contains(3) || attrs.Contains(65000) {
+		t.Fatal("Invalid parsed AS path:", attrs.ASPath)
+	}
+
+	// Drop next hop attribute (flags, type, length, address)
+	// and fix lengths of the message and attributes.
+	i := bytes.Index(b, []byte{flagTransitive, attrNextHop, 4})
+	malformed := append(append([]byte(nil), b[:i]...), b[i+7:]...)
+	malformed[17] -= 7
+	malformed[headerLen+2+3+1] -= 7
+
+	if _, err = ReadMessage(bytes.NewReader(malformed)); err != errMissing {
+		t.Fatal("Update without next hop must not be accepted:", err)
+	}
+}
+
+func TestReadMessageErrors(t *testing.T) {
+	var buf bytes.Buffer
+	WriteMessage(&buf, &Keepalive{})
+
+	b := buf.Bytes()
+	b[0] = 0
+
+	if _, err := ReadMessage(bytes.NewReader(b)); err == nil || err.(*Error).Code != MessageHeaderError {
+		t.Fatal("Message with invalid marker must not be accepted:", err)
+	}
+
+	b[0], b[18] = 0xff, 9
+	if _, err := ReadMessage(bytes.NewReader(b)); err == nil || err.(*Error).Subcode != 3 {
+		t.Fatal("Message of unknown type must not be accepted:", err)
+	}
+
+	buf.Reset()
+
+	notification := &Notification{Cease, 0, []byte{1}}
+	WriteMessage(&buf, notification)
+
+	m, err := ReadMessage(&buf)
+	if err != nil {
+		t.Fatal("Failed to read notification:", err)
+	}
+
+	if parsed, ok := m.(*Notification); !ok || parsed.Code != Cease || len(parsed.Data) != 1 {
+		t.Fatal("Invalid parsed notification:", m)
+	}
+}
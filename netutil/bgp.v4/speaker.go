@@ -0,0 +1,668 @@
+package bgp
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// HoldTime is a default hold time proposed to peers.
+	HoldTime = 90 * time.Second
+
+	// ConnectRetry is a default interval between connection attempts.
+	ConnectRetry = 120 * time.Second
+
+	// openHoldTime is a hold time used until session is opened.
+	openHoldTime = 4 * time.Minute
+
+	// writeTimeout limits duration of writing a single message.
+	writeTimeout = 5 * time.Second
+)
+
+// State is a state of the session with the peer.
+type State uint8
+
+const (
+	Idle State = iota
+	Connect
+	Active
+	OpenSent
+	OpenConfirm
+	Established
+)
+
+var states = []string{
+	"Idle", "Connect", "Active", "OpenSent", "OpenConfirm", "Established",
+}
+
+func (s State) String() string {
+	if int(s) < len(states) {
+		return states[s]
+	}
+
+	return "Unknown"
+}
+
+// Neighbor is a state of the session with the configured peer.
+type Neighbor struct {
+	// Address of the peer.
+	Addr net.IP
+
+	// Autonomous system of the peer.
+	AS uint16
+
+	// BGP identifier of the peer, set once session is opened.
+	ID net.IP
+
+	// State of the session.
+	State State
+
+	// Time, when session reached established state.
+	Established time.Time
+
+	// Number of prefixes received from the peer.
+	Prefixes int
+}
+
+// Route is a best path to the destination network.
+type Route struct {
+	// Destination network.
+	Network net.IPNet
+
+	// Address of the next router.
+	NextHop net.IP
+
+	// Address of the peer, the route was received from.
+	Peer net.IP
+
+	// Path attributes of the route.
+	Attrs *Attrs
+}
+
+// path is a route received from the peer.
+type path struct {
+	network net.IPNet
+	attrs   *Attrs
+	peer    *peer
+}
+
+// better reports whether path is preferred over the other one.
+func (p *path) better(other *path) bool {
+	if n, on := p.attrs.PathLen(), other.attrs.PathLen(); n != on {
+		return n < on
+	}
+
+	if p.attrs.Origin != other.attrs.Origin {
+		return p.attrs.Origin < other.attrs.Origin
+	}
+
+	// Discriminators are comparable only between
+	// paths received from the same autonomous system.
+	if p.attrs.FirstAS() == other.attrs.FirstAS() && p.attrs.MED != other.attrs.MED {
+		return p.attrs.MED < other.attrs.MED
+	}
+
+	if cmp := bytes.Compare(p.peer.id.To4(), other.peer.id.To4()); cmp != 0 {
+		return cmp < 0
+	}
+
+	return bytes.Compare(p.peer.addr.To4(), other.peer.addr.To4()) < 0
+}
+
+// peer is a session with the configured neighbor.
+type peer struct {
+	addr net.IP
+	as   uint16
+
+	id          net.IP
+	state       State
+	established time.Time
+
+	// Adj-RIB-In of the peer.
+	rib map[string]*path
+
+	conn   net.Conn
+	wlock  sync.Mutex
+	stopCh chan bool
+	doneCh chan bool
+}
+
+// write writes message to the peer connection.
+func (p *peer) write(conn net.Conn, m Message) error {
+	p.wlock.Lock()
+	defer p.wlock.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return WriteMessage(conn, m)
+}
+
+// Speaker implements external BGP-4 speaker for IPv4 unicast routes:
+// it opens sessions to the configured neighbors, selects best paths
+// among received routes and advertises local networks. Routes learned
+// from neighbors are not advertised to other neighbors. Speaker opens
+// sessions actively, incoming connections are not accepted.
+type Speaker struct {
+	// Autonomous system of the speaker.
+	AS uint16
+
+	// BGP identifier of the speaker.
+	ID net.IP
+
+	// Hold time proposed to peers.
+	HoldTime time.Duration
+
+	// Interval between connection attempts.
+	ConnectRetry time.Duration
+
+	// Dial is called to open connection to the peer address.
+	Dial func(addr string) (net.Conn, error)
+
+	// LocalAddr is called to get next hop address advertised to
+	// the peer, local address of the connection is used, when nil.
+	LocalAddr func(peer net.IP) net.IP
+
+	// Learn is called, when best path to the destination is selected.
+	Learn func(Route)
+
+	// Forget is called, when destination becomes unreachable.
+	Forget func(Route)
+
+	peers    map[string]*peer
+	networks []net.IPNet
+	best     map[string]Route
+
+	lock sync.Mutex
+
+	// Serializes notifications about best path changes.
+	notifyLock sync.Mutex
+}
+
+// NewSpeaker creates a new instance of Speaker type.
+func NewSpeaker(as uint16, id net.IP) *Speaker {
+	return &Speaker{
+		AS:           as,
+		ID:           id,
+		HoldTime:     HoldTime,
+		ConnectRetry: ConnectRetry,
+		Dial: func(addr string) (net.Conn, error) {
+			return net.DialTimeout("tcp", addr, writeTimeout)
+		},
+		peers: make(map[string]*peer),
+		best:  make(map[string]Route),
+	}
+}
+
+// Neighbors returns states of sessions ordered by peer address.
+func (s *Speaker) Neighbors() []Neighbor {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var neighbors []Neighbor
+	for _, p := range s.peers {
+		neighbors = append(neighbors, Neighbor{
+			Addr:        p.addr,
+			AS:          p.as,
+			ID:          p.id,
+			State:       p.state,
+			Established: p.established,
+			Prefixes:    len(p.rib),
+		})
+	}
+
+	sort.Slice(neighbors, func(i, j int) bool {
+		return bytes.Compare(neighbors[i].Addr.To4(), neighbors[j].Addr.To4()) < 0
+	})
+
+	return neighbors
+}
+
+// Routes returns selected best paths ordered by destination.
+func (s *Speaker) Routes() []Route {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var routes []Route
+	for _, key := range routeKeys(s.best) {
+		routes = append(routes, s.best[key])
+	}
+
+	return routes
+}
+
+// routeKeys returns sorted keys of the routes.
+func routeKeys(routes map[string]Route) []string {
+	var keys []string
+	for key := range routes {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// AddNeighbor starts session with the peer.
+func (s *Speaker) AddNeighbor(addr net.IP, as uint16) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.peers[addr.String()]; ok {
+		return
+	}
+
+	p := &peer{
+		addr:   addr.To4(),
+		as:     as,
+		rib:    make(map[string]*path),
+		stopCh: make(chan bool),
+		doneCh: make(chan bool),
+	}
+
+	s.peers[addr.String()] = p
+	go s.run(p)
+}
+
+// DeleteNeighbor closes session with the peer and
+// withdraws routes received from it.
+func (s *Speaker) DeleteNeighbor(addr net.IP) {
+	s.lock.Lock()
+	p, ok := s.peers[addr.String()]
+	delete(s.peers, addr.String())
+	s.lock.Unlock()
+
+	if ok {
+		s.stopPeer(p)
+	}
+}
+
+// Stop closes sessions with all peers.
+func (s *Speaker) Stop() {
+	s.lock.Lock()
+	var peers []*peer
+	for _, p := range s.peers {
+		peers = append(peers, p)
+	}
+	s.lock.Unlock()
+
+	for _, p := range peers {
+		s.DeleteNeighbor(p.addr)
+	}
+}
+
+// stopPeer sends cease notification to the peer and
+// waits until session goroutine returns.
+func (s *Speaker) stopPeer(p *peer) {
+	s.lock.Lock()
+	close(p.stopCh)
+	conn := p.conn
+	s.lock.Unlock()
+
+	if conn != nil {
+		p.write(conn, &Notification{Code: Cease})
+		conn.Close()
+	}
+
+	<-p.doneCh
+}
+
+// Advertise replaces networks advertised to peers.
+func (s *Speaker) Advertise(networks []net.IPNet) {
+	s.lock.Lock()
+
+	var withdrawn []net.IPNet
+	for _, network := range s.networks {
+		if !containsNetwork(networks, network) {
+			withdrawn = append(withdrawn, network)
+		}
+	}
+
+	var nlri []net.IPNet
+	for _, network := range networks {
+		if !containsNetwork(s.networks, network) {
+			nlri = append(nlri, network)
+		}
+	}
+
+	s.networks = append([]net.IPNet(nil), networks...)
+
+	var peers []*peer
+	var conns []net.Conn
+	for _, p := range s.peers {
+		if p.state == Established {
+			peers = append(peers, p)
+			conns = append(conns, p.conn)
+		}
+	}
+
+	s.lock.Unlock()
+
+	for i, p := range peers {
+		s.announce(p, conns[i], withdrawn, nlri)
+	}
+}
+
+// containsNetwork reports whether network is in the list.
+func containsNetwork(networks []net.IPNet, network net.IPNet) bool {
+	for _, n := range networks {
+		if n.String() == network.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// announce sends update with local networks to the peer.
+func (s *Speaker) announce(p *peer, conn net.Conn, withdrawn, nlri []net.IPNet) {
+	if len(withdrawn) == 0 && len(nlri) == 0 {
+		return
+	}
+
+	var nexthop net.IP
+	if s.LocalAddr != nil {
+		nexthop = s.LocalAddr(p.addr)
+	}
+
+	if nexthop == nil {
+		if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+			nexthop = addr.IP
+		}
+	}
+
+	attrs := &Attrs{
+		Origin:  OriginIGP,
+		ASPath:  []ASSegment{{ASSequence, []uint16{s.AS}}},
+		NextHop: nexthop,
+	}
+
+	p.write(conn, &Update{Withdrawn: withdrawn, Attrs: attrs, NLRI: nlri})
+}
+
+// run maintains session with the peer until it is deleted.
+func (s *Speaker) run(p *peer) {
+	defer close(p.doneCh)
+
+	for {
+		s.setState(p, Connect)
+
+		addr := net.JoinHostPort(p.addr.String(), strconv.Itoa(Port))
+		if conn, err := s.Dial(addr); err == nil {
+			s.session(p, conn)
+			conn.Close()
+			s.down(p)
+		}
+
+		s.setState(p, Active)
+
+		select {
+		case <-p.stopCh:
+			s.setState(p, Idle)
+			return
+		case <-time.After(s.ConnectRetry):
+		}
+	}
+}
+
+// setState updates state of the session.
+func (s *Speaker) setState(p *peer, state State) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	p.state = state
+}
+
+// down clears state of the closed session and
+// withdraws routes received from the peer.
+func (s *Speaker) down(p *peer) {
+	s.lock.Lock()
+	p.conn = nil
+	p.rib = make(map[string]*path)
+	p.established = time.Time{}
+	s.lock.Unlock()
+
+	s.decide()
+}
+
+// notify sends notification about the error and returns it.
+func (s *Speaker) notify(p *peer, conn net.Conn, err error) error {
+	switch e := err.(type) {
+	case *Error:
+		p.write(conn, &Notification{Code: e.Code, Subcode: e.Subcode})
+	case net.Error:
+		if e.Timeout() {
+			p.write(conn, &Notification{Code: HoldTimerExpired})
+		}
+	}
+
+	return err
+}
+
+// read reads message from the peer within the hold time.
+func (s *Speaker) read(conn net.Conn, hold time.Duration) (Message, error) {
+	if hold > 0 {
+		conn.SetReadDeadline(time.Now().Add(hold))
+	} else {
+		conn.SetReadDeadline(time.Time{})
+	}
+
+	return ReadMessage(conn)
+}
+
+// validate returns error, when open message is not acceptable.
+func (s *Speaker) validate(p *peer, open *Open) error {
+	switch {
+	case open.Version != Version:
+		return &Error{OpenMessageError, 1}
+	case open.AS != p.as:
+		return &Error{OpenMessageError, 2}
+	case open.HoldTime == 1 || open.HoldTime == 2:
+		return &Error{OpenMessageError, 6}
+	case open.ID.To4() == nil || open.ID.Equal(net.IPv4zero) || open.ID.Equal(s.ID):
+		return &Error{OpenMessageError, 3}
+	}
+
+	return nil
+}
+
+// session runs established connection with the peer.
+func (s *Speaker) session(p *peer, conn net.Conn) error {
+	s.lock.Lock()
+	select {
+	case <-p.stopCh:
+		s.lock.Unlock()
+		return nil
+	default:
+	}
+
+	p.conn = conn
+	p.state = OpenSent
+	s.lock.Unlock()
+
+	err := p.write(conn, &Open{
+		Version:  Version,
+		AS:       s.AS,
+		HoldTime: uint16(s.HoldTime / time.Second),
+		ID:       s.ID,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	m, err := s.read(conn, openHoldTime)
+	if err != nil {
+		return s.notify(p, conn, err)
+	}
+
+	open, ok := m.(*Open)
+	if !ok {
+		return s.notify(p, conn, &Error{Code: FSMError})
+	}
+
+	if err = s.validate(p, open); err != nil {
+		return s.notify(p, conn, err)
+	}
+
+	hold := time.Duration(open.HoldTime) * time.Second
+	if s.HoldTime < hold {
+		hold = s.HoldTime
+	}
+
+	s.lock.Lock()
+	p.id, p.state = open.ID, OpenConfirm
+	s.lock.Unlock()
+
+	if err = p.write(conn, &Keepalive{}); err != nil {
+		return err
+	}
+
+	if m, err = s.read(conn, hold); err != nil {
+		return s.notify(p, conn, err)
+	}
+
+	if _, ok = m.(*Keepalive); !ok {
+		return s.notify(p, conn, &Error{Code: FSMError})
+	}
+
+	s.lock.Lock()
+	p.state, p.established = Established, time.Now()
+	networks := s.networks
+	s.lock.Unlock()
+
+	s.announce(p, conn, nil, networks)
+
+	stopCh := make(chan bool)
+	defer close(stopCh)
+
+	if hold > 0 {
+		go s.keepalive(p, conn, hold/3, stopCh)
+	}
+
+	for {
+		if m, err = s.read(conn, hold); err != nil {
+			return s.notify(p, conn, err)
+		}
+
+		switch m := m.(type) {
+		case *Update:
+			if err = s.update(p, m); err != nil {
+				return s.notify(p, conn, err)
+			}
+		case *Notification:
+			return &Error{m.Code, m.Subcode}
+		case *Open:
+			return s.notify(p, conn, &Error{Code: FSMError})
+		}
+	}
+}
+
+// keepalive sends keepalive messages to the peer.
+func (s *Speaker) keepalive(p *peer, conn net.Conn, interval time.Duration, stopCh chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if p.write(conn, &Keepalive{}) != nil {
+				return
+			}
+		}
+	}
+}
+
+// update updates Adj-RIB-In of the peer with received routes.
+func (s *Speaker) update(p *peer, update *Update) error {
+	if update.Attrs != nil && update.Attrs.FirstAS() != p.as {
+		return &Error{UpdateMessageError, 11}
+	}
+
+	s.lock.Lock()
+	for _, network := range update.Withdrawn {
+		delete(p.rib, network.String())
+	}
+
+	for _, network := range update.NLRI {
+		// Paths, that passed through the local autonomous
+		// system, are looped and treated as withdrawn.
+		if update.Attrs.Contains(s.AS) {
+			delete(p.rib, network.String())
+			continue
+		}
+
+		p.rib[network.String()] = &path{network, update.Attrs, p}
+	}
+	s.lock.Unlock()
+
+	s.decide()
+	return nil
+}
+
+// decide selects best paths and notifies about changed destinations.
+func (s *Speaker) decide() {
+	s.notifyLock.Lock()
+	defer s.notifyLock.Unlock()
+
+	s.lock.Lock()
+
+	selected := make(map[string]*path)
+	for _, p := range s.peers {
+		for key, path := range p.rib {
+			if best, ok := selected[key]; !ok || path.better(best) {
+				selected[key] = path
+			}
+		}
+	}
+
+	var learned, forgotten []Route
+
+	for _, key := range routeKeys(s.best) {
+		route := s.best[key]
+		path, ok := selected[key]
+
+		if !ok || !path.attrs.NextHop.Equal(route.NextHop) || !path.peer.addr.Equal(route.Peer) {
+			forgotten = append(forgotten, route)
+			delete(s.best, key)
+		} else {
+			s.best[key] = Route{route.Network, route.NextHop, route.Peer, path.attrs}
+		}
+	}
+
+	var keys []string
+	for key := range selected {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if _, ok := s.best[key]; ok {
+			continue
+		}
+
+		path := selected[key]
+		route := Route{path.network, path.attrs.NextHop, path.peer.addr, path.attrs}
+
+		s.best[key] = route
+		learned = append(learned, route)
+	}
+
+	s.lock.Unlock()
+
+	for _, route := range forgotten {
+		if s.Forget != nil {
+			s.Forget(route)
+		}
+	}
+
+	for _, route := range learned {
+		if s.Learn != nil {
+			s.Learn(route)
+		}
+	}
+}
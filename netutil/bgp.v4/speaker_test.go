@@ -0,0 +1,327 @@
+package bgp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// timeout limits waiting for messages and routes in tests.
+const timeout = 5 * time.Second
+
+// fakePeer is an in-process BGP peer listening on the loopback address.
+type fakePeer struct {
+	t        *testing.T
+	listener net.Listener
+	conn     net.Conn
+	messages chan Message
+}
+
+func newFakePeer(t *testing.T) *fakePeer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+
+	return &fakePeer{t: t, listener: listener, messages: make(chan Message, 16)}
+}
+
+// accept accepts connection from the speaker and exchanges opens.
+func (f *fakePeer) accept(open *Open) {
+	conn, err := f.listener.Accept()
+	if err != nil {
+		f.t.Fatal("Failed to accept connection:", err)
+	}
+
+	f.conn = conn
+	go func() {
+		defer close(f.messages)
+
+		for {
+			m, err := ReadMessage(conn)
+			if err != nil {
+				return
+			}
+
+			f.messages <- m
+		}
+	}()
+
+	f.send(open)
+	f.expect(OpenType)
+}
+
+// establish accepts connection and confirms the session.
+func (f *fakePeer) establish(open *Open) {
+	f.accept(open)
+
+	f.send(&Keepalive{})
+	f.expect(KeepaliveType)
+}
+
+// send sends message to the speaker.
+func (f *fakePeer) send(m Message) {
+	if err := WriteMessage(f.conn, m); err != nil {
+		f.t.Fatal("Failed to send message:", err)
+	}
+}
+
+// expect returns the next message of the type, keepalives are skipped.
+func (f *fakePeer) expect(typ Type) Message {
+	for {
+		select {
+		case m, ok := <-f.messages:
+			if !ok {
+				f.t.Fatal("Connection closed, expecting message:", typ)
+			}
+
+			if m.Type() == typ {
+				return m
+			}
+
+			if m.Type() != KeepaliveType {
+				f.t.Fatal("Unexpected message:", m)
+			}
+		case <-time.After(timeout):
+			f.t.Fatal("Timed out, expecting message:", typ)
+		}
+	}
+}
+
+func (f *fakePeer) close() {
+	if f.conn != nil {
+		f.conn.Close()
+	}
+
+	f.listener.Close()
+}
+
+// event is a change of the best path.
+type event struct {
+	learned bool
+	route   Route
+}
+
+// newTestSpeaker creates speaker, that connects to the fake peers.
+func newTestSpeaker(peers map[string]*fakePeer) (*Speaker, chan event) {
+	events := make(chan event, 16)
+
+	speaker := NewSpeaker(65000, net.IPv4(10, 0, 0, 1))
+	speaker.ConnectRetry = 100 * time.Millisecond
+
+	speaker.Dial = func(addr string) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(addr)
+		return net.Dial("tcp", peers[host].listener.Addr().String())
+	}
+
+	speaker.LocalAddr = func(peer net.IP) net.IP {
+		return net.IPv4(10, 0, 0, 1)
+	}
+
+	speaker.Learn = func(route Route) {
+		events <- event{true, route}
+	}
+
+	speaker.Forget = func(route Route) {
+		events <- event{false, route}
+	}
+
+	return speaker, events
+}
+
+// expectEvent waits for the change of the best path.
+func expectEvent(t *testing.T, events chan event, learned bool, network string, nexthop net.IP) {
+	select {
+	case e := <-events:
+		if e.learned != learned || e.route.Network.String() != network || !e.route.NextHop.Equal(nexthop) {
+			t.Fatal("Unexpected best path change:", e.learned, e.route)
+		}
+	case <-time.After(timeout):
+		t.Fatal("Timed out, expecting best path change:", network)
+	}
+}
+
+// announce returns update with network, advertised by the path.
+func announce(network string, nexthop net.IP, path ...uint16) *Update {
+	_, ipnet, _ := net.ParseCIDR(network)
+
+	return &Update{
+		Attrs: &Attrs{
+			Origin:  OriginIGP,
+			ASPath:  []ASSegment{{ASSequence, path}},
+			NextHop: nexthop,
+		},
+		NLRI: []net.IPNet{*ipnet},
+	}
+}
+
+// withdraw returns update withdrawing the network.
+func withdraw(network string) *Update {
+	_, ipnet, _ := net.ParseCIDR(network)
+	return &Update{Withdrawn: []net.IPNet{*ipnet}}
+}
+
+func TestSpeakerSession(t *testing.T) {
+	fake := newFakePeer(t)
+	defer fake.close()
+
+	speaker, events := newTestSpeaker(map[string]*fakePeer{"10.0.0.2": fake})
+
+	_, local, _ := net.ParseCIDR("192.168.1.0/24")
+	speaker.Advertise([]net.IPNet{*local})
+	speaker.AddNeighbor(net.IPv4(10, 0, 0, 2), 65001)
+
+	fake.establish(&Open{Version, 65001, 30, net.IPv4(2, 2, 2, 2)})
+
+	// Local networks are advertised once session is established.
+	update := fake.expect(UpdateType).(*Update)
+	if len(update.NLRI) != 1 || update.NLRI[0].String() != "192.168.1.0/24" {
+		t.Fatal("Local network is not advertised:", update.NLRI)
+	}
+
+	if update.Attrs.FirstAS() != 65000 || !update.Attrs.NextHop.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Fatal("Invalid attributes of advertised network:", update.Attrs)
+	}
+
+	nexthop := net.IPv4(10, 0, 0, 2)
+	fake.send(announce("172.16.0.0/16", nexthop, 65001, 65100))
+	expectEvent(t, events, true, "172.16.0.0/16", nexthop)
+
+	neighbors := speaker.Neighbors()
+	if len(neighbors) != 1 || neighbors[0].State != Established || neighbors[0].Prefixes != 1 {
+		t.Fatal("Invalid neighbor state:", neighbors)
+	}
+
+	if !neighbors[0].ID.Equal(net.IPv4(2, 2, 2, 2)) || neighbors[0].Established.IsZero() {
+		t.Fatal("Invalid neighbor session:", neighbors[0])
+	}
+
+	// Path, that passed through the local autonomous system, is looped.
+	fake.send(announce("172.17.0.0/16", nexthop, 65001, 65000))
+	fake.send(withdraw("172.16.0.0/16"))
+	expectEvent(t, events, false, "172.16.0.0/16", nexthop)
+
+	if routes := speaker.Routes(); len(routes) != 0 {
+		t.Fatal("Looped path must not be selected:", routes)
+	}
+
+	speaker.Advertise(nil)
+	update = fake.expect(UpdateType).(*Update)
+	if len(update.Withdrawn) != 1 || len(update.NLRI) != 0 {
+		t.Fatal("Local network is not withdrawn:", update)
+	}
+
+	fake.send(announce("172.16.0.0/16", nexthop, 65001))
+	expectEvent(t, events, true, "172.16.0.0/16", nexthop)
+
+	// Routes of the peer are withdrawn, when session is closed.
+	speaker.Stop()
+	expectEvent(t, events, false, "172.16.0.0/16", nexthop)
+
+	notification := fake.expect(NotificationType).(*Notification)
+	if notification.Code != Cease {
+		t.Fatal("Session must be closed with cease notification:", notification)
+	}
+}
+
+func TestSpeakerBadPeerAS(t *testing.T) {
+	fake := newFakePeer(t)
+	defer fake.close()
+
+	speaker, _ := newTestSpeaker(map[string]*fakePeer{"10.0.0.2": fake})
+	defer speaker.Stop()
+
+	speaker.AddNeighbor(net.IPv4(10, 0, 0, 2), 65001)
+	fake.accept(&Open{Version, 65002, 30, net.IPv4(2, 2, 2, 2)})
+
+	notification := fake.expect(NotificationType).(*Notification)
+	if notification.Code != OpenMessageError || notification.Subcode != 2 {
+		t.Fatal("Invalid notification:", notification)
+	}
+}
+
+func TestSpeakerMalformedPath(t *testing.T) {
+	fake := newFakePeer(t)
+	defer fake.close()
+
+	speaker, _ := newTestSpeaker(map[string]*fakePeer{"10.0.0.2": fake})
+	defer speaker.Stop()
+
+	speaker.AddNeighbor(net.IPv4(10, 0, 0, 2), 65001)
+	fake.establish(&Open{Version, 65001, 30, net.IPv4(2, 2, 2, 2)})
+
+	// The first autonomous system must be the one of the peer.
+	fake.send(announce("172.16.0.0/16", net.IPv4(10, 0, 0, 2), 65100))
+
+	notification := fake.expect(NotificationType).(*Notification)
+	if notification.Code != UpdateMessageError || notification.Subcode != 11 {
+		t.Fatal("Invalid notification:", notification)
+	}
+}
+
+func TestSpeakerBestPath(t *testing.T) {
+	fake1, fake2 := newFakePeer(t), newFakePeer(t)
+	defer fake1.close()
+	defer fake2.close()
+
+	speaker, events := newTestSpeaker(map[string]*fakePeer{
+		"10.0.0.2": fake1,
+		"10.0.0.3": fake2,
+	})
+
+	defer speaker.Stop()
+
+	speaker.AddNeighbor(net.IPv4(10, 0, 0, 2), 65001)
+	fake1.establish(&Open{Version, 65001, 30, net.IPv4(2, 2, 2, 2)})
+
+	speaker.AddNeighbor(net.IPv4(10, 0, 0, 3), 65002)
+	fake2.establish(&Open{Version, 65002, 30, net.IPv4(3, 3, 3, 3)})
+
+	nexthop1, nexthop2 := net.IPv4(10, 0, 0, 2), net.IPv4(10, 0, 0, 3)
+
+	fake1.send(announce("172.16.0.0/16", nexthop1, 65001, 65100, 65200))
+	expectEvent(t, events, true, "172.16.0.0/16", nexthop1)
+
+	// Shorter AS path is preferred.
+	fake2.send(announce("172.16.0.0/16", nexthop2, 65002, 65200))
+	expectEvent(t, events, false, "172.16.0.0/16", nexthop1)
+	expectEvent(t, events, true, "172.16.0.0/16", nexthop2)
+
+	// Failover to the remaining path.
+	fake2.send(withdraw("172.16.0.0/16"))
+	expectEvent(t, events, false, "172.16.0.0/16", nexthop2)
+	expectEvent(t, events, true, "172.16.0.0/16", nexthop1)
+
+	routes := speaker.Routes()
+	if len(routes) != 1 || !routes[0].Peer.Equal(nexthop1) || routes[0].Attrs.PathLen() != 3 {
+		t.Fatal("Invalid best paths:", routes)
+	}
+}
+
+func TestPathBetter(t *testing.T) {
+	peer1 := &peer{addr: net.IPv4(10, 0, 0, 2), id: net.IPv4(2, 2, 2, 2)}
+	peer2 := &peer{addr: net.IPv4(10, 0, 0, 3), id: net.IPv4(1, 1, 1, 1)}
+
+	attrs := func(origin Origin, med uint32, path ...uint16) *Attrs {
+		return &Attrs{Origin: origin, ASPath: []ASSegment{{ASSequence, path}}, MED: med}
+	}
+
+	tests := []struct {
+		p1, p2 *path
+	}{
+		// Lower origin.
+		{&path{attrs: attrs(OriginIGP, 0, 1), peer: peer1}, &path{attrs: attrs(OriginIncomplete, 0, 2), peer: peer2}},
+		// Lower discriminator from the same autonomous system.
+		{&path{attrs: attrs(OriginIGP, 10, 1), peer: peer1}, &path{attrs: attrs(OriginIGP, 20, 1), peer: peer2}},
+		// Discriminators of different autonomous systems are ignored.
+		{&path{attrs: attrs(OriginIGP, 20, 2), peer: peer2}, &path{attrs: attrs(OriginIGP, 10, 1), peer: peer1}},
+		// Lower router identifier.
+		{&path{attrs: attrs(OriginIGP, 0, 1), peer: peer2}, &path{attrs: attrs(OriginIGP, 0, 1), peer: peer1}},
+	}
+
+	for i, test := range tests {
+		if !test.p1.better(test.p2) || test.p2.better(test.p1) {
+			t.Fatal("Invalid path preference in test", i)
+		}
+	}
+}
@@ -0,0 +1,629 @@
+package bridge
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ofp.v13"
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+	"github.com/netrack/openflow/ofp.v13/ofputil"
+)
+
+const (
+	// BridgeMechanismName is a name of MAC learning bridge mechanism.
+	BridgeMechanismName = "bridge"
+
+	// IdleTimeout is a number of idle seconds before
+	// expiration of flows installed to the learned hosts.
+	IdleTimeout = 60
+
+	// sweepInterval is an interval between removals of aged addresses.
+	sweepInterval = 10 * time.Second
+)
+
+func init() {
+	constructor := mech.LinkMechanismConstructorFunc(NewBridgeMechanism)
+	mech.RegisterLinkMechanism(BridgeMechanismName, constructor)
+}
+
+// Match frames destined to the host.
+func hostMatch(addr net.HardwareAddr) ofp.Match {
+	return ofp.Match{ofp.MT_OXM, []ofp.OXM{
+		ofputil.EthDstAddr(addr, nil),
+	}}
+}
+
+// Match frames received on the port with the tag destined to the host.
+func forwardMatch(port uint32, tag uint16, addr net.HardwareAddr) ofp.Match {
+	return ofp.Match{ofp.MT_OXM, []ofp.OXM{
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IN_PORT, of.Bytes(ofp.PortNo(port)), nil},
+		ofp13.VLANMatch(tag),
+		ofputil.EthDstAddr(addr, nil),
+	}}
+}
+
+// Match all frames.
+func anyMatch() ofp.Match {
+	return ofp.Match{ofp.MT_OXM, nil}
+}
+
+// isMulticast returns true for group (multicast and broadcast) addresses.
+func isMulticast(addr net.HardwareAddr) bool {
+	return len(addr) == 0 || addr[0]&0x01 != 0
+}
+
+// BridgeMechanism is a MAC learning bridge. It learns hardware addresses
+// of hosts from frames, that were not claimed by protocol specific
+// mechanisms of the switch, forwards frames to the learned hosts and
+// floods frames with unknown destination. Ports attached to other
+// switches are excluded from flooding to prevent forwarding loops.
+//
+// Frames are bridged only within a VLAN: access ports carry untagged
+// frames of the configured VLAN, trunk ports carry tagged frames of
+// all VLANs and ports without VLAN mode carry untagged frames only.
+type BridgeMechanism struct {
+	mech.BaseLinkMechanism
+
+	// Handle request based on cookie value.
+	cookies *mech.CookieFilter
+
+	// Stores registered handlers to delete them later.
+	filter *of.ServeFilter
+
+	// Learned hardware addresses.
+	macTable *mech.MACTable
+
+	// Reader of ethernet frames.
+	reader mech.LinkFrameReader
+
+	// VLAN configuration of the switch ports.
+	ports     map[uint32]mech.LinkPort
+	portsLock sync.RWMutex
+
+	// Graph of switches.
+	topology *mech.Topology
+
+	// Table allocated for forwarding rules.
+	tableNo int
+
+	stopCh chan bool
+	lock   sync.Mutex
+}
+
+// NewBridgeMechanism creates a new instance of BridgeMechanism type.
+func NewBridgeMechanism() mech.LinkMechanism {
+	return &BridgeMechanism{
+		cookies:  mech.NewCookieFilter(),
+		filter:   of.NewServeFilter(),
+		macTable: mech.NewMACTable(),
+		reader:   drivers.NewEthernetLinkDriver(),
+		ports:    make(map[uint32]mech.LinkPort),
+		topology: mech.DefaultTopology,
+	}
+}
+
+// Name implements Mechanism interface.
+func (m *BridgeMechanism) Name() string {
+	return BridgeMechanismName
+}
+
+// Description implements Mechanism interface.
+func (m *BridgeMechanism) Description() string {
+	return "MAC learning bridge"
+}
+
+// Version implements VersionedMechanism interface.
+func (m *BridgeMechanism) Version() string {
+	return ofp13.ProtoVersion
+}
+
+// MACTable implements MACTableReader interface.
+func (m *BridgeMechanism) MACTable() []mech.MACEntry {
+	return m.macTable.Entries()
+}
+
+// Enable implements Mechanism interface.
+func (m *BridgeMechanism) Enable(c *mech.MechanismContext) {
+	m.BaseLinkMechanism.Enable(c)
+
+	m.filter.Mux = m.C.Mux
+	// Handle frames with unknown destination.
+	m.filter.HandleFunc(of.T_PACKET_IN, m.packetInHandler)
+
+	// Hosts behind the ports, that went down, are gone.
+	m.C.Ports.Subscribe(m.Name(), m.portHandler)
+
+	// Expose learned addresses.
+	m.C.Managers.Bind(new(mech.MACTableReader), m)
+
+	log.InfoLog("bridge/ENABLE_HOOK", "Mechanism bridge enabled")
+}
+
+// Activate implements Mechanism interface.
+func (m *BridgeMechanism) Activate() {
+	m.BaseLinkMechanism.Activate()
+
+	// Operate on PacketIn messages
+	m.cookies.Baker = ofputil.PacketInBaker()
+
+	// Allocate table for forwarding rules.
+	tableNo, err := m.C.Switch.AllocateTable()
+	if err != nil {
+		log.ErrorLog("bridge/ACTIVATE_HOOK",
+			"Failed to allocate a new table: ", err)
+		return
+	}
+
+	m.tableNo = tableNo
+
+	log.DebugLog("bridge/ACTIVATE_HOOK",
+		"Allocated table: ", tableNo)
+
+	// Send frames with unknown destination to the controller.
+	instructions := ofp.Instructions{ofp.InstructionActions{
+		ofp.IT_APPLY_ACTIONS,
+		ofp.Actions{ofp.ActionOutput{ofp.P_CONTROLLER, ofp.CML_NO_BUFFER}},
+	}}
+
+	flowMod := ofp.FlowMod{
+		Command:      ofp.FC_ADD,
+		TableID:      ofp.Table(m.tableNo),
+		BufferID:     ofp.NO_BUFFER,
+		Match:        anyMatch(),
+		Instructions: instructions,
+	}
+
+	m.cookies.FilterFunc(&flowMod, m.frameHandler)
+
+	flowModMiss, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
+	if err != nil {
+		log.ErrorLog("bridge/ACTIVATE_HOOK",
+			"Failed to create ofp_flow_mod request: ", err)
+		return
+	}
+
+	// Protocol specific mechanisms take precedence over bridging.
+	flowModGoto, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp.FlowMod{
+		Command:      ofp.FC_ADD,
+		BufferID:     ofp.NO_BUFFER,
+		Priority:     1,
+		Match:        anyMatch(),
+		Instructions: ofp.Instructions{ofp.InstructionGotoTable{ofp.Table(m.tableNo)}},
+	}))
+
+	if err != nil {
+		log.ErrorLog("bridge/ACTIVATE_HOOK",
+			"Failed to create ofp_flow_mod request: ", err)
+		return
+	}
+
+	err = of.Send(m.C.Switch.Conn(),
+		// Flush flows from table before using it.
+		ofputil.TableFlush(ofp.Table(m.tableNo)),
+		// Send not matched frames to the controller.
+		flowModMiss,
+		// Redirect not claimed frames to allocated table.
+		flowModGoto,
+	)
+
+	if err != nil {
+		log.ErrorLog("bridge/ACTIVATE_HOOK",
+			"Failed to send requests: ", err)
+		return
+	}
+
+	m.start()
+
+	log.DebugLog("bridge/ACTIVATE_HOOK",
+		"Mechanism bridge activated")
+}
+
+// Disable implements Mechanism interface.
+func (m *BridgeMechanism) Disable() {
+	m.BaseLinkMechanism.Disable()
+
+	m.stop()
+	m.filter.Unhandle()
+	m.C.Ports.Unsubscribe(m.Name())
+	m.C.Managers.Unbind(new(mech.MACTableReader))
+
+	// Empty match removes all flows of the table, so
+	// delete only redirect flow of the mechanism.
+	flowModGoto, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp.FlowMod{
+		Command:  ofp.FC_DELETE_STRICT,
+		Priority: 1,
+		BufferID: ofp.NO_BUFFER,
+		OutPort:  ofp.P_ANY,
+		OutGroup: ofp.G_ANY,
+		Match:    anyMatch(),
+	}))
+
+	if err == nil {
+		err = of.Send(m.C.Switch.Conn(),
+			// Flush installed flows
+			ofputil.TableFlush(ofp.Table(m.tableNo)),
+			// Flush redirect flow
+			flowModGoto,
+		)
+	}
+
+	// Release acquired table
+	m.C.Switch.ReleaseTable(m.tableNo)
+
+	if err != nil {
+		log.ErrorLog("bridge/DISABLE_HOOK",
+			"Failed to send requests: ", err)
+	}
+
+	log.InfoLog("bridge/DISABLE_HOOK", "Mechanism bridge disabled")
+}
+
+// Deactivate implements Mechanism interface.
+func (m *BridgeMechanism) Deactivate() {
+	m.BaseLinkMechanism.Deactivate()
+
+	m.stop()
+	m.filter.Unhandle()
+	m.C.Ports.Unsubscribe(m.Name())
+	m.C.Managers.Unbind(new(mech.MACTableReader))
+
+	// Drop handlers of cookies of installed flows.
+	m.cookies = mech.NewCookieFilter()
+
+	// Release acquired table
+	m.C.Switch.ReleaseTable(m.tableNo)
+
+	log.InfoLog("bridge/DEACTIVATE_HOOK", "Mechanism bridge deactivated")
+}
+
+// OwnsFlow implements mech.FlowOwner interface.
+func (m *BridgeMechanism) OwnsFlow(table int, cookie uint64) bool {
+	return table != 0 && table == m.tableNo ||
+		m.cookies.Contains(cookie)
+}
+
+// CreateLinkPreCommit implements LinkMechanism interface.
+func (m *BridgeMechanism) CreateLinkPreCommit(context *mech.LinkContext) error {
+	m.setPort(context.Port, context.PortMode, context.VLAN)
+	return nil
+}
+
+// UpdateLinkPostCommit implements LinkMechanism interface.
+func (m *BridgeMechanism) UpdateLinkPostCommit(context *mech.LinkContext) error {
+	m.setPort(context.Port, context.PortMode, context.VLAN)
+	return nil
+}
+
+// DeleteLinkPreCommit implements LinkMechanism interface.
+func (m *BridgeMechanism) DeleteLinkPreCommit(context *mech.LinkContext) error {
+	m.setPort(context.Port, "", 0)
+	return nil
+}
+
+// setPort updates VLAN configuration of the port, hosts learned
+// on the port are removed, when configuration changes.
+func (m *BridgeMechanism) setPort(port uint32, mode mech.LinkPortMode, vlan uint16) {
+	m.portsLock.Lock()
+
+	prev := m.ports[port]
+	if mode == "" {
+		delete(m.ports, port)
+	} else {
+		m.ports[port] = mech.LinkPort{Port: port, Mode: mode, VLAN: vlan}
+	}
+
+	m.portsLock.Unlock()
+
+	if prev.Mode != mode || prev.VLAN != vlan {
+		m.forget(m.macTable.DeletePort(port))
+	}
+}
+
+// ingress returns VLAN of the frame received on the port with the
+// tag, false is returned when port does not accept such frames.
+func (m *BridgeMechanism) ingress(port uint32, tag uint16) (uint16, bool) {
+	m.portsLock.RLock()
+	defer m.portsLock.RUnlock()
+
+	config := m.ports[port]
+	switch config.Mode {
+	case mech.LinkPortAccess:
+		return config.VLAN, tag == 0
+	case mech.LinkPortTrunk:
+		return tag, tag != 0
+	}
+
+	return 0, tag == 0
+}
+
+// egress returns tag of the frame of the VLAN sent through the
+// port, false is returned when port is not a member of the VLAN.
+func (m *BridgeMechanism) egress(port uint32, vlan uint16) (uint16, bool) {
+	m.portsLock.RLock()
+	defer m.portsLock.RUnlock()
+
+	config := m.ports[port]
+	switch config.Mode {
+	case mech.LinkPortAccess:
+		return 0, config.VLAN == vlan
+	case mech.LinkPortTrunk:
+		return vlan, vlan != 0
+	}
+
+	return 0, vlan == 0
+}
+
+// start starts removal of aged addresses in a separate goroutine.
+func (m *BridgeMechanism) start() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.stopCh != nil {
+		return
+	}
+
+	m.stopCh = make(chan bool)
+	go m.run(m.stopCh)
+}
+
+// stop stops removal of aged addresses.
+func (m *BridgeMechanism) stop() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.stopCh != nil {
+		close(m.stopCh)
+		m.stopCh = nil
+	}
+}
+
+func (m *BridgeMechanism) run(stopCh chan bool) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		m.forget(m.macTable.Expire(time.Now()))
+	}
+}
+
+// forget removes flows installed to the removed hosts.
+func (m *BridgeMechanism) forget(entries []mech.MACEntry) {
+	var requests []*of.Request
+
+	for _, entry := range entries {
+		log.DebugLogf("bridge/FORGET",
+			"Address %s of VLAN %d removed from port %d",
+			entry.Addr, entry.VLAN, entry.Port)
+
+		requests = append(requests,
+			ofputil.FlowFlush(ofp.Table(m.tableNo), hostMatch(entry.Addr)))
+	}
+
+	if len(requests) == 0 {
+		return
+	}
+
+	if err := of.Send(m.C.Switch.Conn(), requests...); err != nil {
+		log.ErrorLog("bridge/FORGET",
+			"Failed to send requests: ", err)
+	}
+}
+
+// trunks returns ports attached to other switches.
+func (m *BridgeMechanism) trunks() map[uint32]bool {
+	dpid := m.C.Switch.ID()
+	ports := make(map[uint32]bool)
+
+	for _, link := range m.topology.Links() {
+		if link.SrcDatapath == dpid {
+			ports[link.SrcPort] = true
+		}
+
+		if link.DstDatapath == dpid {
+			ports[link.DstPort] = true
+		}
+	}
+
+	return ports
+}
+
+func (m *BridgeMechanism) packetInHandler(rw of.ResponseWriter, r *of.Request) {
+	// Serve message based on PacketIn cookies.
+	m.cookies.Serve(rw, r)
+}
+
+func (m *BridgeMechanism) frameHandler(rw of.ResponseWriter, r *of.Request) {
+	var packet ofp.PacketIn
+	var data bytes.Buffer
+
+	if _, err := of.ReadAllFrom(r.Body, &packet, &data); err != nil {
+		log.ErrorLog("bridge/FRAME_HANDLER",
+			"Failed to read packet: ", err)
+		return
+	}
+
+	frame, err := m.reader.ReadFrame(bytes.NewReader(data.Bytes()))
+	if err != nil {
+		log.ErrorLog("bridge/FRAME_HANDLER",
+			"Failed to read ethernet frame: ", err)
+		return
+	}
+
+	inPort := packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.UInt32()
+
+	trunks := m.trunks()
+	if trunks[inPort] {
+		// Frames between switches are forwarded by routing.
+		return
+	}
+
+	vlan, ok := m.ingress(inPort, frame.VLAN)
+	if !ok {
+		log.DebugLogf("bridge/FRAME_HANDLER",
+			"Frame tagged with VLAN %d is not allowed on port %d", frame.VLAN, inPort)
+		return
+	}
+
+	hwsrc := net.HardwareAddr(frame.SrcAddr.Bytes())
+	hwdst := net.HardwareAddr(frame.DstAddr.Bytes())
+
+	now := time.Now()
+
+	if !isMulticast(hwsrc) && m.macTable.Learn(hwsrc, vlan, inPort, now) {
+		log.DebugLogf("bridge/FRAME_HANDLER",
+			"Address %s of VLAN %d learned on port %d", hwsrc, vlan, inPort)
+
+		// Host could be moved from another port.
+		err := of.Send(m.C.Switch.Conn(),
+			ofputil.FlowFlush(ofp.Table(m.tableNo), hostMatch(hwsrc)))
+
+		if err != nil {
+			log.ErrorLog("bridge/FRAME_HANDLER",
+				"Failed to remove stale flows: ", err)
+		}
+	}
+
+	var actions ofp.Actions
+
+	entry, ok := m.macTable.Lookup(hwdst, vlan, now)
+	if ok && !isMulticast(hwdst) {
+		if entry.Port == inPort {
+			// Host is on the same segment.
+			return
+		}
+
+		tag, ok := m.egress(entry.Port, vlan)
+		if !ok {
+			return
+		}
+
+		actions = append(ofp13.VLANActions(frame.VLAN, tag),
+			ofp.ActionOutput{ofp.PortNo(entry.Port), 0})
+
+		if err := m.install(inPort, frame.VLAN, entry.Addr, actions); err != nil {
+			return
+		}
+	} else {
+		actions = m.flood(inPort, frame.VLAN, vlan, trunks)
+	}
+
+	if len(actions) == 0 {
+		return
+	}
+
+	packetOut := ofp.PacketOut{
+		BufferID: packet.BufferID,
+		InPort:   ofp.PortNo(inPort),
+		Actions:  actions,
+	}
+
+	// Frame is buffered by the switch.
+	if packet.BufferID != ofp.NO_BUFFER {
+		data.Reset()
+	}
+
+	_, err = of.WriteAllTo(rw, &packetOut, &data)
+	if err != nil {
+		log.ErrorLog("bridge/FRAME_HANDLER",
+			"Failed to write response: ", err)
+		return
+	}
+
+	rw.Header().Set(of.TypeHeaderKey, of.T_PACKET_OUT)
+	rw.Header().Set(of.VersionHeaderKey, ofp.VERSION)
+	if err = rw.WriteHeader(); err != nil {
+		log.ErrorLog("bridge/FRAME_HANDLER",
+			"Failed to send ofp_packet_out response: ", err)
+	}
+}
+
+// flood returns actions to send frame of the VLAN received on the
+// port with the tag to all other member ports of the VLAN.
+func (m *BridgeMechanism) flood(inPort uint32, tag, vlan uint16, trunks map[uint32]bool) ofp.Actions {
+	var tags []uint16
+	ports := make(map[uint16][]uint32)
+
+	for _, port := range m.C.Switch.PortList() {
+		if port.Number == inPort || trunks[port.Number] {
+			continue
+		}
+
+		egress, ok := m.egress(port.Number, vlan)
+		if !ok {
+			continue
+		}
+
+		// Output frame with the received tag first, so
+		// it will be re-tagged only for the rest ports.
+		if _, ok := ports[egress]; !ok && egress == tag {
+			tags = append([]uint16{egress}, tags...)
+		} else if !ok {
+			tags = append(tags, egress)
+		}
+
+		ports[egress] = append(ports[egress], port.Number)
+	}
+
+	var actions ofp.Actions
+
+	for _, egress := range tags {
+		actions = append(actions, ofp13.VLANActions(tag, egress)...)
+		tag = egress
+
+		for _, port := range ports[egress] {
+			actions = append(actions, ofp.ActionOutput{ofp.PortNo(port), 0})
+		}
+	}
+
+	return actions
+}
+
+// install forwards frames received on the port with the tag
+// destined to the learned host with the given actions.
+func (m *BridgeMechanism) install(port uint32, tag uint16, addr net.HardwareAddr, actions ofp.Actions) error {
+	instructions := ofp.Instructions{ofp.InstructionActions{
+		ofp.IT_APPLY_ACTIONS, actions,
+	}}
+
+	r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp.FlowMod{
+		Command:      ofp.FC_ADD,
+		TableID:      ofp.Table(m.tableNo),
+		BufferID:     ofp.NO_BUFFER,
+		IdleTimeout:  IdleTimeout,
+		Priority:     10,
+		Match:        forwardMatch(port, tag, addr),
+		Instructions: instructions,
+	}))
+
+	if err != nil {
+		log.ErrorLog("bridge/INSTALL",
+			"Failed to create ofp_flow_mod request: ", err)
+		return err
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("bridge/INSTALL",
+			"Failed to send ofp_flow_mod request: ", err)
+	}
+
+	return err
+}
+
+func (m *BridgeMechanism) portHandler(event mech.PortEvent) {
+	switch event.Type {
+	case mech.PortDown, mech.PortDeleted:
+		m.forget(m.macTable.DeletePort(event.Port.Number))
+	}
+}
@@ -0,0 +1,70 @@
+package bridge
+
+import (
+	"net"
+	"testing"
+
+	"github.com/netrack/netrack/mechanism"
+)
+
+func TestIsMulticast(t *testing.T) {
+	tests := []struct {
+		addr      net.HardwareAddr
+		multicast bool
+	}{
+		{net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, true},
+		{net.HardwareAddr{0x01, 0x80, 0xc2, 0, 0, 0x0e}, true},
+		{net.HardwareAddr{0x02, 0, 0, 0, 0, 1}, false},
+		{nil, true},
+	}
+
+	for _, test := range tests {
+		if isMulticast(test.addr) != test.multicast {
+			t.Fatal("Invalid multicast check of:", test.addr)
+		}
+	}
+}
+
+func TestBridgeVLANs(t *testing.T) {
+	m := NewBridgeMechanism().(*BridgeMechanism)
+	m.setPort(1, mech.LinkPortAccess, 10)
+	m.setPort(2, mech.LinkPortTrunk, 0)
+
+	tests := []struct {
+		port    uint32
+		tag     uint16
+		vlan    uint16
+		allowed bool
+	}{
+		{1, 0, 10, true},
+		{1, 20, 0, false},
+		{2, 20, 20, true},
+		{2, 0, 0, false},
+		{3, 0, 0, true},
+		{3, 10, 0, false},
+	}
+
+	for _, test := range tests {
+		vlan, ok := m.ingress(test.port, test.tag)
+		if ok != test.allowed || (ok && vlan != test.vlan) {
+			t.Fatal("Invalid ingress VLAN of port:", test.port, test.tag)
+		}
+	}
+
+	if tag, ok := m.egress(1, 10); !ok || tag != 0 {
+		t.Fatal("Access port must send untagged frames of its VLAN")
+	}
+
+	if _, ok := m.egress(1, 20); ok {
+		t.Fatal("Access port must not send frames of other VLAN")
+	}
+
+	if tag, ok := m.egress(2, 10); !ok || tag != 10 {
+		t.Fatal("Trunk port must send tagged frames")
+	}
+
+	m.setPort(1, "", 0)
+	if _, ok := m.egress(1, 10); ok {
+		t.Fatal("Deleted port must not be a member of VLAN")
+	}
+}
@@ -0,0 +1,650 @@
+package dhcp
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/netrack/net/iana"
+	"github.com/netrack/net/l2"
+	"github.com/netrack/netrack/database"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ofp.v13"
+	"github.com/netrack/netrack/netutil/udp"
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+	"github.com/netrack/openflow/ofp.v13/ofputil"
+)
+
+const DHCPMechanismName = "dhcp"
+
+func init() {
+	constructor := mech.NetworkMechanismConstructorFunc(NewDHCPMechanism)
+	mech.RegisterNetworkMechanism(DHCPMechanismName, constructor)
+}
+
+// isIPv4 reports whether address belongs to IPv4 network,
+// addresses of other network protocols are not leased.
+func isIPv4(addr mech.NetworkAddr) bool {
+	return len(addr.Bytes()) == net.IPv4len
+}
+
+// serverMatch matches DHCP messages received on the port.
+func serverMatch(port uint32, vlan uint16) ofp.Match {
+	return ofp.Match{ofp.MT_OXM, []ofp.OXM{
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IN_PORT, of.Bytes(ofp.PortNo(port)), nil},
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_TYPE, of.Bytes(iana.ETHT_IPV4), nil},
+		ofp13.VLANMatch(vlan),
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IP_PROTO, of.Bytes(iana.IP_PROTO_UDP), nil},
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_UDP_DST, of.Bytes(uint16(ServerPort)), nil},
+	}}
+}
+
+// DHCPMechanism leases addresses from the pools, configured on
+// the network interfaces, to the hosts connected to switch ports.
+type DHCPMechanism struct {
+	mech.BaseNetworkMechanism
+
+	// Handle request based on cookie value.
+	cookies *mech.CookieFilter
+
+	// Stores registered handlers to delete them later.
+	filter *of.ServeFilter
+
+	// Pools of addresses indexed by switch port.
+	pools map[uint32]*Pool
+
+	// Relay agents indexed by switch port.
+	relays map[uint32]*Relay
+
+	// Persisted leases of ports without pools.
+	restored []mech.DHCPLease
+	lock     sync.RWMutex
+}
+
+func NewDHCPMechanism() mech.NetworkMechanism {
+	return &DHCPMechanism{
+		cookies: mech.NewCookieFilter(),
+		filter:  of.NewServeFilter(),
+		pools:   make(map[uint32]*Pool),
+		relays:  make(map[uint32]*Relay),
+	}
+}
+
+func (m *DHCPMechanism) Name() string {
+	return DHCPMechanismName
+}
+
+func (m *DHCPMechanism) Description() string {
+	return "DHCP server and relay agent"
+}
+
+// Version implements VersionedMechanism interface.
+func (m *DHCPMechanism) Version() string {
+	return ofp13.ProtoVersion
+}
+
+// DHCPLeases implements DHCPLeaseReader interface.
+func (m *DHCPMechanism) DHCPLeases() []mech.DHCPLease {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.leases(time.Now())
+}
+
+// leases returns active leases ordered by port, lock must be held.
+func (m *DHCPMechanism) leases(now time.Time) []mech.DHCPLease {
+	var ports []int
+	for port := range m.pools {
+		ports = append(ports, int(port))
+	}
+
+	sort.Ints(ports)
+
+	leases := make([]mech.DHCPLease, 0)
+	for _, port := range ports {
+		for _, lease := range m.pools[uint32(port)].Leases(now) {
+			leases = append(leases, mech.DHCPLease{
+				Addr:     lease.Addr.String(),
+				LinkAddr: lease.HWAddr.String(),
+				Port:     uint32(port),
+				Expires:  lease.Expires,
+			})
+		}
+	}
+
+	for _, lease := range m.restored {
+		if lease.Expires.After(now) {
+			leases = append(leases, lease)
+		}
+	}
+
+	return leases
+}
+
+// persist saves active leases to the database.
+func (m *DHCPMechanism) persist() {
+	m.lock.RLock()
+	context := mech.DHCPLeaseContext{m.C.Switch.ID(), m.leases(time.Now())}
+	m.lock.RUnlock()
+
+	if err := db.Update(mech.DHCPLeaseModel, context.Datapath, &context); err != nil {
+		log.ErrorLog("dhcp/PERSIST",
+			"Failed to save DHCP leases: ", err)
+	}
+}
+
+// restore loads persisted leases of the switch.
+func (m *DHCPMechanism) restore() {
+	var context mech.DHCPLeaseContext
+
+	err := db.Transaction(func(p db.ModelPersister) error {
+		err := p.Lock(mech.DHCPLeaseModel, m.C.Switch.ID(), &context)
+		if err == nil {
+			return nil
+		}
+
+		// Create a new record for a new switch.
+		return p.Create(mech.DHCPLeaseModel, map[string]string{"id": m.C.Switch.ID()})
+	})
+
+	if err != nil {
+		log.ErrorLog("dhcp/RESTORE",
+			"Failed to restore DHCP leases: ", err)
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.restored = context.Leases
+}
+
+// Enable implements Mechanism interface.
+func (m *DHCPMechanism) Enable(c *mech.MechanismContext) {
+	m.BaseNetworkMechanism.Enable(c)
+
+	m.filter.Mux = m.C.Mux
+	// Handle incoming DHCP messages.
+	m.filter.HandleFunc(of.T_PACKET_IN, m.packetInHandler)
+	m.filter.HandleFunc(of.T_FLOW_REMOVED, m.flowRemovedHandler)
+
+	// Expose leased addresses.
+	m.C.Managers.Bind(new(mech.DHCPLeaseReader), m)
+
+	log.InfoLog("dhcp/ENABLE_HOOK", "Mechanism DHCP enabled")
+}
+
+// Activate implements Mechanism interface.
+func (m *DHCPMechanism) Activate() {
+	m.BaseNetworkMechanism.Activate()
+
+	// Operate on PacketIn messages
+	m.cookies.Baker = ofputil.PacketInBaker()
+
+	m.restore()
+}
+
+// Disable implements Mechanism interface.
+func (m *DHCPMechanism) Disable() {
+	m.BaseNetworkMechanism.Disable()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+	m.C.Managers.Unbind(new(mech.DHCPLeaseReader))
+
+	log.InfoLog("dhcp/DISABLE_HOOK", "Mechanism DHCP disabled")
+}
+
+// Deactivate implements Mechanism interface.
+func (m *DHCPMechanism) Deactivate() {
+	m.BaseNetworkMechanism.Deactivate()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+	m.C.Managers.Unbind(new(mech.DHCPLeaseReader))
+
+	// Drop handlers of cookies of installed flows.
+	m.cookies = mech.NewCookieFilter()
+
+	m.lock.Lock()
+	m.pools = make(map[uint32]*Pool)
+	m.relays = make(map[uint32]*Relay)
+	m.lock.Unlock()
+
+	log.InfoLog("dhcp/DEACTIVATE_HOOK", "Mechanism DHCP deactivated")
+}
+
+// OwnsFlow implements mech.FlowOwner interface.
+func (m *DHCPMechanism) OwnsFlow(table int, cookie uint64) bool {
+	return m.cookies.Contains(cookie)
+}
+
+// newPool returns a pool of the interface, nil is returned, when
+// pool does not belong to the network of the interface address.
+func newPool(context *mech.NetworkContext) *Pool {
+	if context.DHCP == nil || !isIPv4(context.NetworkAddr) {
+		return nil
+	}
+
+	start := net.ParseIP(context.DHCP.PoolStart).To4()
+	end := net.ParseIP(context.DHCP.PoolEnd).To4()
+	if start == nil || end == nil {
+		return nil
+	}
+
+	network := net.IPNet{
+		net.IP(context.NetworkAddr.Bytes()),
+		net.IPMask(context.NetworkAddr.Mask().Bytes()),
+	}
+
+	if !network.Contains(start) || !network.Contains(end) {
+		return nil
+	}
+
+	pool := NewPool(network.IP, network.Mask, start, end)
+	if context.DHCP.LeaseTime != 0 {
+		pool.LeaseTime = time.Duration(context.DHCP.LeaseTime) * time.Second
+	}
+
+	for _, s := range context.DHCP.DNS {
+		if ip := net.ParseIP(s).To4(); ip != nil {
+			pool.DNS = append(pool.DNS, ip)
+		}
+	}
+
+	return pool
+}
+
+// setPool configures pool of the port and restores its persisted leases.
+func (m *DHCPMechanism) setPool(port uint32, pool *Pool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var restored []mech.DHCPLease
+	for _, lease := range m.restored {
+		hwaddr, err := net.ParseMAC(lease.LinkAddr)
+		addr := net.ParseIP(lease.Addr)
+
+		if lease.Port != port || err != nil || !pool.Contains(addr) {
+			restored = append(restored, lease)
+			continue
+		}
+
+		pool.Restore(Lease{addr.To4(), hwaddr, lease.Expires, false})
+	}
+
+	m.restored = restored
+	m.pools[port] = pool
+}
+
+// delPool removes pool of the port, leases of the pool are kept
+// to be restored, when pool will be configured again.
+func (m *DHCPMechanism) delPool(port uint32, server net.IP) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	pool, ok := m.pools[port]
+	if !ok || !pool.ServerAddr.Equal(server) {
+		return false
+	}
+
+	for _, lease := range pool.Leases(time.Now()) {
+		m.restored = append(m.restored, mech.DHCPLease{
+			Addr:     lease.Addr.String(),
+			LinkAddr: lease.HWAddr.String(),
+			Port:     port,
+			Expires:  lease.Expires,
+		})
+	}
+
+	delete(m.pools, port)
+	return true
+}
+
+// pool returns pool of the port.
+func (m *DHCPMechanism) pool(port uint32) (*Pool, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	pool, ok := m.pools[port]
+	return pool, ok
+}
+
+func (m *DHCPMechanism) CreateNetworkPreCommit(context *mech.NetworkContext) error {
+	return m.UpdateNetworkPostCommit(context)
+}
+
+func (m *DHCPMechanism) UpdateNetworkPreCommit(context *mech.NetworkContext) error {
+	return m.DeleteNetworkPreCommit(context)
+}
+
+func (m *DHCPMechanism) UpdateNetworkPostCommit(context *mech.NetworkContext) error {
+	log.DebugLog("dhcp/UPDATE_NETWORK_POSTCOMMIT",
+		"Got update network request")
+
+	if context.DHCP != nil && len(context.DHCP.Relay) != 0 {
+		return m.updateRelay(context)
+	}
+
+	pool := newPool(context)
+	if pool == nil {
+		return nil
+	}
+
+	m.setPool(context.Port, pool)
+
+	// Send DHCP messages received on the port to the controller.
+	return m.addFlow(serverMatch(context.Port, context.VLAN), m.dhcpHandler)
+}
+
+// addFlow sends DHCP messages matching the flow to the handler.
+func (m *DHCPMechanism) addFlow(match ofp.Match, handler func(of.ResponseWriter, *of.Request)) error {
+	instructions := ofp.Instructions{ofp.InstructionActions{
+		ofp.IT_APPLY_ACTIONS,
+		ofp.Actions{ofp.ActionOutput{ofp.P_CONTROLLER, ofp.CML_NO_BUFFER}},
+	}}
+
+	flowMod := ofp.FlowMod{
+		Command:  ofp.FC_ADD,
+		BufferID: ofp.NO_BUFFER,
+		// Notify controller, when flow removed
+		Flags:        ofp.FF_SEND_FLOW_REM,
+		Priority:     40, // Use non-zero priority
+		Match:        match,
+		Instructions: instructions,
+	}
+
+	// Assign cookie to FlowMod message, and
+	// redirect such requests to the handler
+	m.cookies.FilterFunc(&flowMod, handler)
+
+	r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
+	if err != nil {
+		log.ErrorLog("dhcp/ADD_FLOW",
+			"Failed to create a new ofp_flow_mod request: ", err)
+		return err
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("dhcp/ADD_FLOW",
+			"Failed to send request: ", err)
+	}
+
+	return err
+}
+
+func (m *DHCPMechanism) DeleteNetworkPreCommit(context *mech.NetworkContext) error {
+	log.DebugLog("dhcp/DELETE_NETWORK_PRECOMMIT",
+		"Got network delete precommit request")
+
+	if !isIPv4(context.NetworkAddr) {
+		return nil
+	}
+
+	if _, ok := m.relay(context.Port); ok {
+		return m.deleteRelay(context)
+	}
+
+	// Skip addresses, that do not serve the pool of the port.
+	if !m.delPool(context.Port, net.IP(context.NetworkAddr.Bytes())) {
+		return nil
+	}
+
+	err := of.Send(m.C.Switch.Conn(), ofputil.FlowFlush(
+		0, serverMatch(context.Port, context.VLAN),
+	))
+
+	if err != nil {
+		log.ErrorLog("dhcp/DELETE_NETWORK_PRECOMMIT",
+			"Failed to send requests: ", err)
+	}
+
+	return err
+}
+
+func (m *DHCPMechanism) packetInHandler(rw of.ResponseWriter, r *of.Request) {
+	m.cookies.Serve(rw, r)
+}
+
+func (m *DHCPMechanism) flowRemovedHandler(rw of.ResponseWriter, r *of.Request) {
+	var flowRemoved ofp.FlowRemoved
+
+	_, err := of.ReadAllFrom(r.Body, &flowRemoved)
+	if err != nil {
+		return
+	}
+
+	m.cookies.Release(&flowRemoved)
+}
+
+// options fills configuration options of the pool.
+func options(pool *Pool, reply *Message) {
+	reply.Options.SetAddrs(OptServerID, pool.ServerAddr)
+	reply.Options.SetAddrs(OptRouter, pool.ServerAddr)
+	reply.Options[OptSubnetMask] = []byte(pool.Mask)
+
+	if len(pool.DNS) != 0 {
+		reply.Options.SetAddrs(OptDNS, pool.DNS...)
+	}
+
+	reply.Options.SetDuration(OptLeaseTime, pool.LeaseTime)
+	reply.Options.SetDuration(OptRenewalTime, pool.LeaseTime/2)
+	reply.Options.SetDuration(OptRebindingTime, pool.LeaseTime*7/8)
+}
+
+// serve returns reply to the client message, nil is returned, when message
+// should not be answered. Second value reports whether leases were changed.
+func serve(pool *Pool, request *Message, now time.Time) (*Message, bool) {
+	requested := request.Options.Addr(OptRequestedAddr)
+
+	switch request.Type() {
+	case Discover:
+		lease, err := pool.Offer(request.HWAddr, requested, now)
+		if err != nil {
+			log.ErrorLog("dhcp/DISCOVER",
+				"Failed to offer address: ", err)
+			return nil, false
+		}
+
+		reply := request.Reply(Offer)
+		reply.YourAddr = lease.Addr
+		options(pool, reply)
+		return reply, false
+
+	case Request:
+		// Client selected another server.
+		server := request.Options.Addr(OptServerID)
+		if server != nil && !server.Equal(pool.ServerAddr) {
+			return nil, false
+		}
+
+		// Client renews or rebinds the lease.
+		if requested == nil && !request.ClientAddr.Equal(net.IPv4zero) {
+			requested = request.ClientAddr
+		}
+
+		lease, err := pool.Ack(request.HWAddr, requested, now)
+		if err != nil {
+			reply := request.Reply(Nak)
+			reply.Options.SetAddrs(OptServerID, pool.ServerAddr)
+			return reply, false
+		}
+
+		reply := request.Reply(Ack)
+		reply.ClientAddr = request.ClientAddr
+		reply.YourAddr = lease.Addr
+		options(pool, reply)
+		return reply, true
+
+	case Decline:
+		pool.Decline(request.HWAddr, requested, now)
+		return nil, true
+
+	case Release:
+		pool.Release(request.HWAddr)
+		return nil, true
+	}
+
+	return nil, false
+}
+
+// readMessage reads DHCP message from the packet-in request.
+func readMessage(r *of.Request, pdu3 *mech.NetworkPacket, readers ...io.ReaderFrom) (*Message, error) {
+	if _, err := of.ReadAllFrom(r.Body, readers...); err != nil {
+		return nil, err
+	}
+
+	_, dstPort, payload, err := udp.ReadDatagram(r.Body, pdu3.ContentLen)
+	if err != nil {
+		return nil, err
+	}
+
+	if dstPort != ServerPort {
+		return nil, ErrFormat
+	}
+
+	var message Message
+	if err = message.UnmarshalBinary(payload); err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+// clientAddr returns address of the client, reply is sent to. Clients,
+// which can not receive unicast datagrams before address is configured,
+// request broadcast replies, true is returned in such case.
+func clientAddr(reply *Message) (net.IP, bool) {
+	if !reply.ClientAddr.Equal(net.IPv4zero) {
+		return reply.ClientAddr, false
+	}
+
+	if reply.Broadcast() || reply.Type() == Nak {
+		return net.IPv4bcast, true
+	}
+
+	return reply.YourAddr, false
+}
+
+// replyWriters returns link and network layer writers
+// of the reply to the client connected to the port.
+func replyWriters(lldriver mech.LinkDriver, nldriver mech.NetworkDriver,
+	port uint32, vlan uint16, server net.IP, reply *Message) (io.WriterTo, io.WriterTo, error) {
+
+	// Search for link layer address of egress port.
+	lladdr, err := lldriver.Addr(port)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err := reply.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dst, broadcast := clientAddr(reply)
+
+	dstLinkAddr := lldriver.CreateAddr(reply.HWAddr)
+	if broadcast {
+		dstLinkAddr = lldriver.CreateAddr(l2.HWBcast)
+	}
+
+	pdu2 := mech.LinkFrame{dstLinkAddr, lladdr, mech.Proto(iana.ETHT_IPV4), vlan, 0}
+	pdu3 := mech.NetworkPacket{
+		DstAddr: nldriver.CreateAddr(dst.To4(), nil),
+		SrcAddr: nldriver.CreateAddr(server.To4(), nil),
+		Proto:   mech.Proto(iana.IP_PROTO_UDP),
+		Payload: bytes.NewReader(udp.MakeDatagram(ServerPort, ClientPort, body)),
+	}
+
+	llwriter := mech.MakeLinkWriterTo(lldriver, &pdu2)
+	nlwriter := mech.MakeNetworkWriterTo(nldriver, &pdu3)
+
+	return llwriter, nlwriter, nil
+}
+
+func (m *DHCPMechanism) dhcpHandler(rw of.ResponseWriter, r *of.Request) {
+	var packet ofp.PacketIn
+	var pdu2 mech.LinkFrame
+	var pdu3 mech.NetworkPacket
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		return
+	}
+
+	llreader := mech.MakeLinkReaderFrom(lldriver, &pdu2)
+	nlreader := mech.MakeNetworkReaderFrom(nldriver, &pdu3)
+
+	request, err := readMessage(r, &pdu3, &packet, llreader, nlreader)
+	if err != nil {
+		log.ErrorLog("dhcp/PACKET_IN_HANDLER",
+			"Failed to read DHCP message: ", err)
+		return
+	}
+
+	// Relayed messages are not served.
+	if request.Op != OpRequest || !request.RelayAddr.Equal(net.IPv4zero) {
+		return
+	}
+
+	// Get port number from match fields.
+	portNo := packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.UInt32()
+
+	pool, ok := m.pool(portNo)
+	if !ok {
+		return
+	}
+
+	log.DebugLogf("dhcp/PACKET_IN_HANDLER",
+		"Got DHCP message %d from %s", request.Type(), request.HWAddr)
+
+	reply, changed := serve(pool, request, time.Now())
+	if changed {
+		m.persist()
+	}
+
+	if reply == nil {
+		return
+	}
+
+	llwriter, nlwriter, err := replyWriters(
+		lldriver, nldriver, portNo, pdu2.VLAN, pool.ServerAddr, reply,
+	)
+
+	if err != nil {
+		log.ErrorLog("dhcp/PACKET_IN_HANDLER",
+			"Failed to create DHCP reply: ", err)
+		return
+	}
+
+	packetOut := ofp.PacketOut{BufferID: ofp.NO_BUFFER,
+		InPort:  packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.PortNo(),
+		Actions: ofp.Actions{ofp.ActionOutput{ofp.P_IN_PORT, 0}},
+	}
+
+	_, err = of.WriteAllTo(rw, &packetOut, llwriter, nlwriter)
+	if err != nil {
+		log.ErrorLog("dhcp/PACKET_IN_HANDLER",
+			"Failed to write response: ", err)
+		return
+	}
+
+	rw.Header().Set(of.TypeHeaderKey, of.T_PACKET_OUT)
+	rw.Header().Set(of.VersionHeaderKey, ofp.VERSION)
+	if err = rw.WriteHeader(); err != nil {
+		log.ErrorLog("dhcp/PACKET_IN_HANDLER",
+			"Failed to send DHCP reply: ", err)
+	}
+}
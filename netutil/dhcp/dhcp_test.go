@@ -0,0 +1,101 @@
+package dhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDHCPServe(t *testing.T) {
+	pool := newTestPool()
+	pool.DNS = []net.IP{net.IPv4(8, 8, 8, 8).To4()}
+	now := time.Now()
+
+	hwaddr, _ := net.ParseMAC("00:00:00:00:00:01")
+	request := func(t MessageType) *Message {
+		return &Message{
+			Op:         OpRequest,
+			ClientAddr: net.IPv4zero,
+			RelayAddr:  net.IPv4zero,
+			HWAddr:     hwaddr,
+			Options:    Options{OptMessageType: {uint8(t)}},
+		}
+	}
+
+	offer, changed := serve(pool, request(Discover), now)
+	if offer == nil || offer.Type() != Offer || changed {
+		t.Fatal("Discover must be answered with offer:", offer)
+	}
+
+	if !offer.Options.Addr(OptRouter).Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Fatal("Interface address must be advertised as router:", offer.Options)
+	}
+
+	if !offer.Options.Addr(OptDNS).Equal(net.IPv4(8, 8, 8, 8)) {
+		t.Fatal("DNS server must be advertised:", offer.Options)
+	}
+
+	// Request of the address, offered by another server.
+	message := request(Request)
+	message.Options.SetAddrs(OptServerID, net.IPv4(10, 0, 0, 254))
+	if reply, _ := serve(pool, message, now); reply != nil {
+		t.Fatal("Request to another server must be ignored:", reply)
+	}
+
+	message = request(Request)
+	message.Options.SetAddrs(OptServerID, pool.ServerAddr)
+	message.Options.SetAddrs(OptRequestedAddr, offer.YourAddr)
+
+	ack, changed := serve(pool, message, now)
+	if ack == nil || ack.Type() != Ack || !changed || !ack.YourAddr.Equal(offer.YourAddr) {
+		t.Fatal("Request must be acknowledged:", ack)
+	}
+
+	message = request(Request)
+	message.Options.SetAddrs(OptRequestedAddr, net.IPv4(10, 0, 1, 2))
+	if nak, _ := serve(pool, message, now); nak == nil || nak.Type() != Nak {
+		t.Fatal("Request of unavailable address must be rejected:", nak)
+	}
+
+	if len(pool.Leases(now)) != 1 {
+		t.Fatal("Acknowledged lease must be active:", pool.Leases(now))
+	}
+
+	if _, changed = serve(pool, request(Release), now); !changed || len(pool.Leases(now)) != 0 {
+		t.Fatal("Released lease must be removed:", pool.Leases(now))
+	}
+
+	// Declined address must not be offered back to the client.
+	message = request(Decline)
+	message.Options.SetAddrs(OptRequestedAddr, offer.YourAddr)
+	if _, changed = serve(pool, message, now); !changed {
+		t.Fatal("Decline must change leases")
+	}
+
+	reply, _ := serve(pool, request(Discover), now)
+	if reply == nil || reply.YourAddr.Equal(offer.YourAddr) {
+		t.Fatal("Declined address must not be offered:", reply)
+	}
+}
+
+func TestDHCPClientAddr(t *testing.T) {
+	reply := &Message{
+		ClientAddr: net.IPv4zero,
+		YourAddr:   net.IPv4(10, 0, 0, 2),
+		Options:    Options{OptMessageType: {uint8(Offer)}},
+	}
+
+	if addr, bcast := clientAddr(reply); bcast || !addr.Equal(reply.YourAddr) {
+		t.Fatal("Reply must be sent to the assigned address:", addr)
+	}
+
+	reply.Flags = flagBroadcast
+	if addr, bcast := clientAddr(reply); !bcast || !addr.Equal(net.IPv4bcast) {
+		t.Fatal("Reply must be broadcasted on client request:", addr)
+	}
+
+	reply.ClientAddr = net.IPv4(10, 0, 0, 3)
+	if addr, bcast := clientAddr(reply); bcast || !addr.Equal(reply.ClientAddr) {
+		t.Fatal("Reply must be sent to the client address:", addr)
+	}
+}
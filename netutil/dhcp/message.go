@@ -0,0 +1,259 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sort"
+	"time"
+)
+
+// Ports of DHCP server and client.
+const (
+	ServerPort = 67
+	ClientPort = 68
+)
+
+// Operation codes of the message.
+const (
+	OpRequest = 1
+	OpReply   = 2
+)
+
+// MessageType is a type of DHCP message.
+type MessageType uint8
+
+const (
+	Discover MessageType = 1
+	Offer    MessageType = 2
+	Request  MessageType = 3
+	Decline  MessageType = 4
+	Ack      MessageType = 5
+	Nak      MessageType = 6
+	Release  MessageType = 7
+	Inform   MessageType = 8
+)
+
+// OptionCode is a code of DHCP option.
+type OptionCode uint8
+
+const (
+	OptPad           OptionCode = 0
+	OptSubnetMask    OptionCode = 1
+	OptRouter        OptionCode = 3
+	OptDNS           OptionCode = 6
+	OptRequestedAddr OptionCode = 50
+	OptLeaseTime     OptionCode = 51
+	OptMessageType   OptionCode = 53
+	OptServerID      OptionCode = 54
+	OptParamList     OptionCode = 55
+	OptRenewalTime   OptionCode = 58
+	OptRebindingTime OptionCode = 59
+	OptClientID      OptionCode = 61
+	OptEnd           OptionCode = 255
+)
+
+const (
+	// headerLen is a length of fixed part of the message.
+	headerLen = 236
+
+	// minLen is a minimal length of BOOTP message,
+	// shorter messages are dropped by some clients.
+	minLen = 300
+
+	// flagBroadcast requests broadcast replies.
+	flagBroadcast = 0x8000
+)
+
+// magicCookie starts options of the message.
+var magicCookie = []byte{99, 130, 83, 99}
+
+var (
+	// ErrFormat is returned on malformed DHCP messages.
+	ErrFormat = errors.New("dhcp: malformed message")
+)
+
+// Options is a set of DHCP options.
+type Options map[OptionCode][]byte
+
+// Addr returns address value of the option, nil is returned when option is absent.
+func (o Options) Addr(code OptionCode) net.IP {
+	if b := o[code]; len(b) == net.IPv4len {
+		return net.IP(b)
+	}
+
+	return nil
+}
+
+// SetAddrs sets option value to the list of addresses.
+func (o Options) SetAddrs(code OptionCode, addrs ...net.IP) {
+	var b []byte
+	for _, addr := range addrs {
+		b = append(b, addr.To4()...)
+	}
+
+	o[code] = b
+}
+
+// SetDuration sets option value to the number of seconds.
+func (o Options) SetDuration(code OptionCode, d time.Duration) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(d/time.Second))
+	o[code] = b
+}
+
+// Message is a DHCP message.
+type Message struct {
+	Op    uint8
+	HType uint8
+	Hops  uint8
+	XID   uint32
+	Secs  uint16
+	Flags uint16
+
+	// Client, assigned, next server and relay agent addresses.
+	ClientAddr net.IP
+	YourAddr   net.IP
+	ServerAddr net.IP
+	RelayAddr  net.IP
+
+	// Hardware address of the client.
+	HWAddr net.HardwareAddr
+
+	Options Options
+}
+
+// Type returns type of the message.
+func (m *Message) Type() MessageType {
+	if b := m.Options[OptMessageType]; len(b) == 1 {
+		return MessageType(b[0])
+	}
+
+	return 0
+}
+
+// Broadcast reports whether client requested broadcast replies.
+func (m *Message) Broadcast() bool {
+	return m.Flags&flagBroadcast != 0
+}
+
+// Reply returns a reply of the specified type to the message.
+func (m *Message) Reply(t MessageType) *Message {
+	return &Message{
+		Op:         OpReply,
+		HType:      m.HType,
+		XID:        m.XID,
+		Flags:      m.Flags,
+		ClientAddr: net.IPv4zero,
+		YourAddr:   net.IPv4zero,
+		ServerAddr: net.IPv4zero,
+		RelayAddr:  m.RelayAddr,
+		HWAddr:     m.HWAddr,
+		Options:    Options{OptMessageType: {uint8(t)}},
+	}
+}
+
+func readAddr(b []byte) net.IP {
+	return net.IPv4(b[0], b[1], b[2], b[3]).To4()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler interface.
+func (m *Message) UnmarshalBinary(b []byte) error {
+	if len(b) < headerLen+len(magicCookie) {
+		return ErrFormat
+	}
+
+	hlen := int(b[2])
+	if hlen > 16 {
+		return ErrFormat
+	}
+
+	m.Op, m.HType, m.Hops = b[0], b[1], b[3]
+	m.XID = binary.BigEndian.Uint32(b[4:8])
+	m.Secs = binary.BigEndian.Uint16(b[8:10])
+	m.Flags = binary.BigEndian.Uint16(b[10:12])
+	m.ClientAddr = readAddr(b[12:16])
+	m.YourAddr = readAddr(b[16:20])
+	m.ServerAddr = readAddr(b[20:24])
+	m.RelayAddr = readAddr(b[24:28])
+	m.HWAddr = net.HardwareAddr(append([]byte(nil), b[28:28+hlen]...))
+
+	b = b[headerLen:]
+	for i := range magicCookie {
+		if b[i] != magicCookie[i] {
+			return ErrFormat
+		}
+	}
+
+	m.Options = make(Options)
+	for b = b[len(magicCookie):]; len(b) > 0; {
+		code := OptionCode(b[0])
+		if code == OptEnd {
+			break
+		}
+
+		if code == OptPad {
+			b = b[1:]
+			continue
+		}
+
+		if len(b) < 2 || len(b) < int(b[1])+2 {
+			return ErrFormat
+		}
+
+		n := int(b[1]) + 2
+		m.Options[code] = append(m.Options[code], b[2:n]...)
+		b = b[n:]
+	}
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler interface.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	b := make([]byte, headerLen, minLen)
+
+	b[0], b[1], b[2], b[3] = m.Op, m.HType, uint8(len(m.HWAddr)), m.Hops
+	binary.BigEndian.PutUint32(b[4:8], m.XID)
+	binary.BigEndian.PutUint16(b[8:10], m.Secs)
+	binary.BigEndian.PutUint16(b[10:12], m.Flags)
+
+	copy(b[12:16], m.ClientAddr.To4())
+	copy(b[16:20], m.YourAddr.To4())
+	copy(b[20:24], m.ServerAddr.To4())
+	copy(b[24:28], m.RelayAddr.To4())
+	copy(b[28:44], m.HWAddr)
+
+	b = append(b, magicCookie...)
+
+	var codes []int
+	for code := range m.Options {
+		if code != OptMessageType {
+			codes = append(codes, int(code))
+		}
+	}
+
+	// Message type is written first, others
+	// are ordered to get reproducible messages.
+	sort.Ints(codes)
+	if _, ok := m.Options[OptMessageType]; ok {
+		codes = append([]int{int(OptMessageType)}, codes...)
+	}
+
+	for _, code := range codes {
+		value := m.Options[OptionCode(code)]
+		if len(value) > 255 {
+			return nil, ErrFormat
+		}
+
+		b = append(b, uint8(code), uint8(len(value)))
+		b = append(b, value...)
+	}
+
+	b = append(b, uint8(OptEnd))
+	for len(b) < minLen {
+		b = append(b, uint8(OptPad))
+	}
+
+	return b, nil
+}
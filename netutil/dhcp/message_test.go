@@ -0,0 +1,67 @@
+package dhcp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMessageMarshal(t *testing.T) {
+	hwaddr, _ := net.ParseMAC("00:00:00:00:00:01")
+
+	message := &Message{
+		Op:         OpRequest,
+		HType:      1,
+		XID:        0xdeadbeef,
+		Flags:      flagBroadcast,
+		ClientAddr: net.IPv4zero,
+		YourAddr:   net.IPv4zero,
+		ServerAddr: net.IPv4zero,
+		RelayAddr:  net.IPv4(10, 0, 0, 1),
+		HWAddr:     hwaddr,
+		Options:    Options{OptMessageType: {uint8(Discover)}},
+	}
+
+	message.Options.SetAddrs(OptRequestedAddr, net.IPv4(10, 0, 0, 10))
+	message.Options.SetDuration(OptLeaseTime, time.Hour)
+
+	b, err := message.MarshalBinary()
+	if err != nil {
+		t.Fatal("Failed to marshal message:", err)
+	}
+
+	if len(b) != minLen {
+		t.Fatal("Message must be padded to minimal length:", len(b))
+	}
+
+	// Message type option is written first.
+	if !bytes.Equal(b[headerLen+4:headerLen+7], []byte{53, 1, 1}) {
+		t.Fatal("Invalid first option:", b[headerLen+4:headerLen+7])
+	}
+
+	var parsed Message
+	if err = parsed.UnmarshalBinary(b); err != nil {
+		t.Fatal("Failed to unmarshal message:", err)
+	}
+
+	if parsed.Type() != Discover || parsed.XID != message.XID || !parsed.Broadcast() {
+		t.Fatal("Invalid header of parsed message:", parsed)
+	}
+
+	if parsed.HWAddr.String() != hwaddr.String() || !parsed.RelayAddr.Equal(message.RelayAddr) {
+		t.Fatal("Invalid addresses of parsed message:", parsed)
+	}
+
+	if !parsed.Options.Addr(OptRequestedAddr).Equal(net.IPv4(10, 0, 0, 10)) {
+		t.Fatal("Invalid requested address:", parsed.Options)
+	}
+
+	if !bytes.Equal(parsed.Options[OptLeaseTime], []byte{0, 0, 0x0e, 0x10}) {
+		t.Fatal("Invalid lease time:", parsed.Options[OptLeaseTime])
+	}
+
+	if err = parsed.UnmarshalBinary(b[:headerLen]); err != ErrFormat {
+		t.Fatal("Truncated message must not be accepted:", err)
+	}
+}
@@ -0,0 +1,248 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLeaseTime is a default lifetime of the lease.
+	DefaultLeaseTime = 24 * time.Hour
+
+	// OfferTime is a time, offered address is reserved for the client.
+	OfferTime = time.Minute
+
+	// DeclineTime is a time, address declined by the client
+	// is not offered to the clients.
+	DeclineTime = time.Hour
+)
+
+var (
+	// ErrPoolExhausted is returned, when there are no free addresses.
+	ErrPoolExhausted = errors.New("dhcp: pool exhausted")
+
+	// ErrAddrUnavailable is returned, when requested
+	// address could not be leased to the client.
+	ErrAddrUnavailable = errors.New("dhcp: address unavailable")
+)
+
+// Lease is an address leased to the client.
+type Lease struct {
+	// Leased address.
+	Addr net.IP
+
+	// Hardware address of the client.
+	HWAddr net.HardwareAddr
+
+	// Time of the lease expiration.
+	Expires time.Time
+
+	// Address is offered, but not acknowledged yet.
+	Offered bool
+}
+
+func ipv4ToInt(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func intToIPv4(n uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+// Pool is a pool of addresses leased to the hosts of the interface.
+type Pool struct {
+	// Address of the interface, it is used as server
+	// identifier and advertised as a router of hosts.
+	ServerAddr net.IP
+
+	// Network mask of the interface network.
+	Mask net.IPMask
+
+	// Addresses of domain name servers.
+	DNS []net.IP
+
+	// Lifetime of leases.
+	LeaseTime time.Duration
+
+	// First and last addresses of the pool.
+	start, end uint32
+
+	// Leases indexed by hardware address of the client, addresses
+	// declined by clients are indexed by the address itself.
+	leases map[string]*Lease
+	lock   sync.RWMutex
+}
+
+// NewPool creates a new pool of addresses from start to end inclusive.
+func NewPool(server net.IP, mask net.IPMask, start, end net.IP) *Pool {
+	return &Pool{
+		ServerAddr: server.To4(),
+		Mask:       mask,
+		LeaseTime:  DefaultLeaseTime,
+		start:      ipv4ToInt(start),
+		end:        ipv4ToInt(end),
+		leases:     make(map[string]*Lease),
+	}
+}
+
+// Contains reports whether address belongs to the pool.
+func (p *Pool) Contains(ip net.IP) bool {
+	if ip = ip.To4(); ip == nil {
+		return false
+	}
+
+	n := ipv4ToInt(ip)
+	return n >= p.start && n <= p.end && !ip.Equal(p.ServerAddr)
+}
+
+// owner returns hardware address of the client, that holds the address.
+func (p *Pool) owner(ip net.IP, now time.Time) (string, bool) {
+	for hwaddr, lease := range p.leases {
+		if lease.Addr.Equal(ip) && lease.Expires.After(now) {
+			return hwaddr, true
+		}
+	}
+
+	return "", false
+}
+
+// free reports whether address could be leased to the client.
+func (p *Pool) free(ip net.IP, hwaddr string, now time.Time) bool {
+	owner, ok := p.owner(ip, now)
+	return p.Contains(ip) && (!ok || owner == hwaddr)
+}
+
+// Offer reserves address for the client, previously leased or
+// requested address is offered, when it is still available.
+func (p *Pool) Offer(hwaddr net.HardwareAddr, requested net.IP, now time.Time) (*Lease, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := hwaddr.String()
+
+	if lease, ok := p.leases[key]; ok && p.free(lease.Addr, key, now) {
+		if lease.Offered || !lease.Expires.After(now) {
+			lease.Offered, lease.Expires = true, now.Add(OfferTime)
+		}
+
+		copied := *lease
+		return &copied, nil
+	}
+
+	addr := requested
+	if addr == nil || !p.free(addr, key, now) {
+		addr = nil
+
+		for n := p.start; n <= p.end && n >= p.start; n++ {
+			if ip := intToIPv4(n); p.free(ip, key, now) {
+				addr = ip
+				break
+			}
+		}
+	}
+
+	if addr == nil {
+		return nil, ErrPoolExhausted
+	}
+
+	lease := &Lease{addr.To4(), hwaddr, now.Add(OfferTime), true}
+	p.leases[key] = lease
+
+	copied := *lease
+	return &copied, nil
+}
+
+// Ack confirms lease of the requested address to the client.
+func (p *Pool) Ack(hwaddr net.HardwareAddr, requested net.IP, now time.Time) (*Lease, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := hwaddr.String()
+	if requested == nil || !p.free(requested, key, now) {
+		return nil, ErrAddrUnavailable
+	}
+
+	lease := &Lease{requested.To4(), hwaddr, now.Add(p.LeaseTime), false}
+	p.leases[key] = lease
+
+	copied := *lease
+	return &copied, nil
+}
+
+// Release releases address leased to the client.
+func (p *Pool) Release(hwaddr net.HardwareAddr) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.leases, hwaddr.String())
+}
+
+// Decline releases address leased to the client and marks it unavailable
+// for the DeclineTime, since the address is already in use on the network.
+func (p *Pool) Decline(hwaddr net.HardwareAddr, declined net.IP, now time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := hwaddr.String()
+	if lease, ok := p.leases[key]; ok && declined == nil {
+		declined = lease.Addr
+	}
+
+	delete(p.leases, key)
+
+	if declined == nil || !p.Contains(declined) {
+		return
+	}
+
+	// Reserve the address without an owner, so it would
+	// not be offered to any client until reservation expires.
+	declined = declined.To4()
+	p.leases[declined.String()] = &Lease{declined, nil, now.Add(DeclineTime), false}
+}
+
+// Restore restores persisted lease of the pool.
+func (p *Pool) Restore(lease Lease) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.Contains(lease.Addr) {
+		p.leases[lease.HWAddr.String()] = &lease
+	}
+}
+
+// Leases returns acknowledged leases, which are not expired, ordered by address.
+// Declined addresses are not listed.
+func (p *Pool) Leases(now time.Time) []Lease {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	var leases []Lease
+	for _, lease := range p.leases {
+		if !lease.Offered && lease.HWAddr != nil && lease.Expires.After(now) {
+			leases = append(leases, *lease)
+		}
+	}
+
+	sort.Sort(leasesByAddr(leases))
+	return leases
+}
+
+type leasesByAddr []Lease
+
+func (l leasesByAddr) Len() int {
+	return len(l)
+}
+
+func (l leasesByAddr) Less(i, j int) bool {
+	return ipv4ToInt(l[i].Addr) < ipv4ToInt(l[j].Addr)
+}
+
+func (l leasesByAddr) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
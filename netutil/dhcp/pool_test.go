@@ -0,0 +1,120 @@
+package dhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestPool() *Pool {
+	return NewPool(
+		net.IPv4(10, 0, 0, 1), net.CIDRMask(24, 32),
+		net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 3),
+	)
+}
+
+func TestPoolOffer(t *testing.T) {
+	pool := newTestPool()
+	now := time.Now()
+
+	hwaddr1, _ := net.ParseMAC("00:00:00:00:00:01")
+	hwaddr2, _ := net.ParseMAC("00:00:00:00:00:02")
+	hwaddr3, _ := net.ParseMAC("00:00:00:00:00:03")
+
+	// Server address is never offered.
+	lease, err := pool.Offer(hwaddr1, nil, now)
+	if err != nil || !lease.Addr.Equal(net.IPv4(10, 0, 0, 2)) {
+		t.Fatal("Failed to offer the first free address:", lease, err)
+	}
+
+	// The same address is offered on retransmission.
+	lease, err = pool.Offer(hwaddr1, net.IPv4(10, 0, 0, 3), now)
+	if err != nil || !lease.Addr.Equal(net.IPv4(10, 0, 0, 2)) {
+		t.Fatal("Offered address must be kept:", lease, err)
+	}
+
+	// Requested address is offered, when it is free.
+	lease, err = pool.Offer(hwaddr2, net.IPv4(10, 0, 0, 2), now)
+	if err != nil || !lease.Addr.Equal(net.IPv4(10, 0, 0, 3)) {
+		t.Fatal("Address offered to other client must be skipped:", lease, err)
+	}
+
+	if _, err = pool.Offer(hwaddr3, nil, now); err != ErrPoolExhausted {
+		t.Fatal("Pool must be exhausted:", err)
+	}
+
+	// Not confirmed offers expire.
+	lease, err = pool.Offer(hwaddr3, nil, now.Add(OfferTime))
+	if err != nil || !lease.Addr.Equal(net.IPv4(10, 0, 0, 2)) {
+		t.Fatal("Expired offer must be reused:", lease, err)
+	}
+}
+
+func TestPoolAck(t *testing.T) {
+	pool := newTestPool()
+	now := time.Now()
+
+	hwaddr1, _ := net.ParseMAC("00:00:00:00:00:01")
+	hwaddr2, _ := net.ParseMAC("00:00:00:00:00:02")
+
+	if _, err := pool.Ack(hwaddr1, net.IPv4(10, 0, 1, 2), now); err != ErrAddrUnavailable {
+		t.Fatal("Address outside of the pool must not be leased:", err)
+	}
+
+	lease, err := pool.Ack(hwaddr1, net.IPv4(10, 0, 0, 2), now)
+	if err != nil || !lease.Expires.Equal(now.Add(DefaultLeaseTime)) {
+		t.Fatal("Failed to lease address:", lease, err)
+	}
+
+	if _, err = pool.Ack(hwaddr2, net.IPv4(10, 0, 0, 2), now); err != ErrAddrUnavailable {
+		t.Fatal("Leased address must not be leased twice:", err)
+	}
+
+	leases := pool.Leases(now)
+	if len(leases) != 1 || leases[0].HWAddr.String() != hwaddr1.String() {
+		t.Fatal("Invalid list of leases:", leases)
+	}
+
+	pool.Release(hwaddr1)
+	if leases = pool.Leases(now); len(leases) != 0 {
+		t.Fatal("Released lease must be removed:", leases)
+	}
+}
+
+func TestPoolDecline(t *testing.T) {
+	pool := newTestPool()
+	now := time.Now()
+
+	hwaddr1, _ := net.ParseMAC("00:00:00:00:00:01")
+	hwaddr2, _ := net.ParseMAC("00:00:00:00:00:02")
+
+	lease, err := pool.Ack(hwaddr1, net.IPv4(10, 0, 0, 2), now)
+	if err != nil {
+		t.Fatal("Failed to lease address:", lease, err)
+	}
+
+	pool.Decline(hwaddr1, nil, now)
+	if leases := pool.Leases(now); len(leases) != 0 {
+		t.Fatal("Declined lease must be removed:", leases)
+	}
+
+	// Declined address is not offered to the same client.
+	lease, err = pool.Offer(hwaddr1, net.IPv4(10, 0, 0, 2), now)
+	if err != nil || !lease.Addr.Equal(net.IPv4(10, 0, 0, 3)) {
+		t.Fatal("Declined address must be skipped:", lease, err)
+	}
+
+	if _, err = pool.Offer(hwaddr2, nil, now); err != ErrPoolExhausted {
+		t.Fatal("Declined address must not be offered:", err)
+	}
+
+	if _, err = pool.Ack(hwaddr2, net.IPv4(10, 0, 0, 2), now); err != ErrAddrUnavailable {
+		t.Fatal("Declined address must not be leased:", err)
+	}
+
+	// Address is available again, when reservation expires.
+	lease, err = pool.Offer(hwaddr2, nil, now.Add(DeclineTime))
+	if err != nil || !lease.Addr.Equal(net.IPv4(10, 0, 0, 2)) {
+		t.Fatal("Expired declined address must be reused:", lease, err)
+	}
+}
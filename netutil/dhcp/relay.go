@@ -0,0 +1,357 @@
+package dhcp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+
+	"github.com/netrack/net/iana"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ip.v4"
+	"github.com/netrack/netrack/netutil/udp"
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+	"github.com/netrack/openflow/ofp.v13/ofputil"
+)
+
+// MaxHops is a maximum number of relay agents, request could pass.
+const MaxHops = 16
+
+// errMechanism is returned, when mechanism required
+// to relay messages is not available on the switch.
+var errMechanism = errors.New("dhcp: mechanism is not available")
+
+// Relay is a relay agent of the interface, it forwards requests
+// of hosts to DHCP servers and replies of servers back to hosts.
+type Relay struct {
+	// Addresses of DHCP servers.
+	Servers []net.IP
+
+	// VLAN identifier of the interface.
+	VLAN uint16
+}
+
+// newRelay returns relay agent of the interface.
+func newRelay(context *mech.NetworkContext) *Relay {
+	relay := &Relay{VLAN: context.VLAN}
+
+	for _, s := range context.DHCP.Relay {
+		if ip := net.ParseIP(s).To4(); ip != nil {
+			relay.Servers = append(relay.Servers, ip)
+		}
+	}
+
+	return relay
+}
+
+// relayMatch matches DHCP replies of servers sent to the relay agent address.
+func relayMatch(addr mech.NetworkAddr) ofp.Match {
+	return ofp.Match{ofp.MT_OXM, []ofp.OXM{
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_TYPE, of.Bytes(iana.ETHT_IPV4), nil},
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IPV4_DST, addr.Bytes(), nil},
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IP_PROTO, of.Bytes(iana.IP_PROTO_UDP), nil},
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_UDP_DST, of.Bytes(uint16(ServerPort)), nil},
+	}}
+}
+
+// relay returns relay agent of the port.
+func (m *DHCPMechanism) relay(port uint32) (*Relay, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	relay, ok := m.relays[port]
+	return relay, ok
+}
+
+// relayOf returns port of the relay agent, which address is assigned
+// to the interface, requests of hosts are relayed from.
+func (m *DHCPMechanism) relayOf(nldriver mech.NetworkDriver, addr net.IP) (uint32, *Relay, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	for port, relay := range m.relays {
+		for _, nladdr := range nldriver.Addrs(port) {
+			if net.IP(nladdr.Bytes()).Equal(addr) {
+				return port, relay, true
+			}
+		}
+	}
+
+	return 0, nil, false
+}
+
+func (m *DHCPMechanism) updateRelay(context *mech.NetworkContext) error {
+	if !isIPv4(context.NetworkAddr) {
+		return nil
+	}
+
+	m.lock.Lock()
+	m.relays[context.Port] = newRelay(context)
+	m.lock.Unlock()
+
+	// Relay requests of hosts connected to the port.
+	err := m.addFlow(serverMatch(context.Port, context.VLAN), m.relayHandler)
+	if err != nil {
+		return err
+	}
+
+	// Relay replies of servers back to hosts.
+	return m.addFlow(relayMatch(context.NetworkAddr), m.relayHandler)
+}
+
+func (m *DHCPMechanism) deleteRelay(context *mech.NetworkContext) error {
+	requests := []*of.Request{
+		ofputil.FlowFlush(0, relayMatch(context.NetworkAddr)),
+	}
+
+	// Requests are relayed, while other
+	// addresses are assigned to the port.
+	if !context.Shared() {
+		m.lock.Lock()
+		delete(m.relays, context.Port)
+		m.lock.Unlock()
+
+		requests = append(requests, ofputil.FlowFlush(
+			0, serverMatch(context.Port, context.VLAN),
+		))
+	}
+
+	err := of.Send(m.C.Switch.Conn(), requests...)
+	if err != nil {
+		log.ErrorLog("dhcp/DELETE_RELAY",
+			"Failed to send requests: ", err)
+	}
+
+	return err
+}
+
+// routing returns IPv4 routing mechanism of the switch.
+func (m *DHCPMechanism) routing() (*ip.IPv4Routing, error) {
+	var routing mech.RoutingMechanismManager
+	if err := m.C.Managers.Obtain(&routing); err != nil {
+		return nil, err
+	}
+
+	rmech, err := routing.Mechanism(ip.IPv4RoutingName)
+	if err != nil {
+		return nil, err
+	}
+
+	ipv4Mech, ok := rmech.(*ip.IPv4Routing)
+	if !ok {
+		return nil, errMechanism
+	}
+
+	return ipv4Mech, nil
+}
+
+// arp returns ARP mechanism of the switch.
+func (m *DHCPMechanism) arp() (*ip.ARPMechanism, error) {
+	var network mech.NetworkMechanismManager
+	if err := m.C.Managers.Obtain(&network); err != nil {
+		return nil, err
+	}
+
+	nmech, err := network.Mechanism(ip.ARPMechanismName)
+	if err != nil {
+		return nil, err
+	}
+
+	arpMech, ok := nmech.(*ip.ARPMechanism)
+	if !ok {
+		return nil, errMechanism
+	}
+
+	return arpMech, nil
+}
+
+// forward sends relayed request to the DHCP server
+// through the port of the route to the server.
+func (m *DHCPMechanism) forward(lldriver mech.LinkDriver, nldriver mech.NetworkDriver,
+	src, server net.IP, body []byte) error {
+
+	routing, err := m.routing()
+	if err != nil {
+		return err
+	}
+
+	arpMech, err := m.arp()
+	if err != nil {
+		return err
+	}
+
+	dst := nldriver.CreateAddr(server, nil)
+
+	route, ok := routing.Lookup(dst)
+	if !ok {
+		log.DebugLogf("dhcp/RELAY_FORWARD",
+			"Route to %s not found", server)
+		return nil
+	}
+
+	nexthop := route.NextHop
+	if nexthop == nil {
+		nexthop = dst
+	}
+
+	dstLinkAddr, err := arpMech.Lookup(nexthop, route.Port)
+	if err != nil {
+		return err
+	}
+
+	// Search for link layer address of egress port.
+	srcLinkAddr, err := lldriver.Addr(route.Port)
+	if err != nil {
+		return err
+	}
+
+	pdu2 := mech.LinkFrame{dstLinkAddr, srcLinkAddr,
+		mech.Proto(iana.ETHT_IPV4), arpMech.VLAN(route.Port), 0,
+	}
+
+	pdu3 := mech.NetworkPacket{
+		DstAddr: dst,
+		SrcAddr: nldriver.CreateAddr(src, nil),
+		Proto:   mech.Proto(iana.IP_PROTO_UDP),
+		Payload: bytes.NewReader(udp.MakeDatagram(ServerPort, ServerPort, body)),
+	}
+
+	packetOut := ofp.PacketOut{
+		BufferID: ofp.NO_BUFFER,
+		InPort:   ofp.P_CONTROLLER,
+		Actions:  ofp.Actions{ofp.ActionOutput{ofp.PortNo(route.Port), 0}},
+	}
+
+	llwriter := mech.MakeLinkWriterTo(lldriver, &pdu2)
+	nlwriter := mech.MakeNetworkWriterTo(nldriver, &pdu3)
+
+	r, err := of.NewRequest(of.T_PACKET_OUT, of.NewReader(&packetOut, llwriter, nlwriter))
+	if err != nil {
+		return err
+	}
+
+	return of.Send(m.C.Switch.Conn(), r)
+}
+
+// relayRequest forwards request of the host connected
+// to the port to DHCP servers of the relay agent.
+func (m *DHCPMechanism) relayRequest(lldriver mech.LinkDriver, nldriver mech.NetworkDriver,
+	port uint32, request *Message) {
+
+	relay, ok := m.relay(port)
+	if !ok || request.Hops >= MaxHops {
+		return
+	}
+
+	// Address of the interface is used by servers
+	// to select a pool and to send replies back.
+	nladdr, err := nldriver.Addr(port)
+	if err != nil {
+		log.ErrorLog("dhcp/RELAY_REQUEST",
+			"Failed to retrieve port network address: ", err)
+		return
+	}
+
+	src := net.IP(nladdr.Bytes())
+
+	// Address of the first relay agent is preserved.
+	if request.RelayAddr.Equal(net.IPv4zero) {
+		request.RelayAddr = src
+	}
+
+	request.Hops++
+
+	body, err := request.MarshalBinary()
+	if err != nil {
+		log.ErrorLog("dhcp/RELAY_REQUEST",
+			"Failed to marshal DHCP request: ", err)
+		return
+	}
+
+	for _, server := range relay.Servers {
+		err = m.forward(lldriver, nldriver, src, server, body)
+		if err != nil {
+			log.ErrorLogf("dhcp/RELAY_REQUEST",
+				"Failed to relay request to %s: %s", server, err)
+		}
+	}
+}
+
+// relayReply sends reply of DHCP server to the host out
+// of the port, request of the host was received on.
+func (m *DHCPMechanism) relayReply(lldriver mech.LinkDriver, nldriver mech.NetworkDriver,
+	reply *Message) {
+
+	port, relay, ok := m.relayOf(nldriver, reply.RelayAddr)
+	if !ok {
+		return
+	}
+
+	llwriter, nlwriter, err := replyWriters(
+		lldriver, nldriver, port, relay.VLAN, reply.RelayAddr, reply,
+	)
+
+	if err != nil {
+		log.ErrorLog("dhcp/RELAY_REPLY",
+			"Failed to create DHCP reply: ", err)
+		return
+	}
+
+	packetOut := ofp.PacketOut{
+		BufferID: ofp.NO_BUFFER,
+		InPort:   ofp.P_CONTROLLER,
+		Actions:  ofp.Actions{ofp.ActionOutput{ofp.PortNo(port), 0}},
+	}
+
+	r, err := of.NewRequest(of.T_PACKET_OUT, of.NewReader(&packetOut, llwriter, nlwriter))
+	if err != nil {
+		log.ErrorLog("dhcp/RELAY_REPLY",
+			"Failed to create a new ofp_packet_out request: ", err)
+		return
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("dhcp/RELAY_REPLY",
+			"Failed to send DHCP reply: ", err)
+	}
+}
+
+func (m *DHCPMechanism) relayHandler(rw of.ResponseWriter, r *of.Request) {
+	var packet ofp.PacketIn
+	var pdu2 mech.LinkFrame
+	var pdu3 mech.NetworkPacket
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		return
+	}
+
+	llreader := mech.MakeLinkReaderFrom(lldriver, &pdu2)
+	nlreader := mech.MakeNetworkReaderFrom(nldriver, &pdu3)
+
+	message, err := readMessage(r, &pdu3, &packet, llreader, nlreader)
+	if err != nil {
+		log.ErrorLog("dhcp/RELAY_HANDLER",
+			"Failed to read DHCP message: ", err)
+		return
+	}
+
+	log.DebugLogf("dhcp/RELAY_HANDLER",
+		"Got DHCP message %d of %s", message.Type(), message.HWAddr)
+
+	switch message.Op {
+	case OpRequest:
+		// Get port number from match fields.
+		portNo := packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.UInt32()
+		m.relayRequest(lldriver, nldriver, portNo, message)
+	case OpReply:
+		m.relayReply(lldriver, nldriver, message)
+	}
+}
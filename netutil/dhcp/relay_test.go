@@ -0,0 +1,23 @@
+package dhcp
+
+import (
+	"net"
+	"testing"
+
+	"github.com/netrack/netrack/mechanism"
+)
+
+func TestNewRelay(t *testing.T) {
+	relay := newRelay(&mech.NetworkContext{
+		VLAN: 10,
+		DHCP: &mech.DHCPConfig{Relay: []string{"10.0.1.1", "invalid", "10.0.2.1"}},
+	})
+
+	if relay.VLAN != 10 || len(relay.Servers) != 2 {
+		t.Fatal("Invalid relay agent:", relay)
+	}
+
+	if !relay.Servers[1].Equal(net.IPv4(10, 0, 2, 1)) {
+		t.Fatal("Invalid server address:", relay.Servers)
+	}
+}
@@ -1,6 +1,8 @@
 package drivers
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -8,13 +10,25 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/netrack/net/iana"
-	"github.com/netrack/net/l2"
 	"github.com/netrack/netrack/mechanism"
 )
 
 const EthernetDriverName = "ieee-802.3"
 
+// VLANEtherType is a tag protocol identifier of 802.1Q header.
+const VLANEtherType = 0x8100
+
+const (
+	// Length of destination, source addresses and type fields.
+	ethernetHeaderLen = 14
+
+	// Length of 802.1Q tag control information and type fields.
+	vlanTagLen = 4
+
+	// Mask of VLAN identifier in tag control information.
+	vlanMask = 0x0fff
+)
+
 func init() {
 	constructor := mech.LinkDriverConstructorFunc(NewEthernetLinkDriver)
 	mech.RegisterLinkDriver(EthernetDriverName, constructor)
@@ -106,26 +120,50 @@ func (d *EthernetLinkDriver) Addr(port uint32) (mech.LinkAddr, error) {
 }
 
 func (d *EthernetLinkDriver) ReadFrame(r io.Reader) (*mech.LinkFrame, error) {
-	var eth l2.EthernetII
+	var header [ethernetHeaderLen]byte
 
-	_, err := eth.ReadFrom(r)
-	if err != nil {
+	if _, err := io.ReadFull(r, header[:]); err != nil {
 		return nil, err
 	}
 
-	length := int64(len(eth.HWSrc)+len(eth.HWDst)) + 2
 	frame := &mech.LinkFrame{
-		DstAddr: EthernetAddr(eth.HWDst),
-		SrcAddr: EthernetAddr(eth.HWSrc),
-		Proto:   mech.Proto(eth.EthType),
-		Len:     length,
+		DstAddr: EthernetAddr(append([]byte(nil), header[0:6]...)),
+		SrcAddr: EthernetAddr(append([]byte(nil), header[6:12]...)),
+		Proto:   mech.Proto(binary.BigEndian.Uint16(header[12:14])),
+		Len:     ethernetHeaderLen,
+	}
+
+	if frame.Proto != mech.Proto(VLANEtherType) {
+		return frame, nil
 	}
 
+	// Tag control information is followed by the payload type.
+	var tag [vlanTagLen]byte
+	if _, err := io.ReadFull(r, tag[:]); err != nil {
+		return nil, err
+	}
+
+	frame.VLAN = binary.BigEndian.Uint16(tag[0:2]) & vlanMask
+	frame.Proto = mech.Proto(binary.BigEndian.Uint16(tag[2:4]))
+	frame.Len += vlanTagLen
+
 	return frame, nil
 }
 
 func (d *EthernetLinkDriver) WriteFrame(w io.Writer, f *mech.LinkFrame) error {
-	eth := l2.EthernetII{f.DstAddr.Bytes(), f.SrcAddr.Bytes(), iana.EthType(f.Proto)}
-	_, err := eth.WriteTo(w)
+	var buf bytes.Buffer
+
+	buf.Write(f.DstAddr.Bytes())
+	buf.Write(f.SrcAddr.Bytes())
+
+	// Emit 802.1Q header for tagged frames.
+	if f.VLAN != 0 {
+		binary.Write(&buf, binary.BigEndian, uint16(VLANEtherType))
+		binary.Write(&buf, binary.BigEndian, f.VLAN&vlanMask)
+	}
+
+	binary.Write(&buf, binary.BigEndian, uint16(f.Proto))
+
+	_, err := buf.WriteTo(w)
 	return err
 }
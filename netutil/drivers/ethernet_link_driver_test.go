@@ -0,0 +1,52 @@
+package drivers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/netrack/netrack/mechanism"
+)
+
+func TestEthernetLinkDriverFrame(t *testing.T) {
+	driver := NewEthernetLinkDriver()
+
+	dst := EthernetAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	src := EthernetAddr{0x02, 0, 0, 0, 0, 1}
+
+	tests := []struct {
+		frame mech.LinkFrame
+		len   int64
+	}{
+		{mech.LinkFrame{DstAddr: dst, SrcAddr: src, Proto: 0x0806}, 14},
+		{mech.LinkFrame{DstAddr: dst, SrcAddr: src, Proto: 0x0800, VLAN: 10}, 18},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+
+		if err := driver.WriteFrame(&buf, &test.frame); err != nil {
+			t.Fatal("Failed to write frame:", err)
+		}
+
+		if int64(buf.Len()) != test.len {
+			t.Fatal("Invalid length of written frame:", buf.Len())
+		}
+
+		frame, err := driver.ReadFrame(&buf)
+		if err != nil {
+			t.Fatal("Failed to read frame:", err)
+		}
+
+		if frame.Len != test.len || frame.VLAN != test.frame.VLAN {
+			t.Fatal("Invalid tag of read frame:", frame)
+		}
+
+		if frame.Proto != test.frame.Proto {
+			t.Fatal("Invalid payload type of read frame:", frame.Proto)
+		}
+
+		if !bytes.Equal(frame.SrcAddr.Bytes(), src) || !bytes.Equal(frame.DstAddr.Bytes(), dst) {
+			t.Fatal("Invalid addresses of read frame:", frame)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -19,6 +20,9 @@ var IPv4HostMask = net.IPMask{255, 255, 255, 255}
 var (
 	IPv4AddrErr = errors.New(
 		"ipv4: there is no network layer address associated with this port")
+
+	IPv4FormatErr = errors.New(
+		"ipv4: invalid IPv4 address")
 )
 
 func init() {
@@ -65,14 +69,15 @@ func (a *IPv4Addr) Mask() mech.NetworkMask {
 type IPv4Driver struct {
 	mech.BaseNetworkDriver
 
-	// Mapping of network addresses to switch ports.
-	addrs map[uint32]mech.NetworkAddr
+	// Mapping of network addresses to switch ports,
+	// the first address of the port is a primary one.
+	addrs map[uint32][]mech.NetworkAddr
 	lock  sync.RWMutex
 }
 
 func NewIPv4Driver() mech.NetworkDriver {
 	return &IPv4Driver{
-		addrs: make(map[uint32]mech.NetworkAddr),
+		addrs: make(map[uint32][]mech.NetworkAddr),
 	}
 }
 
@@ -90,6 +95,11 @@ func (d *IPv4Driver) ParseAddr(s string) (mech.NetworkAddr, error) {
 		netw = &net.IPNet{nil, IPv4HostMask}
 	}
 
+	// IPv6 addresses are handled by IPv6 driver.
+	if ip.To4() == nil || len(netw.Mask) != net.IPv4len {
+		return nil, IPv4FormatErr
+	}
+
 	return &IPv4Addr{ip, netw.Mask}, nil
 }
 
@@ -105,31 +115,57 @@ func (d *IPv4Driver) Addr(port uint32) (mech.NetworkAddr, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
 
-	if addr, ok := d.addrs[port]; ok {
-		return addr, nil
+	if addrs := d.addrs[port]; len(addrs) != 0 {
+		return addrs[0], nil
 	}
 
 	return nil, IPv4AddrErr
 }
 
+func (d *IPv4Driver) Addrs(port uint32) []mech.NetworkAddr {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return append([]mech.NetworkAddr(nil), d.addrs[port]...)
+}
+
 func (d *IPv4Driver) UpdateAddr(port uint32, addr mech.NetworkAddr) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
-	d.addrs[port] = addr
+	addrs := d.addrs[port]
+	for i, nladdr := range addrs {
+		if bytes.Equal(nladdr.Bytes(), addr.Bytes()) {
+			addrs[i] = addr
+			return nil
+		}
+	}
+
+	d.addrs[port] = append(addrs, addr)
 	return nil
 }
 
-func (d *IPv4Driver) DeleteAddr(port uint32) error {
+func (d *IPv4Driver) DeleteAddr(port uint32, addr mech.NetworkAddr) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
-	if _, ok := d.addrs[port]; !ok {
-		return IPv4AddrErr
+	addrs := d.addrs[port]
+	for i, nladdr := range addrs {
+		if !bytes.Equal(nladdr.Bytes(), addr.Bytes()) {
+			continue
+		}
+
+		addrs = append(addrs[:i], addrs[i+1:]...)
+		if len(addrs) == 0 {
+			delete(d.addrs, port)
+		} else {
+			d.addrs[port] = addrs
+		}
+
+		return nil
 	}
 
-	delete(d.addrs, port)
-	return nil
+	return IPv4AddrErr
 }
 
 func (d *IPv4Driver) ReadPacket(r io.Reader) (*mech.NetworkPacket, error) {
@@ -0,0 +1,234 @@
+package drivers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+
+	"github.com/netrack/netrack/mechanism"
+)
+
+const IPv6DriverName = "ipv6"
+
+const (
+	// IPv6HeaderLen is a length of IPv6 fixed header.
+	IPv6HeaderLen = 40
+
+	// IPv6HopLimit is a hop limit of packets originated by controller,
+	// neighbor discovery messages are accepted only with the maximum value.
+	IPv6HopLimit = 255
+)
+
+var IPv6HostMask = net.CIDRMask(128, 128)
+
+var (
+	IPv6AddrErr = errors.New(
+		"ipv6: there is no network layer address associated with this port")
+
+	IPv6FormatErr = errors.New(
+		"ipv6: invalid IPv6 address")
+
+	IPv6VersionErr = errors.New(
+		"ipv6: invalid version of the packet")
+)
+
+func init() {
+	constructor := mech.NetworkDriverConstructorFunc(NewIPv6Driver)
+	mech.RegisterNetworkDriver(IPv6DriverName, constructor)
+}
+
+type IPv6Mask struct {
+	mask net.IPMask
+}
+
+func (m *IPv6Mask) Len() int {
+	ones, _ := m.mask.Size()
+	return ones
+}
+
+func (m *IPv6Mask) Bytes() []byte {
+	return []byte(m.mask)
+}
+
+type IPv6Addr struct {
+	ip   net.IP
+	mask net.IPMask
+}
+
+func (a *IPv6Addr) String() string {
+	ones, _ := a.mask.Size()
+	return fmt.Sprintf("%s/%d", a.ip, ones)
+}
+
+func (a *IPv6Addr) Contains(nladdr mech.NetworkAddr) bool {
+	network := net.IPNet{a.ip.Mask(a.mask), a.mask}
+	return network.Contains(net.IP(nladdr.Bytes()))
+}
+
+func (a *IPv6Addr) Bytes() []byte {
+	return []byte(a.ip.To16())
+}
+
+func (a *IPv6Addr) Mask() mech.NetworkMask {
+	return &IPv6Mask{a.mask}
+}
+
+type IPv6Driver struct {
+	mech.BaseNetworkDriver
+
+	// Mapping of network addresses to switch ports,
+	// the first address of the port is a primary one.
+	addrs map[uint32][]mech.NetworkAddr
+	lock  sync.RWMutex
+}
+
+func NewIPv6Driver() mech.NetworkDriver {
+	return &IPv6Driver{
+		addrs: make(map[uint32][]mech.NetworkAddr),
+	}
+}
+
+func (d *IPv6Driver) Name() string {
+	return IPv6DriverName
+}
+
+func (d *IPv6Driver) ParseAddr(s string) (mech.NetworkAddr, error) {
+	ip, netw, err := net.ParseCIDR(s)
+	if err != nil {
+		if ip = net.ParseIP(s); ip == nil {
+			return nil, err
+		}
+
+		netw = &net.IPNet{nil, IPv6HostMask}
+	}
+
+	// IPv4 addresses are handled by IPv4 driver.
+	if ip.To4() != nil || len(netw.Mask) != net.IPv6len {
+		return nil, IPv6FormatErr
+	}
+
+	return &IPv6Addr{ip, netw.Mask}, nil
+}
+
+func (d *IPv6Driver) CreateAddr(addr []byte, mask []byte) mech.NetworkAddr {
+	if mask == nil {
+		mask = IPv6HostMask
+	}
+
+	return &IPv6Addr{addr, mask}
+}
+
+func (d *IPv6Driver) Addr(port uint32) (mech.NetworkAddr, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	if addrs := d.addrs[port]; len(addrs) != 0 {
+		return addrs[0], nil
+	}
+
+	return nil, IPv6AddrErr
+}
+
+func (d *IPv6Driver) Addrs(port uint32) []mech.NetworkAddr {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return append([]mech.NetworkAddr(nil), d.addrs[port]...)
+}
+
+func (d *IPv6Driver) UpdateAddr(port uint32, addr mech.NetworkAddr) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	addrs := d.addrs[port]
+	for i, nladdr := range addrs {
+		if bytes.Equal(nladdr.Bytes(), addr.Bytes()) {
+			addrs[i] = addr
+			return nil
+		}
+	}
+
+	d.addrs[port] = append(addrs, addr)
+	return nil
+}
+
+func (d *IPv6Driver) DeleteAddr(port uint32, addr mech.NetworkAddr) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	addrs := d.addrs[port]
+	for i, nladdr := range addrs {
+		if !bytes.Equal(nladdr.Bytes(), addr.Bytes()) {
+			continue
+		}
+
+		addrs = append(addrs[:i], addrs[i+1:]...)
+		if len(addrs) == 0 {
+			delete(d.addrs, port)
+		} else {
+			d.addrs[port] = addrs
+		}
+
+		return nil
+	}
+
+	return IPv6AddrErr
+}
+
+// ReadPacket reads fixed header of IPv6 packet, extension
+// headers are left in the reader as a part of payload.
+func (d *IPv6Driver) ReadPacket(r io.Reader) (*mech.NetworkPacket, error) {
+	var header [IPv6HeaderLen]byte
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	if header[0]>>4 != 6 {
+		return nil, IPv6VersionErr
+	}
+
+	dst := make(net.IP, net.IPv6len)
+	src := make(net.IP, net.IPv6len)
+
+	copy(src, header[8:24])
+	copy(dst, header[24:40])
+
+	packet := &mech.NetworkPacket{
+		DstAddr:    &IPv6Addr{dst, IPv6HostMask},
+		SrcAddr:    &IPv6Addr{src, IPv6HostMask},
+		Proto:      mech.Proto(header[6]),
+		Len:        IPv6HeaderLen,
+		ContentLen: int64(binary.BigEndian.Uint16(header[4:6])),
+	}
+
+	return packet, nil
+}
+
+func (d *IPv6Driver) WritePacket(w io.Writer, p *mech.NetworkPacket) error {
+	payload, err := ioutil.ReadAll(p.Payload)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	header := make([]byte, 8)
+	header[0] = 6 << 4
+	binary.BigEndian.PutUint16(header[4:6], uint16(len(payload)))
+	header[6] = uint8(p.Proto)
+	header[7] = IPv6HopLimit
+
+	buf.Write(header)
+	buf.Write(net.IP(p.SrcAddr.Bytes()).To16())
+	buf.Write(net.IP(p.DstAddr.Bytes()).To16())
+	buf.Write(payload)
+
+	_, err = buf.WriteTo(w)
+	return err
+}
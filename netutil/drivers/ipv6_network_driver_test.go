@@ -0,0 +1,123 @@
+package drivers
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/netrack/netrack/mechanism"
+)
+
+func TestIPv6DriverParseAddr(t *testing.T) {
+	driver := NewIPv6Driver()
+
+	network, err := driver.ParseAddr("2001:db8:1::1/64")
+	if err != nil {
+		t.Fatal("Failed to parse IPv6 address:", err)
+	}
+
+	if network.String() != "2001:db8:1::1/64" || network.Mask().Len() != 64 {
+		t.Fatal("Invalid parsed IPv6 address:", network)
+	}
+
+	host, err := driver.ParseAddr("2001:db8:1::20")
+	if err != nil || host.Mask().Len() != 128 {
+		t.Fatal("Failed to parse IPv6 host address:", host, err)
+	}
+
+	if !network.Contains(host) {
+		t.Fatal("Network must contain host:", host)
+	}
+
+	if other, _ := driver.ParseAddr("2001:db8:2::1"); network.Contains(other) {
+		t.Fatal("Network must not contain host:", other)
+	}
+
+	if _, err = driver.ParseAddr("10.0.0.1/24"); err == nil {
+		t.Fatal("IPv4 address must not be accepted")
+	}
+}
+
+func TestIPv6DriverPacket(t *testing.T) {
+	driver := NewIPv6Driver()
+
+	src := driver.CreateAddr(net.ParseIP("fe80::1"), nil)
+	dst := driver.CreateAddr(net.ParseIP("ff02::1"), nil)
+	payload := []byte{128, 0, 0, 0}
+
+	var buf bytes.Buffer
+
+	err := driver.WritePacket(&buf, &mech.NetworkPacket{
+		DstAddr: dst,
+		SrcAddr: src,
+		Proto:   58,
+		Payload: bytes.NewReader(payload),
+	})
+
+	if err != nil {
+		t.Fatal("Failed to write packet:", err)
+	}
+
+	if buf.Len() != IPv6HeaderLen+len(payload) {
+		t.Fatal("Invalid length of written packet:", buf.Len())
+	}
+
+	packet, err := driver.ReadPacket(&buf)
+	if err != nil {
+		t.Fatal("Failed to read packet:", err)
+	}
+
+	if packet.Proto != 58 || packet.ContentLen != int64(len(payload)) {
+		t.Fatal("Invalid header of read packet:", packet)
+	}
+
+	if !bytes.Equal(packet.SrcAddr.Bytes(), src.Bytes()) ||
+		!bytes.Equal(packet.DstAddr.Bytes(), dst.Bytes()) {
+		t.Fatal("Invalid addresses of read packet:", packet)
+	}
+
+	if !bytes.Equal(buf.Bytes(), payload) {
+		t.Fatal("Payload must be left in the reader:", buf.Bytes())
+	}
+}
+
+func TestIPv6DriverAddrs(t *testing.T) {
+	driver := NewIPv6Driver()
+
+	global, _ := driver.ParseAddr("2001:db8:1::1/64")
+	secondary, _ := driver.ParseAddr("2001:db8:2::1/64")
+
+	driver.UpdateAddr(1, global)
+	driver.UpdateAddr(1, secondary)
+
+	if addrs := driver.Addrs(1); len(addrs) != 2 {
+		t.Fatal("Both addresses must be assigned to the port:", addrs)
+	}
+
+	// Address with the same host part replaces assigned one.
+	updated, _ := driver.ParseAddr("2001:db8:1::1/48")
+	driver.UpdateAddr(1, updated)
+
+	primary, err := driver.Addr(1)
+	if err != nil || primary.String() != "2001:db8:1::1/48" {
+		t.Fatal("Primary address must be updated:", primary, err)
+	}
+
+	if err = driver.DeleteAddr(1, global); err != nil {
+		t.Fatal("Failed to delete address:", err)
+	}
+
+	primary, err = driver.Addr(1)
+	if err != nil || primary.String() != secondary.String() {
+		t.Fatal("Secondary address must become primary:", primary, err)
+	}
+
+	if err = driver.DeleteAddr(1, global); err != IPv6AddrErr {
+		t.Fatal("Deleted address must not be found:", err)
+	}
+
+	driver.DeleteAddr(1, secondary)
+	if _, err = driver.Addr(1); err != IPv6AddrErr {
+		t.Fatal("Port must not have addresses:", err)
+	}
+}
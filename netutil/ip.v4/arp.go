@@ -1,8 +1,11 @@
 package ip
 
 import (
+	"errors"
 	"net"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/netrack/net/iana"
 	"github.com/netrack/net/l2"
@@ -11,6 +14,8 @@ import (
 	"github.com/netrack/netrack/mechanism"
 	"github.com/netrack/netrack/mechanism/mechutil"
 	"github.com/netrack/netrack/mechanism/rpc"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ofp.v13"
 	"github.com/netrack/openflow"
 	"github.com/netrack/openflow/ofp.v13"
 	"github.com/netrack/openflow/ofp.v13/ofputil"
@@ -18,18 +23,49 @@ import (
 
 const ARPMechanismName = "arp"
 
+// ARPLookupTimeout is a time to wait for the ARP reply on lookup.
+const ARPLookupTimeout = 3 * time.Second
+
+// ErrARPTimeout is returned, when ARP reply
+// was not received in ARPLookupTimeout.
+var ErrARPTimeout = errors.New("arp: lookup timed out")
+
 func init() {
 	constructor := mech.NetworkMechanismConstructorFunc(NewARPMechanism)
 	mech.RegisterNetworkMechanism(ARPMechanismName, constructor)
 }
 
+// isIPv4 reports whether address belongs to IPv4 network, addresses
+// of other network protocols are handled by other mechanisms.
+func isIPv4(addr mech.NetworkAddr) bool {
+	return len(addr.Bytes()) == net.IPv4len
+}
+
+// NeighWatcher is the interface implemented by mechanisms,
+// that forward packets to the neighbors resolved by ARP.
+type NeighWatcher interface {
+	// NeighChanged is called, when a new neighbor is
+	// learned or link layer address of neighbor changed.
+	NeighChanged(mechutil.NeighEntry)
+
+	// NeighRemoved is called, when neighbor is forgotten,
+	// because the port it was learned on went down.
+	NeighRemoved(mechutil.NeighEntry)
+
+	// VLANChanged is called, when VLAN identifier
+	// of the interface of the port changed.
+	VLANChanged(port uint32)
+}
+
 // ARPMechanism handles ARP requests to the networks,
 // associated with switch ports.
 type ARPMechanism struct {
 	mech.BaseNetworkMechanism
 
-	// Handle request based on cookie value.
-	cookies *of.CookieFilter
+	// Handle request based on cookie value, filter is
+	// replaced, when mechanism is deactivated.
+	cookies     *mech.CookieFilter
+	cookiesLock sync.RWMutex
 
 	// Stores registered handlers to delete them later.
 	filter *of.ServeFilter
@@ -40,27 +76,147 @@ type ARPMechanism struct {
 	// Table number allocated for the mechanism.
 	tableNo int
 
-	requests map[string][]chan bool
+	// VLAN identifiers of interfaces of switch ports.
+	vlans     map[uint32]uint16
+	vlansLock sync.RWMutex
+
+	// Mechanisms notified about changes of neighbors.
+	watchers     map[string]NeighWatcher
+	watchersLock sync.RWMutex
+
+	// Waiters of ARP replies, error is sent to
+	// waiters, when request could not be resolved.
+	requests map[string][]chan error
 	lock     sync.Mutex
 }
 
 func NewARPMechanism() mech.NetworkMechanism {
 	return &ARPMechanism{
 		filter:     of.NewServeFilter(),
-		cookies:    of.NewCookieFilter(),
-		requests:   make(map[string][]chan bool),
+		cookies:    mech.NewCookieFilter(),
+		requests:   make(map[string][]chan error),
 		neighTable: mechutil.NewNeighTable(),
+		vlans:      make(map[uint32]uint16),
+		watchers:   make(map[string]NeighWatcher),
+	}
+}
+
+// Watch registers watcher of neighbors under the name,
+// watcher registered under the same name is replaced.
+func (m *ARPMechanism) Watch(name string, w NeighWatcher) {
+	m.watchersLock.Lock()
+	defer m.watchersLock.Unlock()
+
+	m.watchers[name] = w
+}
+
+// Unwatch removes watcher registered under the name.
+func (m *ARPMechanism) Unwatch(name string) {
+	m.watchersLock.Lock()
+	defer m.watchersLock.Unlock()
+
+	delete(m.watchers, name)
+}
+
+// notify calls function for each registered watcher.
+func (m *ARPMechanism) notify(fn func(NeighWatcher)) {
+	m.watchersLock.RLock()
+
+	var watchers []NeighWatcher
+	for _, w := range m.watchers {
+		watchers = append(watchers, w)
+	}
+
+	m.watchersLock.RUnlock()
+
+	for _, w := range watchers {
+		fn(w)
+	}
+}
+
+// learn updates neighbor table and notifies
+// watchers, when the neighbor changed.
+func (m *ARPMechanism) learn(entry mechutil.NeighEntry) {
+	if !m.neighTable.Populate(entry) {
+		return
+	}
+
+	m.notify(func(w NeighWatcher) {
+		w.NeighChanged(entry)
+	})
+}
+
+// Neighbor returns link layer address of the resolved
+// neighbor, no ARP requests are sent to the network.
+func (m *ARPMechanism) Neighbor(addr mech.NetworkAddr) (mech.LinkAddr, bool) {
+	neigh, ok := m.neighTable.Lookup(addr)
+	return neigh.LinkAddr, ok
+}
+
+// VLAN returns VLAN identifier of the interface
+// of the port, zero is returned for untagged interface.
+func (m *ARPMechanism) VLAN(port uint32) uint16 {
+	m.vlansLock.RLock()
+	defer m.vlansLock.RUnlock()
+
+	return m.vlans[port]
+}
+
+// VLANs returns sorted list of VLAN identifiers of
+// interfaces, untagged interfaces are not included.
+func (m *ARPMechanism) VLANs() []uint16 {
+	m.vlansLock.RLock()
+	defer m.vlansLock.RUnlock()
+
+	seen := make(map[uint16]bool)
+
+	var vlans []int
+	for _, vlan := range m.vlans {
+		if !seen[vlan] {
+			seen[vlan] = true
+			vlans = append(vlans, int(vlan))
+		}
+	}
+
+	sort.Ints(vlans)
+
+	ids := make([]uint16, 0, len(vlans))
+	for _, vlan := range vlans {
+		ids = append(ids, uint16(vlan))
+	}
+
+	return ids
+}
+
+func (m *ARPMechanism) setVLAN(port uint32, vlan uint16) {
+	m.vlansLock.Lock()
+
+	changed := m.vlans[port] != vlan
+	if vlan == 0 {
+		delete(m.vlans, port)
+	} else {
+		m.vlans[port] = vlan
+	}
+
+	m.vlansLock.Unlock()
+
+	if changed {
+		m.notify(func(w NeighWatcher) {
+			w.VLANChanged(port)
+		})
 	}
 }
 
-func (m *ARPMechanism) createRequest(nladdr mech.NetworkAddr) <-chan bool {
+func (m *ARPMechanism) createRequest(nladdr mech.NetworkAddr) <-chan error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
 	log.DebugLog("arp/CREATE_REQUEST",
 		"Create request for: ", nladdr)
 
-	waitCh := make(chan bool)
+	// Waiter could leave before the reply, so
+	// the channel is buffered to not block sender.
+	waitCh := make(chan error, 1)
 	channels := m.requests[nladdr.String()]
 
 	channels = append(channels, waitCh)
@@ -69,6 +225,26 @@ func (m *ARPMechanism) createRequest(nladdr mech.NetworkAddr) <-chan bool {
 	return waitCh
 }
 
+// cancelRequest removes waiter, that stopped waiting for the reply.
+func (m *ARPMechanism) cancelRequest(nladdr mech.NetworkAddr, wait <-chan error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var channels []chan error
+	for _, ch := range m.requests[nladdr.String()] {
+		if ch != wait {
+			channels = append(channels, ch)
+		}
+	}
+
+	if len(channels) == 0 {
+		delete(m.requests, nladdr.String())
+		return
+	}
+
+	m.requests[nladdr.String()] = channels
+}
+
 func (m *ARPMechanism) releaseRequest(nladdr mech.NetworkAddr) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -83,13 +259,36 @@ func (m *ARPMechanism) releaseRequest(nladdr mech.NetworkAddr) {
 
 		go func() {
 			defer close(ch)
-			ch <- true
+			ch <- nil
 		}()
 	}
 
 	delete(m.requests, nladdr.String())
 }
 
+// releaseRequests sends specified error to all waiters.
+func (m *ARPMechanism) releaseRequests(err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	log.DebugLog("arp/RELEASE_REQUESTS",
+		"Release all requests with error: ", err)
+
+	for _, channels := range m.requests {
+		for _, channel := range channels {
+			// To prevent enclosing of the variable
+			ch := channel
+
+			go func() {
+				defer close(ch)
+				ch <- err
+			}()
+		}
+	}
+
+	m.requests = make(map[string][]chan error)
+}
+
 func (m *ARPMechanism) Name() string {
 	return ARPMechanismName
 }
@@ -98,6 +297,11 @@ func (m *ARPMechanism) Description() string {
 	return "MISSING DESCRIPTION!"
 }
 
+// Version implements VersionedMechanism interface.
+func (m *ARPMechanism) Version() string {
+	return ofp13.ProtoVersion
+}
+
 // Enable implements Mechanism interface
 func (m *ARPMechanism) Enable(c *mech.MechanismContext) {
 	m.BaseMechanism.Enable(c)
@@ -111,6 +315,9 @@ func (m *ARPMechanism) Enable(c *mech.MechanismContext) {
 	// Handle removed flows notifications
 	m.filter.HandleFunc(of.T_FLOW_REMOVED, m.flowRemovedHandler)
 
+	// Neighbors behind the ports, that went down, are gone.
+	m.C.Ports.Subscribe(m.Name(), m.portHandler)
+
 	log.InfoLog("arp/ENABLE_HOOK", "Mechanism ARP enabled")
 }
 
@@ -119,7 +326,7 @@ func (m *ARPMechanism) Activate() {
 	m.BaseMechanism.Activate()
 
 	// Operate on PacketIn messages
-	m.cookies.Baker = ofputil.PacketInBaker()
+	m.cookieFilter().Baker = ofputil.PacketInBaker()
 
 	// Allocate table for handling arp protocol.
 	tableNo, err := m.C.Switch.AllocateTable()
@@ -178,6 +385,10 @@ func (m *ARPMechanism) Disable() {
 
 	// Remove installed handlers
 	m.filter.Unhandle()
+	m.C.Ports.Unsubscribe(m.Name())
+
+	// Nobody will answer pending requests.
+	m.releaseRequests(mech.ErrSwitchDisconnected)
 
 	// Match packets of ARP protocol.
 	match := ofp.Match{ofp.MT_OXM, []ofp.OXM{
@@ -202,6 +413,53 @@ func (m *ARPMechanism) Disable() {
 	log.InfoLog("arp/DISABLE_HOOK", "Mechanism ARP disabled")
 }
 
+// Deactivate implements Mechanism interface.
+func (m *ARPMechanism) Deactivate() {
+	m.BaseMechanism.Deactivate()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+	m.C.Ports.Unsubscribe(m.Name())
+
+	// Unblock goroutines waiting for ARP replies.
+	m.releaseRequests(mech.ErrSwitchDisconnected)
+
+	// Drop handlers of cookies of installed flows, packet-in
+	// handlers could still be serving the previous filter.
+	m.cookiesLock.Lock()
+	m.cookies = mech.NewCookieFilter()
+	m.cookiesLock.Unlock()
+
+	// Release acquired table
+	m.C.Switch.ReleaseTable(m.tableNo)
+
+	log.InfoLog("arp/DEACTIVATE_HOOK", "Mechanism ARP deactivated")
+}
+
+// OwnsFlow implements mech.FlowOwner interface.
+func (m *ARPMechanism) OwnsFlow(table int, cookie uint64) bool {
+	return table != 0 && table == m.tableNo ||
+		m.cookieFilter().Contains(cookie)
+}
+
+func (m *ARPMechanism) portHandler(event mech.PortEvent) {
+	switch event.Type {
+	case mech.PortDown, mech.PortDeleted:
+	default:
+		return
+	}
+
+	for _, neigh := range m.neighTable.DeletePort(event.Port.Number) {
+		log.DebugLogf("arp/PORT_HANDLER",
+			"Port %s is %s, forgetting neighbor %s",
+			event.Port.Name, event.Type, neigh.NetworkAddr)
+
+		m.notify(func(w NeighWatcher) {
+			w.NeighRemoved(neigh)
+		})
+	}
+}
+
 func (m *ARPMechanism) CreateNetworkPreCommit(context *mech.NetworkContext) error {
 	return m.UpdateNetworkPostCommit(context)
 }
@@ -214,9 +472,17 @@ func (m *ARPMechanism) UpdateNetworkPostCommit(context *mech.NetworkContext) err
 	log.DebugLog("arp/UPDATE_NETWORK_POSTCOMMIT",
 		"Got update network request")
 
+	if !isIPv4(context.NetworkAddr) {
+		return nil
+	}
+
+	// Sub-interfaces of trunk ports receive tagged frames.
+	m.setVLAN(context.Port, context.VLAN)
+
 	// Match broadcast ARP requests to resolve updated address.
 	match := ofp.Match{ofp.MT_OXM, []ofp.OXM{
 		ofputil.EthType(uint16(iana.ETHT_ARP), nil),
+		ofp13.VLANMatch(context.VLAN),
 		ofputil.ARPOpType(uint16(l3.ARPOT_REQUEST), nil),
 		ofputil.ARPTargetHWAddr(l2.HWUnspec, nil),
 		ofputil.ARPTargetProtoAddr(context.NetworkAddr.Bytes(), nil),
@@ -247,7 +513,7 @@ func (m *ARPMechanism) UpdateNetworkPostCommit(context *mech.NetworkContext) err
 
 	// Assign cookie to FlowMod message, and
 	// redirect such requests to arpRequestHandler
-	m.cookies.FilterFunc(&flowMod, m.arpRequestHandler)
+	m.cookieFilter().FilterFunc(&flowMod, m.arpRequestHandler)
 
 	// Insert flow into ARP-allocated flow table.
 	arpRequest, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
@@ -260,6 +526,7 @@ func (m *ARPMechanism) UpdateNetworkPostCommit(context *mech.NetworkContext) err
 	// Match direct messages to receive ARP responses.
 	match = ofp.Match{ofp.MT_OXM, []ofp.OXM{
 		ofputil.EthType(uint16(iana.ETHT_ARP), nil),
+		ofp13.VLANMatch(context.VLAN),
 		ofputil.EthDstAddr(context.LinkAddr.Bytes(), nil),
 		ofputil.ARPOpType(uint16(l3.ARPOT_REPLY), nil),
 		ofputil.ARPTargetProtoAddr(context.NetworkAddr.Bytes(), nil),
@@ -287,7 +554,7 @@ func (m *ARPMechanism) UpdateNetworkPostCommit(context *mech.NetworkContext) err
 		Instructions: instructions,
 	}
 
-	m.cookies.FilterFunc(&flowMod, m.arpReplyHandler)
+	m.cookieFilter().FilterFunc(&flowMod, m.arpReplyHandler)
 
 	// Insert flow into ARP-allocated flow table.
 	arpReply, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
@@ -314,6 +581,16 @@ func (m *ARPMechanism) DeleteNetworkPreCommit(context *mech.NetworkContext) erro
 	log.DebugLog("arp/DELETE_NETWORK_PRECOMMIT",
 		"Got delete network request")
 
+	if !isIPv4(context.NetworkAddr) {
+		return nil
+	}
+
+	// Keep VLAN of the interface, while other
+	// IPv4 addresses are assigned to the port.
+	if !context.Shared() {
+		m.setVLAN(context.Port, 0)
+	}
+
 	match := ofp.Match{ofp.MT_OXM, []ofp.OXM{
 		ofputil.EthType(uint16(iana.ETHT_ARP), nil),
 		ofputil.ARPTargetProtoAddr(context.NetworkAddr.Bytes(), nil),
@@ -331,9 +608,17 @@ func (m *ARPMechanism) DeleteNetworkPreCommit(context *mech.NetworkContext) erro
 	return err
 }
 
+// cookieFilter returns filter of cookies of installed flows.
+func (m *ARPMechanism) cookieFilter() *mech.CookieFilter {
+	m.cookiesLock.RLock()
+	defer m.cookiesLock.RUnlock()
+
+	return m.cookies
+}
+
 func (m *ARPMechanism) packetInHandler(rw of.ResponseWriter, r *of.Request) {
 	// Serve message based on PacketIn cookies.
-	m.cookies.Serve(rw, r)
+	m.cookieFilter().Serve(rw, r)
 }
 
 func (m *ARPMechanism) flowRemovedHandler(rw of.ResponseWriter, r *of.Request) {
@@ -344,7 +629,7 @@ func (m *ARPMechanism) flowRemovedHandler(rw of.ResponseWriter, r *of.Request) {
 		return
 	}
 
-	m.cookies.Release(&flowRemoved)
+	m.cookieFilter().Release(&flowRemoved)
 }
 
 func (m *ARPMechanism) arpRequestHandler(rw of.ResponseWriter, r *of.Request) {
@@ -360,7 +645,7 @@ func (m *ARPMechanism) arpRequestHandler(rw of.ResponseWriter, r *of.Request) {
 		return
 	}
 
-	nldriver, err := mech.NetworkDrv(m.C)
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
 	if err != nil {
 		return
 	}
@@ -388,7 +673,7 @@ func (m *ARPMechanism) arpRequestHandler(rw of.ResponseWriter, r *of.Request) {
 	}
 
 	// Update neighbor table with a new lladdr
-	m.neighTable.Populate(mechutil.NeighEntry{
+	m.learn(mechutil.NeighEntry{
 		NetworkAddr: nldriver.CreateAddr(pdu3.ProtoSrc, nil),
 		LinkAddr:    pdu2.SrcAddr,
 		Port:        portNo,
@@ -398,7 +683,8 @@ func (m *ARPMechanism) arpRequestHandler(rw of.ResponseWriter, r *of.Request) {
 		"Resolve network layer address %s -> %s", pdu3.ProtoDst, lladdr)
 
 	// Build link layer PDU.
-	pdu2 = mech.LinkFrame{pdu2.SrcAddr, lladdr, mech.Proto(iana.ETHT_ARP), 0}
+	// Reply through the same VLAN.
+	pdu2 = mech.LinkFrame{pdu2.SrcAddr, lladdr, mech.Proto(iana.ETHT_ARP), pdu2.VLAN, 0}
 
 	// Build ARP response message.
 	pdu3 = l3.ARP{l3.ARPT_ETHERNET, iana.ETHT_IPV4, l3.ARPOT_REPLY,
@@ -442,7 +728,7 @@ func (m *ARPMechanism) arpReplyHandler(rw of.ResponseWriter, r *of.Request) {
 		return
 	}
 
-	nldriver, err := mech.NetworkDrv(m.C)
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
 	if err != nil {
 		return
 	}
@@ -461,7 +747,7 @@ func (m *ARPMechanism) arpReplyHandler(rw of.ResponseWriter, r *of.Request) {
 	portNo := packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.UInt32()
 	nladdr := nldriver.CreateAddr(pdu3.ProtoSrc, nil)
 
-	m.neighTable.Populate(mechutil.NeighEntry{
+	m.learn(mechutil.NeighEntry{
 		NetworkAddr: nladdr,
 		LinkAddr:    pdu2.SrcAddr,
 		Port:        portNo,
@@ -490,6 +776,9 @@ func resolveFuncWrapper(param rpc.Param, result rpc.Result) (err error) {
 	return err
 }
 
+// Lookup returns link layer address of the neighbor, ARP request is
+// sent through the port, when the address is not resolved yet, and
+// the call blocks until the reply is received.
 func (m *ARPMechanism) Lookup(addr mech.NetworkAddr, port uint32) (mech.LinkAddr, error) {
 	log.DebugLog("arp/ARP_LOOKUP",
 		"Got requests to lookup address: ", addr)
@@ -499,30 +788,65 @@ func (m *ARPMechanism) Lookup(addr mech.NetworkAddr, port uint32) (mech.LinkAddr
 		return neigh.LinkAddr, nil
 	}
 
+	// Create waiter for specified network address
+	wait := m.createRequest(addr)
+
+	if err := m.Request(addr, port); err != nil {
+		m.cancelRequest(addr, wait)
+		return nil, err
+	}
+
+	// Wait for response
+	select {
+	case err := <-wait:
+		if err != nil {
+			log.ErrorLog("arp/ARP_LOOKUP",
+				"Failed to wait for ARP reply: ", err)
+			return nil, err
+		}
+	case <-time.After(ARPLookupTimeout):
+		m.cancelRequest(addr, wait)
+		log.ErrorLog("arp/ARP_LOOKUP",
+			"Failed to wait for ARP reply: ", ErrARPTimeout)
+		return nil, ErrARPTimeout
+	}
+
+	neigh, _ := m.neighTable.Lookup(addr)
+	return neigh.LinkAddr, nil
+}
+
+// Request sends ARP request to resolve the address through the
+// port without waiting for reply, watchers are notified, when
+// the neighbor is learned.
+func (m *ARPMechanism) Request(addr mech.NetworkAddr, port uint32) error {
+	log.DebugLog("arp/ARP_REQUEST",
+		"Got request to resolve address: ", addr)
+
 	lldriver, err := mech.LinkDrv(m.C)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	nldriver, err := mech.NetworkDrv(m.C)
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Get link layer address associated with egress port.
 	lladdr, err := lldriver.Addr(port)
 	if err != nil {
-		log.ErrorLogf("arp/ARP_LOOKUP",
+		log.ErrorLogf("arp/ARP_REQUEST",
 			"Failed to resolve port '%d' hardware address: '%s'", port, err)
-		return nil, err
+		return err
 	}
 
-	// Get network layer address associated with egress port.
-	nladdr, err := nldriver.Addr(port)
+	// Get network layer address of egress port, that
+	// belongs to the same network, as target address.
+	nladdr, err := mech.SourceAddr(nldriver, port, addr)
 	if err != nil {
-		log.ErrorLogf("arp/ARP_LOOKUP",
+		log.ErrorLogf("arp/ARP_REQUEST",
 			"Failed to resolve port '%d' network address: '%s'", port, err)
-		return nil, err
+		return err
 	}
 
 	// Start long process of discovery
@@ -544,35 +868,26 @@ func (m *ARPMechanism) Lookup(addr mech.NetworkAddr, port uint32) (mech.LinkAddr
 
 	llbcast := lldriver.CreateAddr(l2.HWBcast)
 	llwriter := mech.MakeLinkWriterTo(lldriver, &mech.LinkFrame{
-		llbcast, lladdr, mech.Proto(iana.ETHT_ARP), 0,
+		llbcast, lladdr, mech.Proto(iana.ETHT_ARP), m.VLAN(port), 0,
 	})
 
 	r, err := of.NewRequest(of.T_PACKET_OUT, of.NewReader(&packetOut, llwriter, &arp))
 	if err != nil {
-		log.ErrorLog("arp/ARP_LOOKUP",
+		log.ErrorLog("arp/ARP_REQUEST",
 			"Failed to create a new ofp_packet_out request: ", err)
-		return nil, err
+		return err
 	}
 
-	// Create waiter for specified network address
-	wait := m.createRequest(addr)
-
 	if err = m.C.Switch.Conn().Send(r); err != nil {
-		log.ErrorLog("arp/ARP_LOOKUP",
+		log.ErrorLog("arp/ARP_REQUEST",
 			"Failed to send an ARP request: ", err)
-		return nil, err
+		return err
 	}
 
 	if err = m.C.Switch.Conn().Flush(); err != nil {
-		log.ErrorLog("arp/ARP_LOOKUP",
+		log.ErrorLog("arp/ARP_REQUEST",
 			"Failed to flush data to connection: ", err)
-		return nil, err
 	}
 
-	//TODO: create timeout waiter
-	// Wait for response
-	<-wait
-
-	neigh, _ := m.neighTable.Lookup(addr)
-	return neigh.LinkAddr, nil
+	return err
 }
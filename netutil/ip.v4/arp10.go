@@ -0,0 +1,396 @@
+package ip
+
+import (
+	"net"
+
+	"github.com/netrack/net/iana"
+	"github.com/netrack/net/l2"
+	"github.com/netrack/net/l3"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/mechanism/mechutil"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ofp.v10"
+	"github.com/netrack/openflow"
+)
+
+const ARP10MechanismName = "arp-ofp1.0"
+
+func init() {
+	constructor := mech.NetworkMechanismConstructorFunc(NewARP10Mechanism)
+	mech.RegisterNetworkMechanism(ARP10MechanismName, constructor)
+}
+
+// ARP10Mechanism handles ARP requests to the networks,
+// associated with ports of OpenFlow 1.0 switches. As
+// OpenFlow 1.0 does not provide cookies in ofp_packet_in
+// messages, packets are dispatched by their contents.
+type ARP10Mechanism struct {
+	*ARPMechanism
+}
+
+func NewARP10Mechanism() mech.NetworkMechanism {
+	return &ARP10Mechanism{NewARPMechanism().(*ARPMechanism)}
+}
+
+func (m *ARP10Mechanism) Name() string {
+	return ARP10MechanismName
+}
+
+func (m *ARP10Mechanism) Description() string {
+	return "ARP protocol support for OpenFlow 1.0 switches"
+}
+
+// Version implements VersionedMechanism interface.
+func (m *ARP10Mechanism) Version() string {
+	return ofp10.ProtoVersion
+}
+
+// Enable implements Mechanism interface
+func (m *ARP10Mechanism) Enable(c *mech.MechanismContext) {
+	m.BaseMechanism.Enable(c)
+
+	m.filter.Mux = m.C.Mux
+	// Handle incoming ARP requests and replies.
+	m.filter.HandleFunc(of.T_PACKET_IN, m.packetInHandler)
+
+	log.InfoLog("arp1.0/ENABLE_HOOK", "Mechanism ARP enabled")
+}
+
+// Activate implements Mechanism interface
+func (m *ARP10Mechanism) Activate() {
+	m.BaseMechanism.Activate()
+}
+
+// Disable implements Mechanism interface.
+func (m *ARP10Mechanism) Disable() {
+	m.BaseMechanism.Disable()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+
+	// Nobody will answer pending requests.
+	m.releaseRequests(mech.ErrSwitchDisconnected)
+
+	// Flush all ARP flows from the single table.
+	match := ofp10.NewMatch(ofp10.MatchEthType(uint16(iana.ETHT_ARP)))
+
+	err := of.Send(m.C.Switch.Conn(), ofp10.FlowFlush(match))
+	if err != nil {
+		log.ErrorLog("arp1.0/DISABLE_HOOK",
+			"Failed to send requests: ", err)
+	}
+
+	log.InfoLog("arp1.0/DISABLE_HOOK", "Mechanism ARP disabled")
+}
+
+// Deactivate implements Mechanism interface.
+func (m *ARP10Mechanism) Deactivate() {
+	m.BaseMechanism.Deactivate()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+
+	// Unblock goroutines waiting for ARP replies.
+	m.releaseRequests(mech.ErrSwitchDisconnected)
+
+	log.InfoLog("arp1.0/DEACTIVATE_HOOK", "Mechanism ARP deactivated")
+}
+
+func (m *ARP10Mechanism) CreateNetworkPreCommit(context *mech.NetworkContext) error {
+	return m.UpdateNetworkPostCommit(context)
+}
+
+func (m *ARP10Mechanism) UpdateNetworkPreCommit(context *mech.NetworkContext) error {
+	return m.DeleteNetworkPreCommit(context)
+}
+
+func (m *ARP10Mechanism) UpdateNetworkPostCommit(context *mech.NetworkContext) error {
+	log.DebugLog("arp1.0/UPDATE_NETWORK_POSTCOMMIT",
+		"Got update network request")
+
+	if !isIPv4(context.NetworkAddr) {
+		return nil
+	}
+
+	// Send all such packets to controller
+	actions := ofp10.Actions{
+		ofp10.ActionOutput{ofp10.P_CONTROLLER, 0xffff},
+	}
+
+	// Match ARP requests to resolve updated address. Lower
+	// bits of ARP operation code are matched by protocol field.
+	arpRequest, err := ofp10.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp10.FlowMod{
+		Command:  ofp10.FC_ADD,
+		BufferID: ofp10.NO_BUFFER,
+		// Use non-zero priority
+		Priority: 2,
+		Match: ofp10.NewMatch(
+			ofp10.MatchEthType(uint16(iana.ETHT_ARP)),
+			ofp10.MatchIPProto(uint8(l3.ARPOT_REQUEST)),
+			ofp10.MatchIPv4Dst(context.NetworkAddr.Bytes(), nil),
+		),
+		Actions: actions,
+	}))
+
+	if err != nil {
+		log.ErrorLog("arp1.0/UPDATE_NETWORK_POSTCOMMIT",
+			"Failed to send ARP request message: ", err)
+		return err
+	}
+
+	// Match direct messages to receive ARP responses.
+	arpReply, err := ofp10.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp10.FlowMod{
+		Command:  ofp10.FC_ADD,
+		BufferID: ofp10.NO_BUFFER,
+		// Use non-zero priority
+		Priority: 3,
+		Match: ofp10.NewMatch(
+			ofp10.MatchEthType(uint16(iana.ETHT_ARP)),
+			ofp10.MatchEthDst(context.LinkAddr.Bytes()),
+			ofp10.MatchIPProto(uint8(l3.ARPOT_REPLY)),
+			ofp10.MatchIPv4Dst(context.NetworkAddr.Bytes(), nil),
+		),
+		Actions: actions,
+	}))
+
+	if err != nil {
+		log.ErrorLog("arp1.0/UPDATE_NETWORK_POSTCOMMIT",
+			"Failed to send ARP reply message: ", err)
+		return err
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), arpRequest, arpReply); err != nil {
+		log.ErrorLog("arp1.0/UPDATE_NETWORK_POSTCOMMIT",
+			"Failed to send requests: ", err)
+	}
+
+	return err
+}
+
+func (m *ARP10Mechanism) DeleteNetworkPreCommit(context *mech.NetworkContext) error {
+	log.DebugLog("arp1.0/DELETE_NETWORK_PRECOMMIT",
+		"Got delete network request")
+
+	if !isIPv4(context.NetworkAddr) {
+		return nil
+	}
+
+	match := ofp10.NewMatch(
+		ofp10.MatchEthType(uint16(iana.ETHT_ARP)),
+		ofp10.MatchIPv4Dst(context.NetworkAddr.Bytes(), nil),
+	)
+
+	err := of.Send(m.C.Switch.Conn(), ofp10.FlowFlush(match))
+	if err != nil {
+		log.ErrorLog("arp1.0/DELETE_NETWORK_PRECOMMIT",
+			"Failed to remove installed ARP flows: ", err)
+	}
+
+	return err
+}
+
+func (m *ARP10Mechanism) packetInHandler(rw of.ResponseWriter, r *of.Request) {
+	var packet ofp10.PacketIn
+	var pdu2 mech.LinkFrame
+	var pdu3 l3.ARP
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	reader := mech.MakeLinkReaderFrom(lldriver, &pdu2)
+	if _, err = of.ReadAllFrom(r.Body, &packet, reader); err != nil {
+		log.ErrorLog("arp1.0/PACKET_IN_HANDLER",
+			"Failed to read packet: ", err)
+		return
+	}
+
+	// Skip packets of other protocols.
+	if pdu2.Proto != mech.Proto(iana.ETHT_ARP) {
+		return
+	}
+
+	if _, err = of.ReadAllFrom(r.Body, &pdu3); err != nil {
+		log.ErrorLog("arp1.0/PACKET_IN_HANDLER",
+			"Failed to read ARP message: ", err)
+		return
+	}
+
+	switch pdu3.Operation {
+	case l3.ARPOT_REQUEST:
+		m.arpRequestHandler(rw, &packet, &pdu2, &pdu3)
+	case l3.ARPOT_REPLY:
+		m.arpReplyHandler(rw, &packet, &pdu2, &pdu3)
+	}
+}
+
+func (m *ARP10Mechanism) arpRequestHandler(rw of.ResponseWriter, packet *ofp10.PacketIn, pdu2 *mech.LinkFrame, pdu3 *l3.ARP) {
+	log.DebugLog("arp1.0/ARP_REQUEST_HANDLER",
+		"Got ARP request to resolve: ", pdu3.ProtoDst)
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		return
+	}
+
+	// Use that port as egress to send response.
+	portNo := uint32(packet.InPort)
+
+	// Get link layer address associated with egress port.
+	lladdr, err := lldriver.Addr(portNo)
+	if err != nil {
+		log.ErrorLogf("arp1.0/ARP_REQUEST_HANDLER",
+			"Failed to resolve port '%d' hardware address: '%s'", portNo, err)
+		return
+	}
+
+	// Update neighbor table with a new lladdr
+	m.neighTable.Populate(mechutil.NeighEntry{
+		NetworkAddr: nldriver.CreateAddr(pdu3.ProtoSrc, nil),
+		LinkAddr:    pdu2.SrcAddr,
+		Port:        portNo,
+	})
+
+	// Build link layer PDU.
+	frame := mech.LinkFrame{pdu2.SrcAddr, lladdr, mech.Proto(iana.ETHT_ARP), pdu2.VLAN, 0}
+
+	// Build ARP response message.
+	arp := l3.ARP{l3.ARPT_ETHERNET, iana.ETHT_IPV4, l3.ARPOT_REPLY,
+		net.HardwareAddr(lladdr.Bytes()),
+		pdu3.ProtoDst,
+		pdu3.HWSrc,
+		pdu3.ProtoSrc,
+	}
+
+	packetOut := ofp10.PacketOut{
+		BufferID: ofp10.NO_BUFFER,
+		InPort:   packet.InPort,
+		Actions:  ofp10.Actions{ofp10.ActionOutput{ofp10.P_IN_PORT, 0}},
+	}
+
+	llwriter := mech.MakeLinkWriterTo(lldriver, &frame)
+	if _, err = of.WriteAllTo(rw, &packetOut, llwriter, &arp); err != nil {
+		log.ErrorLog("arp1.0/ARP_REQUEST_WRITE_ERR",
+			"Failed to write ARP response: ", err)
+
+		return
+	}
+
+	rw.Header().Set(of.TypeHeaderKey, of.T_PACKET_OUT)
+	rw.Header().Set(of.VersionHeaderKey, ofp10.VERSION)
+	if err = rw.WriteHeader(); err != nil {
+		log.ErrorLog("arp1.0/ARP_REQUEST_SEND_ERR",
+			"Failed to send ARP response: ", err)
+	}
+}
+
+func (m *ARP10Mechanism) arpReplyHandler(rw of.ResponseWriter, packet *ofp10.PacketIn, pdu2 *mech.LinkFrame, pdu3 *l3.ARP) {
+	log.DebugLogf("arp1.0/ARP_REPLY_HANDLER",
+		"Resolve network layer address %s -> %s", pdu3.ProtoSrc, pdu3.HWSrc)
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		return
+	}
+
+	nladdr := nldriver.CreateAddr(pdu3.ProtoSrc, nil)
+
+	m.neighTable.Populate(mechutil.NeighEntry{
+		NetworkAddr: nladdr,
+		LinkAddr:    pdu2.SrcAddr,
+		Port:        uint32(packet.InPort),
+	})
+
+	m.releaseRequest(nladdr)
+}
+
+func (m *ARP10Mechanism) Lookup(addr mech.NetworkAddr, port uint32) (mech.LinkAddr, error) {
+	log.DebugLog("arp1.0/ARP_LOOKUP",
+		"Got requests to lookup address: ", addr)
+
+	if neigh, ok := m.neighTable.Lookup(addr); ok {
+		// Success, table hit.
+		return neigh.LinkAddr, nil
+	}
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return nil, err
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get link layer address associated with egress port.
+	lladdr, err := lldriver.Addr(port)
+	if err != nil {
+		log.ErrorLogf("arp1.0/ARP_LOOKUP",
+			"Failed to resolve port '%d' hardware address: '%s'", port, err)
+		return nil, err
+	}
+
+	// Get network layer address of egress port, that
+	// belongs to the same network, as target address.
+	nladdr, err := mech.SourceAddr(nldriver, port, addr)
+	if err != nil {
+		log.ErrorLogf("arp1.0/ARP_LOOKUP",
+			"Failed to resolve port '%d' network address: '%s'", port, err)
+		return nil, err
+	}
+
+	arp := l3.ARP{
+		HWType:    l3.ARPT_ETHERNET,
+		ProtoType: iana.ETHT_IPV4,
+		Operation: l3.ARPOT_REQUEST,
+		HWSrc:     lladdr.Bytes(),
+		ProtoSrc:  nladdr.Bytes(),
+		ProtoDst:  addr.Bytes(),
+	}
+
+	packetOut := ofp10.PacketOut{
+		BufferID: ofp10.NO_BUFFER,
+		InPort:   ofp10.P_NONE,
+		Actions:  ofp10.Actions{ofp10.ActionOutput{ofp10.PortNo(port), 0}},
+	}
+
+	llbcast := lldriver.CreateAddr(l2.HWBcast)
+	llwriter := mech.MakeLinkWriterTo(lldriver, &mech.LinkFrame{
+		llbcast, lladdr, mech.Proto(iana.ETHT_ARP), 0, 0,
+	})
+
+	r, err := ofp10.NewRequest(of.T_PACKET_OUT, of.NewReader(&packetOut, llwriter, &arp))
+	if err != nil {
+		log.ErrorLog("arp1.0/ARP_LOOKUP",
+			"Failed to create a new ofp_packet_out request: ", err)
+		return nil, err
+	}
+
+	// Create waiter for specified network address
+	wait := m.createRequest(addr)
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("arp1.0/ARP_LOOKUP",
+			"Failed to send an ARP request: ", err)
+		return nil, err
+	}
+
+	//TODO: create timeout waiter
+	// Wait for response
+	if err = <-wait; err != nil {
+		log.ErrorLog("arp1.0/ARP_LOOKUP",
+			"Failed to wait for ARP reply: ", err)
+		return nil, err
+	}
+
+	neigh, _ := m.neighTable.Lookup(addr)
+	return neigh.LinkAddr, nil
+}
@@ -2,8 +2,20 @@ package ip
 
 import (
 	"testing"
+
+	"github.com/netrack/netrack/mechanism"
 )
 
+type fakeNetworkAddr struct {
+	mech.NetworkAddr
+
+	addr string
+}
+
+func (a fakeNetworkAddr) String() string {
+	return a.addr
+}
+
 func TestARPUpdateNetworkPostCommit(t *testing.T) {
 }
 
@@ -18,3 +30,41 @@ func TestARPReplyHandler(t *testing.T) {
 
 func TestARPLookup(t *testing.T) {
 }
+
+func TestARPReleaseRequests(t *testing.T) {
+	m := NewARPMechanism().(*ARPMechanism)
+
+	resolved := m.createRequest(fakeNetworkAddr{addr: "10.0.0.1"})
+	pending := m.createRequest(fakeNetworkAddr{addr: "10.0.0.2"})
+
+	m.releaseRequest(fakeNetworkAddr{addr: "10.0.0.1"})
+	if err := <-resolved; err != nil {
+		t.Fatal("Resolved request must be released without error:", err)
+	}
+
+	m.releaseRequests(mech.ErrSwitchDisconnected)
+	if err := <-pending; err != mech.ErrSwitchDisconnected {
+		t.Fatal("Pending request must be released with error:", err)
+	}
+
+	if len(m.requests) != 0 {
+		t.Fatal("Released requests must be removed:", m.requests)
+	}
+}
+
+func TestARPCancelRequest(t *testing.T) {
+	m := NewARPMechanism().(*ARPMechanism)
+
+	canceled := m.createRequest(fakeNetworkAddr{addr: "10.0.0.1"})
+	waiting := m.createRequest(fakeNetworkAddr{addr: "10.0.0.1"})
+
+	m.cancelRequest(fakeNetworkAddr{addr: "10.0.0.1"}, canceled)
+	if len(m.requests["10.0.0.1"]) != 1 {
+		t.Fatal("Only canceled waiter must be removed:", m.requests)
+	}
+
+	m.cancelRequest(fakeNetworkAddr{addr: "10.0.0.1"}, waiting)
+	if len(m.requests) != 0 {
+		t.Fatal("Request without waiters must be removed:", m.requests)
+	}
+}
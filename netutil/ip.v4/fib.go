@@ -0,0 +1,744 @@
+package ip
+
+import (
+	"bytes"
+	"net"
+	"sort"
+
+	"github.com/netrack/net/iana"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/mechanism/mechutil"
+	"github.com/netrack/netrack/netutil/ofp.v13"
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+	"github.com/netrack/openflow/ofp.v13/ofputil"
+)
+
+const (
+	// routePriority is a priority of flows of the network connected
+	// to other switch with zero-length prefix, longer prefixes take
+	// precedence, local routes take precedence over remote networks
+	// with the same prefix length.
+	routePriority = 15
+
+	// hostPriority is a priority of flows to the hosts, resolved
+	// on demand, it is higher than priorities of all routes.
+	hostPriority = routePriority + 2*8*net.IPv4len + 2
+)
+
+// prefixPriority returns priority of flows of the network prefix.
+func prefixPriority(ones int, local bool) uint16 {
+	priority := routePriority + 2*ones
+	if local {
+		priority++
+	}
+
+	return uint16(priority)
+}
+
+// noVLAN is used for flows matching packets of any VLAN.
+const noVLAN = -1
+
+// fibPrefix returns prefix of the network with zeroed host bits.
+func fibPrefix(network mech.NetworkAddr) net.IPNet {
+	mask := net.IPMask(network.Mask().Bytes())
+	return net.IPNet{IP: net.IP(network.Bytes()).Mask(mask), Mask: mask}
+}
+
+// fibNextHop is a resolved next hop of the forwarding entry.
+type fibNextHop struct {
+	// Route through the next hop.
+	route mechutil.RouteEntry
+
+	// Link layer addresses of the egress port and the next hop.
+	srcAddr mech.LinkAddr
+	dstAddr mech.LinkAddr
+
+	// VLAN identifier of the interface of the egress port.
+	egress uint16
+}
+
+// actions returns actions, that send packet of the ingress VLAN to the next hop.
+func (h *fibNextHop) actions(ingress uint16) ofp.Actions {
+	// Change source and destination link layer addresses
+	setDst := ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_DST, h.dstAddr.Bytes(), nil}
+	setSrc := ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_SRC, h.srcAddr.Bytes(), nil}
+
+	// Move packet from the ingress VLAN to the VLAN of egress interface.
+	actions := ofp13.VLANActions(ingress, h.egress)
+
+	return append(actions,
+		ofp.ActionSetField{setDst},
+		ofp.ActionSetField{setSrc},
+		ofp.Action{ofp.AT_DEC_NW_TTL},
+		ofp.ActionOutput{ofp.PortNo(h.route.Port), 0},
+	)
+}
+
+// fibEntry is a forwarding entry compiled
+// from the best routes to the network.
+type fibEntry struct {
+	// The best routes to the network, equal-cost
+	// routes through other next hops are included.
+	routes []mechutil.RouteEntry
+
+	// Network prefix with zeroed host bits.
+	prefix net.IPNet
+
+	// Resolved next hops, packets are sent to
+	// the controller, when none is resolved.
+	nexthops []fibNextHop
+
+	// Select group distributing packets across
+	// next hops, zero for a single next hop.
+	group ofp.Group
+
+	// VLANs of forwarded packets, zero is used for untagged packets.
+	vlans []uint16
+}
+
+// connected reports whether network is directly connected.
+func (e *fibEntry) connected() bool {
+	return e.routes[0].NextHop == nil
+}
+
+// forwarding reports whether packets are forwarded
+// by the switch without involving the controller.
+func (e *fibEntry) forwarding() bool {
+	return len(e.nexthops) != 0
+}
+
+// multipath reports whether packets are distributed across next hops.
+func (e *fibEntry) multipath() bool {
+	return len(e.nexthops) > 1
+}
+
+// via reports whether any of the best routes leads through the next hop.
+func (e *fibEntry) via(nexthop mech.NetworkAddr) bool {
+	for _, route := range e.routes {
+		if route.NextHop != nil && bytes.Equal(route.NextHop.Bytes(), nexthop.Bytes()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolved returns resolved next hop of the route.
+func (e *fibEntry) resolved(route *mechutil.RouteEntry) (*fibNextHop, bool) {
+	for i := range e.nexthops {
+		if e.nexthops[i].route.Equal(route) {
+			return &e.nexthops[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// state returns state of the entry.
+func (e *fibEntry) state() string {
+	switch {
+	case e.forwarding():
+		return mech.FIBForward
+	case e.connected():
+		return mech.FIBConnected
+	}
+
+	return mech.FIBResolving
+}
+
+// priority returns priority of flows of the entry.
+func (e *fibEntry) priority() uint16 {
+	ones, _ := e.prefix.Mask.Size()
+	return prefixPriority(ones, true)
+}
+
+// flows returns ingress VLANs of flows of the entry, packets
+// are sent to the controller by a single flow of any VLAN.
+func (e *fibEntry) flows() []int {
+	if !e.forwarding() {
+		return []int{noVLAN}
+	}
+
+	var vlans []int
+	for _, vlan := range e.vlans {
+		vlans = append(vlans, int(vlan))
+	}
+
+	return vlans
+}
+
+// contains reports whether network of the entry
+// includes longer prefix of the other entry.
+func (e *fibEntry) contains(entry *fibEntry) bool {
+	ones, _ := e.prefix.Mask.Size()
+	other, _ := entry.prefix.Mask.Size()
+
+	return other > ones && e.prefix.Contains(entry.prefix.IP)
+}
+
+// match returns match of IPv4 packets of the ingress VLAN destined to the network.
+func (e *fibEntry) match(vlan int) ofp.Match {
+	oxms := []ofp.OXM{ofputil.EthType(uint16(iana.ETHT_IPV4), nil)}
+
+	if vlan != noVLAN {
+		oxms = append(oxms, ofp13.VLANMatch(uint16(vlan)))
+	}
+
+	oxms = append(oxms, ofputil.IPv4DstAddr(e.prefix.IP, e.prefix.Mask))
+	return ofp.Match{ofp.MT_OXM, oxms}
+}
+
+// flowMod returns modification, that installs flow of the ingress VLAN.
+func (e *fibEntry) flowMod(tableNo, vlan int) ofp.FlowMod {
+	if !e.forwarding() {
+		// Send all such packets to controller.
+		instructions := ofp.Instructions{ofp.InstructionActions{
+			ofp.IT_APPLY_ACTIONS, ofp.Actions{
+				ofp.ActionOutput{ofp.P_CONTROLLER, ofp.CML_NO_BUFFER},
+			},
+		}}
+
+		return ofp.FlowMod{
+			Command: ofp.FC_ADD,
+			TableID: ofp.Table(tableNo),
+			// Notify controller, when flow removed
+			Flags:        ofp.FF_SEND_FLOW_REM,
+			BufferID:     ofp.NO_BUFFER,
+			Priority:     e.priority(),
+			Match:        e.match(vlan),
+			Instructions: instructions,
+		}
+	}
+
+	var actions ofp.Actions
+	if e.multipath() {
+		// Buckets send untagged packets to the VLANs of egress interfaces.
+		actions = append(ofp13.VLANActions(uint16(vlan), 0), ofp.ActionGroup{e.group})
+	} else {
+		actions = e.nexthops[0].actions(uint16(vlan))
+	}
+
+	instructions := ofp.Instructions{ofp.InstructionActions{
+		ofp.IT_APPLY_ACTIONS, actions,
+	}}
+
+	return ofp.FlowMod{
+		Command:      ofp.FC_ADD,
+		TableID:      ofp.Table(tableNo),
+		BufferID:     ofp.NO_BUFFER,
+		Priority:     e.priority(),
+		Match:        e.match(vlan),
+		Instructions: instructions,
+	}
+}
+
+// deleteFlowMod returns modification, that removes flow of the ingress VLAN,
+// flows of longer prefixes are not affected.
+func (e *fibEntry) deleteFlowMod(tableNo, vlan int) ofp.FlowMod {
+	return ofp.FlowMod{
+		Command:  ofp.FC_DELETE_STRICT,
+		TableID:  ofp.Table(tableNo),
+		BufferID: ofp.NO_BUFFER,
+		Priority: e.priority(),
+		OutPort:  ofp.P_ANY,
+		OutGroup: ofp.G_ANY,
+		Match:    e.match(vlan),
+	}
+}
+
+// groupMod returns modification of the select group with a bucket per
+// next hop, buckets of next hops behind the ports, that are down, are
+// not used by the switch.
+func (e *fibEntry) groupMod(command ofp.GroupModCommand) ofp.GroupMod {
+	var buckets ofp.Buckets
+	for _, nexthop := range e.nexthops {
+		buckets = append(buckets, ofp.Bucket{
+			Weight:     1,
+			WatchPort:  ofp.PortNo(nexthop.route.Port),
+			WatchGroup: ofp.G_ANY,
+			Actions:    nexthop.actions(0),
+		})
+	}
+
+	return ofp.GroupMod{
+		Command: command,
+		Type:    ofp.GT_SELECT,
+		Group:   e.group,
+		Buckets: buckets,
+	}
+}
+
+// arp returns ARP mechanism of the switch.
+func (m *IPv4Routing) arp() (*ARPMechanism, error) {
+	var network mech.NetworkMechanismManager
+	if err := m.C.Managers.Obtain(&network); err != nil {
+		log.ErrorLog("fib/ARP",
+			"Failed to obtain network layer manager: ", err)
+		return nil, err
+	}
+
+	nmech, err := network.Mechanism(ARPMechanismName)
+	if err != nil {
+		log.ErrorLog("fib/ARP",
+			"ARP network mechanism is not found: ", err)
+		return nil, err
+	}
+
+	arpMech, ok := nmech.(*ARPMechanism)
+	if !ok {
+		log.ErrorLog("fib/ARP",
+			"Failed to cast mechanism to arp mechanism type")
+		return nil, mech.ErrMechanismNotRegistered
+	}
+
+	return arpMech, nil
+}
+
+// allocateGroup returns unused group identifier.
+func (m *IPv4Routing) allocateGroup() ofp.Group {
+	group := ofp.Group(1)
+	for m.groups[group] {
+		group++
+	}
+
+	m.groups[group] = true
+	return group
+}
+
+// releaseGroup makes group identifier available for allocation.
+func (m *IPv4Routing) releaseGroup(group ofp.Group) {
+	delete(m.groups, group)
+}
+
+// resolve returns forwarding entry of the routes, ARP requests are
+// sent to the next hops, that are not resolved yet.
+func (m *IPv4Routing) resolve(routes []mechutil.RouteEntry) *fibEntry {
+	entry := &fibEntry{routes: routes, prefix: fibPrefix(routes[0].Network)}
+
+	// Hosts of connected networks are resolved on demand.
+	if entry.connected() {
+		return entry
+	}
+
+	arpMech, err := m.arp()
+	if err != nil {
+		return entry
+	}
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		log.ErrorLog("fib/RESOLVE",
+			"Link layer driver is not initialized: ", err)
+		return entry
+	}
+
+	for _, route := range routes {
+		if route.NextHop == nil {
+			continue
+		}
+
+		dstAddr, ok := arpMech.Neighbor(route.NextHop)
+		if !ok {
+			// Entry is recompiled, when neighbor is learned.
+			arpMech.Request(route.NextHop, route.Port)
+			continue
+		}
+
+		// Search for link layer address of egress port.
+		srcAddr, err := lldriver.Addr(route.Port)
+		if err != nil {
+			log.ErrorLog("fib/RESOLVE",
+				"Failed to retrieve port link layer address: ", err)
+			continue
+		}
+
+		entry.nexthops = append(entry.nexthops, fibNextHop{
+			route:   route,
+			srcAddr: srcAddr,
+			dstAddr: dstAddr,
+			egress:  arpMech.VLAN(route.Port),
+		})
+	}
+
+	// Packets of different VLANs require different tag operations.
+	if entry.forwarding() {
+		entry.vlans = append([]uint16{0}, arpMech.VLANs()...)
+	}
+
+	return entry
+}
+
+// compile updates forwarding entry of the network
+// with the best routes from the routing table.
+func (m *IPv4Routing) compile(network mech.NetworkAddr) error {
+	prefix := fibPrefix(network)
+
+	key := prefix.String()
+	old := m.fib[key]
+
+	routes := m.routeTable.Best(network)
+	if len(routes) == 0 {
+		if old == nil {
+			return nil
+		}
+
+		delete(m.fib, key)
+		return m.uninstall(old)
+	}
+
+	entry := m.resolve(routes)
+	m.fib[key] = entry
+
+	log.DebugLogf("fib/COMPILE",
+		"Compiled %s to %s through %d next hops",
+		key, entry.state(), len(entry.nexthops))
+
+	return m.install(entry, old)
+}
+
+// install sends flows of the entry to the switch,
+// flows of the replaced entry are removed.
+func (m *IPv4Routing) install(entry, old *fibEntry) error {
+	// Flows of hosts of the connected network are replaced as well.
+	if old != nil && old.connected() && !entry.connected() {
+		if err := m.uninstall(old); err != nil {
+			return err
+		}
+
+		old = nil
+	}
+
+	var requests []*of.Request
+
+	// Buckets of the group are updated in place,
+	// so flows could keep referencing it.
+	if entry.multipath() {
+		command := ofp.GC_MODIFY
+
+		if old != nil && old.group != 0 {
+			entry.group = old.group
+		} else {
+			entry.group = m.allocateGroup()
+			command = ofp.GC_ADD
+		}
+
+		groupMod := entry.groupMod(command)
+		r, err := of.NewRequest(of.T_GROUP_MOD, of.NewReader(&groupMod))
+		if err != nil {
+			log.ErrorLog("fib/INSTALL",
+				"Failed to create new ofp_group_mod request: ", err)
+			return err
+		}
+
+		requests = append(requests, r)
+	}
+
+	installed := make(map[int]bool)
+	for _, vlan := range entry.flows() {
+		flowMod := entry.flowMod(m.tableNo, vlan)
+		installed[vlan] = true
+
+		if !entry.forwarding() {
+			// Move ip packets to ipPacketHandler
+			m.cookies.FilterFunc(&flowMod, m.ipPacketHandler)
+		}
+
+		r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
+		if err != nil {
+			log.ErrorLog("fib/INSTALL",
+				"Failed to create new ofp_flow_mod request: ", err)
+			return err
+		}
+
+		requests = append(requests, r)
+	}
+
+	// New flows are added before removal of stale
+	// ones to keep forwarding packets to the network.
+	if old != nil {
+		for _, vlan := range old.flows() {
+			if installed[vlan] {
+				continue
+			}
+
+			flowMod := old.deleteFlowMod(m.tableNo, vlan)
+			r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
+			if err != nil {
+				log.ErrorLog("fib/INSTALL",
+					"Failed to create new ofp_flow_mod request: ", err)
+				return err
+			}
+
+			requests = append(requests, r)
+		}
+
+		// Group is not referenced by the replaced flows anymore.
+		if old.group != 0 && entry.group == 0 {
+			r, err := m.deleteGroup(old.group)
+			if err != nil {
+				return err
+			}
+
+			requests = append(requests, r)
+		}
+	}
+
+	err := of.Send(m.C.Switch.Conn(), requests...)
+	if err != nil {
+		log.ErrorLog("fib/INSTALL",
+			"Failed to send requests: ", err)
+	}
+
+	return err
+}
+
+// deleteGroup returns request, that removes the group, and
+// makes its identifier available for allocation.
+func (m *IPv4Routing) deleteGroup(group ofp.Group) (*of.Request, error) {
+	m.releaseGroup(group)
+
+	r, err := of.NewRequest(of.T_GROUP_MOD, of.NewReader(&ofp.GroupMod{
+		Command: ofp.GC_DELETE,
+		Type:    ofp.GT_SELECT,
+		Group:   group,
+	}))
+
+	if err != nil {
+		log.ErrorLog("fib/DELETE_GROUP",
+			"Failed to create new ofp_group_mod request: ", err)
+	}
+
+	return r, err
+}
+
+// uninstall removes flows of the entry from the switch.
+func (m *IPv4Routing) uninstall(entry *fibEntry) error {
+	if !entry.connected() {
+		var requests []*of.Request
+
+		for _, vlan := range entry.flows() {
+			flowMod := entry.deleteFlowMod(m.tableNo, vlan)
+			r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
+			if err != nil {
+				log.ErrorLog("fib/UNINSTALL",
+					"Failed to create new ofp_flow_mod request: ", err)
+				return err
+			}
+
+			requests = append(requests, r)
+		}
+
+		if entry.group != 0 {
+			r, err := m.deleteGroup(entry.group)
+			if err != nil {
+				return err
+			}
+
+			requests = append(requests, r)
+		}
+
+		err := of.Send(m.C.Switch.Conn(), requests...)
+		if err != nil {
+			log.ErrorLog("fib/UNINSTALL",
+				"Failed to send requests: ", err)
+		}
+
+		return err
+	}
+
+	// Flows of hosts of the connected network are removed as well.
+	err := of.Send(m.C.Switch.Conn(),
+		ofputil.FlowFlush(ofp.Table(m.tableNo), entry.match(noVLAN)),
+	)
+
+	if err != nil {
+		log.ErrorLog("fib/UNINSTALL",
+			"Failed to send requests: ", err)
+		return err
+	}
+
+	return m.reinstall(entry)
+}
+
+// reinstall sends flows of longer prefixes within
+// the network of the entry, removed by the flush.
+func (m *IPv4Routing) reinstall(entry *fibEntry) error {
+	for _, e := range m.entries() {
+		if !entry.contains(e) {
+			continue
+		}
+
+		// Groups are kept by the switch.
+		if err := m.install(e, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// entries returns forwarding entries ordered by network.
+func (m *IPv4Routing) entries() []*fibEntry {
+	entries := make([]*fibEntry, 0, len(m.fib))
+	for _, entry := range m.fib {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].prefix, entries[j].prefix
+		if c := bytes.Compare(a.IP, b.IP); c != 0 {
+			return c < 0
+		}
+
+		return bytes.Compare(a.Mask, b.Mask) < 0
+	})
+
+	return entries
+}
+
+// recompile updates forwarding entries matching the function.
+func (m *IPv4Routing) recompile(fn func(*fibEntry) bool) {
+	m.fibLock.Lock()
+	defer m.fibLock.Unlock()
+
+	for _, entry := range m.entries() {
+		if fn(entry) {
+			m.compile(entry.routes[0].Network)
+		}
+	}
+}
+
+// NeighChanged implements NeighWatcher interface.
+func (m *IPv4Routing) NeighChanged(neigh mechutil.NeighEntry) {
+	m.recompile(func(entry *fibEntry) bool {
+		return entry.via(neigh.NetworkAddr)
+	})
+}
+
+// NeighRemoved implements NeighWatcher interface.
+func (m *IPv4Routing) NeighRemoved(neigh mechutil.NeighEntry) {
+	// Buckets of the next hop are removed from the groups.
+	m.recompile(func(entry *fibEntry) bool {
+		return entry.via(neigh.NetworkAddr)
+	})
+}
+
+// VLANChanged implements NeighWatcher interface.
+func (m *IPv4Routing) VLANChanged(port uint32) {
+	// Set of VLANs of ingress packets could change as well.
+	m.recompile(func(entry *fibEntry) bool {
+		return !entry.connected()
+	})
+}
+
+// RIB implements ForwardingReader interface.
+func (m *IPv4Routing) RIB() []mech.RIBEntry {
+	entries := make([]mech.RIBEntry, 0)
+
+	var network string
+	var best mechutil.RouteEntry
+	for _, route := range m.routeTable.Routes() {
+		if !isIPv4(route.Network) {
+			continue
+		}
+
+		prefix := fibPrefix(route.Network)
+
+		entry := mech.RIBEntry{
+			Type:     route.Type,
+			Network:  prefix.String(),
+			Port:     route.Port,
+			Distance: route.Distance,
+			Metric:   route.Metric,
+		}
+
+		if route.NextHop != nil {
+			entry.NextHop = net.IP(route.NextHop.Bytes()).String()
+		}
+
+		// Candidates are ordered by preference, equal-cost
+		// routes following the first one are the best as well.
+		if entry.Network != network {
+			network, best = entry.Network, route
+		}
+
+		entry.Best = route.Distance == best.Distance && route.Metric == best.Metric
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// FIB implements ForwardingReader interface.
+func (m *IPv4Routing) FIB() []mech.FIBEntry {
+	m.fibLock.Lock()
+	defer m.fibLock.Unlock()
+
+	entries := make([]mech.FIBEntry, 0, len(m.fib))
+	for _, e := range m.entries() {
+		entry := mech.FIBEntry{
+			Type:     e.routes[0].Type,
+			Network:  e.prefix.String(),
+			NextHops: make([]mech.FIBNextHop, 0, len(e.routes)),
+			Group:    uint32(e.group),
+			Priority: e.priority(),
+			State:    e.state(),
+		}
+
+		for i, route := range e.routes {
+			nexthop := mech.FIBNextHop{Port: route.Port}
+
+			if route.NextHop != nil {
+				nexthop.Addr = net.IP(route.NextHop.Bytes()).String()
+			}
+
+			if resolved, ok := e.resolved(&e.routes[i]); ok {
+				nexthop.LinkAddr = net.HardwareAddr(resolved.dstAddr.Bytes()).String()
+			}
+
+			entry.NextHops = append(entry.NextHops, nexthop)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// Groups implements GroupReader interface.
+func (m *IPv4Routing) Groups() []mech.GroupEntry {
+	m.fibLock.Lock()
+	defer m.fibLock.Unlock()
+
+	groups := make([]mech.GroupEntry, 0)
+	for _, e := range m.entries() {
+		if e.group == 0 {
+			continue
+		}
+
+		group := mech.GroupEntry{
+			ID:      uint32(e.group),
+			Type:    mech.GroupSelect,
+			Network: e.prefix.String(),
+		}
+
+		for _, nexthop := range e.nexthops {
+			group.Buckets = append(group.Buckets, mech.GroupBucket{
+				Weight:   1,
+				NextHop:  net.IP(nexthop.route.NextHop.Bytes()).String(),
+				LinkAddr: net.HardwareAddr(nexthop.dstAddr.Bytes()).String(),
+				Port:     nexthop.route.Port,
+			})
+		}
+
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].ID < groups[j].ID
+	})
+
+	return groups
+}
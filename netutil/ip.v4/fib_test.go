@@ -0,0 +1,210 @@
+package ip
+
+import (
+	"net"
+	"testing"
+
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/mechanism/mechutil"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/openflow/ofp.v13"
+)
+
+func parseIPv4(t *testing.T, s string) mech.NetworkAddr {
+	addr, err := drivers.NewIPv4Driver().ParseAddr(s)
+	if err != nil {
+		t.Fatal("Failed to parse address:", err)
+	}
+
+	return addr
+}
+
+func TestFIBPrefix(t *testing.T) {
+	prefix := fibPrefix(parseIPv4(t, "10.1.2.3/16"))
+	if prefix.String() != "10.1.0.0/16" || len(prefix.IP) != net.IPv4len {
+		t.Fatal("Host bits of the prefix must be zeroed:", prefix.String())
+	}
+}
+
+func TestFIBEntryPriority(t *testing.T) {
+	entry := func(network string) *fibEntry {
+		return &fibEntry{prefix: fibPrefix(parseIPv4(t, network))}
+	}
+
+	deflt, net16, net24 := entry("0.0.0.0/0"), entry("10.1.0.0/16"), entry("10.1.2.0/24")
+
+	if deflt.priority() != routePriority+1 || net16.priority() >= net24.priority() {
+		t.Fatal("Longer prefixes must take precedence:", deflt.priority(), net16.priority(), net24.priority())
+	}
+
+	if host := entry("10.1.2.3/32"); host.priority() >= hostPriority {
+		t.Fatal("Flows to hosts must take precedence over routes:", host.priority())
+	}
+
+	// Remote networks take precedence over shorter local prefixes only.
+	if remote := prefixPriority(16, false); remote >= net16.priority() || remote <= deflt.priority() {
+		t.Fatal("Invalid priority of the remote network:", remote)
+	}
+
+	if !net16.contains(net24) || net24.contains(net16) || net16.contains(net16) {
+		t.Fatal("Only longer prefixes must be contained by the network")
+	}
+}
+
+func TestFIBEntryFlows(t *testing.T) {
+	ethernet := drivers.NewEthernetLinkDriver()
+
+	route := mechutil.RouteEntry{
+		Type:    mech.StaticRoute,
+		Network: parseIPv4(t, "10.1.0.0/16"),
+		NextHop: parseIPv4(t, "192.168.0.1"),
+		Port:    2,
+	}
+
+	entry := &fibEntry{
+		routes: []mechutil.RouteEntry{route},
+		prefix: fibPrefix(parseIPv4(t, "10.1.0.0/16")),
+	}
+
+	// Packets to unresolved next hop are sent to the controller.
+	if flows := entry.flows(); len(flows) != 1 || flows[0] != noVLAN || entry.state() != mech.FIBResolving {
+		t.Fatal("Unresolved entry must be installed by a single flow:", flows)
+	}
+
+	flowMod := entry.flowMod(1, noVLAN)
+	if flowMod.Priority != 48 || flowMod.Flags != ofp.FF_SEND_FLOW_REM {
+		t.Fatal("Invalid flow of unresolved entry:", flowMod)
+	}
+
+	entry.nexthops = []fibNextHop{{
+		route:   route,
+		srcAddr: ethernet.CreateAddr(net.HardwareAddr{0, 0, 0, 0, 0, 1}),
+		dstAddr: ethernet.CreateAddr(net.HardwareAddr{0, 0, 0, 0, 0, 2}),
+		egress:  10,
+	}}
+	entry.vlans = []uint16{0, 10, 20}
+
+	if flows := entry.flows(); len(flows) != 3 || entry.state() != mech.FIBForward {
+		t.Fatal("Resolved entry must be installed by a flow per VLAN:", flows)
+	}
+
+	tests := []struct {
+		vlan    int
+		actions int
+	}{
+		// Push and set VLAN, set addresses, decrement TTL and output.
+		{0, 6},
+		// Packets of the egress VLAN are not retagged.
+		{10, 4},
+		{20, 5},
+	}
+
+	for _, test := range tests {
+		flowMod := entry.flowMod(1, test.vlan)
+		if flowMod.Command != ofp.FC_ADD || flowMod.Flags != 0 {
+			t.Fatal("Invalid forwarding flow:", flowMod)
+		}
+
+		instruction := flowMod.Instructions[0].(ofp.InstructionActions)
+		if len(instruction.Actions) != test.actions {
+			t.Fatal("Invalid actions of VLAN", test.vlan, instruction.Actions)
+		}
+
+		output := instruction.Actions[len(instruction.Actions)-1].(ofp.ActionOutput)
+		if output.Port != 2 {
+			t.Fatal("Packets must be sent to the egress port:", output.Port)
+		}
+	}
+
+	flowMod = entry.deleteFlowMod(1, 10)
+	if flowMod.Command != ofp.FC_DELETE_STRICT || flowMod.Priority != 48 {
+		t.Fatal("Flows of the entry must be removed strictly:", flowMod)
+	}
+}
+
+func TestFIBEntryMultipath(t *testing.T) {
+	ethernet := drivers.NewEthernetLinkDriver()
+
+	entry := &fibEntry{
+		prefix: fibPrefix(parseIPv4(t, "10.1.0.0/16")),
+		group:  1,
+		vlans:  []uint16{0, 10},
+	}
+
+	for i, nexthop := range []string{"192.168.0.1", "192.168.1.1", "192.168.2.1"} {
+		route := mechutil.RouteEntry{
+			Type:    mech.OSPFRoute,
+			Network: parseIPv4(t, "10.1.0.0/16"),
+			NextHop: parseIPv4(t, nexthop),
+			Port:    uint32(i + 1),
+		}
+
+		entry.routes = append(entry.routes, route)
+
+		// The last next hop is not resolved.
+		if i == 2 {
+			continue
+		}
+
+		entry.nexthops = append(entry.nexthops, fibNextHop{
+			route:   route,
+			srcAddr: ethernet.CreateAddr(net.HardwareAddr{0, 0, 0, 0, 0, 1}),
+			dstAddr: ethernet.CreateAddr(net.HardwareAddr{0, 0, 0, 0, 1, byte(i)}),
+			egress:  uint16(i * 10),
+		})
+	}
+
+	if !entry.multipath() || !entry.via(parseIPv4(t, "192.168.2.1")) {
+		t.Fatal("Entry must distribute packets across next hops")
+	}
+
+	if _, ok := entry.resolved(&entry.routes[2]); ok {
+		t.Fatal("Unresolved next hop must not be found")
+	}
+
+	groupMod := entry.groupMod(ofp.GC_ADD)
+	if groupMod.Type != ofp.GT_SELECT || groupMod.Group != 1 || len(groupMod.Buckets) != 2 {
+		t.Fatal("Group must contain a bucket per resolved next hop:", groupMod)
+	}
+
+	tests := []struct {
+		port    ofp.PortNo
+		actions int
+	}{
+		// Untagged packets are sent to the untagged interface.
+		{1, 4},
+		// Push and set VLAN of the egress interface.
+		{2, 6},
+	}
+
+	for i, test := range tests {
+		bucket := groupMod.Buckets[i]
+		if bucket.WatchPort != test.port || bucket.Weight != 1 {
+			t.Fatal("Bucket must be used only when the port is up:", bucket)
+		}
+
+		if len(bucket.Actions) != test.actions {
+			t.Fatal("Invalid actions of bucket", i, bucket.Actions)
+		}
+
+		output := bucket.Actions[len(bucket.Actions)-1].(ofp.ActionOutput)
+		if output.Port != test.port {
+			t.Fatal("Packets must be sent to the egress port:", output.Port)
+		}
+	}
+
+	// Tagged packets are untagged before being sent to the group.
+	for vlan, n := range map[int]int{0: 1, 10: 2} {
+		flowMod := entry.flowMod(1, vlan)
+
+		instruction := flowMod.Instructions[0].(ofp.InstructionActions)
+		if len(instruction.Actions) != n {
+			t.Fatal("Invalid actions of VLAN", vlan, instruction.Actions)
+		}
+
+		group := instruction.Actions[n-1].(ofp.ActionGroup)
+		if group.Group != 1 {
+			t.Fatal("Packets must be sent to the group:", group.Group)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"github.com/netrack/net/l3"
 	"github.com/netrack/netrack/logging"
 	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ofp.v13"
 	"github.com/netrack/openflow"
 	"github.com/netrack/openflow/ofp.v13"
 	"github.com/netrack/openflow/ofp.v13/ofputil"
@@ -32,12 +34,12 @@ type ICMPMechanism struct {
 	mech.BaseNetworkMechanism
 
 	// Handle request based on cookie value.
-	cookies *of.CookieFilter
+	cookies *mech.CookieFilter
 }
 
 func NewICMPMechanism() mech.NetworkMechanism {
 	return &ICMPMechanism{
-		cookies: of.NewCookieFilter(),
+		cookies: mech.NewCookieFilter(),
 	}
 }
 
@@ -49,6 +51,11 @@ func (m *ICMPMechanism) Description() string {
 	return "MISSING DESCRIPTION!"
 }
 
+// Version implements VersionedMechanism interface.
+func (m *ICMPMechanism) Version() string {
+	return ofp13.ProtoVersion
+}
+
 func (m *ICMPMechanism) Enable(c *mech.MechanismContext) {
 	m.BaseNetworkMechanism.Enable(c)
 
@@ -66,6 +73,21 @@ func (m *ICMPMechanism) Activate() {
 	m.cookies.Baker = ofputil.PacketInBaker()
 }
 
+// Deactivate implements Mechanism interface.
+func (m *ICMPMechanism) Deactivate() {
+	m.BaseNetworkMechanism.Deactivate()
+
+	// Drop handlers of cookies of installed flows.
+	m.cookies = mech.NewCookieFilter()
+
+	log.InfoLog("icmp/DEACTIVATE_HOOK", "Mechanism ICMP deactivated")
+}
+
+// OwnsFlow implements mech.FlowOwner interface.
+func (m *ICMPMechanism) OwnsFlow(table int, cookie uint64) bool {
+	return m.cookies.Contains(cookie)
+}
+
 func (m *ICMPMechanism) CreateNetworkPreCommit(context *mech.NetworkContext) error {
 	return m.UpdateNetworkPostCommit(context)
 }
@@ -78,6 +100,10 @@ func (m *ICMPMechanism) UpdateNetworkPostCommit(context *mech.NetworkContext) er
 	log.DebugLog("icmp/UPDATE_NETWORK_POSTCOMMIT",
 		"Got update network request")
 
+	if !isIPv4(context.NetworkAddr) {
+		return nil
+	}
+
 	// Send ICMP message to the controller
 	instructions := ofp.Instructions{ofp.InstructionActions{
 		ofp.IT_APPLY_ACTIONS,
@@ -118,6 +144,10 @@ func (m *ICMPMechanism) DeleteNetworkPreCommit(context *mech.NetworkContext) err
 	log.DebugLog("icmp/DELETE_NETWORK_PRECOMMIT",
 		"Got network delete precommit request")
 
+	if !isIPv4(context.NetworkAddr) {
+		return nil
+	}
+
 	// Flush ICMP flow for specified address (if any).
 	err := of.Send(m.C.Switch.Conn(), ofputil.FlowFlush(
 		0, EchoRequest(context.NetworkAddr.Bytes()),
@@ -160,7 +190,7 @@ func (m *ICMPMechanism) icmpEchoHandler(rw of.ResponseWriter, r *of.Request) {
 		return
 	}
 
-	nldriver, err := mech.NetworkDrv(m.C)
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
 	if err != nil {
 		return
 	}
@@ -197,7 +227,7 @@ func (m *ICMPMechanism) icmpEchoHandler(rw of.ResponseWriter, r *of.Request) {
 	}
 
 	// Build link layer PDU.
-	pdu2 = mech.LinkFrame{pdu2.SrcAddr, lladdr, mech.Proto(iana.ETHT_IPV4), 0}
+	pdu2 = mech.LinkFrame{pdu2.SrcAddr, lladdr, mech.Proto(iana.ETHT_IPV4), pdu2.VLAN, 0}
 
 	// Send echo-reply message.
 	icmp.Type = l3.ICMPT_ECHO_REPLY
@@ -0,0 +1,267 @@
+package ip
+
+import (
+	"net"
+
+	"github.com/netrack/net/iana"
+	"github.com/netrack/net/l3"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ofp.v10"
+	"github.com/netrack/openflow"
+)
+
+const ICMP10MechanismName = "icmp-ofp1.0"
+
+func init() {
+	constructor := mech.NetworkMechanismConstructorFunc(NewICMP10Mechanism)
+	mech.RegisterNetworkMechanism(ICMP10MechanismName, constructor)
+}
+
+// EchoRequest10 returns OpenFlow 1.0 match of ICMP echo-request
+// messages to specified network address. ICMP type is matched by
+// the transport source port field.
+func EchoRequest10(ipaddr []byte) ofp10.Match {
+	return ofp10.NewMatch(
+		ofp10.MatchEthType(uint16(iana.ETHT_IPV4)),
+		ofp10.MatchIPv4Dst(ipaddr, nil),
+		ofp10.MatchIPProto(uint8(iana.IP_PROTO_ICMP)),
+		ofp10.MatchTPSrc(uint16(l3.ICMPT_ECHO_REQUEST)),
+	)
+}
+
+// ICMP10Mechanism responds to ICMP echo-requests sent to the
+// addresses of OpenFlow 1.0 switch ports.
+type ICMP10Mechanism struct {
+	mech.BaseNetworkMechanism
+
+	// Stores registered handlers to delete them later.
+	filter *of.ServeFilter
+}
+
+func NewICMP10Mechanism() mech.NetworkMechanism {
+	return &ICMP10Mechanism{filter: of.NewServeFilter()}
+}
+
+func (m *ICMP10Mechanism) Name() string {
+	return ICMP10MechanismName
+}
+
+func (m *ICMP10Mechanism) Description() string {
+	return "ICMP echo support for OpenFlow 1.0 switches"
+}
+
+// Version implements VersionedMechanism interface.
+func (m *ICMP10Mechanism) Version() string {
+	return ofp10.ProtoVersion
+}
+
+// Enable implements Mechanism interface.
+func (m *ICMP10Mechanism) Enable(c *mech.MechanismContext) {
+	m.BaseNetworkMechanism.Enable(c)
+
+	m.filter.Mux = m.C.Mux
+	// Handle incoming ICMP requests.
+	m.filter.HandleFunc(of.T_PACKET_IN, m.packetInHandler)
+
+	log.InfoLog("icmp1.0/ENABLE_HOOK", "Mechanism ICMP enabled")
+}
+
+// Disable implements Mechanism interface.
+func (m *ICMP10Mechanism) Disable() {
+	m.BaseNetworkMechanism.Disable()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+
+	log.InfoLog("icmp1.0/DISABLE_HOOK", "Mechanism ICMP disabled")
+}
+
+// Deactivate implements Mechanism interface.
+func (m *ICMP10Mechanism) Deactivate() {
+	m.BaseNetworkMechanism.Deactivate()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+
+	log.InfoLog("icmp1.0/DEACTIVATE_HOOK", "Mechanism ICMP deactivated")
+}
+
+func (m *ICMP10Mechanism) CreateNetworkPreCommit(context *mech.NetworkContext) error {
+	return m.UpdateNetworkPostCommit(context)
+}
+
+func (m *ICMP10Mechanism) UpdateNetworkPreCommit(context *mech.NetworkContext) error {
+	return m.DeleteNetworkPreCommit(context)
+}
+
+func (m *ICMP10Mechanism) UpdateNetworkPostCommit(context *mech.NetworkContext) error {
+	log.DebugLog("icmp1.0/UPDATE_NETWORK_POSTCOMMIT",
+		"Got update network request")
+
+	if !isIPv4(context.NetworkAddr) {
+		return nil
+	}
+
+	r, err := ofp10.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp10.FlowMod{
+		Command:  ofp10.FC_ADD,
+		BufferID: ofp10.NO_BUFFER,
+		Priority: 30, // Use non-zero priority
+		Match:    EchoRequest10(context.NetworkAddr.Bytes()),
+		// Send ICMP message to the controller
+		Actions: ofp10.Actions{ofp10.ActionOutput{ofp10.P_CONTROLLER, 0xffff}},
+	}))
+
+	if err != nil {
+		log.ErrorLog("icmp1.0/UPDATE_NETWORK_POSTCOMMIT",
+			"Failed to create a new ofp_flow_mod request: ", err)
+		return err
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("icmp1.0/UPDATE_NETWORK_POSTCOMMIT",
+			"Failed to send request: ", err)
+	}
+
+	return err
+}
+
+func (m *ICMP10Mechanism) DeleteNetworkPreCommit(context *mech.NetworkContext) error {
+	log.DebugLog("icmp1.0/DELETE_NETWORK_PRECOMMIT",
+		"Got network delete precommit request")
+
+	if !isIPv4(context.NetworkAddr) {
+		return nil
+	}
+
+	// Flush ICMP flow for specified address (if any).
+	err := of.Send(m.C.Switch.Conn(), ofp10.FlowFlush(
+		EchoRequest10(context.NetworkAddr.Bytes()),
+	))
+
+	if err != nil {
+		log.ErrorLog("icmp1.0/DELETE_NETWORK_PRECOMMIT",
+			"Failed to send requests: ", err)
+	}
+
+	return err
+}
+
+// portAddr returns true, when specified
+// address is assigned to the port.
+func (m *ICMP10Mechanism) portAddr(nldriver mech.NetworkDriver, port uint32, addr mech.NetworkAddr) bool {
+	for _, nladdr := range nldriver.Addrs(port) {
+		if net.IP(nladdr.Bytes()).Equal(net.IP(addr.Bytes())) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *ICMP10Mechanism) packetInHandler(rw of.ResponseWriter, r *of.Request) {
+	var packet ofp10.PacketIn
+	var pdu2 mech.LinkFrame
+	var pdu3 mech.NetworkPacket
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		return
+	}
+
+	llreader := mech.MakeLinkReaderFrom(lldriver, &pdu2)
+	if _, err = of.ReadAllFrom(r.Body, &packet, llreader); err != nil {
+		log.ErrorLog("icmp1.0/PACKET_IN_HANDLER",
+			"Failed to read packet: ", err)
+		return
+	}
+
+	// Skip packets of other protocols.
+	if pdu2.Proto != mech.Proto(iana.ETHT_IPV4) {
+		return
+	}
+
+	nlreader := mech.MakeNetworkReaderFrom(nldriver, &pdu3)
+	if _, err = of.ReadAllFrom(r.Body, nlreader); err != nil {
+		log.ErrorLog("icmp1.0/PACKET_IN_HANDLER",
+			"Failed to read network packet: ", err)
+		return
+	}
+
+	portNo := uint32(packet.InPort)
+
+	// Respond only to requests destined to the ingress port.
+	if !m.portAddr(nldriver, portNo, pdu3.DstAddr) {
+		return
+	}
+
+	if pdu3.Proto != mech.Proto(iana.IP_PROTO_ICMP) {
+		return
+	}
+
+	// Read icmp echo-request message
+	icmp := l3.ICMPEcho{Data: make([]byte, pdu3.ContentLen-l3.ICMPHeaderLen)}
+	if _, err = of.ReadAllFrom(r.Body, &icmp); err != nil {
+		log.ErrorLog("icmp1.0/PACKET_IN_HANDLER",
+			"Failed to read ICMP message: ", err)
+		return
+	}
+
+	if icmp.Type != l3.ICMPT_ECHO_REQUEST {
+		return
+	}
+
+	log.DebugLogf("icmp1.0/ECHO_REQUEST_HANDLER",
+		"Got ICMP echo-request: %s -> %s", pdu3.SrcAddr, pdu3.DstAddr)
+
+	// Search for link layer address of egress port.
+	lladdr, err := lldriver.Addr(portNo)
+	if err != nil {
+		log.ErrorLog("icmp1.0/PACKET_IN_HWADDR_ERR",
+			"Failed to retrieve port hardware address: ", err)
+		return
+	}
+
+	// Build link layer PDU.
+	pdu2 = mech.LinkFrame{pdu2.SrcAddr, lladdr, mech.Proto(iana.ETHT_IPV4), pdu2.VLAN, 0}
+
+	// Send echo-reply message.
+	icmp.Type = l3.ICMPT_ECHO_REPLY
+
+	// Build network layer PDU.
+	pdu3 = mech.NetworkPacket{
+		DstAddr: pdu3.SrcAddr,
+		SrcAddr: pdu3.DstAddr,
+		Proto:   pdu3.Proto,
+		Payload: of.NewReader(&icmp),
+	}
+
+	packetOut := ofp10.PacketOut{
+		BufferID: ofp10.NO_BUFFER,
+		InPort:   packet.InPort,
+		Actions:  ofp10.Actions{ofp10.ActionOutput{ofp10.P_IN_PORT, 0}},
+	}
+
+	llwriter := mech.MakeLinkWriterTo(lldriver, &pdu2)
+	nlwriter := mech.MakeNetworkWriterTo(nldriver, &pdu3)
+
+	_, err = of.WriteAllTo(rw, &packetOut, llwriter, nlwriter)
+	if err != nil {
+		log.ErrorLog("icmp1.0/PACKET_IN_HANDLER",
+			"Failed to write response: ", err)
+		return
+	}
+
+	rw.Header().Set(of.TypeHeaderKey, of.T_PACKET_OUT)
+	rw.Header().Set(of.VersionHeaderKey, ofp10.VERSION)
+	if err = rw.WriteHeader(); err != nil {
+		log.ErrorLog("icmp1.0/PACKET_IN_HANDLER",
+			"Failed to send ICMP-REPLY response: ", err)
+	}
+}
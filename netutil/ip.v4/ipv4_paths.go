@@ -0,0 +1,274 @@
+package ip
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/netrack/net/iana"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/mechanism/mechutil"
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+	"github.com/netrack/openflow/ofp.v13/ofputil"
+)
+
+// TransitIdleTimeout is a number of idle seconds before
+// expiration of flows installed on the path to another switch.
+const TransitIdleTimeout = 60
+
+// routerList is a controller-wide list of IPv4 routing mechanisms,
+// it is used to forward packets to the networks connected to other
+// switches through the discovered topology.
+type routerList struct {
+	routers map[string]*IPv4Routing
+	lock    sync.RWMutex
+}
+
+var routers = &routerList{routers: make(map[string]*IPv4Routing)}
+
+func (l *routerList) add(m *IPv4Routing) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.routers[m.C.Switch.ID()] = m
+}
+
+func (l *routerList) remove(m *IPv4Routing) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// Switch could be already reconnected with a new mechanism.
+	if l.routers[m.C.Switch.ID()] == m {
+		delete(l.routers, m.C.Switch.ID())
+	}
+}
+
+func (l *routerList) get(dpid string) (*IPv4Routing, bool) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	m, ok := l.routers[dpid]
+	return m, ok
+}
+
+// others returns routing mechanisms of other switches sorted by datapath identifiers.
+func (l *routerList) others(dpid string) []*IPv4Routing {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	var dpids []string
+	for id := range l.routers {
+		if id != dpid {
+			dpids = append(dpids, id)
+		}
+	}
+
+	sort.Strings(dpids)
+
+	var others []*IPv4Routing
+	for _, id := range dpids {
+		others = append(others, l.routers[id])
+	}
+
+	return others
+}
+
+// locate returns routing mechanism of other switch, that has network
+// of the address directly connected, and the route to that network.
+// Network with the longest prefix is chosen.
+func (l *routerList) locate(addr mech.NetworkAddr, dpid string) (*IPv4Routing, mechutil.RouteEntry, bool) {
+	var remote *IPv4Routing
+	var remoteRoute mechutil.RouteEntry
+
+	for _, m := range l.others(dpid) {
+		route, ok := m.routeTable.Lookup(addr)
+		if !ok || route.Type != mech.ConnectedRoute {
+			continue
+		}
+
+		if remote == nil || route.Network.Mask().Len() > remoteRoute.Network.Mask().Len() {
+			remote, remoteRoute = m, route
+		}
+	}
+
+	return remote, remoteRoute, remote != nil
+}
+
+// remoteMatch matches IPv4 packets destined to the network.
+func remoteMatch(network mech.NetworkAddr) ofp.Match {
+	return ofp.Match{ofp.MT_OXM, []ofp.OXM{
+		ofputil.EthType(uint16(iana.ETHT_IPV4), nil),
+		ofputil.IPv4DstAddr(network.Bytes(), network.Mask().Bytes()),
+	}}
+}
+
+// announce redirects packets destined to the network
+// connected to other switch to the controller.
+func (m *IPv4Routing) announce(network mech.NetworkAddr) error {
+	instructions := ofp.Instructions{ofp.InstructionActions{
+		ofp.IT_APPLY_ACTIONS, ofp.Actions{
+			ofp.ActionOutput{ofp.P_CONTROLLER, ofp.CML_NO_BUFFER},
+		},
+	}}
+
+	// Longer prefixes take precedence, local routes
+	// take precedence over remote networks of the same length.
+	flowMod := ofp.FlowMod{
+		Command:      ofp.FC_ADD,
+		TableID:      ofp.Table(m.tableNo),
+		BufferID:     ofp.NO_BUFFER,
+		Priority:     prefixPriority(network.Mask().Len(), false),
+		Match:        remoteMatch(network),
+		Instructions: instructions,
+	}
+
+	m.cookies.FilterFunc(&flowMod, m.ipPacketHandler)
+
+	r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
+	if err != nil {
+		log.ErrorLog("ipv4_paths/ANNOUNCE",
+			"Failed to create new ofp_flow_mod request: ", err)
+		return err
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("ipv4_paths/ANNOUNCE",
+			"Failed to send ofp_flow_mod request: ", err)
+	}
+
+	return err
+}
+
+// withdraw removes redirection of packets destined to the network.
+func (m *IPv4Routing) withdraw(network mech.NetworkAddr) error {
+	err := of.Send(m.C.Switch.Conn(),
+		ofputil.FlowFlush(ofp.Table(m.tableNo), remoteMatch(network)),
+	)
+
+	if err != nil {
+		log.ErrorLog("ipv4_paths/WITHDRAW",
+			"Failed to send requests: ", err)
+		return err
+	}
+
+	m.fibLock.Lock()
+	defer m.fibLock.Unlock()
+
+	// Local routes within the network are flushed as well.
+	return m.reinstall(&fibEntry{prefix: fibPrefix(network)})
+}
+
+// announceConnected notifies other switches about connected network.
+func (m *IPv4Routing) announceConnected(network mech.NetworkAddr) {
+	for _, router := range routers.others(m.C.Switch.ID()) {
+		router.announce(network)
+	}
+}
+
+// withdrawConnected notifies other switches about removed network.
+func (m *IPv4Routing) withdrawConnected(network mech.NetworkAddr) {
+	for _, router := range routers.others(m.C.Switch.ID()) {
+		router.withdraw(network)
+	}
+}
+
+// join adds mechanism to the list of routers and
+// learns networks connected to other switches.
+func (m *IPv4Routing) join() {
+	routers.add(m)
+
+	for _, router := range routers.others(m.C.Switch.ID()) {
+		for _, route := range router.routeTable.Routes() {
+			if route.Type == mech.ConnectedRoute {
+				m.announce(route.Network)
+			}
+		}
+	}
+}
+
+// leave removes mechanism from the list of routers.
+func (m *IPv4Routing) leave() {
+	routers.remove(m)
+}
+
+// hostMatch matches IPv4 packets destined to the host.
+func hostMatch(addr mech.NetworkAddr) ofp.Match {
+	return ofp.Match{ofp.MT_OXM, []ofp.OXM{
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_TYPE, of.Bytes(iana.ETHT_IPV4), nil},
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IPV4_DST, addr.Bytes(), nil},
+	}}
+}
+
+// installTransit forwards packets destined to the host through the port.
+func (m *IPv4Routing) installTransit(addr mech.NetworkAddr, port uint32) error {
+	instructions := ofp.Instructions{ofp.InstructionActions{
+		ofp.IT_APPLY_ACTIONS,
+		ofp.Actions{ofp.ActionOutput{ofp.PortNo(port), 0}},
+	}}
+
+	r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp.FlowMod{
+		Command:      ofp.FC_ADD,
+		TableID:      ofp.Table(m.tableNo),
+		BufferID:     ofp.NO_BUFFER,
+		IdleTimeout:  TransitIdleTimeout,
+		Priority:     hostPriority,
+		Match:        hostMatch(addr),
+		Instructions: instructions,
+	}))
+
+	if err != nil {
+		log.ErrorLog("ipv4_paths/INSTALL_TRANSIT",
+			"Failed to create new ofp_flow_mod request: ", err)
+		return err
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("ipv4_paths/INSTALL_TRANSIT",
+			"Failed to send ofp_flow_mod request: ", err)
+	}
+
+	return err
+}
+
+// forwardRemote installs flows on the shortest path to the switch,
+// that has network of the address directly connected, the last
+// switch of the path resolves link layer address of the host.
+func (m *IPv4Routing) forwardRemote(addr mech.NetworkAddr) error {
+	remote, _, ok := routers.locate(addr, m.C.Switch.ID())
+	if !ok {
+		log.DebugLogf("ipv4_paths/FORWARD_REMOTE",
+			"Network of %s is not connected to any switch", addr)
+		return mech.ErrPathNotFound
+	}
+
+	src, dst := m.C.Switch.ID(), remote.C.Switch.ID()
+	key := fmt.Sprintf("%s>%s", src, addr)
+
+	install := func(links []mech.TopologyLink) error {
+		if links == nil {
+			// Next packet will trigger path computation.
+			return of.Send(m.C.Switch.Conn(),
+				ofputil.FlowFlush(ofp.Table(m.tableNo), hostMatch(addr)))
+		}
+
+		for _, link := range links {
+			hop, ok := routers.get(link.SrcDatapath)
+			if !ok {
+				return mech.ErrPathNotFound
+			}
+
+			if err := hop.installTransit(addr, link.SrcPort); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	log.DebugLogf("ipv4_paths/FORWARD_REMOTE",
+		"Installing path to %s from %s to %s", addr, src, dst)
+
+	return mech.DefaultPaths.Install(key, src, dst, install)
+}
@@ -1,10 +1,14 @@
 package ip
 
 import (
+	"sync"
+
 	"github.com/netrack/net/iana"
 	"github.com/netrack/netrack/logging"
 	"github.com/netrack/netrack/mechanism"
 	"github.com/netrack/netrack/mechanism/mechutil"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ofp.v13"
 	"github.com/netrack/openflow"
 	"github.com/netrack/openflow/ofp.v13"
 	"github.com/netrack/openflow/ofp.v13/ofputil"
@@ -20,19 +24,29 @@ func init() {
 type IPv4Routing struct {
 	mech.BaseRoutingMechanism
 
-	cookies *of.CookieFilter
+	cookies *mech.CookieFilter
 
 	// IPv4 routing table instance.
 	routeTable *mechutil.RoutingTable
 
+	// Forwarding entries compiled from the best
+	// routes, indexed by network prefix.
+	fib     map[string]*fibEntry
+	fibLock sync.Mutex
+
+	// Identifiers of select groups of equal-cost routes.
+	groups map[ofp.Group]bool
+
 	// Table number allocated for the mechanism.
 	tableNo int
 }
 
 func NewIPv4Routing() mech.RoutingMechanism {
 	return &IPv4Routing{
-		cookies:    of.NewCookieFilter(),
+		cookies:    mech.NewCookieFilter(),
 		routeTable: mechutil.NewRoutingTable(),
+		fib:        make(map[string]*fibEntry),
+		groups:     make(map[ofp.Group]bool),
 	}
 }
 
@@ -44,6 +58,11 @@ func (m *IPv4Routing) Description() string {
 	return "MISSING DESCRIPNION!"
 }
 
+// Version implements VersionedMechanism interface.
+func (m *IPv4Routing) Version() string {
+	return ofp13.ProtoVersion
+}
+
 // Enable implements Mechanism interface
 func (m *IPv4Routing) Enable(c *mech.MechanismContext) {
 	m.BaseRoutingMechanism.Enable(c)
@@ -52,10 +71,25 @@ func (m *IPv4Routing) Enable(c *mech.MechanismContext) {
 	m.C.Mux.HandleFunc(of.T_PACKET_IN, m.packetInHandler)
 	m.C.Mux.HandleFunc(of.T_FLOW_REMOVED, m.flowRemovedHandler)
 
+	// Expose routing and forwarding information bases.
+	m.C.Managers.Bind(new(mech.ForwardingReader), m)
+	m.C.Managers.Bind(new(mech.GroupReader), m)
+
 	log.InfoLog("ipv4_routing/ENABLE_HOOK",
 		"IPv4 routing enabled")
 }
 
+// Disable implements Mechanism interface.
+func (m *IPv4Routing) Disable() {
+	m.BaseRoutingMechanism.Disable()
+
+	m.C.Managers.Unbind(new(mech.ForwardingReader))
+	m.C.Managers.Unbind(new(mech.GroupReader))
+
+	log.InfoLog("ipv4_routing/DISABLE_HOOK",
+		"IPv4 routing disabled")
+}
+
 func (m *IPv4Routing) Activate() {
 	m.BaseRoutingMechanism.Activate()
 
@@ -99,6 +133,19 @@ func (m *IPv4Routing) Activate() {
 		return
 	}
 
+	groupFlush, err := of.NewRequest(of.T_GROUP_MOD, of.NewReader(&ofp.GroupMod{
+		Command: ofp.GC_DELETE,
+		Type:    ofp.GT_ALL,
+		Group:   ofp.G_ALL,
+	}))
+
+	if err != nil {
+		log.ErrorLog("ipv4_routing/ACTIVATE_HOOK",
+			"Failed to create ofp_group_mod request: ", err)
+
+		return
+	}
+
 	err = of.Send(m.C.Switch.Conn(),
 		// Flush flows from table before using it.
 		ofputil.TableFlush(ofp.Table(m.tableNo)),
@@ -106,74 +153,113 @@ func (m *IPv4Routing) Activate() {
 		ofputil.FlowDrop(ofp.Table(m.tableNo)),
 		// Redirect all ARP requests to allocated table to process.
 		flowModGoto,
+		// Remove groups of equal-cost routes.
+		groupFlush,
 	)
 
 	if err != nil {
 		log.ErrorLog("ipv4_routing/ACTIVATE_HOOK",
 			"Failed to send requests: ", err)
+		return
+	}
+
+	// Flows of forwarding entries were flushed with the table.
+	m.fibLock.Lock()
+	m.fib = make(map[string]*fibEntry)
+	m.groups = make(map[ofp.Group]bool)
+	m.fibLock.Unlock()
+
+	// Recompile forwarding entries, when next hops are resolved.
+	if arpMech, err := m.arp(); err == nil {
+		arpMech.Watch(m.Name(), m)
+	}
+
+	// Learn networks connected to other switches.
+	m.join()
+}
+
+// Deactivate implements Mechanism interface.
+func (m *IPv4Routing) Deactivate() {
+	m.BaseRoutingMechanism.Deactivate()
+
+	// Other switches are not able to forward packets through this one.
+	m.leave()
+
+	if arpMech, err := m.arp(); err == nil {
+		arpMech.Unwatch(m.Name())
 	}
+
+	m.C.Managers.Unbind(new(mech.ForwardingReader))
+	m.C.Managers.Unbind(new(mech.GroupReader))
+
+	// Drop handlers of cookies of installed flows.
+	m.cookies = mech.NewCookieFilter()
+
+	// Release acquired table
+	m.C.Switch.ReleaseTable(m.tableNo)
+
+	log.InfoLog("ipv4_routing/DEACTIVATE_HOOK",
+		"IPv4 routing deactivated")
+}
+
+// OwnsFlow implements mech.FlowOwner interface.
+func (m *IPv4Routing) OwnsFlow(table int, cookie uint64) bool {
+	return table != 0 && table == m.tableNo ||
+		m.cookies.Contains(cookie)
 }
 
 func (m *IPv4Routing) UpdateRoute(context *mech.RoutingContext) error {
 	log.DebugLog("ipv4_routing/UPDATE_ROUTE",
 		"Got routing update route request")
 
-	// Match IPv4 packets of specified route.
-	match := ofp.Match{ofp.MT_OXM, []ofp.OXM{
-		ofputil.EthType(uint16(iana.ETHT_IPV4), nil),
-		ofputil.IPv4DstAddr(context.Network.Bytes(), context.Network.Mask().Bytes()),
-	}}
-
-	// Send all such packets to controller.
-	instruction := ofp.Instructions{ofp.InstructionActions{
-		ofp.IT_APPLY_ACTIONS, ofp.Actions{
-			ofp.ActionOutput{ofp.P_CONTROLLER, ofp.CML_NO_BUFFER},
-		},
-	}}
-
-	flowMod := ofp.FlowMod{
-		Command: ofp.FC_ADD,
-		TableID: ofp.Table(m.tableNo),
-		// Notify controller, when flow removed
-		Flags:        ofp.FF_SEND_FLOW_REM,
-		BufferID:     ofp.NO_BUFFER,
-		Priority:     15,
-		Match:        match,
-		Instructions: instruction,
+	if !isIPv4(context.Network) {
+		return nil
 	}
 
-	// Move ip packets to ipPacketHandler
-	m.cookies.FilterFunc(&flowMod, m.ipPacketHandler)
-
 	// Update routing table with new address
-	m.routeTable.Populate(mechutil.RouteEntry{
-		Type:    context.Type,
-		Network: context.Network,
-		NextHop: context.NextHop,
-		Port:    context.Port,
+	err := m.routeTable.Populate(mechutil.RouteEntry{
+		Type:     context.Type,
+		Network:  context.Network,
+		NextHop:  context.NextHop,
+		Port:     context.Port,
+		Distance: context.Distance,
+		Metric:   context.Metric,
 	})
 
-	r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
 	if err != nil {
 		log.ErrorLog("ipv4_routing/UPDATE_ROUTE",
-			"Failed to create new ofp_flow_mod request: ", err)
+			"Failed to update routing table: ", err)
 		return err
 	}
 
-	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
-		log.ErrorLog("ipv4_routing/UPDATE_ROUTE",
-			"Failed to send ofp_flow_mode request: ", err)
+	// Install the best route to the network.
+	m.fibLock.Lock()
+	err = m.compile(context.Network)
+	m.fibLock.Unlock()
+
+	if err != nil {
+		return err
 	}
 
-	return err
+	// Make connected network reachable from other switches.
+	if context.Type == mech.ConnectedRoute {
+		m.announceConnected(context.Network)
+	}
+
+	return nil
 }
 
 func (m *IPv4Routing) DeleteRoute(context *mech.RoutingContext) error {
 	log.DebugLog("ipv4_routing/DELETE_ROUTE",
 		"Got delete route request")
 
+	if !isIPv4(context.Network) {
+		return nil
+	}
+
 	// Update routing table with new address
 	evicted := m.routeTable.Evict(mechutil.RouteEntry{
+		Type:    context.Type,
 		Network: context.Network,
 		NextHop: context.NextHop,
 		Port:    context.Port,
@@ -185,22 +271,21 @@ func (m *IPv4Routing) DeleteRoute(context *mech.RoutingContext) error {
 		return nil
 	}
 
-	// Match IPv4 packets of specified route.
-	match := ofp.Match{ofp.MT_OXM, []ofp.OXM{
-		ofputil.EthType(uint16(iana.ETHT_IPV4), nil),
-		ofputil.IPv4DstAddr(context.Network.Bytes(), context.Network.Mask().Bytes()),
-	}}
+	if context.Type == mech.ConnectedRoute {
+		m.withdrawConnected(context.Network)
+	}
 
-	err := of.Send(m.C.Switch.Conn(),
-		ofputil.FlowFlush(ofp.Table(m.tableNo), match),
-	)
+	// Install the next best route to the network, flows of
+	// longer prefixes are not removed along with the route.
+	m.fibLock.Lock()
+	defer m.fibLock.Unlock()
 
-	if err != nil {
-		log.ErrorLog("ipv4_routing/DELETE_ROUTES",
-			"Failed to send requests: ", err)
-	}
+	return m.compile(context.Network)
+}
 
-	return err
+// Lookup returns route to the network layer address.
+func (m *IPv4Routing) Lookup(addr mech.NetworkAddr) (mechutil.RouteEntry, bool) {
+	return m.routeTable.Lookup(addr)
 }
 
 func (m *IPv4Routing) packetInHandler(rw of.ResponseWriter, r *of.Request) {
@@ -233,7 +318,7 @@ func (m *IPv4Routing) ipPacketHandler(rw of.ResponseWriter, r *of.Request) {
 		return
 	}
 
-	nldriver, err := mech.NetworkDrv(m.C)
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
 	if err != nil {
 		log.InfoLog("ipv4_routing/IP_PACKET_HANDLER",
 			"Network layer driver is not intialized: ", err)
@@ -253,47 +338,54 @@ func (m *IPv4Routing) ipPacketHandler(rw of.ResponseWriter, r *of.Request) {
 		"Got ip packet to: ", pdu3.DstAddr)
 
 	route, ok := m.routeTable.Lookup(pdu3.DstAddr)
-	if !ok {
+
+	// Destination could be behind another switch, network connected
+	// to it is preferred, when its prefix is longer than local one.
+	_, remoteRoute, remote := routers.locate(pdu3.DstAddr, m.C.Switch.ID())
+	if remote && (!ok || remoteRoute.Network.Mask().Len() > route.Network.Mask().Len()) {
 		log.DebugLogf("ipv4_routing/IP_PACKET_HANDLER",
-			"Route to %s not found", pdu3.DstAddr)
-		return
-	}
+			"Network of %s is connected to other switch", pdu3.DstAddr)
 
-	// Search for link layer address of egress port.
-	srcAddr, err := lldriver.Addr(route.Port)
-	if err != nil {
-		log.ErrorLog("ipv4_routing/PACKET_IN_HANDLER",
-			"Failed to retrieve port link layer address: ", err)
+		m.forwardRemote(pdu3.DstAddr)
 		return
 	}
 
-	var network mech.NetworkMechanismManager
-	if err = m.C.Managers.Obtain(&network); err != nil {
-		log.ErrorLog("ipv4_routing/PACKET_IN_HANDLER",
-			"Failed to obtain network layer manager: ", err)
+	if !ok {
+		log.DebugLog("ipv4_routing/IP_PACKET_HANDLER",
+			"Route not found: ", pdu3.DstAddr)
 		return
 	}
 
-	nmech, err := network.Mechanism(ARPMechanismName)
+	arpMech, err := m.arp()
 	if err != nil {
-		log.ErrorLog("ipv4_routing/IP_PACKET_HANDLER",
-			"ARP network mechanism is not found: ", err)
 		return
 	}
 
-	arpMech, ok := nmech.(*ARPMechanism)
-	if !ok {
-		log.ErrorLog("ipv4_routing/IP_PACKET_HANDLER",
-			"Failed to cast mechanism to arp mechanism type")
+	// Packets to the unresolved next hop are dropped, flows
+	// are installed, once the link layer address is learned.
+	if route.NextHop != nil {
+		log.DebugLog("ipv4_routing/IP_PACKET_HANDLER",
+			"Next hop is not resolved: ", route.NextHop)
+
+		// Resolve all next hops of the equal-cost routes.
+		for _, route := range m.routeTable.Best(route.Network) {
+			if route.NextHop != nil {
+				arpMech.Request(route.NextHop, route.Port)
+			}
+		}
+
 		return
 	}
 
-	netwAddr := route.NextHop
-	if netwAddr == nil {
-		netwAddr = pdu3.DstAddr
+	// Search for link layer address of egress port.
+	srcAddr, err := lldriver.Addr(route.Port)
+	if err != nil {
+		log.ErrorLog("ipv4_routing/PACKET_IN_HANDLER",
+			"Failed to retrieve port link layer address: ", err)
+		return
 	}
 
-	dstAddr, err := arpMech.Lookup(netwAddr, route.Port)
+	dstAddr, err := arpMech.Lookup(pdu3.DstAddr, route.Port)
 	if err != nil {
 		log.ErrorLog("ipv4_routing/IP_PACKET_HANDLER",
 			"Failed to resolve link layer address: ", err)
@@ -303,9 +395,11 @@ func (m *IPv4Routing) ipPacketHandler(rw of.ResponseWriter, r *of.Request) {
 	log.DebugLog("ipv4_routing/IP_PACKET_HANDLER",
 		"Resolved link layer address: ", dstAddr)
 
-	// Create permanent rule for discovered address.
+	// Create permanent rule for discovered address, packets
+	// of different VLANs require different tag operations.
 	match := ofp.Match{ofp.MT_OXM, []ofp.OXM{
 		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_TYPE, of.Bytes(iana.ETHT_IPV4), nil},
+		ofp13.VLANMatch(pdu2.VLAN),
 		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IPV4_DST, pdu3.DstAddr.Bytes(), nil},
 	}}
 
@@ -313,14 +407,19 @@ func (m *IPv4Routing) ipPacketHandler(rw of.ResponseWriter, r *of.Request) {
 	setDst := ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_DST, dstAddr.Bytes(), nil}
 	setSrc := ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_SRC, srcAddr.Bytes(), nil}
 
+	// Move packet from the VLAN of ingress interface
+	// to the VLAN of egress interface.
+	actions := ofp13.VLANActions(pdu2.VLAN, arpMech.VLAN(route.Port))
+
+	actions = append(actions,
+		ofp.ActionSetField{setDst},
+		ofp.ActionSetField{setSrc},
+		ofp.Action{ofp.AT_DEC_NW_TTL},
+		ofp.ActionOutput{ofp.PortNo(route.Port), 0},
+	)
+
 	instructions := ofp.Instructions{ofp.InstructionActions{
-		ofp.IT_APPLY_ACTIONS,
-		ofp.Actions{
-			ofp.ActionSetField{setDst},
-			ofp.ActionSetField{setSrc},
-			ofp.Action{ofp.AT_DEC_NW_TTL},
-			ofp.ActionOutput{ofp.PortNo(route.Port), 0},
-		},
+		ofp.IT_APPLY_ACTIONS, actions,
 	}}
 
 	// TODO: set expire timeout
@@ -328,7 +427,7 @@ func (m *IPv4Routing) ipPacketHandler(rw of.ResponseWriter, r *of.Request) {
 		Command:      ofp.FC_ADD,
 		TableID:      ofp.Table(m.tableNo),
 		BufferID:     ofp.NO_BUFFER,
-		Priority:     25,
+		Priority:     hostPriority,
 		Match:        match,
 		Instructions: instructions,
 	}
@@ -0,0 +1,311 @@
+package ip
+
+import (
+	"net"
+
+	"github.com/netrack/net/iana"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/mechanism/mechutil"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ofp.v10"
+	"github.com/netrack/openflow"
+)
+
+const IPv4Routing10Name = "ipv4-ofp1.0"
+
+func init() {
+	constructor := mech.RoutingMechanismConstructorFunc(NewIPv4Routing10)
+	mech.RegisterRoutingMechanism(IPv4Routing10Name, constructor)
+}
+
+// IPv4Routing10 implements IPv4 routing for OpenFlow 1.0 switches.
+type IPv4Routing10 struct {
+	mech.BaseRoutingMechanism
+
+	// Stores registered handlers to delete them later.
+	filter *of.ServeFilter
+
+	// IPv4 routing table instance.
+	routeTable *mechutil.RoutingTable
+}
+
+func NewIPv4Routing10() mech.RoutingMechanism {
+	return &IPv4Routing10{
+		filter:     of.NewServeFilter(),
+		routeTable: mechutil.NewRoutingTable(),
+	}
+}
+
+func (m *IPv4Routing10) Name() string {
+	return IPv4Routing10Name
+}
+
+func (m *IPv4Routing10) Description() string {
+	return "IPv4 routing for OpenFlow 1.0 switches"
+}
+
+// Version implements VersionedMechanism interface.
+func (m *IPv4Routing10) Version() string {
+	return ofp10.ProtoVersion
+}
+
+// Enable implements Mechanism interface
+func (m *IPv4Routing10) Enable(c *mech.MechanismContext) {
+	m.BaseRoutingMechanism.Enable(c)
+
+	m.filter.Mux = m.C.Mux
+	// Handle incoming IPv4 packets.
+	m.filter.HandleFunc(of.T_PACKET_IN, m.packetInHandler)
+
+	log.InfoLog("ipv4_routing1.0/ENABLE_HOOK",
+		"IPv4 routing enabled")
+}
+
+// Disable implements Mechanism interface.
+func (m *IPv4Routing10) Disable() {
+	m.BaseRoutingMechanism.Disable()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+
+	log.InfoLog("ipv4_routing1.0/DISABLE_HOOK",
+		"IPv4 routing disabled")
+}
+
+// Deactivate implements Mechanism interface.
+func (m *IPv4Routing10) Deactivate() {
+	m.BaseRoutingMechanism.Deactivate()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+
+	log.InfoLog("ipv4_routing1.0/DEACTIVATE_HOOK",
+		"IPv4 routing deactivated")
+}
+
+func (m *IPv4Routing10) UpdateRoute(context *mech.RoutingContext) error {
+	log.DebugLog("ipv4_routing1.0/UPDATE_ROUTE",
+		"Got routing update route request")
+
+	if !isIPv4(context.Network) {
+		return nil
+	}
+
+	// Match IPv4 packets of specified route.
+	match := ofp10.NewMatch(
+		ofp10.MatchEthType(uint16(iana.ETHT_IPV4)),
+		ofp10.MatchIPv4Dst(context.Network.Bytes(), context.Network.Mask().Bytes()),
+	)
+
+	// Update routing table with new address
+	m.routeTable.Populate(mechutil.RouteEntry{
+		Type:     context.Type,
+		Network:  context.Network,
+		NextHop:  context.NextHop,
+		Port:     context.Port,
+		Distance: context.Distance,
+		Metric:   context.Metric,
+	})
+
+	r, err := ofp10.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp10.FlowMod{
+		Command:  ofp10.FC_ADD,
+		BufferID: ofp10.NO_BUFFER,
+		Priority: 15,
+		Match:    match,
+		// Send all such packets to controller.
+		Actions: ofp10.Actions{ofp10.ActionOutput{ofp10.P_CONTROLLER, 0xffff}},
+	}))
+
+	if err != nil {
+		log.ErrorLog("ipv4_routing1.0/UPDATE_ROUTE",
+			"Failed to create new ofp_flow_mod request: ", err)
+		return err
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("ipv4_routing1.0/UPDATE_ROUTE",
+			"Failed to send ofp_flow_mod request: ", err)
+	}
+
+	return err
+}
+
+func (m *IPv4Routing10) DeleteRoute(context *mech.RoutingContext) error {
+	log.DebugLog("ipv4_routing1.0/DELETE_ROUTE",
+		"Got delete route request")
+
+	if !isIPv4(context.Network) {
+		return nil
+	}
+
+	evicted := m.routeTable.Evict(mechutil.RouteEntry{
+		Type:    context.Type,
+		Network: context.Network,
+		NextHop: context.NextHop,
+		Port:    context.Port,
+	})
+
+	if !evicted {
+		log.ErrorLog("ipv4_routing1.0/DELETE_ROUTE",
+			"Failed to delete specified route: ", context.Network)
+		return nil
+	}
+
+	match := ofp10.NewMatch(
+		ofp10.MatchEthType(uint16(iana.ETHT_IPV4)),
+		ofp10.MatchIPv4Dst(context.Network.Bytes(), context.Network.Mask().Bytes()),
+	)
+
+	err := of.Send(m.C.Switch.Conn(), ofp10.FlowFlush(match))
+	if err != nil {
+		log.ErrorLog("ipv4_routing1.0/DELETE_ROUTE",
+			"Failed to send requests: ", err)
+	}
+
+	return err
+}
+
+// localAddr returns true, when specified address is
+// assigned to one of the switch ports.
+func (m *IPv4Routing10) localAddr(nldriver mech.NetworkDriver, addr mech.NetworkAddr) bool {
+	for _, port := range m.C.Switch.PortList() {
+		for _, nladdr := range nldriver.Addrs(port.Number) {
+			if net.IP(nladdr.Bytes()).Equal(net.IP(addr.Bytes())) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (m *IPv4Routing10) packetInHandler(rw of.ResponseWriter, r *of.Request) {
+	var packet ofp10.PacketIn
+	var pdu2 mech.LinkFrame
+	var pdu3 mech.NetworkPacket
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		log.InfoLog("ipv4_routing1.0/PACKET_IN_HANDLER",
+			"Link layer driver is not initialized: ", err)
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		log.InfoLog("ipv4_routing1.0/PACKET_IN_HANDLER",
+			"Network layer driver is not intialized: ", err)
+		return
+	}
+
+	llreader := mech.MakeLinkReaderFrom(lldriver, &pdu2)
+	if _, err = of.ReadAllFrom(r.Body, &packet, llreader); err != nil {
+		log.ErrorLog("ipv4_routing1.0/PACKET_IN_HANDLER",
+			"Failed to read packet: ", err)
+		return
+	}
+
+	// Skip packets of other protocols.
+	if pdu2.Proto != mech.Proto(iana.ETHT_IPV4) {
+		return
+	}
+
+	nlreader := mech.MakeNetworkReaderFrom(nldriver, &pdu3)
+	if _, err = of.ReadAllFrom(r.Body, nlreader); err != nil {
+		log.ErrorLog("ipv4_routing1.0/PACKET_IN_HANDLER",
+			"Failed to read network packet: ", err)
+		return
+	}
+
+	// Packets to the switch itself are served by other mechanisms.
+	if m.localAddr(nldriver, pdu3.DstAddr) {
+		return
+	}
+
+	log.DebugLog("ipv4_routing1.0/PACKET_IN_HANDLER",
+		"Got ip packet to: ", pdu3.DstAddr)
+
+	route, ok := m.routeTable.Lookup(pdu3.DstAddr)
+	if !ok {
+		log.DebugLogf("ipv4_routing1.0/PACKET_IN_HANDLER",
+			"Route to %s not found", pdu3.DstAddr)
+		return
+	}
+
+	// Search for link layer address of egress port.
+	srcAddr, err := lldriver.Addr(route.Port)
+	if err != nil {
+		log.ErrorLog("ipv4_routing1.0/PACKET_IN_HANDLER",
+			"Failed to retrieve port link layer address: ", err)
+		return
+	}
+
+	var network mech.NetworkMechanismManager
+	if err = m.C.Managers.Obtain(&network); err != nil {
+		log.ErrorLog("ipv4_routing1.0/PACKET_IN_HANDLER",
+			"Failed to obtain network layer manager: ", err)
+		return
+	}
+
+	nmech, err := network.Mechanism(ARP10MechanismName)
+	if err != nil {
+		log.ErrorLog("ipv4_routing1.0/PACKET_IN_HANDLER",
+			"ARP network mechanism is not found: ", err)
+		return
+	}
+
+	arpMech, ok := nmech.(*ARP10Mechanism)
+	if !ok {
+		log.ErrorLog("ipv4_routing1.0/PACKET_IN_HANDLER",
+			"Failed to cast mechanism to arp mechanism type")
+		return
+	}
+
+	netwAddr := route.NextHop
+	if netwAddr == nil {
+		netwAddr = pdu3.DstAddr
+	}
+
+	dstAddr, err := arpMech.Lookup(netwAddr, route.Port)
+	if err != nil {
+		log.ErrorLog("ipv4_routing1.0/PACKET_IN_HANDLER",
+			"Failed to resolve link layer address: ", err)
+		return
+	}
+
+	log.DebugLog("ipv4_routing1.0/PACKET_IN_HANDLER",
+		"Resolved link layer address: ", dstAddr)
+
+	// Create permanent rule for discovered address. OpenFlow 1.0
+	// does not define an action to decrement TTL, so it is
+	// left untouched.
+	// TODO: set expire timeout
+	flowMod := ofp10.FlowMod{
+		Command:  ofp10.FC_ADD,
+		BufferID: packet.BufferID,
+		Priority: 25,
+		Match: ofp10.NewMatch(
+			ofp10.MatchEthType(uint16(iana.ETHT_IPV4)),
+			ofp10.MatchIPv4Dst(pdu3.DstAddr.Bytes(), nil),
+		),
+		Actions: ofp10.Actions{
+			ofp10.ActionSetDLDst{dstAddr.Bytes()},
+			ofp10.ActionSetDLSrc{srcAddr.Bytes()},
+			ofp10.ActionOutput{ofp10.PortNo(route.Port), 0},
+		},
+	}
+
+	if _, err = of.WriteAllTo(rw, &flowMod); err != nil {
+		log.ErrorLog("ipv4_routing1.0/PACKET_IN_HANDLER",
+			"Failed to write response: ", err)
+		return
+	}
+
+	rw.Header().Set(of.TypeHeaderKey, of.T_FLOW_MOD)
+	rw.Header().Set(of.VersionHeaderKey, ofp10.VERSION)
+	if err = rw.WriteHeader(); err != nil {
+		log.ErrorLog("ipv4_routing1.0/PACKET_IN_HANDLER",
+			"Failed to send ofp_flow_mod response: ", err)
+	}
+}
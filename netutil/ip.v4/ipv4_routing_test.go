@@ -12,3 +12,6 @@ func TestIPv4DeleteRoute(t *testing.T) {
 
 func TestIPv4PacketHandler(t *testing.T) {
 }
+
+func TestIPv4ForwardRemote(t *testing.T) {
+}
@@ -0,0 +1,306 @@
+package ip6
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/netrack/net/iana"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ofp.v13"
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+	"github.com/netrack/openflow/ofp.v13/ofputil"
+)
+
+const ICMPv6MechanismName = "icmpv6"
+
+func init() {
+	constructor := mech.NetworkMechanismConstructorFunc(NewICMPv6Mechanism)
+	mech.RegisterNetworkMechanism(ICMPv6MechanismName, constructor)
+}
+
+func EchoRequest(ipaddr []byte) ofp.Match {
+	// Match ICMPv6 echo-request messages to created network address.
+	return icmpv6Match(ICMPv6EchoRequest,
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IPV6_DST, ipaddr, nil},
+	)
+}
+
+type ICMPv6Mechanism struct {
+	mech.BaseNetworkMechanism
+
+	// Handle request based on cookie value.
+	cookies *mech.CookieFilter
+
+	// Stores registered handlers to delete them later.
+	filter *of.ServeFilter
+}
+
+func NewICMPv6Mechanism() mech.NetworkMechanism {
+	return &ICMPv6Mechanism{
+		cookies: mech.NewCookieFilter(),
+		filter:  of.NewServeFilter(),
+	}
+}
+
+func (m *ICMPv6Mechanism) Name() string {
+	return ICMPv6MechanismName
+}
+
+func (m *ICMPv6Mechanism) Description() string {
+	return "ICMPv6 echo responder"
+}
+
+// Version implements VersionedMechanism interface.
+func (m *ICMPv6Mechanism) Version() string {
+	return ofp13.ProtoVersion
+}
+
+func (m *ICMPv6Mechanism) Enable(c *mech.MechanismContext) {
+	m.BaseNetworkMechanism.Enable(c)
+
+	m.filter.Mux = m.C.Mux
+	// Handle incoming ICMPv6 requests.
+	m.filter.HandleFunc(of.T_PACKET_IN, m.packetInHandler)
+	m.filter.HandleFunc(of.T_FLOW_REMOVED, m.flowRemovedHandler)
+
+	log.InfoLog("icmpv6/ENABLE_HOOK", "Mechanism ICMPv6 enabled")
+}
+
+func (m *ICMPv6Mechanism) Activate() {
+	m.BaseNetworkMechanism.Activate()
+
+	// Operate on PacketIn messages
+	m.cookies.Baker = ofputil.PacketInBaker()
+}
+
+// Disable implements Mechanism interface.
+func (m *ICMPv6Mechanism) Disable() {
+	m.BaseNetworkMechanism.Disable()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+
+	log.InfoLog("icmpv6/DISABLE_HOOK", "Mechanism ICMPv6 disabled")
+}
+
+// Deactivate implements Mechanism interface.
+func (m *ICMPv6Mechanism) Deactivate() {
+	m.BaseNetworkMechanism.Deactivate()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+
+	// Drop handlers of cookies of installed flows.
+	m.cookies = mech.NewCookieFilter()
+
+	log.InfoLog("icmpv6/DEACTIVATE_HOOK", "Mechanism ICMPv6 deactivated")
+}
+
+// OwnsFlow implements mech.FlowOwner interface.
+func (m *ICMPv6Mechanism) OwnsFlow(table int, cookie uint64) bool {
+	return m.cookies.Contains(cookie)
+}
+
+func (m *ICMPv6Mechanism) CreateNetworkPreCommit(context *mech.NetworkContext) error {
+	return m.UpdateNetworkPostCommit(context)
+}
+
+func (m *ICMPv6Mechanism) UpdateNetworkPreCommit(context *mech.NetworkContext) error {
+	return m.DeleteNetworkPreCommit(context)
+}
+
+// addrs returns global and link-local addresses of the interface.
+func addrs(context *mech.NetworkContext) [][]byte {
+	linkLocal := LinkLocal(context.LinkAddr.Bytes())
+	return [][]byte{context.NetworkAddr.Bytes(), []byte(linkLocal)}
+}
+
+func (m *ICMPv6Mechanism) UpdateNetworkPostCommit(context *mech.NetworkContext) error {
+	log.DebugLog("icmpv6/UPDATE_NETWORK_POSTCOMMIT",
+		"Got update network request")
+
+	if !isIPv6(context.NetworkAddr) {
+		return nil
+	}
+
+	// Send ICMPv6 message to the controller
+	instructions := ofp.Instructions{ofp.InstructionActions{
+		ofp.IT_APPLY_ACTIONS,
+		ofp.Actions{ofp.ActionOutput{ofp.P_CONTROLLER, ofp.CML_NO_BUFFER}},
+	}}
+
+	var requests []*of.Request
+
+	for _, addr := range addrs(context) {
+		flowMod := ofp.FlowMod{
+			Command:  ofp.FC_ADD,
+			BufferID: ofp.NO_BUFFER,
+			// Notify controller, when flow removed
+			Flags:        ofp.FF_SEND_FLOW_REM,
+			Priority:     30, // Use non-zero priority
+			Match:        EchoRequest(addr),
+			Instructions: instructions,
+		}
+
+		// Assign cookie to FlowMod message, and
+		// redirect such requests to icmpEchoHandler
+		m.cookies.FilterFunc(&flowMod, m.icmpEchoHandler)
+
+		r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
+		if err != nil {
+			log.ErrorLog("icmpv6/UPDATE_NETWORK_POSTCOMMIT",
+				"Failed to create a new ofp_flow_mod request: ", err)
+			return err
+		}
+
+		requests = append(requests, r)
+	}
+
+	err := of.Send(m.C.Switch.Conn(), requests...)
+	if err != nil {
+		log.ErrorLog("icmpv6/UPDATE_NETWORK_POSTCOMMIT",
+			"Failed to send requests: ", err)
+	}
+
+	return err
+}
+
+func (m *ICMPv6Mechanism) DeleteNetworkPreCommit(context *mech.NetworkContext) error {
+	log.DebugLog("icmpv6/DELETE_NETWORK_PRECOMMIT",
+		"Got network delete precommit request")
+
+	if !isIPv6(context.NetworkAddr) {
+		return nil
+	}
+
+	var requests []*of.Request
+
+	// Link-local address is kept, while other
+	// IPv6 addresses are assigned to the port.
+	nladdrs := addrs(context)
+	if context.Shared() {
+		nladdrs = nladdrs[:1]
+	}
+
+	// Flush ICMPv6 flows for specified addresses (if any).
+	for _, addr := range nladdrs {
+		requests = append(requests, ofputil.FlowFlush(0, EchoRequest(addr)))
+	}
+
+	err := of.Send(m.C.Switch.Conn(), requests...)
+	if err != nil {
+		log.ErrorLog("icmpv6/DELETE_NETWORK_PRECOMMIT",
+			"Failed to send requests: ", err)
+	}
+
+	return err
+}
+
+func (m *ICMPv6Mechanism) packetInHandler(rw of.ResponseWriter, r *of.Request) {
+	m.cookies.Serve(rw, r)
+}
+
+func (m *ICMPv6Mechanism) flowRemovedHandler(rw of.ResponseWriter, r *of.Request) {
+	var flowRemoved ofp.FlowRemoved
+
+	_, err := of.ReadAllFrom(r.Body, &flowRemoved)
+	if err != nil {
+		return
+	}
+
+	m.cookies.Release(&flowRemoved)
+}
+
+func (m *ICMPv6Mechanism) icmpEchoHandler(rw of.ResponseWriter, r *of.Request) {
+	var packet ofp.PacketIn
+	var pdu2 mech.LinkFrame
+	var pdu3 mech.NetworkPacket
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv6DriverName)
+	if err != nil {
+		return
+	}
+
+	llreader := mech.MakeLinkReaderFrom(lldriver, &pdu2)
+	nlreader := mech.MakeNetworkReaderFrom(nldriver, &pdu3)
+
+	if _, err = of.ReadAllFrom(r.Body, &packet, llreader, nlreader); err != nil {
+		log.ErrorLog("icmpv6/PACKET_IN_HANDLER",
+			"Failed to read packet: ", err)
+		return
+	}
+
+	// Read icmpv6 echo-request message
+	icmp, err := ReadICMPv6(r.Body, pdu3.ContentLen)
+	if err != nil {
+		log.ErrorLog("icmpv6/PACKET_IN_HANDLER",
+			"Failed to read ICMPv6 message: ", err)
+		return
+	}
+
+	src, dst := net.IP(pdu3.SrcAddr.Bytes()), net.IP(pdu3.DstAddr.Bytes())
+	if !icmp.VerifyChecksum(src, dst) {
+		log.ErrorLog("icmpv6/PACKET_IN_HANDLER",
+			"Failed to verify ICMPv6 message: ", ErrICMPv6Checksum)
+		return
+	}
+
+	log.DebugLogf("icmpv6/ECHO_REQUEST_HANDLER",
+		"Got ICMPv6 echo-request: %s -> %s", pdu3.SrcAddr, pdu3.DstAddr)
+
+	// Get port number from match fields.
+	portNo := packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.UInt32()
+
+	// Search for link layer address of egress port.
+	lladdr, err := lldriver.Addr(portNo)
+	if err != nil {
+		log.ErrorLog("icmpv6/PACKET_IN_HWADDR_ERR",
+			"Failed to retrieve port hardware address: ", err)
+		return
+	}
+
+	// Build link layer PDU.
+	pdu2 = mech.LinkFrame{pdu2.SrcAddr, lladdr, mech.Proto(iana.ETHT_IPV6), pdu2.VLAN, 0}
+
+	// Send echo-reply message, identifier, sequence
+	// number and data are copied from the request.
+	reply := ICMPv6{Type: ICMPv6EchoReply, Body: icmp.Body}
+
+	// Build network layer PDU.
+	pdu3 = mech.NetworkPacket{
+		DstAddr: pdu3.SrcAddr,
+		SrcAddr: pdu3.DstAddr,
+		Proto:   pdu3.Proto,
+		Payload: bytes.NewReader(reply.Marshal(dst, src)),
+	}
+
+	packetOut := ofp.PacketOut{BufferID: ofp.NO_BUFFER,
+		InPort:  packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.PortNo(),
+		Actions: ofp.Actions{ofp.ActionOutput{ofp.P_IN_PORT, 0}},
+	}
+
+	llwriter := mech.MakeLinkWriterTo(lldriver, &pdu2)
+	nlwriter := mech.MakeNetworkWriterTo(nldriver, &pdu3)
+
+	_, err = of.WriteAllTo(rw, &packetOut, llwriter, nlwriter)
+	if err != nil {
+		log.ErrorLog("icmpv6/PACKET_IN_HANDLER",
+			"Failed to write response: ", err)
+		return
+	}
+
+	rw.Header().Set(of.TypeHeaderKey, of.T_PACKET_OUT)
+	rw.Header().Set(of.VersionHeaderKey, ofp.VERSION)
+	if err = rw.WriteHeader(); err != nil {
+		log.ErrorLog("icmpv6/PACKET_IN_HANDLER",
+			"Failed to send ICMPv6 echo-reply response: ", err)
+	}
+}
@@ -0,0 +1,14 @@
+package ip6
+
+import (
+	"testing"
+)
+
+func TestICMPv6UpdateNetworkPreCommit(t *testing.T) {
+}
+
+func TestICMPv6DeleteNetworkPreCommit(t *testing.T) {
+}
+
+func TestICMPv6EchoHandler(t *testing.T) {
+}
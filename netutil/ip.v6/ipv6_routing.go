@@ -0,0 +1,461 @@
+package ip6
+
+import (
+	"net"
+
+	"github.com/netrack/net/iana"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/mechanism/mechutil"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ofp.v13"
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+	"github.com/netrack/openflow/ofp.v13/ofputil"
+)
+
+const IPv6RoutingName = "ipv6"
+
+func init() {
+	constructor := mech.RoutingMechanismConstructorFunc(NewIPv6Routing)
+	mech.RegisterRoutingMechanism(IPv6RoutingName, constructor)
+}
+
+// routeMatch matches IPv6 packets destined to the network.
+func routeMatch(network mech.NetworkAddr) ofp.Match {
+	return ofp.Match{ofp.MT_OXM, []ofp.OXM{
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_TYPE, of.Bytes(iana.ETHT_IPV6), nil},
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IPV6_DST, network.Bytes(), network.Mask().Bytes()},
+	}}
+}
+
+// IPv6Routing forwards IPv6 packets according to the
+// routing table, next hops are resolved with NDP.
+type IPv6Routing struct {
+	mech.BaseRoutingMechanism
+
+	cookies *mech.CookieFilter
+
+	// Stores registered handlers to delete them later.
+	filter *of.ServeFilter
+
+	// IPv6 routing table instance.
+	routeTable *mechutil.RoutingTable
+
+	// Table number allocated for the mechanism.
+	tableNo int
+}
+
+func NewIPv6Routing() mech.RoutingMechanism {
+	return &IPv6Routing{
+		cookies:    mech.NewCookieFilter(),
+		filter:     of.NewServeFilter(),
+		routeTable: mechutil.NewRoutingTable(),
+	}
+}
+
+func (m *IPv6Routing) Name() string {
+	return IPv6RoutingName
+}
+
+func (m *IPv6Routing) Description() string {
+	return "IPv6 routing"
+}
+
+// Version implements VersionedMechanism interface.
+func (m *IPv6Routing) Version() string {
+	return ofp13.ProtoVersion
+}
+
+// Enable implements Mechanism interface
+func (m *IPv6Routing) Enable(c *mech.MechanismContext) {
+	m.BaseRoutingMechanism.Enable(c)
+
+	m.filter.Mux = m.C.Mux
+	// Handle incoming IPv6 packets.
+	m.filter.HandleFunc(of.T_PACKET_IN, m.packetInHandler)
+	m.filter.HandleFunc(of.T_FLOW_REMOVED, m.flowRemovedHandler)
+
+	log.InfoLog("ipv6_routing/ENABLE_HOOK",
+		"IPv6 routing enabled")
+}
+
+func (m *IPv6Routing) Activate() {
+	m.BaseRoutingMechanism.Activate()
+
+	// Operate on PacketIn messages
+	m.cookies.Baker = ofputil.PacketInBaker()
+
+	// Allocate table for handling ipv6 protocol.
+	tableNo, err := m.C.Switch.AllocateTable()
+	if err != nil {
+		log.ErrorLog("ipv6_routing/ACTIVATE_HOOK",
+			"Failed to allocate a new table: ", err)
+		return
+	}
+
+	m.tableNo = tableNo
+
+	log.DebugLog("ipv6_routing/ACTIVATE_HOOK",
+		"Allocated table: ", tableNo)
+
+	// Match packets of IPv6 protocol.
+	match := ofp.Match{ofp.MT_OXM, []ofp.OXM{
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_TYPE, of.Bytes(iana.ETHT_IPV6), nil},
+	}}
+
+	// Move all packets to allocated matching table for IPv6 packets.
+	instructions := ofp.Instructions{ofp.InstructionGotoTable{ofp.Table(m.tableNo)}}
+
+	// Insert flow into 0 table.
+	flowModGoto, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp.FlowMod{
+		Command:      ofp.FC_ADD,
+		BufferID:     ofp.NO_BUFFER,
+		Priority:     10,
+		Match:        match,
+		Instructions: instructions,
+	}))
+
+	if err != nil {
+		log.ErrorLog("ipv6_routing/ACTIVATE_HOOK",
+			"Failed to create ofp_flow_mod request: ", err)
+		return
+	}
+
+	err = of.Send(m.C.Switch.Conn(),
+		// Flush flows from table before using it.
+		ofputil.TableFlush(ofp.Table(m.tableNo)),
+		// Create black-hole rule for non-matching packets.
+		ofputil.FlowDrop(ofp.Table(m.tableNo)),
+		// Redirect all IPv6 packets to allocated table to process.
+		flowModGoto,
+	)
+
+	if err != nil {
+		log.ErrorLog("ipv6_routing/ACTIVATE_HOOK",
+			"Failed to send requests: ", err)
+	}
+}
+
+// Disable implements Mechanism interface.
+func (m *IPv6Routing) Disable() {
+	m.BaseRoutingMechanism.Disable()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+
+	// Match packets of IPv6 protocol.
+	match := ofp.Match{ofp.MT_OXM, []ofp.OXM{
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_TYPE, of.Bytes(iana.ETHT_IPV6), nil},
+	}}
+
+	// Neighbor discovery and echo flows of table 0 are
+	// removed by their mechanisms, so delete redirect only.
+	flowModGoto, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp.FlowMod{
+		Command:  ofp.FC_DELETE_STRICT,
+		Priority: 10,
+		BufferID: ofp.NO_BUFFER,
+		OutPort:  ofp.P_ANY,
+		OutGroup: ofp.G_ANY,
+		Match:    match,
+	}))
+
+	if err == nil {
+		err = of.Send(m.C.Switch.Conn(),
+			// Flush installed flows
+			ofputil.TableFlush(ofp.Table(m.tableNo)),
+			// Flush redirect flow
+			flowModGoto,
+		)
+	}
+
+	// Release acquired table
+	m.C.Switch.ReleaseTable(m.tableNo)
+
+	if err != nil {
+		log.ErrorLog("ipv6_routing/DISABLE_HOOK",
+			"Failed to send requests: ", err)
+	}
+
+	log.InfoLog("ipv6_routing/DISABLE_HOOK",
+		"IPv6 routing disabled")
+}
+
+// Deactivate implements Mechanism interface.
+func (m *IPv6Routing) Deactivate() {
+	m.BaseRoutingMechanism.Deactivate()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+
+	// Drop handlers of cookies of installed flows.
+	m.cookies = mech.NewCookieFilter()
+
+	// Release acquired table
+	m.C.Switch.ReleaseTable(m.tableNo)
+
+	log.InfoLog("ipv6_routing/DEACTIVATE_HOOK",
+		"IPv6 routing deactivated")
+}
+
+// OwnsFlow implements mech.FlowOwner interface.
+func (m *IPv6Routing) OwnsFlow(table int, cookie uint64) bool {
+	return table != 0 && table == m.tableNo ||
+		m.cookies.Contains(cookie)
+}
+
+func (m *IPv6Routing) UpdateRoute(context *mech.RoutingContext) error {
+	log.DebugLog("ipv6_routing/UPDATE_ROUTE",
+		"Got routing update route request")
+
+	if !isIPv6(context.Network) {
+		return nil
+	}
+
+	// Send all such packets to controller.
+	instruction := ofp.Instructions{ofp.InstructionActions{
+		ofp.IT_APPLY_ACTIONS, ofp.Actions{
+			ofp.ActionOutput{ofp.P_CONTROLLER, ofp.CML_NO_BUFFER},
+		},
+	}}
+
+	flowMod := ofp.FlowMod{
+		Command: ofp.FC_ADD,
+		TableID: ofp.Table(m.tableNo),
+		// Notify controller, when flow removed
+		Flags:    ofp.FF_SEND_FLOW_REM,
+		BufferID: ofp.NO_BUFFER,
+		// Longer prefixes take precedence in the switch as well.
+		Priority:     uint16(15 + context.Network.Mask().Len()),
+		Match:        routeMatch(context.Network),
+		Instructions: instruction,
+	}
+
+	// Move ip packets to ipPacketHandler
+	m.cookies.FilterFunc(&flowMod, m.ipPacketHandler)
+
+	// Update routing table with new address
+	err := m.routeTable.Populate(mechutil.RouteEntry{
+		Type:     context.Type,
+		Network:  context.Network,
+		NextHop:  context.NextHop,
+		Port:     context.Port,
+		Distance: context.Distance,
+		Metric:   context.Metric,
+	})
+
+	if err != nil {
+		log.ErrorLog("ipv6_routing/UPDATE_ROUTE",
+			"Failed to update routing table: ", err)
+		return err
+	}
+
+	r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
+	if err != nil {
+		log.ErrorLog("ipv6_routing/UPDATE_ROUTE",
+			"Failed to create new ofp_flow_mod request: ", err)
+		return err
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("ipv6_routing/UPDATE_ROUTE",
+			"Failed to send ofp_flow_mode request: ", err)
+	}
+
+	return err
+}
+
+func (m *IPv6Routing) DeleteRoute(context *mech.RoutingContext) error {
+	log.DebugLog("ipv6_routing/DELETE_ROUTE",
+		"Got delete route request")
+
+	if !isIPv6(context.Network) {
+		return nil
+	}
+
+	// Update routing table with new address
+	evicted := m.routeTable.Evict(mechutil.RouteEntry{
+		Type:    context.Type,
+		Network: context.Network,
+		NextHop: context.NextHop,
+		Port:    context.Port,
+	})
+
+	if !evicted {
+		log.ErrorLog("ipv6_routing/DELETE_ROUTE",
+			"Failed to delete specified route: ", context.Network)
+		return nil
+	}
+
+	err := of.Send(m.C.Switch.Conn(),
+		ofputil.FlowFlush(ofp.Table(m.tableNo), routeMatch(context.Network)),
+	)
+
+	if err != nil {
+		log.ErrorLog("ipv6_routing/DELETE_ROUTE",
+			"Failed to send requests: ", err)
+	}
+
+	return err
+}
+
+func (m *IPv6Routing) packetInHandler(rw of.ResponseWriter, r *of.Request) {
+	m.cookies.Serve(rw, r)
+}
+
+func (m *IPv6Routing) flowRemovedHandler(rw of.ResponseWriter, r *of.Request) {
+	var flowRemoved ofp.FlowRemoved
+
+	_, err := of.ReadAllFrom(r.Body, &flowRemoved)
+	if err != nil {
+		return
+	}
+
+	m.cookies.Release(&flowRemoved)
+}
+
+// ndp returns neighbor discovery mechanism of the switch.
+func (m *IPv6Routing) ndp() (*NDPMechanism, error) {
+	var network mech.NetworkMechanismManager
+	if err := m.C.Managers.Obtain(&network); err != nil {
+		log.ErrorLog("ipv6_routing/NDP",
+			"Failed to obtain network layer manager: ", err)
+		return nil, err
+	}
+
+	nmech, err := network.Mechanism(NDPMechanismName)
+	if err != nil {
+		log.ErrorLog("ipv6_routing/NDP",
+			"NDP network mechanism is not found: ", err)
+		return nil, err
+	}
+
+	ndpMech, ok := nmech.(*NDPMechanism)
+	if !ok {
+		log.ErrorLog("ipv6_routing/NDP",
+			"Failed to cast mechanism to NDP mechanism type")
+		return nil, mech.ErrMechanismNotRegistered
+	}
+
+	return ndpMech, nil
+}
+
+func (m *IPv6Routing) ipPacketHandler(rw of.ResponseWriter, r *of.Request) {
+	var packet ofp.PacketIn
+	var pdu2 mech.LinkFrame
+	var pdu3 mech.NetworkPacket
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv6DriverName)
+	if err != nil {
+		return
+	}
+
+	llreader := mech.MakeLinkReaderFrom(lldriver, &pdu2)
+	nlreader := mech.MakeNetworkReaderFrom(nldriver, &pdu3)
+
+	if _, err = of.ReadAllFrom(r.Body, &packet, llreader, nlreader); err != nil {
+		log.ErrorLog("ipv6_routing/IP_PACKET_HANDLER",
+			"Failed to read packet: ", err)
+		return
+	}
+
+	// Link-local and multicast packets are never routed.
+	dst := net.IP(pdu3.DstAddr.Bytes())
+	if dst.IsMulticast() || dst.IsLinkLocalUnicast() {
+		return
+	}
+
+	log.DebugLog("ipv6_routing/IP_PACKET_HANDLER",
+		"Got ip packet to: ", pdu3.DstAddr)
+
+	route, ok := m.routeTable.Lookup(pdu3.DstAddr)
+	if !ok {
+		log.DebugLog("ipv6_routing/IP_PACKET_HANDLER",
+			"Route not found: ", pdu3.DstAddr)
+		return
+	}
+
+	// Search for link layer address of egress port.
+	srcAddr, err := lldriver.Addr(route.Port)
+	if err != nil {
+		log.ErrorLog("ipv6_routing/IP_PACKET_HANDLER",
+			"Failed to retrieve port link layer address: ", err)
+		return
+	}
+
+	ndpMech, err := m.ndp()
+	if err != nil {
+		return
+	}
+
+	netwAddr := route.NextHop
+	if netwAddr == nil {
+		netwAddr = pdu3.DstAddr
+	}
+
+	dstAddr, err := ndpMech.Lookup(netwAddr, route.Port)
+	if err != nil {
+		log.ErrorLog("ipv6_routing/IP_PACKET_HANDLER",
+			"Failed to resolve link layer address: ", err)
+		return
+	}
+
+	log.DebugLog("ipv6_routing/IP_PACKET_HANDLER",
+		"Resolved link layer address: ", dstAddr)
+
+	// Create permanent rule for discovered address, packets
+	// of different VLANs require different tag operations.
+	match := ofp.Match{ofp.MT_OXM, []ofp.OXM{
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_TYPE, of.Bytes(iana.ETHT_IPV6), nil},
+		ofp13.VLANMatch(pdu2.VLAN),
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IPV6_DST, pdu3.DstAddr.Bytes(), nil},
+	}}
+
+	// Change source and destination link layer addresses
+	setDst := ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_DST, dstAddr.Bytes(), nil}
+	setSrc := ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_SRC, srcAddr.Bytes(), nil}
+
+	// Move packet from the VLAN of ingress interface
+	// to the VLAN of egress interface.
+	actions := ofp13.VLANActions(pdu2.VLAN, ndpMech.VLAN(route.Port))
+
+	// Decrement of network TTL decrements hop limit of IPv6 packets.
+	actions = append(actions,
+		ofp.ActionSetField{setDst},
+		ofp.ActionSetField{setSrc},
+		ofp.Action{ofp.AT_DEC_NW_TTL},
+		ofp.ActionOutput{ofp.PortNo(route.Port), 0},
+	)
+
+	instructions := ofp.Instructions{ofp.InstructionActions{
+		ofp.IT_APPLY_ACTIONS, actions,
+	}}
+
+	flowMod := ofp.FlowMod{
+		Command:      ofp.FC_ADD,
+		TableID:      ofp.Table(m.tableNo),
+		BufferID:     ofp.NO_BUFFER,
+		Priority:     200,
+		Match:        match,
+		Instructions: instructions,
+	}
+
+	_, err = of.WriteAllTo(rw, &flowMod)
+	if err != nil {
+		log.ErrorLog("ipv6_routing/IP_PACKET_HANDLER",
+			"Failed to write response: ", err)
+		return
+	}
+
+	rw.Header().Set(of.TypeHeaderKey, of.T_FLOW_MOD)
+	rw.Header().Set(of.VersionHeaderKey, ofp.VERSION)
+	if err = rw.WriteHeader(); err != nil {
+		log.ErrorLog("ipv6_routing/IP_PACKET_HANDLER",
+			"Failed to send ofp_flow_mod response: ", err)
+	}
+}
@@ -0,0 +1,14 @@
+package ip6
+
+import (
+	"testing"
+)
+
+func TestIPv6UpdateRoute(t *testing.T) {
+}
+
+func TestIPv6DeleteRoute(t *testing.T) {
+}
+
+func TestIPv6PacketHandler(t *testing.T) {
+}
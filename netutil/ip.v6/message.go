@@ -0,0 +1,421 @@
+package ip6
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/netrack/net/iana"
+)
+
+// ICMPv6Type is a type of ICMPv6 message.
+type ICMPv6Type uint8
+
+const (
+	ICMPv6EchoRequest           ICMPv6Type = 128
+	ICMPv6EchoReply             ICMPv6Type = 129
+	ICMPv6RouterSolicitation    ICMPv6Type = 133
+	ICMPv6RouterAdvertisement   ICMPv6Type = 134
+	ICMPv6NeighborSolicitation  ICMPv6Type = 135
+	ICMPv6NeighborAdvertisement ICMPv6Type = 136
+)
+
+// ICMPv6HeaderLen is a length of ICMPv6 message header.
+const ICMPv6HeaderLen = 4
+
+// Types of neighbor discovery options.
+const (
+	ndpOptSrcLinkAddr   = 1
+	ndpOptDstLinkAddr   = 2
+	ndpOptPrefixInfo    = 3
+	ndpOptMTU           = 5
+	ndpOptPrefixInfoLen = 32
+)
+
+var (
+	// ErrICMPv6Format is returned on malformed ICMPv6 messages.
+	ErrICMPv6Format = errors.New("icmpv6: malformed message")
+
+	// ErrICMPv6Checksum is returned on messages with invalid checksum.
+	ErrICMPv6Checksum = errors.New("icmpv6: invalid checksum")
+)
+
+var (
+	// AllNodes is a link-local all-nodes multicast address.
+	AllNodes = net.ParseIP("ff02::1")
+
+	// AllRouters is a link-local all-routers multicast address.
+	AllRouters = net.ParseIP("ff02::2")
+)
+
+// ICMPv6 is an ICMPv6 message, body of the message
+// depends on the message type.
+type ICMPv6 struct {
+	Type     ICMPv6Type
+	Code     uint8
+	Checksum uint16
+	Body     []byte
+}
+
+// ReadICMPv6 reads ICMPv6 message of the specified length.
+func ReadICMPv6(r io.Reader, n int64) (*ICMPv6, error) {
+	if n < ICMPv6HeaderLen {
+		return nil, ErrICMPv6Format
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+
+	return &ICMPv6{
+		Type:     ICMPv6Type(b[0]),
+		Code:     b[1],
+		Checksum: binary.BigEndian.Uint16(b[2:4]),
+		Body:     b[ICMPv6HeaderLen:],
+	}, nil
+}
+
+// Marshal returns binary form of the message with the checksum
+// calculated for the specified source and destination addresses.
+func (m *ICMPv6) Marshal(src, dst net.IP) []byte {
+	b := make([]byte, ICMPv6HeaderLen+len(m.Body))
+
+	b[0] = uint8(m.Type)
+	b[1] = m.Code
+	copy(b[ICMPv6HeaderLen:], m.Body)
+
+	m.Checksum = checksum(src, dst, b)
+	binary.BigEndian.PutUint16(b[2:4], m.Checksum)
+
+	return b
+}
+
+// checksum returns ICMPv6 checksum of the message, including
+// IPv6 pseudo-header. Checksum field of the message must be zero.
+func checksum(src, dst net.IP, b []byte) uint16 {
+	var sum uint32
+
+	add := func(b []byte) {
+		for i := 0; i+1 < len(b); i += 2 {
+			sum += uint32(b[i])<<8 | uint32(b[i+1])
+		}
+
+		if len(b)%2 != 0 {
+			sum += uint32(b[len(b)-1]) << 8
+		}
+	}
+
+	add(src.To16())
+	add(dst.To16())
+
+	sum += uint32(len(b))
+	sum += uint32(iana.IP_PROTO_IPV6_ICMP)
+
+	add(b)
+
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+
+	return ^uint16(sum)
+}
+
+// VerifyChecksum reports whether checksum of the message
+// received from the source to destination is correct.
+func (m *ICMPv6) VerifyChecksum(src, dst net.IP) bool {
+	received := m.Checksum
+	computed := (&ICMPv6{Type: m.Type, Code: m.Code, Body: m.Body}).Marshal(src, dst)
+
+	return binary.BigEndian.Uint16(computed[2:4]) == received
+}
+
+// ndpOption is a neighbor discovery option.
+type ndpOption struct {
+	Type uint8
+	Data []byte
+}
+
+// readOptions returns list of neighbor discovery options.
+func readOptions(b []byte) ([]ndpOption, error) {
+	var options []ndpOption
+
+	for len(b) > 0 {
+		if len(b) < 2 || b[1] == 0 || len(b) < int(b[1])*8 {
+			return nil, ErrICMPv6Format
+		}
+
+		n := int(b[1]) * 8
+		options = append(options, ndpOption{b[0], b[2:n]})
+		b = b[n:]
+	}
+
+	return options, nil
+}
+
+// writeOption appends option padded to 8 octets boundary.
+func writeOption(b []byte, t uint8, data []byte) []byte {
+	n := (len(data) + 2 + 7) / 8 * 8
+
+	option := make([]byte, n)
+	option[0], option[1] = t, uint8(n/8)
+	copy(option[2:], data)
+
+	return append(b, option...)
+}
+
+// linkAddrOption returns link layer address of the options.
+func linkAddrOption(options []ndpOption, t uint8) []byte {
+	for _, option := range options {
+		if option.Type == t && len(option.Data) >= 6 {
+			return option.Data[:6]
+		}
+	}
+
+	return nil
+}
+
+// NeighborSolicitation is a neighbor solicitation message.
+type NeighborSolicitation struct {
+	// Target address of the solicitation.
+	Target net.IP
+
+	// Link layer address of the sender, it
+	// could be empty for duplicate address detection.
+	LinkAddr []byte
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler interface.
+func (m *NeighborSolicitation) UnmarshalBinary(b []byte) error {
+	if len(b) < 20 {
+		return ErrICMPv6Format
+	}
+
+	options, err := readOptions(b[20:])
+	if err != nil {
+		return err
+	}
+
+	m.Target = net.IP(append([]byte(nil), b[4:20]...))
+	m.LinkAddr = linkAddrOption(options, ndpOptSrcLinkAddr)
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler interface.
+func (m *NeighborSolicitation) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 20)
+	copy(b[4:], m.Target.To16())
+
+	if len(m.LinkAddr) != 0 {
+		b = writeOption(b, ndpOptSrcLinkAddr, m.LinkAddr)
+	}
+
+	return b, nil
+}
+
+// NeighborAdvertisement is a neighbor advertisement message.
+type NeighborAdvertisement struct {
+	// Sender is a router.
+	Router bool
+
+	// Advertisement is sent in response to solicitation.
+	Solicited bool
+
+	// Advertisement should override existing cache entry.
+	Override bool
+
+	// Target address of the advertisement.
+	Target net.IP
+
+	// Link layer address of the target.
+	LinkAddr []byte
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler interface.
+func (m *NeighborAdvertisement) UnmarshalBinary(b []byte) error {
+	if len(b) < 20 {
+		return ErrICMPv6Format
+	}
+
+	options, err := readOptions(b[20:])
+	if err != nil {
+		return err
+	}
+
+	m.Router = b[0]&0x80 != 0
+	m.Solicited = b[0]&0x40 != 0
+	m.Override = b[0]&0x20 != 0
+	m.Target = net.IP(append([]byte(nil), b[4:20]...))
+	m.LinkAddr = linkAddrOption(options, ndpOptDstLinkAddr)
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler interface.
+func (m *NeighborAdvertisement) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 20)
+
+	if m.Router {
+		b[0] |= 0x80
+	}
+
+	if m.Solicited {
+		b[0] |= 0x40
+	}
+
+	if m.Override {
+		b[0] |= 0x20
+	}
+
+	copy(b[4:], m.Target.To16())
+
+	if len(m.LinkAddr) != 0 {
+		b = writeOption(b, ndpOptDstLinkAddr, m.LinkAddr)
+	}
+
+	return b, nil
+}
+
+// RouterSolicitation is a router solicitation message.
+type RouterSolicitation struct {
+	// Link layer address of the sender.
+	LinkAddr []byte
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler interface.
+func (m *RouterSolicitation) UnmarshalBinary(b []byte) error {
+	if len(b) < 4 {
+		return ErrICMPv6Format
+	}
+
+	options, err := readOptions(b[4:])
+	if err != nil {
+		return err
+	}
+
+	m.LinkAddr = linkAddrOption(options, ndpOptSrcLinkAddr)
+	return nil
+}
+
+// PrefixInfo is an on-link prefix advertised by router.
+type PrefixInfo struct {
+	// Network prefix.
+	Prefix net.IPNet
+
+	// Prefix could be used for on-link determination.
+	OnLink bool
+
+	// Prefix could be used for stateless address configuration.
+	Autonomous bool
+
+	// Lifetimes of the prefix in seconds.
+	ValidLifetime     uint32
+	PreferredLifetime uint32
+}
+
+// RouterAdvertisement is a router advertisement message.
+type RouterAdvertisement struct {
+	// Default hop limit of the hosts.
+	HopLimit uint8
+
+	// Addresses are available through DHCPv6.
+	Managed bool
+
+	// Other configuration is available through DHCPv6.
+	Other bool
+
+	// Lifetime of the default router in seconds.
+	Lifetime uint16
+
+	// Timers of neighbor unreachability detection in milliseconds.
+	ReachableTime uint32
+	RetransTimer  uint32
+
+	// Link layer address of the router.
+	LinkAddr []byte
+
+	// Link MTU, zero when not advertised.
+	MTU uint32
+
+	// On-link prefixes.
+	Prefixes []PrefixInfo
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler interface.
+func (m *RouterAdvertisement) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 12)
+
+	b[0] = m.HopLimit
+	if m.Managed {
+		b[1] |= 0x80
+	}
+
+	if m.Other {
+		b[1] |= 0x40
+	}
+
+	binary.BigEndian.PutUint16(b[2:4], m.Lifetime)
+	binary.BigEndian.PutUint32(b[4:8], m.ReachableTime)
+	binary.BigEndian.PutUint32(b[8:12], m.RetransTimer)
+
+	if len(m.LinkAddr) != 0 {
+		b = writeOption(b, ndpOptSrcLinkAddr, m.LinkAddr)
+	}
+
+	if m.MTU != 0 {
+		mtu := make([]byte, 6)
+		binary.BigEndian.PutUint32(mtu[2:], m.MTU)
+		b = writeOption(b, ndpOptMTU, mtu)
+	}
+
+	for _, prefix := range m.Prefixes {
+		info := make([]byte, ndpOptPrefixInfoLen-2)
+
+		ones, _ := prefix.Prefix.Mask.Size()
+		info[0] = uint8(ones)
+
+		if prefix.OnLink {
+			info[1] |= 0x80
+		}
+
+		if prefix.Autonomous {
+			info[1] |= 0x40
+		}
+
+		binary.BigEndian.PutUint32(info[2:6], prefix.ValidLifetime)
+		binary.BigEndian.PutUint32(info[6:10], prefix.PreferredLifetime)
+		copy(info[14:], prefix.Prefix.IP.Mask(prefix.Prefix.Mask).To16())
+
+		b = writeOption(b, ndpOptPrefixInfo, info)
+	}
+
+	return b, nil
+}
+
+// SolicitedNode returns solicited-node multicast address of the address.
+func SolicitedNode(ip net.IP) net.IP {
+	addr := net.ParseIP("ff02::1:ff00:0")
+	copy(addr[13:], ip.To16()[13:])
+	return addr
+}
+
+// MulticastLinkAddr returns ethernet address of the IPv6 multicast group.
+func MulticastLinkAddr(ip net.IP) []byte {
+	return append([]byte{0x33, 0x33}, ip.To16()[12:]...)
+}
+
+// LinkLocal returns link-local address of the interface with
+// the ethernet address, interface identifier is formed as EUI-64.
+func LinkLocal(hwaddr []byte) net.IP {
+	ip := net.ParseIP("fe80::")
+	if len(hwaddr) != 6 {
+		return ip
+	}
+
+	copy(ip[8:11], hwaddr[:3])
+	ip[8] ^= 0x02
+	ip[11], ip[12] = 0xff, 0xfe
+	copy(ip[13:], hwaddr[3:])
+
+	return ip
+}
@@ -0,0 +1,132 @@
+package ip6
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestICMPv6Checksum(t *testing.T) {
+	src := net.ParseIP("fe80::1")
+	dst := net.ParseIP("fe80::2")
+
+	echo := ICMPv6{Type: ICMPv6EchoRequest, Body: []byte{0, 1, 0, 1, 'p', 'i', 'n', 'g'}}
+	b := echo.Marshal(src, dst)
+
+	msg, err := ReadICMPv6(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal("Failed to read ICMPv6 message:", err)
+	}
+
+	if msg.Type != ICMPv6EchoRequest || !bytes.Equal(msg.Body, echo.Body) {
+		t.Fatal("Invalid read ICMPv6 message:", msg)
+	}
+
+	if !msg.VerifyChecksum(src, dst) {
+		t.Fatal("Checksum of the message must be valid")
+	}
+
+	if msg.VerifyChecksum(src, net.ParseIP("fe80::3")) {
+		t.Fatal("Checksum must depend on pseudo-header")
+	}
+}
+
+func TestNeighborSolicitation(t *testing.T) {
+	ns := NeighborSolicitation{
+		Target:   net.ParseIP("2001:db8::1"),
+		LinkAddr: []byte{0x02, 0, 0, 0, 0, 1},
+	}
+
+	b, err := ns.MarshalBinary()
+	if err != nil || len(b) != 28 {
+		t.Fatal("Failed to marshal neighbor solicitation:", b, err)
+	}
+
+	var decoded NeighborSolicitation
+	if err = decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal("Failed to unmarshal neighbor solicitation:", err)
+	}
+
+	if !decoded.Target.Equal(ns.Target) || !bytes.Equal(decoded.LinkAddr, ns.LinkAddr) {
+		t.Fatal("Invalid unmarshaled neighbor solicitation:", decoded)
+	}
+
+	if err = decoded.UnmarshalBinary(append(b, 1, 0)); err != ErrICMPv6Format {
+		t.Fatal("Malformed option must be rejected:", err)
+	}
+}
+
+func TestNeighborAdvertisement(t *testing.T) {
+	na := NeighborAdvertisement{
+		Router:    true,
+		Solicited: true,
+		Target:    net.ParseIP("2001:db8::1"),
+		LinkAddr:  []byte{0x02, 0, 0, 0, 0, 1},
+	}
+
+	b, err := na.MarshalBinary()
+	if err != nil {
+		t.Fatal("Failed to marshal neighbor advertisement:", err)
+	}
+
+	var decoded NeighborAdvertisement
+	if err = decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal("Failed to unmarshal neighbor advertisement:", err)
+	}
+
+	if !decoded.Router || !decoded.Solicited || decoded.Override {
+		t.Fatal("Invalid flags of neighbor advertisement:", decoded)
+	}
+
+	if !decoded.Target.Equal(na.Target) || !bytes.Equal(decoded.LinkAddr, na.LinkAddr) {
+		t.Fatal("Invalid unmarshaled neighbor advertisement:", decoded)
+	}
+}
+
+func TestRouterAdvertisement(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR("2001:db8:1::/64")
+
+	ra := RouterAdvertisement{
+		HopLimit: 64,
+		Lifetime: RouterLifetime,
+		LinkAddr: []byte{0x02, 0, 0, 0, 0, 1},
+		Prefixes: []PrefixInfo{{Prefix: *prefix, OnLink: true, Autonomous: true}},
+	}
+
+	b, err := ra.MarshalBinary()
+	if err != nil {
+		t.Fatal("Failed to marshal router advertisement:", err)
+	}
+
+	options, err := readOptions(b[12:])
+	if err != nil || len(options) != 2 {
+		t.Fatal("Invalid options of router advertisement:", options, err)
+	}
+
+	info := options[1]
+	if info.Type != ndpOptPrefixInfo || len(info.Data) != ndpOptPrefixInfoLen-2 {
+		t.Fatal("Invalid prefix information option:", info)
+	}
+
+	if info.Data[0] != 64 || info.Data[1] != 0xc0 || !net.IP(info.Data[14:]).Equal(prefix.IP) {
+		t.Fatal("Invalid advertised prefix:", info.Data)
+	}
+}
+
+func TestMulticastAddrs(t *testing.T) {
+	addr := net.ParseIP("2001:db8::12:3456")
+
+	if group := SolicitedNode(addr); !group.Equal(net.ParseIP("ff02::1:ff12:3456")) {
+		t.Fatal("Invalid solicited-node address:", group)
+	}
+
+	lladdr := MulticastLinkAddr(SolicitedNode(addr))
+	if !bytes.Equal(lladdr, []byte{0x33, 0x33, 0xff, 0x12, 0x34, 0x56}) {
+		t.Fatal("Invalid multicast link layer address:", lladdr)
+	}
+
+	linkLocal := LinkLocal([]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55})
+	if !linkLocal.Equal(net.ParseIP("fe80::211:22ff:fe33:4455")) {
+		t.Fatal("Invalid link-local address:", linkLocal)
+	}
+}
@@ -0,0 +1,878 @@
+package ip6
+
+import (
+	"bytes"
+	"net"
+	"sync"
+
+	"github.com/netrack/net/iana"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/mechanism/mechutil"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ofp.v13"
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+	"github.com/netrack/openflow/ofp.v13/ofputil"
+)
+
+const NDPMechanismName = "ndp"
+
+const (
+	// RouterLifetime is a lifetime of the default router in seconds.
+	RouterLifetime = 1800
+
+	// PrefixValidLifetime is a valid lifetime of advertised prefixes.
+	PrefixValidLifetime = 2592000
+
+	// PrefixPreferredLifetime is a preferred lifetime of advertised prefixes.
+	PrefixPreferredLifetime = 604800
+
+	// autoconfPrefixLen is a length of prefixes, that are
+	// suitable for stateless address autoconfiguration.
+	autoconfPrefixLen = 64
+)
+
+func init() {
+	constructor := mech.NetworkMechanismConstructorFunc(NewNDPMechanism)
+	mech.RegisterNetworkMechanism(NDPMechanismName, constructor)
+}
+
+// isIPv6 reports whether address belongs to IPv6 network, addresses
+// of other network protocols are handled by other mechanisms.
+func isIPv6(addr mech.NetworkAddr) bool {
+	return len(addr.Bytes()) == net.IPv6len
+}
+
+// icmpv6Match matches ICMPv6 messages of the specified type.
+func icmpv6Match(t ICMPv6Type, fields ...ofp.OXM) ofp.Match {
+	oxm := []ofp.OXM{
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_TYPE, of.Bytes(iana.ETHT_IPV6), nil},
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IP_PROTO, of.Bytes(iana.IP_PROTO_IPV6_ICMP), nil},
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ICMPV6_TYPE, of.Bytes(uint8(t)), nil},
+	}
+
+	return ofp.Match{ofp.MT_OXM, append(oxm, fields...)}
+}
+
+// ndTargetMatch matches neighbor solicitations of the address.
+func ndTargetMatch(addr net.IP, fields ...ofp.OXM) ofp.Match {
+	target := ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IPV6_ND_TARGET, []byte(addr.To16()), nil}
+	return icmpv6Match(ICMPv6NeighborSolicitation, append([]ofp.OXM{target}, fields...)...)
+}
+
+// ndpTypes are types of neighbor discovery messages handled by mechanism.
+var ndpTypes = []ICMPv6Type{
+	ICMPv6RouterSolicitation,
+	ICMPv6NeighborSolicitation,
+	ICMPv6NeighborAdvertisement,
+}
+
+// NDPMechanism implements IPv6 neighbor discovery for the networks,
+// associated with switch ports. It answers neighbor solicitations
+// and router solicitations, resolves link layer addresses of
+// neighbors and advertises prefixes of the networks.
+type NDPMechanism struct {
+	mech.BaseNetworkMechanism
+
+	// Handle request based on cookie value.
+	cookies *mech.CookieFilter
+
+	// Stores registered handlers to delete them later.
+	filter *of.ServeFilter
+
+	// Neighbor cache
+	neighTable *mechutil.NeighTable
+
+	// Table number allocated for the mechanism.
+	tableNo int
+
+	// VLAN identifiers of interfaces of switch ports.
+	vlans     map[uint32]uint16
+	vlansLock sync.RWMutex
+
+	// Waiters of neighbor advertisements, error is sent
+	// to waiters, when request could not be resolved.
+	requests map[string][]chan error
+	lock     sync.Mutex
+}
+
+func NewNDPMechanism() mech.NetworkMechanism {
+	return &NDPMechanism{
+		filter:     of.NewServeFilter(),
+		cookies:    mech.NewCookieFilter(),
+		requests:   make(map[string][]chan error),
+		neighTable: mechutil.NewNeighTable(),
+		vlans:      make(map[uint32]uint16),
+	}
+}
+
+// VLAN returns VLAN identifier of the interface
+// of the port, zero is returned for untagged interface.
+func (m *NDPMechanism) VLAN(port uint32) uint16 {
+	m.vlansLock.RLock()
+	defer m.vlansLock.RUnlock()
+
+	return m.vlans[port]
+}
+
+func (m *NDPMechanism) setVLAN(port uint32, vlan uint16) {
+	m.vlansLock.Lock()
+	defer m.vlansLock.Unlock()
+
+	if vlan == 0 {
+		delete(m.vlans, port)
+		return
+	}
+
+	m.vlans[port] = vlan
+}
+
+func (m *NDPMechanism) createRequest(nladdr mech.NetworkAddr) <-chan error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	log.DebugLog("ndp/CREATE_REQUEST",
+		"Create request for: ", nladdr)
+
+	waitCh := make(chan error)
+	m.requests[nladdr.String()] = append(m.requests[nladdr.String()], waitCh)
+
+	return waitCh
+}
+
+func (m *NDPMechanism) releaseRequest(nladdr mech.NetworkAddr) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	log.DebugLog("ndp/RELEASE_REQUEST",
+		"Release requests for: ", nladdr)
+
+	// Broadcast response to waiters
+	for _, channel := range m.requests[nladdr.String()] {
+		// To prevent enclosing of the variable
+		ch := channel
+
+		go func() {
+			defer close(ch)
+			ch <- nil
+		}()
+	}
+
+	delete(m.requests, nladdr.String())
+}
+
+// releaseRequests sends specified error to all waiters.
+func (m *NDPMechanism) releaseRequests(err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	log.DebugLog("ndp/RELEASE_REQUESTS",
+		"Release all requests with error: ", err)
+
+	for _, channels := range m.requests {
+		for _, channel := range channels {
+			// To prevent enclosing of the variable
+			ch := channel
+
+			go func() {
+				defer close(ch)
+				ch <- err
+			}()
+		}
+	}
+
+	m.requests = make(map[string][]chan error)
+}
+
+func (m *NDPMechanism) Name() string {
+	return NDPMechanismName
+}
+
+func (m *NDPMechanism) Description() string {
+	return "IPv6 neighbor discovery"
+}
+
+// Version implements VersionedMechanism interface.
+func (m *NDPMechanism) Version() string {
+	return ofp13.ProtoVersion
+}
+
+// Enable implements Mechanism interface
+func (m *NDPMechanism) Enable(c *mech.MechanismContext) {
+	m.BaseMechanism.Enable(c)
+
+	m.filter.Mux = m.C.Mux
+	// Handle incoming neighbor discovery messages.
+	m.filter.HandleFunc(of.T_PACKET_IN, m.packetInHandler)
+	// Handle removed flows notifications
+	m.filter.HandleFunc(of.T_FLOW_REMOVED, m.flowRemovedHandler)
+
+	log.InfoLog("ndp/ENABLE_HOOK", "Mechanism NDP enabled")
+}
+
+// Activate implements Mechanism interface
+func (m *NDPMechanism) Activate() {
+	m.BaseMechanism.Activate()
+
+	// Operate on PacketIn messages
+	m.cookies.Baker = ofputil.PacketInBaker()
+
+	// Allocate table for handling neighbor discovery protocol.
+	tableNo, err := m.C.Switch.AllocateTable()
+	if err != nil {
+		log.ErrorLog("ndp/ACTIVATE_HOOK",
+			"Failed to allocate a new table: ", err)
+		return
+	}
+
+	m.tableNo = tableNo
+
+	log.DebugLog("ndp/ACTIVATE_HOOK",
+		"Allocated table: ", tableNo)
+
+	requests := []*of.Request{
+		// Flush flows from table before using it.
+		ofputil.TableFlush(ofp.Table(m.tableNo)),
+		// Create black-hole rule for non-matching packets.
+		ofputil.FlowDrop(ofp.Table(m.tableNo)),
+	}
+
+	// Move neighbor discovery messages to allocated table,
+	// so they will not be forwarded by IPv6 routing.
+	for _, t := range ndpTypes {
+		flowModGoto, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp.FlowMod{
+			Command:      ofp.FC_ADD,
+			BufferID:     ofp.NO_BUFFER,
+			Priority:     20,
+			Match:        icmpv6Match(t),
+			Instructions: ofp.Instructions{ofp.InstructionGotoTable{ofp.Table(m.tableNo)}},
+		}))
+
+		if err != nil {
+			log.ErrorLog("ndp/ACTIVATE_HOOK",
+				"Failed to create ofp_flow_mod request: ", err)
+			return
+		}
+
+		requests = append(requests, flowModGoto)
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), requests...); err != nil {
+		log.ErrorLog("ndp/ACTIVATE_HOOK",
+			"Failed to send requests: ", err)
+	}
+}
+
+// Disable implements Mechanism interface.
+func (m *NDPMechanism) Disable() {
+	m.BaseMechanism.Disable()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+
+	// Nobody will answer pending requests.
+	m.releaseRequests(mech.ErrSwitchDisconnected)
+
+	requests := []*of.Request{
+		// Flush installed flows
+		ofputil.TableFlush(ofp.Table(m.tableNo)),
+	}
+
+	// Flush redirect flows
+	for _, t := range ndpTypes {
+		requests = append(requests, ofputil.FlowFlush(0, icmpv6Match(t)))
+	}
+
+	err := of.Send(m.C.Switch.Conn(), requests...)
+
+	// Release acquired table
+	m.C.Switch.ReleaseTable(m.tableNo)
+
+	if err != nil {
+		log.ErrorLog("ndp/DISABLE_HOOK",
+			"Failed to send requests: ", err)
+	}
+
+	log.InfoLog("ndp/DISABLE_HOOK", "Mechanism NDP disabled")
+}
+
+// Deactivate implements Mechanism interface.
+func (m *NDPMechanism) Deactivate() {
+	m.BaseMechanism.Deactivate()
+
+	// Remove installed handlers
+	m.filter.Unhandle()
+
+	// Unblock goroutines waiting for neighbor advertisements.
+	m.releaseRequests(mech.ErrSwitchDisconnected)
+
+	// Drop handlers of cookies of installed flows.
+	m.cookies = mech.NewCookieFilter()
+
+	// Release acquired table
+	m.C.Switch.ReleaseTable(m.tableNo)
+
+	log.InfoLog("ndp/DEACTIVATE_HOOK", "Mechanism NDP deactivated")
+}
+
+// OwnsFlow implements mech.FlowOwner interface.
+func (m *NDPMechanism) OwnsFlow(table int, cookie uint64) bool {
+	return table != 0 && table == m.tableNo ||
+		m.cookies.Contains(cookie)
+}
+
+func (m *NDPMechanism) CreateNetworkPreCommit(context *mech.NetworkContext) error {
+	return m.UpdateNetworkPostCommit(context)
+}
+
+func (m *NDPMechanism) UpdateNetworkPreCommit(context *mech.NetworkContext) error {
+	return m.DeleteNetworkPreCommit(context)
+}
+
+// redirect creates request to send matching messages to the controller.
+func (m *NDPMechanism) redirect(match ofp.Match, priority uint16, handler func(of.ResponseWriter, *of.Request)) (*of.Request, error) {
+	instructions := ofp.Instructions{ofp.InstructionActions{
+		ofp.IT_APPLY_ACTIONS, ofp.Actions{
+			ofp.ActionOutput{ofp.P_CONTROLLER, ofp.CML_NO_BUFFER},
+		},
+	}}
+
+	flowMod := ofp.FlowMod{
+		Command: ofp.FC_ADD,
+		TableID: ofp.Table(m.tableNo),
+		// Notify controller, when flow removed
+		Flags:        ofp.FF_SEND_FLOW_REM,
+		BufferID:     ofp.NO_BUFFER,
+		Priority:     priority,
+		Match:        match,
+		Instructions: instructions,
+	}
+
+	m.cookies.FilterFunc(&flowMod, handler)
+
+	return of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
+}
+
+func (m *NDPMechanism) UpdateNetworkPostCommit(context *mech.NetworkContext) error {
+	log.DebugLog("ndp/UPDATE_NETWORK_POSTCOMMIT",
+		"Got update network request")
+
+	if !isIPv6(context.NetworkAddr) {
+		return nil
+	}
+
+	// Sub-interfaces of trunk ports receive tagged frames.
+	m.setVLAN(context.Port, context.VLAN)
+
+	vlan := ofp13.VLANMatch(context.VLAN)
+	inPort := ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IN_PORT, of.Bytes(ofp.PortNo(context.Port)), nil}
+	ethDst := ofputil.EthDstAddr(context.LinkAddr.Bytes(), nil)
+
+	var requests []*of.Request
+
+	// Solicitations of both global and link-local addresses of the interface.
+	for _, addr := range []net.IP{context.NetworkAddr.Bytes(), LinkLocal(context.LinkAddr.Bytes())} {
+		r, err := m.redirect(ndTargetMatch(addr, vlan), 2, m.solicitationHandler)
+		if err != nil {
+			log.ErrorLog("ndp/UPDATE_NETWORK_POSTCOMMIT",
+				"Failed to create ofp_flow_mod request: ", err)
+			return err
+		}
+
+		requests = append(requests, r)
+	}
+
+	// Advertisements in reply to solicitations of the controller.
+	r, err := m.redirect(icmpv6Match(ICMPv6NeighborAdvertisement, vlan, ethDst), 3, m.advertisementHandler)
+	if err != nil {
+		log.ErrorLog("ndp/UPDATE_NETWORK_POSTCOMMIT",
+			"Failed to create ofp_flow_mod request: ", err)
+		return err
+	}
+
+	requests = append(requests, r)
+
+	// Router solicitations of the hosts connected to the port.
+	r, err = m.redirect(icmpv6Match(ICMPv6RouterSolicitation, inPort, vlan), 2, m.routerSolicitationHandler)
+	if err != nil {
+		log.ErrorLog("ndp/UPDATE_NETWORK_POSTCOMMIT",
+			"Failed to create ofp_flow_mod request: ", err)
+		return err
+	}
+
+	requests = append(requests, r)
+
+	if err = of.Send(m.C.Switch.Conn(), requests...); err != nil {
+		log.ErrorLog("ndp/UPDATE_NETWORK_POSTCOMMIT",
+			"Failed to send requests: ", err)
+		return err
+	}
+
+	// Let hosts configure addresses without waiting for solicitation.
+	nladdrs := context.NetworkDriver.Addrs(context.Port)
+	return m.advertise(context.Port, nladdrs, context.LinkAddr, AllNodes, MulticastLinkAddr(AllNodes))
+}
+
+func (m *NDPMechanism) DeleteNetworkPreCommit(context *mech.NetworkContext) error {
+	log.DebugLog("ndp/DELETE_NETWORK_PRECOMMIT",
+		"Got delete network request")
+
+	if !isIPv6(context.NetworkAddr) {
+		return nil
+	}
+
+	requests := []*of.Request{
+		ofputil.FlowFlush(ofp.Table(m.tableNo), ndTargetMatch(context.NetworkAddr.Bytes())),
+	}
+
+	// Flows of the interface itself are kept, while
+	// other IPv6 addresses are assigned to the port.
+	if !context.Shared() {
+		m.setVLAN(context.Port, 0)
+
+		inPort := ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IN_PORT, of.Bytes(ofp.PortNo(context.Port)), nil}
+		ethDst := ofputil.EthDstAddr(context.LinkAddr.Bytes(), nil)
+
+		requests = append(requests,
+			ofputil.FlowFlush(ofp.Table(m.tableNo), ndTargetMatch(LinkLocal(context.LinkAddr.Bytes()))),
+			ofputil.FlowFlush(ofp.Table(m.tableNo), icmpv6Match(ICMPv6NeighborAdvertisement, ethDst)),
+			ofputil.FlowFlush(ofp.Table(m.tableNo), icmpv6Match(ICMPv6RouterSolicitation, inPort)),
+		)
+	}
+
+	err := of.Send(m.C.Switch.Conn(), requests...)
+
+	if err != nil {
+		log.ErrorLog("ndp/DELETE_NETWORK_PRECOMMIT",
+			"Failed to remove installed NDP flows: ", err)
+	}
+
+	return err
+}
+
+func (m *NDPMechanism) packetInHandler(rw of.ResponseWriter, r *of.Request) {
+	// Serve message based on PacketIn cookies.
+	m.cookies.Serve(rw, r)
+}
+
+func (m *NDPMechanism) flowRemovedHandler(rw of.ResponseWriter, r *of.Request) {
+	var flowRemoved ofp.FlowRemoved
+
+	_, err := of.ReadAllFrom(r.Body, &flowRemoved)
+	if err != nil {
+		return
+	}
+
+	m.cookies.Release(&flowRemoved)
+}
+
+// readMessage reads ICMPv6 message of the packet and verifies its checksum.
+func (m *NDPMechanism) readMessage(r *of.Request) (*ofp.PacketIn, *mech.LinkFrame, *mech.NetworkPacket, *ICMPv6, error) {
+	var packet ofp.PacketIn
+	var pdu2 mech.LinkFrame
+	var pdu3 mech.NetworkPacket
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv6DriverName)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	llreader := mech.MakeLinkReaderFrom(lldriver, &pdu2)
+	nlreader := mech.MakeNetworkReaderFrom(nldriver, &pdu3)
+
+	if _, err = of.ReadAllFrom(r.Body, &packet, llreader, nlreader); err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	icmp, err := ReadICMPv6(r.Body, pdu3.ContentLen)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	if !icmp.VerifyChecksum(pdu3.SrcAddr.Bytes(), pdu3.DstAddr.Bytes()) {
+		return nil, nil, nil, nil, ErrICMPv6Checksum
+	}
+
+	return &packet, &pdu2, &pdu3, icmp, nil
+}
+
+// writeMessage writes ICMPv6 message through the ingress port of the packet.
+func (m *NDPMechanism) writeMessage(rw of.ResponseWriter, packet *ofp.PacketIn, pdu2 *mech.LinkFrame, src, dst net.IP, icmp *ICMPv6) error {
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return err
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv6DriverName)
+	if err != nil {
+		return err
+	}
+
+	pdu3 := mech.NetworkPacket{
+		DstAddr: nldriver.CreateAddr(dst, nil),
+		SrcAddr: nldriver.CreateAddr(src, nil),
+		Proto:   mech.Proto(iana.IP_PROTO_IPV6_ICMP),
+		Payload: bytes.NewReader(icmp.Marshal(src, dst)),
+	}
+
+	packetOut := ofp.PacketOut{BufferID: ofp.NO_BUFFER,
+		InPort:  packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.PortNo(),
+		Actions: ofp.Actions{ofp.ActionOutput{ofp.P_IN_PORT, 0}},
+	}
+
+	llwriter := mech.MakeLinkWriterTo(lldriver, pdu2)
+	nlwriter := mech.MakeNetworkWriterTo(nldriver, &pdu3)
+
+	if _, err = of.WriteAllTo(rw, &packetOut, llwriter, nlwriter); err != nil {
+		return err
+	}
+
+	rw.Header().Set(of.TypeHeaderKey, of.T_PACKET_OUT)
+	rw.Header().Set(of.VersionHeaderKey, ofp.VERSION)
+	return rw.WriteHeader()
+}
+
+// learn updates neighbor cache with link layer address of the neighbor.
+func (m *NDPMechanism) learn(addr net.IP, lladdr []byte, port uint32) {
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv6DriverName)
+	if err != nil {
+		return
+	}
+
+	if addr.IsUnspecified() || len(lladdr) == 0 {
+		return
+	}
+
+	m.neighTable.Populate(mechutil.NeighEntry{
+		NetworkAddr: nldriver.CreateAddr(addr, nil),
+		LinkAddr:    lldriver.CreateAddr(lladdr),
+		Port:        port,
+	})
+}
+
+func (m *NDPMechanism) solicitationHandler(rw of.ResponseWriter, r *of.Request) {
+	packet, pdu2, pdu3, icmp, err := m.readMessage(r)
+	if err != nil {
+		log.ErrorLog("ndp/SOLICITATION_HANDLER",
+			"Failed to read packet: ", err)
+		return
+	}
+
+	var ns NeighborSolicitation
+	if err = ns.UnmarshalBinary(icmp.Body); err != nil {
+		log.ErrorLog("ndp/SOLICITATION_HANDLER",
+			"Failed to read neighbor solicitation: ", err)
+		return
+	}
+
+	log.DebugLog("ndp/SOLICITATION_HANDLER",
+		"Got neighbor solicitation to resolve: ", ns.Target)
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	// Use that port as egress to send response.
+	portNo := packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.UInt32()
+
+	// Get link layer address associated with egress port.
+	lladdr, err := lldriver.Addr(portNo)
+	if err != nil {
+		log.ErrorLogf("ndp/SOLICITATION_HANDLER",
+			"Failed to resolve port '%d' hardware address: '%s'", portNo, err)
+		return
+	}
+
+	src := net.IP(pdu3.SrcAddr.Bytes())
+	m.learn(src, ns.LinkAddr, portNo)
+
+	na := NeighborAdvertisement{
+		Router:    true,
+		Solicited: true,
+		Override:  true,
+		Target:    ns.Target,
+		LinkAddr:  lladdr.Bytes(),
+	}
+
+	dst := src
+	dstLinkAddr := pdu2.SrcAddr
+
+	// Defend address from duplicate address detection.
+	if src.IsUnspecified() {
+		na.Solicited = false
+		dst = AllNodes
+		dstLinkAddr = lldriver.CreateAddr(MulticastLinkAddr(AllNodes))
+	}
+
+	body, _ := na.MarshalBinary()
+	icmp = &ICMPv6{Type: ICMPv6NeighborAdvertisement, Body: body}
+
+	// Reply through the same VLAN.
+	frame := mech.LinkFrame{dstLinkAddr, lladdr, mech.Proto(iana.ETHT_IPV6), pdu2.VLAN, 0}
+
+	if err = m.writeMessage(rw, packet, &frame, ns.Target, dst, icmp); err != nil {
+		log.ErrorLog("ndp/SOLICITATION_HANDLER",
+			"Failed to send neighbor advertisement: ", err)
+	}
+}
+
+func (m *NDPMechanism) advertisementHandler(rw of.ResponseWriter, r *of.Request) {
+	packet, pdu2, _, icmp, err := m.readMessage(r)
+	if err != nil {
+		log.ErrorLog("ndp/ADVERTISEMENT_HANDLER",
+			"Failed to read packet: ", err)
+		return
+	}
+
+	var na NeighborAdvertisement
+	if err = na.UnmarshalBinary(icmp.Body); err != nil {
+		log.ErrorLog("ndp/ADVERTISEMENT_HANDLER",
+			"Failed to read neighbor advertisement: ", err)
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv6DriverName)
+	if err != nil {
+		return
+	}
+
+	lladdr := na.LinkAddr
+	if len(lladdr) == 0 {
+		lladdr = pdu2.SrcAddr.Bytes()
+	}
+
+	log.DebugLogf("ndp/ADVERTISEMENT_HANDLER",
+		"Resolve network layer address %s -> %s", na.Target, net.HardwareAddr(lladdr))
+
+	portNo := packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.UInt32()
+	m.learn(na.Target, lladdr, portNo)
+
+	m.releaseRequest(nldriver.CreateAddr(na.Target, nil))
+}
+
+func (m *NDPMechanism) routerSolicitationHandler(rw of.ResponseWriter, r *of.Request) {
+	packet, pdu2, pdu3, icmp, err := m.readMessage(r)
+	if err != nil {
+		log.ErrorLog("ndp/ROUTER_SOLICITATION_HANDLER",
+			"Failed to read packet: ", err)
+		return
+	}
+
+	var rs RouterSolicitation
+	if err = rs.UnmarshalBinary(icmp.Body); err != nil {
+		log.ErrorLog("ndp/ROUTER_SOLICITATION_HANDLER",
+			"Failed to read router solicitation: ", err)
+		return
+	}
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv6DriverName)
+	if err != nil {
+		return
+	}
+
+	portNo := packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.UInt32()
+
+	lladdr, err := lldriver.Addr(portNo)
+	if err != nil {
+		log.ErrorLogf("ndp/ROUTER_SOLICITATION_HANDLER",
+			"Failed to resolve port '%d' hardware address: '%s'", portNo, err)
+		return
+	}
+
+	nladdrs := nldriver.Addrs(portNo)
+	if len(nladdrs) == 0 {
+		log.ErrorLogf("ndp/ROUTER_SOLICITATION_HANDLER",
+			"Failed to resolve port '%d' network addresses", portNo)
+		return
+	}
+
+	src := net.IP(pdu3.SrcAddr.Bytes())
+	m.learn(src, rs.LinkAddr, portNo)
+
+	log.DebugLog("ndp/ROUTER_SOLICITATION_HANDLER",
+		"Got router solicitation from: ", src)
+
+	dst := src
+	dstLinkAddr := pdu2.SrcAddr
+
+	// Hosts without address receive multicast advertisement.
+	if src.IsUnspecified() {
+		dst = AllNodes
+		dstLinkAddr = lldriver.CreateAddr(MulticastLinkAddr(AllNodes))
+	}
+
+	linkLocal := LinkLocal(lladdr.Bytes())
+	frame := mech.LinkFrame{dstLinkAddr, lladdr, mech.Proto(iana.ETHT_IPV6), pdu2.VLAN, 0}
+
+	err = m.writeMessage(rw, packet, &frame, linkLocal, dst, routerAdvertisement(nladdrs, lladdr))
+	if err != nil {
+		log.ErrorLog("ndp/ROUTER_SOLICITATION_HANDLER",
+			"Failed to send router advertisement: ", err)
+	}
+}
+
+// routerAdvertisement returns advertisement of the networks of the interface.
+func routerAdvertisement(nladdrs []mech.NetworkAddr, lladdr mech.LinkAddr) *ICMPv6 {
+	ra := RouterAdvertisement{
+		HopLimit: 64,
+		Lifetime: RouterLifetime,
+		LinkAddr: lladdr.Bytes(),
+	}
+
+	for _, nladdr := range nladdrs {
+		prefix := net.IPNet{net.IP(nladdr.Bytes()), net.IPMask(nladdr.Mask().Bytes())}
+
+		ra.Prefixes = append(ra.Prefixes, PrefixInfo{
+			Prefix:            prefix,
+			OnLink:            true,
+			Autonomous:        nladdr.Mask().Len() == autoconfPrefixLen,
+			ValidLifetime:     PrefixValidLifetime,
+			PreferredLifetime: PrefixPreferredLifetime,
+		})
+	}
+
+	body, _ := ra.MarshalBinary()
+	return &ICMPv6{Type: ICMPv6RouterAdvertisement, Body: body}
+}
+
+// advertise sends router advertisement of the networks through the port.
+func (m *NDPMechanism) advertise(port uint32, nladdrs []mech.NetworkAddr, lladdr mech.LinkAddr, dst net.IP, dstLinkAddr []byte) error {
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return err
+	}
+
+	src := LinkLocal(lladdr.Bytes())
+	frame := mech.LinkFrame{lldriver.CreateAddr(dstLinkAddr), lladdr, mech.Proto(iana.ETHT_IPV6), m.VLAN(port), 0}
+
+	return m.send(port, &frame, src, dst, routerAdvertisement(nladdrs, lladdr))
+}
+
+// send sends ICMPv6 message through the port.
+func (m *NDPMechanism) send(port uint32, frame *mech.LinkFrame, src, dst net.IP, icmp *ICMPv6) error {
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return err
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv6DriverName)
+	if err != nil {
+		return err
+	}
+
+	pdu3 := mech.NetworkPacket{
+		DstAddr: nldriver.CreateAddr(dst, nil),
+		SrcAddr: nldriver.CreateAddr(src, nil),
+		Proto:   mech.Proto(iana.IP_PROTO_IPV6_ICMP),
+		Payload: bytes.NewReader(icmp.Marshal(src, dst)),
+	}
+
+	packetOut := ofp.PacketOut{
+		BufferID: ofp.NO_BUFFER,
+		InPort:   ofp.P_CONTROLLER,
+		Actions:  ofp.Actions{ofp.ActionOutput{ofp.PortNo(port), 0}},
+	}
+
+	llwriter := mech.MakeLinkWriterTo(lldriver, frame)
+	nlwriter := mech.MakeNetworkWriterTo(nldriver, &pdu3)
+
+	r, err := of.NewRequest(of.T_PACKET_OUT, of.NewReader(&packetOut, llwriter, nlwriter))
+	if err != nil {
+		log.ErrorLog("ndp/SEND",
+			"Failed to create a new ofp_packet_out request: ", err)
+		return err
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("ndp/SEND",
+			"Failed to send ofp_packet_out request: ", err)
+	}
+
+	return err
+}
+
+// Lookup returns link layer address of the neighbor connected to the
+// port, neighbor solicitation is sent, when address is not cached.
+func (m *NDPMechanism) Lookup(addr mech.NetworkAddr, port uint32) (mech.LinkAddr, error) {
+	log.DebugLog("ndp/LOOKUP",
+		"Got requests to lookup address: ", addr)
+
+	if neigh, ok := m.neighTable.Lookup(addr); ok {
+		// Success, table hit.
+		return neigh.LinkAddr, nil
+	}
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return nil, err
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv6DriverName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get link layer address associated with egress port.
+	lladdr, err := lldriver.Addr(port)
+	if err != nil {
+		log.ErrorLogf("ndp/LOOKUP",
+			"Failed to resolve port '%d' hardware address: '%s'", port, err)
+		return nil, err
+	}
+
+	// Get network layer address of egress port, that
+	// belongs to the same network, as target address.
+	nladdr, err := mech.SourceAddr(nldriver, port, addr)
+	if err != nil {
+		log.ErrorLogf("ndp/LOOKUP",
+			"Failed to resolve port '%d' network address: '%s'", port, err)
+		return nil, err
+	}
+
+	target := net.IP(addr.Bytes())
+	group := SolicitedNode(target)
+
+	ns := NeighborSolicitation{Target: target, LinkAddr: lladdr.Bytes()}
+	body, _ := ns.MarshalBinary()
+
+	frame := mech.LinkFrame{
+		lldriver.CreateAddr(MulticastLinkAddr(group)), lladdr,
+		mech.Proto(iana.ETHT_IPV6), m.VLAN(port), 0,
+	}
+
+	// Create waiter for specified network address
+	wait := m.createRequest(nldriver.CreateAddr(target, nil))
+
+	icmp := &ICMPv6{Type: ICMPv6NeighborSolicitation, Body: body}
+	if err = m.send(port, &frame, nladdr.Bytes(), group, icmp); err != nil {
+		return nil, err
+	}
+
+	//TODO: create timeout waiter
+	// Wait for response
+	if err = <-wait; err != nil {
+		log.ErrorLog("ndp/LOOKUP",
+			"Failed to wait for neighbor advertisement: ", err)
+		return nil, err
+	}
+
+	neigh, _ := m.neighTable.Lookup(addr)
+	return neigh.LinkAddr, nil
+}
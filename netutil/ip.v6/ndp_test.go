@@ -0,0 +1,53 @@
+package ip6
+
+import (
+	"testing"
+
+	"github.com/netrack/netrack/mechanism"
+)
+
+type fakeNetworkAddr struct {
+	mech.NetworkAddr
+
+	addr string
+}
+
+func (a fakeNetworkAddr) String() string {
+	return a.addr
+}
+
+func TestNDPUpdateNetworkPostCommit(t *testing.T) {
+}
+
+func TestNDPDeleteNetworkPreCommit(t *testing.T) {
+}
+
+func TestNDPSolicitationHandler(t *testing.T) {
+}
+
+func TestNDPAdvertisementHandler(t *testing.T) {
+}
+
+func TestNDPLookup(t *testing.T) {
+}
+
+func TestNDPReleaseRequests(t *testing.T) {
+	m := NewNDPMechanism().(*NDPMechanism)
+
+	resolved := m.createRequest(fakeNetworkAddr{addr: "2001:db8::1/128"})
+	pending := m.createRequest(fakeNetworkAddr{addr: "2001:db8::2/128"})
+
+	m.releaseRequest(fakeNetworkAddr{addr: "2001:db8::1/128"})
+	if err := <-resolved; err != nil {
+		t.Fatal("Resolved request must be released without error:", err)
+	}
+
+	m.releaseRequests(mech.ErrSwitchDisconnected)
+	if err := <-pending; err != mech.ErrSwitchDisconnected {
+		t.Fatal("Pending request must be released with error:", err)
+	}
+
+	if len(m.requests) != 0 {
+		t.Fatal("Released requests must be removed:", m.requests)
+	}
+}
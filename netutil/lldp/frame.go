@@ -0,0 +1,141 @@
+package lldp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+)
+
+// EtherType is an Ethernet type of LLDP frames.
+const EtherType = 0x88cc
+
+// Types of LLDP TLVs used by the mechanism.
+const (
+	tlvEnd       = 0
+	tlvChassisID = 1
+	tlvPortID    = 2
+	tlvTTL       = 3
+)
+
+// Locally assigned subtype of chassis and port identifiers.
+const subtypeLocal = 7
+
+var (
+	// NearestBridge is a multicast address, that is
+	// not forwarded by IEEE 802.1D compliant bridges.
+	NearestBridge = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e}
+
+	// ErrFrameMalformed is returned for frames, that are
+	// not LLDP frames or were not sent by the controller.
+	ErrFrameMalformed = errors.New("lldp: malformed frame")
+)
+
+// Frame is an Ethernet frame with LLDP data unit, that
+// identifies switch port, it has been sent through.
+type Frame struct {
+	// Source hardware address.
+	SrcAddr net.HardwareAddr
+
+	// Datapath identifier of the sender switch.
+	Datapath string
+
+	// Port number of the sender switch.
+	Port uint32
+
+	// Time to live in seconds.
+	TTL uint16
+}
+
+func writeTLV(buf *bytes.Buffer, t uint16, value []byte) {
+	binary.Write(buf, binary.BigEndian, t<<9|uint16(len(value)))
+	buf.Write(value)
+}
+
+// WriteTo implements io.WriterTo interface.
+func (f *Frame) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	buf.Write(NearestBridge)
+	buf.Write(f.SrcAddr)
+	binary.Write(&buf, binary.BigEndian, uint16(EtherType))
+
+	port := strconv.FormatUint(uint64(f.Port), 10)
+
+	var ttl [2]byte
+	binary.BigEndian.PutUint16(ttl[:], f.TTL)
+
+	writeTLV(&buf, tlvChassisID, append([]byte{subtypeLocal}, f.Datapath...))
+	writeTLV(&buf, tlvPortID, append([]byte{subtypeLocal}, port...))
+	writeTLV(&buf, tlvTTL, ttl[:])
+	writeTLV(&buf, tlvEnd, nil)
+
+	return buf.WriteTo(w)
+}
+
+// ReadFrom implements io.ReaderFrom interface.
+func (f *Frame) ReadFrom(r io.Reader) (int64, error) {
+	data, err := ioutil.ReadAll(r)
+	n := int64(len(data))
+
+	if err != nil {
+		return n, err
+	}
+
+	if len(data) < 14 || binary.BigEndian.Uint16(data[12:14]) != EtherType {
+		return n, ErrFrameMalformed
+	}
+
+	f.SrcAddr = net.HardwareAddr(data[6:12])
+
+	var chassis, port bool
+
+	for data = data[14:]; len(data) >= 2; {
+		header := binary.BigEndian.Uint16(data[:2])
+		t, length := header>>9, int(header&0x1ff)
+
+		if len(data) < 2+length {
+			return n, ErrFrameMalformed
+		}
+
+		value := data[2 : 2+length]
+		data = data[2+length:]
+
+		switch t {
+		case tlvEnd:
+			data = nil
+		case tlvChassisID:
+			if length < 2 || value[0] != subtypeLocal {
+				return n, ErrFrameMalformed
+			}
+
+			f.Datapath, chassis = string(value[1:]), true
+		case tlvPortID:
+			if length < 2 || value[0] != subtypeLocal {
+				return n, ErrFrameMalformed
+			}
+
+			number, err := strconv.ParseUint(string(value[1:]), 10, 32)
+			if err != nil {
+				return n, ErrFrameMalformed
+			}
+
+			f.Port, port = uint32(number), true
+		case tlvTTL:
+			if length != 2 {
+				return n, ErrFrameMalformed
+			}
+
+			f.TTL = binary.BigEndian.Uint16(value)
+		}
+	}
+
+	if !chassis || !port {
+		return n, ErrFrameMalformed
+	}
+
+	return n, nil
+}
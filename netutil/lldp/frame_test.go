@@ -0,0 +1,52 @@
+package lldp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestFrame(t *testing.T) {
+	frame := Frame{
+		SrcAddr:  net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+		Datapath: "00:00:00:00:00:00:00:01",
+		Port:     3,
+		TTL:      120,
+	}
+
+	var buf bytes.Buffer
+	if _, err := frame.WriteTo(&buf); err != nil {
+		t.Fatal("Failed to write LLDP frame:", err)
+	}
+
+	if !bytes.Equal(buf.Bytes()[:6], NearestBridge) {
+		t.Fatal("Invalid destination address:", buf.Bytes()[:6])
+	}
+
+	var read Frame
+	if _, err := read.ReadFrom(&buf); err != nil {
+		t.Fatal("Failed to read LLDP frame:", err)
+	}
+
+	if read.Datapath != frame.Datapath || read.Port != frame.Port ||
+		read.TTL != frame.TTL || !bytes.Equal(read.SrcAddr, frame.SrcAddr) {
+		t.Fatal("Invalid LLDP frame:", read)
+	}
+}
+
+func TestFrameMalformed(t *testing.T) {
+	// ARP frame.
+	data := make([]byte, 42)
+	data[12], data[13] = 0x08, 0x06
+
+	var frame Frame
+	if _, err := frame.ReadFrom(bytes.NewReader(data)); err != ErrFrameMalformed {
+		t.Fatal("Non-LLDP frame must be rejected:", err)
+	}
+
+	// LLDP frame without port identifier.
+	data = append(make([]byte, 12), 0x88, 0xcc, 0x02, 0x02, subtypeLocal, '1', 0x00, 0x00)
+	if _, err := frame.ReadFrom(bytes.NewReader(data)); err != ErrFrameMalformed {
+		t.Fatal("Incomplete LLDP frame must be rejected:", err)
+	}
+}
@@ -0,0 +1,312 @@
+package lldp
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/netutil/ofp.v13"
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+	"github.com/netrack/openflow/ofp.v13/ofputil"
+)
+
+const (
+	// LLDPMechanismName is a name of topology discovery mechanism.
+	LLDPMechanismName = "lldp"
+
+	// DefaultInterval is a default interval between LLDP frames.
+	DefaultInterval = 5 * time.Second
+)
+
+func init() {
+	constructor := mech.ExtensionMechanismConstructorFunc(NewLLDPMechanism)
+	mech.RegisterExtensionMechanism(LLDPMechanismName, constructor)
+}
+
+// Match packets of LLDP protocol.
+func lldpMatch() ofp.Match {
+	return ofp.Match{ofp.MT_OXM, []ofp.OXM{
+		ofputil.EthType(uint16(EtherType), nil),
+	}}
+}
+
+// hardwareAddr returns locally administered hardware
+// address built from the datapath identifier.
+func hardwareAddr(dpid string) net.HardwareAddr {
+	addr := make(net.HardwareAddr, 6)
+
+	if id, err := net.ParseMAC(dpid); err == nil && len(id) >= 6 {
+		copy(addr, id[len(id)-6:])
+	}
+
+	addr[0] = addr[0]&0xfe | 0x02
+	return addr
+}
+
+// LLDPMechanism discovers links between switches, it periodically
+// sends LLDP frames through all switch ports and builds controller
+// wide topology from the frames received by neighbor switches.
+type LLDPMechanism struct {
+	mech.BaseMechanism
+
+	// Handle request based on cookie value.
+	cookies *mech.CookieFilter
+
+	// Stores registered handlers to delete them later.
+	filter *of.ServeFilter
+
+	// Graph of switches.
+	topology *mech.Topology
+
+	// Interval between LLDP frames.
+	interval time.Duration
+
+	stopCh chan bool
+	lock   sync.Mutex
+}
+
+// NewLLDPMechanism creates a new instance of LLDPMechanism type.
+func NewLLDPMechanism() mech.ExtensionMechanism {
+	return &LLDPMechanism{
+		cookies:  mech.NewCookieFilter(),
+		filter:   of.NewServeFilter(),
+		topology: mech.DefaultTopology,
+		interval: DefaultInterval,
+	}
+}
+
+// Name implements Mechanism interface.
+func (m *LLDPMechanism) Name() string {
+	return LLDPMechanismName
+}
+
+// Description implements Mechanism interface.
+func (m *LLDPMechanism) Description() string {
+	return "Discovery of links between switches"
+}
+
+// Version implements VersionedMechanism interface.
+func (m *LLDPMechanism) Version() string {
+	return ofp13.ProtoVersion
+}
+
+// Enable implements Mechanism interface.
+func (m *LLDPMechanism) Enable(c *mech.MechanismContext) {
+	m.BaseMechanism.Enable(c)
+
+	m.filter.Mux = m.C.Mux
+	// Handle incoming LLDP frames.
+	m.filter.HandleFunc(of.T_PACKET_IN, m.packetInHandler)
+
+	// Links through the ports, that went down, are gone.
+	m.C.Ports.Subscribe(m.Name(), m.portHandler)
+
+	log.InfoLog("lldp/ENABLE_HOOK", "Mechanism LLDP enabled")
+}
+
+// Activate implements Mechanism interface.
+func (m *LLDPMechanism) Activate() {
+	m.BaseMechanism.Activate()
+
+	// Operate on PacketIn messages
+	m.cookies.Baker = ofputil.PacketInBaker()
+
+	// Send LLDP frames to the controller.
+	instructions := ofp.Instructions{ofp.InstructionActions{
+		ofp.IT_APPLY_ACTIONS,
+		ofp.Actions{ofp.ActionOutput{ofp.P_CONTROLLER, ofp.CML_NO_BUFFER}},
+	}}
+
+	flowMod := ofp.FlowMod{
+		Command:      ofp.FC_ADD,
+		BufferID:     ofp.NO_BUFFER,
+		Priority:     50,
+		Match:        lldpMatch(),
+		Instructions: instructions,
+	}
+
+	m.cookies.FilterFunc(&flowMod, m.lldpHandler)
+
+	r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
+	if err != nil {
+		log.ErrorLog("lldp/ACTIVATE_HOOK",
+			"Failed to create ofp_flow_mod request: ", err)
+		return
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("lldp/ACTIVATE_HOOK",
+			"Failed to send requests: ", err)
+		return
+	}
+
+	m.topology.AddNode(m.C.Switch.ID())
+	m.start()
+
+	log.DebugLog("lldp/ACTIVATE_HOOK",
+		"Mechanism LLDP activated")
+}
+
+// Disable implements Mechanism interface.
+func (m *LLDPMechanism) Disable() {
+	m.BaseMechanism.Disable()
+
+	m.stop()
+	m.filter.Unhandle()
+	m.C.Ports.Unsubscribe(m.Name())
+
+	// Flush redirect flow
+	err := of.Send(m.C.Switch.Conn(), ofputil.FlowFlush(0, lldpMatch()))
+	if err != nil {
+		log.ErrorLog("lldp/DISABLE_HOOK",
+			"Failed to send requests: ", err)
+	}
+
+	m.topology.DeleteNode(m.C.Switch.ID())
+
+	log.InfoLog("lldp/DISABLE_HOOK", "Mechanism LLDP disabled")
+}
+
+// Deactivate implements Mechanism interface.
+func (m *LLDPMechanism) Deactivate() {
+	m.BaseMechanism.Deactivate()
+
+	m.stop()
+	m.filter.Unhandle()
+	m.C.Ports.Unsubscribe(m.Name())
+
+	// Drop handlers of cookies of installed flows.
+	m.cookies = mech.NewCookieFilter()
+
+	m.topology.DeleteNode(m.C.Switch.ID())
+
+	log.InfoLog("lldp/DEACTIVATE_HOOK", "Mechanism LLDP deactivated")
+}
+
+// OwnsFlow implements mech.FlowOwner interface.
+func (m *LLDPMechanism) OwnsFlow(table int, cookie uint64) bool {
+	return m.cookies.Contains(cookie)
+}
+
+// start starts sending LLDP frames in a separate goroutine.
+func (m *LLDPMechanism) start() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.stopCh != nil {
+		return
+	}
+
+	m.stopCh = make(chan bool)
+	go m.run(m.stopCh)
+}
+
+// stop stops sending LLDP frames.
+func (m *LLDPMechanism) stop() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.stopCh != nil {
+		close(m.stopCh)
+		m.stopCh = nil
+	}
+}
+
+func (m *LLDPMechanism) run(stopCh chan bool) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	m.tick()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		m.tick()
+	}
+}
+
+// tick sends LLDP frames through all switch ports
+// and removes links, that were not confirmed in time.
+func (m *LLDPMechanism) tick() {
+	m.topology.Expire(time.Now())
+
+	dpid := m.C.Switch.ID()
+	lladdr := hardwareAddr(dpid)
+
+	// Neighbors have to keep information till the next frame.
+	ttl := uint16(m.topology.Timeout / time.Second)
+
+	var requests []*of.Request
+
+	for _, port := range m.C.Switch.PortList() {
+		packetOut := ofp.PacketOut{
+			BufferID: ofp.NO_BUFFER,
+			InPort:   ofp.P_CONTROLLER,
+			Actions:  ofp.Actions{ofp.ActionOutput{ofp.PortNo(port.Number), 0}},
+		}
+
+		frame := Frame{lladdr, dpid, port.Number, ttl}
+
+		r, err := of.NewRequest(of.T_PACKET_OUT, of.NewReader(&packetOut, &frame))
+		if err != nil {
+			log.ErrorLog("lldp/SEND_FRAMES",
+				"Failed to create ofp_packet_out request: ", err)
+			return
+		}
+
+		requests = append(requests, r)
+	}
+
+	if len(requests) == 0 {
+		return
+	}
+
+	if err := of.Send(m.C.Switch.Conn(), requests...); err != nil {
+		log.ErrorLog("lldp/SEND_FRAMES",
+			"Failed to send LLDP frames: ", err)
+	}
+}
+
+func (m *LLDPMechanism) packetInHandler(rw of.ResponseWriter, r *of.Request) {
+	// Serve message based on PacketIn cookies.
+	m.cookies.Serve(rw, r)
+}
+
+func (m *LLDPMechanism) lldpHandler(rw of.ResponseWriter, r *of.Request) {
+	var packet ofp.PacketIn
+	var frame Frame
+
+	if _, err := of.ReadAllFrom(r.Body, &packet, &frame); err != nil {
+		log.ErrorLog("lldp/LLDP_HANDLER",
+			"Failed to read LLDP frame: ", err)
+		return
+	}
+
+	link := mech.TopologyLink{
+		SrcDatapath: frame.Datapath,
+		SrcPort:     frame.Port,
+		DstDatapath: m.C.Switch.ID(),
+		DstPort:     packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.UInt32(),
+		LastSeen:    time.Now(),
+	}
+
+	log.DebugLogf("lldp/LLDP_HANDLER",
+		"Link discovered: %s/%d -> %s/%d", link.SrcDatapath,
+		link.SrcPort, link.DstDatapath, link.DstPort)
+
+	m.topology.UpdateLink(link)
+}
+
+func (m *LLDPMechanism) portHandler(event mech.PortEvent) {
+	switch event.Type {
+	case mech.PortDown, mech.PortDeleted:
+		m.topology.DeletePort(m.C.Switch.ID(), event.Port.Number)
+	}
+}
@@ -0,0 +1,54 @@
+package ofp10
+
+import (
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/openflow"
+)
+
+func init() {
+	constructor := mech.ExtensionMechanismConstructorFunc(NewOFPMechanism)
+	mech.RegisterExtensionMechanism("ofp-1.0", constructor)
+}
+
+type OFPMechanism struct {
+	mech.BaseMechanism
+}
+
+// NewOFPMechanism creates new instance of OFPMechanism type.
+func NewOFPMechanism() mech.ExtensionMechanism {
+	return &OFPMechanism{}
+}
+
+func (m *OFPMechanism) Name() string {
+	return "ofp-1.0"
+}
+
+func (m *OFPMechanism) Description() string {
+	return "OpenFlow 1.0 protocol support"
+}
+
+// Version implements VersionedMechanism interface.
+func (m *OFPMechanism) Version() string {
+	return ProtoVersion
+}
+
+// Enable implements Mechanism interface.
+func (m *OFPMechanism) Enable(c *mech.MechanismContext) {
+	m.BaseMechanism.Enable(c)
+
+	m.C.Mux.HandleFunc(of.T_ECHO_REQUEST, m.echoHandler)
+
+	log.InfoLog("ofp1.0/ENABLE_HOOK",
+		"Mechanism ofp1.0 enabled")
+}
+
+func (m *OFPMechanism) echoHandler(rw of.ResponseWriter, r *of.Request) {
+	rw.Header().Set(of.TypeHeaderKey, of.T_ECHO_REPLY)
+	rw.Header().Set(of.VersionHeaderKey, VERSION)
+
+	if err := rw.WriteHeader(); err != nil {
+		log.ErrorLog("ofp1.0/ECHO_SEND_ECHO_REPLY",
+			"Failed to send ofp_echo_reply: ", err)
+	}
+}
@@ -0,0 +1,663 @@
+package ofp10
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/netrack/openflow"
+)
+
+// VERSION is a wire protocol version of OpenFlow 1.0.
+const VERSION uint8 = 0x01
+
+const (
+	// T_STATS_REQUEST is a type of ofp_stats_request message.
+	T_STATS_REQUEST of.Type = 16
+
+	// T_STATS_REPLY is a type of ofp_stats_reply message.
+	T_STATS_REPLY of.Type = 17
+)
+
+var (
+	// ErrActionType is returned on reading unsupported action.
+	ErrActionType = errors.New("ofp10: unsupported action type")
+)
+
+// PortNo is a switch port number.
+type PortNo uint16
+
+const (
+	// P_MAX is a maximum number of physical switch ports.
+	P_MAX PortNo = 0xff00
+
+	// P_IN_PORT sends the packet out the input port.
+	P_IN_PORT PortNo = 0xfff8
+
+	// P_TABLE performs actions in flow table (packet-out only).
+	P_TABLE PortNo = 0xfff9
+
+	// P_NORMAL process with normal L2/L3 switching.
+	P_NORMAL PortNo = 0xfffa
+
+	// P_FLOOD sends packet to all physical ports except
+	// input port and those disabled by STP.
+	P_FLOOD PortNo = 0xfffb
+
+	// P_ALL sends packet to all physical ports except input port.
+	P_ALL PortNo = 0xfffc
+
+	// P_CONTROLLER sends packet to controller.
+	P_CONTROLLER PortNo = 0xfffd
+
+	// P_LOCAL is a local openflow port.
+	P_LOCAL PortNo = 0xfffe
+
+	// P_NONE is not associated with a physical port.
+	P_NONE PortNo = 0xffff
+)
+
+// NO_BUFFER means, that packet is not buffered on the switch.
+const NO_BUFFER uint32 = 0xffffffff
+
+// NewRequest creates a new OpenFlow request with OpenFlow 1.0
+// version in a message header.
+func NewRequest(t of.Type, body io.Reader) (*of.Request, error) {
+	r, err := of.NewRequest(t, body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Header.Set(of.VersionHeaderKey, VERSION)
+	return r, nil
+}
+
+// Wildcards is a bitmap of match fields, that should be ignored.
+type Wildcards uint32
+
+const (
+	FW_IN_PORT Wildcards = 1 << iota
+	FW_DL_VLAN
+	FW_DL_SRC
+	FW_DL_DST
+	FW_DL_TYPE
+	FW_NW_PROTO
+	FW_TP_SRC
+	FW_TP_DST
+)
+
+const (
+	fwNWSrcShift = 8
+	fwNWDstShift = 14
+
+	// FW_NW_SRC_MASK is a mask of the network source wildcard bits.
+	FW_NW_SRC_MASK Wildcards = ((1 << 6) - 1) << fwNWSrcShift
+
+	// FW_NW_DST_MASK is a mask of the network destination wildcard bits.
+	FW_NW_DST_MASK Wildcards = ((1 << 6) - 1) << fwNWDstShift
+
+	FW_DL_VLAN_PCP Wildcards = 1 << 20
+	FW_NW_TOS      Wildcards = 1 << 21
+
+	// FW_ALL wildcards all fields.
+	FW_ALL Wildcards = (1 << 22) - 1
+)
+
+// Match is a fields to match against flows.
+type Match struct {
+	Wildcards Wildcards
+	InPort    PortNo
+	DLSrc     [6]byte
+	DLDst     [6]byte
+	DLVLAN    uint16
+	DLVLANPCP uint8
+	_         uint8
+	DLType    uint16
+	NWTos     uint8
+	NWProto   uint8
+	_         uint16
+	NWSrc     [4]byte
+	NWDst     [4]byte
+	TPSrc     uint16
+	TPDst     uint16
+}
+
+// MatchField updates match and clears corresponding wildcard bits.
+type MatchField func(*Match)
+
+// NewMatch creates a new match, all fields are wildcarded
+// except specified ones.
+func NewMatch(fields ...MatchField) Match {
+	m := Match{Wildcards: FW_ALL}
+
+	for _, field := range fields {
+		field(&m)
+	}
+
+	return m
+}
+
+// MatchInPort matches packets received on specified port.
+func MatchInPort(port PortNo) MatchField {
+	return func(m *Match) {
+		m.Wildcards &^= FW_IN_PORT
+		m.InPort = port
+	}
+}
+
+// MatchEthType matches packets of specified ethernet type.
+func MatchEthType(t uint16) MatchField {
+	return func(m *Match) {
+		m.Wildcards &^= FW_DL_TYPE
+		m.DLType = t
+	}
+}
+
+// MatchEthSrc matches packets with specified source hardware address.
+func MatchEthSrc(addr []byte) MatchField {
+	return func(m *Match) {
+		m.Wildcards &^= FW_DL_SRC
+		copy(m.DLSrc[:], addr)
+	}
+}
+
+// MatchEthDst matches packets with specified destination hardware address.
+func MatchEthDst(addr []byte) MatchField {
+	return func(m *Match) {
+		m.Wildcards &^= FW_DL_DST
+		copy(m.DLDst[:], addr)
+	}
+}
+
+// MatchIPProto matches packets of specified IP protocol. For
+// ARP packets the lower 8 bits of the ARP opcode are matched.
+func MatchIPProto(proto uint8) MatchField {
+	return func(m *Match) {
+		m.Wildcards &^= FW_NW_PROTO
+		m.NWProto = proto
+	}
+}
+
+// maskBits returns count of wildcarded bits for specified mask.
+func maskBits(mask []byte) Wildcards {
+	if mask == nil {
+		return 0
+	}
+
+	ones, _ := net.IPMask(mask).Size()
+	return Wildcards(32 - ones)
+}
+
+// MatchIPv4Src matches packets with specified source address
+// (or ARP sender address) under specified mask.
+func MatchIPv4Src(addr, mask []byte) MatchField {
+	return func(m *Match) {
+		m.Wildcards &^= FW_NW_SRC_MASK
+		m.Wildcards |= maskBits(mask) << fwNWSrcShift
+		copy(m.NWSrc[:], net.IP(addr).To4())
+	}
+}
+
+// MatchIPv4Dst matches packets with specified destination address
+// (or ARP target address) under specified mask.
+func MatchIPv4Dst(addr, mask []byte) MatchField {
+	return func(m *Match) {
+		m.Wildcards &^= FW_NW_DST_MASK
+		m.Wildcards |= maskBits(mask) << fwNWDstShift
+		copy(m.NWDst[:], net.IP(addr).To4())
+	}
+}
+
+// MatchTPSrc matches specified transport source port (or ICMP type).
+func MatchTPSrc(port uint16) MatchField {
+	return func(m *Match) {
+		m.Wildcards &^= FW_TP_SRC
+		m.TPSrc = port
+	}
+}
+
+// MatchTPDst matches specified transport destination port (or ICMP code).
+func MatchTPDst(port uint16) MatchField {
+	return func(m *Match) {
+		m.Wildcards &^= FW_TP_DST
+		m.TPDst = port
+	}
+}
+
+// ReadFrom implements io.ReaderFrom interface.
+func (m *Match) ReadFrom(r io.Reader) (int64, error) {
+	return 40, binary.Read(r, binary.BigEndian, m)
+}
+
+// WriteTo implements io.WriterTo interface.
+func (m *Match) WriteTo(w io.Writer) (int64, error) {
+	return 40, binary.Write(w, binary.BigEndian, m)
+}
+
+// ActionType is a type of the action.
+type ActionType uint16
+
+const (
+	AT_OUTPUT ActionType = iota
+	AT_SET_VLAN_VID
+	AT_SET_VLAN_PCP
+	AT_STRIP_VLAN
+	AT_SET_DL_SRC
+	AT_SET_DL_DST
+	AT_SET_NW_SRC
+	AT_SET_NW_DST
+	AT_SET_NW_TOS
+	AT_SET_TP_SRC
+	AT_SET_TP_DST
+	AT_ENQUEUE
+)
+
+// Action describes types, that can be written as ofp_action.
+type Action interface {
+	io.WriterTo
+}
+
+// ActionOutput sends packet to the specified port.
+type ActionOutput struct {
+	Port   PortNo
+	MaxLen uint16
+}
+
+// WriteTo implements io.WriterTo interface.
+func (a ActionOutput) WriteTo(w io.Writer) (int64, error) {
+	return 8, binary.Write(w, binary.BigEndian, struct {
+		Type   ActionType
+		Len    uint16
+		Port   PortNo
+		MaxLen uint16
+	}{AT_OUTPUT, 8, a.Port, a.MaxLen})
+}
+
+// ActionSetDLSrc updates source hardware address of the packet.
+type ActionSetDLSrc struct {
+	Addr []byte
+}
+
+// WriteTo implements io.WriterTo interface.
+func (a ActionSetDLSrc) WriteTo(w io.Writer) (int64, error) {
+	return writeDLAction(w, AT_SET_DL_SRC, a.Addr)
+}
+
+// ActionSetDLDst updates destination hardware address of the packet.
+type ActionSetDLDst struct {
+	Addr []byte
+}
+
+// WriteTo implements io.WriterTo interface.
+func (a ActionSetDLDst) WriteTo(w io.Writer) (int64, error) {
+	return writeDLAction(w, AT_SET_DL_DST, a.Addr)
+}
+
+func writeDLAction(w io.Writer, t ActionType, addr []byte) (int64, error) {
+	var action struct {
+		Type ActionType
+		Len  uint16
+		Addr [6]byte
+		_    [6]byte
+	}
+
+	action.Type, action.Len = t, 16
+	copy(action.Addr[:], addr)
+
+	return 16, binary.Write(w, binary.BigEndian, &action)
+}
+
+// ActionSetVLANVID sets 802.1Q VLAN identifier, if packet does
+// not have a VLAN tag, a new one will be added.
+type ActionSetVLANVID struct {
+	VLANVID uint16
+}
+
+// WriteTo implements io.WriterTo interface.
+func (a ActionSetVLANVID) WriteTo(w io.Writer) (int64, error) {
+	return 8, binary.Write(w, binary.BigEndian, struct {
+		Type    ActionType
+		Len     uint16
+		VLANVID uint16
+		_       uint16
+	}{Type: AT_SET_VLAN_VID, Len: 8, VLANVID: a.VLANVID})
+}
+
+// ActionStripVLAN removes 802.1Q header from the packet.
+type ActionStripVLAN struct{}
+
+// WriteTo implements io.WriterTo interface.
+func (a ActionStripVLAN) WriteTo(w io.Writer) (int64, error) {
+	return 8, binary.Write(w, binary.BigEndian, struct {
+		Type ActionType
+		Len  uint16
+		_    uint32
+	}{Type: AT_STRIP_VLAN, Len: 8})
+}
+
+// Actions is a list of actions.
+type Actions []Action
+
+// Bytes returns wire representation of the actions.
+func (a Actions) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	for _, action := range a {
+		if _, err := action.WriteTo(&buf); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// WriteTo implements io.WriterTo interface.
+func (a Actions) WriteTo(w io.Writer) (int64, error) {
+	b, err := a.Bytes()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// FlowModCommand is a flow modification command.
+type FlowModCommand uint16
+
+const (
+	FC_ADD FlowModCommand = iota
+	FC_MODIFY
+	FC_MODIFY_STRICT
+	FC_DELETE
+	FC_DELETE_STRICT
+)
+
+// FlowModFlags is a flow modification flags.
+type FlowModFlags uint16
+
+const (
+	FF_SEND_FLOW_REM FlowModFlags = 1 << iota
+	FF_CHECK_OVERLAP
+	FF_EMERG
+)
+
+// FlowMod is a flow setup and teardown message.
+type FlowMod struct {
+	Match       Match
+	Cookie      uint64
+	Command     FlowModCommand
+	IdleTimeout uint16
+	HardTimeout uint16
+	Priority    uint16
+	BufferID    uint32
+	OutPort     PortNo
+	Flags       FlowModFlags
+	Actions     Actions
+}
+
+// WriteTo implements io.WriterTo interface.
+func (f *FlowMod) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	if _, err := f.Match.WriteTo(&buf); err != nil {
+		return 0, err
+	}
+
+	outPort := f.OutPort
+	// Output port is ignored for non-delete commands,
+	// but make it explicit for the delete ones.
+	if outPort == 0 {
+		outPort = P_NONE
+	}
+
+	err := binary.Write(&buf, binary.BigEndian, struct {
+		Cookie      uint64
+		Command     FlowModCommand
+		IdleTimeout uint16
+		HardTimeout uint16
+		Priority    uint16
+		BufferID    uint32
+		OutPort     PortNo
+		Flags       FlowModFlags
+	}{f.Cookie, f.Command, f.IdleTimeout, f.HardTimeout,
+		f.Priority, f.BufferID, outPort, f.Flags})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = f.Actions.WriteTo(&buf); err != nil {
+		return 0, err
+	}
+
+	return buf.WriteTo(w)
+}
+
+// FlowRemoved is a message sent by switch on flow expiration.
+type FlowRemoved struct {
+	Match        Match
+	Cookie       uint64
+	Priority     uint16
+	Reason       uint8
+	_            uint8
+	DurationSec  uint32
+	DurationNSec uint32
+	IdleTimeout  uint16
+	_            uint16
+	PacketCount  uint64
+	ByteCount    uint64
+}
+
+// ReadFrom implements io.ReaderFrom interface.
+func (f *FlowRemoved) ReadFrom(r io.Reader) (int64, error) {
+	return 80, binary.Read(r, binary.BigEndian, f)
+}
+
+// PacketIn is a message sent by switch on packet received.
+// The ethernet frame follows the message header.
+type PacketIn struct {
+	BufferID uint32
+	TotalLen uint16
+	InPort   PortNo
+	Reason   uint8
+	_        uint8
+}
+
+// ReadFrom implements io.ReaderFrom interface.
+func (p *PacketIn) ReadFrom(r io.Reader) (int64, error) {
+	return 10, binary.Read(r, binary.BigEndian, p)
+}
+
+// PacketOut is a message sent by controller to send
+// packet through the datapath. Ethernet frame should be
+// written right after the message header.
+type PacketOut struct {
+	BufferID uint32
+	InPort   PortNo
+	Actions  Actions
+}
+
+// WriteTo implements io.WriterTo interface.
+func (p *PacketOut) WriteTo(w io.Writer) (int64, error) {
+	actions, err := p.Actions.Bytes()
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+
+	err = binary.Write(&buf, binary.BigEndian, struct {
+		BufferID   uint32
+		InPort     PortNo
+		ActionsLen uint16
+	}{p.BufferID, p.InPort, uint16(len(actions))})
+
+	if err != nil {
+		return 0, err
+	}
+
+	buf.Write(actions)
+	return buf.WriteTo(w)
+}
+
+// PortConfig is a bitmap of port configuration flags.
+type PortConfig uint32
+
+const (
+	PC_PORT_DOWN PortConfig = 1 << iota
+	PC_NO_STP
+	PC_NO_RECV
+	PC_NO_RECV_STP
+	PC_NO_FLOOD
+	PC_NO_FWD
+	PC_NO_PACKET_IN
+)
+
+var portConfigText = []string{
+	"PORT_DOWN", "NO_STP", "NO_RECV", "NO_RECV_STP",
+	"NO_FLOOD", "NO_FWD", "NO_PACKET_IN",
+}
+
+func (c PortConfig) String() string {
+	return bitmapString(uint32(c), portConfigText)
+}
+
+// PortState is a bitmap of current port state.
+type PortState uint32
+
+const (
+	PS_LINK_DOWN PortState = 1 << 0
+)
+
+var portStateText = []string{"LINK_DOWN"}
+
+func (s PortState) String() string {
+	return bitmapString(uint32(s), portStateText)
+}
+
+// PortFeatures is a bitmap of port features.
+type PortFeatures uint32
+
+var portFeaturesText = []string{
+	"10MB_HD", "10MB_FD", "100MB_HD", "100MB_FD", "1GB_HD", "1GB_FD",
+	"10GB_FD", "COPPER", "FIBER", "AUTONEG", "PAUSE", "PAUSE_ASYM",
+}
+
+func (f PortFeatures) String() string {
+	return bitmapString(uint32(f), portFeaturesText)
+}
+
+func bitmapString(bitmap uint32, text []string) string {
+	var parts []string
+
+	for i, s := range text {
+		if bitmap&(1<<uint(i)) != 0 {
+			parts = append(parts, s)
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// PhyPort is a description of physical port.
+type PhyPort struct {
+	PortNo     PortNo
+	HWAddr     [6]byte
+	Name       [16]byte
+	Config     PortConfig
+	State      PortState
+	Curr       PortFeatures
+	Advertised PortFeatures
+	Supported  PortFeatures
+	Peer       PortFeatures
+}
+
+// ReadFrom implements io.ReaderFrom interface.
+func (p *PhyPort) ReadFrom(r io.Reader) (int64, error) {
+	return 48, binary.Read(r, binary.BigEndian, p)
+}
+
+// SwitchFeatures is a switch features reply message.
+type SwitchFeatures struct {
+	DatapathID   uint64
+	NumBuffers   uint32
+	NumTables    uint8
+	Capabilities uint32
+	Actions      uint32
+	Ports        []PhyPort
+}
+
+// ReadFrom implements io.ReaderFrom interface.
+func (s *SwitchFeatures) ReadFrom(r io.Reader) (int64, error) {
+	var header struct {
+		DatapathID   uint64
+		NumBuffers   uint32
+		NumTables    uint8
+		_            [3]byte
+		Capabilities uint32
+		Actions      uint32
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return 0, err
+	}
+
+	s.DatapathID = header.DatapathID
+	s.NumBuffers = header.NumBuffers
+	s.NumTables = header.NumTables
+	s.Capabilities = header.Capabilities
+	s.Actions = header.Actions
+	s.Ports = nil
+
+	n := int64(24)
+
+	// Port descriptions are following till the end of message.
+	for {
+		var port PhyPort
+
+		_, err := port.ReadFrom(r)
+		if err == io.EOF {
+			return n, nil
+		}
+
+		if err != nil {
+			return n, err
+		}
+
+		n += 48
+		s.Ports = append(s.Ports, port)
+	}
+}
+
+// TableFlush returns a request to remove all flows from the table.
+func TableFlush() *of.Request {
+	return FlowFlush(NewMatch())
+}
+
+// FlowFlush returns a request to remove flows matching specified match.
+func FlowFlush(match Match) *of.Request {
+	r, _ := NewRequest(of.T_FLOW_MOD, of.NewReader(&FlowMod{
+		Command:  FC_DELETE,
+		BufferID: NO_BUFFER,
+		OutPort:  P_NONE,
+		Match:    match,
+	}))
+
+	return r
+}
+
+// FlowDrop returns a request to insert black-hole rule with the
+// lowest priority.
+func FlowDrop() *of.Request {
+	r, _ := NewRequest(of.T_FLOW_MOD, of.NewReader(&FlowMod{
+		Command:  FC_ADD,
+		BufferID: NO_BUFFER,
+		Match:    NewMatch(),
+	}))
+
+	return r
+}
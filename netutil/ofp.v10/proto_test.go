@@ -0,0 +1,88 @@
+package ofp10
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewMatch(t *testing.T) {
+	match := NewMatch(
+		MatchEthType(0x0800),
+		MatchIPv4Dst([]byte{10, 0, 0, 0}, []byte{255, 255, 255, 0}),
+	)
+
+	wildcards := FW_ALL&^FW_DL_TYPE&^FW_NW_DST_MASK | 8<<fwNWDstShift
+	if match.Wildcards != wildcards {
+		t.Fatalf("Invalid wildcards: %x != %x", match.Wildcards, wildcards)
+	}
+
+	var buf bytes.Buffer
+	if _, err := match.WriteTo(&buf); err != nil {
+		t.Fatal("Failed to write match:", err)
+	}
+
+	if buf.Len() != 40 {
+		t.Fatal("Invalid match length:", buf.Len())
+	}
+
+	var m Match
+	if _, err := m.ReadFrom(&buf); err != nil {
+		t.Fatal("Failed to read match:", err)
+	}
+
+	if m != match {
+		t.Fatal("Match was not restored:", m)
+	}
+}
+
+func TestFlowModWriteTo(t *testing.T) {
+	flowMod := FlowMod{
+		Command:  FC_ADD,
+		BufferID: NO_BUFFER,
+		Match:    NewMatch(),
+		Actions: Actions{
+			ActionSetDLDst{[]byte{0, 1, 2, 3, 4, 5}},
+			ActionOutput{Port: 1},
+		},
+	}
+
+	var buf bytes.Buffer
+	if _, err := flowMod.WriteTo(&buf); err != nil {
+		t.Fatal("Failed to write flow mod:", err)
+	}
+
+	// 40 bytes of match, 24 bytes of flow mod
+	// fields and 16 + 8 bytes of actions.
+	if buf.Len() != 88 {
+		t.Fatal("Invalid flow mod length:", buf.Len())
+	}
+}
+
+func TestSwitchFeaturesReadFrom(t *testing.T) {
+	var buf bytes.Buffer
+
+	buf.Write([]byte{0, 0, 0, 0, 0, 0, 0, 1})
+	buf.Write(make([]byte, 16))
+
+	port := make([]byte, 48)
+	copy(port, []byte{0xff, 0xfe})
+	copy(port[8:], "br0")
+	buf.Write(port)
+
+	var features SwitchFeatures
+	if _, err := features.ReadFrom(&buf); err != nil {
+		t.Fatal("Failed to read switch features:", err)
+	}
+
+	if features.DatapathID != 1 {
+		t.Fatal("Invalid datapath identifier:", features.DatapathID)
+	}
+
+	if len(features.Ports) != 1 || features.Ports[0].PortNo != P_LOCAL {
+		t.Fatal("Invalid port descriptions:", features.Ports)
+	}
+
+	if name := SwitchPort(features.Ports[0]).Name; name != "br0" {
+		t.Fatal("Invalid port name:", name)
+	}
+}
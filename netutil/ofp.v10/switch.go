@@ -0,0 +1,292 @@
+package ofp10
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/openflow"
+)
+
+// ProtoVersion is a protocol version handled by the switch.
+const ProtoVersion = "OFP/1.0"
+
+var (
+	// ErrTableAllocate is returned on attempt to allocate table,
+	// OpenFlow 1.0 switches have only one table for all mechanisms.
+	ErrTableAllocate = errors.New("Switch: multiple tables are not supported")
+)
+
+func init() {
+	constructor := mech.SwitchConstructorFunc(NewSwitch)
+	mech.RegisterSwitch(ProtoVersion, constructor)
+}
+
+func SwitchPort(port PhyPort) *mech.SwitchPort {
+	return &mech.SwitchPort{
+		Name:     strings.TrimRight(string(port.Name[:]), "\u0000"),
+		Number:   uint32(port.PortNo),
+		Config:   port.Config.String(),
+		State:    port.State.String(),
+		Features: port.Curr.String(),
+	}
+}
+
+// HardwareAddr returns hardware address of the switch port.
+func HardwareAddr(port PhyPort) net.HardwareAddr {
+	return net.HardwareAddr(port.HWAddr[:])
+}
+
+// Switch handles connections with OpenFlow 1.0 switches
+type Switch struct {
+	// Connection to openflow switch.
+	conn of.OFPConn
+
+	// List of switch features, including port descriptions.
+	features SwitchFeatures
+}
+
+// NewSwitch returns new instance of a Switch.
+func NewSwitch() mech.Switch {
+	return &Switch{}
+}
+
+// Boot implements Switch interface
+func (s *Switch) Boot(c of.OFPConn) error {
+	// Save connection instance
+	s.conn = c
+
+	// Send ofp_hello message to complete handshake.
+	ofpHello, err := NewRequest(of.T_HELLO, nil)
+	if err != nil {
+		log.ErrorLog("switch/SWITCH_BOOT_ERR",
+			"Failed to create OpenFlow Hello request: ", err)
+		return err
+	}
+
+	// Send ofp_features_request to retrieve datapath id and
+	// port descriptions, OpenFlow 1.0 has no separate request
+	// for port descriptions.
+	ofpFeatures, err := NewRequest(of.T_FEATURES_REQUEST, nil)
+	if err != nil {
+		log.ErrorLog("switch/SWITCH_BOOT_ERR",
+			"Failed to create OpenFlow Features request: ", err)
+		return err
+	}
+
+	err = of.Send(c,
+		ofpHello,
+		ofpFeatures,
+		// Clear the flow table first
+		TableFlush(),
+		// Write black-hole rule with the lowest priority.
+		// This rule prevents flooding of the controller with
+		// dumb ofp_packet_in messages.
+		FlowDrop(),
+	)
+
+	if err != nil {
+		log.ErrorLog("switch/SWITCH_BOOT_SEND_ERR",
+			"Failed to send handshake messages: ", err)
+		return err
+	}
+
+	errCh, doneCh := make(chan error, 1), make(chan bool, 1)
+
+	echoHandler := func(rw of.ResponseWriter, r *of.Request) {
+		rw.Header().Set(of.VersionHeaderKey, VERSION)
+		rw.Header().Set(of.TypeHeaderKey, of.T_ECHO_REPLY)
+
+		if err := rw.WriteHeader(); err != nil {
+			log.ErrorLog("ofp1.0/ECHO_SEND_ECHO_REPLY",
+				"Failed to send ofp_echo_reply: ", err)
+		}
+	}
+
+	featuresHandler := func(rw of.ResponseWriter, r *of.Request) {
+		if _, err := s.features.ReadFrom(r.Body); err != nil {
+			log.ErrorLog("ofp1.0/FEATURES_READ_ERR",
+				"Failed to read ofp_switch_features: ", err)
+
+			errCh <- err
+			return
+		}
+
+		doneCh <- true
+	}
+
+	mux := of.NewServeMux()
+	mux.HandleFunc(of.T_FEATURES_REPLY, featuresHandler)
+	mux.HandleFunc(of.T_ECHO_REQUEST, echoHandler)
+
+	for {
+		r, err := c.Receive()
+		if err != nil {
+			log.ErrorLog("switch/SWITCH_BOOT_ERR",
+				"Failed receive next OpenFlow message: ", err)
+
+			return err
+		}
+
+		mux.Serve(&of.Response{Conn: c}, r)
+
+		select {
+		case err := <-errCh:
+			return err
+
+		case <-doneCh:
+			return nil
+
+		default:
+		}
+	}
+}
+
+// Conn implements Switch interface
+func (s *Switch) Conn() of.OFPConn {
+	return s.conn
+}
+
+// Version implements Switch interface
+func (s *Switch) Version() string {
+	return ProtoVersion
+}
+
+// ID implements Switch interface
+func (s *Switch) ID() string {
+	var b bytes.Buffer
+
+	err := binary.Write(&b, binary.BigEndian, s.features.DatapathID)
+	if err != nil {
+		log.ErrorLog("switch/SWITCH_ID_ERR",
+			"Failed serialize datapath identifier: ", err)
+
+		return ""
+	}
+
+	id := fmt.Sprintf("%x", b.Bytes())
+	var parts []string
+
+	for i := 0; i < len(id); i += 2 {
+		parts = append(parts, string(id[i:i+2]))
+	}
+
+	return strings.Join(parts, ":")
+}
+
+// AllocateTable implements Switch interface. All mechanisms
+// share the single table of OpenFlow 1.0 switch.
+func (s *Switch) AllocateTable() (int, error) {
+	return 0, ErrTableAllocate
+}
+
+// ReleaseTable implements Switch interface.
+func (s *Switch) ReleaseTable(tableNo int) {
+}
+
+// Name implements Switch interface
+func (s *Switch) Name() (name string) {
+	s.PortIter(func(port *mech.SwitchPort) (ok bool) {
+		if ok = port.Number != uint32(P_LOCAL); !ok {
+			log.DebugLog("switch/SWITCH_NAME",
+				"Found local port name: ", port.Name)
+
+			name = port.Name
+		}
+
+		return
+	})
+
+	if name == "" {
+		log.ErrorLog("switch/SWITCH_NAME",
+			"Failed to find switch local port")
+	}
+
+	return
+}
+
+// PortIter calls specified function for all registered ports.
+func (s *Switch) PortIter(fn func(*mech.SwitchPort) bool) {
+	for _, port := range s.features.Ports {
+		if !fn(SwitchPort(port)) {
+			return
+		}
+	}
+}
+
+// PortHardwareAddr returns hardware address of the specified port.
+func (s *Switch) PortHardwareAddr(number uint32) (net.HardwareAddr, error) {
+	for _, port := range s.features.Ports {
+		if uint32(port.PortNo) == number {
+			return HardwareAddr(port), nil
+		}
+	}
+
+	return nil, errors.New("switch: port does not exist")
+}
+
+// PortList implements Switch interface
+func (s *Switch) PortList() []*mech.SwitchPort {
+	var ports []*mech.SwitchPort
+
+	s.PortIter(func(port *mech.SwitchPort) bool {
+		if port.Number != uint32(P_LOCAL) {
+			ports = append(ports, port)
+		}
+
+		return true
+	})
+
+	return ports
+}
+
+// PortByName implements Switch interface
+func (s *Switch) PortByName(name string) (p *mech.SwitchPort, err error) {
+	err = errors.New("switch: port does not exist")
+
+	s.PortIter(func(port *mech.SwitchPort) (ok bool) {
+		if ok = port.Name != name; !ok {
+			p, err = port, nil
+
+			log.DebugLog("switch/PORT_BY_NAME",
+				"Found port by name: ", name)
+		}
+
+		return
+	})
+
+	if err != nil {
+		log.ErrorLog("switch/SWITCH_BY_NAME",
+			"Failed to find switch by name: ", name)
+	}
+
+	return
+}
+
+// PortByNumber implements Switch interface
+func (s *Switch) PortByNumber(number uint32) (p *mech.SwitchPort, err error) {
+	err = errors.New("switch: port does not exist")
+
+	s.PortIter(func(port *mech.SwitchPort) (ok bool) {
+		if ok = port.Number != number; !ok {
+			p, err = port, nil
+
+			log.DebugLog("switch/PORT_BY_NUMBER",
+				"Found port by number: ", number)
+		}
+
+		return
+	})
+
+	if err != nil {
+		log.ErrorLog("switch/SWITCH_BY_NUMBER",
+			"Failed to find switch by number: ", number)
+	}
+
+	return
+}
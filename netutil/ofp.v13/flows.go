@@ -0,0 +1,190 @@
+package ofp13
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/openflow/ofp.v13"
+)
+
+var oxmNames = map[ofp.XMType]string{
+	ofp.XMT_OFB_IN_PORT:        "in_port",
+	ofp.XMT_OFB_IN_PHY_PORT:    "in_phy_port",
+	ofp.XMT_OFB_METADATA:       "metadata",
+	ofp.XMT_OFB_ETH_DST:        "eth_dst",
+	ofp.XMT_OFB_ETH_SRC:        "eth_src",
+	ofp.XMT_OFB_ETH_TYPE:       "eth_type",
+	ofp.XMT_OFB_VLAN_VID:       "vlan_vid",
+	ofp.XMT_OFB_VLAN_PCP:       "vlan_pcp",
+	ofp.XMT_OFB_IP_DSCP:        "ip_dscp",
+	ofp.XMT_OFB_IP_ECN:         "ip_ecn",
+	ofp.XMT_OFB_IP_PROTO:       "ip_proto",
+	ofp.XMT_OFB_IPV4_SRC:       "ipv4_src",
+	ofp.XMT_OFB_IPV4_DST:       "ipv4_dst",
+	ofp.XMT_OFB_TCP_SRC:        "tcp_src",
+	ofp.XMT_OFB_TCP_DST:        "tcp_dst",
+	ofp.XMT_OFB_UDP_SRC:        "udp_src",
+	ofp.XMT_OFB_UDP_DST:        "udp_dst",
+	ofp.XMT_OFB_SCTP_SRC:       "sctp_src",
+	ofp.XMT_OFB_SCTP_DST:       "sctp_dst",
+	ofp.XMT_OFB_ICMPV4_TYPE:    "icmpv4_type",
+	ofp.XMT_OFB_ICMPV4_CODE:    "icmpv4_code",
+	ofp.XMT_OFB_ARP_OP:         "arp_op",
+	ofp.XMT_OFB_ARP_SPA:        "arp_spa",
+	ofp.XMT_OFB_ARP_TPA:        "arp_tpa",
+	ofp.XMT_OFB_ARP_SHA:        "arp_sha",
+	ofp.XMT_OFB_ARP_THA:        "arp_tha",
+	ofp.XMT_OFB_IPV6_SRC:       "ipv6_src",
+	ofp.XMT_OFB_IPV6_DST:       "ipv6_dst",
+	ofp.XMT_OFB_IPV6_FLABEL:    "ipv6_flabel",
+	ofp.XMT_OFB_ICMPV6_TYPE:    "icmpv6_type",
+	ofp.XMT_OFB_ICMPV6_CODE:    "icmpv6_code",
+	ofp.XMT_OFB_IPV6_ND_TARGET: "ipv6_nd_target",
+	ofp.XMT_OFB_IPV6_ND_SLL:    "ipv6_nd_sll",
+	ofp.XMT_OFB_IPV6_ND_TLL:    "ipv6_nd_tll",
+}
+
+var portNames = map[ofp.PortNo]string{
+	ofp.P_IN_PORT:    "in_port",
+	ofp.P_TABLE:      "table",
+	ofp.P_NORMAL:     "normal",
+	ofp.P_FLOOD:      "flood",
+	ofp.P_ALL:        "all",
+	ofp.P_CONTROLLER: "controller",
+	ofp.P_LOCAL:      "local",
+	ofp.P_ANY:        "any",
+}
+
+// formatPort returns text representation of port number.
+func formatPort(port ofp.PortNo) string {
+	if name, ok := portNames[port]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("%d", port)
+}
+
+// formatValue returns text representation of OXM value.
+func formatValue(t ofp.XMType, value []byte) string {
+	switch t {
+	case ofp.XMT_OFB_ETH_DST, ofp.XMT_OFB_ETH_SRC,
+		ofp.XMT_OFB_ARP_SHA, ofp.XMT_OFB_ARP_THA,
+		ofp.XMT_OFB_IPV6_ND_SLL, ofp.XMT_OFB_IPV6_ND_TLL:
+		return net.HardwareAddr(value).String()
+	case ofp.XMT_OFB_IPV4_SRC, ofp.XMT_OFB_IPV4_DST,
+		ofp.XMT_OFB_ARP_SPA, ofp.XMT_OFB_ARP_TPA,
+		ofp.XMT_OFB_IPV6_SRC, ofp.XMT_OFB_IPV6_DST,
+		ofp.XMT_OFB_IPV6_ND_TARGET:
+		return net.IP(value).String()
+	case ofp.XMT_OFB_ETH_TYPE:
+		return fmt.Sprintf("0x%04x", new(big.Int).SetBytes(value))
+	case ofp.XMT_OFB_METADATA:
+		return fmt.Sprintf("0x%x", new(big.Int).SetBytes(value))
+	}
+
+	return new(big.Int).SetBytes(value).String()
+}
+
+// formatOXM returns text representation of OXM field.
+func formatOXM(oxm ofp.OXM) string {
+	name, ok := oxmNames[oxm.Type]
+	if !ok {
+		name = fmt.Sprintf("oxm_%d", oxm.Type)
+	}
+
+	value := formatValue(oxm.Type, oxm.Value)
+	if oxm.Mask != nil {
+		value += "/" + formatValue(oxm.Type, oxm.Mask)
+	}
+
+	return fmt.Sprintf("%s=%s", name, value)
+}
+
+// formatMatch returns text representation of match fields.
+func formatMatch(match ofp.Match) []string {
+	fields := make([]string, 0)
+
+	for _, oxm := range match.Fields {
+		fields = append(fields, formatOXM(oxm))
+	}
+
+	return fields
+}
+
+// formatAction returns text representation of flow action.
+func formatAction(action ofp.Actioner) string {
+	switch a := action.(type) {
+	case ofp.ActionOutput:
+		return "output:" + formatPort(a.Port)
+	case ofp.ActionGroup:
+		return fmt.Sprintf("group:%d", a.Group)
+	case ofp.ActionSetField:
+		return "set_field:" + formatOXM(a.Field)
+	case ofp.ActionPush:
+		return fmt.Sprintf("push:0x%04x", a.EtherType)
+	case ofp.Action:
+		switch a.Type {
+		case ofp.AT_POP_VLAN:
+			return "pop_vlan"
+		case ofp.AT_DEC_NW_TTL:
+			return "dec_nw_ttl"
+		}
+
+		return fmt.Sprintf("action_%d", a.Type)
+	}
+
+	return fmt.Sprintf("%T", action)
+}
+
+// formatInstructions returns text representation of flow instructions.
+func formatInstructions(instructions ofp.Instructions) []string {
+	names := map[ofp.InstructionType]string{
+		ofp.IT_APPLY_ACTIONS: "apply_actions",
+		ofp.IT_WRITE_ACTIONS: "write_actions",
+		ofp.IT_CLEAR_ACTIONS: "clear_actions",
+	}
+
+	texts := make([]string, 0)
+
+	for _, instruction := range instructions {
+		switch i := instruction.(type) {
+		case ofp.InstructionGotoTable:
+			texts = append(texts, fmt.Sprintf("goto_table:%d", i.Table))
+		case ofp.InstructionActions:
+			var actions []string
+			for _, action := range i.Actions {
+				actions = append(actions, formatAction(action))
+			}
+
+			texts = append(texts, fmt.Sprintf("%s(%s)",
+				names[i.Type], strings.Join(actions, ",")))
+		default:
+			texts = append(texts, fmt.Sprintf("%T", instruction))
+		}
+	}
+
+	return texts
+}
+
+// flowEntry converts ofp_flow_stats to the flow description.
+func flowEntry(stats ofp.FlowStats) mech.FlowEntry {
+	duration := time.Duration(stats.DurationSec)*time.Second +
+		time.Duration(stats.DurationNSec)*time.Nanosecond
+
+	return mech.FlowEntry{
+		Table:        int(stats.TableID),
+		Priority:     int(stats.Priority),
+		Cookie:       stats.Cookie,
+		Match:        formatMatch(stats.Match),
+		Instructions: formatInstructions(stats.Instructions),
+		PacketCount:  stats.PacketCount,
+		ByteCount:    stats.ByteCount,
+		Duration:     duration,
+		IdleTimeout:  int(stats.IdleTimeout),
+		HardTimeout:  int(stats.HardTimeout),
+	}
+}
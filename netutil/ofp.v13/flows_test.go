@@ -0,0 +1,45 @@
+package ofp13
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/netrack/openflow/ofp.v13"
+)
+
+func TestFlowEntry(t *testing.T) {
+	stats := ofp.FlowStats{
+		TableID:  1,
+		Priority: 2,
+		Match: ofp.Match{ofp.MT_OXM, []ofp.OXM{
+			{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_TYPE, []byte{0x08, 0x00}, nil},
+			{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IPV4_DST,
+				[]byte{10, 0, 0, 0}, []byte{255, 255, 255, 0}},
+		}},
+		Instructions: ofp.Instructions{
+			ofp.InstructionActions{ofp.IT_APPLY_ACTIONS, ofp.Actions{
+				ofp.Action{ofp.AT_DEC_NW_TTL},
+				ofp.ActionOutput{ofp.P_CONTROLLER, ofp.CML_NO_BUFFER},
+			}},
+			ofp.InstructionGotoTable{3},
+		},
+		DurationSec:  1,
+		DurationNSec: 500000000,
+	}
+
+	entry := flowEntry(stats)
+
+	match := []string{"eth_type=0x0800", "ipv4_dst=10.0.0.0/255.255.255.0"}
+	if !reflect.DeepEqual(entry.Match, match) {
+		t.Fatal("Invalid match representation:", entry.Match)
+	}
+
+	instructions := []string{"apply_actions(dec_nw_ttl,output:controller)", "goto_table:3"}
+	if !reflect.DeepEqual(entry.Instructions, instructions) {
+		t.Fatal("Invalid instructions representation:", entry.Instructions)
+	}
+
+	if entry.Table != 1 || entry.Priority != 2 || entry.Duration.Seconds() != 1.5 {
+		t.Fatal("Invalid flow entry:", entry)
+	}
+}
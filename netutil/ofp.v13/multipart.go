@@ -0,0 +1,170 @@
+package ofp13
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+)
+
+// MultipartTimeout is a time to wait for ofp_multipart_reply messages.
+const MultipartTimeout = 5 * time.Second
+
+var (
+	// ErrMultipartTimeout is returned when switch did not
+	// reply to ofp_multipart_request in time.
+	ErrMultipartTimeout = errors.New("ofp: multipart request timed out")
+)
+
+// multipartCall collects replies of a single request.
+type multipartCall struct {
+	// Type of the requested statistics.
+	mtype ofp.MultipartType
+
+	// Joined bodies of received replies.
+	body bytes.Buffer
+
+	done     chan bool
+	finished bool
+}
+
+// multipartClient sends ofp_multipart_request messages and collects
+// ofp_multipart_reply messages. Replies are matched with requests by
+// transaction identifier, parts of the reply are expected to be served
+// in order of their arrival.
+type multipartClient struct {
+	// Pending calls indexed by transaction identifier.
+	calls map[uint32]*multipartCall
+
+	// The last allocated transaction identifier.
+	xid uint32
+
+	// Lock for the pending calls.
+	lock sync.Mutex
+}
+
+// register allocates transaction identifier for the call.
+func (c *multipartClient) register(call *multipartCall) uint32 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.calls == nil {
+		c.calls = make(map[uint32]*multipartCall)
+	}
+
+	c.xid++
+	c.calls[c.xid] = call
+
+	return c.xid
+}
+
+// unregister removes pending call.
+func (c *multipartClient) unregister(xid uint32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.calls, xid)
+}
+
+// pending returns call of the reply, when transaction identifier of
+// the reply is unknown, the only pending call of the type is returned.
+func (c *multipartClient) pending(r *of.Request, mtype ofp.MultipartType) *multipartCall {
+	if xid, ok := r.Header.Get(of.XIDHeaderKey).(uint32); ok {
+		if call, ok := c.calls[xid]; ok && call.mtype == mtype {
+			return call
+		}
+
+		return nil
+	}
+
+	var pending *multipartCall
+	for _, call := range c.calls {
+		if call.mtype != mtype {
+			continue
+		}
+
+		// Replies to concurrent requests are indistinguishable.
+		if pending != nil {
+			return nil
+		}
+
+		pending = call
+	}
+
+	return pending
+}
+
+// Request sends ofp_multipart_request with specified body to the
+// switch and returns joined bodies of ofp_multipart_reply messages.
+func (c *multipartClient) Request(conn of.OFPConn, mtype ofp.MultipartType, body io.WriterTo) (io.Reader, error) {
+	r, err := of.NewRequest(of.T_MULTIPART_REQUEST, of.NewReader(
+		&ofp.MultipartRequest{Type: mtype, Body: body},
+	))
+
+	if err != nil {
+		log.ErrorLog("multipart/REQUEST",
+			"Failed to create ofp_multipart_request message: ", err)
+		return nil, err
+	}
+
+	call := &multipartCall{mtype: mtype, done: make(chan bool)}
+
+	xid := c.register(call)
+	defer c.unregister(xid)
+
+	r.Header.Set(of.XIDHeaderKey, xid)
+
+	if err = of.Send(conn, r); err != nil {
+		log.ErrorLog("multipart/REQUEST",
+			"Failed to send ofp_multipart_request message: ", err)
+		return nil, err
+	}
+
+	select {
+	case <-call.done:
+		// Body is not modified, once the call is finished.
+		return &call.body, nil
+	case <-time.After(MultipartTimeout):
+		log.ErrorLog("multipart/REQUEST",
+			"Multipart request timed out: ", mtype)
+		return nil, ErrMultipartTimeout
+	}
+}
+
+// replyHandler appends reply to the pending call, it has to be
+// called sequentially for replies in order of their arrival.
+func (c *multipartClient) replyHandler(rw of.ResponseWriter, r *of.Request) {
+	var reply ofp.MultipartReply
+
+	if _, err := reply.ReadFrom(r.Body); err != nil {
+		log.ErrorLog("multipart/REPLY_HANDLER",
+			"Failed to read ofp_multipart_reply message: ", err)
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Skip replies to the requests sent by others.
+	call := c.pending(r, reply.Type)
+	if call == nil || call.finished {
+		return
+	}
+
+	if _, err := call.body.ReadFrom(r.Body); err != nil {
+		log.ErrorLog("multipart/REPLY_HANDLER",
+			"Failed to read ofp_multipart_reply body: ", err)
+		return
+	}
+
+	// Statistics could be split into multiple replies.
+	if reply.Flags&ofp.MPF_REPLY_MORE == 0 {
+		call.finished = true
+		close(call.done)
+	}
+}
@@ -0,0 +1,52 @@
+package ofp13
+
+import (
+	"testing"
+
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+)
+
+func TestMultipartClientPending(t *testing.T) {
+	var c multipartClient
+
+	flows := &multipartCall{mtype: ofp.MP_FLOW}
+	ports := &multipartCall{mtype: ofp.MP_PORT_STATS}
+
+	flowsXID, portsXID := c.register(flows), c.register(ports)
+	if flowsXID == portsXID {
+		t.Fatal("Transaction identifiers must be unique:", flowsXID)
+	}
+
+	reply := func(xid interface{}) *of.Request {
+		r := &of.Request{Header: of.Header{}}
+		if xid != nil {
+			r.Header.Set(of.XIDHeaderKey, xid)
+		}
+
+		return r
+	}
+
+	if call := c.pending(reply(flowsXID), ofp.MP_FLOW); call != flows {
+		t.Fatal("Reply must be matched by transaction identifier")
+	}
+
+	if call := c.pending(reply(portsXID), ofp.MP_FLOW); call != nil {
+		t.Fatal("Reply of other type must not be matched")
+	}
+
+	if call := c.pending(reply(nil), ofp.MP_PORT_STATS); call != ports {
+		t.Fatal("The only pending call of the type must be matched")
+	}
+
+	// Replies to concurrent requests of the same type are ambiguous.
+	c.register(&multipartCall{mtype: ofp.MP_FLOW})
+	if call := c.pending(reply(nil), ofp.MP_FLOW); call != nil {
+		t.Fatal("Ambiguous reply must not be matched")
+	}
+
+	c.unregister(flowsXID)
+	if call := c.pending(reply(flowsXID), ofp.MP_FLOW); call != nil {
+		t.Fatal("Reply of finished request must not be matched")
+	}
+}
@@ -14,6 +14,9 @@ func init() {
 
 type OFPMechanism struct {
 	mech.BaseMechanism
+
+	// Client of multipart messages.
+	multipart multipartClient
 }
 
 // NewOFPMechanism creates new instance of OFPMechanism type.
@@ -29,11 +32,21 @@ func (m *OFPMechanism) Description() string {
 	return "MISSING DESCRIPTION!"
 }
 
+// Version implements VersionedMechanism interface.
+func (m *OFPMechanism) Version() string {
+	return ProtoVersion
+}
+
 // Enable implements Mechanism interface.
 func (m *OFPMechanism) Enable(c *mech.MechanismContext) {
 	m.BaseMechanism.Enable(c)
 
 	m.C.Mux.HandleFunc(of.T_ECHO_REQUEST, m.echoHandler)
+	m.C.Mux.HandleFunc(of.T_MULTIPART_REPLY, m.multipart.replyHandler)
+
+	// Make installed flows available for others.
+	m.C.Managers.Bind(new(mech.FlowInventory), m)
+	m.C.Managers.Bind(new(mech.PortStatsReader), m)
 
 	log.InfoLog("ofp/ENABLE_HOOK",
 		"Mechanism ofp1.3 enabled")
@@ -48,3 +61,67 @@ func (m *OFPMechanism) echoHandler(rw of.ResponseWriter, r *of.Request) {
 			"Failed to send ofp_echo_reply: ", err)
 	}
 }
+
+// Flows implements FlowInventory interface.
+func (m *OFPMechanism) Flows(table int) ([]mech.FlowEntry, error) {
+	tableID := ofp.TT_ALL
+	if table >= 0 {
+		tableID = ofp.Table(table)
+	}
+
+	body, err := m.multipart.Request(m.C.Switch.Conn(), ofp.MP_FLOW,
+		&ofp.FlowStatsRequest{
+			TableID:  tableID,
+			OutPort:  ofp.P_ANY,
+			OutGroup: ofp.G_ANY,
+		})
+
+	if err != nil {
+		log.ErrorLog("ofp1.3/FLOWS",
+			"Failed to request flow statistics: ", err)
+		return nil, err
+	}
+
+	var flows []ofp.FlowStats
+	if err = readFlowStats(body, &flows); err != nil {
+		log.ErrorLog("ofp1.3/FLOWS",
+			"Failed to read ofp_flow_stats values: ", err)
+		return nil, err
+	}
+
+	entries := make([]mech.FlowEntry, 0, len(flows))
+
+	for _, stats := range flows {
+		entry := flowEntry(stats)
+		entry.Mechanism, _ = mech.FlowOwnerName(m.C, entry.Table, entry.Cookie)
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// PortStats implements PortStatsReader interface.
+func (m *OFPMechanism) PortStats() ([]mech.PortCounters, error) {
+	body, err := m.multipart.Request(m.C.Switch.Conn(), ofp.MP_PORT_STATS,
+		&ofp.PortStatsRequest{PortNo: ofp.P_ANY})
+
+	if err != nil {
+		log.ErrorLog("ofp1.3/PORT_STATS",
+			"Failed to request port statistics: ", err)
+		return nil, err
+	}
+
+	var ports []ofp.PortStats
+	if err = readPortStats(body, &ports); err != nil {
+		log.ErrorLog("ofp1.3/PORT_STATS",
+			"Failed to read ofp_port_stats values: ", err)
+		return nil, err
+	}
+
+	counters := make([]mech.PortCounters, 0, len(ports))
+	for _, stats := range ports {
+		counters = append(counters, portCounters(stats))
+	}
+
+	return counters, nil
+}
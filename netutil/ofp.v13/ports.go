@@ -0,0 +1,41 @@
+package ofp13
+
+import (
+	"io"
+
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/openflow/ofp.v13"
+)
+
+// readPortStats reads ofp_port_stats values until the end of reader.
+func readPortStats(r io.Reader, ports *[]ofp.PortStats) error {
+	for {
+		var stats ofp.PortStats
+
+		_, err := stats.ReadFrom(r)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		*ports = append(*ports, stats)
+	}
+}
+
+// portCounters converts ofp_port_stats into port counters.
+func portCounters(stats ofp.PortStats) mech.PortCounters {
+	return mech.PortCounters{
+		Port:      uint32(stats.PortNo),
+		RxPackets: stats.RxPackets,
+		TxPackets: stats.TxPackets,
+		RxBytes:   stats.RxBytes,
+		TxBytes:   stats.TxBytes,
+		RxDropped: stats.RxDropped,
+		TxDropped: stats.TxDropped,
+		RxErrors:  stats.RxErrors,
+		TxErrors:  stats.TxErrors,
+	}
+}
@@ -0,0 +1,256 @@
+package ofp13
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+)
+
+// readFlowStats reads ofp_flow_stats values until the end of reader.
+func readFlowStats(r io.Reader, flows *[]ofp.FlowStats) error {
+	for {
+		var stats ofp.FlowStats
+
+		_, err := stats.ReadFrom(r)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		*flows = append(*flows, stats)
+	}
+}
+
+// flowKey returns a key, that identifies a flow in a flow table. Tables
+// are allocated to mechanisms in order of their names, so flows of the
+// mechanism are installed to the same table after reconnection.
+func flowKey(table ofp.Table, priority uint16, match ofp.Match) string {
+	var buf bytes.Buffer
+	match.WriteTo(&buf)
+
+	return fmt.Sprintf("%d/%d/%x", table, priority, buf.Bytes())
+}
+
+// flowInstructions returns binary representation of flow instructions.
+func flowInstructions(instructions ofp.Instructions) []byte {
+	var buf bytes.Buffer
+	instructions.WriteTo(&buf)
+
+	return buf.Bytes()
+}
+
+// reconcileEntry is a flow installed on the switch before boot.
+type reconcileEntry struct {
+	stats ofp.FlowStats
+
+	// Set to true, when mechanisms requested the same flow.
+	matched bool
+
+	// Set to true, when the flow could be removed by mechanisms.
+	deleted bool
+}
+
+// reconcileConn intercepts ofp_flow_mod messages sent by mechanisms
+// after switch boot. Flows, that already installed on the switch
+// are not sent again, flushes of mechanism tables are postponed till
+// the end of reconciliation, when all stale flows are removed.
+type reconcileConn struct {
+	of.OFPConn
+
+	// Flows installed on the switch.
+	flows map[string]*reconcileEntry
+
+	// Number of flow modifications, that were not sent.
+	skipped int
+
+	done bool
+	lock sync.Mutex
+}
+
+func newReconcileConn(c of.OFPConn, flows []ofp.FlowStats) *reconcileConn {
+	entries := make(map[string]*reconcileEntry)
+
+	for _, stats := range flows {
+		key := flowKey(stats.TableID, stats.Priority, stats.Match)
+		entries[key] = &reconcileEntry{stats: stats}
+	}
+
+	return &reconcileConn{OFPConn: c, flows: entries}
+}
+
+// Send implements of.OFPConn interface.
+func (c *reconcileConn) Send(r *of.Request) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.done || r.Header.Get(of.TypeHeaderKey) != of.T_FLOW_MOD {
+		return c.OFPConn.Send(r)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.ErrorLog("reconcile/SEND_FLOW_MOD",
+			"Failed to read ofp_flow_mod message: ", err)
+		return err
+	}
+
+	// Restore consumed message body.
+	r.Body = bytes.NewReader(body)
+
+	var flowMod ofp.FlowMod
+	if _, err = flowMod.ReadFrom(bytes.NewReader(body)); err != nil {
+		log.ErrorLog("reconcile/SEND_FLOW_MOD",
+			"Failed to parse ofp_flow_mod message: ", err)
+		return err
+	}
+
+	if !c.reconcile(&flowMod) {
+		c.skipped++
+		return nil
+	}
+
+	return c.OFPConn.Send(r)
+}
+
+// tableFlush returns true, when flow modification removes all
+// flows from the table allocated to a mechanism.
+func tableFlush(flowMod *ofp.FlowMod) bool {
+	return flowMod.Command == ofp.FC_DELETE &&
+		flowMod.TableID != 0 && flowMod.TableID != ofp.TT_ALL &&
+		flowMod.CookieMask == 0 && len(flowMod.Match.Fields) == 0
+}
+
+// reconcile accounts requested flow modification, false
+// is returned, when it should not be sent to the switch.
+func (c *reconcileConn) reconcile(flowMod *ofp.FlowMod) bool {
+	switch flowMod.Command {
+	case ofp.FC_ADD:
+	case ofp.FC_DELETE, ofp.FC_DELETE_STRICT:
+		// Mechanisms flush tables before using them,
+		// but only stale flows should be removed.
+		if tableFlush(flowMod) {
+			return false
+		}
+
+		c.delete(flowMod)
+		return true
+	default:
+		return true
+	}
+
+	key := flowKey(flowMod.TableID, flowMod.Priority, flowMod.Match)
+
+	entry, ok := c.flows[key]
+	if !ok {
+		// Flow is missing on the switch.
+		return true
+	}
+
+	// Flow with the same match will be replaced anyway.
+	entry.matched = true
+
+	if entry.deleted {
+		// Flow could be removed by mechanism.
+		return true
+	}
+
+	// Cookies are used to dispatch ofp_packet_in messages,
+	// so flows with outdated cookies have to be replaced.
+	stale := entry.stats.Cookie != flowMod.Cookie ||
+		entry.stats.Flags != flowMod.Flags ||
+		entry.stats.IdleTimeout != flowMod.IdleTimeout ||
+		entry.stats.HardTimeout != flowMod.HardTimeout ||
+		!bytes.Equal(flowInstructions(entry.stats.Instructions),
+			flowInstructions(flowMod.Instructions))
+
+	return stale
+}
+
+// delete accounts flows removed by the flow modification, so
+// they are installed again, when requested by mechanisms.
+func (c *reconcileConn) delete(flowMod *ofp.FlowMod) {
+	if flowMod.Command == ofp.FC_DELETE_STRICT {
+		delete(c.flows, flowKey(flowMod.TableID, flowMod.Priority, flowMod.Match))
+		return
+	}
+
+	// Flows not matching the request are still removed as stale.
+	for _, entry := range c.flows {
+		if flowMod.TableID == ofp.TT_ALL || flowMod.TableID == entry.stats.TableID {
+			entry.deleted = true
+		}
+	}
+}
+
+// stale returns installed flows, that were not requested by mechanisms.
+func (c *reconcileConn) stale() []ofp.FlowStats {
+	var flows []ofp.FlowStats
+
+	for _, entry := range c.flows {
+		if !entry.matched {
+			flows = append(flows, entry.stats)
+		}
+	}
+
+	return flows
+}
+
+// Reconcile removes stale flows from the switch and
+// stops interception of ofp_flow_mod messages.
+func (c *reconcileConn) Reconcile() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.done {
+		return nil
+	}
+
+	c.done = true
+
+	var requests []*of.Request
+
+	for _, stats := range c.stale() {
+		r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp.FlowMod{
+			Command:  ofp.FC_DELETE_STRICT,
+			TableID:  stats.TableID,
+			Priority: stats.Priority,
+			BufferID: ofp.NO_BUFFER,
+			OutPort:  ofp.P_ANY,
+			OutGroup: ofp.G_ANY,
+			Match:    stats.Match,
+		}))
+
+		if err != nil {
+			log.ErrorLog("reconcile/RECONCILE",
+				"Failed to create ofp_flow_mod request: ", err)
+			return err
+		}
+
+		requests = append(requests, r)
+	}
+
+	log.InfoLogf("reconcile/RECONCILE",
+		"Flows reconciled: %d skipped, %d stale",
+		c.skipped, len(requests))
+
+	if len(requests) == 0 {
+		return nil
+	}
+
+	err := of.Send(c.OFPConn, requests...)
+	if err != nil {
+		log.ErrorLog("reconcile/RECONCILE",
+			"Failed to remove stale flows: ", err)
+	}
+
+	return err
+}
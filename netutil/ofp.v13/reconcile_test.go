@@ -0,0 +1,250 @@
+package ofp13
+
+import (
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/netrack/netrack/database"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+	"github.com/netrack/openflow/ofp.v13/ofputil"
+)
+
+func TestReconcileConn(t *testing.T) {
+	c := newReconcileConn(nil, []ofp.FlowStats{
+		{TableID: 0, Priority: 0},
+		{TableID: 1, Priority: 2, Cookie: 5},
+		{TableID: 1, Priority: 3, Cookie: 6},
+		{TableID: 2, Priority: 4, Cookie: 8},
+	})
+
+	tests := []struct {
+		flowMod ofp.FlowMod
+		send    bool
+	}{
+		// Installed flow.
+		{ofp.FlowMod{Command: ofp.FC_ADD, TableID: 0, Priority: 0}, false},
+		// Installed flow with outdated cookie.
+		{ofp.FlowMod{Command: ofp.FC_ADD, TableID: 1, Priority: 2, Cookie: 7}, true},
+		// Missing flow.
+		{ofp.FlowMod{Command: ofp.FC_ADD, TableID: 2, Priority: 1}, true},
+		// Table flush.
+		{ofp.FlowMod{Command: ofp.FC_DELETE, TableID: 1}, false},
+		// Flow modification.
+		{ofp.FlowMod{Command: ofp.FC_MODIFY, TableID: 1, Priority: 3}, true},
+		// Flow removal.
+		{ofp.FlowMod{Command: ofp.FC_DELETE_STRICT, TableID: 2, Priority: 4}, true},
+		// Removed flow.
+		{ofp.FlowMod{Command: ofp.FC_ADD, TableID: 2, Priority: 4, Cookie: 8}, true},
+		// Removal of all flows.
+		{ofp.FlowMod{Command: ofp.FC_DELETE, TableID: ofp.TT_ALL}, true},
+		// Flush of the shared table.
+		{ofp.FlowMod{Command: ofp.FC_DELETE, TableID: 0}, true},
+	}
+
+	for i, test := range tests {
+		if send := c.reconcile(&test.flowMod); send != test.send {
+			t.Fatalf("Invalid reconciliation of %d flow: %t", i, send)
+		}
+	}
+
+	stale := c.stale()
+	if len(stale) != 1 {
+		t.Fatal("Invalid number of stale flows:", len(stale))
+	}
+
+	if stale[0].TableID != 1 || stale[0].Priority != 3 {
+		t.Fatal("Invalid stale flow:", stale[0])
+	}
+}
+
+// reconcileTestConn replays switch replies and records
+// sent flow modifications.
+type reconcileTestConn struct {
+	replies []*of.Request
+	sent    []ofp.FlowMod
+	lock    sync.Mutex
+}
+
+func (c *reconcileTestConn) Receive() (*of.Request, error) {
+	c.lock.Lock()
+	if len(c.replies) == 0 {
+		c.lock.Unlock()
+
+		// Keep the switch connected till the end of the test.
+		select {}
+	}
+
+	r := c.replies[0]
+	c.replies = c.replies[1:]
+	c.lock.Unlock()
+
+	return r, nil
+}
+
+func (c *reconcileTestConn) Send(r *of.Request) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if r.Header.Get(of.TypeHeaderKey) != of.T_FLOW_MOD {
+		return nil
+	}
+
+	var flowMod ofp.FlowMod
+	if _, err := flowMod.ReadFrom(r.Body); err != nil {
+		return err
+	}
+
+	c.sent = append(c.sent, flowMod)
+	return nil
+}
+
+func (c *reconcileTestConn) Flush() error {
+	return nil
+}
+
+func (c *reconcileTestConn) Close() error {
+	return nil
+}
+
+func (c *reconcileTestConn) reply(t *testing.T, rtype of.Type, body ...io.WriterTo) {
+	r, err := of.NewRequest(rtype, of.NewReader(body...))
+	if err != nil {
+		t.Fatal("Failed to create reply:", err)
+	}
+
+	c.replies = append(c.replies, r)
+}
+
+// reconcilePersister accepts all database modifications.
+type reconcilePersister struct {
+	db.Persister
+}
+
+func (p *reconcilePersister) Transaction(fn func(db.ModelPersister) error) error {
+	return fn(p)
+}
+
+func (p *reconcilePersister) Lock(m db.Model, id string, s interface{}) error {
+	return nil
+}
+
+func (p *reconcilePersister) Update(m db.Model, id string, s interface{}) error {
+	return nil
+}
+
+// reconcileMechanism flushes allocated table, installs the
+// first flow and replaces the third flow of the table.
+type reconcileMechanism struct {
+	mech.BaseMechanism
+	t *testing.T
+}
+
+func (m *reconcileMechanism) Name() string {
+	return "reconcile"
+}
+
+func (m *reconcileMechanism) Description() string {
+	return "Reconciliation test"
+}
+
+func (m *reconcileMechanism) Activate() {
+	m.BaseMechanism.Activate()
+
+	tableNo, err := m.C.Switch.AllocateTable()
+	if err != nil {
+		m.t.Fatal("Failed to allocate table:", err)
+	}
+
+	flowAdd, _ := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp.FlowMod{
+		Command:  ofp.FC_ADD,
+		TableID:  ofp.Table(tableNo),
+		Priority: 1,
+		Cookie:   1,
+		BufferID: ofp.NO_BUFFER,
+	}))
+
+	flowDelete, _ := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp.FlowMod{
+		Command:  ofp.FC_DELETE_STRICT,
+		TableID:  ofp.Table(tableNo),
+		Priority: 3,
+		BufferID: ofp.NO_BUFFER,
+		OutPort:  ofp.P_ANY,
+		OutGroup: ofp.G_ANY,
+	}))
+
+	flowReplace, _ := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&ofp.FlowMod{
+		Command:  ofp.FC_ADD,
+		TableID:  ofp.Table(tableNo),
+		Priority: 3,
+		Cookie:   3,
+		BufferID: ofp.NO_BUFFER,
+	}))
+
+	err = of.Send(m.C.Switch.Conn(), ofputil.TableFlush(ofp.Table(tableNo)),
+		flowAdd, flowDelete, flowReplace)
+	if err != nil {
+		m.t.Fatal("Failed to send flows:", err)
+	}
+}
+
+func TestSwitchBootReconcile(t *testing.T) {
+	defer func(persister db.Persister) {
+		db.DefaultDB = persister
+	}(db.DefaultDB)
+
+	db.DefaultDB = &reconcilePersister{}
+
+	mech.RegisterExtensionMechanism("reconcile",
+		mech.ExtensionMechanismConstructorFunc(func() mech.ExtensionMechanism {
+			return &reconcileMechanism{t: t}
+		}))
+
+	conn := &reconcileTestConn{}
+	conn.reply(t, of.T_HELLO)
+	conn.reply(t, of.T_FEATURES_REPLY, &ofp.SwitchFeatures{DatapathID: 1, NumTables: 4})
+	conn.reply(t, of.T_MULTIPART_REPLY,
+		&ofp.MultipartReply{Type: ofp.MP_PORT_DESC},
+		&ofp.Port{PortNo: ofp.P_LOCAL, Name: []byte("br0")})
+	conn.reply(t, of.T_MULTIPART_REPLY,
+		&ofp.MultipartReply{Type: ofp.MP_FLOW},
+		// Black-hole rule.
+		&ofp.FlowStats{TableID: 0, Priority: 0},
+		&ofp.FlowStats{TableID: 1, Priority: 1, Cookie: 1},
+		&ofp.FlowStats{TableID: 1, Priority: 2, Cookie: 2},
+		&ofp.FlowStats{TableID: 1, Priority: 3, Cookie: 3})
+
+	manager := &mech.SwitchManager{BootMode: mech.BootReconcile}
+	if err := manager.CreateSwitch(conn); err != nil {
+		t.Fatal("Failed to create switch:", err)
+	}
+
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+
+	// Table flush and installed flows are not sent, removed
+	// flow is installed again, the second flow is stale.
+	tests := []struct {
+		command  ofp.FlowModCommand
+		priority uint16
+	}{
+		{ofp.FC_DELETE_STRICT, 3},
+		{ofp.FC_ADD, 3},
+		{ofp.FC_DELETE_STRICT, 2},
+	}
+
+	if len(conn.sent) != len(tests) {
+		t.Fatal("Invalid number of flow modifications:", conn.sent)
+	}
+
+	for i, test := range tests {
+		flowMod := conn.sent[i]
+
+		if flowMod.Command != test.command || flowMod.TableID != 1 ||
+			flowMod.Priority != test.priority {
+			t.Fatalf("Invalid %d flow modification: %v", i, flowMod)
+		}
+	}
+}
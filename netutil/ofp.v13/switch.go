@@ -15,6 +15,9 @@ import (
 	"github.com/netrack/openflow/ofp.v13/ofputil"
 )
 
+// ProtoVersion is a protocol version handled by the switch.
+const ProtoVersion = "OFP/1.3"
+
 var (
 	// ErrTableAllocate is returned when all tables are allocated.
 	ErrTableAllocate = errors.New("Switch: all tables are allocated")
@@ -22,7 +25,7 @@ var (
 
 func init() {
 	constructor := mech.SwitchConstructorFunc(NewSwitch)
-	mech.RegisterSwitch("OFP/1.3", constructor)
+	mech.RegisterSwitch(ProtoVersion, constructor)
 }
 
 func SwitchPort(port ofp.Port) *mech.SwitchPort {
@@ -49,8 +52,14 @@ type Switch struct {
 	// Switch tables allocation.
 	tables []int
 
+	// Connection used during flows reconciliation.
+	reconciler *reconcileConn
+
 	// Lock for tables.
 	lock sync.Mutex
+
+	// Lock for ports.
+	portLock sync.RWMutex
 }
 
 // NewSwitch returns new instance of a Switch.
@@ -60,6 +69,76 @@ func NewSwitch() mech.Switch {
 
 // Boot implements Switch interface
 func (s *Switch) Boot(c of.OFPConn) error {
+	return s.boot(c, nil)
+}
+
+// BootReconcile implements ReconcilingSwitch interface.
+func (s *Switch) BootReconcile(c of.OFPConn) error {
+	var flows []ofp.FlowStats
+
+	if err := s.boot(c, &flows); err != nil {
+		return err
+	}
+
+	log.DebugLogf("switch/SWITCH_BOOT_RECONCILE",
+		"Received %d installed flows", len(flows))
+
+	// Intercept flows, installed by mechanisms.
+	s.reconciler = newReconcileConn(c, flows)
+	s.conn = s.reconciler
+
+	return nil
+}
+
+// Reconcile implements ReconcilingSwitch interface.
+func (s *Switch) Reconcile() error {
+	if s.reconciler == nil {
+		return nil
+	}
+
+	// Black-hole rule is also a subject of reconciliation, it is
+	// sent only here, since reconciliation is completed only by
+	// the master instance, so slave never modifies the switch.
+	err := of.Send(s.reconciler, ofputil.FlowDrop(0))
+	if err != nil {
+		log.ErrorLog("switch/SWITCH_RECONCILE",
+			"Failed to send black-hole rule: ", err)
+		return err
+	}
+
+	return s.reconciler.Reconcile()
+}
+
+// RequestRole implements RoleSwitch interface.
+func (s *Switch) RequestRole(role mech.Role, generation uint64) error {
+	roles := map[mech.Role]ofp.ControllerRole{
+		mech.RoleEqual:  ofp.CR_ROLE_EQUAL,
+		mech.RoleMaster: ofp.CR_ROLE_MASTER,
+		mech.RoleSlave:  ofp.CR_ROLE_SLAVE,
+	}
+
+	r, err := of.NewRequest(of.T_ROLE_REQUEST, of.NewReader(&ofp.RoleRequest{
+		Role:         roles[role],
+		GenerationID: generation,
+	}))
+
+	if err != nil {
+		log.ErrorLog("switch/REQUEST_ROLE",
+			"Failed to create ofp_role_request message: ", err)
+		return err
+	}
+
+	if err = of.Send(s.conn, r); err != nil {
+		log.ErrorLog("switch/REQUEST_ROLE",
+			"Failed to send ofp_role_request message: ", err)
+	}
+
+	return err
+}
+
+// boot performs switch handshake, when flows is not nil, installed
+// flows are saved into it, otherwise all flows are flushed.
+func (s *Switch) boot(c of.OFPConn, flows *[]ofp.FlowStats) error {
 	// Save connection instance
 	s.conn = c
 
@@ -88,17 +167,43 @@ func (s *Switch) Boot(c of.OFPConn) error {
 		return err
 	}
 
-	err = of.Send(c,
-		ofpHello,
-		ofpFeatures,
-		ofpMultipart,
-		// Clear 0 table first
-		ofputil.TableFlush(0),
-		// Write black-hole rule with the lowest priority.
-		// This rule prevents flooding of the controller with
-		// dumb ofp_packet_in messages.
-		ofputil.FlowDrop(0),
-	)
+	requests := []*of.Request{ofpHello, ofpFeatures, ofpMultipart}
+
+	// Wait for ofp_features_reply and ofp_port list.
+	count := 2
+
+	if flows != nil {
+		// Send ofp_multipart_request to retrieve installed flows.
+		body = of.NewReader(&ofp.MultipartRequest{
+			Type: ofp.MP_FLOW,
+			Body: &ofp.FlowStatsRequest{
+				TableID:  ofp.TT_ALL,
+				OutPort:  ofp.P_ANY,
+				OutGroup: ofp.G_ANY,
+			},
+		})
+
+		ofpFlows, err := of.NewRequest(of.T_MULTIPART_REQUEST, body)
+		if err != nil {
+			log.ErrorLog("switch/SWITCH_BOOT_ERR",
+				"Failed to create OpenFlow Flow Statistics request: ", err)
+			return err
+		}
+
+		requests = append(requests, ofpFlows)
+		count++
+	} else {
+		requests = append(requests,
+			// Clear 0 table first
+			ofputil.TableFlush(0),
+			// Write black-hole rule with the lowest priority.
+			// This rule prevents flooding of the controller with
+			// dumb ofp_packet_in messages.
+			ofputil.FlowDrop(0),
+		)
+	}
+
+	err = of.Send(c, requests...)
 
 	if err != nil {
 		log.ErrorLog("switch/SWITCH_BOOT_SEND_ERR",
@@ -106,7 +211,7 @@ func (s *Switch) Boot(c of.OFPConn) error {
 		return err
 	}
 
-	errCh, doneCh := make(chan error, 2), make(chan bool, 2)
+	errCh, doneCh := make(chan error, count), make(chan bool, count)
 
 	done := func() {
 		doneCh <- true
@@ -136,8 +241,6 @@ func (s *Switch) Boot(c of.OFPConn) error {
 	multipartHandler := func(rw of.ResponseWriter, r *of.Request) {
 		var packet ofp.MultipartReply
 
-		defer done()
-
 		if _, err := of.ReadAllFrom(r.Body, &packet); err != nil {
 			log.ErrorLog("switch/SWITCH_BOOT_ERR",
 				"Failed to read ofp_multipart_reply message: ", err)
@@ -146,6 +249,24 @@ func (s *Switch) Boot(c of.OFPConn) error {
 			return
 		}
 
+		if packet.Type == ofp.MP_FLOW {
+			// Flow statistics could be split into multiple replies.
+			if packet.Flags&ofp.MPF_REPLY_MORE == 0 {
+				defer done()
+			}
+
+			if err := readFlowStats(r.Body, flows); err != nil {
+				log.ErrorLog("switch/SWITCH_BOOT_ERR",
+					"Failed to read ofp_flow_stats values: ", err)
+
+				errCh <- err
+			}
+
+			return
+		}
+
+		defer done()
+
 		if _, err := of.ReadAllFrom(r.Body, &s.ports); err != nil {
 			log.ErrorLog("switch/SWITCH_BOOT_ERR",
 				"Failed to read ofp_port values: ", err)
@@ -182,7 +303,7 @@ func (s *Switch) Boot(c of.OFPConn) error {
 		}
 	}
 
-	go run(2, func() error {
+	go run(count, func() error {
 		r, err := c.Receive()
 		if err != nil {
 			log.ErrorLog("switch/SWITCH_BOOT_ERR",
@@ -211,6 +332,11 @@ func (s *Switch) Conn() of.OFPConn {
 	return s.conn
 }
 
+// Version implements Switch interface
+func (s *Switch) Version() string {
+	return ProtoVersion
+}
+
 // ID implements Switch interface
 func (s *Switch) ID() string {
 	var b bytes.Buffer
@@ -292,13 +418,81 @@ func (s *Switch) Name() (name string) {
 
 // PortIter calls specified function for all registered ports.
 func (s *Switch) PortIter(fn func(*mech.SwitchPort) bool) {
-	for _, port := range s.ports {
+	s.portLock.RLock()
+	ports := make(ofp.Ports, len(s.ports))
+	copy(ports, s.ports)
+	s.portLock.RUnlock()
+
+	for _, port := range ports {
 		if !fn(SwitchPort(port)) {
 			return
 		}
 	}
 }
 
+// portUp returns true, when port is administratively and physically up.
+func portUp(port ofp.Port) bool {
+	return port.Config&ofp.PC_PORT_DOWN == 0 &&
+		port.State&ofp.PS_LINK_DOWN == 0
+}
+
+// UpdatePort implements PortStatusSwitch interface.
+func (s *Switch) UpdatePort(r *of.Request) (mech.PortEvent, error) {
+	var status ofp.PortStatus
+
+	if _, err := status.ReadFrom(r.Body); err != nil {
+		log.ErrorLog("switch/UPDATE_PORT",
+			"Failed to read ofp_port_status message: ", err)
+		return mech.PortEvent{}, err
+	}
+
+	return s.updatePort(status), nil
+}
+
+// updatePort applies port status to the list of switch ports.
+func (s *Switch) updatePort(status ofp.PortStatus) mech.PortEvent {
+	s.portLock.Lock()
+	defer s.portLock.Unlock()
+
+	event := mech.PortEvent{Port: SwitchPort(status.Port)}
+
+	index := -1
+	for i, port := range s.ports {
+		if port.PortNo == status.Port.PortNo {
+			index = i
+			break
+		}
+	}
+
+	switch {
+	case status.Reason == ofp.PR_DELETE:
+		event.Type = mech.PortDeleted
+
+		if index >= 0 {
+			s.ports = append(s.ports[:index], s.ports[index+1:]...)
+		}
+
+	case index < 0:
+		// Modification of unknown port is treated as addition.
+		event.Type = mech.PortAdded
+		s.ports = append(s.ports, status.Port)
+
+	default:
+		event.Type = mech.PortModified
+
+		if up := portUp(status.Port); up != portUp(s.ports[index]) {
+			event.Type = mech.PortDown
+			if up {
+				event.Type = mech.PortUp
+			}
+		}
+
+		s.ports[index] = status.Port
+	}
+
+	return event
+}
+
 // PortList implements Switch interface
 func (s *Switch) PortList() []*mech.SwitchPort {
 	var ports []*mech.SwitchPort
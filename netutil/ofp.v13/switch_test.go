@@ -0,0 +1,48 @@
+package ofp13
+
+import (
+	"testing"
+
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/openflow/ofp.v13"
+)
+
+func TestSwitchUpdatePort(t *testing.T) {
+	s := &Switch{ports: ofp.Ports{
+		{PortNo: 1, Name: []byte("eth1")},
+		{PortNo: 2, Name: []byte("eth2")},
+	}}
+
+	tests := []struct {
+		status ofp.PortStatus
+		event  mech.PortEventType
+		ports  int
+	}{
+		{ofp.PortStatus{ofp.PR_ADD, ofp.Port{PortNo: 3, Name: []byte("eth3")}}, mech.PortAdded, 3},
+		{ofp.PortStatus{ofp.PR_MODIFY, ofp.Port{PortNo: 1, Name: []byte("eth1"),
+			State: ofp.PS_LINK_DOWN}}, mech.PortDown, 3},
+		{ofp.PortStatus{ofp.PR_MODIFY, ofp.Port{PortNo: 1, Name: []byte("eth1"),
+			State: ofp.PS_LINK_DOWN}}, mech.PortModified, 3},
+		{ofp.PortStatus{ofp.PR_MODIFY, ofp.Port{PortNo: 1, Name: []byte("eth1")}}, mech.PortUp, 3},
+		{ofp.PortStatus{ofp.PR_DELETE, ofp.Port{PortNo: 2, Name: []byte("eth2")}}, mech.PortDeleted, 2},
+	}
+
+	for i, test := range tests {
+		event := s.updatePort(test.status)
+		if event.Type != test.event {
+			t.Fatalf("Invalid event of %d port status: %s", i, event.Type)
+		}
+
+		if len(s.ports) != test.ports {
+			t.Fatalf("Invalid number of ports after %d port status: %d", i, len(s.ports))
+		}
+	}
+
+	if _, err := s.PortByName("eth2"); err == nil {
+		t.Fatal("Deleted port must not be found")
+	}
+
+	if port, err := s.PortByName("eth3"); err != nil || port.Number != 3 {
+		t.Fatal("Added port must be found:", port, err)
+	}
+}
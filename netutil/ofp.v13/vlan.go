@@ -0,0 +1,38 @@
+package ofp13
+
+import (
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+)
+
+// vlanEtherType is a tag protocol identifier of 802.1Q header.
+const vlanEtherType = 0x8100
+
+// VLANMatch returns match field of frames of the VLAN, untagged
+// frames are matched when VLAN identifier is zero.
+func VLANMatch(vlan uint16) ofp.OXM {
+	vid := vlan
+	if vlan != 0 {
+		vid |= ofp.VID_PRESENT
+	}
+
+	return ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_VLAN_VID, of.Bytes(vid), nil}
+}
+
+// VLANActions returns actions, that retag frame received from the
+// ingress VLAN to send it to the egress VLAN, zero VLAN identifier
+// is used for untagged frames.
+func VLANActions(ingress, egress uint16) ofp.Actions {
+	setVID := ofp.ActionSetField{VLANMatch(egress)}
+
+	switch {
+	case ingress == egress:
+		return nil
+	case egress == 0:
+		return ofp.Actions{ofp.Action{ofp.AT_POP_VLAN}}
+	case ingress == 0:
+		return ofp.Actions{ofp.ActionPush{ofp.AT_PUSH_VLAN, vlanEtherType}, setVID}
+	}
+
+	return ofp.Actions{setVID}
+}
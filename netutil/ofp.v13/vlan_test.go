@@ -0,0 +1,27 @@
+package ofp13
+
+import (
+	"testing"
+
+	"github.com/netrack/openflow/ofp.v13"
+)
+
+func TestVLANActions(t *testing.T) {
+	if actions := VLANActions(10, 10); len(actions) != 0 {
+		t.Fatal("Frame of the same VLAN must not be retagged:", actions)
+	}
+
+	actions := VLANActions(10, 0)
+	if len(actions) != 1 || actions[0] != (ofp.Action{ofp.AT_POP_VLAN}) {
+		t.Fatal("Tag must be removed for untagged egress:", actions)
+	}
+
+	actions = VLANActions(0, 20)
+	if len(actions) != 2 || actions[0] != (ofp.ActionPush{ofp.AT_PUSH_VLAN, 0x8100}) {
+		t.Fatal("Tag must be pushed for tagged egress:", actions)
+	}
+
+	if actions = VLANActions(10, 20); len(actions) != 1 {
+		t.Fatal("Tag must be rewritten for another VLAN:", actions)
+	}
+}
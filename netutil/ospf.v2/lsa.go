@@ -0,0 +1,347 @@
+package ospf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+)
+
+const (
+	// MaxAge is an age in seconds of flushed LSAs.
+	MaxAge = 3600
+
+	// MaxAgeDiff is a maximum difference in seconds between
+	// ages of instances of the same LSA considered equal.
+	MaxAgeDiff = 900
+
+	// LSRefreshTime is an age in seconds of self-originated LSAs,
+	// after which they are originated again.
+	LSRefreshTime = 1800
+
+	// InitialSeq is a sequence number of the first LSA instance.
+	InitialSeq int32 = -0x7fffffff
+
+	// MaxSeq is a maximum sequence number of LSA.
+	MaxSeq int32 = 0x7fffffff
+)
+
+// LSType is a type of LSA, only router and network
+// LSAs are originated within the single area.
+type LSType uint8
+
+const (
+	RouterLSA  LSType = 1
+	NetworkLSA LSType = 2
+)
+
+func (t LSType) String() string {
+	switch t {
+	case RouterLSA:
+		return "router"
+	case NetworkLSA:
+		return "network"
+	}
+
+	return "unknown"
+}
+
+// LinkType is a type of the router link.
+type LinkType uint8
+
+const (
+	PointToPointLink LinkType = 1
+	TransitLink      LinkType = 2
+	StubLink         LinkType = 3
+)
+
+const (
+	// lsaHeaderLen is a length of LSA header.
+	lsaHeaderLen = 20
+
+	// linkLen is a length of the router link without TOS metrics.
+	linkLen = 12
+)
+
+// LSAKey identifies LSA in the link state database.
+type LSAKey struct {
+	Type      LSType
+	ID        ID
+	AdvRouter ID
+}
+
+// LSAHeader is a header of link state advertisement.
+type LSAHeader struct {
+	// Time in seconds since the LSA was originated.
+	Age uint16
+
+	// Optional capabilities of the originator.
+	Options uint8
+
+	// Type of the advertisement.
+	Type LSType
+
+	// Link state identifier.
+	ID ID
+
+	// Router identifier of the originator.
+	AdvRouter ID
+
+	// Sequence number of the instance.
+	Seq int32
+
+	// Fletcher checksum of the advertisement except age field.
+	Checksum uint16
+
+	// Length of the advertisement including header.
+	Length uint16
+}
+
+// Key returns key of the LSA in the link state database.
+func (h *LSAHeader) Key() LSAKey {
+	return LSAKey{h.Type, h.ID, h.AdvRouter}
+}
+
+// Compare returns positive number, when header describes more
+// recent instance of LSA, than other header, negative number, when
+// it describes less recent instance and zero for the same instance.
+func (h *LSAHeader) Compare(other *LSAHeader) int {
+	switch {
+	case h.Seq != other.Seq:
+		if h.Seq > other.Seq {
+			return 1
+		}
+		return -1
+	case h.Checksum != other.Checksum:
+		if h.Checksum > other.Checksum {
+			return 1
+		}
+		return -1
+	case (h.Age == MaxAge) != (other.Age == MaxAge):
+		if h.Age == MaxAge {
+			return 1
+		}
+		return -1
+	case h.Age+MaxAgeDiff < other.Age:
+		return 1
+	case other.Age+MaxAgeDiff < h.Age:
+		return -1
+	}
+
+	return 0
+}
+
+func (h *LSAHeader) marshal() []byte {
+	b := make([]byte, lsaHeaderLen)
+
+	binary.BigEndian.PutUint16(b[0:2], h.Age)
+	b[2], b[3] = h.Options, uint8(h.Type)
+	binary.BigEndian.PutUint32(b[4:8], uint32(h.ID))
+	binary.BigEndian.PutUint32(b[8:12], uint32(h.AdvRouter))
+	binary.BigEndian.PutUint32(b[12:16], uint32(h.Seq))
+	binary.BigEndian.PutUint16(b[16:18], h.Checksum)
+	binary.BigEndian.PutUint16(b[18:20], h.Length)
+
+	return b
+}
+
+func (h *LSAHeader) unmarshal(b []byte) {
+	h.Age = binary.BigEndian.Uint16(b[0:2])
+	h.Options, h.Type = b[2], LSType(b[3])
+	h.ID = ID(binary.BigEndian.Uint32(b[4:8]))
+	h.AdvRouter = ID(binary.BigEndian.Uint32(b[8:12]))
+	h.Seq = int32(binary.BigEndian.Uint32(b[12:16]))
+	h.Checksum = binary.BigEndian.Uint16(b[16:18])
+	h.Length = binary.BigEndian.Uint16(b[18:20])
+}
+
+// LSA is a link state advertisement.
+type LSA struct {
+	LSAHeader
+
+	// Type specific contents of the advertisement.
+	Body []byte
+}
+
+// fletcher returns Fletcher checksum of the bytes, that
+// should be placed at the offset to make checksum valid.
+func fletcher(b []byte, offset int) uint16 {
+	var c0, c1 int
+
+	for i, v := range b {
+		if i == offset || i == offset+1 {
+			v = 0
+		}
+
+		c0 = (c0 + int(v)) % 255
+		c1 = (c1 + c0) % 255
+	}
+
+	x := ((len(b)-offset-1)*c0 - c1) % 255
+	if x <= 0 {
+		x += 255
+	}
+
+	y := 510 - c0 - x
+	if y > 255 {
+		y -= 255
+	}
+
+	return uint16(x)<<8 | uint16(y)
+}
+
+// Sum updates length and checksum of the advertisement.
+func (l *LSA) Sum() {
+	l.Length = uint16(lsaHeaderLen + len(l.Body))
+
+	// Age field is not covered by checksum.
+	b := l.marshal()
+	l.Checksum = fletcher(b[2:], 14)
+}
+
+// Valid reports whether checksum of the advertisement is valid.
+func (l *LSA) Valid() bool {
+	b := l.marshal()
+	return fletcher(b[2:], 14) == l.Checksum
+}
+
+func (l *LSA) marshal() []byte {
+	return append(l.LSAHeader.marshal(), l.Body...)
+}
+
+func (l *LSA) unmarshal(b []byte) (int, error) {
+	if len(b) < lsaHeaderLen {
+		return 0, ErrFormat
+	}
+
+	l.LSAHeader.unmarshal(b)
+
+	n := int(l.Length)
+	if n < lsaHeaderLen || n > len(b) {
+		return 0, ErrFormat
+	}
+
+	l.Body = append([]byte(nil), b[lsaHeaderLen:n]...)
+	return n, nil
+}
+
+// RouterLink is a link of the router LSA.
+type RouterLink struct {
+	// Type of the link.
+	Type LinkType
+
+	// Identifier of the object the link connects to: router
+	// identifier of the neighbor, interface address of the
+	// designated router or network address of the stub network.
+	ID ID
+
+	// Interface address of the router or network
+	// mask of the stub network.
+	Data ID
+
+	// Cost of the link.
+	Metric uint16
+}
+
+// NewRouterLSA creates a new router LSA with the specified links.
+func NewRouterLSA(router ID, seq int32, links []RouterLink) LSA {
+	b := make([]byte, 4, 4+len(links)*linkLen)
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(links)))
+
+	for _, link := range links {
+		l := make([]byte, linkLen)
+		binary.BigEndian.PutUint32(l[0:4], uint32(link.ID))
+		binary.BigEndian.PutUint32(l[4:8], uint32(link.Data))
+		l[8] = uint8(link.Type)
+		binary.BigEndian.PutUint16(l[10:12], link.Metric)
+		b = append(b, l...)
+	}
+
+	lsa := LSA{LSAHeader{
+		Options:   OptionE,
+		Type:      RouterLSA,
+		ID:        router,
+		AdvRouter: router,
+		Seq:       seq,
+	}, b}
+
+	lsa.Sum()
+	return lsa
+}
+
+// RouterLinks returns links of the router LSA.
+func (l *LSA) RouterLinks() ([]RouterLink, error) {
+	if l.Type != RouterLSA || len(l.Body) < 4 {
+		return nil, ErrFormat
+	}
+
+	count := int(binary.BigEndian.Uint16(l.Body[2:4]))
+	links := make([]RouterLink, 0, count)
+
+	for b := l.Body[4:]; count > 0; count-- {
+		if len(b) < linkLen {
+			return nil, ErrFormat
+		}
+
+		// TOS metrics are skipped.
+		n := linkLen + int(b[9])*4
+		if len(b) < n {
+			return nil, ErrFormat
+		}
+
+		links = append(links, RouterLink{
+			Type:   LinkType(b[8]),
+			ID:     ID(binary.BigEndian.Uint32(b[0:4])),
+			Data:   ID(binary.BigEndian.Uint32(b[4:8])),
+			Metric: binary.BigEndian.Uint16(b[10:12]),
+		})
+
+		b = b[n:]
+	}
+
+	return links, nil
+}
+
+// NewNetworkLSA creates a new network LSA of the designated router.
+func NewNetworkLSA(addr net.IPNet, router ID, seq int32, routers []ID) LSA {
+	b := make([]byte, 4, 4+len(routers)*4)
+	copy(b, addr.Mask)
+
+	for _, id := range routers {
+		r := make([]byte, 4)
+		binary.BigEndian.PutUint32(r, uint32(id))
+		b = append(b, r...)
+	}
+
+	lsa := LSA{LSAHeader{
+		Options:   OptionE,
+		Type:      NetworkLSA,
+		ID:        IDFromIP(addr.IP),
+		AdvRouter: router,
+		Seq:       seq,
+	}, b}
+
+	lsa.Sum()
+	return lsa
+}
+
+// Network returns network mask and attached routers of the network LSA.
+func (l *LSA) Network() (net.IPMask, []ID, error) {
+	if l.Type != NetworkLSA || len(l.Body) < 4 || len(l.Body)%4 != 0 {
+		return nil, nil, ErrFormat
+	}
+
+	mask := net.IPMask(append([]byte(nil), l.Body[0:4]...))
+
+	var routers []ID
+	for b := l.Body[4:]; len(b) > 0; b = b[4:] {
+		routers = append(routers, ID(binary.BigEndian.Uint32(b)))
+	}
+
+	return mask, routers, nil
+}
+
+// sameContents reports whether advertisements differ only in
+// header fields, that are changed by the origination.
+func (l *LSA) sameContents(other *LSA) bool {
+	return l.Options == other.Options && bytes.Equal(l.Body, other.Body)
+}
@@ -0,0 +1,87 @@
+package ospf
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestLSAChecksum(t *testing.T) {
+	links := []RouterLink{
+		{PointToPointLink, 0x0a000002, 0x0a000001, 10},
+		{StubLink, 0x0a000000, 0xfffffffc, 10},
+	}
+
+	lsa := NewRouterLSA(0x0a000001, InitialSeq, links)
+	if !lsa.Valid() {
+		t.Fatal("Originated LSA is not valid")
+	}
+
+	// Fletcher sums of the checksummed bytes are zero.
+	var c0, c1 int
+	for _, v := range lsa.marshal()[2:] {
+		c0 = (c0 + int(v)) % 255
+		c1 = (c1 + c0) % 255
+	}
+
+	if c0 != 0 || c1 != 0 {
+		t.Fatal("Invalid checksum:", lsa.Checksum)
+	}
+
+	// Age is not covered by checksum.
+	lsa.Age = MaxAge
+	if !lsa.Valid() {
+		t.Fatal("Aged LSA is not valid")
+	}
+
+	lsa.Body[4] ^= 0xff
+	if lsa.Valid() {
+		t.Fatal("Corrupted LSA is valid")
+	}
+}
+
+func TestLSALinks(t *testing.T) {
+	links := []RouterLink{
+		{TransitLink, 0x0a000001, 0x0a000002, 5},
+		{StubLink, 0xc0a80000, 0xffffff00, 10},
+	}
+
+	lsa := NewRouterLSA(0x0a000002, InitialSeq, links)
+
+	decoded, err := lsa.RouterLinks()
+	if err != nil || !reflect.DeepEqual(links, decoded) {
+		t.Fatal("Invalid links decoded:", decoded, err)
+	}
+
+	addr := net.IPNet{net.IPv4(10, 0, 0, 1).To4(), net.IPv4Mask(255, 255, 255, 0)}
+	lsa = NewNetworkLSA(addr, 0x0a000001, InitialSeq, []ID{1, 2})
+
+	mask, routers, err := lsa.Network()
+	if err != nil || mask.String() != "ffffff00" || !reflect.DeepEqual(routers, []ID{1, 2}) {
+		t.Fatal("Invalid network decoded:", mask, routers, err)
+	}
+
+	if lsa.ID != 0x0a000001 {
+		t.Fatal("Invalid link state identifier:", lsa.ID)
+	}
+}
+
+func TestLSACompare(t *testing.T) {
+	tests := []struct {
+		h1, h2 LSAHeader
+		cmp    int
+	}{
+		{LSAHeader{Seq: 2}, LSAHeader{Seq: 1}, 1},
+		{LSAHeader{Seq: InitialSeq}, LSAHeader{Seq: 1}, -1},
+		{LSAHeader{Seq: 1, Checksum: 1}, LSAHeader{Seq: 1, Checksum: 2}, -1},
+		{LSAHeader{Seq: 1, Age: MaxAge}, LSAHeader{Seq: 1, Age: 10}, 1},
+		{LSAHeader{Seq: 1, Age: 10}, LSAHeader{Seq: 1, Age: 1000}, 1},
+		{LSAHeader{Seq: 1, Age: 10}, LSAHeader{Seq: 1, Age: 900}, 0},
+	}
+
+	for _, test := range tests {
+		if cmp := test.h1.Compare(&test.h2); cmp != test.cmp {
+			t.Fatalf("Invalid comparison of %v and %v: %d", test.h1, test.h2, cmp)
+		}
+	}
+}
@@ -0,0 +1,575 @@
+package ospf
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/netrack/net/iana"
+	"github.com/netrack/netrack/logging"
+	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ofp.v13"
+	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+	"github.com/netrack/openflow/ofp.v13/ofputil"
+)
+
+const OSPFMechanismName = "ospf"
+
+// tickInterval is an interval between checks of protocol timers.
+const tickInterval = time.Second
+
+func init() {
+	constructor := mech.NetworkMechanismConstructorFunc(NewOSPFMechanism)
+	mech.RegisterNetworkMechanism(OSPFMechanismName, constructor)
+}
+
+// isIPv4 reports whether address belongs to IPv4 network.
+func isIPv4(addr mech.NetworkAddr) bool {
+	return len(addr.Bytes()) == net.IPv4len
+}
+
+// ospfMatch matches OSPF packets received on the port.
+func ospfMatch(port uint32, vlan uint16) ofp.Match {
+	return ofp.Match{ofp.MT_OXM, []ofp.OXM{
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IN_PORT, of.Bytes(ofp.PortNo(port)), nil},
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_TYPE, of.Bytes(iana.ETHT_IPV4), nil},
+		ofp13.VLANMatch(vlan),
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IP_PROTO, of.Bytes(iana.IPProto(Proto)), nil},
+	}}
+}
+
+// interfaceConfig returns OSPF configuration of the interface.
+func interfaceConfig(context *mech.NetworkContext) (InterfaceConfig, bool) {
+	if context.OSPF == nil || !isIPv4(context.NetworkAddr) {
+		return InterfaceConfig{}, false
+	}
+
+	config := InterfaceConfig{
+		Port: context.Port,
+		Addr: net.IPNet{
+			net.IP(context.NetworkAddr.Bytes()),
+			net.IPMask(context.NetworkAddr.Mask().Bytes()),
+		},
+		Type:          Broadcast,
+		Cost:          context.OSPF.Cost,
+		Priority:      context.OSPF.Priority,
+		HelloInterval: time.Duration(context.OSPF.HelloInterval) * time.Second,
+		DeadInterval:  time.Duration(context.OSPF.DeadInterval) * time.Second,
+	}
+
+	if context.OSPF.Type == mech.OSPFPointToPoint {
+		config.Type = PointToPoint
+	}
+
+	return config, true
+}
+
+// OSPFMechanism implements OSPFv2 routing protocol within the
+// backbone area on the interfaces of the switch, routes calculated
+// from the link state database are installed to the routing tables
+// of other mechanisms.
+type OSPFMechanism struct {
+	mech.BaseNetworkMechanism
+
+	// Handle request based on cookie value.
+	cookies *mech.CookieFilter
+
+	// Stores registered handlers to delete them later.
+	filter *of.ServeFilter
+
+	// OSPF protocol engine.
+	router *Router
+
+	// Hardware addresses of neighbors indexed by network address.
+	neighbors map[string]net.HardwareAddr
+
+	// VLAN identifiers of interfaces indexed by port.
+	vlans map[uint32]uint16
+
+	stopCh chan bool
+	lock   sync.Mutex
+}
+
+func NewOSPFMechanism() mech.NetworkMechanism {
+	m := &OSPFMechanism{
+		cookies:   mech.NewCookieFilter(),
+		filter:    of.NewServeFilter(),
+		neighbors: make(map[string]net.HardwareAddr),
+		vlans:     make(map[uint32]uint16),
+	}
+
+	m.router = m.newRouter()
+	return m
+}
+
+func (m *OSPFMechanism) Name() string {
+	return OSPFMechanismName
+}
+
+func (m *OSPFMechanism) Description() string {
+	return "OSPFv2 routing protocol"
+}
+
+// Version implements VersionedMechanism interface.
+func (m *OSPFMechanism) Version() string {
+	return ofp13.ProtoVersion
+}
+
+// newRouter returns router, that sends packets through the switch.
+func (m *OSPFMechanism) newRouter() *Router {
+	router := NewRouter()
+	router.Send = m.send
+	router.Learn = m.learn
+	router.Forget = m.forget
+
+	return router
+}
+
+// OSPFNeighbors implements OSPFReader interface.
+func (m *OSPFMechanism) OSPFNeighbors() []mech.OSPFNeighbor {
+	neighbors := make([]mech.OSPFNeighbor, 0)
+
+	for _, nbr := range m.router.Neighbors() {
+		neighbors = append(neighbors, mech.OSPFNeighbor{
+			RouterID: nbr.RouterID.String(),
+			Addr:     nbr.Addr.String(),
+			Port:     nbr.Port,
+			State:    nbr.State.String(),
+			Priority: nbr.Priority,
+			DR:       nbr.DR.String(),
+			BDR:      nbr.BDR.String(),
+		})
+	}
+
+	return neighbors
+}
+
+// OSPFDatabase implements OSPFReader interface.
+func (m *OSPFMechanism) OSPFDatabase() []mech.OSPFLSA {
+	lsas := make([]mech.OSPFLSA, 0)
+
+	for _, lsa := range m.router.Database(time.Now()) {
+		lsas = append(lsas, mech.OSPFLSA{
+			Type:      lsa.Type.String(),
+			ID:        lsa.ID.String(),
+			AdvRouter: lsa.AdvRouter.String(),
+			Seq:       uint32(lsa.Seq),
+			Age:       lsa.Age,
+			Checksum:  lsa.Checksum,
+			Length:    lsa.Length,
+		})
+	}
+
+	return lsas
+}
+
+// Enable implements Mechanism interface.
+func (m *OSPFMechanism) Enable(c *mech.MechanismContext) {
+	m.BaseNetworkMechanism.Enable(c)
+
+	m.filter.Mux = m.C.Mux
+	// Handle incoming OSPF packets.
+	m.filter.HandleFunc(of.T_PACKET_IN, m.packetInHandler)
+	m.filter.HandleFunc(of.T_FLOW_REMOVED, m.flowRemovedHandler)
+
+	// Expose neighbors and link state database.
+	m.C.Managers.Bind(new(mech.OSPFReader), m)
+
+	log.InfoLog("ospf/ENABLE_HOOK", "Mechanism OSPF enabled")
+}
+
+// Activate implements Mechanism interface.
+func (m *OSPFMechanism) Activate() {
+	m.BaseNetworkMechanism.Activate()
+
+	// Operate on PacketIn messages
+	m.cookies.Baker = ofputil.PacketInBaker()
+
+	m.start()
+}
+
+// Disable implements Mechanism interface.
+func (m *OSPFMechanism) Disable() {
+	m.BaseNetworkMechanism.Disable()
+
+	m.stop()
+	m.filter.Unhandle()
+	m.C.Managers.Unbind(new(mech.OSPFReader))
+
+	log.InfoLog("ospf/DISABLE_HOOK", "Mechanism OSPF disabled")
+}
+
+// Deactivate implements Mechanism interface.
+func (m *OSPFMechanism) Deactivate() {
+	m.BaseNetworkMechanism.Deactivate()
+
+	m.stop()
+	m.filter.Unhandle()
+	m.C.Managers.Unbind(new(mech.OSPFReader))
+
+	// Drop handlers of cookies of installed flows.
+	m.cookies = mech.NewCookieFilter()
+
+	// Adjacencies are gone along with the switch.
+	m.router = m.newRouter()
+
+	m.lock.Lock()
+	m.neighbors = make(map[string]net.HardwareAddr)
+	m.vlans = make(map[uint32]uint16)
+	m.lock.Unlock()
+
+	log.InfoLog("ospf/DEACTIVATE_HOOK", "Mechanism OSPF deactivated")
+}
+
+// OwnsFlow implements mech.FlowOwner interface.
+func (m *OSPFMechanism) OwnsFlow(table int, cookie uint64) bool {
+	return m.cookies.Contains(cookie)
+}
+
+// start starts protocol timers in a separate goroutine.
+func (m *OSPFMechanism) start() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.stopCh != nil {
+		return
+	}
+
+	m.stopCh = make(chan bool)
+	go m.run(m.stopCh)
+}
+
+// stop stops protocol timers.
+func (m *OSPFMechanism) stop() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.stopCh != nil {
+		close(m.stopCh)
+		m.stopCh = nil
+	}
+}
+
+func (m *OSPFMechanism) run(stopCh chan bool) {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			m.router.Tick(time.Now())
+		}
+	}
+}
+
+func (m *OSPFMechanism) CreateNetworkPreCommit(context *mech.NetworkContext) error {
+	return m.UpdateNetworkPostCommit(context)
+}
+
+func (m *OSPFMechanism) UpdateNetworkPreCommit(context *mech.NetworkContext) error {
+	return m.DeleteNetworkPreCommit(context)
+}
+
+func (m *OSPFMechanism) UpdateNetworkPostCommit(context *mech.NetworkContext) error {
+	log.DebugLog("ospf/UPDATE_NETWORK_POSTCOMMIT",
+		"Got update network request")
+
+	config, ok := interfaceConfig(context)
+	if !ok {
+		return nil
+	}
+
+	m.lock.Lock()
+	m.vlans[context.Port] = context.VLAN
+	m.lock.Unlock()
+
+	instructions := ofp.Instructions{ofp.InstructionActions{
+		ofp.IT_APPLY_ACTIONS,
+		ofp.Actions{ofp.ActionOutput{ofp.P_CONTROLLER, ofp.CML_NO_BUFFER}},
+	}}
+
+	flowMod := ofp.FlowMod{
+		Command:  ofp.FC_ADD,
+		BufferID: ofp.NO_BUFFER,
+		// Notify controller, when flow removed
+		Flags:        ofp.FF_SEND_FLOW_REM,
+		Priority:     40, // Use non-zero priority
+		Match:        ospfMatch(context.Port, context.VLAN),
+		Instructions: instructions,
+	}
+
+	// Assign cookie to FlowMod message, and
+	// redirect such requests to ospfHandler
+	m.cookies.FilterFunc(&flowMod, m.ospfHandler)
+
+	r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
+	if err != nil {
+		log.ErrorLog("ospf/UPDATE_NETWORK_POSTCOMMIT",
+			"Failed to create a new ofp_flow_mod request: ", err)
+		return err
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("ospf/UPDATE_NETWORK_POSTCOMMIT",
+			"Failed to send request: ", err)
+		return err
+	}
+
+	m.router.AddInterface(config, time.Now())
+	return nil
+}
+
+func (m *OSPFMechanism) DeleteNetworkPreCommit(context *mech.NetworkContext) error {
+	log.DebugLog("ospf/DELETE_NETWORK_PRECOMMIT",
+		"Got network delete precommit request")
+
+	config, ok := interfaceConfig(context)
+	if !ok {
+		return nil
+	}
+
+	m.router.DeleteInterface(config.Port, config.Addr, time.Now())
+
+	// OSPF still runs on other interfaces of the port.
+	if len(m.router.Networks(context.Port)) != 0 {
+		return nil
+	}
+
+	err := of.Send(m.C.Switch.Conn(),
+		ofputil.FlowFlush(0, ospfMatch(context.Port, context.VLAN)),
+	)
+
+	if err != nil {
+		log.ErrorLog("ospf/DELETE_NETWORK_PRECOMMIT",
+			"Failed to send requests: ", err)
+	}
+
+	return err
+}
+
+func (m *OSPFMechanism) packetInHandler(rw of.ResponseWriter, r *of.Request) {
+	m.cookies.Serve(rw, r)
+}
+
+func (m *OSPFMechanism) flowRemovedHandler(rw of.ResponseWriter, r *of.Request) {
+	var flowRemoved ofp.FlowRemoved
+
+	_, err := of.ReadAllFrom(r.Body, &flowRemoved)
+	if err != nil {
+		return
+	}
+
+	m.cookies.Release(&flowRemoved)
+}
+
+// routingContext returns routing context of the calculated route.
+func (m *OSPFMechanism) routingContext(route Route) (*mech.RoutingContext, error) {
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		return nil, err
+	}
+
+	context := &mech.RoutingContext{
+		Type:    mech.OSPFRoute,
+		Network: nldriver.CreateAddr(route.Network.IP, route.Network.Mask),
+		NextHop: nldriver.CreateAddr(route.NextHop, nil),
+		Driver:  nldriver,
+		Port:    route.Port,
+		Metric:  int(route.Cost),
+	}
+
+	return context, nil
+}
+
+// routing returns routing mechanism manager of the switch.
+func (m *OSPFMechanism) routing() (mech.RoutingMechanismManager, error) {
+	var routing mech.RoutingMechanismManager
+	err := m.C.Managers.Obtain(&routing)
+	return routing, err
+}
+
+// learn installs calculated route to the routing tables.
+func (m *OSPFMechanism) learn(route Route) {
+	context, err := m.routingContext(route)
+	if err != nil {
+		return
+	}
+
+	routing, err := m.routing()
+	if err != nil {
+		log.ErrorLog("ospf/LEARN_ROUTE",
+			"Failed to obtain routing manager: ", err)
+		return
+	}
+
+	if err = routing.LearnRoute(context); err != nil {
+		log.ErrorLog("ospf/LEARN_ROUTE",
+			"Failed to install calculated route: ", err)
+	}
+}
+
+// forget removes unreachable route from the routing tables.
+func (m *OSPFMechanism) forget(route Route) {
+	context, err := m.routingContext(route)
+	if err != nil {
+		return
+	}
+
+	routing, err := m.routing()
+	if err != nil {
+		log.ErrorLog("ospf/FORGET_ROUTE",
+			"Failed to obtain routing manager: ", err)
+		return
+	}
+
+	if err = routing.ForgetRoute(context); err != nil {
+		log.ErrorLog("ospf/FORGET_ROUTE",
+			"Failed to remove unreachable route: ", err)
+	}
+}
+
+// linkAddr returns hardware address of the packet destination.
+func (m *OSPFMechanism) linkAddr(dst net.IP) (net.HardwareAddr, bool) {
+	switch {
+	case dst.Equal(AllSPFRouters):
+		return AllSPFLinkAddr, true
+	case dst.Equal(AllDRouters):
+		return AllDLinkAddr, true
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	hwaddr, ok := m.neighbors[dst.String()]
+	return hwaddr, ok
+}
+
+// send sends packet out of the switch port.
+func (m *OSPFMechanism) send(port uint32, src, dst net.IP, packet *Packet) {
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		return
+	}
+
+	lladdr, err := lldriver.Addr(port)
+	if err != nil {
+		log.ErrorLog("ospf/SEND_PACKET",
+			"Failed to retrieve port hardware address: ", err)
+		return
+	}
+
+	hwaddr, ok := m.linkAddr(dst)
+	if !ok {
+		log.InfoLog("ospf/SEND_PACKET",
+			"Hardware address of neighbor is unknown: ", dst)
+		return
+	}
+
+	body, err := packet.MarshalBinary()
+	if err != nil {
+		log.ErrorLog("ospf/SEND_PACKET",
+			"Failed to marshal OSPF packet: ", err)
+		return
+	}
+
+	m.lock.Lock()
+	vlan := m.vlans[port]
+	m.lock.Unlock()
+
+	pdu2 := mech.LinkFrame{lldriver.CreateAddr(hwaddr),
+		lladdr, mech.Proto(iana.ETHT_IPV4), vlan, 0,
+	}
+
+	pdu3 := mech.NetworkPacket{
+		DstAddr: nldriver.CreateAddr(dst, nil),
+		SrcAddr: nldriver.CreateAddr(src, nil),
+		Proto:   mech.Proto(Proto),
+		Payload: bytes.NewReader(body),
+	}
+
+	packetOut := ofp.PacketOut{
+		BufferID: ofp.NO_BUFFER,
+		InPort:   ofp.P_CONTROLLER,
+		Actions:  ofp.Actions{ofp.ActionOutput{ofp.PortNo(port), 0}},
+	}
+
+	llwriter := mech.MakeLinkWriterTo(lldriver, &pdu2)
+	nlwriter := mech.MakeNetworkWriterTo(nldriver, &pdu3)
+
+	r, err := of.NewRequest(of.T_PACKET_OUT, of.NewReader(&packetOut, llwriter, nlwriter))
+	if err != nil {
+		log.ErrorLog("ospf/SEND_PACKET",
+			"Failed to create a new ofp_packet_out request: ", err)
+		return
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("ospf/SEND_PACKET",
+			"Failed to send OSPF packet: ", err)
+	}
+}
+
+func (m *OSPFMechanism) ospfHandler(rw of.ResponseWriter, r *of.Request) {
+	var packet ofp.PacketIn
+	var pdu2 mech.LinkFrame
+	var pdu3 mech.NetworkPacket
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		return
+	}
+
+	llreader := mech.MakeLinkReaderFrom(lldriver, &pdu2)
+	nlreader := mech.MakeNetworkReaderFrom(nldriver, &pdu3)
+
+	if _, err = of.ReadAllFrom(r.Body, &packet, llreader, nlreader); err != nil {
+		log.ErrorLog("ospf/OSPF_HANDLER",
+			"Failed to read packet: ", err)
+		return
+	}
+
+	payload := make([]byte, pdu3.ContentLen)
+	if _, err = io.ReadFull(r.Body, payload); err != nil {
+		log.ErrorLog("ospf/OSPF_HANDLER",
+			"Failed to read OSPF packet: ", err)
+		return
+	}
+
+	var ospfPacket Packet
+	if err = ospfPacket.UnmarshalBinary(payload); err != nil {
+		log.ErrorLog("ospf/OSPF_HANDLER",
+			"Failed to read OSPF packet: ", err)
+		return
+	}
+
+	// Get port number from match fields.
+	portNo := packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.UInt32()
+	src := net.IP(pdu3.SrcAddr.Bytes())
+
+	log.DebugLogf("ospf/OSPF_HANDLER",
+		"Got OSPF packet %d from %s", ospfPacket.Body.Type(), src)
+
+	// Remember hardware address of the neighbor
+	// to send unicast packets to it.
+	m.lock.Lock()
+	m.neighbors[src.String()] = net.HardwareAddr(pdu2.SrcAddr.Bytes())
+	m.lock.Unlock()
+
+	m.router.Receive(portNo, src, &ospfPacket, time.Now())
+}
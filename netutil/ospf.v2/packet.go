@@ -0,0 +1,446 @@
+package ospf
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+const (
+	// Version is a version of OSPF protocol.
+	Version = 2
+
+	// Proto is an IP protocol number of OSPF packets.
+	Proto = 89
+
+	// OptionE is an option of routers, that accept AS-external LSAs.
+	OptionE = 0x02
+)
+
+// Type is a type of OSPF packet.
+type Type uint8
+
+const (
+	HelloType Type = 1 + iota
+	DatabaseDescriptionType
+	LSRequestType
+	LSUpdateType
+	LSAckType
+)
+
+// Flags of database description packets.
+const (
+	// FlagMS is set by the master of the database exchange.
+	FlagMS uint8 = 1 << iota
+
+	// FlagM is set, when more packets follow.
+	FlagM
+
+	// FlagI is set in the first packet of the exchange.
+	FlagI
+)
+
+const (
+	// headerLen is a length of packet header.
+	headerLen = 24
+
+	// helloLen is a length of hello packet without neighbors.
+	helloLen = 20
+
+	// ddLen is a length of database description
+	// packet without LSA headers.
+	ddLen = 8
+
+	// requestLen is a length of a single link state request.
+	requestLen = 12
+)
+
+var (
+	// AllSPFRouters is an address of all OSPF routers.
+	AllSPFRouters = net.IPv4(224, 0, 0, 5).To4()
+
+	// AllDRouters is an address of designated routers.
+	AllDRouters = net.IPv4(224, 0, 0, 6).To4()
+
+	// AllSPFLinkAddr is a hardware address of all OSPF routers.
+	AllSPFLinkAddr = net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0x05}
+
+	// AllDLinkAddr is a hardware address of designated routers.
+	AllDLinkAddr = net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0x06}
+)
+
+var (
+	// ErrFormat is returned on malformed OSPF packets.
+	ErrFormat = errors.New("ospf: malformed packet")
+
+	// ErrChecksum is returned on packets with invalid checksum.
+	ErrChecksum = errors.New("ospf: invalid checksum")
+)
+
+// ID is a router, area or link state identifier.
+type ID uint32
+
+// IDFromIP returns identifier of the IPv4 address.
+func IDFromIP(ip net.IP) ID {
+	if ip = ip.To4(); ip == nil {
+		return 0
+	}
+
+	return ID(binary.BigEndian.Uint32(ip))
+}
+
+// IP returns identifier as IPv4 address.
+func (id ID) IP() net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, uint32(id))
+	return ip
+}
+
+func (id ID) String() string {
+	return id.IP().String()
+}
+
+// Body is a type specific part of OSPF packet.
+type Body interface {
+	// Type returns type of the packet.
+	Type() Type
+
+	marshal() []byte
+	unmarshal([]byte) error
+}
+
+// Packet is an OSPF packet, authentication is not supported.
+type Packet struct {
+	// Router identifier of the packet source.
+	RouterID ID
+
+	// Area identifier of the packet.
+	AreaID ID
+
+	// Type specific part of the packet.
+	Body Body
+}
+
+// Hello is a packet, that discovers and maintains neighbor relationships.
+type Hello struct {
+	// Network mask of the interface.
+	Mask net.IPMask
+
+	// Interval between hello packets in seconds.
+	HelloInterval uint16
+
+	// Optional capabilities of the router.
+	Options uint8
+
+	// Priority of the router in the designated router election.
+	Priority uint8
+
+	// Interval in seconds before neighbor is declared down.
+	DeadInterval uint32
+
+	// Designated and backup designated routers of the network.
+	DR  ID
+	BDR ID
+
+	// Routers, hello packets have been seen from.
+	Neighbors []ID
+}
+
+func (h *Hello) Type() Type {
+	return HelloType
+}
+
+func (h *Hello) marshal() []byte {
+	b := make([]byte, helloLen+len(h.Neighbors)*4)
+
+	copy(b[0:4], h.Mask)
+	binary.BigEndian.PutUint16(b[4:6], h.HelloInterval)
+	b[6], b[7] = h.Options, h.Priority
+	binary.BigEndian.PutUint32(b[8:12], h.DeadInterval)
+	binary.BigEndian.PutUint32(b[12:16], uint32(h.DR))
+	binary.BigEndian.PutUint32(b[16:20], uint32(h.BDR))
+
+	for i, id := range h.Neighbors {
+		binary.BigEndian.PutUint32(b[helloLen+i*4:], uint32(id))
+	}
+
+	return b
+}
+
+func (h *Hello) unmarshal(b []byte) error {
+	if len(b) < helloLen || (len(b)-helloLen)%4 != 0 {
+		return ErrFormat
+	}
+
+	h.Mask = net.IPMask(append([]byte(nil), b[0:4]...))
+	h.HelloInterval = binary.BigEndian.Uint16(b[4:6])
+	h.Options, h.Priority = b[6], b[7]
+	h.DeadInterval = binary.BigEndian.Uint32(b[8:12])
+	h.DR = ID(binary.BigEndian.Uint32(b[12:16]))
+	h.BDR = ID(binary.BigEndian.Uint32(b[16:20]))
+	h.Neighbors = nil
+
+	for b = b[helloLen:]; len(b) > 0; b = b[4:] {
+		h.Neighbors = append(h.Neighbors, ID(binary.BigEndian.Uint32(b)))
+	}
+
+	return nil
+}
+
+// DatabaseDescription is a packet, that describes
+// contents of the link state database.
+type DatabaseDescription struct {
+	// Maximum size of IP packet, sent out of the interface.
+	MTU uint16
+
+	// Optional capabilities of the router.
+	Options uint8
+
+	// Flags of the exchange.
+	Flags uint8
+
+	// Sequence number of the exchange.
+	Seq uint32
+
+	// Headers of LSAs of the database.
+	Headers []LSAHeader
+}
+
+func (d *DatabaseDescription) Type() Type {
+	return DatabaseDescriptionType
+}
+
+func (d *DatabaseDescription) marshal() []byte {
+	b := make([]byte, ddLen, ddLen+len(d.Headers)*lsaHeaderLen)
+
+	binary.BigEndian.PutUint16(b[0:2], d.MTU)
+	b[2], b[3] = d.Options, d.Flags
+	binary.BigEndian.PutUint32(b[4:8], d.Seq)
+
+	for _, header := range d.Headers {
+		b = append(b, header.marshal()...)
+	}
+
+	return b
+}
+
+func (d *DatabaseDescription) unmarshal(b []byte) error {
+	if len(b) < ddLen || (len(b)-ddLen)%lsaHeaderLen != 0 {
+		return ErrFormat
+	}
+
+	d.MTU = binary.BigEndian.Uint16(b[0:2])
+	d.Options, d.Flags = b[2], b[3]
+	d.Seq = binary.BigEndian.Uint32(b[4:8])
+	d.Headers = nil
+
+	for b = b[ddLen:]; len(b) > 0; b = b[lsaHeaderLen:] {
+		var header LSAHeader
+		header.unmarshal(b)
+		d.Headers = append(d.Headers, header)
+	}
+
+	return nil
+}
+
+// LSRequest is a packet, that requests LSAs
+// missing in the link state database.
+type LSRequest struct {
+	Requests []LSAKey
+}
+
+func (l *LSRequest) Type() Type {
+	return LSRequestType
+}
+
+func (l *LSRequest) marshal() []byte {
+	b := make([]byte, len(l.Requests)*requestLen)
+
+	for i, key := range l.Requests {
+		r := b[i*requestLen:]
+		binary.BigEndian.PutUint32(r[0:4], uint32(key.Type))
+		binary.BigEndian.PutUint32(r[4:8], uint32(key.ID))
+		binary.BigEndian.PutUint32(r[8:12], uint32(key.AdvRouter))
+	}
+
+	return b
+}
+
+func (l *LSRequest) unmarshal(b []byte) error {
+	if len(b)%requestLen != 0 {
+		return ErrFormat
+	}
+
+	l.Requests = nil
+
+	for ; len(b) > 0; b = b[requestLen:] {
+		l.Requests = append(l.Requests, LSAKey{
+			Type:      LSType(binary.BigEndian.Uint32(b[0:4])),
+			ID:        ID(binary.BigEndian.Uint32(b[4:8])),
+			AdvRouter: ID(binary.BigEndian.Uint32(b[8:12])),
+		})
+	}
+
+	return nil
+}
+
+// LSUpdate is a packet, that floods LSAs.
+type LSUpdate struct {
+	LSAs []LSA
+}
+
+func (l *LSUpdate) Type() Type {
+	return LSUpdateType
+}
+
+func (l *LSUpdate) marshal() []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(len(l.LSAs)))
+
+	for _, lsa := range l.LSAs {
+		b = append(b, lsa.marshal()...)
+	}
+
+	return b
+}
+
+func (l *LSUpdate) unmarshal(b []byte) error {
+	if len(b) < 4 {
+		return ErrFormat
+	}
+
+	count := binary.BigEndian.Uint32(b)
+	l.LSAs = nil
+
+	for b = b[4:]; count > 0; count-- {
+		var lsa LSA
+
+		n, err := lsa.unmarshal(b)
+		if err != nil {
+			return err
+		}
+
+		l.LSAs = append(l.LSAs, lsa)
+		b = b[n:]
+	}
+
+	return nil
+}
+
+// LSAck is a packet, that acknowledges flooded LSAs.
+type LSAck struct {
+	Headers []LSAHeader
+}
+
+func (l *LSAck) Type() Type {
+	return LSAckType
+}
+
+func (l *LSAck) marshal() []byte {
+	var b []byte
+	for _, header := range l.Headers {
+		b = append(b, header.marshal()...)
+	}
+
+	return b
+}
+
+func (l *LSAck) unmarshal(b []byte) error {
+	if len(b)%lsaHeaderLen != 0 {
+		return ErrFormat
+	}
+
+	l.Headers = nil
+
+	for ; len(b) > 0; b = b[lsaHeaderLen:] {
+		var header LSAHeader
+		header.unmarshal(b)
+		l.Headers = append(l.Headers, header)
+	}
+
+	return nil
+}
+
+// checksum returns internet checksum of the packet,
+// authentication field is excluded from the checksum.
+func checksum(b []byte) uint16 {
+	var sum uint32
+
+	for i := 0; i+1 < len(b); i += 2 {
+		if i >= 16 && i < headerLen {
+			continue
+		}
+
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+
+	if len(b)%2 != 0 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+
+	return ^uint16(sum)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler interface.
+func (p *Packet) MarshalBinary() ([]byte, error) {
+	if p.Body == nil {
+		return nil, ErrFormat
+	}
+
+	b := append(make([]byte, headerLen), p.Body.marshal()...)
+
+	b[0], b[1] = Version, uint8(p.Body.Type())
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	binary.BigEndian.PutUint32(b[4:8], uint32(p.RouterID))
+	binary.BigEndian.PutUint32(b[8:12], uint32(p.AreaID))
+	binary.BigEndian.PutUint16(b[12:14], checksum(b))
+
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler interface.
+func (p *Packet) UnmarshalBinary(b []byte) error {
+	if len(b) < headerLen || b[0] != Version {
+		return ErrFormat
+	}
+
+	length := int(binary.BigEndian.Uint16(b[2:4]))
+	if length < headerLen || length > len(b) {
+		return ErrFormat
+	}
+
+	b = b[:length]
+
+	// Only null authentication is supported.
+	if binary.BigEndian.Uint16(b[14:16]) != 0 {
+		return ErrFormat
+	}
+
+	if checksum(b) != 0 {
+		return ErrChecksum
+	}
+
+	switch Type(b[1]) {
+	case HelloType:
+		p.Body = new(Hello)
+	case DatabaseDescriptionType:
+		p.Body = new(DatabaseDescription)
+	case LSRequestType:
+		p.Body = new(LSRequest)
+	case LSUpdateType:
+		p.Body = new(LSUpdate)
+	case LSAckType:
+		p.Body = new(LSAck)
+	default:
+		return ErrFormat
+	}
+
+	p.RouterID = ID(binary.BigEndian.Uint32(b[4:8]))
+	p.AreaID = ID(binary.BigEndian.Uint32(b[8:12]))
+
+	return p.Body.unmarshal(b[headerLen:])
+}
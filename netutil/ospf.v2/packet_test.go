@@ -0,0 +1,81 @@
+package ospf
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestPacketMarshal(t *testing.T) {
+	lsa := NewRouterLSA(0x0a000001, InitialSeq, []RouterLink{
+		{StubLink, 0x0a000000, 0xffffff00, 10},
+	})
+
+	header := lsa.LSAHeader
+
+	bodies := []Body{
+		&Hello{
+			Mask:          net.IPv4Mask(255, 255, 255, 0),
+			HelloInterval: 10,
+			Options:       OptionE,
+			Priority:      1,
+			DeadInterval:  40,
+			DR:            0x0a000001,
+			Neighbors:     []ID{0x0a000002, 0x0a000003},
+		},
+		&DatabaseDescription{
+			MTU:     mtu,
+			Options: OptionE,
+			Flags:   FlagI | FlagM | FlagMS,
+			Seq:     42,
+			Headers: []LSAHeader{header},
+		},
+		&LSRequest{[]LSAKey{header.Key()}},
+		&LSUpdate{[]LSA{lsa}},
+		&LSAck{[]LSAHeader{header}},
+	}
+
+	for _, body := range bodies {
+		packet := Packet{RouterID: 0x0a000001, AreaID: 0, Body: body}
+
+		b, err := packet.MarshalBinary()
+		if err != nil {
+			t.Fatal("Failed to marshal packet:", err)
+		}
+
+		var decoded Packet
+		if err = decoded.UnmarshalBinary(b); err != nil {
+			t.Fatal("Failed to unmarshal packet:", err)
+		}
+
+		if !reflect.DeepEqual(packet, decoded) {
+			t.Fatalf("Invalid packet decoded: %#v", decoded.Body)
+		}
+	}
+}
+
+func TestPacketChecksum(t *testing.T) {
+	packet := Packet{RouterID: 1, Body: &LSAck{}}
+
+	b, err := packet.MarshalBinary()
+	if err != nil {
+		t.Fatal("Failed to marshal packet:", err)
+	}
+
+	// Authentication data is not covered by checksum.
+	b[20] = 0xff
+
+	var decoded Packet
+	if err = decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal("Failed to unmarshal packet:", err)
+	}
+
+	b[7] ^= 0xff
+	if err = decoded.UnmarshalBinary(b); err != ErrChecksum {
+		t.Fatal("Corrupted packet accepted:", err)
+	}
+
+	if err = decoded.UnmarshalBinary(b[:headerLen-1]); err != ErrFormat {
+		t.Fatal("Truncated packet accepted:", err)
+	}
+}
@@ -0,0 +1,1814 @@
+package ospf
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// HelloInterval is a default interval between hello packets.
+	HelloInterval = 10 * time.Second
+
+	// DeadInterval is a default interval, after which
+	// silent neighbor is declared down.
+	DeadInterval = 40 * time.Second
+
+	// RxmtInterval is an interval between retransmissions of
+	// database descriptions, requests and not acknowledged LSAs.
+	RxmtInterval = 5 * time.Second
+
+	// MinLSArrival is a minimum interval between accepted
+	// instances of the same LSA.
+	MinLSArrival = time.Second
+)
+
+const (
+	// mtu is a maximum size of IP packets sent by the router.
+	mtu = 1500
+
+	// maxPayload is a maximum size of OSPF packet body.
+	maxPayload = mtu - 20 - headerLen
+
+	// maxHeaders is a maximum number of LSA
+	// headers in database description packet.
+	maxHeaders = (maxPayload - ddLen) / lsaHeaderLen
+
+	// maxRequests is a maximum number of LSAs
+	// requested with a single request packet.
+	maxRequests = maxPayload / requestLen
+)
+
+// NetworkType is a type of the network attached to the interface.
+type NetworkType uint8
+
+const (
+	Broadcast NetworkType = iota
+	PointToPoint
+)
+
+// NeighborState is a state of conversation with the neighbor.
+type NeighborState uint8
+
+const (
+	Down NeighborState = iota
+	Init
+	TwoWay
+	ExStart
+	Exchange
+	Loading
+	Full
+)
+
+var neighborStates = []string{
+	"Down", "Init", "2-Way", "ExStart", "Exchange", "Loading", "Full",
+}
+
+func (s NeighborState) String() string {
+	if int(s) < len(neighborStates) {
+		return neighborStates[s]
+	}
+
+	return "Unknown"
+}
+
+// ifaceState is a state of the interface.
+type ifaceState uint8
+
+const (
+	waiting ifaceState = iota
+	pointToPoint
+	drOther
+	backup
+	designated
+)
+
+// InterfaceConfig is a configuration of the interface, OSPF runs on.
+type InterfaceConfig struct {
+	// Switch port number of the interface.
+	Port uint32
+
+	// Interface address.
+	Addr net.IPNet
+
+	// Type of the attached network.
+	Type NetworkType
+
+	// Cost of sending packets out of the interface.
+	Cost uint16
+
+	// Priority of the router in the designated router election.
+	Priority uint8
+
+	// Interval between hello packets.
+	HelloInterval time.Duration
+
+	// Interval, after which silent neighbor is declared down.
+	DeadInterval time.Duration
+}
+
+// Neighbor is a neighbor router discovered on the interface.
+type Neighbor struct {
+	// Router identifier of the neighbor.
+	RouterID ID
+
+	// Interface address of the neighbor.
+	Addr net.IP
+
+	// Switch port number, the neighbor is connected to.
+	Port uint32
+
+	// State of the conversation.
+	State NeighborState
+
+	// Priority of the neighbor.
+	Priority uint8
+
+	// Designated and backup designated routers declared by the neighbor.
+	DR  ID
+	BDR ID
+}
+
+// Route is a route calculated from the link state database.
+type Route struct {
+	// Destination network.
+	Network net.IPNet
+
+	// Address of the next router.
+	NextHop net.IP
+
+	// Switch port number, the route goes through.
+	Port uint32
+
+	// Cost of the path to the destination.
+	Cost uint32
+}
+
+// neighbor is a state of conversation with the neighbor router.
+type neighbor struct {
+	id       ID
+	addr     net.IP
+	priority uint8
+	dr, bdr  ID
+	state    NeighborState
+
+	// Time, when neighbor is declared down.
+	dead time.Time
+
+	// Neighbor is a master of the database exchange.
+	master bool
+
+	// Sequence number of the database exchange.
+	seq uint32
+
+	// Last sent database description has more flag.
+	more bool
+
+	// Headers of LSAs to be described to the neighbor.
+	summary []LSAHeader
+
+	// Last sent and received database descriptions.
+	lastSent *DatabaseDescription
+	lastRecv *DatabaseDescription
+	ddTime   time.Time
+
+	// LSAs to be requested from the neighbor
+	// and LSAs requested with the last request.
+	requests []LSAHeader
+	inflight map[LSAKey]bool
+	reqTime  time.Time
+
+	// LSAs flooded, but not acknowledged by the neighbor.
+	retransmit map[LSAKey]bool
+	rxmtTime   time.Time
+}
+
+// iface is an interface of the router.
+type iface struct {
+	InterfaceConfig
+
+	state     ifaceState
+	waitTime  time.Time
+	helloTime time.Time
+
+	// Interface addresses of the designated
+	// and backup designated routers.
+	dr, bdr ID
+
+	neighbors map[ID]*neighbor
+}
+
+// entry is an LSA installed into the link state database.
+type entry struct {
+	lsa       LSA
+	installed time.Time
+}
+
+// age returns age of the LSA in seconds.
+func (e *entry) age(now time.Time) uint16 {
+	age := int64(e.lsa.Age) + int64(now.Sub(e.installed)/time.Second)
+	if age > MaxAge {
+		age = MaxAge
+	}
+
+	return uint16(age)
+}
+
+// current returns copy of the LSA with the current age.
+func (e *entry) current(now time.Time) LSA {
+	lsa := e.lsa
+	lsa.Age = e.age(now)
+	return lsa
+}
+
+// outgoing is a packet to be sent out of the switch port.
+type outgoing struct {
+	port     uint32
+	src, dst net.IP
+	packet   *Packet
+}
+
+// Router implements OSPFv2 protocol within the backbone area: it
+// forms adjacencies, maintains link state database and calculates
+// routes with the shortest path first algorithm. Router does not
+// send packets itself, but provides them through callbacks.
+type Router struct {
+	// Router identifier, address of the first interface is used, when zero.
+	ID ID
+
+	// Area identifier of all interfaces.
+	Area ID
+
+	// Send is called to send packet out of the switch port.
+	Send func(port uint32, src, dst net.IP, packet *Packet)
+
+	// Learn is called, when route to the destination is calculated.
+	Learn func(Route)
+
+	// Forget is called, when destination becomes unreachable.
+	Forget func(Route)
+
+	ifaces []*iface
+	db     map[LSAKey]*entry
+	routes map[string]Route
+
+	// Sequence number of the last database exchange.
+	seq uint32
+
+	// Link state database changed since the last calculation.
+	dirty bool
+
+	out  []outgoing
+	lock sync.Mutex
+}
+
+// NewRouter creates a new instance of Router type.
+func NewRouter() *Router {
+	return &Router{
+		db:     make(map[LSAKey]*entry),
+		routes: make(map[string]Route),
+	}
+}
+
+// seconds returns duration in seconds.
+func seconds(d time.Duration) uint32 {
+	return uint32(d / time.Second)
+}
+
+// network returns network of the address.
+func network(addr net.IPNet) net.IPNet {
+	return net.IPNet{addr.IP.To4().Mask(addr.Mask), addr.Mask}
+}
+
+// Networks returns interface addresses of the port, OSPF runs on.
+func (r *Router) Networks(port uint32) []net.IPNet {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var addrs []net.IPNet
+	for _, ifc := range r.ifaces {
+		if ifc.Port == port {
+			addrs = append(addrs, ifc.Addr)
+		}
+	}
+
+	return addrs
+}
+
+// Neighbors returns neighbors of all interfaces ordered
+// by port number and router identifier.
+func (r *Router) Neighbors() []Neighbor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var neighbors []Neighbor
+	for _, ifc := range r.ifaces {
+		for _, nbr := range ifc.sorted() {
+			neighbors = append(neighbors, Neighbor{
+				RouterID: nbr.id,
+				Addr:     nbr.addr,
+				Port:     ifc.Port,
+				State:    nbr.state,
+				Priority: nbr.priority,
+				DR:       nbr.dr,
+				BDR:      nbr.bdr,
+			})
+		}
+	}
+
+	sort.SliceStable(neighbors, func(i, j int) bool {
+		return neighbors[i].Port < neighbors[j].Port
+	})
+
+	return neighbors
+}
+
+// Database returns LSAs of the link state database ordered by key.
+func (r *Router) Database(now time.Time) []LSA {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var lsas []LSA
+	for _, key := range r.keys() {
+		lsas = append(lsas, r.db[key].current(now))
+	}
+
+	return lsas
+}
+
+// Routes returns calculated routes ordered by destination.
+func (r *Router) Routes() []Route {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var routes []Route
+	for _, key := range routeKeys(r.routes) {
+		routes = append(routes, r.routes[key])
+	}
+
+	return routes
+}
+
+// less reports whether key precedes other key.
+func (k LSAKey) less(other LSAKey) bool {
+	if k.Type != other.Type {
+		return k.Type < other.Type
+	}
+
+	if k.ID != other.ID {
+		return k.ID < other.ID
+	}
+
+	return k.AdvRouter < other.AdvRouter
+}
+
+// keys returns sorted keys of the database, lock must be held.
+func (r *Router) keys() []LSAKey {
+	var keys []LSAKey
+	for key := range r.db {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].less(keys[j])
+	})
+
+	return keys
+}
+
+// routeKeys returns sorted keys of routes.
+func routeKeys(routes map[string]Route) []string {
+	var keys []string
+	for key := range routes {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// sorted returns neighbors of the interface ordered by router identifier.
+func (ifc *iface) sorted() []*neighbor {
+	var neighbors []*neighbor
+	for _, nbr := range ifc.neighbors {
+		neighbors = append(neighbors, nbr)
+	}
+
+	sort.Slice(neighbors, func(i, j int) bool {
+		return neighbors[i].id < neighbors[j].id
+	})
+
+	return neighbors
+}
+
+// full returns fully adjacent neighbors of the interface.
+func (ifc *iface) full() []*neighbor {
+	var neighbors []*neighbor
+	for _, nbr := range ifc.sorted() {
+		if nbr.state == Full {
+			neighbors = append(neighbors, nbr)
+		}
+	}
+
+	return neighbors
+}
+
+// iface returns interface of the port, the address belongs to.
+func (r *Router) iface(port uint32, addr net.IP) *iface {
+	for _, ifc := range r.ifaces {
+		if ifc.Port == port && ifc.Addr.Contains(addr) {
+			return ifc
+		}
+	}
+
+	return nil
+}
+
+// connected reports whether network is attached to one of interfaces.
+func (r *Router) connected(subnet net.IPNet) bool {
+	for _, ifc := range r.ifaces {
+		n := network(ifc.Addr)
+		if n.String() == subnet.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddInterface starts OSPF on the interface address of the port.
+func (r *Router) AddInterface(config InterfaceConfig, now time.Time) {
+	r.lock.Lock()
+
+	config.Addr.IP = config.Addr.IP.To4()
+	if r.iface(config.Port, config.Addr.IP) != nil {
+		r.lock.Unlock()
+		return
+	}
+
+	if r.ID == 0 {
+		r.ID = IDFromIP(config.Addr.IP)
+	}
+
+	if config.HelloInterval == 0 {
+		config.HelloInterval = HelloInterval
+	}
+
+	if config.DeadInterval == 0 {
+		config.DeadInterval = DeadInterval
+	}
+
+	ifc := &iface{InterfaceConfig: config, neighbors: make(map[ID]*neighbor)}
+
+	switch {
+	case config.Type == PointToPoint:
+		ifc.state = pointToPoint
+	case config.Priority == 0:
+		ifc.state = drOther
+	default:
+		// Wait for hellos of the existing designated routers.
+		ifc.state = waiting
+		ifc.waitTime = now.Add(config.DeadInterval)
+	}
+
+	r.ifaces = append(r.ifaces, ifc)
+	r.hello(ifc, now)
+
+	learned, forgotten, out := r.commit(now)
+
+	r.lock.Unlock()
+	r.notify(learned, forgotten, out)
+}
+
+// DeleteInterface stops OSPF on the interface address of the port,
+// adjacencies of the interface are torn down.
+func (r *Router) DeleteInterface(port uint32, addr net.IPNet, now time.Time) {
+	r.lock.Lock()
+
+	addr.IP = addr.IP.To4()
+
+	for i, ifc := range r.ifaces {
+		if ifc.Port == port && ifc.Addr.IP.Equal(addr.IP) {
+			r.ifaces = append(r.ifaces[:i], r.ifaces[i+1:]...)
+			break
+		}
+	}
+
+	learned, forgotten, out := r.commit(now)
+
+	r.lock.Unlock()
+	r.notify(learned, forgotten, out)
+}
+
+// Receive processes packet received from the router
+// with the specified address on the switch port.
+func (r *Router) Receive(port uint32, src net.IP, packet *Packet, now time.Time) {
+	r.lock.Lock()
+
+	ifc := r.iface(port, src)
+	if ifc == nil || packet.AreaID != r.Area || packet.RouterID == r.ID {
+		r.lock.Unlock()
+		return
+	}
+
+	if hello, ok := packet.Body.(*Hello); ok {
+		r.receiveHello(ifc, packet.RouterID, src, hello, now)
+	} else if nbr, ok := ifc.neighbors[packet.RouterID]; ok {
+		switch body := packet.Body.(type) {
+		case *DatabaseDescription:
+			r.receiveDescription(ifc, nbr, body, now)
+		case *LSRequest:
+			r.receiveRequest(ifc, nbr, body, now)
+		case *LSUpdate:
+			r.receiveUpdate(ifc, nbr, body, now)
+		case *LSAck:
+			r.receiveAck(nbr, body, now)
+		}
+	}
+
+	learned, forgotten, out := r.commit(now)
+
+	r.lock.Unlock()
+	r.notify(learned, forgotten, out)
+}
+
+// Tick sends hello packets, retransmits packets, that were not
+// acknowledged in time, declares silent neighbors down and ages LSAs.
+func (r *Router) Tick(now time.Time) {
+	r.lock.Lock()
+
+	for _, ifc := range r.ifaces {
+		if !now.Before(ifc.helloTime) {
+			r.hello(ifc, now)
+		}
+
+		changed := ifc.state == waiting && !now.Before(ifc.waitTime)
+
+		for _, nbr := range ifc.sorted() {
+			if !now.Before(nbr.dead) {
+				delete(ifc.neighbors, nbr.id)
+				changed = true
+				continue
+			}
+
+			r.retransmit(ifc, nbr, now)
+		}
+
+		if changed && ifc.Type == Broadcast {
+			r.elect(ifc, now)
+		}
+	}
+
+	r.age(now)
+
+	learned, forgotten, out := r.commit(now)
+
+	r.lock.Unlock()
+	r.notify(learned, forgotten, out)
+}
+
+// retransmit retransmits packets, that were not answered by
+// the neighbor within retransmission interval, lock must be held.
+func (r *Router) retransmit(ifc *iface, nbr *neighbor, now time.Time) {
+	// Only master retransmits database descriptions.
+	master := nbr.state == ExStart || (nbr.state == Exchange && !nbr.master)
+	if master && nbr.lastSent != nil && !now.Before(nbr.ddTime) {
+		r.send(ifc, r.unicast(ifc, nbr), nbr.lastSent)
+		nbr.ddTime = now.Add(RxmtInterval)
+	}
+
+	if (nbr.state == Exchange || nbr.state == Loading) &&
+		len(nbr.requests) != 0 && !now.Before(nbr.reqTime) {
+		r.request(ifc, nbr, now)
+	}
+
+	if len(nbr.retransmit) == 0 || now.Before(nbr.rxmtTime) {
+		return
+	}
+
+	var lsas []LSA
+	for _, key := range r.keys() {
+		if nbr.retransmit[key] {
+			lsas = append(lsas, r.db[key].current(now))
+		}
+	}
+
+	nbr.retransmit = make(map[LSAKey]bool)
+	for _, lsa := range lsas {
+		nbr.retransmit[lsa.Key()] = true
+	}
+
+	r.update(ifc, r.unicast(ifc, nbr), lsas)
+	nbr.rxmtTime = now.Add(RxmtInterval)
+}
+
+// age flushes LSAs, that reached maximum age, and removes
+// them, when all neighbors acknowledged them, lock must be held.
+func (r *Router) age(now time.Time) {
+	exchanging := r.exchanging()
+
+	for _, key := range r.keys() {
+		e := r.db[key]
+		if e.age(now) < MaxAge {
+			continue
+		}
+
+		if e.lsa.Age < MaxAge {
+			lsa := e.current(now)
+			r.install(lsa, now)
+			r.flood(nil, nil, lsa, now)
+			continue
+		}
+
+		if !exchanging && !r.retransmitted(key) {
+			delete(r.db, key)
+		}
+	}
+}
+
+// exchanging reports whether database exchange
+// with any neighbor is in progress, lock must be held.
+func (r *Router) exchanging() bool {
+	for _, ifc := range r.ifaces {
+		for _, nbr := range ifc.neighbors {
+			if nbr.state == Exchange || nbr.state == Loading {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// retransmitted reports whether LSA is on the retransmission
+// list of any neighbor, lock must be held.
+func (r *Router) retransmitted(key LSAKey) bool {
+	for _, ifc := range r.ifaces {
+		for _, nbr := range ifc.neighbors {
+			if nbr.retransmit[key] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// send queues packet to be sent out of the interface, lock must be held.
+func (r *Router) send(ifc *iface, dst net.IP, body Body) {
+	packet := &Packet{RouterID: r.ID, AreaID: r.Area, Body: body}
+	r.out = append(r.out, outgoing{ifc.Port, ifc.Addr.IP, dst, packet})
+}
+
+// unicast returns destination address of packets sent to the neighbor.
+func (r *Router) unicast(ifc *iface, nbr *neighbor) net.IP {
+	if ifc.Type == PointToPoint {
+		return AllSPFRouters
+	}
+
+	return nbr.addr
+}
+
+// hello sends hello packet out of the interface, lock must be held.
+func (r *Router) hello(ifc *iface, now time.Time) {
+	hello := &Hello{
+		Mask:          ifc.Addr.Mask,
+		HelloInterval: uint16(seconds(ifc.HelloInterval)),
+		Options:       OptionE,
+		Priority:      ifc.Priority,
+		DeadInterval:  seconds(ifc.DeadInterval),
+		DR:            ifc.dr,
+		BDR:           ifc.bdr,
+	}
+
+	// Network mask is not used on point-to-point networks.
+	if ifc.Type == PointToPoint {
+		hello.Mask = net.IPv4Mask(0, 0, 0, 0)
+	}
+
+	for _, nbr := range ifc.sorted() {
+		hello.Neighbors = append(hello.Neighbors, nbr.id)
+	}
+
+	r.send(ifc, AllSPFRouters, hello)
+	ifc.helloTime = now.Add(ifc.HelloInterval)
+}
+
+// receiveHello processes hello packet of the neighbor, lock must be held.
+func (r *Router) receiveHello(ifc *iface, id ID, src net.IP, hello *Hello, now time.Time) {
+	if ifc.Type == Broadcast && !bytes.Equal(hello.Mask, ifc.Addr.Mask) {
+		return
+	}
+
+	if uint32(hello.HelloInterval) != seconds(ifc.HelloInterval) ||
+		hello.DeadInterval != seconds(ifc.DeadInterval) {
+		return
+	}
+
+	nbr, ok := ifc.neighbors[id]
+	if !ok {
+		nbr = &neighbor{id: id, state: Init}
+		r.clear(nbr)
+		ifc.neighbors[id] = nbr
+	}
+
+	addr := IDFromIP(src)
+
+	// Neighbor changes its priority or its
+	// designated router declarations.
+	changed := ok && (nbr.priority != hello.Priority ||
+		(nbr.dr == addr) != (hello.DR == addr) ||
+		(nbr.bdr == addr) != (hello.BDR == addr))
+
+	// Neighbor is already the designated or backup designated router.
+	backupSeen := ifc.state == waiting &&
+		(hello.BDR == addr || (hello.DR == addr && hello.BDR == 0))
+
+	nbr.addr = append(net.IP(nil), src.To4()...)
+	nbr.priority, nbr.dr, nbr.bdr = hello.Priority, hello.DR, hello.BDR
+	nbr.dead = now.Add(ifc.DeadInterval)
+
+	seen := false
+	for _, neighbor := range hello.Neighbors {
+		seen = seen || neighbor == r.ID
+	}
+
+	if seen && nbr.state == Init {
+		r.twoWay(ifc, nbr, now)
+		changed = true
+	} else if !seen && nbr.state >= TwoWay {
+		r.clear(nbr)
+		nbr.state = Init
+		changed = true
+	}
+
+	if ifc.Type != Broadcast {
+		return
+	}
+
+	if backupSeen || (changed && ifc.state != waiting) {
+		r.elect(ifc, now)
+	}
+}
+
+// adjacent reports whether adjacency should be
+// formed with the neighbor, lock must be held.
+func (r *Router) adjacent(ifc *iface, nbr *neighbor) bool {
+	if ifc.Type == PointToPoint {
+		return true
+	}
+
+	addr := IDFromIP(nbr.addr)
+	return ifc.state == designated || ifc.state == backup ||
+		addr == ifc.dr || addr == ifc.bdr
+}
+
+// twoWay processes bidirectional communication
+// with the neighbor, lock must be held.
+func (r *Router) twoWay(ifc *iface, nbr *neighbor, now time.Time) {
+	if r.adjacent(ifc, nbr) {
+		r.exstart(ifc, nbr, now)
+		return
+	}
+
+	nbr.state = TwoWay
+}
+
+// clear clears database exchange lists of the neighbor.
+func (r *Router) clear(nbr *neighbor) {
+	nbr.summary, nbr.requests = nil, nil
+	nbr.lastSent, nbr.lastRecv = nil, nil
+	nbr.inflight = make(map[LSAKey]bool)
+	nbr.retransmit = make(map[LSAKey]bool)
+}
+
+// candidate is a router participating in the designated router election.
+type candidate struct {
+	id       ID
+	addr     ID
+	priority uint8
+	dr, bdr  ID
+}
+
+// better reports whether candidate is preferred over the other one.
+func (c *candidate) better(other *candidate) bool {
+	return other == nil || c.priority > other.priority ||
+		(c.priority == other.priority && c.id > other.id)
+}
+
+// electDR returns addresses of the designated and backup designated
+// routers elected among candidates as described in RFC 2328, 9.4.
+func electDR(candidates []candidate) (dr, bdr ID) {
+	var declaredDR, declaredBDR, others *candidate
+
+	for i := range candidates {
+		c := &candidates[i]
+		switch {
+		case c.dr == c.addr:
+			if c.better(declaredDR) {
+				declaredDR = c
+			}
+		case c.bdr == c.addr:
+			if c.better(declaredBDR) {
+				declaredBDR = c
+			}
+		default:
+			if c.better(others) {
+				others = c
+			}
+		}
+	}
+
+	backup := declaredBDR
+	if backup == nil {
+		backup = others
+	}
+
+	designated := declaredDR
+	if designated == nil {
+		designated = backup
+	}
+
+	if backup != nil {
+		bdr = backup.addr
+	}
+
+	if designated != nil {
+		dr = designated.addr
+	}
+
+	return dr, bdr
+}
+
+// elect elects designated and backup designated routers of the
+// broadcast network, adjacencies with neighbors are formed or
+// torn down according to the result, lock must be held.
+func (r *Router) elect(ifc *iface, now time.Time) {
+	self := IDFromIP(ifc.Addr.IP)
+	dr, bdr := ifc.dr, ifc.bdr
+
+	for i := 0; i < 2; i++ {
+		var candidates []candidate
+
+		if ifc.Priority != 0 {
+			candidates = append(candidates, candidate{
+				r.ID, self, ifc.Priority, ifc.dr, ifc.bdr,
+			})
+		}
+
+		for _, nbr := range ifc.sorted() {
+			if nbr.state >= TwoWay && nbr.priority != 0 {
+				candidates = append(candidates, candidate{
+					nbr.id, IDFromIP(nbr.addr), nbr.priority, nbr.dr, nbr.bdr,
+				})
+			}
+		}
+
+		wasDR, wasBDR := ifc.dr == self, ifc.bdr == self
+		ifc.dr, ifc.bdr = electDR(candidates)
+
+		// Election is repeated, when the router itself
+		// becomes or stops being designated router.
+		if wasDR == (ifc.dr == self) && wasBDR == (ifc.bdr == self) {
+			break
+		}
+	}
+
+	switch self {
+	case ifc.dr:
+		ifc.state = designated
+	case ifc.bdr:
+		ifc.state = backup
+	default:
+		ifc.state = drOther
+	}
+
+	// Neighbors are notified about the new designated routers.
+	if dr != ifc.dr || bdr != ifc.bdr {
+		r.hello(ifc, now)
+	}
+
+	for _, nbr := range ifc.sorted() {
+		adjacent := r.adjacent(ifc, nbr)
+
+		if nbr.state == TwoWay && adjacent {
+			r.exstart(ifc, nbr, now)
+		} else if nbr.state >= ExStart && !adjacent {
+			r.clear(nbr)
+			nbr.state = TwoWay
+		}
+	}
+}
+
+// exstart starts database exchange with the neighbor, lock must be held.
+func (r *Router) exstart(ifc *iface, nbr *neighbor, now time.Time) {
+	if r.seq == 0 {
+		r.seq = uint32(now.Unix())
+	}
+
+	r.seq++
+	r.clear(nbr)
+
+	nbr.state = ExStart
+	nbr.seq, nbr.master, nbr.more = r.seq, false, true
+
+	r.describe(ifc, nbr, FlagI|FlagM|FlagMS, nil, now)
+}
+
+// describe sends database description to the neighbor, lock must be held.
+func (r *Router) describe(ifc *iface, nbr *neighbor, flags uint8, headers []LSAHeader, now time.Time) {
+	nbr.lastSent = &DatabaseDescription{
+		MTU:     mtu,
+		Options: OptionE,
+		Flags:   flags,
+		Seq:     nbr.seq,
+		Headers: headers,
+	}
+
+	nbr.ddTime = now.Add(RxmtInterval)
+	r.send(ifc, r.unicast(ifc, nbr), nbr.lastSent)
+}
+
+// duplicate reports whether database description
+// was already received from the neighbor.
+func (nbr *neighbor) duplicate(dd *DatabaseDescription) bool {
+	last := nbr.lastRecv
+	return last != nil && last.Flags == dd.Flags &&
+		last.Options == dd.Options && last.Seq == dd.Seq
+}
+
+// receiveDescription processes database description
+// of the neighbor, lock must be held.
+func (r *Router) receiveDescription(ifc *iface, nbr *neighbor, dd *DatabaseDescription, now time.Time) {
+	if nbr.state == Init {
+		r.twoWay(ifc, nbr, now)
+	}
+
+	switch nbr.state {
+	case ExStart:
+		const negotiation = FlagI | FlagM | FlagMS
+
+		switch {
+		case dd.Flags&negotiation == negotiation && len(dd.Headers) == 0 && nbr.id > r.ID:
+			nbr.master, nbr.seq = true, dd.Seq
+			r.exchange(nbr, now)
+			r.slave(ifc, nbr, dd, now)
+		case dd.Flags&(FlagI|FlagMS) == 0 && dd.Seq == nbr.seq && nbr.id < r.ID:
+			nbr.master = false
+			r.exchange(nbr, now)
+			r.master(ifc, nbr, dd, now)
+		}
+
+	case Exchange:
+		if nbr.duplicate(dd) {
+			// Slave resends the last description,
+			// master discards duplicates.
+			if nbr.master {
+				r.send(ifc, r.unicast(ifc, nbr), nbr.lastSent)
+			}
+			return
+		}
+
+		if (dd.Flags&FlagMS != 0) != nbr.master || dd.Flags&FlagI != 0 {
+			r.exstart(ifc, nbr, now)
+			return
+		}
+
+		switch {
+		case nbr.master && dd.Seq == nbr.seq+1:
+			nbr.seq = dd.Seq
+			r.slave(ifc, nbr, dd, now)
+		case !nbr.master && dd.Seq == nbr.seq:
+			r.master(ifc, nbr, dd, now)
+		default:
+			r.exstart(ifc, nbr, now)
+		}
+
+	case Loading, Full:
+		if !nbr.duplicate(dd) {
+			r.exstart(ifc, nbr, now)
+		} else if nbr.master {
+			r.send(ifc, r.unicast(ifc, nbr), nbr.lastSent)
+		}
+	}
+}
+
+// exchange starts describing the database to the neighbor.
+func (r *Router) exchange(nbr *neighbor, now time.Time) {
+	nbr.state = Exchange
+	nbr.summary = nil
+
+	for _, key := range r.keys() {
+		if lsa := r.db[key].current(now); lsa.Age < MaxAge {
+			nbr.summary = append(nbr.summary, lsa.LSAHeader)
+		}
+	}
+}
+
+// compare compares described LSAs with LSAs of the database and
+// adds missing or outdated ones to the request list of the neighbor.
+func (r *Router) compare(nbr *neighbor, headers []LSAHeader, now time.Time) bool {
+	for _, header := range headers {
+		if header.Type != RouterLSA && header.Type != NetworkLSA {
+			return false
+		}
+
+		e, ok := r.db[header.Key()]
+		if !ok {
+			nbr.requests = append(nbr.requests, header)
+			continue
+		}
+
+		if lsa := e.current(now); header.Compare(&lsa.LSAHeader) > 0 {
+			nbr.requests = append(nbr.requests, header)
+		}
+	}
+
+	return true
+}
+
+// next returns the next portion of database summary of the neighbor.
+func (nbr *neighbor) next() []LSAHeader {
+	n := len(nbr.summary)
+	if n > maxHeaders {
+		n = maxHeaders
+	}
+
+	headers := nbr.summary[:n]
+	nbr.summary = nbr.summary[n:]
+	nbr.more = len(nbr.summary) != 0
+
+	return headers
+}
+
+// master processes description of the slave neighbor and
+// sends the next portion of the database, lock must be held.
+func (r *Router) master(ifc *iface, nbr *neighbor, dd *DatabaseDescription, now time.Time) {
+	if !r.compare(nbr, dd.Headers, now) {
+		r.exstart(ifc, nbr, now)
+		return
+	}
+
+	nbr.lastRecv = dd
+	nbr.seq++
+
+	if !nbr.more && dd.Flags&FlagM == 0 {
+		r.exchangeDone(ifc, nbr, now)
+		return
+	}
+
+	headers := nbr.next()
+
+	flags := FlagMS
+	if nbr.more {
+		flags |= FlagM
+	}
+
+	r.describe(ifc, nbr, flags, headers, now)
+}
+
+// slave processes description of the master neighbor and
+// answers with the next portion of the database, lock must be held.
+func (r *Router) slave(ifc *iface, nbr *neighbor, dd *DatabaseDescription, now time.Time) {
+	if !r.compare(nbr, dd.Headers, now) {
+		r.exstart(ifc, nbr, now)
+		return
+	}
+
+	nbr.lastRecv = dd
+	headers := nbr.next()
+
+	var flags uint8
+	if nbr.more {
+		flags |= FlagM
+	}
+
+	r.describe(ifc, nbr, flags, headers, now)
+
+	if !nbr.more && dd.Flags&FlagM == 0 {
+		r.exchangeDone(ifc, nbr, now)
+	}
+}
+
+// exchangeDone requests missing LSAs, when database
+// exchange is completed, lock must be held.
+func (r *Router) exchangeDone(ifc *iface, nbr *neighbor, now time.Time) {
+	if len(nbr.requests) == 0 {
+		nbr.state = Full
+		return
+	}
+
+	nbr.state = Loading
+	r.request(ifc, nbr, now)
+}
+
+// request sends request of missing LSAs to the neighbor, lock must be held.
+func (r *Router) request(ifc *iface, nbr *neighbor, now time.Time) {
+	request := new(LSRequest)
+	nbr.inflight = make(map[LSAKey]bool)
+
+	for i := 0; i < len(nbr.requests) && i < maxRequests; i++ {
+		key := nbr.requests[i].Key()
+		request.Requests = append(request.Requests, key)
+		nbr.inflight[key] = true
+	}
+
+	nbr.reqTime = now.Add(RxmtInterval)
+	r.send(ifc, r.unicast(ifc, nbr), request)
+}
+
+// unrequest removes LSA from the request list of the neighbor.
+func (r *Router) unrequest(nbr *neighbor, i int) {
+	delete(nbr.inflight, nbr.requests[i].Key())
+	nbr.requests = append(nbr.requests[:i], nbr.requests[i+1:]...)
+
+	if nbr.state == Loading && len(nbr.requests) == 0 {
+		nbr.state = Full
+	}
+}
+
+// requested returns index of the LSA in the request list of
+// the neighbor or negative number, when it was not requested.
+func (nbr *neighbor) requested(key LSAKey) int {
+	for i, header := range nbr.requests {
+		if header.Key() == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// receiveRequest sends requested LSAs to the neighbor, lock must be held.
+func (r *Router) receiveRequest(ifc *iface, nbr *neighbor, request *LSRequest, now time.Time) {
+	if nbr.state < Exchange {
+		return
+	}
+
+	var lsas []LSA
+	for _, key := range request.Requests {
+		e, ok := r.db[key]
+		if !ok {
+			// Neighbor requests unknown LSA.
+			r.exstart(ifc, nbr, now)
+			return
+		}
+
+		lsas = append(lsas, e.current(now))
+	}
+
+	r.update(ifc, r.unicast(ifc, nbr), lsas)
+}
+
+// update sends LSAs out of the interface, LSAs are split
+// into several packets, when necessary, lock must be held.
+func (r *Router) update(ifc *iface, dst net.IP, lsas []LSA) {
+	var update *LSUpdate
+	var size int
+
+	for _, lsa := range lsas {
+		// Account transmission delay of the interface.
+		if lsa.Age < MaxAge {
+			lsa.Age++
+		}
+
+		if update != nil && size+int(lsa.Length) > maxPayload {
+			r.send(ifc, dst, update)
+			update = nil
+		}
+
+		if update == nil {
+			update, size = new(LSUpdate), 4
+		}
+
+		update.LSAs = append(update.LSAs, lsa)
+		size += int(lsa.Length)
+	}
+
+	if update != nil {
+		r.send(ifc, dst, update)
+	}
+}
+
+// receiveUpdate installs LSAs flooded by the neighbor
+// into the database as described in RFC 2328, 13.
+func (r *Router) receiveUpdate(ifc *iface, nbr *neighbor, update *LSUpdate, now time.Time) {
+	if nbr.state < Exchange {
+		return
+	}
+
+	var acks []LSAHeader
+
+	for _, lsa := range update.LSAs {
+		if !lsa.Valid() || (lsa.Type != RouterLSA && lsa.Type != NetworkLSA) {
+			continue
+		}
+
+		if lsa.Age > MaxAge {
+			lsa.Age = MaxAge
+		}
+
+		key := lsa.Key()
+		e, ok := r.db[key]
+
+		// Flushed LSA unknown to the router is acknowledged only.
+		if !ok && lsa.Age == MaxAge && !r.exchanging() {
+			acks = append(acks, lsa.LSAHeader)
+			continue
+		}
+
+		var current LSA
+		if ok {
+			current = e.current(now)
+		}
+
+		switch {
+		case !ok || lsa.Compare(&current.LSAHeader) > 0:
+			if ok && now.Sub(e.installed) < MinLSArrival {
+				continue
+			}
+
+			r.install(lsa, now)
+			r.flood(ifc, nbr, lsa, now)
+			acks = append(acks, lsa.LSAHeader)
+
+		case nbr.requested(key) >= 0:
+			r.exstart(ifc, nbr, now)
+			return
+
+		case lsa.Compare(&current.LSAHeader) == 0:
+			// Instance is treated as implied acknowledgment.
+			if nbr.retransmit[key] {
+				delete(nbr.retransmit, key)
+			} else {
+				acks = append(acks, lsa.LSAHeader)
+			}
+
+		case current.Age != MaxAge || current.Seq != MaxSeq:
+			// Neighbor has an outdated instance.
+			r.update(ifc, r.unicast(ifc, nbr), []LSA{current})
+		}
+	}
+
+	if len(acks) != 0 {
+		r.send(ifc, r.unicast(ifc, nbr), &LSAck{acks})
+	}
+
+	if len(nbr.inflight) == 0 && len(nbr.requests) != 0 {
+		r.request(ifc, nbr, now)
+	}
+}
+
+// receiveAck removes acknowledged LSAs from the
+// retransmission list of the neighbor, lock must be held.
+func (r *Router) receiveAck(nbr *neighbor, ack *LSAck, now time.Time) {
+	if nbr.state < Exchange {
+		return
+	}
+
+	for _, header := range ack.Headers {
+		key := header.Key()
+
+		e, ok := r.db[key]
+		if !ok || !nbr.retransmit[key] {
+			continue
+		}
+
+		if lsa := e.current(now); header.Compare(&lsa.LSAHeader) == 0 {
+			delete(nbr.retransmit, key)
+		}
+	}
+}
+
+// install installs LSA into the database, lock must be held.
+func (r *Router) install(lsa LSA, now time.Time) {
+	key := lsa.Key()
+	old, ok := r.db[key]
+
+	// Previous instance is not retransmitted anymore.
+	for _, ifc := range r.ifaces {
+		for _, nbr := range ifc.neighbors {
+			delete(nbr.retransmit, key)
+		}
+	}
+
+	r.db[key] = &entry{lsa, now}
+
+	if !ok || !old.lsa.sameContents(&lsa) || (old.lsa.Age == MaxAge) != (lsa.Age == MaxAge) {
+		r.dirty = true
+	}
+}
+
+// flood floods LSA received on the interface from the neighbor
+// out of the router interfaces as described in RFC 2328, 13.3,
+// self-originated LSAs are flooded with nil interface and neighbor.
+func (r *Router) flood(from *iface, sender *neighbor, lsa LSA, now time.Time) {
+	key := lsa.Key()
+
+	for _, ifc := range r.ifaces {
+		added := false
+
+		for _, nbr := range ifc.sorted() {
+			if nbr.state < Exchange {
+				continue
+			}
+
+			if i := nbr.requested(key); i >= 0 {
+				cmp := lsa.Compare(&nbr.requests[i])
+				if cmp < 0 {
+					continue
+				}
+
+				r.unrequest(nbr, i)
+				if cmp == 0 {
+					continue
+				}
+			}
+
+			if nbr == sender {
+				continue
+			}
+
+			if len(nbr.retransmit) == 0 {
+				nbr.rxmtTime = now.Add(RxmtInterval)
+			}
+
+			nbr.retransmit[key] = true
+			added = true
+		}
+
+		if !added {
+			continue
+		}
+
+		if ifc == from {
+			// Designated routers flood LSA themselves.
+			addr := IDFromIP(sender.addr)
+			if addr == ifc.dr || addr == ifc.bdr || ifc.state == backup {
+				continue
+			}
+		}
+
+		dst := AllSPFRouters
+		if ifc.state == drOther {
+			dst = AllDRouters
+		}
+
+		r.update(ifc, dst, []LSA{lsa})
+	}
+}
+
+// links returns links of the router LSA, lock must be held.
+func (r *Router) links() []RouterLink {
+	var links []RouterLink
+
+	for _, ifc := range r.ifaces {
+		addr := IDFromIP(ifc.Addr.IP)
+		subnet := network(ifc.Addr)
+
+		stub := RouterLink{StubLink, IDFromIP(subnet.IP),
+			IDFromIP(net.IP(subnet.Mask)), ifc.Cost}
+
+		if ifc.Type == PointToPoint {
+			for _, nbr := range ifc.full() {
+				links = append(links, RouterLink{PointToPointLink, nbr.id, addr, ifc.Cost})
+			}
+
+			links = append(links, stub)
+			continue
+		}
+
+		// Network is transit, when router is fully
+		// adjacent to the designated router.
+		transit := ifc.state == designated && len(ifc.full()) != 0
+		for _, nbr := range ifc.full() {
+			transit = transit || IDFromIP(nbr.addr) == ifc.dr
+		}
+
+		if ifc.state != waiting && transit {
+			links = append(links, RouterLink{TransitLink, ifc.dr, addr, ifc.Cost})
+		} else {
+			links = append(links, stub)
+		}
+	}
+
+	return links
+}
+
+// originate originates router and network LSAs, that describe
+// current state of interfaces, and flushes LSAs, that are not
+// originated anymore, lock must be held.
+func (r *Router) originate(now time.Time) {
+	desired := make(map[LSAKey]LSA)
+
+	if len(r.ifaces) != 0 {
+		lsa := NewRouterLSA(r.ID, InitialSeq, r.links())
+		desired[lsa.Key()] = lsa
+	}
+
+	for _, ifc := range r.ifaces {
+		full := ifc.full()
+		if ifc.state != designated || len(full) == 0 {
+			continue
+		}
+
+		routers := []ID{r.ID}
+		for _, nbr := range full {
+			routers = append(routers, nbr.id)
+		}
+
+		lsa := NewNetworkLSA(ifc.Addr, r.ID, InitialSeq, routers)
+		desired[lsa.Key()] = lsa
+	}
+
+	for _, key := range r.keys() {
+		e := r.db[key]
+		if _, ok := desired[key]; ok || key.AdvRouter != r.ID || e.lsa.Age == MaxAge {
+			continue
+		}
+
+		lsa := e.current(now)
+		lsa.Age = MaxAge
+
+		r.install(lsa, now)
+		r.flood(nil, nil, lsa, now)
+	}
+
+	var keys []LSAKey
+	for key := range desired {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].less(keys[j])
+	})
+
+	for _, key := range keys {
+		lsa := desired[key]
+
+		e, ok := r.db[key]
+		if ok && e.lsa.Age < MaxAge && e.age(now) < LSRefreshTime && e.lsa.sameContents(&lsa) {
+			continue
+		}
+
+		// New instance supersedes the previous one.
+		if ok && e.lsa.Seq < MaxSeq {
+			lsa.Seq = e.lsa.Seq + 1
+			lsa.Sum()
+		}
+
+		r.install(lsa, now)
+		r.flood(nil, nil, lsa, now)
+	}
+}
+
+// commit originates LSAs, calculates routes and returns
+// changes to be notified, lock must be held.
+func (r *Router) commit(now time.Time) (learned, forgotten []Route, out []outgoing) {
+	r.originate(now)
+
+	if r.dirty {
+		learned, forgotten = r.calculate()
+	}
+
+	out, r.out = r.out, nil
+	return learned, forgotten, out
+}
+
+// hop is a next hop of the path to the vertex.
+type hop struct {
+	port uint32
+	addr net.IP
+}
+
+// vertex is a vertex of the shortest path tree.
+type vertex struct {
+	lsa  *LSA
+	dist uint32
+	hops []hop
+
+	// Vertex is a network attached to the router.
+	attached bool
+}
+
+// edge is an edge of the link state graph.
+type edge struct {
+	lsa  *LSA
+	cost uint32
+
+	// Interface address of the router,
+	// that originates the link.
+	data ID
+}
+
+// lookup returns reachable LSA of the database.
+func (r *Router) lookup(key LSAKey) *LSA {
+	if e, ok := r.db[key]; ok && e.lsa.Age < MaxAge {
+		return &e.lsa
+	}
+
+	return nil
+}
+
+// networkLSA returns network LSA of the designated router.
+func (r *Router) networkLSA(id ID) *LSA {
+	for _, key := range r.keys() {
+		if key.Type == NetworkLSA && key.ID == id {
+			return r.lookup(key)
+		}
+	}
+
+	return nil
+}
+
+// backlink returns link of the router LSA to the vertex.
+func backlink(lsa *LSA, v *vertex) (RouterLink, bool) {
+	links, _ := lsa.RouterLinks()
+
+	for _, link := range links {
+		if v.lsa.Type == RouterLSA && link.Type == PointToPointLink && link.ID == v.lsa.ID {
+			return link, true
+		}
+
+		if v.lsa.Type == NetworkLSA && link.Type == TransitLink && link.ID == v.lsa.ID {
+			return link, true
+		}
+	}
+
+	return RouterLink{}, false
+}
+
+// edges returns edges of the vertex, that are confirmed
+// by the links back to the vertex, lock must be held.
+func (r *Router) edges(v *vertex) []edge {
+	var edges []edge
+
+	if v.lsa.Type == NetworkLSA {
+		_, routers, err := v.lsa.Network()
+		if err != nil {
+			return nil
+		}
+
+		for _, id := range routers {
+			lsa := r.lookup(LSAKey{RouterLSA, id, id})
+			if lsa == nil {
+				continue
+			}
+
+			if link, ok := backlink(lsa, v); ok {
+				edges = append(edges, edge{lsa, 0, link.Data})
+			}
+		}
+
+		return edges
+	}
+
+	links, err := v.lsa.RouterLinks()
+	if err != nil {
+		return nil
+	}
+
+	for _, link := range links {
+		var lsa *LSA
+
+		switch link.Type {
+		case PointToPointLink:
+			lsa = r.lookup(LSAKey{RouterLSA, link.ID, link.ID})
+			if lsa != nil {
+				if _, ok := backlink(lsa, v); !ok {
+					lsa = nil
+				}
+			}
+		case TransitLink:
+			lsa = r.networkLSA(link.ID)
+			if lsa != nil {
+				_, routers, _ := lsa.Network()
+
+				attached := false
+				for _, id := range routers {
+					attached = attached || id == v.lsa.AdvRouter
+				}
+
+				if !attached {
+					lsa = nil
+				}
+			}
+		}
+
+		if lsa != nil {
+			edges = append(edges, edge{lsa, uint32(link.Metric), link.Data})
+		}
+	}
+
+	return edges
+}
+
+// nexthops returns next hops of the path to the edge
+// vertex as described in RFC 2328, 16.1.1.
+func (r *Router) nexthops(root, v *vertex, e edge) ([]hop, bool) {
+	if v.attached {
+		// Next hop is the router attached to the network.
+		var hops []hop
+		for _, h := range v.hops {
+			hops = append(hops, hop{h.port, e.data.IP()})
+		}
+
+		return hops, false
+	}
+
+	if v != root {
+		return v.hops, false
+	}
+
+	var ifc *iface
+	for _, i := range r.ifaces {
+		if IDFromIP(i.Addr.IP) == e.data {
+			ifc = i
+		}
+	}
+
+	if ifc == nil {
+		return nil, false
+	}
+
+	if e.lsa.Type == NetworkLSA {
+		return []hop{{ifc.Port, nil}}, true
+	}
+
+	nbr, ok := ifc.neighbors[e.lsa.AdvRouter]
+	if !ok {
+		return nil, false
+	}
+
+	return []hop{{ifc.Port, nbr.addr}}, false
+}
+
+// merge returns union of next hops ordered by port and address.
+func merge(hops, other []hop) []hop {
+	for _, h := range other {
+		found := false
+		for _, existing := range hops {
+			found = found || (existing.port == h.port && existing.addr.Equal(h.addr))
+		}
+
+		if !found {
+			hops = append(hops, h)
+		}
+	}
+
+	sort.Slice(hops, func(i, j int) bool {
+		if hops[i].port != hops[j].port {
+			return hops[i].port < hops[j].port
+		}
+
+		return bytes.Compare(hops[i].addr, hops[j].addr) < 0
+	})
+
+	return hops
+}
+
+// spf calculates shortest paths to the destinations of
+// the area with Dijkstra algorithm, lock must be held.
+func (r *Router) spf() map[string]Route {
+	routes := make(map[string]Route)
+
+	lsa := r.lookup(LSAKey{RouterLSA, r.ID, r.ID})
+	if lsa == nil {
+		return routes
+	}
+
+	root := &vertex{lsa: lsa}
+	tree := make(map[LSAKey]*vertex)
+	candidates := map[LSAKey]*vertex{lsa.Key(): root}
+
+	var order []*vertex
+
+	for len(candidates) != 0 {
+		var v *vertex
+		var vkey LSAKey
+
+		// Networks are preferred over routers on equal distance.
+		for key, c := range candidates {
+			if v == nil || c.dist < v.dist ||
+				(c.dist == v.dist && (key.Type > vkey.Type ||
+					(key.Type == vkey.Type && key.less(vkey)))) {
+				v, vkey = c, key
+			}
+		}
+
+		delete(candidates, vkey)
+		tree[vkey] = v
+		order = append(order, v)
+
+		for _, e := range r.edges(v) {
+			key := e.lsa.Key()
+			if _, ok := tree[key]; ok {
+				continue
+			}
+
+			hops, attached := r.nexthops(root, v, e)
+			if len(hops) == 0 {
+				continue
+			}
+
+			dist := v.dist + e.cost
+
+			c, ok := candidates[key]
+			switch {
+			case !ok || dist < c.dist:
+				candidates[key] = &vertex{e.lsa, dist, merge(nil, hops), attached}
+			case dist == c.dist:
+				c.hops = merge(c.hops, hops)
+			}
+		}
+	}
+
+	add := func(subnet net.IPNet, cost uint32, hops []hop) {
+		if len(hops) == 0 || r.connected(subnet) {
+			return
+		}
+
+		key := subnet.String()
+		if route, ok := routes[key]; ok && route.Cost <= cost {
+			return
+		}
+
+		routes[key] = Route{subnet, hops[0].addr, hops[0].port, cost}
+	}
+
+	for _, v := range order {
+		if v.lsa.Type == NetworkLSA {
+			mask, _, err := v.lsa.Network()
+			if err == nil && !v.attached {
+				add(net.IPNet{v.lsa.ID.IP().Mask(mask), mask}, v.dist, v.hops)
+			}
+
+			continue
+		}
+
+		// Stub networks of the router itself are connected.
+		if v == root {
+			continue
+		}
+
+		links, _ := v.lsa.RouterLinks()
+		for _, link := range links {
+			if link.Type != StubLink {
+				continue
+			}
+
+			mask := net.IPMask(link.Data.IP())
+			add(net.IPNet{link.ID.IP().Mask(mask), mask}, v.dist+uint32(link.Metric), v.hops)
+		}
+	}
+
+	return routes
+}
+
+// calculate calculates routes and returns changed ones, lock must be held.
+func (r *Router) calculate() (learned, forgotten []Route) {
+	r.dirty = false
+	routes := r.spf()
+
+	for _, key := range routeKeys(r.routes) {
+		route, ok := routes[key]
+		if old := r.routes[key]; !ok || !old.NextHop.Equal(route.NextHop) ||
+			old.Port != route.Port || old.Cost != route.Cost {
+			forgotten = append(forgotten, old)
+		}
+	}
+
+	for _, key := range routeKeys(routes) {
+		old, ok := r.routes[key]
+		if route := routes[key]; !ok || !old.NextHop.Equal(route.NextHop) ||
+			old.Port != route.Port || old.Cost != route.Cost {
+			learned = append(learned, route)
+		}
+	}
+
+	r.routes = routes
+	return learned, forgotten
+}
+
+// notify calls callbacks of changed routes and sends packets.
+func (r *Router) notify(learned, forgotten []Route, out []outgoing) {
+	for _, o := range out {
+		if r.Send != nil {
+			r.Send(o.port, o.src, o.dst, o.packet)
+		}
+	}
+
+	for _, route := range forgotten {
+		if r.Forget != nil {
+			r.Forget(route)
+		}
+	}
+
+	for _, route := range learned {
+		if r.Learn != nil {
+			r.Learn(route)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package ospf
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// member is a router interface attached to the network segment.
+type member struct {
+	router *Router
+	port   uint32
+	addr   net.IP
+}
+
+// frame is a packet sent by the router.
+type frame struct {
+	router   *Router
+	port     uint32
+	src, dst net.IP
+	packet   *Packet
+}
+
+// lab connects routers with network segments and
+// delivers packets sent by routers to their neighbors.
+type lab struct {
+	t *testing.T
+
+	segments  [][]member
+	queue     []frame
+	forgotten map[*Router][]Route
+}
+
+func newLab(t *testing.T) *lab {
+	return &lab{t: t, forgotten: make(map[*Router][]Route)}
+}
+
+// router creates a new router, that sends packets through the lab.
+func (l *lab) router() *Router {
+	router := NewRouter()
+
+	router.Send = func(port uint32, src, dst net.IP, packet *Packet) {
+		l.queue = append(l.queue, frame{router, port, src, dst, packet})
+	}
+
+	router.Forget = func(route Route) {
+		l.forgotten[router] = append(l.forgotten[router], route)
+	}
+
+	return router
+}
+
+// attach attaches interfaces to a new network segment.
+func (l *lab) attach(now time.Time, configs map[*Router]InterfaceConfig) {
+	var segment []member
+	for router, config := range configs {
+		segment = append(segment, member{router, config.Port, config.Addr.IP.To4()})
+	}
+
+	l.segments = append(l.segments, segment)
+
+	for router, config := range configs {
+		router.AddInterface(config, now)
+	}
+
+	l.deliver(now)
+}
+
+// detach detaches router from all network segments.
+func (l *lab) detach(router *Router) {
+	for i, segment := range l.segments {
+		var members []member
+		for _, m := range segment {
+			if m.router != router {
+				members = append(members, m)
+			}
+		}
+
+		l.segments[i] = members
+	}
+}
+
+// segment returns members of the segment of the router interface.
+func (l *lab) segment(router *Router, port uint32) []member {
+	for _, segment := range l.segments {
+		for _, m := range segment {
+			if m.router == router && m.port == port {
+				return segment
+			}
+		}
+	}
+
+	return nil
+}
+
+// deliver delivers queued packets to the routers.
+func (l *lab) deliver(now time.Time) {
+	for len(l.queue) != 0 {
+		f := l.queue[0]
+		l.queue = l.queue[1:]
+
+		b, err := f.packet.MarshalBinary()
+		if err != nil {
+			l.t.Fatal("Failed to marshal packet:", err)
+		}
+
+		for _, m := range l.segment(f.router, f.port) {
+			multicast := f.dst.Equal(AllSPFRouters) || f.dst.Equal(AllDRouters)
+			if m.router == f.router || (!multicast && !f.dst.Equal(m.addr)) {
+				continue
+			}
+
+			var packet Packet
+			if err = packet.UnmarshalBinary(b); err != nil {
+				l.t.Fatal("Failed to unmarshal packet:", err)
+			}
+
+			m.router.Receive(m.port, f.src, &packet, now)
+		}
+	}
+}
+
+// run runs routers for the specified duration.
+func (l *lab) run(now time.Time, d time.Duration, routers ...*Router) time.Time {
+	for end := now.Add(d); now.Before(end); now = now.Add(time.Second) {
+		for _, router := range routers {
+			router.Tick(now)
+		}
+
+		l.deliver(now)
+	}
+
+	return now
+}
+
+func config(port uint32, s string, t NetworkType, priority uint8) InterfaceConfig {
+	ip, network, _ := net.ParseCIDR(s)
+	return InterfaceConfig{
+		Port:     port,
+		Addr:     net.IPNet{ip.To4(), network.Mask},
+		Type:     t,
+		Cost:     10,
+		Priority: priority,
+	}
+}
+
+// neighborState returns state of the neighbor with the specified router identifier.
+func neighborState(router *Router, id string) NeighborState {
+	for _, nbr := range router.Neighbors() {
+		if nbr.RouterID == IDFromIP(net.ParseIP(id)) {
+			return nbr.State
+		}
+	}
+
+	return Down
+}
+
+// route returns route to the destination network.
+func route(router *Router, network string) (Route, bool) {
+	for _, route := range router.Routes() {
+		if route.Network.String() == network {
+			return route, true
+		}
+	}
+
+	return Route{}, false
+}
+
+func TestRouterPointToPoint(t *testing.T) {
+	l := newLab(t)
+	now := time.Unix(1000, 0)
+
+	r1, r2 := l.router(), l.router()
+
+	l.attach(now, map[*Router]InterfaceConfig{
+		r1: config(1, "10.0.0.1/30", PointToPoint, 1),
+		r2: config(1, "10.0.0.2/30", PointToPoint, 1),
+	})
+
+	l.attach(now, map[*Router]InterfaceConfig{r1: config(2, "192.168.1.1/24", Broadcast, 1)})
+	l.attach(now, map[*Router]InterfaceConfig{r2: config(2, "192.168.2.1/24", Broadcast, 1)})
+
+	now = l.run(now, 30*time.Second, r1, r2)
+
+	if state := neighborState(r1, "10.0.0.2"); state != Full {
+		t.Fatal("Adjacency is not formed:", state)
+	}
+
+	if n := len(r1.Database(now)); n != 2 {
+		t.Fatal("Link state database is not synchronized:", n)
+	}
+
+	rt, ok := route(r1, "192.168.2.0/24")
+	if !ok || !rt.NextHop.Equal(net.ParseIP("10.0.0.2")) || rt.Port != 1 || rt.Cost != 20 {
+		t.Fatal("Invalid route calculated:", rt, ok)
+	}
+
+	// Network of the interface is connected.
+	if _, ok = route(r2, "10.0.0.0/30"); ok {
+		t.Fatal("Route to connected network calculated")
+	}
+
+	if _, ok = route(r2, "192.168.1.0/24"); !ok {
+		t.Fatal("Route is not calculated:", r2.Routes())
+	}
+
+	// Silent neighbor is declared down after dead interval.
+	l.detach(r2)
+	now = l.run(now, DeadInterval+time.Second, r1)
+
+	if state := neighborState(r1, "10.0.0.2"); state != Down {
+		t.Fatal("Neighbor is not declared down:", state)
+	}
+
+	if len(l.forgotten[r1]) != 1 || l.forgotten[r1][0].Network.String() != "192.168.2.0/24" {
+		t.Fatal("Route is not forgotten:", l.forgotten[r1])
+	}
+}
+
+func TestRouterBroadcast(t *testing.T) {
+	l := newLab(t)
+	now := time.Unix(1000, 0)
+
+	r1, r2, r3 := l.router(), l.router(), l.router()
+
+	l.attach(now, map[*Router]InterfaceConfig{
+		r1: config(1, "10.1.0.1/24", Broadcast, 1),
+		r2: config(1, "10.1.0.2/24", Broadcast, 1),
+		r3: config(1, "10.1.0.3/24", Broadcast, 1),
+	})
+
+	l.attach(now, map[*Router]InterfaceConfig{r3: config(2, "192.168.3.1/24", Broadcast, 0)})
+
+	now = l.run(now, DeadInterval+20*time.Second, r1, r2, r3)
+
+	for _, nbr := range r1.Neighbors() {
+		if nbr.DR.String() != "10.1.0.3" || nbr.BDR.String() != "10.1.0.2" {
+			t.Fatal("Invalid designated routers:", nbr.DR, nbr.BDR)
+		}
+
+		// Routers, that are not designated, form adjacencies
+		// only with designated and backup designated routers.
+		if nbr.State != Full {
+			t.Fatal("Adjacency is not formed:", nbr.RouterID, nbr.State)
+		}
+	}
+
+	if state := neighborState(r2, "10.1.0.3"); state != Full {
+		t.Fatal("Adjacency is not formed:", state)
+	}
+
+	var network bool
+	for _, lsa := range r1.Database(now) {
+		if lsa.Type == NetworkLSA {
+			_, routers, _ := lsa.Network()
+			network = lsa.AdvRouter.String() == "10.1.0.3" && len(routers) == 3
+		}
+	}
+
+	if !network {
+		t.Fatal("Network LSA is not originated:", r1.Database(now))
+	}
+
+	rt, ok := route(r1, "192.168.3.0/24")
+	if !ok || !rt.NextHop.Equal(net.ParseIP("10.1.0.3")) || rt.Cost != 20 {
+		t.Fatal("Invalid route calculated:", rt, ok)
+	}
+}
+
+func TestElectDR(t *testing.T) {
+	candidates := []candidate{
+		{id: 1, addr: 1, priority: 1},
+		{id: 2, addr: 2, priority: 2},
+		{id: 3, addr: 3, priority: 1},
+	}
+
+	dr, bdr := electDR(candidates)
+	if dr != 2 || bdr != 2 {
+		t.Fatal("Invalid routers elected:", dr, bdr)
+	}
+
+	// Declared designated router is preserved.
+	candidates[0].dr = 1
+	candidates[2].bdr = 3
+
+	dr, bdr = electDR(candidates)
+	if dr != 1 || bdr != 3 {
+		t.Fatal("Invalid routers elected:", dr, bdr)
+	}
+}
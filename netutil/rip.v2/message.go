@@ -0,0 +1,134 @@
+package rip
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+const (
+	// Port is a UDP port of RIP routers.
+	Port = 520
+
+	// Version is a version of RIP protocol.
+	Version = 2
+
+	// Infinity is a metric of unreachable destinations.
+	Infinity = 16
+
+	// MaxEntries is a maximum number of entries in a single message.
+	MaxEntries = 25
+
+	// AFInet is an address family identifier of IPv4 addresses.
+	AFInet = 2
+)
+
+// Command is a command of RIP message.
+type Command uint8
+
+const (
+	Request  Command = 1
+	Response Command = 2
+)
+
+const (
+	// headerLen is a length of message header.
+	headerLen = 4
+
+	// entryLen is a length of route entry.
+	entryLen = 20
+)
+
+var (
+	// MulticastAddr is an address of all RIPv2 routers.
+	MulticastAddr = net.IPv4(224, 0, 0, 9).To4()
+
+	// MulticastLinkAddr is a hardware address of all RIPv2 routers.
+	MulticastLinkAddr = net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0x09}
+)
+
+var (
+	// ErrFormat is returned on malformed RIP messages.
+	ErrFormat = errors.New("rip: malformed message")
+)
+
+// Entry is a route entry of RIP message.
+type Entry struct {
+	// Address family identifier.
+	Family uint16
+
+	// Route tag, attribute assigned to a route
+	// learned from other routing protocols.
+	Tag uint16
+
+	// Destination network.
+	Network net.IPNet
+
+	// Immediate next hop, zero address means that
+	// the originator of the message is next hop.
+	NextHop net.IP
+
+	// Distance to the destination.
+	Metric uint32
+}
+
+// Message is a RIP message.
+type Message struct {
+	Command Command
+	Version uint8
+	Entries []Entry
+}
+
+// WholeTable reports whether message requests all routes of the router.
+func (m *Message) WholeTable() bool {
+	return m.Command == Request && len(m.Entries) == 1 &&
+		m.Entries[0].Family == 0 && m.Entries[0].Metric == Infinity
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler interface.
+func (m *Message) UnmarshalBinary(b []byte) error {
+	if len(b) < headerLen || (len(b)-headerLen)%entryLen != 0 {
+		return ErrFormat
+	}
+
+	m.Command, m.Version = Command(b[0]), b[1]
+	m.Entries = nil
+
+	for b = b[headerLen:]; len(b) > 0; b = b[entryLen:] {
+		addr := net.IP(append([]byte(nil), b[4:8]...))
+		mask := net.IPMask(append([]byte(nil), b[8:12]...))
+
+		m.Entries = append(m.Entries, Entry{
+			Family:  binary.BigEndian.Uint16(b[0:2]),
+			Tag:     binary.BigEndian.Uint16(b[2:4]),
+			Network: net.IPNet{addr, mask},
+			NextHop: net.IP(append([]byte(nil), b[12:16]...)),
+			Metric:  binary.BigEndian.Uint32(b[16:20]),
+		})
+	}
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler interface.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	if len(m.Entries) > MaxEntries {
+		return nil, ErrFormat
+	}
+
+	b := make([]byte, headerLen+len(m.Entries)*entryLen)
+	b[0], b[1] = uint8(m.Command), m.Version
+
+	for i, entry := range m.Entries {
+		e := b[headerLen+i*entryLen:]
+
+		binary.BigEndian.PutUint16(e[0:2], entry.Family)
+		binary.BigEndian.PutUint16(e[2:4], entry.Tag)
+		copy(e[4:8], entry.Network.IP.To4())
+		copy(e[8:12], entry.Network.Mask)
+		copy(e[12:16], entry.NextHop.To4())
+		binary.BigEndian.PutUint32(e[16:20], entry.Metric)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,54 @@
+package rip
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMessageMarshal(t *testing.T) {
+	_, network, _ := net.ParseCIDR("192.168.0.0/24")
+
+	message := &Message{Response, Version, []Entry{{
+		Family:  AFInet,
+		Tag:     7,
+		Network: *network,
+		NextHop: net.IPv4(10, 0, 0, 2),
+		Metric:  3,
+	}}}
+
+	b, err := message.MarshalBinary()
+	if err != nil {
+		t.Fatal("Failed to marshal message:", err)
+	}
+
+	if len(b) != headerLen+entryLen || b[0] != uint8(Response) || b[1] != Version {
+		t.Fatal("Invalid message:", b)
+	}
+
+	var parsed Message
+	if err = parsed.UnmarshalBinary(b); err != nil {
+		t.Fatal("Failed to unmarshal message:", err)
+	}
+
+	if parsed.Command != Response || len(parsed.Entries) != 1 {
+		t.Fatal("Invalid parsed message:", parsed)
+	}
+
+	entry := parsed.Entries[0]
+	if entry.Family != AFInet || entry.Tag != 7 || entry.Metric != 3 {
+		t.Fatal("Invalid entry attributes:", entry)
+	}
+
+	if entry.Network.String() != "192.168.0.0/24" || !entry.NextHop.Equal(net.IPv4(10, 0, 0, 2)) {
+		t.Fatal("Invalid entry addresses:", entry)
+	}
+
+	if err = parsed.UnmarshalBinary(b[:headerLen+1]); err != ErrFormat {
+		t.Fatal("Truncated message must not be accepted:", err)
+	}
+
+	request := Message{Request, Version, []Entry{{Metric: Infinity}}}
+	if !request.WholeTable() {
+		t.Fatal("Request of the whole table is not recognized")
+	}
+}
@@ -1,13 +1,502 @@
 package rip
 
 import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/netrack/net/iana"
+	"github.com/netrack/netrack/logging"
 	"github.com/netrack/netrack/mechanism"
+	"github.com/netrack/netrack/netutil/drivers"
+	"github.com/netrack/netrack/netutil/ip.v4"
+	"github.com/netrack/netrack/netutil/ofp.v13"
+	"github.com/netrack/netrack/netutil/udp"
 	"github.com/netrack/openflow"
+	"github.com/netrack/openflow/ofp.v13"
+	"github.com/netrack/openflow/ofp.v13/ofputil"
 )
 
+const RIPMechanismName = "rip"
+
+// tickInterval is an interval between checks of route timers.
+const tickInterval = time.Second
+
+func init() {
+	constructor := mech.RoutingMechanismConstructorFunc(NewRIPMechanism)
+	mech.RegisterRoutingMechanism(RIPMechanismName, constructor)
+}
+
+// errMechanism is returned, when mechanism required
+// to send messages is not available on the switch.
+var errMechanism = errors.New("rip: mechanism is not available")
+
+// isIPv4 reports whether address belongs to IPv4 network.
+func isIPv4(addr mech.NetworkAddr) bool {
+	return len(addr.Bytes()) == net.IPv4len
+}
+
+// ripMatch matches RIP messages received on the port.
+func ripMatch(port uint32) ofp.Match {
+	return ofp.Match{ofp.MT_OXM, []ofp.OXM{
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IN_PORT, of.Bytes(ofp.PortNo(port)), nil},
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_ETH_TYPE, of.Bytes(iana.ETHT_IPV4), nil},
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_IP_PROTO, of.Bytes(iana.IP_PROTO_UDP), nil},
+		ofp.OXM{ofp.XMC_OPENFLOW_BASIC, ofp.XMT_OFB_UDP_DST, of.Bytes(uint16(Port)), nil},
+	}}
+}
+
+// RIPMechanism implements RIPv2 routing protocol, it runs on the
+// networks connected to the switch ports and installs routes learned
+// from neighbor routers to the routing tables of other mechanisms.
 type RIPMechanism struct {
-	mech.BaseMechanismDriver
+	mech.BaseRoutingMechanism
+
+	// Handle request based on cookie value.
+	cookies *mech.CookieFilter
+
+	// Stores registered handlers to delete them later.
+	filter *of.ServeFilter
+
+	// RIP routing table.
+	router *Router
+
+	stopCh chan bool
+	lock   sync.Mutex
+}
+
+func NewRIPMechanism() mech.RoutingMechanism {
+	m := &RIPMechanism{
+		cookies: mech.NewCookieFilter(),
+		filter:  of.NewServeFilter(),
+	}
+
+	m.router = m.newRouter()
+	return m
+}
+
+func (m *RIPMechanism) Name() string {
+	return RIPMechanismName
+}
+
+func (m *RIPMechanism) Description() string {
+	return "RIPv2 routing protocol"
+}
+
+// Version implements VersionedMechanism interface.
+func (m *RIPMechanism) Version() string {
+	return ofp13.ProtoVersion
+}
+
+// Routes returns routes of RIP routing table.
+func (m *RIPMechanism) Routes() []Route {
+	return m.router.Routes()
+}
+
+// newRouter returns router, that sends messages through the switch.
+func (m *RIPMechanism) newRouter() *Router {
+	router := NewRouter()
+	router.Send = m.send
+	router.Learn = m.learn
+	router.Forget = m.forget
+
+	return router
+}
+
+// Enable implements Mechanism interface.
+func (m *RIPMechanism) Enable(c *mech.MechanismContext) {
+	m.BaseRoutingMechanism.Enable(c)
+
+	m.filter.Mux = m.C.Mux
+	// Handle incoming RIP messages.
+	m.filter.HandleFunc(of.T_PACKET_IN, m.packetInHandler)
+	m.filter.HandleFunc(of.T_FLOW_REMOVED, m.flowRemovedHandler)
+
+	log.InfoLog("rip/ENABLE_HOOK", "Mechanism RIP enabled")
+}
+
+// Activate implements Mechanism interface.
+func (m *RIPMechanism) Activate() {
+	m.BaseRoutingMechanism.Activate()
+
+	// Operate on PacketIn messages
+	m.cookies.Baker = ofputil.PacketInBaker()
+
+	m.start()
+}
+
+// Disable implements Mechanism interface.
+func (m *RIPMechanism) Disable() {
+	m.BaseRoutingMechanism.Disable()
+
+	m.stop()
+	m.filter.Unhandle()
+
+	log.InfoLog("rip/DISABLE_HOOK", "Mechanism RIP disabled")
+}
+
+// Deactivate implements Mechanism interface.
+func (m *RIPMechanism) Deactivate() {
+	m.BaseRoutingMechanism.Deactivate()
+
+	m.stop()
+	m.filter.Unhandle()
+
+	// Drop handlers of cookies of installed flows.
+	m.cookies = mech.NewCookieFilter()
+
+	// Learned routes are gone along with the switch.
+	m.router = m.newRouter()
+
+	log.InfoLog("rip/DEACTIVATE_HOOK", "Mechanism RIP deactivated")
+}
+
+// OwnsFlow implements mech.FlowOwner interface.
+func (m *RIPMechanism) OwnsFlow(table int, cookie uint64) bool {
+	return m.cookies.Contains(cookie)
+}
+
+// start starts route timers in a separate goroutine.
+func (m *RIPMechanism) start() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.stopCh != nil {
+		return
+	}
+
+	m.stopCh = make(chan bool)
+	go m.run(m.stopCh)
+}
+
+// stop stops route timers.
+func (m *RIPMechanism) stop() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.stopCh != nil {
+		close(m.stopCh)
+		m.stopCh = nil
+	}
+}
+
+func (m *RIPMechanism) run(stopCh chan bool) {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	updateTicker := time.NewTicker(UpdateInterval)
+	defer updateTicker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			m.router.Tick(time.Now())
+		case <-updateTicker.C:
+			m.router.Update()
+		}
+	}
+}
+
+// UpdateRoute starts RIP on the networks connected to the switch,
+// routes of other types are not advertised to neighbors.
+func (m *RIPMechanism) UpdateRoute(context *mech.RoutingContext) error {
+	if context.Type != mech.ConnectedRoute || !isIPv4(context.Network) {
+		return nil
+	}
+
+	log.DebugLog("rip/UPDATE_ROUTE",
+		"Got update route request: ", context.Network)
+
+	instructions := ofp.Instructions{ofp.InstructionActions{
+		ofp.IT_APPLY_ACTIONS,
+		ofp.Actions{ofp.ActionOutput{ofp.P_CONTROLLER, ofp.CML_NO_BUFFER}},
+	}}
+
+	flowMod := ofp.FlowMod{
+		Command:  ofp.FC_ADD,
+		BufferID: ofp.NO_BUFFER,
+		// Notify controller, when flow removed
+		Flags:        ofp.FF_SEND_FLOW_REM,
+		Priority:     40, // Use non-zero priority
+		Match:        ripMatch(context.Port),
+		Instructions: instructions,
+	}
+
+	// Assign cookie to FlowMod message, and
+	// redirect such requests to ripHandler
+	m.cookies.FilterFunc(&flowMod, m.ripHandler)
+
+	r, err := of.NewRequest(of.T_FLOW_MOD, of.NewReader(&flowMod))
+	if err != nil {
+		log.ErrorLog("rip/UPDATE_ROUTE",
+			"Failed to create a new ofp_flow_mod request: ", err)
+		return err
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("rip/UPDATE_ROUTE",
+			"Failed to send request: ", err)
+		return err
+	}
+
+	m.router.AddNetwork(context.Port, net.IPNet{
+		net.IP(context.Network.Bytes()),
+		net.IPMask(context.Network.Mask().Bytes()),
+	})
+
+	return nil
+}
+
+func (m *RIPMechanism) DeleteRoute(context *mech.RoutingContext) error {
+	if context.Type != mech.ConnectedRoute || !isIPv4(context.Network) {
+		return nil
+	}
+
+	log.DebugLog("rip/DELETE_ROUTE",
+		"Got delete route request: ", context.Network)
+
+	m.router.DeleteNetwork(context.Port, net.IPNet{
+		net.IP(context.Network.Bytes()),
+		net.IPMask(context.Network.Mask().Bytes()),
+	}, time.Now())
+
+	// RIP still runs on other networks of the port.
+	if len(m.router.Networks(context.Port)) != 0 {
+		return nil
+	}
+
+	err := of.Send(m.C.Switch.Conn(),
+		ofputil.FlowFlush(0, ripMatch(context.Port)),
+	)
+
+	if err != nil {
+		log.ErrorLog("rip/DELETE_ROUTE",
+			"Failed to send requests: ", err)
+	}
+
+	return err
+}
+
+func (m *RIPMechanism) packetInHandler(rw of.ResponseWriter, r *of.Request) {
+	m.cookies.Serve(rw, r)
+}
+
+func (m *RIPMechanism) flowRemovedHandler(rw of.ResponseWriter, r *of.Request) {
+	var flowRemoved ofp.FlowRemoved
+
+	_, err := of.ReadAllFrom(r.Body, &flowRemoved)
+	if err != nil {
+		return
+	}
+
+	m.cookies.Release(&flowRemoved)
+}
+
+// routingContext returns routing context of the learned route.
+func (m *RIPMechanism) routingContext(route Route) (*mech.RoutingContext, error) {
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		return nil, err
+	}
+
+	context := &mech.RoutingContext{
+		Type:    mech.RIPRoute,
+		Network: nldriver.CreateAddr(route.Network.IP, route.Network.Mask),
+		NextHop: nldriver.CreateAddr(route.NextHop, nil),
+		Driver:  nldriver,
+		Port:    route.Port,
+		Metric:  int(route.Metric),
+	}
+
+	return context, nil
+}
+
+// routing returns routing mechanism manager of the switch.
+func (m *RIPMechanism) routing() (mech.RoutingMechanismManager, error) {
+	var routing mech.RoutingMechanismManager
+	err := m.C.Managers.Obtain(&routing)
+	return routing, err
+}
+
+// learn installs learned route to the routing tables.
+func (m *RIPMechanism) learn(route Route) {
+	context, err := m.routingContext(route)
+	if err != nil {
+		return
+	}
+
+	routing, err := m.routing()
+	if err != nil {
+		log.ErrorLog("rip/LEARN_ROUTE",
+			"Failed to obtain routing manager: ", err)
+		return
+	}
+
+	if err = routing.LearnRoute(context); err != nil {
+		log.ErrorLog("rip/LEARN_ROUTE",
+			"Failed to install learned route: ", err)
+	}
 }
 
-func (m *RIPMech) Initialize(c *mech.MechanismDriverContext) {
+// forget removes unreachable route from the routing tables.
+func (m *RIPMechanism) forget(route Route) {
+	context, err := m.routingContext(route)
+	if err != nil {
+		return
+	}
+
+	routing, err := m.routing()
+	if err != nil {
+		log.ErrorLog("rip/FORGET_ROUTE",
+			"Failed to obtain routing manager: ", err)
+		return
+	}
+
+	if err = routing.ForgetRoute(context); err != nil {
+		log.ErrorLog("rip/FORGET_ROUTE",
+			"Failed to remove unreachable route: ", err)
+	}
+}
+
+// vlan returns VLAN identifier of the interface of the port.
+func (m *RIPMechanism) vlan(port uint32) (uint16, error) {
+	var network mech.NetworkMechanismManager
+	if err := m.C.Managers.Obtain(&network); err != nil {
+		return 0, err
+	}
+
+	nmech, err := network.Mechanism(ip.ARPMechanismName)
+	if err != nil {
+		return 0, err
+	}
+
+	arpMech, ok := nmech.(*ip.ARPMechanism)
+	if !ok {
+		return 0, errMechanism
+	}
+
+	return arpMech.VLAN(port), nil
+}
+
+// send sends message to all RIP routers of the network connected to the port.
+func (m *RIPMechanism) send(port uint32, src net.IP, message *Message) {
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		return
+	}
+
+	lladdr, err := lldriver.Addr(port)
+	if err != nil {
+		log.ErrorLog("rip/SEND_MESSAGE",
+			"Failed to retrieve port hardware address: ", err)
+		return
+	}
+
+	vlan, err := m.vlan(port)
+	if err != nil {
+		log.ErrorLog("rip/SEND_MESSAGE",
+			"Failed to retrieve VLAN of the port: ", err)
+		return
+	}
+
+	body, err := message.MarshalBinary()
+	if err != nil {
+		log.ErrorLog("rip/SEND_MESSAGE",
+			"Failed to marshal RIP message: ", err)
+		return
+	}
+
+	pdu2 := mech.LinkFrame{lldriver.CreateAddr(MulticastLinkAddr),
+		lladdr, mech.Proto(iana.ETHT_IPV4), vlan, 0,
+	}
+
+	pdu3 := mech.NetworkPacket{
+		DstAddr: nldriver.CreateAddr(MulticastAddr, nil),
+		SrcAddr: nldriver.CreateAddr(src, nil),
+		Proto:   mech.Proto(iana.IP_PROTO_UDP),
+		Payload: bytes.NewReader(udp.MakeDatagram(Port, Port, body)),
+	}
+
+	packetOut := ofp.PacketOut{
+		BufferID: ofp.NO_BUFFER,
+		InPort:   ofp.P_CONTROLLER,
+		Actions:  ofp.Actions{ofp.ActionOutput{ofp.PortNo(port), 0}},
+	}
+
+	llwriter := mech.MakeLinkWriterTo(lldriver, &pdu2)
+	nlwriter := mech.MakeNetworkWriterTo(nldriver, &pdu3)
+
+	r, err := of.NewRequest(of.T_PACKET_OUT, of.NewReader(&packetOut, llwriter, nlwriter))
+	if err != nil {
+		log.ErrorLog("rip/SEND_MESSAGE",
+			"Failed to create a new ofp_packet_out request: ", err)
+		return
+	}
+
+	if err = of.Send(m.C.Switch.Conn(), r); err != nil {
+		log.ErrorLog("rip/SEND_MESSAGE",
+			"Failed to send RIP message: ", err)
+	}
+}
+
+func (m *RIPMechanism) ripHandler(rw of.ResponseWriter, r *of.Request) {
+	var packet ofp.PacketIn
+	var pdu2 mech.LinkFrame
+	var pdu3 mech.NetworkPacket
+
+	lldriver, err := mech.LinkDrv(m.C)
+	if err != nil {
+		return
+	}
+
+	nldriver, err := mech.NetworkDrv(m.C, drivers.IPv4DriverName)
+	if err != nil {
+		return
+	}
+
+	llreader := mech.MakeLinkReaderFrom(lldriver, &pdu2)
+	nlreader := mech.MakeNetworkReaderFrom(nldriver, &pdu3)
+
+	if _, err = of.ReadAllFrom(r.Body, &packet, llreader, nlreader); err != nil {
+		log.ErrorLog("rip/RIP_HANDLER",
+			"Failed to read packet: ", err)
+		return
+	}
+
+	srcPort, _, payload, err := udp.ReadDatagram(r.Body, pdu3.ContentLen)
+	if err != nil {
+		log.ErrorLog("rip/RIP_HANDLER",
+			"Failed to read datagram: ", err)
+		return
+	}
+
+	var message Message
+	if err = message.UnmarshalBinary(payload); err != nil {
+		log.ErrorLog("rip/RIP_HANDLER",
+			"Failed to read RIP message: ", err)
+		return
+	}
+
+	// Responses are accepted only from RIP port of other routers.
+	if message.Command == Response && srcPort != Port {
+		return
+	}
+
+	// Get port number from match fields.
+	portNo := packet.Match.Field(ofp.XMT_OFB_IN_PORT).Value.UInt32()
+
+	log.DebugLogf("rip/RIP_HANDLER",
+		"Got RIP message %d from %s", message.Command, pdu3.SrcAddr)
+
+	src := net.IP(pdu3.SrcAddr.Bytes())
+	m.router.Receive(portNo, src, &message, time.Now())
 }
@@ -0,0 +1,487 @@
+package rip
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// UpdateInterval is an interval between regular updates.
+	UpdateInterval = 30 * time.Second
+
+	// Timeout is a time, after which not confirmed route
+	// becomes unreachable.
+	Timeout = 180 * time.Second
+
+	// GarbageTime is a time, unreachable route is
+	// advertised to neighbors before removal.
+	GarbageTime = 120 * time.Second
+)
+
+// Route is an entry of RIP routing table.
+type Route struct {
+	// Destination network.
+	Network net.IPNet
+
+	// Address of the next router, nil for connected networks.
+	NextHop net.IP
+
+	// Switch port number, the route goes through.
+	Port uint32
+
+	// Distance to the destination.
+	Metric uint32
+
+	// Route tag.
+	Tag uint16
+
+	// Network is connected to the router interface.
+	Connected bool
+
+	// Time, when route becomes unreachable.
+	expires time.Time
+
+	// Time, when unreachable route is removed.
+	garbage time.Time
+
+	// Route is changed since the last update.
+	changed bool
+}
+
+// iface is a network address of the router interface.
+type iface struct {
+	port uint32
+	addr net.IPNet
+}
+
+// outgoing is a message to be sent out of the switch port.
+type outgoing struct {
+	port    uint32
+	src     net.IP
+	message *Message
+}
+
+// Router implements routing table maintenance of RIPv2 protocol, it
+// does not send messages itself, but provides them through callbacks.
+type Router struct {
+	// Send is called to send message out of the switch port,
+	// message is sent to all routers of the interface network.
+	Send func(port uint32, src net.IP, message *Message)
+
+	// Learn is called, when route to the destination is learned.
+	Learn func(Route)
+
+	// Forget is called, when learned route becomes unreachable.
+	Forget func(Route)
+
+	ifaces []iface
+	routes map[string]*Route
+	lock   sync.Mutex
+}
+
+// NewRouter creates a new instance of Router type.
+func NewRouter() *Router {
+	return &Router{routes: make(map[string]*Route)}
+}
+
+// Networks returns interface addresses of the port, RIP runs on.
+func (r *Router) Networks(port uint32) []net.IPNet {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var addrs []net.IPNet
+	for _, ifc := range r.ifaces {
+		if ifc.port == port {
+			addrs = append(addrs, ifc.addr)
+		}
+	}
+
+	return addrs
+}
+
+// Routes returns routes of the routing table ordered by destination.
+func (r *Router) Routes() []Route {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var routes []Route
+	for _, key := range r.keys() {
+		routes = append(routes, *r.routes[key])
+	}
+
+	return routes
+}
+
+// keys returns sorted keys of routes, lock must be held.
+func (r *Router) keys() []string {
+	var keys []string
+	for key := range r.routes {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// network returns network of the address.
+func network(addr net.IPNet) net.IPNet {
+	ip := addr.IP.To4()
+	return net.IPNet{ip.Mask(addr.Mask), addr.Mask}
+}
+
+// connected reports whether address belongs to one of
+// the networks, connected to the port, lock must be held.
+func (r *Router) connected(port uint32, addr net.IP) bool {
+	for _, i := range r.ifaces {
+		if i.port == port && i.addr.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// local reports whether address is assigned to the router, lock must be held.
+func (r *Router) local(addr net.IP) bool {
+	for _, i := range r.ifaces {
+		if i.addr.IP.Equal(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// poison makes route unreachable and starts its garbage collection.
+func (route *Route) poison(now time.Time) {
+	route.Metric = Infinity
+	route.garbage = now.Add(GarbageTime)
+	route.changed = true
+}
+
+// AddNetwork starts RIP on the interface address of the port,
+// network of the address is advertised to other routers.
+func (r *Router) AddNetwork(port uint32, addr net.IPNet) {
+	var forgotten []Route
+
+	r.lock.Lock()
+
+	addr.IP = addr.IP.To4()
+	r.ifaces = append(r.ifaces, iface{port, addr})
+
+	route := &Route{
+		Network:   network(addr),
+		Port:      port,
+		Metric:    1,
+		Connected: true,
+		changed:   true,
+	}
+
+	key := route.Network.String()
+
+	// Connected network replaces learned route.
+	if learned, ok := r.routes[key]; ok && !learned.Connected && learned.Metric < Infinity {
+		forgotten = append(forgotten, *learned)
+	}
+
+	r.routes[key] = route
+
+	// Request routes of neighbors on the new interface.
+	request := &Message{Request, Version, []Entry{{Metric: Infinity}}}
+	out := append(r.triggered(), outgoing{port, addr.IP, request})
+
+	r.lock.Unlock()
+	r.notify(nil, forgotten, out)
+}
+
+// DeleteNetwork stops RIP on the interface address of the port,
+// routes through the network of the address become unreachable.
+func (r *Router) DeleteNetwork(port uint32, addr net.IPNet, now time.Time) {
+	var forgotten []Route
+
+	r.lock.Lock()
+
+	addr.IP = addr.IP.To4()
+
+	for i, ifc := range r.ifaces {
+		if ifc.port == port && ifc.addr.IP.Equal(addr.IP) {
+			r.ifaces = append(r.ifaces[:i], r.ifaces[i+1:]...)
+			break
+		}
+	}
+
+	subnet := network(addr)
+
+	for _, route := range r.routes {
+		if route.Port != port || route.Metric == Infinity {
+			continue
+		}
+
+		if route.Connected && route.Network.String() == subnet.String() {
+			route.Connected = false
+			route.poison(now)
+			continue
+		}
+
+		// Next hop is not reachable anymore.
+		if !route.Connected && subnet.Contains(route.NextHop) {
+			forgotten = append(forgotten, *route)
+			route.poison(now)
+		}
+	}
+
+	out := r.triggered()
+
+	r.lock.Unlock()
+	r.notify(nil, forgotten, out)
+}
+
+// Receive processes message received from the router
+// with the specified address on the switch port.
+func (r *Router) Receive(port uint32, src net.IP, message *Message, now time.Time) {
+	r.lock.Lock()
+
+	// Messages from own interfaces and from routers of
+	// not directly connected networks are ignored.
+	if message.Version < Version || r.local(src) || !r.connected(port, src) {
+		r.lock.Unlock()
+		return
+	}
+
+	var learned, forgotten []Route
+	var out []outgoing
+
+	switch message.Command {
+	case Request:
+		// Whole routing table is sent to all routers of the
+		// interface, as requests are sent during start up.
+		if message.WholeTable() {
+			out = r.update(port, false)
+		}
+	case Response:
+		learned, forgotten = r.response(port, src, message, now)
+		out = r.triggered()
+	}
+
+	r.lock.Unlock()
+	r.notify(learned, forgotten, out)
+}
+
+// response updates routing table with the entries of
+// the response message, lock must be held.
+func (r *Router) response(port uint32, src net.IP, message *Message, now time.Time) (learned, forgotten []Route) {
+	for _, entry := range message.Entries {
+		ip, mask := entry.Network.IP.To4(), entry.Network.Mask
+		if entry.Family != AFInet || ip == nil || len(mask) != net.IPv4len {
+			continue
+		}
+
+		if entry.Metric < 1 || entry.Metric > Infinity {
+			continue
+		}
+
+		metric := entry.Metric + 1
+		if metric > Infinity {
+			metric = Infinity
+		}
+
+		// Originator of the message advertises another next hop.
+		nexthop := src.To4()
+		if nh := entry.NextHop.To4(); nh != nil && !nh.Equal(net.IPv4zero) && r.connected(port, nh) {
+			nexthop = nh
+		}
+
+		subnet := net.IPNet{ip.Mask(mask), mask}
+		key := subnet.String()
+
+		route, ok := r.routes[key]
+
+		switch {
+		case !ok:
+			if metric == Infinity {
+				continue
+			}
+
+			route = &Route{Network: subnet, NextHop: nexthop, Port: port,
+				Metric: metric, Tag: entry.Tag, expires: now.Add(Timeout), changed: true}
+
+			r.routes[key] = route
+			learned = append(learned, *route)
+
+		case route.Connected:
+			continue
+
+		case route.Port == port && route.NextHop.Equal(nexthop):
+			if metric < Infinity {
+				route.expires = now.Add(Timeout)
+				route.garbage = time.Time{}
+			}
+
+			if metric == route.Metric {
+				continue
+			}
+
+			route.Metric, route.Tag, route.changed = metric, entry.Tag, true
+
+			// Route with the changed metric is learned again,
+			// so the routing table keeps the actual metric.
+			if metric == Infinity {
+				route.poison(now)
+				forgotten = append(forgotten, *route)
+			} else {
+				learned = append(learned, *route)
+			}
+
+		case metric < route.Metric:
+			if route.Metric < Infinity {
+				forgotten = append(forgotten, *route)
+			}
+
+			*route = Route{Network: subnet, NextHop: nexthop, Port: port,
+				Metric: metric, Tag: entry.Tag, expires: now.Add(Timeout), changed: true}
+
+			learned = append(learned, *route)
+		}
+	}
+
+	return learned, forgotten
+}
+
+// Tick expires routes, that were not confirmed in time,
+// and removes unreachable routes after garbage collection time.
+func (r *Router) Tick(now time.Time) {
+	var forgotten []Route
+
+	r.lock.Lock()
+
+	for key, route := range r.routes {
+		if !route.garbage.IsZero() && !now.Before(route.garbage) {
+			delete(r.routes, key)
+			continue
+		}
+
+		if route.Connected || route.Metric == Infinity || now.Before(route.expires) {
+			continue
+		}
+
+		forgotten = append(forgotten, *route)
+		route.poison(now)
+	}
+
+	out := r.triggered()
+
+	r.lock.Unlock()
+	r.notify(nil, forgotten, out)
+}
+
+// Update sends whole routing table to the neighbors.
+func (r *Router) Update() {
+	r.lock.Lock()
+
+	var out []outgoing
+	for _, ifc := range r.ifaces {
+		out = append(out, r.messages(ifc, false)...)
+	}
+
+	r.clear()
+
+	r.lock.Unlock()
+	r.notify(nil, nil, out)
+}
+
+// update returns messages with routing table for all interface
+// addresses of the port, lock must be held.
+func (r *Router) update(port uint32, changed bool) []outgoing {
+	var out []outgoing
+	for _, ifc := range r.ifaces {
+		if ifc.port == port {
+			out = append(out, r.messages(ifc, changed)...)
+		}
+	}
+
+	return out
+}
+
+// triggered returns messages with changed routes for all
+// interfaces and clears route changes, lock must be held.
+func (r *Router) triggered() []outgoing {
+	var out []outgoing
+	for _, ifc := range r.ifaces {
+		out = append(out, r.messages(ifc, true)...)
+	}
+
+	r.clear()
+	return out
+}
+
+// clear marks all routes as advertised, lock must be held.
+func (r *Router) clear() {
+	for _, route := range r.routes {
+		route.changed = false
+	}
+}
+
+// messages returns response messages with routes to be sent
+// out of the interface. Split horizon with poisoned reverse is
+// applied: routes learned through the port are advertised back
+// as unreachable. Lock must be held.
+func (r *Router) messages(ifc iface, changed bool) []outgoing {
+	var out []outgoing
+	var entries []Entry
+
+	for _, key := range r.keys() {
+		route := r.routes[key]
+		if changed && !route.changed {
+			continue
+		}
+
+		metric := route.Metric
+		if route.Port == ifc.port && !route.Connected {
+			metric = Infinity
+		}
+
+		entries = append(entries, Entry{
+			Family:  AFInet,
+			Tag:     route.Tag,
+			Network: route.Network,
+			NextHop: net.IPv4zero,
+			Metric:  metric,
+		})
+	}
+
+	for len(entries) > 0 {
+		n := len(entries)
+		if n > MaxEntries {
+			n = MaxEntries
+		}
+
+		message := &Message{Response, Version, entries[:n]}
+		out = append(out, outgoing{ifc.port, ifc.addr.IP, message})
+		entries = entries[n:]
+	}
+
+	return out
+}
+
+// notify calls callbacks of changed routes and sends messages.
+func (r *Router) notify(learned, forgotten []Route, out []outgoing) {
+	for _, route := range forgotten {
+		if r.Forget != nil {
+			r.Forget(route)
+		}
+	}
+
+	for _, route := range learned {
+		if r.Learn != nil {
+			r.Learn(route)
+		}
+	}
+
+	for _, o := range out {
+		if r.Send != nil {
+			r.Send(o.port, o.src, o.message)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package rip
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// sent is a message sent by the router.
+type sent struct {
+	port    uint32
+	src     net.IP
+	message *Message
+}
+
+// fakePeer is a scripted neighbor router, it records
+// messages and route changes of the tested router.
+type fakePeer struct {
+	addr      net.IP
+	port      uint32
+	sent      []sent
+	learned   []Route
+	forgotten []Route
+}
+
+func newFakePeer(router *Router, addr net.IP, port uint32) *fakePeer {
+	peer := &fakePeer{addr: addr, port: port}
+
+	router.Send = func(port uint32, src net.IP, message *Message) {
+		peer.sent = append(peer.sent, sent{port, src, message})
+	}
+
+	router.Learn = func(route Route) {
+		peer.learned = append(peer.learned, route)
+	}
+
+	router.Forget = func(route Route) {
+		peer.forgotten = append(peer.forgotten, route)
+	}
+
+	return peer
+}
+
+// advertise sends response with the routes to the router.
+func (p *fakePeer) advertise(router *Router, now time.Time, routes map[string]uint32) {
+	message := &Message{Command: Response, Version: Version}
+
+	for s, metric := range routes {
+		_, network, _ := net.ParseCIDR(s)
+		message.Entries = append(message.Entries, Entry{
+			Family: AFInet, Network: *network, NextHop: net.IPv4zero, Metric: metric,
+		})
+	}
+
+	router.Receive(p.port, p.addr, message, now)
+}
+
+// metric returns metric of the route to the network
+// advertised out of the port, zero if not advertised.
+func (p *fakePeer) metric(port uint32, network string) uint32 {
+	for _, s := range p.sent {
+		if s.port != port || s.message.Command != Response {
+			continue
+		}
+
+		for _, entry := range s.message.Entries {
+			if entry.Network.String() == network {
+				return entry.Metric
+			}
+		}
+	}
+
+	return 0
+}
+
+func (p *fakePeer) reset() {
+	p.sent, p.learned, p.forgotten = nil, nil, nil
+}
+
+func cidr(s string) net.IPNet {
+	ip, network, _ := net.ParseCIDR(s)
+	return net.IPNet{ip, network.Mask}
+}
+
+func TestRouterNetworks(t *testing.T) {
+	router := NewRouter()
+	peer := newFakePeer(router, net.IPv4(10, 0, 0, 2), 1)
+
+	router.AddNetwork(1, cidr("10.0.0.1/24"))
+
+	if len(peer.sent) != 2 || !peer.sent[1].message.WholeTable() {
+		t.Fatal("Routes of neighbors must be requested:", peer.sent)
+	}
+
+	if peer.metric(1, "10.0.0.0/24") != 1 {
+		t.Fatal("Connected network must be advertised:", peer.sent)
+	}
+
+	if !peer.sent[0].src.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Fatal("Interface address must be used as source:", peer.sent[0].src)
+	}
+
+	if networks := router.Networks(1); len(networks) != 1 {
+		t.Fatal("Invalid interface networks:", networks)
+	}
+
+	peer.reset()
+	router.DeleteNetwork(1, cidr("10.0.0.1/24"), time.Now())
+
+	if networks := router.Networks(1); len(networks) != 0 {
+		t.Fatal("Interface network must be removed:", networks)
+	}
+
+	routes := router.Routes()
+	if len(routes) != 1 || routes[0].Metric != Infinity || routes[0].Connected {
+		t.Fatal("Removed network must become unreachable:", routes)
+	}
+}
+
+func TestRouterReceive(t *testing.T) {
+	now := time.Now()
+
+	router := NewRouter()
+	peer := newFakePeer(router, net.IPv4(10, 0, 0, 2), 1)
+
+	router.AddNetwork(1, cidr("10.0.0.1/24"))
+	router.AddNetwork(2, cidr("10.0.1.1/24"))
+
+	peer.reset()
+	peer.advertise(router, now, map[string]uint32{
+		"192.168.0.0/24": 1,
+		"172.16.0.0/16":  15,
+		"10.0.1.0/24":    1,
+	})
+
+	if len(peer.learned) != 1 || peer.learned[0].Network.String() != "192.168.0.0/24" {
+		t.Fatal("Reachable route must be learned:", peer.learned)
+	}
+
+	route := peer.learned[0]
+	if route.Metric != 2 || route.Port != 1 || !route.NextHop.Equal(peer.addr) {
+		t.Fatal("Invalid learned route:", route)
+	}
+
+	// Split horizon with poisoned reverse.
+	if peer.metric(1, "192.168.0.0/24") != Infinity {
+		t.Fatal("Route must be poisoned on the learning port:", peer.sent)
+	}
+
+	if peer.metric(2, "192.168.0.0/24") != 2 {
+		t.Fatal("Triggered update must be sent to other ports:", peer.sent)
+	}
+
+	// Routers of not directly connected networks are ignored.
+	peer.reset()
+	router.Receive(1, net.IPv4(10, 0, 5, 2), &Message{Response, Version, []Entry{{
+		Family: AFInet, Network: cidr("192.168.1.0/24"), Metric: 1,
+	}}}, now)
+
+	if len(peer.learned) != 0 || len(peer.sent) != 0 {
+		t.Fatal("Message of unknown router must be ignored:", peer.learned)
+	}
+
+	// Metric change of the same route is learned again.
+	peer.advertise(router, now, map[string]uint32{"192.168.0.0/24": 4})
+
+	if len(peer.learned) != 1 || peer.learned[0].Metric != 5 || len(peer.forgotten) != 0 {
+		t.Fatal("Route must be updated in place:", peer.learned, peer.forgotten)
+	}
+
+	if peer.metric(2, "192.168.0.0/24") != 5 {
+		t.Fatal("Changed metric must be advertised:", peer.sent)
+	}
+
+	// The same metric is not learned twice.
+	peer.reset()
+	peer.advertise(router, now, map[string]uint32{"192.168.0.0/24": 4})
+
+	if len(peer.learned) != 0 {
+		t.Fatal("Refreshed route must not be learned:", peer.learned)
+	}
+
+	// Better route through another router replaces current one.
+	peer.reset()
+	other := &fakePeer{addr: net.IPv4(10, 0, 1, 2), port: 2}
+	other.advertise(router, now, map[string]uint32{"192.168.0.0/24": 1})
+
+	if len(peer.forgotten) != 1 || len(peer.learned) != 1 || peer.learned[0].Port != 2 {
+		t.Fatal("Route must be replaced:", peer.learned, peer.forgotten)
+	}
+
+	// Unreachable destination is withdrawn immediately.
+	peer.reset()
+	other.advertise(router, now, map[string]uint32{"192.168.0.0/24": Infinity})
+
+	if len(peer.forgotten) != 1 || peer.metric(1, "192.168.0.0/24") != Infinity {
+		t.Fatal("Unreachable route must be withdrawn:", peer.forgotten, peer.sent)
+	}
+
+	// Whole table is sent back on request.
+	peer.reset()
+	router.Receive(1, peer.addr, &Message{Request, Version, []Entry{{Metric: Infinity}}}, now)
+
+	if len(peer.sent) != 1 || peer.sent[0].port != 1 || peer.metric(1, "10.0.1.0/24") != 1 {
+		t.Fatal("Routing table must be sent on request:", peer.sent)
+	}
+}
+
+func TestRouterTimers(t *testing.T) {
+	now := time.Now()
+
+	router := NewRouter()
+	peer := newFakePeer(router, net.IPv4(10, 0, 0, 2), 1)
+
+	router.AddNetwork(1, cidr("10.0.0.1/24"))
+	router.AddNetwork(2, cidr("10.0.1.1/24"))
+	peer.advertise(router, now, map[string]uint32{"192.168.0.0/24": 1})
+
+	// Confirmation of the route restarts timeout.
+	peer.advertise(router, now.Add(Timeout/2), map[string]uint32{"192.168.0.0/24": 1})
+
+	peer.reset()
+	router.Tick(now.Add(Timeout))
+
+	if len(peer.forgotten) != 0 {
+		t.Fatal("Confirmed route must not expire:", peer.forgotten)
+	}
+
+	expired := now.Add(Timeout/2 + Timeout)
+	router.Tick(expired)
+
+	if len(peer.forgotten) != 1 || peer.metric(2, "192.168.0.0/24") != Infinity {
+		t.Fatal("Expired route must be withdrawn:", peer.forgotten, peer.sent)
+	}
+
+	// Unreachable route is advertised during garbage collection.
+	peer.reset()
+	router.Update()
+
+	if peer.metric(2, "192.168.0.0/24") != Infinity {
+		t.Fatal("Unreachable route must be advertised:", peer.sent)
+	}
+
+	router.Tick(expired.Add(GarbageTime))
+
+	for _, route := range router.Routes() {
+		if route.Network.String() == "192.168.0.0/24" {
+			t.Fatal("Unreachable route must be removed:", route)
+		}
+	}
+
+	// Routes through the removed network are withdrawn.
+	peer.advertise(router, expired, map[string]uint32{"192.168.0.0/24": 1})
+
+	peer.reset()
+	router.DeleteNetwork(1, cidr("10.0.0.1/24"), expired)
+
+	if len(peer.forgotten) != 1 || peer.metric(2, "10.0.0.0/24") != Infinity {
+		t.Fatal("Routes through removed network must be withdrawn:", peer.forgotten)
+	}
+}
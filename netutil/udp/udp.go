@@ -0,0 +1,49 @@
+package udp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// HeaderLen is a length of UDP header.
+const HeaderLen = 8
+
+var (
+	// ErrFormat is returned on malformed UDP datagrams.
+	ErrFormat = errors.New("udp: malformed datagram")
+)
+
+// ReadDatagram reads UDP datagram of the specified length and
+// returns source and destination ports along with payload.
+func ReadDatagram(r io.Reader, n int64) (src, dst uint16, payload []byte, err error) {
+	if n < HeaderLen {
+		return 0, 0, nil, ErrFormat
+	}
+
+	b := make([]byte, n)
+	if _, err = io.ReadFull(r, b); err != nil {
+		return 0, 0, nil, err
+	}
+
+	length := int64(binary.BigEndian.Uint16(b[4:6]))
+	if length < HeaderLen || length > n {
+		return 0, 0, nil, ErrFormat
+	}
+
+	src = binary.BigEndian.Uint16(b[0:2])
+	dst = binary.BigEndian.Uint16(b[2:4])
+	return src, dst, b[HeaderLen:length], nil
+}
+
+// MakeDatagram returns UDP datagram with the payload, checksum
+// is not calculated, since it is optional for IPv4.
+func MakeDatagram(src, dst uint16, payload []byte) []byte {
+	b := make([]byte, HeaderLen, HeaderLen+len(payload))
+
+	binary.BigEndian.PutUint16(b[0:2], src)
+	binary.BigEndian.PutUint16(b[2:4], dst)
+	binary.BigEndian.PutUint16(b[4:6], uint16(HeaderLen+len(payload)))
+
+	return append(b, payload...)
+}
@@ -0,0 +1,25 @@
+package udp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDatagram(t *testing.T) {
+	payload := []byte{1, 2, 3}
+	b := MakeDatagram(67, 68, payload)
+
+	src, dst, data, err := ReadDatagram(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal("Failed to read datagram:", err)
+	}
+
+	if src != 67 || dst != 68 || !bytes.Equal(data, payload) {
+		t.Fatal("Invalid datagram:", src, dst, data)
+	}
+
+	_, _, _, err = ReadDatagram(bytes.NewReader(b[:4]), 4)
+	if err != ErrFormat {
+		t.Fatal("Truncated datagram must not be accepted:", err)
+	}
+}